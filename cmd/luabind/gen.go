@@ -0,0 +1,440 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// directive marks the types and functions to bind in their doc comments.
+const directive = "//luabind:export"
+
+// generator generates the bindings of a package.
+type generator struct {
+	pkg   string
+	names func(string) string // Lua name of a Go name
+	open  string              // name of the function opening the functions
+	types []*typeInfo
+	funcs []*funcInfo
+	buf   bytes.Buffer
+}
+
+// typeInfo is a struct type to bind.
+type typeInfo struct {
+	name    string
+	fields  []*fieldInfo
+	methods []*funcInfo
+}
+
+// fieldInfo is a field of a struct type to bind, of a basic type.
+type fieldInfo struct {
+	name string
+	typ  string
+}
+
+// funcInfo is a function or method to bind.
+type funcInfo struct {
+	name    string
+	recv    string // name of the receiver type of a method
+	params  []string
+	results []string
+	errs    bool // the last result is an error, not in results
+}
+
+// basicTypes are the types of the fields, parameters and results that bind
+// directly to Lua values.
+var basicTypes = map[string]bool{
+	"bool": true, "string": true, "float32": true, "float64": true,
+	"int": true, "int8": true, "int16": true, "int32": true, "int64": true,
+	"uint": true, "uint8": true, "uint16": true, "uint32": true, "uint64": true,
+}
+
+// parse parses the Go files of dir, except tests and output, and collects the
+// types and functions to bind.
+func (g *generator) parse(dir, output string) error {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go") && fi.Name() != filepath.Base(output)
+	}, parser.ParseComments)
+	if err != nil {
+		return err
+	}
+	if len(pkgs) != 1 {
+		return fmt.Errorf("%s: found %d packages; want 1", dir, len(pkgs))
+	}
+	var methods []*ast.FuncDecl
+	for name, pkg := range pkgs {
+		g.pkg = name
+		var files []string
+		for file := range pkg.Files {
+			files = append(files, file)
+		}
+		sort.Strings(files)
+		for _, file := range files {
+			for _, decl := range pkg.Files[file].Decls {
+				switch decl := decl.(type) {
+				case *ast.GenDecl:
+					for _, spec := range decl.Specs {
+						ts, ok := spec.(*ast.TypeSpec)
+						if !ok || !exported(decl.Doc) && !exported(ts.Doc) {
+							continue
+						}
+						st, ok := ts.Type.(*ast.StructType)
+						if !ok {
+							return fmt.Errorf("%s: %s is not a struct type", fset.Position(ts.Pos()), ts.Name.Name)
+						}
+						g.types = append(g.types, structInfo(ts.Name.Name, st))
+					}
+				case *ast.FuncDecl:
+					switch {
+					case decl.Recv != nil:
+						methods = append(methods, decl)
+					case exported(decl.Doc):
+						fn, err := g.funcInfo(decl)
+						if err != nil {
+							return fmt.Errorf("%s: %v", fset.Position(decl.Pos()), err)
+						}
+						g.funcs = append(g.funcs, fn)
+					}
+				}
+			}
+		}
+	}
+	// The exported methods of the bound types are bound too, unless their
+	// signature does not bind.
+	for _, decl := range methods {
+		recv := typeName(decl.Recv.List[0].Type)
+		recv = strings.TrimPrefix(recv, "*")
+		t := g.lookup(recv)
+		if t == nil || !decl.Name.IsExported() {
+			continue
+		}
+		fn, err := g.funcInfo(decl)
+		if err != nil {
+			if exported(decl.Doc) {
+				return fmt.Errorf("%s: %v", fset.Position(decl.Pos()), err)
+			}
+			fmt.Fprintf(os.Stderr, "luabind: skipping %s.%s: %v\n", recv, decl.Name.Name, err)
+			continue
+		}
+		fn.recv = recv
+		t.methods = append(t.methods, fn)
+	}
+	return nil
+}
+
+// exported reports whether the doc comment has the directive.
+func exported(doc *ast.CommentGroup) bool {
+	if doc != nil {
+		for _, c := range doc.List {
+			if strings.TrimSpace(c.Text) == directive {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// structInfo returns the type info of the struct type st, with its exported
+// fields of basic types.
+func structInfo(name string, st *ast.StructType) *typeInfo {
+	t := &typeInfo{name: name}
+	for _, f := range st.Fields.List {
+		typ := typeName(f.Type)
+		if !basicTypes[typ] {
+			continue
+		}
+		for _, id := range f.Names {
+			if id.IsExported() {
+				t.fields = append(t.fields, &fieldInfo{id.Name, typ})
+			}
+		}
+	}
+	return t
+}
+
+// lookup returns the bound type name, or nil.
+func (g *generator) lookup(name string) *typeInfo {
+	for _, t := range g.types {
+		if t.name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// typeName returns the name of the type expression, such as "int" or "*T",
+// or "" if it is not a name or a pointer to a name.
+func typeName(expr ast.Expr) string {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		return expr.Name
+	case *ast.StarExpr:
+		if id, ok := expr.X.(*ast.Ident); ok {
+			return "*" + id.Name
+		}
+	}
+	return ""
+}
+
+// bindable reports whether values of type typ bind.
+func (g *generator) bindable(typ string) bool {
+	return basicTypes[typ] || strings.HasPrefix(typ, "*") && g.lookup(typ[1:]) != nil
+}
+
+// funcInfo returns the info of the function or method decl, or an error if
+// its signature does not bind.
+func (g *generator) funcInfo(decl *ast.FuncDecl) (*funcInfo, error) {
+	fn := &funcInfo{name: decl.Name.Name}
+	for _, p := range decl.Type.Params.List {
+		typ := typeName(p.Type)
+		if !g.bindable(typ) {
+			return nil, fmt.Errorf("parameter of unsupported type %s", exprString(p.Type))
+		}
+		for range namesOf(p) {
+			fn.params = append(fn.params, typ)
+		}
+	}
+	if decl.Type.Results != nil {
+		for _, r := range decl.Type.Results.List {
+			for range namesOf(r) {
+				fn.results = append(fn.results, typeName(r.Type))
+			}
+		}
+	}
+	if n := len(fn.results); n > 0 && fn.results[n-1] == "error" {
+		fn.results, fn.errs = fn.results[:n-1], true
+	}
+	for _, typ := range fn.results {
+		if !g.bindable(typ) {
+			return nil, fmt.Errorf("result of unsupported type %q", typ)
+		}
+	}
+	return fn, nil
+}
+
+// namesOf returns the names of the field, or one blank for an unnamed one.
+func namesOf(f *ast.Field) []*ast.Ident {
+	if len(f.Names) == 0 {
+		return []*ast.Ident{nil}
+	}
+	return f.Names
+}
+
+func exprString(expr ast.Expr) string {
+	var b bytes.Buffer
+	format.Node(&b, token.NewFileSet(), expr)
+	return b.String()
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// generate returns the formatted source of the bindings.
+func (g *generator) generate() ([]byte, error) {
+	g.printf("// Code generated by luabind. DO NOT EDIT.\n\n")
+	g.printf("package %s\n\n", g.pkg)
+	g.printf("import (\n\t\"fmt\"\n\n\t\"github.com/Azure/golua/lua\"\n)\n\n")
+	for _, t := range g.types {
+		g.genMethods(t)
+		g.genIndex(t)
+		g.genSetIndex(t)
+	}
+	if len(g.funcs) > 0 {
+		g.genOpen()
+	}
+	g.genHelpers()
+	src, err := format.Source(g.buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("formatting generated code: %v", err)
+	}
+	return src, nil
+}
+
+// genMethods generates the lua.HasMethods implementation of t.
+func (g *generator) genMethods(t *typeInfo) {
+	g.printf("// Methods implements lua.HasMethods.\n")
+	g.printf("func (*%s) Methods() map[string]lua.Func {\n", t.name)
+	g.printf("return map[string]lua.Func{\n")
+	for _, m := range t.methods {
+		g.printf("%q: func(state *lua.State) int {\n", g.names(m.name))
+		g.printf("x := state.CheckGoValue(1, (*%s)(nil)).(*%s)\n", t.name, t.name)
+		g.genCall(m, "x."+m.name, 2)
+		g.printf("},\n")
+	}
+	g.printf("}\n}\n\n")
+}
+
+// genCall generates the call of fn, whose arguments start at index arg, and
+// the pushing of its results.
+func (g *generator) genCall(fn *funcInfo, call string, arg int) {
+	var args []string
+	for i, typ := range fn.params {
+		args = append(args, checkArg(typ, arg+i))
+	}
+	var rets []string
+	for i := range fn.results {
+		rets = append(rets, fmt.Sprintf("r%d", i))
+	}
+	if fn.errs {
+		rets = append(rets, "err")
+	}
+	call = fmt.Sprintf("%s(%s)", call, strings.Join(args, ", "))
+	if len(rets) > 0 {
+		g.printf("%s := %s\n", strings.Join(rets, ", "), call)
+	} else {
+		g.printf("%s\n", call)
+	}
+	if fn.errs {
+		g.printf("if err != nil {\nstate.Errorf(\"%%v\", err)\n}\n")
+	}
+	for i, typ := range fn.results {
+		g.printf("state.Push(%s)\n", pushValue(typ, fmt.Sprintf("r%d", i)))
+	}
+	g.printf("return %d\n", len(fn.results))
+}
+
+// checkArg returns the expression checking the argument at index of type typ.
+func checkArg(typ string, index int) string {
+	switch {
+	case typ == "bool":
+		return fmt.Sprintf("state.ToBool(%d)", index)
+	case typ == "string":
+		return fmt.Sprintf("state.CheckString(%d)", index)
+	case typ == "int64":
+		return fmt.Sprintf("state.CheckInt(%d)", index)
+	case typ == "float64":
+		return fmt.Sprintf("state.CheckNumber(%d)", index)
+	case typ == "float32":
+		return fmt.Sprintf("float32(state.CheckNumber(%d))", index)
+	case strings.HasPrefix(typ, "*"):
+		return fmt.Sprintf("state.CheckGoValue(%d, (%s)(nil)).(%s)", index, typ, typ)
+	}
+	return fmt.Sprintf("%s(state.CheckInt(%d))", typ, index)
+}
+
+// pushValue returns the expression pushing the Go value v of type typ.
+func pushValue(typ, v string) string {
+	switch {
+	case typ == "bool", typ == "string", typ == "int64", typ == "float64", strings.HasPrefix(typ, "*"):
+		return v
+	case strings.HasPrefix(typ, "float"):
+		return fmt.Sprintf("float64(%s)", v)
+	}
+	return fmt.Sprintf("int64(%s)", v)
+}
+
+// luaValue returns the expression converting the Go value v of the basic type
+// typ to a lua.Value.
+func luaValue(typ, v string) string {
+	switch {
+	case typ == "bool":
+		return fmt.Sprintf("lua.Bool(%s)", v)
+	case typ == "string":
+		return fmt.Sprintf("lua.String(%s)", v)
+	case strings.HasPrefix(typ, "float"):
+		return fmt.Sprintf("lua.Float(%s)", v)
+	}
+	return fmt.Sprintf("lua.Int(%s)", v)
+}
+
+// genIndex generates the lua.HasIndex implementation of t, for its fields.
+func (g *generator) genIndex(t *typeInfo) {
+	g.printf("// Index implements lua.HasIndex.\n")
+	g.printf("func (x *%s) Index(key lua.Value) (lua.Value, error) {\n", t.name)
+	if len(t.fields) > 0 {
+		g.printf("switch key {\n")
+		for _, f := range t.fields {
+			g.printf("case lua.String(%q):\nreturn %s, nil\n", g.names(f.name), luaValue(f.typ, "x."+f.name))
+		}
+		g.printf("}\n")
+	}
+	g.printf("return lua.None, nil\n}\n\n")
+}
+
+// genSetIndex generates the lua.HasNewIndex implementation of t, for its
+// fields.
+func (g *generator) genSetIndex(t *typeInfo) {
+	g.printf("// SetIndex implements lua.HasNewIndex.\n")
+	g.printf("func (x *%s) SetIndex(key, value lua.Value) error {\n", t.name)
+	if len(t.fields) > 0 {
+		g.printf("switch key {\n")
+		for _, f := range t.fields {
+			name := g.names(f.name)
+			g.printf("case lua.String(%q):\n", name)
+			var conv, v string
+			switch {
+			case f.typ == "bool":
+				conv, v = "value.(lua.Bool)", "bool(v)"
+			case f.typ == "string":
+				conv, v = "value.(lua.String)", "string(v)"
+			case strings.HasPrefix(f.typ, "float"):
+				conv, v = "luabindFloat(value)", f.typ+"(v)"
+			default:
+				conv, v = "luabindInt(value)", f.typ+"(v)"
+			}
+			g.printf("v, ok := %s\nif !ok {\n", conv)
+			g.printf("return fmt.Errorf(\"cannot assign a %%v value to field '%s' of type %s\", value.Type())\n}\n", name, f.typ)
+			g.printf("x.%s = %s\nreturn nil\n", f.name, v)
+		}
+		g.printf("}\n")
+	}
+	g.printf("return fmt.Errorf(\"no field '%%v' in %s\", key)\n}\n\n", t.name)
+}
+
+// genOpen generates the function opening the bound functions.
+func (g *generator) genOpen() {
+	g.printf("// %s opens the functions of package %s bound to Lua, and returns\n", g.open, g.pkg)
+	g.printf("// them in a table; it is a lua.Func for State.Require or State.Preload.\n")
+	g.printf("func %s(state *lua.State) int {\n", g.open)
+	g.printf("var funcs = map[string]lua.Func{\n")
+	for _, fn := range g.funcs {
+		g.printf("%q: func(state *lua.State) int {\n", g.names(fn.name))
+		g.genCall(fn, fn.name, 1)
+		g.printf("},\n")
+	}
+	g.printf("}\n")
+	g.printf("state.NewTableSize(0, len(funcs))\nstate.SetFuncs(funcs, 0)\nreturn 1\n}\n\n")
+}
+
+// genHelpers generates the conversions of numbers assigned to fields.
+func (g *generator) genHelpers() {
+	g.printf(`// luabindInt returns the Lua number v as an integer, if it has an exact
+// integer representation.
+func luabindInt(v lua.Value) (int64, bool) {
+	switch v := v.(type) {
+	case lua.Int:
+		return int64(v), true
+	case lua.Float:
+		if i := int64(v); lua.Float(i) == v {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// luabindFloat returns the Lua number v as a float.
+func luabindFloat(v lua.Value) (float64, bool) {
+	switch v := v.(type) {
+	case lua.Int:
+		return float64(v), true
+	case lua.Float:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+var (
+	_ = luabindInt
+	_ = luabindFloat
+)
+`)
+}
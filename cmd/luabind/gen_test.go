@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Azure/golua/lua"
+)
+
+const gameSource = `package game
+
+//luabind:export
+type Player struct {
+	Name   string
+	Health int
+	Speed  float32
+	secret int
+}
+
+func (p *Player) MoveTo(x, y float64) {}
+
+func (p *Player) Damage(n int) (int, error) { return 0, nil }
+
+func (p *Player) Skip(m map[string]int) {}
+
+//luabind:export
+func NewPlayer(name string) *Player { return &Player{Name: name} }
+`
+
+// gameTest calls the generated bindings from the game package.
+const gameTest = `package game
+
+import (
+	"testing"
+
+	"github.com/Azure/golua/lua"
+)
+
+func TestBindings(t *testing.T) {
+	state := lua.NewState()
+	defer state.Close()
+	state.Require("game", OpenLua, false)
+
+	state.GetField(1, "new_player")
+	state.Push("ann")
+	if err := state.PCall(1, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	state.Push(10)
+	state.SetField(2, "health")
+	state.GetField(2, "move_to")
+	state.PushIndex(2)
+	state.Push(1.0)
+	state.Push(2.0)
+	if err := state.PCall(3, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	state.GetField(2, "damage")
+	state.PushIndex(2)
+	state.Push(3)
+	if err := state.PCall(2, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	state.GetField(2, "name")
+	state.GetField(2, "health")
+	if name, health, damage := state.ToString(4), state.ToInt(5), state.ToInt(3); name != "ann" || health != 10 || damage != 0 {
+		t.Errorf("name, health, damage = %q, %d, %d; want %q, 10, 0", name, health, damage, "ann")
+	}
+}
+`
+
+func TestGenerate(t *testing.T) {
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "game.go"), []byte(gameSource), 0666); err != nil {
+		t.Fatal(err)
+	}
+	g := &generator{open: "OpenLua", names: lua.SnakeCase}
+	if err := g.parse(dir, "game_luabind.go"); err != nil {
+		t.Fatal(err)
+	}
+	src, err := g.generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), "game_luabind.go", src, 0); err != nil {
+		t.Fatalf("generated code does not parse: %v\n%s", err, src)
+	}
+	for _, want := range []string{
+		`"move_to": func(state *lua.State) int {`,
+		`r0, err := x.Damage(int(state.CheckInt(2)))`,
+		`case lua.String("health"):`,
+		`x.Speed = float32(v)`,
+		`r0 := NewPlayer(state.CheckString(1))`,
+		`func OpenLua(state *lua.State) int {`,
+	} {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("generated code lacks %q:\n%s", want, src)
+		}
+	}
+	for _, unwanted := range []string{"secret", "skip"} {
+		if strings.Contains(string(src), unwanted) {
+			t.Errorf("generated code binds %q:\n%s", unwanted, src)
+		}
+	}
+
+	// Build the game package in a module that uses this tree, and run
+	// its test calling the bindings.
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip(err)
+	}
+	root, err := filepath.Abs("../..")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"game_luabind.go": string(src),
+		"game_test.go":    gameTest,
+		"go.mod": fmt.Sprintf("module game\n\ngo 1.16\n\nrequire github.com/Azure/golua v0.0.0\n\nreplace github.com/Azure/golua => %s\n",
+			filepath.ToSlash(root)),
+	}
+	for name, text := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(text), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmd := exec.Command(goTool, "test", "-mod=mod", ".")
+	cmd.Dir = dir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go test of the generated package: %v\n%s", err, out)
+	}
+}
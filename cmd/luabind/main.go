@@ -0,0 +1,69 @@
+// Command luabind generates static Lua bindings for Go types and functions,
+// for hot paths where the reflection of lua.Bind is too slow. It is meant to
+// be run by go generate in the package to bind:
+//
+//	//go:generate luabind -snake
+//
+// It binds the struct types and the functions whose doc comment has the line
+//
+//	//luabind:export
+//
+// For a struct type T, it generates the Methods, Index and SetIndex methods
+// of *T, so that values pushed with State.Push expose the exported fields of T
+// that are booleans, strings and numbers as properties, and the exported
+// methods of T and *T whose parameters and results are such values or
+// pointers to bound types; a last error result is raised. For functions, it
+// generates a lua.Func, OpenLua by default, that returns them in a table.
+// Arguments are checked and results pushed with direct State calls.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/Azure/golua/lua"
+)
+
+var (
+	snake  bool   = false
+	open   string = "OpenLua"
+	output string = ""
+)
+
+func must(err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "fatal: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func init() {
+	flag.BoolVar(&snake, "snake", snake, "name fields, methods and functions in snake case")
+	flag.StringVar(&open, "open", open, "name of the function opening the bound functions")
+	flag.StringVar(&output, "o", output, "output file (default <package>_luabind.go)")
+}
+
+func main() {
+	flag.Parse()
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+	g := &generator{open: open, names: func(name string) string { return name }}
+	if snake {
+		g.names = lua.SnakeCase
+	}
+	must(g.parse(dir, output))
+	if output == "" {
+		output = g.pkg + "_luabind.go"
+	}
+	if len(g.types) == 0 && len(g.funcs) == 0 {
+		must(fmt.Errorf("no %s types or functions in %s", directive, dir))
+	}
+	src, err := g.generate()
+	must(err)
+	must(ioutil.WriteFile(filepath.Join(dir, output), src, 0666))
+}
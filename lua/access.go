@@ -84,7 +84,7 @@ func (state *State) CheckInt(index int) int64 {
 // See https://www.lua.org/manual/5.3/manual.html#luaL_checkany
 func (state *State) CheckAny(index int) Value {
 	if state.TypeAt(index) == NoneType {
-		argError(state, index, "value expected")
+		argError(state, index, state.Message(MsgValueExpected))
 	}
 	return state.get(index)
 }
@@ -96,7 +96,7 @@ func (state *State) CheckAny(index int) Value {
 //
 // See https://www.lua.org/manual/5.3/manual.html#pdf-optstring
 func (state *State) OptString(index int, optStr string) string {
-	if state.TypeAt(index) == NoneType {
+	if state.IsNoneOrNil(index) {
 		return optStr
 	}
 	return state.CheckString(index)
@@ -107,7 +107,7 @@ func (state *State) OptString(index int, optStr string) string {
 //
 // See https://www.lua.org/manual/5.3/manual.html#luaL_optnumber
 func (state *State) OptNumber(index int, optNum float64) float64 {
-	if state.TypeAt(index) == NoneType {
+	if state.IsNoneOrNil(index) {
 		return optNum
 	}
 	return state.CheckNumber(index)
@@ -120,7 +120,7 @@ func (state *State) OptNumber(index int, optNum float64) float64 {
 //
 // See https://www.lua.org/manual/5.3/manual.html#luaL_optinteger
 func (state *State) OptInt(index int, optInt int64) int64 {
-	if state.TypeAt(index) == NoneType {
+	if state.IsNoneOrNil(index) {
 		return optInt
 	}
 	return state.CheckInt(index)
@@ -136,28 +136,30 @@ func (state *State) ToUserData(index int) *Object {
 	return nil
 }
 
-// ToStringMeta converts any Lua value at the given index to a C string in a reasonable format. The resulting
-// string is pushed onto the stack and also returned by the function. If len is not NULL, the function also
-// sets *len with the string length.
+// ToStringMeta converts any Lua value at the given index to a string in a reasonable format. The resulting
+// string is pushed onto the stack and also returned by the function.
 //
-// If the value has a metatable with a __tostring field, then luaL_tolstring calls the corresponding
-// metamethod with the value as argument, and uses the result of the call as its result.
+// If the value has a metatable with a __tostring field, then ToStringMeta calls the corresponding
+// metamethod with the value as argument, and uses the result of the call as its result. Otherwise,
+// tables, functions, threads and userdata are shown as "name: 0x...", where name is the __name
+// field of the metatable if it is a string, or else the type name.
 //
 // See https://www.lua.org/manual/5.3/manual.html#luaL_tolstring
 func (state *State) ToStringMeta(index int) string {
+	index = state.AbsIndex(index)
 	if state.CallMeta(index, "__tostring") {
 		s, ok := state.TryString(-1)
 		if ok {
 			return s
 		}
-		state.Errorf("'__tostring' must return a string")
+		state.Raise(MsgToStringResult)
 	} else {
 		switch kind := state.TypeAt(index); kind {
 		case NumberType:
 			if state.IsInt(index) {
 				state.Push(fmt.Sprintf("%d", state.ToInt(index)))
 			} else {
-				state.Push(fmt.Sprintf("%.14g", state.ToNumber(index)))
+				state.Push(formatFloat(state.ToNumber(index)))
 			}
 		case StringType:
 			state.PushIndex(index)
@@ -3,6 +3,8 @@ package lua
 import (
 	"fmt"
 	"io"
+	"os/exec"
+	"sort"
 	"syscall"
 )
 
@@ -20,12 +22,74 @@ func (state *State) ArgCheck(cond bool, arg int, msg string) {
 // function that called it, using a standard message that includes msg as
 // a comment: ```bad argument #arg to 'funcname' (msg)```.
 //
+// The function is named as in the code that called it, or else after its field
+// in package.loaded, such as 'string.rep'. For a method call, the arguments are
+// counted from after self, and a bad self reads ```calling 'funcname' on bad
+// self (msg)```.
+//
 // This function never returns.
 //
 // See https://www.lua.org/manual/5.3/manual.html#luaL_argerror
 func (state *State) ArgError(arg int, msg string) int {
-	// TODO: to 'funcname'
-	return state.Errorf("bad argument #%d (%s)", arg, msg)
+	argError(state, arg, msg)
+	return 0
+}
+
+// argFuncName returns the name of the Go function running in state for its
+// argument errors, and whether it was called as a method.
+func (state *State) argFuncName() (name string, method bool) {
+	frame := state.frame()
+	if frame.closure == nil {
+		return "?", false
+	}
+	name, what := callname(frame)
+	if what == "" {
+		name = state.globalFuncName(frame.closure)
+	}
+	if name == "" {
+		name = "?"
+	}
+	return name, what == "method"
+}
+
+// globalFuncName returns the name of fn as a field of a module in
+// package.loaded, such as "string.rep", or "print" for the fields of _G, or
+// "" if there is none, as pushglobalfuncname does. The first module in order
+// of name wins, after _G.
+func (state *State) globalFuncName(fn *Closure) string {
+	loaded, ok := state.global.registry.get(String(LoadedKey)).(*table)
+	if !ok {
+		return ""
+	}
+	var mods []string
+	for k, v := range loaded.hash {
+		if name, ok := k.(String); ok && name != "_G" {
+			if _, ok := v.(*table); ok {
+				mods = append(mods, string(name))
+			}
+		}
+	}
+	sort.Strings(mods)
+	for _, mod := range append([]string{"_G"}, mods...) {
+		t, ok := loaded.getStr(mod).(*table)
+		if !ok {
+			continue
+		}
+		var field string
+		for k, v := range t.hash {
+			if name, ok := k.(String); ok && v == Value(fn) && (field == "" || string(name) < field) {
+				field = string(name)
+			}
+		}
+		switch {
+		case field == "":
+			continue
+		case mod == "_G":
+			return field
+		}
+		return mod + "." + field
+	}
+	return ""
 }
 
 // FileResult procudes the return values for file-related function in the standard library
@@ -51,6 +115,34 @@ func (state *State) FileResult(err error, filename string) int {
 	return 3
 }
 
+// ExecResult produces the return values for process-related functions in the
+// standard library (os.execute and io.close), given the error returned by
+// waiting for the process.
+//
+// See https://www.lua.org/manual/5.3/manual.html#luaL_execresult
+func (state *State) ExecResult(err error) int {
+	exit, ok := err.(*exec.ExitError)
+	if err != nil && !ok {
+		return state.FileResult(err, "")
+	}
+	var (
+		what = "exit"
+		code = 0
+	)
+	if ok {
+		code = exit.ExitCode()
+		if ws, ok := exit.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			what, code = "signal", int(ws.Signal())
+		}
+		state.Push(nil)
+	} else {
+		state.Push(true)
+	}
+	state.Push(what)
+	state.Push(code)
+	return 3
+}
+
 // ExecFrom uses Exec to load and execute the Lua chunk from the reader r.
 func (state *State) ExecFrom(r io.Reader) error {
 	return state.ExecChunk("?", r, BinaryMode|TextMode)
@@ -190,11 +282,48 @@ func (state *State) CallMeta(index int, event string) bool {
 	return false
 }
 
+// ExtendType adds methods to the metatable shared by all values of type typ, so
+// scripts can call them with the method syntax (for example ("x"):upper()).
+//
+// The methods are stored in the __index table of the type's metatable; both are
+// created if missing. For strings this is the string library once it is open.
+// ExtendType never replaces an existing method: if a name is already taken, or
+// if __index is not a table, it returns an error and adds nothing. Tables and
+// userdata have per-value metatables and cannot be extended.
+func (state *State) ExtendType(typ Type, methods map[string]Func) error {
+	if typ < NilType || typ >= maxTypeID || typ == TableType || typ == UserDataType {
+		return fmt.Errorf("lua: cannot extend type %s", typ)
+	}
+	meta := state.global.builtins[typ]
+	if meta == nil {
+		meta = newTable(state, 0, 1)
+	}
+	index, ok := meta.getStr("__index").(*table)
+	if !ok {
+		if !IsNone(meta.getStr("__index")) {
+			return fmt.Errorf("lua: __index of %s metatable is not a table", typ)
+		}
+		index = newTable(state, 0, len(methods))
+	}
+	for name := range methods {
+		if index.exists(String(name)) {
+			return fmt.Errorf("lua: %s method %q already exists", typ, name)
+		}
+	}
+	for name, fn := range methods {
+		index.setStr(name, newGoClosure(fn, 0))
+	}
+	meta.setStr("__index", index)
+	state.global.builtins[typ] = meta
+	return nil
+}
+
 // TypeAt returns the type of the value in the given valid index.
 //
 // TypeAt returns NilType for a non-valid (but acceptable) index.
 //
 // Otherwise, TypeAt returns one of:
+//
 //	LUA_TNUMBER
 //	LUA_TBOOLEAN
 //	LUA_TSTRING
@@ -214,7 +343,7 @@ func (state *State) TypeAt(index int) Type {
 // of the control at level in the call stack. Typically this string
 // has the following format:
 //
-// 		chunkname:currentline:
+//	chunkname:currentline:
 //
 // Level 0 is the running function,
 // level 1 is the function that called the running function, etc.
@@ -223,16 +352,16 @@ func (state *State) TypeAt(index int) Type {
 //
 // See https://www.lua.org/manual/5.3/manual.html#luaL_where
 func (state *State) Where(level int) {
-	// var debug Debug
-	// if state.GetStack(level, &debug) {
-	//		state.GetInfo("Sl", &debug)
-	//		if debug.Line > 0 {
-	//			state.Push(fmt.Sprintf("%s:%d: ", debug.Source, debug.Line))
-	//			return
-	//		}
-	// }
-
-	// TODO
+	fr := state.frame()
+	for ; level > 0 && fr != nil; level-- {
+		fr = fr.caller()
+	}
+	if cls := fr.function(); cls.isLua() {
+		if line := currentLine(fr); line > 0 {
+			state.Push(fmt.Sprintf("%s:%d: ", chunkID(cls.binary.Source), line))
+			return
+		}
+	}
 	state.Push("")
 }
 
@@ -64,6 +64,8 @@ type (
 		PcLnTab  []uint32
 		Locals   []LocalVar
 		UpNames  []string
+
+		frozen *reader // where to decode the prototype from, see LoadFrozen
 	}
 
 	Header struct {
@@ -101,14 +103,44 @@ func (proto *Prototype) NumParams() int              { return int(proto.Params)
 func (proto *Prototype) StackSize() int              { return int(proto.Stack) }
 func (proto *Prototype) IsVararg() bool              { return int(proto.Vararg) == 1 }
 func (proto *Prototype) Const(index int) interface{} { return proto.Consts[index] }
-func (proto *Prototype) Proto(index int) *Prototype  { return &proto.Protos[index] }
+func (proto *Prototype) IsFrozen() bool              { return proto.frozen != nil }
+
+// Proto returns the nested prototype at index, decoding it first if it was
+// left to decode by LoadFrozen.
+func (proto *Prototype) Proto(index int) *Prototype {
+	p := &proto.Protos[index]
+	if r := p.frozen; r != nil {
+		p.frozen = nil
+		decodePrototype(r, p, true)
+	}
+	return p
+}
 
 func (upval *UpValue) IsLocal() bool { return upval.InStack == 1 }
 func (upval *UpValue) AtIndex() int  { return int(upval.Index) }
 
 func IsChunk(data []byte) bool { return len(data) > 4 && string(data[:4]) == LUA_SIGNATURE }
 
+// Load decodes the binary chunk data.
 func Load(data []byte) (chunk Chunk, err error) {
+	return load(string(data), false)
+}
+
+// LoadFrozen decodes the binary chunk data without copying it: the strings
+// of the chunk, such as its constants, refer to data, and the nested
+// functions are only decoded when they are first used (see Proto), so that
+// loading a large chunk embedded in the program, such as with
+//
+//	//go:embed scripts.luac
+//	var scripts string
+//
+// costs little memory for the functions a program does not run. The whole
+// chunk is still checked, and LoadFrozen fails on the chunks Load fails on.
+func LoadFrozen(data string) (chunk Chunk, err error) {
+	return load(data, true)
+}
+
+func load(data string, lazy bool) (chunk Chunk, err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			if e, ok := r.(error); ok {
@@ -119,7 +151,7 @@ func Load(data []byte) (chunk Chunk, err error) {
 			}
 		}
 	}()
-	decode(bytes.NewBuffer(data), &chunk)
+	decode(&reader{data: data}, &chunk, lazy)
 	return chunk, err
 }
 
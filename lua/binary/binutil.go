@@ -116,10 +116,10 @@ func (w *writer) writeUpValues(upvalues []UpValue) {
 	}
 }
 
-func (w *writer) writeProtos(protos []Prototype) {
-	w.writeU32(uint32(len(protos)))
-	for _, proto := range protos {
-		encodeProto(w, &proto)
+func (w *writer) writeProtos(proto *Prototype) {
+	w.writeU32(uint32(len(proto.Protos)))
+	for i := range proto.Protos {
+		encodeProto(w, proto.Proto(i))
 	}
 }
 
@@ -1,14 +1,44 @@
 package binary
 
 import (
-	"bytes"
 	"encoding/binary"
 	"fmt"
+	"io"
+	"math"
+	"strings"
 )
 
-func decode(r *bytes.Buffer, c *Chunk) {
+// reader reads a binary chunk. The strings it reads are substrings of the
+// chunk, so that decoding does not copy them.
+type reader struct {
+	data string
+	pos  int
+}
+
+// next returns the next n bytes of the chunk.
+func (r *reader) next(n int) string {
+	if n < 0 || n > len(r.data)-r.pos {
+		panic(io.ErrUnexpectedEOF)
+	}
+	r.pos += n
+	return r.data[r.pos-n : r.pos]
+}
+
+func (r *reader) byte() byte { return r.next(1)[0] }
+
+func (r *reader) u32() uint32 { return le32(r.next(4)) }
+
+func (r *reader) u64() uint64 {
+	s := r.next(8)
+	return uint64(le32(s)) | uint64(le32(s[4:]))<<32
+}
+
+// count reads the number of elements of a list.
+func (r *reader) count() int { return int(r.u32()) }
+
+func decode(r *reader, c *Chunk, lazy bool) {
 	var h Header
-	must(binary.Read(r, order, &h))
+	must(binary.Read(strings.NewReader(r.next(binary.Size(h))), order, &h))
 
 	assert(h.Signature == head, "not a precompiled chunk")
 	assert(h.Version == LUAC_VERSION, "version mismatch")
@@ -24,52 +54,44 @@ func decode(r *bytes.Buffer, c *Chunk) {
 	c.Header = h
 
 	// decode size_upvalues (?)
-	_, err := r.ReadByte()
-	must(err)
+	r.byte()
 
 	// decode container closure prototype
-	decodePrototype(r, &c.Entry)
+	decodePrototype(r, &c.Entry, lazy)
 }
 
-func decodePrototype(r *bytes.Buffer, proto *Prototype) {
+// decodePrototype decodes a function prototype. If lazy, its nested
+// prototypes are only checked, and are decoded by Proto on first use.
+func decodePrototype(r *reader, proto *Prototype, lazy bool) {
 	// decode source name string (b[0] == length)
 	// b[0] == 0xFF ? uint64 : size
 	proto.Source = decodeString(r)
 
 	// decode line start
-	must(binary.Read(r, order, &proto.SrcPos))
+	proto.SrcPos = r.u32()
 
 	// decode line end
-	must(binary.Read(r, order, &proto.EndPos))
+	proto.EndPos = r.u32()
 
 	// decode number of parameters
-	must(binary.Read(r, order, &proto.Params))
+	proto.Params = r.byte()
 
 	// decode is varadic
-	must(binary.Read(r, order, &proto.Vararg))
+	proto.Vararg = r.byte()
 
 	// decode maximum stack size
-	must(binary.Read(r, order, &proto.Stack))
+	proto.Stack = r.byte()
 
 	// decode instruction bytecode
 	//
 	// leading 4-bytes is number of instructions
-	{
-		var num uint32
-		must(binary.Read(r, order, &num))
-		proto.Code = make([]uint32, num)
-		for i := range proto.Code {
-			must(binary.Read(r, order, &proto.Code[i]))
-		}
-	}
+	proto.Code = decodeU32s(r)
 
 	// decode constants
 	//
 	// leading 4-bytes is number of constants
 	{
-		var num uint32
-		must(binary.Read(r, order, &num))
-		proto.Consts = make([]interface{}, num)
+		proto.Consts = make([]interface{}, r.count())
 		for i := range proto.Consts {
 			proto.Consts[i] = decodeConst(r)
 		}
@@ -79,21 +101,9 @@ func decodePrototype(r *bytes.Buffer, proto *Prototype) {
 	//
 	// leading 4-bytes is number of upvalues
 	{
-		var num uint32
-		must(binary.Read(r, order, &num))
-		proto.UpValues = make([]UpValue, num)
+		proto.UpValues = make([]UpValue, r.count())
 		for i := range proto.UpValues {
-			var (
-				upv UpValue
-				err error
-			)
-			upv.InStack, err = r.ReadByte()
-			must(err)
-
-			upv.Index, err = r.ReadByte()
-			must(err)
-
-			proto.UpValues[i] = upv
+			proto.UpValues[i] = UpValue{InStack: r.byte(), Index: r.byte()}
 		}
 	}
 
@@ -101,40 +111,29 @@ func decodePrototype(r *bytes.Buffer, proto *Prototype) {
 	//
 	// leading 4-bytes is number of prototypes
 	{
-		var num uint32
-		must(binary.Read(r, order, &num))
-		proto.Protos = make([]Prototype, num)
+		proto.Protos = make([]Prototype, r.count())
 		for i := range proto.Protos {
-			var fn Prototype
-			decodePrototype(r, &fn)
-			proto.Protos[i] = fn
+			if lazy {
+				proto.Protos[i].frozen = &reader{data: r.data, pos: r.pos}
+				skipPrototype(r)
+			} else {
+				decodePrototype(r, &proto.Protos[i], false)
+			}
 		}
 	}
 
 	// decode line info (pc -> line)
 	//
 	// leading 4-bytes is number of pcln entries
-	{
-		var num uint32
-		must(binary.Read(r, order, &num))
-		proto.PcLnTab = make([]uint32, num)
-		for i := range proto.PcLnTab {
-			must(binary.Read(r, order, &proto.PcLnTab[i]))
-		}
-	}
+	proto.PcLnTab = decodeU32s(r)
 
 	// decode local variables
 	//
 	// leading 4-bytes is number of pcln entries
 	{
-		var num uint32
-		must(binary.Read(r, order, &num))
-		proto.Locals = make([]LocalVar, num)
+		proto.Locals = make([]LocalVar, r.count())
 		for i := range proto.Locals {
-			local := LocalVar{Name: decodeString(r)}
-			must(binary.Read(r, order, &local.Live))
-			must(binary.Read(r, order, &local.Dead))
-			proto.Locals[i] = local
+			proto.Locals[i] = LocalVar{Name: decodeString(r), Live: r.u32(), Dead: r.u32()}
 		}
 	}
 
@@ -142,56 +141,77 @@ func decodePrototype(r *bytes.Buffer, proto *Prototype) {
 	//
 	// leading 4-bytes is number of pcln entries
 	{
-		var num uint32
-		must(binary.Read(r, order, &num))
-		proto.UpNames = make([]string, num)
+		proto.UpNames = make([]string, r.count())
 		for i := range proto.UpNames {
 			proto.UpNames[i] = decodeString(r)
 		}
 	}
 }
 
-func decodeString(r *bytes.Buffer) string {
-	b, err := r.ReadByte()
-	must(err)
-	switch {
-	case b == 0x00:
+// skipPrototype skips a function prototype, checking that it decodes.
+func skipPrototype(r *reader) {
+	decodeString(r)       // source
+	r.next(4 + 4 + 3)     // lines, parameters, vararg and stack size
+	r.next(4 * r.count()) // code
+	for n := r.count(); n > 0; n-- {
+		decodeConst(r)
+	}
+	r.next(2 * r.count()) // upvalues
+	for n := r.count(); n > 0; n-- {
+		skipPrototype(r)
+	}
+	r.next(4 * r.count()) // line info
+	for n := r.count(); n > 0; n-- {
+		decodeString(r)
+		r.next(4 + 4)
+	}
+	for n := r.count(); n > 0; n-- {
+		decodeString(r)
+	}
+}
+
+// le32 decodes the little endian uint32 at the start of s.
+func le32(s string) uint32 {
+	return uint32(s[0]) | uint32(s[1])<<8 | uint32(s[2])<<16 | uint32(s[3])<<24
+}
+
+func decodeU32s(r *reader) []uint32 {
+	u32s := make([]uint32, r.count())
+	s := r.next(4 * len(u32s))
+	for i := range u32s {
+		u32s[i] = le32(s[4*i:])
+	}
+	return u32s
+}
+
+func decodeString(r *reader) string {
+	switch b := r.byte(); b {
+	case 0x00:
 		return ""
-	case b == 0xFF:
-		var u64 uint64
-		must(binary.Read(r, order, &u64))
-		return string(r.Next(int(u64) - 1))
+	case 0xFF:
+		return r.next(int(r.u64()) - 1)
 	default:
-		return string(r.Next(int(b) - 1))
+		return r.next(int(b) - 1)
 	}
 }
 
-func decodeConst(r *bytes.Buffer) interface{} {
-	t, err := r.ReadByte()
-	must(err)
-	switch t {
+func decodeConst(r *reader) interface{} {
+	switch t := r.byte(); t {
 	case LUA_TYPE_NIL: // NIL
 		return nil
 	case LUA_TYPE_BOOL: // BOOL
-		b, err := r.ReadByte()
-		must(err)
-		var v bool
-		if b == 0 {
-			v = false
-		} else if b == 1 {
-			v = true
-		} else {
+		switch b := r.byte(); b {
+		case 0:
+			return false
+		case 1:
+			return true
+		default:
 			panic(fmt.Errorf("invalid bool constant: %d", b))
 		}
-		return v
 	case LUA_NUM_INT: // INT
-		var i64 int64
-		must(binary.Read(r, order, &i64))
-		return i64
+		return int64(r.u64())
 	case LUA_NUM_FLOAT: // FLOAT
-		var f64 float64
-		must(binary.Read(r, order, &f64))
-		return f64
+		return math.Float64frombits(r.u64())
 	case LUA_STR_SHORT, LUA_STR_LONG: // STRING
 		return decodeString(r)
 	default:
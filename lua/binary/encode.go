@@ -15,7 +15,7 @@ func encodeProto(w *writer, p *Prototype) {
 	w.writeCode(p.Code)
 	w.writeConsts(p.Consts)
 	w.writeUpValues(p.UpValues)
-	w.writeProtos(p.Protos)
+	w.writeProtos(p)
 	w.writePcLnInfo(p.PcLnTab)
 	w.writeLocalVars(p.Locals)
 	w.writeUpValueNames(p.UpNames)
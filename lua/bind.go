@@ -0,0 +1,273 @@
+package lua
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Binder binds Go values to Lua with reflection; see Bind.
+//
+// A Binder caches the binding of each Go type, so it is meant to be shared,
+// such as in a package variable.
+type Binder struct {
+	// Names maps the name of a Go field or method to its name in Lua, such
+	// as SnakeCase. If nil, the Go names are used.
+	Names func(string) string
+
+	bindings sync.Map // reflect.Type -> *binding
+}
+
+// binding holds the exported fields and methods of a Go type.
+type binding struct {
+	binder  *Binder
+	typ     reflect.Type
+	fields  map[string][]int          // field index paths by Lua name
+	methods map[string]reflect.Method // methods by Lua name
+}
+
+// Bound is a Go value bound with Bind. It is pushed like other Go values,
+// with State.Push.
+type Bound struct {
+	v reflect.Value
+	b *binding
+}
+
+var defaultBinder Binder
+
+// Bind binds value, a pointer to a struct or any other Go value with methods,
+// for scripts to use its exported fields as properties and call its exported
+// methods with the method syntax:
+//
+//	state.Push(lua.Bind(player))
+//	state.SetGlobal("player")
+//
+//	player.Health = player.Health - 10  -- in Lua
+//	player:MoveTo(3, 4)
+//
+// Fields and arguments convert between Lua and Go as with PushChannel;
+// fields that are structs or pointers to structs are bound too, so that
+// player.Pos.X = 1 changes the player. Fields can only be set through a
+// pointer: a struct bound by value is copied first. A method whose last result
+// is an error raises it, and returns its other results.
+//
+// Bind uses the Go names; use a Binder to rename them, for example in snake
+// case.
+func Bind(value interface{}) *Bound { return defaultBinder.Bind(value) }
+
+// Bind binds value like the Bind function, with the names of b.
+func (b *Binder) Bind(value interface{}) *Bound {
+	v := reflect.ValueOf(value)
+	if v.Kind() == reflect.Struct {
+		ptr := reflect.New(v.Type())
+		ptr.Elem().Set(v)
+		v = ptr
+	}
+	return &Bound{v: v, b: b.binding(v.Type())}
+}
+
+// Value returns the bound Go value.
+func (x *Bound) Value() interface{} { return x.v.Interface() }
+
+// binding returns the binding of typ, making it on first use.
+func (b *Binder) binding(typ reflect.Type) *binding {
+	if x, ok := b.bindings.Load(typ); ok {
+		return x.(*binding)
+	}
+	name := b.Names
+	if name == nil {
+		name = func(s string) string { return s }
+	}
+	x := &binding{
+		binder:  b,
+		typ:     typ,
+		fields:  make(map[string][]int),
+		methods: make(map[string]reflect.Method),
+	}
+	for i := 0; i < typ.NumMethod(); i++ {
+		if m := typ.Method(i); m.PkgPath == "" {
+			x.methods[name(m.Name)] = m
+		}
+	}
+	if st := typ; st.Kind() == reflect.Ptr && st.Elem().Kind() == reflect.Struct {
+		addFields(x.fields, st.Elem(), nil, name)
+	}
+	actual, _ := b.bindings.LoadOrStore(typ, x)
+	return actual.(*binding)
+}
+
+// addFields adds the exported fields of the struct type typ, found at the
+// index path at, to fields, then the fields promoted from its embedded structs
+// that they do not shadow.
+func addFields(fields map[string][]int, typ reflect.Type, at []int, name func(string) string) {
+	var embedded []reflect.StructField
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			embedded = append(embedded, f)
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if _, ok := fields[name(f.Name)]; !ok {
+			fields[name(f.Name)] = append(at[:len(at):len(at)], i)
+		}
+	}
+	for _, f := range embedded {
+		addFields(fields, f.Type, append(at[:len(at):len(at)], f.Index...), name)
+	}
+}
+
+// field returns the field of x with the Lua name key.
+func (x *Bound) field(key Value) (reflect.Value, bool) {
+	name, ok := key.(String)
+	if !ok || x.v.Kind() != reflect.Ptr || x.v.IsNil() {
+		return reflect.Value{}, false
+	}
+	index, ok := x.b.fields[string(name)]
+	if !ok {
+		return reflect.Value{}, false
+	}
+	return x.v.Elem().FieldByIndex(index), true
+}
+
+// boundValue converts the Go value v to Lua, binding structs with the binder
+// of x.
+func (x *Bound) boundValue(state *State, v reflect.Value) Value {
+	switch {
+	case v.Kind() == reflect.Struct && v.CanAddr():
+		return valueOf(state, &Bound{v: v.Addr(), b: x.b.binder.binding(v.Addr().Type())})
+	case v.Kind() == reflect.Struct:
+		return valueOf(state, x.b.binder.Bind(v.Interface()))
+	case v.Kind() == reflect.Ptr && !v.IsNil() && v.Elem().Kind() == reflect.Struct:
+		return valueOf(state, &Bound{v: v, b: x.b.binder.binding(v.Type())})
+	}
+	return luaValue(state, v)
+}
+
+// metatable sets the metamethods of the bound value in events.
+func (x *Bound) metatable(state *State, events *table) {
+	index := func(state *State) int {
+		var (
+			key = state.get(2)
+			fn  Value
+		)
+		if name, ok := key.(String); ok {
+			if m, ok := x.b.methods[string(name)]; ok {
+				fn = state.boundMethod(x.b, m)
+			}
+		}
+		if fn == nil {
+			if f, ok := x.field(key); ok {
+				fn = x.boundValue(state, f)
+			} else {
+				fn = Nil(1)
+			}
+		}
+		state.Push(fn)
+		return 1
+	}
+	newindex := func(state *State) int {
+		key, val := state.get(2), state.get(3)
+		f, ok := x.field(key)
+		switch {
+		case !ok:
+			state.errorf("no field '%v' in %v", key, x.b.typ)
+		case !f.CanSet():
+			state.errorf("cannot set field '%v' of %v", key, x.b.typ)
+		}
+		v, ok := goValue(val, f.Type())
+		if !ok {
+			state.errorf("cannot assign a %s value to field '%v' of type %v", state.TypeAt(3), key, f.Type())
+		}
+		f.Set(v)
+		return 0
+	}
+	tostring := func(state *State) int {
+		state.Push(fmt.Sprintf("%v: %p", x.b.typ, x))
+		return 1
+	}
+	events.setStr(metaIndex.ID(), newGoClosure(index, 0))
+	events.setStr(metaNewIndex.ID(), newGoClosure(newindex, 0))
+	events.setStr("__tostring", newGoClosure(tostring, 0))
+}
+
+// boundMethod returns the Lua function calling the method m of the values of
+// the binding b, shared by the values of the state.
+func (state *State) boundMethod(b *binding, m reflect.Method) *Closure {
+	type key struct {
+		b    *binding
+		name string
+	}
+	g := state.global
+	if cls, ok := g.bound[key{b, m.Name}]; ok {
+		return cls
+	}
+	call := func(state *State) int {
+		self, ok := state.ToUserData(1).Value().(*Bound)
+		if !ok || self.b != b {
+			typeError(state, 1, b.typ.String())
+		}
+		var (
+			ft   = m.Type
+			args = []reflect.Value{self.v}
+		)
+		arg := func(i int, typ reflect.Type) { // argument i of the method, after self
+			v, ok := goValue(state.get(i+1), typ)
+			if !ok {
+				typeError(state, i+1, typ.String())
+			}
+			args = append(args, v)
+		}
+		for i := 1; i < ft.NumIn(); i++ {
+			if ft.IsVariadic() && i == ft.NumIn()-1 {
+				for ; i < state.Top(); i++ {
+					arg(i, ft.In(ft.NumIn()-1).Elem())
+				}
+				break
+			}
+			arg(i, ft.In(i))
+		}
+		rets := m.Func.Call(args)
+		if k := len(rets) - 1; k >= 0 && ft.Out(k) == errorType {
+			if err, _ := rets[k].Interface().(error); err != nil {
+				state.errorf("%v", err)
+			}
+			rets = rets[:k]
+		}
+		for _, r := range rets {
+			state.Push(self.boundValue(state, r))
+		}
+		return len(rets)
+	}
+	cls := newGoClosure(call, 0)
+	if g.bound == nil {
+		g.bound = make(map[interface{}]*Closure)
+	}
+	g.bound[key{b, m.Name}] = cls
+	return cls
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// SnakeCase returns the Go name in snake case, such as "move_to" for "MoveTo"
+// and "http_server" for "HTTPServer", for Binder.Names.
+func SnakeCase(name string) string {
+	var (
+		b     strings.Builder
+		runes = []rune(name)
+	)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1]) ||
+				i+1 < len(runes) && unicode.IsLower(runes[i+1])) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
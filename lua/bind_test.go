@@ -0,0 +1,93 @@
+package lua
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type vec2 struct{ X, Y float64 }
+
+type entity struct{ ID int }
+
+type player struct {
+	entity
+	Name   string
+	Health int
+	Pos    vec2
+	secret int
+}
+
+func (p *player) MoveTo(x, y float64) { p.Pos = vec2{x, y} }
+
+func (p *player) Damage(n int) (int, error) {
+	if n < 0 {
+		return 0, errors.New("negative damage")
+	}
+	p.Health -= n
+	return p.Health, nil
+}
+
+func TestBind(t *testing.T) {
+	state := NewState()
+	defer state.Close()
+
+	p := &player{entity: entity{7}, Name: "ann", Health: 100}
+	binder := &Binder{Names: SnakeCase}
+	state.Push(binder.Bind(p))
+
+	call := func(name string, args ...interface{}) error {
+		state.GetField(1, name)
+		state.PushIndex(1)
+		for _, arg := range args {
+			state.Push(arg)
+		}
+		return state.PCall(1+len(args), 1, 0)
+	}
+	if err := call("move_to", 3, 4.5); err != nil {
+		t.Fatal(err)
+	}
+	if err := call("damage", 30); err != nil || state.ToInt(-1) != 70 {
+		t.Errorf("damage(30) = %v, %v; want 70", state.get(-1), err)
+	}
+	if err := call("damage", -1); err == nil || !strings.Contains(err.Error(), "negative damage") {
+		t.Errorf("damage(-1) error = %v", err)
+	}
+	if err := call("damage", "x"); err == nil || !strings.Contains(err.Error(), "bad argument #2 to '?' (int expected, got string)") {
+		t.Errorf("damage('x') error = %v", err)
+	}
+	state.SetTop(1)
+
+	// Nested structs are bound in place.
+	state.GetField(1, "pos")
+	state.Push(-1)
+	state.SetField(-2, "y")
+	state.Push("bob")
+	state.SetField(1, "name")
+	if p.Pos != (vec2{3, -1}) || p.Name != "bob" {
+		t.Errorf("player = %+v", p)
+	}
+	if state.GetField(1, "id"); state.ToInt(-1) != 7 {
+		t.Errorf("promoted id = %v; want 7", state.get(-1))
+	}
+	if typ := state.GetField(1, "secret"); typ != NilType {
+		t.Errorf("unexported field is %v", typ)
+	}
+	state.SetTop(1)
+
+	state.Push(Func(func(state *State) int {
+		state.Push("full")
+		state.SetField(1, "health")
+		return 0
+	}))
+	state.PushIndex(1)
+	if err := state.PCall(1, 0, 0); err == nil || !strings.Contains(err.Error(), "cannot assign a string value to field 'health' of type int") {
+		t.Errorf("health = 'full' error = %v", err)
+	}
+
+	for name, want := range map[string]string{"MoveTo": "move_to", "HTTPServer": "http_server", "ID": "id"} {
+		if got := SnakeCase(name); got != want {
+			t.Errorf("SnakeCase(%q) = %q; want %q", name, got, want)
+		}
+	}
+}
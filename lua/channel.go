@@ -0,0 +1,223 @@
+package lua
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// ChanDir is the direction in which scripts may use a channel pushed with
+// PushChannel.
+type ChanDir int
+
+const (
+	ChanRecv ChanDir = 1 << iota // scripts may receive from the channel
+	ChanSend                     // scripts may send on and close the channel
+
+	ChanBoth = ChanRecv | ChanSend // scripts may do both
+)
+
+var chanDirNames = map[ChanDir]string{
+	ChanRecv: "receive",
+	ChanSend: "send",
+}
+
+// channel is a Go channel pushed with PushChannel.
+type channel struct {
+	ch  reflect.Value
+	dir ChanDir
+}
+
+// PushChannel pushes the Go channel ch onto the stack as a userdata with
+// methods, restricted to the direction dir and to the directions of the type
+// of ch:
+//
+//	ch:send(v)           sends v, converted to the element type of ch
+//	v, ok = ch:receive() receives a value; ok is false once ch is closed and drained
+//	ch:close()           closes ch; it needs the send direction
+//
+// Integers, floats, strings and booleans are converted to and from the
+// element type of ch; channels of interface{} receive values converted as
+// with Push, and send the plain Go values of Lua numbers, strings and
+// booleans, and the Go values of userdata.
+//
+// Scripts running in a coroutine do not block on a channel that is not ready:
+// send and receive yield the channel to the resumer instead, and try again
+// when resumed, so that a scheduler written in Lua can run producers and
+// consumers over Go pipelines side by side. On the main thread, send and
+// receive block. PushChannel panics if ch is not a channel.
+func (state *State) PushChannel(ch interface{}, dir ChanDir) {
+	v := reflect.ValueOf(ch)
+	if v.Kind() != reflect.Chan {
+		panic(fmt.Errorf("lua: PushChannel of non-channel %T", ch))
+	}
+	if v.Type().ChanDir()&reflect.RecvDir == 0 {
+		dir &^= ChanRecv
+	}
+	if v.Type().ChanDir()&reflect.SendDir == 0 {
+		dir &^= ChanSend
+	}
+	if dir == 0 {
+		panic(fmt.Errorf("lua: PushChannel of %T in no direction it allows", ch))
+	}
+	state.Push(&channel{v, dir})
+}
+
+// Methods implements HasMethods.
+func (*channel) Methods() map[string]Func {
+	return map[string]Func{
+		"send":    chanSend,
+		"receive": chanReceive,
+		"close":   chanClose,
+	}
+}
+
+// checkChannel returns the channel receiving the method call, checking that it
+// can be used in direction dir for op.
+func checkChannel(state *State, dir ChanDir, op string) *channel {
+	c := state.CheckGoValue(1, (*channel)(nil)).(*channel)
+	if c.dir&dir == 0 {
+		state.Raise(MsgChanDirection, op, chanDirNames[c.dir])
+	}
+	return c
+}
+
+func chanSend(state *State) int {
+	c := checkChannel(state, ChanSend, "send on")
+	v, ok := goValue(state.get(2), c.ch.Type().Elem())
+	if !ok {
+		state.Raise(MsgChanValue, state.TypeAt(2), c.ch.Type().Elem())
+	}
+	if !state.IsYieldable() {
+		trySend(state, c.ch, v, true)
+		return 0
+	}
+	for !trySend(state, c.ch, v, false) {
+		state.PushIndex(1)
+		state.Yield(1)
+		state.SetTop(2)
+	}
+	return 0
+}
+
+// trySend sends v on ch, reporting whether it was sent; it only waits for ch
+// to be ready if block.
+func trySend(state *State, ch, v reflect.Value, block bool) (sent bool) {
+	defer func() {
+		if recover() != nil {
+			state.Raise(MsgChanClosed)
+		}
+	}()
+	if block {
+		ch.Send(v)
+		return true
+	}
+	return ch.TrySend(v)
+}
+
+func chanReceive(state *State) int {
+	c := checkChannel(state, ChanRecv, "receive from")
+	var (
+		v  reflect.Value
+		ok bool
+	)
+	if !state.IsYieldable() {
+		v, ok = c.ch.Recv()
+	} else {
+		for {
+			var cases = []reflect.SelectCase{
+				{Dir: reflect.SelectRecv, Chan: c.ch},
+				{Dir: reflect.SelectDefault},
+			}
+			var chosen int
+			if chosen, v, ok = reflect.Select(cases); chosen == 0 {
+				break
+			}
+			state.PushIndex(1)
+			state.Yield(1)
+			state.SetTop(1)
+		}
+	}
+	if !ok {
+		state.Push(nil)
+		state.Push(false)
+		return 2
+	}
+	state.Push(luaValue(state, v))
+	state.Push(true)
+	return 2
+}
+
+func chanClose(state *State) int {
+	c := checkChannel(state, ChanSend, "close")
+	defer func() {
+		if recover() != nil {
+			state.Raise(MsgCloseClosed)
+		}
+	}()
+	c.ch.Close()
+	return 0
+}
+
+// goValue converts the Lua value v to a Go value of type typ. Numbers only
+// convert to numeric types that represent them exactly.
+func goValue(v Value, typ reflect.Type) (reflect.Value, bool) {
+	if typ.Kind() == reflect.Interface && typ.NumMethod() > 0 && reflect.TypeOf(v).Implements(typ) {
+		return reflect.ValueOf(v), true // such as a channel of Value
+	}
+	var x interface{}
+	switch v := v.(type) {
+	case Int:
+		x = int64(v)
+	case Float:
+		x = float64(v)
+	case String:
+		x = string(v)
+	case Bool:
+		x = bool(v)
+	case *Object:
+		x = v.data
+	case Nil:
+		switch typ.Kind() {
+		case reflect.Interface, reflect.Ptr, reflect.Map, reflect.Slice, reflect.Chan, reflect.Func:
+			return reflect.Zero(typ), true
+		}
+		return reflect.Value{}, false
+	default:
+		x = v
+	}
+	rv := reflect.ValueOf(x)
+	switch {
+	case rv.Type().AssignableTo(typ):
+		return rv, true
+	case isNumberKind(typ.Kind()) && isNumberKind(rv.Kind()):
+		if conv := rv.Convert(typ); conv.Convert(rv.Type()).Interface() == x {
+			return conv, true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// luaValue converts the Go value v to a Lua value.
+func luaValue(state *State, v reflect.Value) Value {
+	switch kind := v.Kind(); {
+	case kind >= reflect.Int && kind <= reflect.Int64:
+		return Int(v.Int())
+	case kind >= reflect.Uint && kind <= reflect.Uintptr:
+		return Int(int64(v.Uint()))
+	case kind == reflect.Float32 || kind == reflect.Float64:
+		return Float(v.Float())
+	case kind == reflect.String:
+		return String(v.String())
+	case kind == reflect.Bool:
+		return Bool(v.Bool())
+	case kind == reflect.Interface && v.IsNil():
+		return Nil(1)
+	case kind == reflect.Interface:
+		return luaValue(state, v.Elem())
+	}
+	return valueOf(state, v.Interface())
+}
+
+func isNumberKind(kind reflect.Kind) bool {
+	return kind >= reflect.Int && kind <= reflect.Float64
+}
@@ -0,0 +1,90 @@
+package lua
+
+import (
+	"fmt"
+	"testing"
+)
+
+// chanCall calls the method of the channel at the top of the stack, leaving
+// the channel there, and returns the results, or the error.
+func chanCall(state *State, method string, args ...interface{}) string {
+	state.GetField(-1, method)
+	state.PushIndex(-2)
+	for _, arg := range args {
+		state.Push(arg)
+	}
+	top := state.Top() - len(args) - 2
+	if err := state.PCall(len(args)+1, MultRets, 0); err != nil {
+		return err.Error()
+	}
+	return fmt.Sprint(state.PopN(state.Top() - top))
+}
+
+func TestChannel(t *testing.T) {
+	state := NewState()
+	defer state.Close()
+
+	ch := make(chan int, 1)
+	state.PushChannel(ch, ChanBoth)
+	for _, step := range []struct {
+		method string
+		args   []interface{}
+		want   string
+	}{
+		{"send", []interface{}{int64(5)}, "[]"},
+		{"receive", nil, "[5 true]"},
+		{"send", []interface{}{1.5}, "cannot send a number value on a channel of int"},
+		{"send", []interface{}{2.0}, "[]"},
+		{"close", nil, "[]"},
+		{"send", []interface{}{int64(1)}, "send on closed channel"},
+		{"receive", nil, "[2 true]"},
+		{"receive", nil, "[nil false]"},
+	} {
+		if got := chanCall(state, step.method, step.args...); got != step.want {
+			t.Errorf("%s%v = %s; want %s", step.method, step.args, got, step.want)
+		}
+	}
+	state.Pop()
+
+	state.PushChannel((<-chan int)(ch), ChanBoth)
+	if got, want := chanCall(state, "send", int64(1)), "cannot send on a receive-only channel"; got != want {
+		t.Errorf("send on a receive-only channel = %s; want %s", got, want)
+	}
+	state.Pop()
+}
+
+func TestChannelYield(t *testing.T) {
+	state := NewState()
+	defer state.Close()
+
+	ch := make(chan interface{})
+	co := state.NewThread()
+	co.Push(Func(func(co *State) int {
+		co.PushChannel(ch, ChanRecv)
+		co.GetField(-1, "receive")
+		co.PushIndex(-2)
+		co.Call(1, 2)
+		return 2
+	}))
+
+	// The coroutine yields the channel until a value is ready.
+	if rets, err := state.Resume(co, 0); err != nil || rets != 1 || state.TypeAt(-1) != UserDataType {
+		t.Fatalf("first resume = %d, %v; want the channel", rets, err)
+	}
+	state.Pop()
+	if got := state.CoStatus(co); got != "suspended" {
+		t.Fatalf("status while waiting = %s; want suspended", got)
+	}
+	done := make(chan struct{})
+	go func() {
+		ch <- uint8(7)
+		close(done)
+	}()
+	var got string
+	for got = resume(state, co); state.CoStatus(co) == "suspended"; got = resume(state, co) {
+	}
+	<-done
+	if got != "[7 true]" {
+		t.Errorf("result = %s; want [7 true]", got)
+	}
+}
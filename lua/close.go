@@ -0,0 +1,197 @@
+package lua
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrClosed is the error returned by calls into a state after Close.
+var ErrClosed = errors.New("lua: state is closed")
+
+// WithLeakReports returns an Option that writes a report to w whenever a state
+// is garbage collected without having been closed, with the Go stack of the
+// NewState call that created it. Collecting the stack makes NewState slower,
+// so the option is meant for development and tests.
+//
+// The report is written from the runtime's finalizer goroutine, which then
+// closes the Go resources registered with CloseOnLeak, such as the files the
+// io library left open, most recently registered first. The __gc metamethods
+// are not called: running Lua code needs the state, which the finalizer cannot
+// refer to without keeping it alive.
+func WithLeakReports(w io.Writer) Option {
+	return func(cfg *config) {
+		cfg.leaks = w
+	}
+}
+
+// CloseOnLeak registers c, a Go resource owned by the userdata at the given
+// index such as an open file, to be closed if the state is garbage collected
+// without having been closed (see WithLeakReports). The registration is
+// dropped when the value is unmarked with Finalized, and c must not refer to
+// the state. Without leak reports, CloseOnLeak does nothing.
+func (state *State) CloseOnLeak(index int, c io.Closer) {
+	guard := state.global.thread0.guard
+	if guard == nil {
+		return
+	}
+	g := state.global
+	if g.leaked == nil {
+		g.leaked = make(map[Value]int)
+	}
+	obj := state.get(index)
+	if mark, ok := g.leaked[obj]; ok {
+		guard.forget(mark)
+	}
+	guard.mu.Lock()
+	defer guard.mu.Unlock()
+	guard.marks++
+	guard.closers[guard.marks] = c
+	g.leaked[obj] = guard.marks
+}
+
+// leakGuard reports a state that was not closed when it is collected, and
+// closes its registered resources. It is only referenced by the main thread
+// so that it becomes unreachable with it, and must not refer to the state.
+type leakGuard struct {
+	w     io.Writer
+	stack []byte
+
+	// Resources to close by registration order; mu guards them since the
+	// finalizer runs on another goroutine.
+	mu      sync.Mutex
+	closers map[int]io.Closer
+	marks   int
+}
+
+func newLeakGuard(w io.Writer) *leakGuard {
+	guard := &leakGuard{w: w, stack: debug.Stack(), closers: make(map[int]io.Closer)}
+	runtime.SetFinalizer(guard, func(guard *leakGuard) {
+		fmt.Fprintf(guard.w, "lua: state was not closed; created at:\n%s", guard.stack)
+		guard.close()
+	})
+	return guard
+}
+
+// forget drops the resource registered with the given mark.
+func (guard *leakGuard) forget(mark int) {
+	guard.mu.Lock()
+	defer guard.mu.Unlock()
+	delete(guard.closers, mark)
+}
+
+// close closes the registered resources, most recently registered first,
+// ignoring their errors.
+func (guard *leakGuard) close() {
+	guard.mu.Lock()
+	marks := make([]int, 0, len(guard.closers))
+	for mark := range guard.closers {
+		marks = append(marks, mark)
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(marks)))
+	closers := guard.closers
+	guard.closers = nil
+	guard.mu.Unlock()
+	for _, mark := range marks {
+		closers[mark].Close()
+	}
+}
+
+// Close releases the resources of the state and of all its threads.
+//
+// Close calls the __gc metamethods of the tables and userdata whose metatable had
+// a __gc field when it was set, most recently marked first, as lua_close does.
+// Errors raised by the metamethods are ignored. Since Go's garbage collector does
+// not run Lua finalizers, these objects stay alive until Close, unless they are
+// unmarked with Finalized; in particular the io library closes files that
+// scripts left open, and unmarks the files they close. Suspended coroutines are
+// unwound first, releasing their goroutines.
+//
+// Afterwards, calls that run Lua code (PCall, ExecText, LoadChunk and the like)
+// return ErrClosed, and Call raises it. Closing a closed state does nothing.
+//
+// See https://www.lua.org/manual/5.3/manual.html#lua_close
+func (state *State) Close() {
+	g := state.global
+	if g.closed || g.closing {
+		return
+	}
+	g.closing = true
+	g.interruptMu.Lock()
+	g.interrupts = nil
+	atomic.StoreInt32(&g.interrupted, 0)
+	g.interruptMu.Unlock()
+	g.closeThreads()
+	main := g.thread0
+	main.SetTop(0)
+	objs := make([]Value, 0, len(g.finobj))
+	for obj := range g.finobj {
+		objs = append(objs, obj)
+	}
+	sort.Slice(objs, func(i, j int) bool { return g.finobj[objs[i]] > g.finobj[objs[j]] })
+	for _, obj := range objs {
+		if gc := main.metamethod(obj, metaGc); !IsNone(gc) {
+			main.Push(gc)
+			main.Push(obj)
+			main.PCall(1, 0, 0)
+			main.SetTop(0)
+		}
+	}
+	g.closed = true
+
+	// Drop the references to the values of the state.
+	g.finobj = nil
+	g.registry = newTable(main, 0, 0)
+	g.registry.setInt(MainThreadIndex, &thread{main})
+	g.registry.setInt(GlobalsIndex, newTable(main, 0, 0))
+	g.builtins = [maxTypeID]*table{}
+	g.methods = nil
+	g.leaked = nil
+	if main.guard != nil {
+		runtime.SetFinalizer(main.guard, nil)
+		main.guard = nil
+	}
+}
+
+// checkFinalizer marks obj to have its __gc metamethod called by Close if meta
+// has one, as luaC_checkfinalizer does.
+func (state *State) checkFinalizer(obj Value, meta *table) {
+	g := state.global
+	if meta == nil || IsNone(meta.get(event2key[metaGc])) {
+		return
+	}
+	if _, ok := g.finobj[obj]; ok {
+		return
+	}
+	if g.finobj == nil {
+		g.finobj = make(map[Value]int)
+	}
+	g.marks++
+	g.finobj[obj] = g.marks
+}
+
+// Finalized unmarks the value at the given index, which was finalized by other
+// means, such as a file closed by the script: Close does not call its __gc
+// metamethod, and the state no longer keeps it alive. Setting a metatable with
+// a __gc field marks it again.
+func (state *State) Finalized(index int) {
+	g := state.global
+	obj := state.get(index)
+	delete(g.finobj, obj)
+	if mark, ok := g.leaked[obj]; ok {
+		delete(g.leaked, obj)
+		g.thread0.guard.forget(mark)
+	}
+}
+
+// checkClosed raises ErrClosed if the state is closed.
+func (state *State) checkClosed() {
+	if state.global.closed {
+		panic(runtimeErr(ErrClosed))
+	}
+}
@@ -0,0 +1,121 @@
+package lua
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClose(t *testing.T) {
+	state := NewState()
+
+	var closed []string
+	gc := func(state *State) int {
+		state.RawGetIndex(1, 1)
+		closed = append(closed, state.ToString(-1))
+		return state.Errorf("ignored")
+	}
+	for _, name := range []string{"first", "closed", "second"} {
+		state.NewTable()
+		state.Push(name)
+		state.RawSetIndex(-2, 1)
+		state.NewTable()
+		state.PushClosure(gc, 0)
+		state.SetField(-2, "__gc")
+		state.PushIndex(-1)
+		state.SetMetaTableAt(-3)
+		state.SetMetaTableAt(-2) // marked once
+		if name == "closed" {
+			state.Finalized(-1)
+		}
+		state.Pop()
+	}
+
+	state.Close()
+	if want := []string{"second", "first"}; !reflect.DeepEqual(closed, want) {
+		t.Fatalf("__gc calls = %v; want %v", closed, want)
+	}
+	state.Close()
+	if len(closed) != 2 {
+		t.Errorf("second Close called __gc again")
+	}
+	state.Push(func(*State) int { return 0 })
+	if err := state.PCall(0, 0, 0); !errors.Is(err, ErrClosed) {
+		t.Errorf("PCall after Close = %v; want ErrClosed", err)
+	}
+	if err := state.ExecText("return"); !errors.Is(err, ErrClosed) {
+		t.Errorf("ExecText after Close = %v; want ErrClosed", err)
+	}
+}
+
+// syncBuffer is a bytes.Buffer safe to write from the finalizer goroutine.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+type closeFunc func() error
+
+func (fn closeFunc) Close() error { return fn() }
+
+func TestLeakReports(t *testing.T) {
+	var (
+		w      syncBuffer
+		mu     sync.Mutex
+		closed []string
+	)
+	closer := func(name string) io.Closer {
+		return closeFunc(func() error {
+			mu.Lock()
+			defer mu.Unlock()
+			closed = append(closed, name)
+			return nil
+		})
+	}
+	func() {
+		NewState(WithLeakReports(&w)).Close()
+		state := NewState(WithLeakReports(&w))
+		for _, name := range []string{"first", "closed", "second"} {
+			state.NewTable()
+			state.CloseOnLeak(-1, closer(name))
+			if name == "closed" {
+				state.Finalized(-1)
+			}
+		}
+	}()
+	done := func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(closed) == 2
+	}
+	for i := 0; i < 50 && !done(); i++ {
+		runtime.GC()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := w.String(); strings.Count(got, "was not closed") != 1 || !strings.Contains(got, "TestLeakReports") {
+		t.Errorf("leak report = %q; want one report with the creation stack", got)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if want := []string{"second", "first"}; !reflect.DeepEqual(closed, want) {
+		t.Errorf("closed = %v; want %v", closed, want)
+	}
+}
@@ -1,6 +1,7 @@
 package lua
 
 import (
+	"io"
 	"os"
 )
 
@@ -17,11 +18,11 @@ const (
 
 const (
 	// Name of the environment variable that Lua checks to set
-	// package.gopath. This takes precedence over LUA_GOPATH.
+	// package.cpath. This takes precedence over LUA_GOPATH.
 	EnvVarLuaGoPath53 = "$LUA_GOPATH_5_3"
 
 	// Name of the environment variable that Lua checks to set
-	// package.gopath.
+	// package.cpath.
 	EnvVarLuaGoPath = "$LUA_GOPATH"
 
 	// Name of the environment variable that Lua checks to set
@@ -95,7 +96,7 @@ var (
 
 	// Path is the path to the directory used by Lua require to search for a go loader.
 	//
-	// Lua initializes the LuaGoPath (package.gopath) in the same way it initializes
+	// Lua initializes the LuaGoPath (package.cpath) in the same way it initializes
 	// LuaPath (package.path), using the environment variable LUA_GOPATH_5_3 or LUA_GOPATH.
 	//
 	// On init, Path is set to the value of the environment variable LUA_GOPATH;
@@ -125,9 +126,29 @@ type config struct {
 	check bool
 	trace bool
 	debug bool
+	crash io.Writer
+	leaks io.Writer
+	ring  int
+
+	record int
+
+	defines  map[string]interface{}
+	limits   *ChunkLimits
+	unpack   int
+	stack    int
+	messages Messages
+
+	threadPool      int
+	resumeTraceback bool
+	ringTraceback   bool
+	fs              FileSystem
 }
 
 // WithChecks returns an Option that instruction a Lua state to perform API checks.
+//
+// The state also checks that tables do not get new keys while they are traversed
+// with next or pairs, which raises "invalid key to 'next'" when the traversal goes
+// on, instead of silently leaving the new keys out.
 func WithChecks(enable bool) Option {
 	return func(cfg *config) {
 		cfg.check = enable
@@ -4,8 +4,16 @@ const (
 	// The Lua version to which this implementation conforms
 	Version = "Lua 5.3"
 
+	// The version of this implementation, _GOLUA.version in scripts
+	GoLuaVersion = "0.1.0"
+
 	// Option for multiple returns in 'lua_pcall' and 'lua_call'
 	MultRets = -1
+
+	// MaxSize is the maximum size of strings and tables, and the maximum
+	// index of the array part of tables: the largest Go int, which is smaller
+	// than the largest Lua integer on 32-bit platforms.
+	MaxSize = int(^uint(0) >> 1)
 )
 
 const (
@@ -45,12 +53,10 @@ const (
 	// Value must fit in a VM register.
 	MaxUpValues = 255
 
-	// Limit for table tag-method chains (to avoid loops).
+	// Limit for table tag-method chains (to avoid loops), the MAXTAGLOOP of
+	// the C implementation.
 	MaxMetaChain = 2000
 
-	// Limit for table tag-method chains (to avoid loops).
-	metaLoopMax = 10
-
 	// Maximum depth for nested Go calls and syntactical nested non-terminals
 	// in a program.
 	//
@@ -0,0 +1,68 @@
+package lua
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// ErrInterrupted is the error of the scripts that DoWithContext stops when its
+// context is done; the error also wraps the error of the context, such as
+// context.DeadlineExceeded.
+var ErrInterrupted = errors.New("script interrupted")
+
+// interruptedErr is the error raised in a script stopped by its context.
+type interruptedErr struct{ cause error }
+
+func (err interruptedErr) Error() string        { return fmt.Sprintf("%v: %v", ErrInterrupted, err.cause) }
+func (err interruptedErr) Is(target error) bool { return target == ErrInterrupted }
+func (err interruptedErr) Unwrap() error        { return err.cause }
+
+// DoWithContext loads and runs the chunk given as to LoadChunk in protected
+// mode, leaving its results on the stack, and returns its error if any. If ctx
+// is cancelled or its deadline passes while the chunk runs, the script is
+// stopped at its next instruction boundary with an error that wraps both
+// ErrInterrupted and ctx.Err(), so that untrusted scripts get hard timeouts:
+//
+//	ctx, cancel := context.WithTimeout(req.Context(), 100*time.Millisecond)
+//	defer cancel()
+//	if err := state.DoWithContext(ctx, "handler.lua", src); errors.Is(err, lua.ErrInterrupted) {
+//		...
+//	}
+//
+// The error is raised like other errors, so pcall catches it and the script
+// can clean up in Go functions, but it is raised again at every following
+// instruction until DoWithContext returns. Go functions that block, such as
+// on I/O, are not interrupted; they should use the context themselves.
+func (state *State) DoWithContext(ctx context.Context, filename string, source interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return interruptedErr{err}
+	}
+	if err := state.LoadChunk(filename, source, BinaryMode|TextMode); err != nil {
+		return err
+	}
+	var (
+		done     = make(chan struct{})
+		finished int32 // set once the chunk returned
+	)
+	var interrupt func(*State)
+	interrupt = func(thread *State) {
+		if atomic.LoadInt32(&finished) != 0 {
+			return // interrupted after the chunk returned
+		}
+		thread.Interrupt(interrupt)
+		panic(runtimeErr(interruptedErr{ctx.Err()}))
+	}
+	go func() {
+		select {
+		case <-ctx.Done():
+			state.Interrupt(interrupt)
+		case <-done:
+		}
+	}()
+	err := state.PCall(0, MultRets, 0)
+	atomic.StoreInt32(&finished, 1)
+	close(done)
+	return err
+}
@@ -0,0 +1,50 @@
+package lua
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Azure/golua/lua/binary"
+	"github.com/Azure/golua/lua/vm"
+)
+
+func TestDoWithContext(t *testing.T) {
+	state := NewState()
+	defer state.Close()
+
+	chunk := func(code ...uint32) []byte {
+		proto := binary.Prototype{
+			Source:   "@test.lua",
+			Vararg:   1,
+			Stack:    16,
+			Code:     code,
+			Consts:   []interface{}{int64(42)},
+			UpValues: []binary.UpValue{{InStack: 1, Index: 0}},
+			UpNames:  []string{"_ENV"},
+		}
+		for i := range code {
+			proto.PcLnTab = append(proto.PcLnTab, uint32(i+1))
+		}
+		return binary.Dump(&proto, false)
+	}
+
+	// while true do end
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := state.DoWithContext(ctx, "loop", chunk(iAsBx(vm.JMP, 0, -1), iABC(vm.RETURN, 0, 1, 0)))
+	if !errors.Is(err, ErrInterrupted) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("DoWithContext(loop) = %v; want an interruption by the deadline", err)
+	}
+	if err := state.DoWithContext(ctx, "loop", chunk(iAsBx(vm.JMP, 0, -1), iABC(vm.RETURN, 0, 1, 0))); !errors.Is(err, ErrInterrupted) {
+		t.Errorf("DoWithContext with a done context = %v; want ErrInterrupted", err)
+	}
+
+	// return 42
+	state.SetTop(0)
+	err = state.DoWithContext(context.Background(), "answer", chunk(iABx(vm.LOADK, 0, 0), iABC(vm.RETURN, 0, 2, 0)))
+	if err != nil || state.Top() != 1 || state.ToInt(-1) != 42 {
+		t.Errorf("DoWithContext(answer) = %v with %d results; want 42", err, state.Top())
+	}
+}
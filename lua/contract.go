@@ -0,0 +1,102 @@
+package lua
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// AnyArity is the arity in a Contract of a function that may take any number
+// of parameters.
+const AnyArity = -1
+
+// Contract declares the functions that a module must export, such as the
+// callbacks of a game mod: the number of parameters of each function by name,
+// or AnyArity.
+//
+//	contract := lua.Contract{"on_load": 0, "on_tick": 1, "on_event": lua.AnyArity}
+//
+// A vararg function satisfies any arity at least its number of fixed
+// parameters, and a Go function any arity.
+type Contract map[string]int
+
+// ContractError is the error of a module that does not satisfy its contract.
+// It lists all the problems of the module, not only the first.
+type ContractError struct {
+	Module   string   // chunk name of the module
+	Problems []string // one per function, sorted by name
+}
+
+func (err *ContractError) Error() string {
+	return fmt.Sprintf("module '%s' does not satisfy its contract:\n\t%s", err.Module, strings.Join(err.Problems, "\n\t"))
+}
+
+// LoadModule loads and runs the chunk of a module, given as to LoadChunk, and
+// checks that the table it returns exports the functions of contract. If it
+// does, LoadModule pushes the table; otherwise it pushes nothing and returns
+// the error of loading or running the chunk, or a *ContractError, so that a
+// mod missing on_tick fails when it is loaded rather than when the game first
+// calls on_tick.
+func (state *State) LoadModule(filename string, source interface{}, contract Contract) error {
+	if err := state.LoadChunk(filename, source, BinaryMode|TextMode); err != nil {
+		return err
+	}
+	if err := state.PCall(0, 1, 0); err != nil {
+		return err
+	}
+	if err := state.CheckContract(-1, filename, contract); err != nil {
+		state.Pop()
+		return err
+	}
+	return nil
+}
+
+// CheckContract checks that the value at index is a table that exports the
+// functions of contract, and returns a *ContractError naming the module if it
+// does not.
+func (state *State) CheckContract(index int, module string, contract Contract) error {
+	t, ok := state.get(index).(*table)
+	if !ok {
+		return &ContractError{module, []string{fmt.Sprintf("module returned %s, not a table", state.TypeAt(index))}}
+	}
+	var problems []string
+	for _, name := range contract.names() {
+		if problem := checkExport(name, t.get(String(name)), contract[name]); problem != "" {
+			problems = append(problems, problem)
+		}
+	}
+	if len(problems) > 0 {
+		return &ContractError{module, problems}
+	}
+	return nil
+}
+
+// checkExport returns the problem of the exported value v of the function
+// name, or "".
+func checkExport(name string, v Value, arity int) string {
+	cls, ok := v.(*Closure)
+	switch {
+	case IsNone(v):
+		return fmt.Sprintf("missing function '%s'", name)
+	case !ok:
+		return fmt.Sprintf("'%s' is a %s, not a function", name, v.Type())
+	case !cls.isLua() || arity == AnyArity:
+		return ""
+	}
+	proto := cls.binary
+	params := proto.NumParams()
+	if params == arity || proto.IsVararg() && params <= arity {
+		return ""
+	}
+	return fmt.Sprintf("function '%s' (line %d) takes %d parameters, want %d", name, proto.SrcPos, params, arity)
+}
+
+// names returns the function names of c, sorted.
+func (c Contract) names() []string {
+	names := make([]string, 0, len(c))
+	for name := range c {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,200 @@
+package lua
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// PushValue pushes the Go value v, converting maps, slices and arrays deeply
+// to tables where State.Push would push them as userdata: maps to tables with
+// the same keys and values, and slices and arrays to sequences. Nested values convert
+// the same way; nil maps and slices convert to nil, []byte to a string, and
+// other values as with State.Push.
+//
+// PushValue returns an error, and pushes nothing, if v contains itself, or a
+// map has a key that is not valid in a table, such as nil or NaN.
+func (state *State) PushValue(v interface{}) error {
+	x, err := state.fromGo(reflect.ValueOf(v), nil)
+	if err != nil {
+		return err
+	}
+	state.frame().push(x)
+	return nil
+}
+
+// fromGo converts the Go value v to Lua as described for PushValue; seen holds
+// the maps and slices being converted.
+func (state *State) fromGo(v reflect.Value, seen map[interface{}]bool) (Value, error) {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return Nil(1), nil
+	case reflect.Interface:
+		if v.IsNil() {
+			return Nil(1), nil
+		}
+		return state.fromGo(v.Elem(), seen)
+	case reflect.Map, reflect.Slice:
+		if v.IsNil() {
+			return Nil(1), nil
+		}
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			return String(v.Bytes()), nil
+		}
+		type ref struct {
+			ptr uintptr
+			typ reflect.Type
+		}
+		key := ref{v.Pointer(), v.Type()}
+		if seen[key] {
+			return nil, fmt.Errorf("cannot convert a %v that contains itself", v.Type())
+		}
+		if seen == nil {
+			seen = make(map[interface{}]bool)
+		}
+		seen[key] = true
+		defer delete(seen, key)
+		if v.Kind() == reflect.Map {
+			return state.mapFromGo(v, seen)
+		}
+		return state.listFromGo(v, seen)
+	case reflect.Array:
+		return state.listFromGo(v, seen)
+	}
+	return luaValue(state, v), nil
+}
+
+// mapFromGo converts the Go map v to a table.
+func (state *State) mapFromGo(v reflect.Value, seen map[interface{}]bool) (Value, error) {
+	t := newTable(state, 0, v.Len())
+	for iter := v.MapRange(); iter.Next(); {
+		k, err := state.fromGo(iter.Key(), seen)
+		if err != nil {
+			return nil, err
+		}
+		if f, ok := k.(Float); IsNone(k) || ok && f != f {
+			return nil, fmt.Errorf("cannot convert the key %v of a %v to a table key", iter.Key(), v.Type())
+		}
+		x, err := state.fromGo(iter.Value(), seen)
+		if err != nil {
+			return nil, err
+		}
+		t.set(k, x)
+	}
+	return t, nil
+}
+
+// listFromGo converts the Go slice or array v to a sequence.
+func (state *State) listFromGo(v reflect.Value, seen map[interface{}]bool) (Value, error) {
+	list := make([]Value, v.Len())
+	for i := range list {
+		x, err := state.fromGo(v.Index(i), seen)
+		if err != nil {
+			return nil, err
+		}
+		list[i] = x
+	}
+	t := newTable(state, 0, 0)
+	t.setList(0, list)
+	return t, nil
+}
+
+// ToGoValue converts the value at index to Go, converting tables deeply: nil
+// to nil, booleans to bool, integers to int64, floats to float64, strings to
+// string, userdata to their Go value, tables with only the keys 1 to n to
+// []interface{}, tables with only string keys to map[string]interface{},
+// other tables to map[interface{}]interface{}, and other values, such as
+// functions, as is.
+//
+// ToGoValue returns an error if a table contains itself.
+func (state *State) ToGoValue(index int) (interface{}, error) {
+	return toGo(state.get(index), nil)
+}
+
+// toGo converts the Lua value v to Go as described for ToGoValue; seen holds
+// the tables being converted.
+func toGo(v Value, seen map[*table]bool) (interface{}, error) {
+	switch v := v.(type) {
+	case Nil:
+		return nil, nil
+	case Bool:
+		return bool(v), nil
+	case Int:
+		return int64(v), nil
+	case Float:
+		return float64(v), nil
+	case String:
+		return string(v), nil
+	case *Object:
+		return v.data, nil
+	case *table:
+		if seen[v] {
+			return nil, fmt.Errorf("cannot convert a table that contains itself")
+		}
+		if seen == nil {
+			seen = make(map[*table]bool)
+		}
+		seen[v] = true
+		defer delete(seen, v)
+		return tableToGo(v, seen)
+	}
+	return v, nil
+}
+
+// tableToGo converts the table t to a slice if its keys are 1 to n, to a map
+// with string keys if they are all strings, and to a map otherwise.
+func tableToGo(t *table, seen map[*table]bool) (interface{}, error) {
+	var (
+		list = make([]interface{}, 0, len(t.list))
+		hash map[interface{}]interface{}
+		strs = true // all the keys are strings
+		err  error
+	)
+	t.ForEach(func(k, v Value) {
+		if err != nil {
+			return
+		}
+		var gk, gv interface{}
+		if gk, err = toGo(k, seen); err != nil {
+			return
+		}
+		if !hashable(gk) {
+			err = fmt.Errorf("cannot convert a %s key to a Go map key", k.Type())
+			return
+		}
+		if gv, err = toGo(v, seen); err != nil {
+			return
+		}
+		if n, ok := k.(Int); ok && hash == nil && int(n) == len(list)+1 {
+			list = append(list, gv)
+			return
+		}
+		if hash == nil {
+			hash = make(map[interface{}]interface{}, len(list)+1)
+			for i, lv := range list {
+				hash[int64(i+1)] = lv
+			}
+		}
+		_, isStr := k.(String)
+		strs = strs && isStr && len(list) == 0
+		hash[gk] = gv
+	})
+	switch {
+	case err != nil:
+		return nil, err
+	case hash != nil && strs:
+		m := make(map[string]interface{}, len(hash))
+		for k, v := range hash {
+			m[k.(string)] = v
+		}
+		return m, nil
+	case hash != nil:
+		return hash, nil
+	}
+	return list, nil
+}
+
+// hashable reports whether x can be used as a key of a Go map: tables convert
+// to slices and maps, which cannot.
+func hashable(x interface{}) bool {
+	return x == nil || reflect.TypeOf(x).Comparable()
+}
@@ -0,0 +1,316 @@
+package lua
+
+// coStatus is the status of a coroutine, as reported by coroutine.status.
+type coStatus int
+
+const (
+	coSuspended coStatus = iota // not started yet, or yielded
+	coRunning                   // running
+	coNormal                    // active but not running (it resumed another coroutine)
+	coDead                      // finished, failed or closed
+)
+
+var coStatusNames = [...]string{
+	coSuspended: "suspended",
+	coRunning:   "running",
+	coNormal:    "normal",
+	coDead:      "dead",
+}
+
+// coroutine is the state of a thread created by NewThread.
+//
+// A coroutine runs on a goroutine of its own, which hands control back and
+// forth with its resumer over channels: only one of them runs at a time, so
+// the threads of a state still need no locking. Since a yield only blocks
+// the coroutine's goroutine, coroutines can yield from anywhere, including
+// across Go functions such as pcall, metamethods and library callbacks.
+type coroutine struct {
+	value   *thread       // the thread as a Lua value
+	status  coStatus      // see coStatus
+	started bool          // whether the body was started
+	nested  int           // number of coroutines resuming it, plus one
+	resumer *State        // thread that resumed it, while active
+	err     error         // error the coroutine died with
+	resume  chan []Value  // values passed by Resume; closed to kill it
+	yield   chan transfer // values passed back by Yield or the body
+}
+
+// transfer is the outcome of running a coroutine until it yields or ends.
+type transfer struct {
+	values []Value     // values yielded or returned
+	err    error       // error raised by the body
+	panic  interface{} // other value the body panicked with
+	done   bool        // whether the body ended
+}
+
+// job is a coroutine body for a worker goroutine to run.
+type job struct {
+	co   *State
+	fn   Value
+	args []Value
+}
+
+// killed is the value a suspended coroutine panics with when it is closed,
+// to unwind its goroutine. It is not an error, so that PCall does not catch it.
+type killed struct{}
+
+// ThreadStats reports the coroutines of a state; see Stats.
+type ThreadStats struct {
+	Suspended int `json:"suspended"` // started coroutines waiting to be resumed
+	Idle      int `json:"idle"`      // goroutines pooled to run new coroutines
+	Hits      int `json:"hits"`      // coroutines started on a pooled goroutine
+	Misses    int `json:"misses"`    // coroutines started on a new goroutine
+}
+
+// WithThreadPool returns an Option that keeps up to size goroutines of dead
+// coroutines to run the coroutines started later, along with the stacks they
+// have grown, instead of starting a goroutine for each. It is worth it for
+// scripts creating coroutines at a high rate, typically with coroutine.wrap.
+// The pooled goroutines exit when the state is closed.
+func WithThreadPool(size int) Option {
+	return func(cfg *config) {
+		cfg.threadPool = size
+	}
+}
+
+// WithResumeTraceback returns an Option that makes tracebacks of coroutines
+// go on with the frames of the threads that resumed them, after a
+// "(resumed from)" line, so that an error in a coroutine shows where it was
+// driven from.
+func WithResumeTraceback(enable bool) Option {
+	return func(cfg *config) {
+		cfg.resumeTraceback = enable
+	}
+}
+
+// NewThread creates a new thread, pushes it on the stack, and returns it. The
+// new thread shares the global environment of the state, but has its own
+// stack: push its body on it, and start it with Resume.
+//
+// Threads are not collected while they are suspended in a yield, since
+// their goroutine is blocked: a thread that yields and is then dropped, such
+// as a generator of coroutine.wrap abandoned in the middle of a loop, keeps
+// its goroutine and stack until it is closed with CloseThread or the state is
+// closed. Long-lived states should close the threads they abandon, and can
+// watch their number in the Suspended field of Stats().Threads.
+//
+// See https://www.lua.org/manual/5.3/manual.html#lua_newthread
+func (state *State) NewThread() *State {
+	co := new(State).reset()
+	co.enter(new(Frame))
+	co.init(state.global)
+	co.co = &coroutine{}
+	co.co.value = &thread{co}
+	if h := state.hook; h != nil {
+		co.SetHook(h.fn, h.mask, h.count)
+	}
+	state.Push(co.co.value)
+	return co
+}
+
+// Resume starts or resumes the thread co, passing it the args values at the
+// top of the stack, which it pops. To start a thread, its body must be on its
+// stack, as pushed after NewThread; the body gets the values as arguments,
+// and a thread suspended by Yield gets them as the results of Yield.
+//
+// Resume returns when the thread yields or returns, pushing the values it
+// yielded or returned and reporting how many. If the thread raises an error,
+// it dies and Resume returns the error, pushing nothing.
+//
+// See https://www.lua.org/manual/5.3/manual.html#lua_resume
+func (state *State) Resume(co *State, args int) (rets int, err error) {
+	c := co.co
+	switch {
+	case c == nil || c.status == coRunning || c.status == coNormal:
+		return 0, state.messageErr(MsgResumeActive)
+	case c.status == coDead || (!c.started && co.Top() == 0):
+		return 0, state.messageErr(MsgResumeDead)
+	}
+	nested := 1
+	if r := state.co; r != nil {
+		nested += r.nested
+		r.status = coNormal
+		defer func() { r.status = coRunning }()
+	}
+	if nested >= MaxCalls {
+		return 0, state.messageErr(MsgStackOverflow)
+	}
+	values := state.frame().popN(args)
+	c.nested, c.resumer, c.status = nested, state, coRunning
+	if !c.started {
+		c.started = true
+		c.resume = make(chan []Value)
+		c.yield = make(chan transfer)
+		state.global.spawn(job{co, co.frame().pop(), values})
+	} else {
+		c.resume <- values
+	}
+	t := <-c.yield
+	c.resumer = nil
+	if c.status = coSuspended; t.done {
+		c.status, c.err = coDead, t.err
+		co.SetTop(0)
+		delete(state.global.threads, c)
+	} else {
+		state.global.threads[c] = true
+	}
+	if t.panic != nil {
+		panic(t.panic)
+	}
+	if t.err != nil {
+		return 0, t.err
+	}
+	state.frame().pushN(t.values)
+	return len(t.values), nil
+}
+
+// Yield suspends the running coroutine, passing the rets values at the top of
+// the stack, which it pops, to its resumer. Yield returns when the coroutine
+// is resumed again, pushing the values passed to Resume and reporting how
+// many, so that a Go function yields with:
+//
+//	return state.Yield(n)
+//
+// Unlike lua_yield, Yield returns to its caller, and can be called from
+// anywhere the coroutine runs.
+//
+// See https://www.lua.org/manual/5.3/manual.html#lua_yield
+func (state *State) Yield(rets int) int {
+	c := state.co
+	if c == nil {
+		state.Raise(MsgYieldOutside)
+	}
+	c.yield <- transfer{values: state.frame().popN(rets)}
+	values, ok := <-c.resume
+	if !ok {
+		panic(killed{})
+	}
+	state.frame().pushN(values)
+	return len(values)
+}
+
+// IsYieldable reports whether the state can yield, that is whether it is a
+// coroutine; the main thread cannot yield.
+//
+// See https://www.lua.org/manual/5.3/manual.html#lua_isyieldable
+func (state *State) IsYieldable() bool { return state.co != nil }
+
+// PushThread pushes the thread represented by state onto its stack, and
+// reports whether it is the main thread of its state.
+//
+// See https://www.lua.org/manual/5.3/manual.html#lua_pushthread
+func (state *State) PushThread() bool {
+	if state.co == nil {
+		state.Push(state.global.registry.getInt(MainThreadIndex))
+		return true
+	}
+	state.Push(state.co.value)
+	return false
+}
+
+// CoStatus returns the status of the thread co as seen from the running
+// thread state, as coroutine.status does: "running", "suspended" (not
+// started yet or yielded), "normal" (active but not running, because it
+// resumed another coroutine) or "dead".
+func (state *State) CoStatus(co *State) string {
+	switch {
+	case co == state:
+		return coStatusNames[coRunning]
+	case co.co == nil:
+		return coStatusNames[coNormal] // main thread
+	}
+	return coStatusNames[co.co.status]
+}
+
+// CloseThread closes the thread co, which must be suspended or dead: a thread
+// suspended in a yield is unwound, releasing its goroutine, and is then dead.
+// CloseThread returns the error the thread died with, if any, so that the
+// error remains reportable once Resume has returned it.
+//
+// See https://www.lua.org/manual/5.4/manual.html#lua_resetthread
+func (state *State) CloseThread(co *State) error {
+	c := co.co
+	if status := state.CoStatus(co); status == "running" || status == "normal" {
+		state.Raise(MsgCloseActive, status)
+	}
+	if c.status == coSuspended {
+		if c.started {
+			state.global.kill(c)
+		}
+		c.status = coDead
+		co.SetTop(0)
+	}
+	return c.err
+}
+
+// body runs the body fn of the coroutine co with args, returning the outcome
+// to pass to its resumer.
+func (co *State) body(fn Value, args []Value) (t transfer) {
+	defer func() {
+		switch r := recover().(type) {
+		case nil, killed:
+		case error:
+			t.err = r
+		default:
+			t.panic = r
+		}
+		t.done = true
+	}()
+	co.frame().push(fn)
+	co.frame().pushN(args)
+	co.Call(len(args), MultRets)
+	t.values = co.frame().popN(co.Top())
+	return t
+}
+
+// spawn runs a coroutine body on a pooled goroutine, or on a new one.
+func (g *global) spawn(jb job) {
+	if n := len(g.idle); n > 0 {
+		jobs := g.idle[n-1]
+		g.idle = g.idle[:n-1]
+		g.poolHits++
+		jobs <- jb
+		return
+	}
+	g.poolMisses++
+	jobs := make(chan job)
+	go g.work(jobs)
+	jobs <- jb
+}
+
+// work runs the coroutine bodies received on jobs. Once a body ends, the
+// goroutine goes back to the pool, if there is room, before the resumer gets
+// control back, so that the pool is only accessed by the running thread.
+func (g *global) work(jobs chan job) {
+	for jb := range jobs {
+		t := jb.co.body(jb.fn, jb.args)
+		pooled := !g.closing && len(g.idle) < g.config.threadPool
+		if pooled {
+			g.idle = append(g.idle, jobs)
+		}
+		jb.co.co.yield <- t
+		if !pooled {
+			return
+		}
+	}
+}
+
+// kill unwinds the suspended coroutine c and waits for its body to end.
+func (g *global) kill(c *coroutine) {
+	close(c.resume)
+	<-c.yield
+	delete(g.threads, c)
+}
+
+// closeThreads kills the suspended coroutines and stops the pooled
+// goroutines, for Close.
+func (g *global) closeThreads() {
+	for c := range g.threads {
+		g.kill(c)
+		c.status = coDead
+	}
+	for _, jobs := range g.idle {
+		close(jobs)
+	}
+	g.idle = nil
+}
@@ -0,0 +1,176 @@
+package lua
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// resume resumes co with args and returns its results, or the error.
+func resume(state *State, co *State, args ...interface{}) string {
+	for _, arg := range args {
+		state.Push(arg)
+	}
+	rets, err := state.Resume(co, len(args))
+	if err != nil {
+		return err.Error()
+	}
+	return fmt.Sprint(state.PopN(rets))
+}
+
+func TestCoroutine(t *testing.T) {
+	state := NewState()
+	defer state.Close()
+	state.Register("yield", func(state *State) int {
+		return state.Yield(state.Top())
+	})
+
+	// The body yields from a Go function called by a Lua function.
+	co := state.NewThread()
+	if err := loadProto(co, wideSelect, "yield"); err != nil {
+		t.Fatal(err)
+	}
+	for _, step := range []struct {
+		args   []interface{}
+		want   string
+		status string
+	}{
+		{[]interface{}{int64(1), "a", "b"}, "[1 1 a b]", "suspended"},
+		{[]interface{}{"x", "y"}, "[x y]", "dead"},
+		{nil, "cannot resume dead coroutine", "dead"},
+	} {
+		if got := resume(state, co, step.args...); got != step.want {
+			t.Errorf("resume%v = %s; want %s", step.args, got, step.want)
+		}
+		if got := state.CoStatus(co); got != step.status {
+			t.Errorf("status after resume%v = %s; want %s", step.args, got, step.status)
+		}
+	}
+	state.Pop() // co
+
+	// Errors kill the coroutine and remain reportable.
+	co = state.NewThread()
+	co.Push(Func(func(state *State) int {
+		state.Yield(0)
+		return state.Errorf("boom")
+	}))
+	resume(state, co)
+	if got := resume(state, co); got != "boom" {
+		t.Errorf("resume = %s; want boom", got)
+	}
+	if status := co.Status(); status != ThreadError {
+		t.Errorf("Status() = %v; want %v", status, ThreadError)
+	}
+	if err := state.CloseThread(co); err == nil || err.Error() != "boom" {
+		t.Errorf("CloseThread() = %v; want boom", err)
+	}
+	state.Pop()
+
+	// Only coroutines yield, and a coroutine cannot resume itself.
+	state.GetGlobal("yield")
+	if err := state.PCall(0, 0, 0); err == nil || err.Error() != "attempt to yield from outside a coroutine" {
+		t.Errorf("yield from the main thread: %v", err)
+	}
+	co = state.NewThread()
+	co.Push(Func(func(co *State) int {
+		co.Push(state.CoStatus(co))
+		co.Push(co.CoStatus(state))
+		if _, err := co.Resume(co, 0); err != nil {
+			co.Push(err.Error())
+		}
+		return 3
+	}))
+	if got, want := resume(state, co), "[running normal cannot resume non-suspended coroutine]"; got != want {
+		t.Errorf("statuses seen from the coroutine: %s; want %s", got, want)
+	}
+	state.Pop()
+}
+
+func TestThreadPool(t *testing.T) {
+	state := NewState(WithThreadPool(1))
+	defer state.Close()
+
+	body := Func(func(state *State) int { return state.Yield(0) })
+	for i := 0; i < 3; i++ {
+		co := state.NewThread()
+		co.Push(body)
+		resume(state, co) // yields
+		resume(state, co) // returns
+		state.Pop()
+	}
+	co := state.NewThread()
+	co.Push(body)
+	resume(state, co)
+	if got, want := state.Stats().Threads, (ThreadStats{Suspended: 1, Hits: 3, Misses: 1}); got != want {
+		t.Errorf("Threads = %+v; want %+v", got, want)
+	}
+}
+
+func TestCloseThread(t *testing.T) {
+	state := NewState(WithThreadPool(1))
+	defer state.Close()
+
+	var unwound []string
+	body := func(name string) Func {
+		return func(state *State) int {
+			defer func() { unwound = append(unwound, name) }()
+			state.Push(Func(func(state *State) int { return state.Yield(0) }))
+			state.PCall(0, 0, 0) // does not catch the closing
+			return 0
+		}
+	}
+
+	// Closing a suspended coroutine unwinds it.
+	co := state.NewThread()
+	co.Push(body("closed"))
+	resume(state, co)
+	if got := state.Stats().Threads.Suspended; got != 1 {
+		t.Errorf("Suspended = %d; want 1", got)
+	}
+	if err := state.CloseThread(co); err != nil {
+		t.Errorf("CloseThread() = %v", err)
+	}
+	if got := state.CoStatus(co); got != "dead" {
+		t.Errorf("status after CloseThread = %s; want dead", got)
+	}
+
+	// The goroutine of the closed coroutine runs the next one.
+	co = state.NewThread()
+	co.Push(body("state closed"))
+	resume(state, co)
+	threads := state.Stats().Threads
+	if want := (ThreadStats{Suspended: 1, Hits: 1, Misses: 1}); threads != want {
+		t.Errorf("Threads = %+v; want %+v", threads, want)
+	}
+
+	// Closing the state unwinds the suspended coroutines.
+	state.Close()
+	if got := fmt.Sprint(unwound); got != "[closed state closed]" {
+		t.Errorf("unwound %s; want [closed state closed]", got)
+	}
+}
+
+func TestResumeTraceback(t *testing.T) {
+	state := NewState(WithResumeTraceback(true))
+	defer state.Close()
+
+	var traceback string
+	state.Register("inner", func(state *State) int {
+		traceback = state.Traceback("")
+		return 0
+	})
+	co := state.NewThread()
+	co.Push(Func(func(co *State) int {
+		co.GetGlobal("inner")
+		co.Call(0, 0)
+		return 0
+	}))
+	state.Push(Func(func(state *State) int {
+		resume(state, co)
+		return 0
+	}))
+	state.Call(0, 0)
+	if got := strings.Count(traceback, "[Go]"); got != 3 || !strings.Contains(traceback, "\n\t(resumed from)\n") {
+		t.Errorf("traceback has %d Go frames and no resumer:\n%s", got, traceback)
+	}
+}
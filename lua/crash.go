@@ -0,0 +1,112 @@
+package lua
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	"github.com/Azure/golua/lua/binary"
+)
+
+// vmPanic wraps a Go runtime error raised while executing a Lua function once
+// its crash report has been written, so that enclosing frames don't report it
+// again.
+type vmPanic struct{ error }
+
+// WithCrashReports returns an Option that writes a crash report to w whenever
+// the VM fails an internal invariant, that is when a Go runtime error such as
+// an out of range index is raised while executing a Lua function. The report
+// holds the bytecode of the active function, the Lua call stack, the recent
+// instructions if WithInstructionTrace is enabled and the Go stack of the
+// failure.
+//
+// The error is still raised afterwards and, unless recovered by PCall, crashes
+// the program as before.
+func WithCrashReports(w io.Writer) Option {
+	return func(cfg *config) {
+		cfg.crash = w
+	}
+}
+
+// crashed writes the crash report for the recovered value r, if it is a Go
+// runtime error, and panics again with r. It is only called when crash
+// reports are enabled.
+func (state *State) crashed(r interface{}) {
+	if err, ok := r.(runtime.Error); ok {
+		state.crashReport(state.global.config.crash, err, debug.Stack())
+		r = vmPanic{err}
+	}
+	panic(r)
+}
+
+// crashReport writes the crash report for err, raised with the Go stack
+// goStack, to w.
+func (state *State) crashReport(w io.Writer, err error, goStack []byte) {
+	b := bufio.NewWriter(w)
+	defer b.Flush()
+
+	fmt.Fprintf(b, "golua crash report\n")
+	fmt.Fprintf(b, "time: %s\n", time.Now().Format(time.RFC3339Nano))
+	fmt.Fprintf(b, "error: %v\n\n", err)
+
+	fmt.Fprintf(b, "%s\n\n", state.Traceback(""))
+
+	fr := state.frame()
+	if cls := fr.function(); cls.isLua() {
+		fmt.Fprintf(b, "active function: %s:%d (pc %d)\n", cls.binary.Source, currentLine(fr), fr.pc-1)
+		fmt.Fprintf(b, "bytecode (base64):\n")
+		enc := base64.StdEncoding.EncodeToString(binaryDump(cls))
+		for len(enc) > 76 {
+			fmt.Fprintf(b, "%s\n", enc[:76])
+			enc = enc[76:]
+		}
+		fmt.Fprintf(b, "%s\n\n", enc)
+	}
+
+	fmt.Fprintf(b, "lua stack:\n")
+	for ; fr != nil && fr != &state.base; fr = fr.prev {
+		fmt.Fprintf(b, "frame #%d: %v (pc %d)\n", fr.depth, fr.closure, fr.pc)
+		for i, v := range fr.locals {
+			fmt.Fprintf(b, "\t[%d] %s\n", i+1, describe(v))
+		}
+		for i, v := range fr.vararg {
+			fmt.Fprintf(b, "\t[...%d] %s\n", i+1, describe(v))
+		}
+	}
+
+	if state.global.ring != nil {
+		fmt.Fprintf(b, "\nrecent instructions:\n")
+		state.DumpInstructions(b)
+	}
+
+	fmt.Fprintf(b, "\ngo stack:\n%s", goStack)
+}
+
+// binaryDump dumps the closure's prototype, recovering from failures since the
+// prototype may be what is corrupted.
+func binaryDump(cls *Closure) (chunk []byte) {
+	defer func() {
+		if r := recover(); r != nil {
+			chunk = nil
+		}
+	}()
+	return binary.Dump(cls.binary, false)
+}
+
+// describe returns a short description of v for crash reports.
+func describe(v Value) string {
+	switch v := v.(type) {
+	case nil:
+		return "<nil>"
+	case String:
+		if len(v) > 64 {
+			return fmt.Sprintf("%q...", string(v[:64]))
+		}
+		return fmt.Sprintf("%q", string(v))
+	}
+	return fmt.Sprintf("%s: %v", v.Type(), v)
+}
@@ -0,0 +1,52 @@
+package lua
+
+import (
+	"bytes"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/Azure/golua/lua/vm"
+)
+
+func TestCrashReports(t *testing.T) {
+	var report bytes.Buffer
+	state := NewState(WithCrashReports(&report), WithInstructionTrace(4))
+	defer state.Close()
+
+	state.Register("crash", func(state *State) int {
+		var list []int
+		return list[state.Top()]
+	})
+	// crash()
+	err := loadProto(state, []uint32{
+		iABC(vm.GETTABUP, 0, 0, rk(0)), // R0 := crash
+		iABC(vm.CALL, 0, 1, 1),         // crash()
+		iABC(vm.RETURN, 0, 1, 0),       // return
+	}, "crash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = state.PCall(0, 0, 0)
+	if p, ok := err.(vmPanic); !ok {
+		t.Errorf("PCall returned %#v; want a vmPanic", err)
+	} else if _, ok := p.error.(runtime.Error); !ok {
+		t.Errorf("vmPanic wraps %#v; want a runtime.Error", p.error)
+	}
+	for _, section := range []string{
+		"golua crash report\n",
+		"error: runtime error: index out of range",
+		"stack traceback:\n\ttest.lua:2: in main chunk\n",
+		"active function: @test.lua:2 (pc 1)\nbytecode (base64):\n",
+		"lua stack:\nframe #1: ",
+		"recent instructions:\ntest.lua:1: [0] GETTABUP\ntest.lua:2: [1] CALL\n",
+		"go stack:\n",
+	} {
+		if !strings.Contains(report.String(), section) {
+			t.Errorf("crash report has no %q:\n%s", section, report.String())
+		}
+	}
+	if n := strings.Count(report.String(), "golua crash report"); n != 1 {
+		t.Errorf("crash reported %d times; want once", n)
+	}
+}
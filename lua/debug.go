@@ -6,6 +6,9 @@ import (
 	"reflect"
 	"runtime"
 	"strings"
+
+	"github.com/Azure/golua/lua/binary"
+	"github.com/Azure/golua/lua/vm"
 )
 
 // debug is a structure used to carry different pieces of information about a function
@@ -25,6 +28,8 @@ type Debug struct {
 	params   int
 	vararg   bool
 	tailcall bool
+	frame    *Frame    // activation record, see GetStack
+	event    HookEvent // event of a hook call, see SetHook
 }
 
 func (debug *Debug) Source() string       { return debug.source }
@@ -39,6 +44,7 @@ func (debug *Debug) IsVararg() bool       { return debug.vararg }
 func (debug *Debug) Name() string         { return debug.name }
 func (debug *Debug) NameWhat() string     { return debug.kind }
 func (debug *Debug) IsTailCall() bool     { return debug.tailcall }
+func (debug *Debug) Event() HookEvent     { return debug.event }
 
 // GetStack returns debug information about the interpreter runtime stack.
 //
@@ -46,13 +52,24 @@ func (debug *Debug) IsTailCall() bool     { return debug.tailcall }
 // the activation record of the function executing at a given level. Level 0
 // is the current running function, whereas level n+1 is the function that has
 // called level n (except for tail calls, which do not count on the stack).
-// When there are no errors, StackDepth returns the Debug structure and nil;
-// Otherwise returns nil and any error.
+// GetStack returns an error when called with a level greater than the stack
+// depth.
 //
 // See https://www.lua.org/manual/5.3/manual.html#lua_getstack
-func (state *State) GetStack(debug *Debug, depth int) error {
-	fmt.Println("state.GetStack(): TODO")
-	return nil
+func (state *State) GetStack(debug *Debug, level int) error {
+	if level >= 0 {
+		for fr := state.frame(); fr != nil && fr != &state.base; fr = fr.prev {
+			if fr.function() == nil {
+				continue
+			}
+			if level == 0 {
+				debug.frame = fr
+				return nil
+			}
+			level--
+		}
+	}
+	return state.messageErr(MsgLevelRange)
 }
 
 // DebugInfo returns debug information about a specific function or function invocation.
@@ -87,30 +104,36 @@ func (state *State) GetStack(debug *Debug, depth int) error {
 //
 // If this option is given together with option 'f', its table is pushed after the function.
 //
-// This function returns 0 on error (for instance, an invalid option in what).
+// This function returns an error for an invalid option in what, in which case
+// it pushes nothing.
 //
 // See https://www.lua.org/manual/5.3/manual.html#lua_getinfo
 func (state *State) GetInfo(debug *Debug, options string) error {
 	if len(options) > 0 && options[0] == '>' {
 		if cls, ok := state.frame().pop().(*Closure); ok {
-			return state.getInfo(state.frame(), debug, cls, options[1:])
+			return state.getInfo(nil, debug, cls, options[1:])
 		}
-		return fmt.Errorf("function expected")
+		return state.messageErr(MsgFuncExpected)
+	}
+	if debug.frame == nil {
+		return state.messageErr(MsgNoActivation)
 	}
-	return fmt.Errorf("state.GetInfo(): TODO")
+	return state.getInfo(debug.frame, debug, debug.frame.closure, options)
 }
 
+// getInfo fills debug with the information selected by options about the
+// closure, running in frame unless frame is nil.
 func (state *State) getInfo(frame *Frame, debug *Debug, closure *Closure, options string) error {
 	for pos := 0; pos < len(options); pos++ {
 		switch b := options[pos]; b {
 		case 'S':
 			funcinfo(frame, debug, closure)
 		case 'l':
-			if debug.active = -1; closure.isLua() && frame.pc > 0 {
-				currentline := int(closure.binary.PcLnTab[frame.pc])
-				debug.active = currentline
+			if debug.active = -1; closure.isLua() && frame != nil {
+				debug.active = currentLine(frame)
 			}
 		case 'u':
+			debug.nups = len(closure.upvals)
 			if !closure.isLua() {
 				debug.vararg = true
 				debug.params = 0
@@ -119,20 +142,107 @@ func (state *State) getInfo(frame *Frame, debug *Debug, closure *Closure, option
 				debug.params = closure.binary.NumParams()
 			}
 		case 't':
-			debug.tailcall = frame.status&callStatusTail != 0
+			debug.tailcall = frame != nil && frame.status&callStatusTail != 0
 		case 'n':
-			name, kind := funcname(frame, closure)
-			debug.name = name
-			debug.kind = kind
+			debug.name, debug.kind = "", ""
+			if frame != nil {
+				debug.name, debug.kind = funcname(frame, closure)
+			}
 		case 'L', 'f':
-			// TODO
+			// pushed below, once the options are known to be valid
 		default:
-			return fmt.Errorf("invalid option: %c", b)
+			return state.messageErr(MsgInfoOption, b)
+		}
+	}
+	if strings.IndexByte(options, 'f') != -1 {
+		state.Push(closure)
+	}
+	if strings.IndexByte(options, 'L') != -1 {
+		if !closure.isLua() {
+			state.Push(nil)
+		} else {
+			lines := newTable(state, 0, len(closure.binary.PcLnTab))
+			for _, line := range closure.binary.PcLnTab {
+				lines.set(Int(line), True)
+			}
+			state.Push(lines)
 		}
 	}
 	return nil
 }
 
+// GetLocal gets information about the local variable n of the activation
+// record debug, as filled by GetStack or given to a hook. It pushes the value
+// of the variable onto the stack and returns its name, or returns "" and
+// pushes nothing if there is no such variable.
+//
+// Local variables are numbered from 1 in the order they are declared, counting
+// only the variables active at the current instruction; past those, the other
+// values on the function's stack are named "(*temporary)", and negative
+// numbers name its vararg arguments "(*vararg)".
+//
+// If debug is nil, n is a parameter of the function at the top of the stack,
+// and GetLocal returns its name without pushing anything.
+//
+// See https://www.lua.org/manual/5.3/manual.html#lua_getlocal
+func (state *State) GetLocal(debug *Debug, n int) string {
+	if debug == nil {
+		if cls, ok := state.frame().local(-1).(*Closure); ok && cls.isLua() {
+			return localName(cls.binary, n, 0)
+		}
+		return ""
+	}
+	name, slot := findLocal(debug.frame, n)
+	if slot != nil {
+		state.Push(*slot)
+	}
+	return name
+}
+
+// SetLocal sets the local variable n of the activation record debug to the
+// value at the top of the stack, which it pops, and returns its name. It
+// returns "" if there is no such variable, popping the value nonetheless.
+// Variables are numbered as in GetLocal.
+//
+// See https://www.lua.org/manual/5.3/manual.html#lua_setlocal
+func (state *State) SetLocal(debug *Debug, n int) string {
+	value := state.frame().pop()
+	name, slot := findLocal(debug.frame, n)
+	if slot != nil {
+		*slot = stackValue(value)
+	}
+	return name
+}
+
+// findLocal returns the name and the stack slot of the local variable n of
+// the frame fr, or "" and nil if there is no such variable.
+func findLocal(fr *Frame, n int) (string, *Value) {
+	if fr == nil {
+		return "", nil
+	}
+	var name string
+	if fr.closure.isLua() {
+		if n < 0 {
+			if n = -n; n <= len(fr.vararg) {
+				return "(*vararg)", &fr.vararg[n-1]
+			}
+			return "", nil
+		}
+		pc := fr.pc - 1
+		if pc < 0 {
+			pc = 0
+		}
+		name = localName(fr.closure.binary, n, pc)
+	}
+	if n <= 0 || n > len(fr.locals) {
+		return "", nil
+	}
+	if name == "" {
+		name = "(*temporary)"
+	}
+	return name, &fr.locals[n-1]
+}
+
 // GetUpValue gets information about the n-th upvalue of the closure at index funcindex.
 // It pushes the upvalue's value onto the stack and returns its name. Returns NULL (and
 // pushes nothing) when the index n is greater than the number of upvalues.
@@ -173,7 +283,7 @@ func (state *State) UpValueID(function, index int) interface{} {
 			return cls.getUp(index - 1)
 		}
 	}
-	panic(fmt.Errorf("closure expected"))
+	panic(state.messageErr(MsgNotClosure))
 }
 
 // UpValueJoin makes the n1-th upvalue of the Lua closure at index func1 refer to the n2-th
@@ -224,9 +334,6 @@ func (evt HookEvent) String() string {
 	return s
 }
 
-// The hook table at registry[HookKey] maps threads to their current hook function.
-const HookKey = 0
-
 func (state *State) Debug(halt bool) {
 	DBG(state.frame(), halt)
 }
@@ -275,16 +382,175 @@ func DBG(fr *Frame, halt bool) {
 	}
 }
 
+// funcname returns the name of the function closure running in frame and what
+// kind of name it is, as found in the code of the calling function. Go
+// functions without such a name are named after their Go function.
 func funcname(frame *Frame, closure *Closure) (name, what string) {
+	if name, what = callname(frame); what != "" {
+		return name, what
+	}
 	if !closure.isLua() {
-		pc := reflect.ValueOf(closure.native).Pointer()
-		fn := runtime.FuncForPC(pc)
-		return fn.Name(), ""
+		return goname(closure), ""
+	}
+	return "", ""
+}
+
+// goname returns the name of the Go function of closure.
+func goname(closure *Closure) string {
+	pc := reflect.ValueOf(closure.native).Pointer()
+	return runtime.FuncForPC(pc).Name()
+}
+
+// callname returns the name of the function running in frame and what kind of
+// name it is, found from the instruction of the calling Lua function that
+// called it, or "" if the caller is not a Lua function.
+func callname(frame *Frame) (name, what string) {
+	caller := frame.caller()
+	switch {
+	case caller == nil:
+		return "", ""
+	case caller.status&callStatusHooked != 0:
+		return "?", "hook"
+	case !caller.closure.isLua() || caller.pc == 0:
+		return "", ""
+	}
+	var (
+		proto = caller.closure.binary
+		pc    = caller.pc - 1
+		instr = caller.code(pc)
+	)
+	switch op := instr.Code(); op {
+	case vm.CALL, vm.TAILCALL:
+		return objname(proto, pc, instr.A())
+	case vm.TFORCALL:
+		return "for iterator", "for iterator"
+	case vm.SELF, vm.GETTABUP, vm.GETTABLE:
+		return "index", "metamethod"
+	case vm.SETTABUP, vm.SETTABLE:
+		return "newindex", "metamethod"
+	case vm.ADD, vm.SUB, vm.MUL, vm.MOD, vm.POW, vm.DIV, vm.IDIV,
+		vm.BAND, vm.BOR, vm.BXOR, vm.SHL, vm.SHR, vm.UNM, vm.BNOT,
+		vm.LEN, vm.CONCAT, vm.EQ, vm.LT, vm.LE:
+		return strings.ToLower(op.String()), "metamethod"
+	}
+	return "", ""
+}
+
+// objname returns a name for the value in register reg of proto at
+// instruction lastpc, and what kind of name it is: "global", "local",
+// "method", "field", "upvalue" or "constant", or "" if none is found.
+func objname(proto *binary.Prototype, lastpc, reg int) (name, what string) {
+	if name = localName(proto, reg+1, lastpc); name != "" {
+		return name, "local"
+	}
+	pc := findsetreg(proto, lastpc, reg)
+	if pc == -1 {
+		return "", ""
+	}
+	switch instr := vm.Instr(proto.Code[pc]); instr.Code() {
+	case vm.MOVE:
+		if b := instr.B(); b < instr.A() {
+			return objname(proto, pc, b)
+		}
+	case vm.GETTABUP, vm.GETTABLE:
+		var table string
+		if instr.Code() == vm.GETTABLE {
+			table = localName(proto, instr.B()+1, pc)
+		} else if instr.B() < len(proto.UpNames) {
+			table = proto.UpNames[instr.B()]
+		}
+		if name = keyname(proto, pc, instr.C()); table == "_ENV" {
+			return name, "global"
+		}
+		return name, "field"
+	case vm.GETUPVAL:
+		if instr.B() < len(proto.UpNames) {
+			return proto.UpNames[instr.B()], "upvalue"
+		}
+		return "?", "upvalue"
+	case vm.LOADK, vm.LOADKX:
+		k := instr.BX()
+		if instr.Code() == vm.LOADKX {
+			k = vm.Instr(proto.Code[pc+1]).AX()
+		}
+		if s, ok := proto.Consts[k].(string); ok {
+			return s, "constant"
+		}
+	case vm.SELF:
+		return keyname(proto, pc, instr.C()), "method"
 	}
-	// TODO
 	return "", ""
 }
 
+// keyname returns the name of the table key rk, a constant or a register,
+// used by the instruction at pc of proto, or "?".
+func keyname(proto *binary.Prototype, pc, rk int) string {
+	if rk > 0xFF {
+		if s, ok := proto.Consts[rk&0xFF].(string); ok {
+			return s
+		}
+	} else if name, what := objname(proto, pc, rk); what == "constant" {
+		return name
+	}
+	return "?"
+}
+
+// findsetreg returns the last instruction of proto before lastpc that sets the
+// register reg, or -1 if none does or the one found is skipped by a jump.
+func findsetreg(proto *binary.Prototype, lastpc, reg int) int {
+	var (
+		setreg = -1
+		target = 0 // any code before this address is conditional
+	)
+	set := func(pc int) {
+		if setreg = pc; pc < target {
+			setreg = -1
+		}
+	}
+	for pc := 0; pc < lastpc; pc++ {
+		instr := vm.Instr(proto.Code[pc])
+		switch a := instr.A(); instr.Code() {
+		case vm.LOADNIL:
+			if a <= reg && reg <= a+instr.B() {
+				set(pc)
+			}
+		case vm.TFORCALL:
+			if reg >= a+2 {
+				set(pc)
+			}
+		case vm.CALL, vm.TAILCALL:
+			if reg >= a {
+				set(pc)
+			}
+		case vm.JMP:
+			if dest := pc + 1 + instr.SBX(); pc < dest && dest <= lastpc && dest > target {
+				target = dest
+			}
+		default:
+			if instr.Code().Mask().SetA() && reg == a {
+				set(pc)
+			}
+		}
+	}
+	return setreg
+}
+
+// localName returns the name of the n-th local variable of proto active at
+// instruction pc, or "" if there is none.
+func localName(proto *binary.Prototype, n, pc int) string {
+	for _, local := range proto.Locals {
+		if int(local.Live) > pc {
+			break
+		}
+		if pc < int(local.Dead) {
+			if n--; n == 0 {
+				return local.Name
+			}
+		}
+	}
+	return ""
+}
+
 func funcinfo(frame *Frame, debug *Debug, closure *Closure) {
 	if closure.isLua() {
 		proto := closure.binary
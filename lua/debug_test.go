@@ -0,0 +1,100 @@
+package lua
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Azure/golua/lua/vm"
+)
+
+func TestHook(t *testing.T) {
+	state := NewState()
+	defer state.Close()
+
+	var events []string
+	state.SetHook(func(state *State, debug *Debug) {
+		switch debug.Event() {
+		case HookLine:
+			events = append(events, fmt.Sprint(debug.CurrentLine()))
+		case HookCall:
+			if err := state.GetInfo(debug, "n"); err != nil {
+				t.Fatal(err)
+			}
+			event := strings.TrimSpace("call " + debug.NameWhat() + " " + debug.Name())
+			if name := state.GetLocal(debug, 2); name != "" {
+				event += fmt.Sprintf(" %s=%v", name, state.Pop())
+			}
+			events = append(events, event)
+		case HookRets:
+			events = append(events, "return")
+		}
+	}, HookCall|HookRets|HookLine, 0)
+	runMethodLoop(t, state, new(counter), 2)
+
+	// The loop jumps back to line 6 and the method call is named from the code.
+	const want = "call 1 2 3 4 5 9 6 7 8 call method update (*temporary)=1 return 9 6 7 8 call method update (*temporary)=2 return 9 10 return"
+	if got := strings.Join(events, " "); got != want {
+		t.Errorf("events:\n%s\nwant:\n%s", got, want)
+	}
+
+	// Hooks are counted in instructions, and can be turned off.
+	var count int
+	state.SetHook(func(*State, *Debug) { count++ }, HookCount, 2)
+	runMethodLoop(t, state, new(counter), 2)
+	if count != 7 { // 15 instructions
+		t.Errorf("count events = %d; want 7", count)
+	}
+	state.SetHook(nil, 0, 0)
+	if hook, _, _ := state.GetHook(); hook != nil {
+		t.Error("hook not turned off")
+	}
+}
+
+func TestStepBack(t *testing.T) {
+	state := NewState(WithRecordMode(16))
+	defer state.Close()
+
+	var (
+		lines   []int
+		rewound bool
+	)
+	state.SetHook(func(state *State, debug *Debug) {
+		lines = append(lines, debug.CurrentLine())
+		if debug.CurrentLine() != 4 || rewound {
+			return
+		}
+		rewound = true
+		if n, err := state.StepBack(debug, 2); n != 2 || err != nil {
+			t.Fatalf("StepBack = %d, %v; want 2", n, err)
+		}
+		if err := state.GetInfo(debug, "l"); err != nil || debug.CurrentLine() != 2 {
+			t.Errorf("line after StepBack = %d, %v; want 2", debug.CurrentLine(), err)
+		}
+		if state.GetLocal(debug, 1); state.ToInt(-1) != 1 {
+			t.Errorf("x after StepBack = %v; want 1", state.get(-1))
+		}
+		state.Pop()
+	}, HookLine, 0)
+
+	// local x = 1; x = x + 1; x = x + 1; return x
+	code := []uint32{
+		iABx(vm.LOADK, 0, 0),
+		iABC(vm.ADD, 0, 0, rk(0)),
+		iABC(vm.ADD, 0, 0, rk(0)),
+		iABC(vm.RETURN, 0, 2, 0),
+	}
+	if got := runProto(t, state, code, []interface{}{int64(1)}); len(got) != 1 || got[0] != Int(3) {
+		t.Errorf("results = %v; want [3]", got)
+	}
+	if got := fmt.Sprint(lines); got != "[1 2 3 4 3 4]" {
+		t.Errorf("lines = %s; want [1 2 3 4 3 4]", got)
+	}
+
+	state.SetHook(func(state *State, debug *Debug) {
+		if _, err := state.StepBack(debug, 1); err == nil {
+			t.Error("StepBack in a call hook succeeded")
+		}
+	}, HookCall, 0)
+	runProto(t, state, code, []interface{}{int64(1)})
+}
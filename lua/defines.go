@@ -0,0 +1,175 @@
+package lua
+
+import (
+	"github.com/Azure/golua/lua/binary"
+	"github.com/Azure/golua/lua/vm"
+)
+
+// WithDefines returns an Option that defines compile-time constants for the
+// chunks loaded by the state, for example
+//
+//	lua.WithDefines(map[string]interface{}{"DEBUG": false, "PLATFORM": "linux"})
+//
+// Reads of the global variables named by defines are replaced with the
+// constants when a chunk is loaded, and the tests of those constants, as in
+// `if DEBUG then ... end` and `if PLATFORM == "windows" then ... end`, are
+// folded into unconditional jumps, so that disabled blocks cost a single jump.
+// The instructions of a disabled block stay in the chunk: code generation is
+// done by luac, which cannot remove them.
+//
+// The values may be nil, booleans, integers, floats or strings; other values
+// are ignored. Assignments to a defined global are not affected, but scripts
+// of the state no longer see them.
+func WithDefines(defines map[string]interface{}) Option {
+	return func(cfg *config) {
+		cfg.defines = make(map[string]interface{}, len(defines))
+		for name, value := range defines {
+			switch v := value.(type) {
+			case nil, bool, int64, float64, string:
+				cfg.defines[name] = v
+			case int:
+				cfg.defines[name] = int64(v)
+			case float32:
+				cfg.defines[name] = float64(v)
+			}
+		}
+	}
+}
+
+// define substitutes the defined constants in proto and its nested functions.
+func define(proto *binary.Prototype, defines map[string]interface{}, env int) {
+	var (
+		code    = proto.Code
+		targets = jumpTargets(code)
+	)
+	for pc := 0; pc < len(code); pc++ {
+		instr := vm.Instr(code[pc])
+		if instr.Code() != vm.GETTABUP || instr.B() != env || !isK(instr.C()) {
+			continue
+		}
+		name, ok := proto.Consts[indexK(instr.C())].(string)
+		if !ok {
+			continue
+		}
+		value, ok := defines[name]
+		if !ok {
+			continue
+		}
+		a := instr.A()
+		switch v := value.(type) {
+		case nil:
+			code[pc] = uint32(vm.ABC(vm.LOADNIL, a, 0, 0))
+		case bool:
+			code[pc] = uint32(vm.ABC(vm.LOADBOOL, a, boolArg(v), 0))
+		default:
+			code[pc] = uint32(vm.ABx(vm.LOADK, a, constant(proto, v)))
+		}
+		// Fold a test of the constant followed by its jump.
+		if pc+2 >= len(code) || targets[pc+1] || vm.Instr(code[pc+2]).Code() != vm.JMP {
+			continue
+		}
+		var (
+			test = vm.Instr(code[pc+1])
+			jump bool // whether the JMP is taken
+		)
+		switch test.Code() {
+		case vm.TEST:
+			if test.A() != a {
+				continue
+			}
+			jump = (value != nil && value != false) == (test.C() == 1)
+		case vm.EQ:
+			var other int
+			switch {
+			case test.B() == a && isK(test.C()):
+				other = test.C()
+			case test.C() == a && isK(test.B()):
+				other = test.B()
+			default:
+				continue
+			}
+			jump = constEqual(value, proto.Consts[indexK(other)]) == (test.A() != 0)
+		default:
+			continue
+		}
+		if jump {
+			code[pc+1] = uint32(vm.AsBx(vm.JMP, 0, 0)) // no-op
+		} else {
+			code[pc+1] = uint32(vm.AsBx(vm.JMP, 0, 1)) // skip the jump
+		}
+	}
+	for i := range proto.Protos {
+		child := &proto.Protos[i]
+		if up := upvalueEnv(child, env); up >= 0 {
+			define(child, defines, up)
+		}
+	}
+}
+
+// upvalueEnv returns the index of the upvalue of child that captures the
+// upvalue env of its parent (its _ENV), or -1.
+func upvalueEnv(child *binary.Prototype, env int) int {
+	for i, up := range child.UpValues {
+		if up.InStack == 0 && int(up.Index) == env {
+			return i
+		}
+	}
+	return -1
+}
+
+// jumpTargets returns the instructions that are the target of a jump, other
+// than the next instruction.
+func jumpTargets(code []uint32) map[int]bool {
+	targets := make(map[int]bool)
+	for pc, c := range code {
+		instr := vm.Instr(c)
+		switch instr.Code() {
+		case vm.JMP, vm.FORLOOP, vm.FORPREP, vm.TFORLOOP:
+			targets[pc+1+instr.SBX()] = true
+		case vm.EQ, vm.LT, vm.LE, vm.TEST, vm.TESTSET:
+			targets[pc+2] = true
+		case vm.LOADBOOL:
+			if instr.C() != 0 {
+				targets[pc+2] = true
+			}
+		}
+	}
+	return targets
+}
+
+// constant returns the index of the constant v in proto, adding it if needed.
+func constant(proto *binary.Prototype, v interface{}) int {
+	for i, k := range proto.Consts {
+		if k == v {
+			return i
+		}
+	}
+	proto.Consts = append(proto.Consts, v)
+	return len(proto.Consts) - 1
+}
+
+// constEqual reports whether the constants x and y are equal Lua values.
+func constEqual(x, y interface{}) bool {
+	switch x := x.(type) {
+	case int64:
+		if y, ok := y.(float64); ok {
+			return float64(x) == y
+		}
+	case float64:
+		if y, ok := y.(int64); ok {
+			return x == float64(y)
+		}
+	}
+	return x == y
+}
+
+func isK(rk int) bool { return rk&(1<<8) != 0 }
+
+func indexK(rk int) int { return rk &^ (1 << 8) }
+
+func boolArg(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
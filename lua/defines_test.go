@@ -0,0 +1,49 @@
+package lua
+
+import (
+	"testing"
+
+	"github.com/Azure/golua/lua/vm"
+)
+
+func TestDefines(t *testing.T) {
+	// local r = 0
+	// if DEBUG then r = 1 end
+	// if PLATFORM == "linux" then r = r + 10 end
+	// return r
+	var (
+		code = []uint32{
+			iABx(vm.LOADK, 0, 0),
+			iABC(vm.GETTABUP, 1, 0, rk(1)),
+			iABC(vm.TEST, 1, 0, 0),
+			iAsBx(vm.JMP, 0, 1),
+			iABx(vm.LOADK, 0, 2),
+			iABC(vm.GETTABUP, 1, 0, rk(3)),
+			iABC(vm.EQ, 0, 1, rk(4)),
+			iAsBx(vm.JMP, 0, 1),
+			iABC(vm.ADD, 0, 0, rk(5)),
+			iABC(vm.RETURN, 0, 2, 0),
+		}
+		consts = []interface{}{int64(0), "DEBUG", int64(1), "PLATFORM", "linux", int64(10)}
+	)
+	var tests = []struct {
+		defines map[string]interface{}
+		want    int64
+	}{
+		{nil, 1}, // from the globals below
+		{map[string]interface{}{"DEBUG": false, "PLATFORM": "linux"}, 10},
+		{map[string]interface{}{"DEBUG": true, "PLATFORM": "windows"}, 1},
+		{map[string]interface{}{"DEBUG": nil}, 0},
+	}
+	for _, test := range tests {
+		state := NewState(WithDefines(test.defines))
+		state.Push(true)
+		state.SetGlobal("DEBUG")
+		state.Push("darwin")
+		state.SetGlobal("PLATFORM")
+		got := runProto(t, state, append([]uint32(nil), code...), consts)
+		if len(got) != 1 || got[0] != Int(test.want) {
+			t.Errorf("defines %v: got %v; want %d", test.defines, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package lua
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Deprecate keeps the function replacement available under its former name
+// old, so that scripts using the old name go on working while they migrate:
+// calling old calls replacement, and the first call writes a warning with the
+// location of the calling script to the error output (see SetErrorOutput):
+//
+//	test.lua:12: 'spawn' is deprecated, use 'entity.spawn' instead
+//
+// Both names are globals or paths of fields from a global, such as
+// "entity.spawn"; the table holding old must exist. The replacement is
+// resolved when Deprecate is called, and it returns an error if it is not a
+// function. Each deprecated name is warned about once per state.
+func (state *State) Deprecate(old, replacement string) error {
+	cls, ok := state.lookup(replacement).(*Closure)
+	if !ok {
+		return fmt.Errorf("cannot deprecate '%s' for '%s' (not a function)", old, replacement)
+	}
+	var (
+		parent Value = state.globals()
+		field        = old
+	)
+	if i := strings.LastIndexByte(old, '.'); i >= 0 {
+		parent, field = state.lookup(old[:i]), old[i+1:]
+	}
+	if _, ok := parent.(*table); !ok {
+		return fmt.Errorf("cannot deprecate '%s' ('%s' is not a table)", old, old[:len(old)-len(field)-1])
+	}
+	shim := func(state *State) int {
+		if g := state.global; !g.deprecated[old] {
+			if g.deprecated == nil {
+				g.deprecated = make(map[string]bool)
+			}
+			g.deprecated[old] = true
+			state.Where(1)
+			fmt.Fprintf(state.ErrorOutput(), "%s'%s' is deprecated, use '%s' instead\n", state.ToString(-1), old, replacement)
+			state.Pop()
+		}
+		state.Push(cls)
+		state.Insert(1)
+		state.Call(state.Top()-1, MultRets)
+		return state.Top()
+	}
+	state.settable(parent, String(field), newGoClosure(shim, 0), false)
+	return nil
+}
@@ -0,0 +1,35 @@
+package lua
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestDeprecate(t *testing.T) {
+	state := NewState()
+	defer state.Close()
+	registerSelect(state)
+	var warnings bytes.Buffer
+	state.SetErrorOutput(&warnings)
+
+	if err := state.Deprecate("choose", "select"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		rets := runProto(t, state, wideSelect, []interface{}{"choose"}, int64(2), "a", "b")
+		if got := fmt.Sprint(rets); got != "[a b]" {
+			t.Errorf("choose(2, 2, a, b) = %s; want [a b]", got)
+		}
+	}
+	if got, want := warnings.String(), "test.lua:5: 'choose' is deprecated, use 'select' instead\n"; got != want {
+		t.Errorf("warnings = %q; want %q", got, want)
+	}
+
+	if err := state.Deprecate("choose", "missing.select"); err == nil {
+		t.Error("Deprecate succeeded with a missing replacement")
+	}
+	if err := state.Deprecate("missing.choose", "select"); err == nil {
+		t.Error("Deprecate succeeded with a missing table")
+	}
+}
@@ -0,0 +1,169 @@
+// Package diag provides an opt-in http.Handler reporting the health of Lua
+// states and pools as JSON, in the spirit of expvar:
+//
+//	h := diag.NewHandler()
+//	h.AddPool("scripts", pool)
+//	adminMux.Handle("/debug/lua", h)
+package diag
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Azure/golua/lua"
+)
+
+// DefaultMaxErrors is the default number of recent errors and of recent slow
+// calls kept by a Handler.
+const DefaultMaxErrors = 50
+
+// Error is a recorded script error.
+type Error struct {
+	Time    time.Time `json:"time"`
+	Source  string    `json:"source"`
+	Message string    `json:"message"`
+}
+
+// SlowCall is a recorded slow call.
+type SlowCall struct {
+	Source string `json:"source"`
+	lua.SlowCall
+}
+
+// Report is the JSON document served by a Handler.
+type Report struct {
+	States map[string]lua.Stats     `json:"states"`
+	Pools  map[string]lua.PoolStats `json:"pools"`
+	Errors []Error                  `json:"errors"`
+	Slow   []SlowCall               `json:"slow"`
+}
+
+// Handler serves a Report of the registered states and pools and of the most
+// recent errors. It is safe for concurrent use.
+type Handler struct {
+	// MaxErrors is the number of recent errors, and of recent slow calls,
+	// kept; it defaults to DefaultMaxErrors when zero.
+	MaxErrors int
+
+	mu     sync.Mutex
+	states map[string]stateEntry
+	pools  map[string]*lua.Pool
+	errors []Error
+	slow   []SlowCall
+}
+
+type stateEntry struct {
+	state *lua.State
+	lock  sync.Locker
+}
+
+// NewHandler returns an empty diagnostics handler.
+func NewHandler() *Handler {
+	return &Handler{
+		states: make(map[string]stateEntry),
+		pools:  make(map[string]*lua.Pool),
+	}
+}
+
+// AddState registers state under name. Because a state may not be inspected
+// while it runs, the handler holds lock while taking its Stats; lock must be
+// the lock guarding the state's use, or nil if the state is idle whenever the
+// handler may be served.
+func (h *Handler) AddState(name string, state *lua.State, lock sync.Locker) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.states[name] = stateEntry{state, lock}
+}
+
+// AddPool registers pool under name, reporting its occupancy.
+func (h *Handler) AddPool(name string, pool *lua.Pool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.pools[name] = pool
+}
+
+// Remove unregisters the state or pool registered under name.
+func (h *Handler) Remove(name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.states, name)
+	delete(h.pools, name)
+}
+
+// RecordError records err, raised by source, among the recent errors.
+func (h *Handler) RecordError(source string, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if max := h.max(); len(h.errors) >= max {
+		h.errors = append(h.errors[:0], h.errors[len(h.errors)-max+1:]...)
+	}
+	h.errors = append(h.errors, Error{
+		Time:    time.Now(),
+		Source:  source,
+		Message: err.Error(),
+	})
+}
+
+// RecordSlowCall records call, made on a state of source, among the recent
+// slow calls, typically from the report function given to lua.State.SetSlowLog:
+//
+//	state.SetSlowLog(time.Second, func(call lua.SlowCall) {
+//	    h.RecordSlowCall("scripts", call)
+//	})
+func (h *Handler) RecordSlowCall(source string, call lua.SlowCall) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if max := h.max(); len(h.slow) >= max {
+		h.slow = append(h.slow[:0], h.slow[len(h.slow)-max+1:]...)
+	}
+	h.slow = append(h.slow, SlowCall{source, call})
+}
+
+func (h *Handler) max() int {
+	if h.MaxErrors <= 0 {
+		return DefaultMaxErrors
+	}
+	return h.MaxErrors
+}
+
+// Report returns the current report.
+func (h *Handler) Report() *Report {
+	h.mu.Lock()
+	entries := make(map[string]stateEntry, len(h.states))
+	for name, entry := range h.states {
+		entries[name] = entry
+	}
+	report := &Report{
+		States: make(map[string]lua.Stats, len(h.states)),
+		Pools:  make(map[string]lua.PoolStats, len(h.pools)),
+		Errors: append([]Error{}, h.errors...),
+		Slow:   append([]SlowCall{}, h.slow...),
+	}
+	for name, pool := range h.pools {
+		report.Pools[name] = pool.Stats()
+	}
+	h.mu.Unlock()
+
+	// Take state stats without holding h.mu, since waiting for a busy
+	// state must not block RecordError.
+	for name, entry := range entries {
+		if entry.lock != nil {
+			entry.lock.Lock()
+		}
+		report.States[name] = entry.state.Stats()
+		if entry.lock != nil {
+			entry.lock.Unlock()
+		}
+	}
+	return report
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(h.Report())
+}
@@ -0,0 +1,84 @@
+package diag
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/golua/lua"
+)
+
+func TestHandler(t *testing.T) {
+	state := lua.NewState()
+	defer state.Close()
+	state.Push(1)
+	state.Push(2)
+
+	pool := lua.NewPool(4, func() (*lua.State, error) { return lua.NewState(), nil })
+	busy, err := pool.Get(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Put(busy)
+
+	h := NewHandler()
+	h.MaxErrors = 1
+	h.AddState("main", state, new(sync.Mutex))
+	h.AddPool("scripts", pool)
+	h.RecordError("main", errors.New("old error"))
+	h.RecordError("scripts", errors.New("attempt to call a nil value"))
+	h.RecordSlowCall("scripts", lua.SlowCall{
+		Duration:  2 * time.Second,
+		Threshold: time.Second,
+		Traceback: "stack traceback:",
+	})
+
+	server := httptest.NewServer(h)
+	defer server.Close()
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q; want application/json", ct)
+	}
+	var report struct {
+		States map[string]struct {
+			StackSize int `json:"stack_size"`
+		} `json:"states"`
+		Pools map[string]struct {
+			Size  int `json:"size"`
+			InUse int `json:"in_use"`
+		} `json:"pools"`
+		Errors []struct {
+			Source  string `json:"source"`
+			Message string `json:"message"`
+		} `json:"errors"`
+		Slow []struct {
+			Source    string        `json:"source"`
+			Duration  time.Duration `json:"duration"`
+			Traceback string        `json:"traceback"`
+		} `json:"slow"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	if s, ok := report.States["main"]; !ok || s.StackSize != 2 {
+		t.Errorf("states = %+v; want main with 2 values on its stack", report.States)
+	}
+	if p, ok := report.Pools["scripts"]; !ok || p.Size != 4 || p.InUse != 1 {
+		t.Errorf("pools = %+v; want scripts of size 4 with 1 state in use", report.Pools)
+	}
+	if len(report.Errors) != 1 || report.Errors[0].Source != "scripts" || report.Errors[0].Message != "attempt to call a nil value" {
+		t.Errorf("errors = %+v; want the last error only", report.Errors)
+	}
+	if len(report.Slow) != 1 || report.Slow[0].Source != "scripts" || report.Slow[0].Duration != 2*time.Second || report.Slow[0].Traceback != "stack traceback:" {
+		t.Errorf("slow = %+v", report.Slow)
+	}
+}
@@ -0,0 +1,252 @@
+package lua
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// DiffKind is the kind of a Difference.
+type DiffKind int
+
+const (
+	// DiffAdded is a value present in b but not in a.
+	DiffAdded DiffKind = iota
+	// DiffRemoved is a value present in a but not in b.
+	DiffRemoved
+	// DiffChanged is a value present in both but not equal.
+	DiffChanged
+)
+
+func (kind DiffKind) String() string {
+	switch kind {
+	case DiffAdded:
+		return "added"
+	case DiffRemoved:
+		return "removed"
+	}
+	return "changed"
+}
+
+// DiffOptions configures Diff.
+type DiffOptions struct {
+	// Epsilon is the largest difference between two numbers still considered
+	// equal. By default numbers must be equal.
+	Epsilon float64
+	// Max stops Diff after this many differences; 0 means no limit.
+	Max int
+}
+
+// Difference is a difference between two values found by Diff.
+type Difference struct {
+	// Path is the sequence of keys leading from the compared values to the
+	// differing values; it is empty if the compared values differ.
+	Path []Value
+	Kind DiffKind
+	// Old is the value in a, or nil if added.
+	Old Value
+	// New is the value in b, or nil if removed.
+	New Value
+}
+
+// String returns the difference in a readable form, such as
+//
+//	changed items[2].count: 1 -> 3
+func (d Difference) String() string {
+	path := FormatPath(d.Path)
+	switch d.Kind {
+	case DiffAdded:
+		return fmt.Sprintf("added %s: %s", path, diffValue(d.New))
+	case DiffRemoved:
+		return fmt.Sprintf("removed %s: %s", path, diffValue(d.Old))
+	}
+	return fmt.Sprintf("changed %s: %s -> %s", path, diffValue(d.Old), diffValue(d.New))
+}
+
+// FormatPath formats a path of keys in Lua syntax, such as items[2].count, or
+// as (root) if it is empty.
+func FormatPath(path []Value) string {
+	if len(path) == 0 {
+		return "(root)"
+	}
+	var b strings.Builder
+	for i, key := range path {
+		if s, ok := key.(String); ok && isName(string(s)) {
+			if i > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString(string(s))
+		} else {
+			fmt.Fprintf(&b, "[%s]", diffValue(key))
+		}
+	}
+	return b.String()
+}
+
+// Diff compares the values a and b, recursing into tables, and returns their
+// differences ordered by path. Values other than tables are compared with raw
+// equality, numbers by their mathematical values except that NaN equals NaN;
+// metatables are ignored.
+func Diff(a, b Value, opts DiffOptions) []Difference {
+	d := differ{opts: opts, seen: make(map[[2]*table]bool)}
+	d.diff(nil, a, b)
+	return d.diffs
+}
+
+type differ struct {
+	opts  DiffOptions
+	seen  map[[2]*table]bool
+	diffs []Difference
+}
+
+// full reports whether the maximum number of differences was reached.
+func (d *differ) full() bool {
+	return d.opts.Max > 0 && len(d.diffs) >= d.opts.Max
+}
+
+func (d *differ) add(path []Value, kind DiffKind, old, new Value) {
+	if !d.full() {
+		path = append([]Value(nil), path...)
+		d.diffs = append(d.diffs, Difference{Path: path, Kind: kind, Old: old, New: new})
+	}
+}
+
+func (d *differ) diff(path []Value, a, b Value) {
+	ta, okA := a.(*table)
+	tb, okB := b.(*table)
+	if !okA || !okB {
+		if !d.equal(a, b) {
+			d.add(path, DiffChanged, a, b)
+		}
+		return
+	}
+	if ta == tb || d.seen[[2]*table{ta, tb}] {
+		return
+	}
+	d.seen[[2]*table{ta, tb}] = true
+	var keys []Value
+	ta.ForEach(func(k, _ Value) { keys = append(keys, k) })
+	tb.ForEach(func(k, _ Value) {
+		if IsNone(ta.get(k)) {
+			keys = append(keys, k)
+		}
+	})
+	sortKeys(keys)
+	for _, k := range keys {
+		if d.full() {
+			return
+		}
+		va, vb := ta.get(k), tb.get(k)
+		switch {
+		case IsNone(va):
+			d.add(append(path, k), DiffAdded, nil, vb)
+		case IsNone(vb):
+			d.add(append(path, k), DiffRemoved, va, nil)
+		default:
+			d.diff(append(path, k), va, vb)
+		}
+	}
+}
+
+// equal reports whether the values a and b, which are not both tables, are
+// equal.
+func (d *differ) equal(a, b Value) bool {
+	if IsNone(a) || IsNone(b) {
+		return IsNone(a) && IsNone(b)
+	}
+	if x, ok := a.(Number); ok {
+		if y, ok := b.(Number); ok {
+			if ix, ok := x.(Int); ok {
+				if iy, ok := y.(Int); ok {
+					return ix == iy
+				}
+			}
+			fx, fy := toFloat64(x), toFloat64(y)
+			if math.IsNaN(fx) || math.IsNaN(fy) {
+				return math.IsNaN(fx) && math.IsNaN(fy)
+			}
+			return fx == fy || math.Abs(fx-fy) <= d.opts.Epsilon
+		}
+		return false
+	}
+	return a == b
+}
+
+func toFloat64(n Number) float64 {
+	if i, ok := n.(Int); ok {
+		return float64(i)
+	}
+	return float64(n.(Float))
+}
+
+// sortKeys sorts keys by type (booleans, numbers, strings, others) and then by
+// value, others by their string form.
+func sortKeys(keys []Value) {
+	rank := func(v Value) int {
+		switch v.(type) {
+		case Bool:
+			return 0
+		case Int, Float:
+			return 1
+		case String:
+			return 2
+		}
+		return 3
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if ra, rb := rank(a), rank(b); ra != rb {
+			return ra < rb
+		}
+		switch a := a.(type) {
+		case Bool:
+			return !bool(a) && bool(b.(Bool))
+		case Int, Float:
+			return toFloat64(a.(Number)) < toFloat64(b.(Number))
+		case String:
+			return a < b.(String)
+		}
+		return a.String() < b.String()
+	})
+}
+
+// diffValue formats a value of a difference.
+func diffValue(v Value) string {
+	switch v := v.(type) {
+	case nil:
+		return "nil"
+	case String:
+		return fmt.Sprintf("%q", string(v))
+	case Float:
+		return formatFloat(float64(v))
+	case *table:
+		return "table"
+	}
+	if IsNone(v) {
+		return "nil"
+	}
+	return v.String()
+}
+
+// isName reports whether s is a Lua name other than a reserved word.
+func isName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, c := range s {
+		switch {
+		case c == '_', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	switch s {
+	case "and", "break", "do", "else", "elseif", "end", "false", "for",
+		"function", "goto", "if", "in", "local", "nil", "not", "or",
+		"repeat", "return", "then", "true", "until", "while":
+		return false
+	}
+	return true
+}
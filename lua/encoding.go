@@ -0,0 +1,130 @@
+package lua
+
+import (
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"math"
+)
+
+// The scalar value types implement encoding.BinaryMarshaler and
+// encoding.BinaryUnmarshaler, and are registered with encoding/gob, so that
+// Go structs holding them, including in fields of type Value, can be stored
+// with gob or other generic serializers. Tables, functions, userdata and
+// threads belong to a state and cannot be serialized this way.
+//
+// The encoding is a byte identifying the type followed by the value: 8 bytes
+// big-endian for integers and floats, 1 byte for booleans and nil (which
+// tells nil from none), and the raw bytes of strings.
+
+func init() {
+	gob.RegisterName("golua.Nil", Nil(0))
+	gob.RegisterName("golua.Bool", Bool(false))
+	gob.RegisterName("golua.Int", Int(0))
+	gob.RegisterName("golua.Float", Float(0))
+	gob.RegisterName("golua.String", String(""))
+}
+
+// binary type tags
+const (
+	tagNil byte = iota
+	tagBool
+	tagInt
+	tagFloat
+	tagString
+)
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (x Nil) MarshalBinary() ([]byte, error) { return []byte{tagNil, byte(x)}, nil }
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (x *Nil) UnmarshalBinary(data []byte) error {
+	b, err := untag(data, tagNil, 1)
+	if err != nil {
+		return err
+	}
+	*x = Nil(b[0])
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (x Bool) MarshalBinary() ([]byte, error) {
+	if x {
+		return []byte{tagBool, 1}, nil
+	}
+	return []byte{tagBool, 0}, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (x *Bool) UnmarshalBinary(data []byte) error {
+	b, err := untag(data, tagBool, 1)
+	if err != nil {
+		return err
+	}
+	*x = Bool(b[0] != 0)
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (x Int) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 9)
+	b[0] = tagInt
+	binary.BigEndian.PutUint64(b[1:], uint64(x))
+	return b, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (x *Int) UnmarshalBinary(data []byte) error {
+	b, err := untag(data, tagInt, 8)
+	if err != nil {
+		return err
+	}
+	*x = Int(binary.BigEndian.Uint64(b))
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (x Float) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 9)
+	b[0] = tagFloat
+	binary.BigEndian.PutUint64(b[1:], math.Float64bits(float64(x)))
+	return b, nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (x *Float) UnmarshalBinary(data []byte) error {
+	b, err := untag(data, tagFloat, 8)
+	if err != nil {
+		return err
+	}
+	*x = Float(math.Float64frombits(binary.BigEndian.Uint64(b)))
+	return nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (x String) MarshalBinary() ([]byte, error) {
+	return append([]byte{tagString}, x...), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (x *String) UnmarshalBinary(data []byte) error {
+	b, err := untag(data, tagString, -1)
+	if err != nil {
+		return err
+	}
+	*x = String(b)
+	return nil
+}
+
+// untag checks the type tag of data and returns the payload, which must have
+// size bytes unless size is negative.
+func untag(data []byte, tag byte, size int) ([]byte, error) {
+	names := [...]string{tagNil: "nil", tagBool: "boolean", tagInt: "integer", tagFloat: "float", tagString: "string"}
+	if len(data) == 0 || data[0] != tag {
+		return nil, fmt.Errorf("lua: binary data is not an encoded %s", names[tag])
+	}
+	if data = data[1:]; size >= 0 && len(data) != size {
+		return nil, fmt.Errorf("lua: invalid length of encoded %s", names[tag])
+	}
+	return data, nil
+}
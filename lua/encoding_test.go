@@ -0,0 +1,88 @@
+package lua
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/gob"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestBinaryMarshaling(t *testing.T) {
+	values := []Value{
+		None, Nil(1), Bool(true), Bool(false),
+		Int(0), Int(-1), Int(math.MinInt64), Int(math.MaxInt64),
+		Float(0.5), Float(math.Inf(-1)), Float(math.NaN()),
+		String(""), String("a\x00b\xff"),
+	}
+	for _, v := range values {
+		data, err := v.(encoding.BinaryMarshaler).MarshalBinary()
+		if err != nil {
+			t.Fatalf("%#v.MarshalBinary: %v", v, err)
+		}
+		x := reflect.New(reflect.TypeOf(v))
+		if err := x.Interface().(encoding.BinaryUnmarshaler).UnmarshalBinary(data); err != nil {
+			t.Fatalf("UnmarshalBinary(%q): %v", data, err)
+		}
+		got := x.Elem().Interface().(Value)
+		if f, ok := v.(Float); ok && math.IsNaN(float64(f)) {
+			if !math.IsNaN(float64(got.(Float))) {
+				t.Errorf("NaN round-trips to %v", got)
+			}
+		} else if got != v {
+			t.Errorf("%#v round-trips to %#v", v, got)
+		}
+	}
+
+	var tests = []struct {
+		into encoding.BinaryUnmarshaler
+		data string
+		want string
+	}{
+		{new(Int), "", "lua: binary data is not an encoded integer"},
+		{new(Int), "\x03\x00\x00\x00\x00\x00\x00\x00\x00", "lua: binary data is not an encoded integer"},
+		{new(Int), "\x02\x00", "lua: invalid length of encoded integer"},
+		{new(Float), "\x03\x00\x00\x00\x00\x00\x00\x00\x00\x00", "lua: invalid length of encoded float"},
+		{new(Bool), "\x01", "lua: invalid length of encoded boolean"},
+		{new(Nil), "\x01\x00", "lua: binary data is not an encoded nil"},
+		{new(String), "abc", "lua: binary data is not an encoded string"},
+	}
+	for _, tt := range tests {
+		if err := tt.into.UnmarshalBinary([]byte(tt.data)); err == nil || err.Error() != tt.want {
+			t.Errorf("%T.UnmarshalBinary(%q): error = %v; want %q", tt.into, tt.data, err, tt.want)
+		}
+	}
+}
+
+func TestGobValues(t *testing.T) {
+	type saved struct {
+		Name  String
+		Value Value
+		List  []Value
+	}
+	in := saved{
+		Name:  "player",
+		Value: Int(42),
+		List:  []Value{Nil(1), Bool(true), Float(1.5), String("x"), Int(-7)},
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(in); err != nil {
+		t.Fatal(err)
+	}
+	var out saved
+	if err := gob.NewDecoder(&buf).Decode(&out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("gob round trip = %#v; want %#v", out, in)
+	}
+
+	// Values bound to a state cannot be encoded.
+	state := NewState()
+	defer state.Close()
+	state.NewTable()
+	if err := gob.NewEncoder(&buf).Encode(saved{Value: state.Pop()}); err == nil {
+		t.Errorf("gob encoded a table")
+	}
+}
@@ -1,24 +1,59 @@
 package lua
 
 import (
+	"errors"
 	"fmt"
 )
 
+// ValueError is the error of a Lua error whose error object is not a string,
+// such as error({code = 404}); PCall returns it so that the object is kept.
+type ValueError struct {
+	Value Value // the error object
+}
+
+func (err *ValueError) Error() string { return fmt.Sprint(err.Value) }
+
+// valueErr returns the error raised with the error object v.
+func valueErr(v Value) error {
+	if s, ok := v.(String); ok {
+		return runtimeErr(errors.New(string(s)))
+	}
+	return &ValueError{v}
+}
+
+// PushError pushes the error object of err: the value given to error for the
+// errors of Lua code, such as those returned by PCall or Resume, or the
+// message of err.
+func (state *State) PushError(err error) { state.frame().push(errorObject(err)) }
+
+// errorObject returns the error object of err.
+func errorObject(err error) Value {
+	var verr *ValueError
+	if errors.As(err, &verr) {
+		return verr.Value
+	}
+	return String(err.Error())
+}
+
 func argError(state *State, argAt int, msg string) {
-	// TODO: stack analysis and debugging info if available.
-	panic(fmt.Errorf("bad argument #%d (%s)", argAt, msg))
+	name, method := state.argFuncName()
+	if method {
+		if argAt--; argAt == 0 {
+			panic(state.messageErr(MsgBadSelf, name, msg))
+		}
+	}
+	panic(state.messageErr(MsgBadArgument, argAt, name, msg))
 }
 
 func intError(state *State, argAt int) {
 	if isNumber(state.get(argAt)) {
-		argError(state, argAt, "number has not ineger representation")
+		argError(state, argAt, state.Message(MsgNoIntRep))
 	}
 	typeError(state, argAt, "number")
 }
 
 func typeError(state *State, argAt int, want string) {
-	// TODO: stack analysis and debugging info if available.
-	panic(fmt.Errorf("%s expected @ %d, got %s", want, argAt, state.valueAt(argAt).Type()))
+	argError(state, argAt, fmt.Sprintf(state.Message(MsgTypeExpected), want, state.TypeName(argAt)))
 }
 
 // luaG_typerror 		"attempt to %s a %s value%s"
@@ -1,7 +1,7 @@
 package lua
 
 import (
-	"fmt"
+	"reflect"
 )
 
 type (
@@ -116,6 +116,10 @@ type (
 	// arguments, and the result of the call (adjusted to one value) is the result of the operation.
 	//
 	// Otherwise, it raises an error.
+	//
+	// The method of a Go value receives the other operand. When the Go value is the second operand, as
+	// in 3 + v, the methods of the commutative operations (+, *, &, | and binary ~) receive the first
+	// operand; the other operations raise an error unless the Go value implements HasArith.
 	HasAdd interface {
 		//Value
 
@@ -202,6 +206,29 @@ type (
 	}
 
 	// __idiv: the floor division (//) operation. Behavior similar to the addition operation.
+
+	// HasMethods is implemented by Go values with methods callable from Lua with the
+	// method syntax obj:name(...). Each method receives the object as its first argument.
+	//
+	// The methods are resolved once per Go type into a table shared by all values of the
+	// type, so a method call does not allocate a bound closure. Methods take precedence
+	// over keys resolved with Index when the value implements HasIndex too.
+	HasMethods interface {
+		//Value
+
+		Methods() map[string]Func
+	}
+
+	// HasArith is implemented by Go values that take part in binary arithmetic, bitwise and
+	// concatenation operations with both operands in order, such as a vector for which 3 - v and
+	// v - 3 differ. It is called whether the Go value is the first or the second operand, and takes
+	// precedence over the interfaces of single operations such as HasAdd; it is also the only way
+	// to implement floor division (//).
+	HasArith interface {
+		//Value
+
+		Arith(op Op, lhs, rhs Value) (Value, error)
+	}
 )
 
 type metaEvent int
@@ -230,6 +257,7 @@ const (
 	metaNewIndex
 	metaCall
 	metaMode
+	metaGc
 )
 
 var event2name = [...]string{
@@ -256,9 +284,39 @@ var event2name = [...]string{
 	metaNewIndex: "newindex",
 	metaCall:     "call",
 	metaMode:     "mode",
+	metaGc:       "gc",
+}
+
+// event2key caches the metatable key of each event so that looking up a
+// metamethod neither builds nor boxes the name on every access.
+var event2key [len(event2name)]Value
+
+func init() {
+	for evt, name := range event2name {
+		event2key[evt] = String("__" + name)
+	}
 }
 
-func (evt metaEvent) ID() string { return "__" + event2name[evt] }
+func (evt metaEvent) ID() string { return string(event2key[evt].(String)) }
+
+// methodsOf returns the table shared by all Go values of the same type as v
+// holding the Lua closures of v's methods.
+func methodsOf(state *State, v HasMethods) *table {
+	g, typ := state.global, reflect.TypeOf(v)
+	if methods, ok := g.methods[typ]; ok {
+		return methods
+	}
+	funcs := v.Methods()
+	methods := newTable(state, 0, len(funcs))
+	for name, fn := range funcs {
+		methods.setStr(name, newGoClosure(fn, 0))
+	}
+	if g.methods == nil {
+		g.methods = make(map[reflect.Type]*table)
+	}
+	g.methods[typ] = methods
+	return methods
+}
 
 func metaOf(state *State, v Value) *table {
 	events := newTable(state, 0, 0)
@@ -268,11 +326,18 @@ func metaOf(state *State, v Value) *table {
 		if u = v.Value(); u == nil {
 			break
 		}
+		// Protect the metamethods bound to the Go value from scripts:
+		// getmetatable returns false instead of the metatable.
+		events.setStr("__metatable", False)
+		if x, ok := u.(*Bound); ok {
+			x.metatable(state, events)
+			break
+		}
 		if o, ok := u.(HasNewIndex); ok { // __newindex
 			method := Func(func(state *State) int {
 				var (
-					key = state.frame().pop()
 					val = state.frame().pop()
+					key = state.frame().pop()
 				)
 				if err := o.SetIndex(key, val); err != nil {
 					state.errorf("%v", err)
@@ -281,9 +346,21 @@ func metaOf(state *State, v Value) *table {
 			})
 			events.setStr(metaNewIndex.ID(), newGoClosure(method, 0))
 		}
+		var methods *table
+		if o, ok := u.(HasMethods); ok {
+			methods = methodsOf(state, o)
+			events.setStr(metaIndex.ID(), methods)
+		}
 		if o, ok := u.(HasIndex); ok { // __index
 			method := Func(func(state *State) int {
-				v, err := o.Index(state.frame().pop())
+				key := state.frame().pop()
+				if methods != nil {
+					if fn := methods.get(key); !IsNone(fn) {
+						state.Push(fn)
+						return 1
+					}
+				}
+				v, err := o.Index(key)
 				if err != nil {
 					state.errorf("%v", err)
 				}
@@ -309,31 +386,121 @@ func metaOf(state *State, v Value) *table {
 			})
 			events.setStr(metaCall.ID(), newGoClosure(method, 0))
 		}
-		if o, ok := u.(HasConcat); ok { // __concat
-			method := Func(func(state *State) int {
-				v, err := o.Concat(state.frame().pop())
-				if err != nil {
-					state.errorf("%v", err)
-				}
-				state.Push(v)
-				return 1
-			})
-			events.setStr(metaConcat.ID(), newGoClosure(method, 0))
+		for _, b := range binaryEvents { // __add, __concat, ...
+			if method := binaryMethod(v, b.event, b.op); method != nil {
+				events.setStr(b.event.ID(), newGoClosure(method, 0))
+			}
 		}
+	}
+	return events
+}
 
-		if o, ok := u.(HasAdd); ok { // __add
-			method := Func(func(state *State) int {
-				v, err := o.Add(state.frame().pop())
-				if err != nil {
-					state.errorf("%v", err)
+// binaryEvents are the binary operations that Go values implement with
+// HasArith or the interfaces of single operations.
+var binaryEvents = []struct {
+	event metaEvent
+	op    Op
+}{
+	{metaAdd, OpAdd}, {metaSub, OpSub}, {metaMul, OpMul}, {metaDiv, OpDiv},
+	{metaMod, OpMod}, {metaPow, OpPow}, {metaIdiv, OpQuo}, {metaBand, OpAnd},
+	{metaBor, OpOr}, {metaBxor, OpXor}, {metaShl, OpLsh}, {metaShr, OpRsh},
+	{metaConcat, OpConcat},
+}
+
+// binaryMethod returns the metamethod of the event of the Go value of obj, or
+// nil if the value does not implement it.
+func binaryMethod(obj *Object, event metaEvent, op Op) Func {
+	u := obj.Value()
+	if o, ok := u.(HasArith); ok {
+		return func(state *State) int {
+			v, err := o.Arith(op, state.get(1), state.get(2))
+			if err != nil {
+				state.errorf("%v", err)
+			}
+			state.Push(v)
+			return 1
+		}
+	}
+	apply := singleMethod(u, event)
+	if apply == nil {
+		return nil
+	}
+	commutes := op == OpAdd || op == OpMul || op == OpAnd || op == OpOr || op == OpXor
+	return func(state *State) int {
+		lhs, rhs := state.get(1), state.get(2)
+		other := rhs
+		if lhs != Value(obj) { // obj is the second operand
+			if !commutes {
+				if op == OpConcat {
+					state.Raise(MsgConcat, state.typeName(lhs), state.typeName(rhs))
 				}
-				state.Push(v)
-				return 1
-			})
-			events.setStr(metaAdd.ID(), newGoClosure(method, 0))
+				state.Raise(MsgArith, event.ID(), state.typeName(lhs), state.typeName(rhs))
+			}
+			other = lhs
 		}
+		v, err := apply(other)
+		if err != nil {
+			state.errorf("%v", err)
+		}
+		state.Push(v)
+		return 1
 	}
-	return events
+}
+
+// singleMethod returns the method of u of the interface of the single
+// operation of event, such as HasSub for __sub, or nil.
+func singleMethod(u interface{}, event metaEvent) func(Value) (Value, error) {
+	switch event {
+	case metaAdd:
+		if o, ok := u.(HasAdd); ok {
+			return o.Add
+		}
+	case metaSub:
+		if o, ok := u.(HasSub); ok {
+			return o.Sub
+		}
+	case metaMul:
+		if o, ok := u.(HasMul); ok {
+			return o.Mul
+		}
+	case metaDiv:
+		if o, ok := u.(HasDiv); ok {
+			return o.Div
+		}
+	case metaMod:
+		if o, ok := u.(HasMod); ok {
+			return o.Mod
+		}
+	case metaPow:
+		if o, ok := u.(HasPow); ok {
+			return o.Pow
+		}
+	case metaBand:
+		if o, ok := u.(HasAnd); ok {
+			return o.And
+		}
+	case metaBor:
+		if o, ok := u.(HasOr); ok {
+			return o.Or
+		}
+	case metaBxor:
+		if o, ok := u.(HasXor); ok {
+			return o.Xor
+		}
+	case metaShl:
+		if o, ok := u.(HasShl); ok {
+			return o.Lsh
+		}
+	case metaShr:
+		if o, ok := u.(HasShr); ok {
+			return o.Rsh
+		}
+	case metaConcat:
+		if o, ok := u.(HasConcat); ok {
+			return o.Concat
+		}
+	}
+	return nil
 }
 
 // tryMetaNewIndex performs the indexing assignment table[key] = value. Like the
@@ -350,29 +517,28 @@ func metaOf(state *State, v Value) *table {
 func tryMetaNewIndex(state *State, object, key, value Value) error {
 	const event = metaNewIndex
 
-	for loop, meta, object := 0, Value(None), object; loop < metaLoopMax; loop++ {
-		if meta = state.metafield(object, event.ID()); !IsNone(meta) {
-			switch meta := meta.(type) {
-			case *Closure:
-				state.frame().push(meta)
-				state.frame().push(object)
-				state.frame().push(key)
-				state.frame().push(value)
-				state.Call(3, 0)
+	for loop := 0; loop < MaxMetaChain; loop++ {
+		var meta Value
+		if table, ok := object.(*table); ok {
+			// assign to existing keys, and to tables without a metamethod.
+			if meta = state.metamethod(table, event); table.exists(key) || IsNone(meta) {
+				table.set(key, value)
 				return nil
-			case *table:
-				object = meta
-				continue
-			default:
-				return fmt.Errorf("meta method '__newindex' not a table or function")
 			}
+		} else if meta = state.metamethod(object, event); IsNone(meta) {
+			return state.messageErr(MsgIndex, state.typeName(object))
 		}
-		if table, ok := object.(*table); ok {
-			table.set(key, value)
+		if cls, ok := meta.(*Closure); ok {
+			state.frame().push(cls)
+			state.frame().push(object)
+			state.frame().push(key)
+			state.frame().push(value)
+			state.Call(3, 0)
 			return nil
 		}
+		object = meta // repeat the assignment over the metamethod
 	}
-	return fmt.Errorf("'__newindex' chain too long; possible loop")
+	return state.messageErr(MsgNewIndexLoop)
 }
 
 // tryMetaIndex performs the indexing access operation table[key]. This event
@@ -387,27 +553,28 @@ func tryMetaNewIndex(state *State, object, key, value Value) error {
 func tryMetaIndex(state *State, object, key Value) (Value, error) {
 	const event = metaIndex
 
-	for loop := 0; loop < metaLoopMax; loop++ {
+	for loop := 0; loop < MaxMetaChain; loop++ {
+		var meta Value
 		if table, ok := object.(*table); ok {
-			if table.exists(key) {
-				return table.get(key), nil
+			if value := table.get(key); !IsNone(value) {
+				return value, nil
+			}
+			if meta = state.metamethod(table, event); IsNone(meta) {
+				return None, nil
 			}
+		} else if meta = state.metamethod(object, event); IsNone(meta) {
+			return None, state.indexErr(object)
 		}
-		switch meta := state.metafield(object, event.ID()).(type) {
-		case *Closure:
-			state.frame().push(meta)
+		if cls, ok := meta.(*Closure); ok {
+			state.frame().push(cls)
 			state.frame().push(object)
 			state.frame().push(key)
 			state.Call(2, 1)
 			return state.frame().pop(), nil
-		case *table:
-			object = meta
-			continue
-		default:
-			return None, nil
 		}
+		object = meta // repeat the access over the metamethod
 	}
-	return None, fmt.Errorf("'__index' chain too long; possible loop")
+	return None, state.messageErr(MsgIndexLoop)
 }
 
 // tryMetaBinary performs one of the following binary operations:
@@ -418,25 +585,29 @@ func tryMetaIndex(state *State, object, key Value) (Value, error) {
 // operands as arguments, and the result of the call (adjusted to one value) is
 // the result of the operation. Otherwise, it raises an error.
 func tryMetaBinary(state *State, lhs, rhs Value, event metaEvent) (Value, error) {
-	if meta := state.metafield(lhs, event.ID()); !IsNone(meta) { // try lhs operand
-		if cls, ok := meta.(*Closure); ok {
-			state.frame().push(cls)
-			state.frame().push(lhs)
-			state.frame().push(rhs)
-			state.Call(2, 1)
-			return state.frame().pop(), nil
-		}
+	if v, ok := callMetaBinary(state, lhs, rhs, event); ok {
+		return v, nil
 	}
-	if meta := state.metafield(rhs, event.ID()); !IsNone(meta) { // try rhs operand
-		if cls, ok := meta.(*Closure); ok {
-			state.frame().push(cls)
-			state.frame().push(lhs)
-			state.frame().push(rhs)
-			state.Call(2, 1)
-			return state.frame().pop(), nil
-		}
-	}
-	return None, fmt.Errorf("attempt to apply %s on %v %v value", event.ID(), lhs.Type(), rhs.Type())
+	return None, state.messageErr(MsgArith, event.ID(), state.typeName(lhs), state.typeName(rhs))
+}
+
+// callMetaBinary calls the metamethod of the event of lhs, or else of rhs, with
+// lhs and rhs in this order whichever operand defines it, and returns its
+// result; it reports false if neither operand defines the metamethod. As in
+// Lua, the metamethod may be any callable value.
+func callMetaBinary(state *State, lhs, rhs Value, event metaEvent) (Value, bool) {
+	meta := state.metamethod(lhs, event) // try lhs operand
+	if IsNone(meta) {
+		meta = state.metamethod(rhs, event) // try rhs operand
+	}
+	if IsNone(meta) {
+		return nil, false
+	}
+	state.frame().push(meta)
+	state.frame().push(lhs)
+	state.frame().push(rhs)
+	state.Call(2, 1)
+	return state.frame().pop(), true
 }
 
 // tryMetaCompare performs one of the follow Lua comparison metamethods: __lt, __le, __eq
@@ -458,7 +629,7 @@ func tryMetaBinary(state *State, lhs, rhs Value, event metaEvent) (Value, error)
 //
 // See https://www.lua.org/manual/5.3/manual.html#2.4
 func tryMetaCompare(state *State, lhs, rhs Value, event metaEvent) (cmp bool, err error) {
-	if meta := state.metafield(lhs, event.ID()); !IsNone(meta) { // try lhs operand
+	if meta := state.metamethod(lhs, event); !IsNone(meta) { // try lhs operand
 		if cls, ok := meta.(*Closure); ok {
 			state.frame().push(cls)
 			state.frame().push(lhs)
@@ -469,7 +640,7 @@ func tryMetaCompare(state *State, lhs, rhs Value, event metaEvent) (cmp bool, er
 			return cmp, nil
 		}
 	}
-	if meta := state.metafield(rhs, event.ID()); !IsNone(meta) { // try rhs operand
+	if meta := state.metamethod(rhs, event); !IsNone(meta) { // try rhs operand
 		if cls, ok := meta.(*Closure); ok {
 			state.frame().push(cls)
 			state.frame().push(lhs)
@@ -480,38 +651,24 @@ func tryMetaCompare(state *State, lhs, rhs Value, event metaEvent) (cmp bool, er
 			return cmp, nil
 		}
 	}
-	if event == metaLe {
+	switch event {
+	case metaEq: // values without __eq are simply different
+		return false, nil
+	case metaLe:
 		cmp, err = tryMetaCompare(state, rhs, lhs, metaLt)
 		return !cmp, err
 	}
-	return false, fmt.Errorf("attempt to compare %s with %s", lhs.Type(), rhs.Type())
+	return false, state.messageErr(MsgCompare, state.typeName(lhs), state.typeName(rhs))
 }
 
 // tryMetaConcat (__concat) performs the concatenation (..) operation. Behavior similar
 // to the addition operation, except that Lua will try a metamethod if any operand is
 // neither a string nor a number (which is always coercible to a string).
 func tryMetaConcat(state *State, lhs, rhs Value) (Value, error) {
-	const event = metaConcat
-
-	if meta := state.metafield(lhs, event.ID()); !IsNone(meta) { // try lhs operand
-		if cls, ok := meta.(*Closure); ok {
-			state.frame().push(cls)
-			state.frame().push(lhs)
-			state.frame().push(rhs)
-			state.Call(2, 1)
-			return state.frame().pop(), nil
-		}
-	}
-	if meta := state.metafield(rhs, event.ID()); !IsNone(meta) { // try rhs operand
-		if cls, ok := meta.(*Closure); ok {
-			state.frame().push(cls)
-			state.frame().push(lhs)
-			state.frame().push(rhs)
-			state.Call(2, 1)
-			return state.frame().pop(), nil
-		}
+	if v, ok := callMetaBinary(state, lhs, rhs, metaConcat); ok {
+		return v, nil
 	}
-	return None, fmt.Errorf("attempt to apply '%s' on %v and %v values", event.ID(), lhs.Type(), rhs.Type())
+	return None, state.messageErr(MsgConcat, state.typeName(lhs), state.typeName(rhs))
 }
 
 // tryMetaLength (__len) performs the length (#) operation. If the object is not a string, Lua
@@ -528,7 +685,7 @@ func tryMetaLength(state *State, obj Value) (Value, error) {
 			return state.frame().pop(), nil
 		}
 	}
-	return nil, fmt.Errorf("attempt to get length of %v value", obj.Type())
+	return nil, state.messageErr(MsgLength, state.typeName(obj))
 }
 
 // tryMetaCall performs the call operation func(args). This event happens when
@@ -540,7 +697,7 @@ func tryMetaLength(state *State, obj Value) (Value, error) {
 func tryMetaCall(state *State, value Value, fnID, args, rets int) bool {
 	const event = metaCall
 
-	if meta := state.metafield(value, event.ID()); !IsNone(meta) {
+	if meta := state.metamethod(value, event); !IsNone(meta) {
 		if cls, ok := meta.(*Closure); ok {
 			state.Push(cls)
 			state.Insert(-(args + 2))
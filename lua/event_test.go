@@ -0,0 +1,116 @@
+package lua
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMetaIndexChain(t *testing.T) {
+	state := NewState()
+	defer state.Close()
+
+	// A chain of 100 tables, each the __index and __newindex of the next,
+	// ending with a table holding "x".
+	base := newTable(state, 0, 0)
+	base.set(String("x"), Int(1))
+	obj := base
+	for i := 0; i < 100; i++ {
+		meta := newTable(state, 0, 0)
+		meta.set(String("__index"), obj)
+		meta.set(String("__newindex"), obj)
+		next := newTable(state, 0, 0)
+		next.meta = meta
+		obj = next
+	}
+	if got := state.gettable(obj, String("x"), false); got != Int(1) {
+		t.Errorf("obj.x = %v; want 1", got)
+	}
+	state.settable(obj, String("x"), Int(2), false)
+	if got := base.get(String("x")); got != Int(2) {
+		t.Errorf("base.x = %v after obj.x = 2; want 2", got)
+	}
+
+	// A table that is its own __index and __newindex.
+	loop := newTable(state, 0, 0)
+	meta := newTable(state, 0, 0)
+	meta.set(String("__index"), meta)
+	meta.set(String("__newindex"), meta)
+	meta.meta = meta
+	loop.meta = meta
+
+	var tests = []struct {
+		name string
+		fn   func()
+		want string
+	}{
+		{"get loop", func() { state.gettable(loop, String("y"), false) }, "'__index' chain too long; possible loop"},
+		{"set loop", func() { state.settable(loop, String("y"), True, false) }, "'__newindex' chain too long; possible loop"},
+		{"get nil", func() { state.gettable(Nil(1), String("y"), false) }, "attempt to index a nil value"},
+		{"set number", func() { state.settable(Int(1), String("y"), True, false) }, "attempt to index a number value"},
+	}
+	for _, test := range tests {
+		err := func() (err error) {
+			defer func() { err, _ = recover().(error) }()
+			test.fn()
+			return nil
+		}()
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: error = %v; want %s", test.name, err, test.want)
+		}
+	}
+}
+
+// scaled implements the single-operand interfaces of * and -.
+type scaled struct{ n int64 }
+
+func (x *scaled) Mul(v Value) (Value, error) { return Int(x.n * int64(v.(Int))), nil }
+func (x *scaled) Sub(v Value) (Value, error) { return Int(x.n - int64(v.(Int))), nil }
+
+// ordered implements HasArith, and describes the operations it is called for.
+type ordered struct{}
+
+func (x *ordered) Arith(op Op, lhs, rhs Value) (Value, error) {
+	name := func(v Value) string {
+		if _, ok := v.(*Object); ok {
+			return "x"
+		}
+		return v.String()
+	}
+	return String(fmt.Sprintf("%d:%s,%s", op, name(lhs), name(rhs))), nil
+}
+
+func TestMetaBinaryOrder(t *testing.T) {
+	state := NewState()
+	defer state.Close()
+
+	try := func(fn func() Value) (v Value, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = r.(error)
+			}
+		}()
+		return fn(), nil
+	}
+	s, o := valueOf(state, &scaled{10}), valueOf(state, &ordered{})
+	for _, test := range []struct {
+		name string
+		fn   func() Value
+		want Value
+	}{
+		{"x * 3", func() Value { return state.arith(OpMul, s, Int(3)) }, Int(30)},
+		{"3 * x", func() Value { return state.arith(OpMul, Int(3), s) }, Int(30)},
+		{"x - 3", func() Value { return state.arith(OpSub, s, Int(3)) }, Int(7)},
+		{"x - 3 (HasArith)", func() Value { return state.arith(OpSub, o, Int(3)) }, String(fmt.Sprintf("%d:x,3", OpSub))},
+		{"3 - x (HasArith)", func() Value { return state.arith(OpSub, Int(3), o) }, String(fmt.Sprintf("%d:3,x", OpSub))},
+		{"3 // x (HasArith)", func() Value { return state.arith(OpQuo, Int(3), o) }, String(fmt.Sprintf("%d:3,x", OpQuo))},
+		{"'a' .. x (HasArith)", func() Value { return state.concat([]Value{String("a"), o}) }, String(fmt.Sprintf("%d:a,x", OpConcat))},
+	} {
+		if got, err := try(test.fn); err != nil || got != test.want {
+			t.Errorf("%s = %v, %v; want %v", test.name, got, err, test.want)
+		}
+	}
+	if _, err := try(func() Value { return state.arith(OpSub, Int(3), s) }); err == nil || !strings.Contains(err.Error(), "__sub") {
+		t.Errorf("3 - x without HasArith = %v; want an arithmetic error", err)
+	}
+}
@@ -2,6 +2,7 @@ package lua
 
 import (
 	"fmt"
+	"sync/atomic"
 
 	"github.com/Azure/golua/lua/binary"
 	"github.com/Azure/golua/lua/vm"
@@ -14,7 +15,10 @@ type v53 struct{ state *State }
 // at index of the binary chunk
 func (vm *v53) prototype(index int) *binary.Prototype {
 	cls := vm.thread().frame().function()
-	return &cls.binary.Protos[index]
+	if vm.thread().global.frozen != nil {
+		return vm.thread().thaw(cls.binary, index)
+	}
+	return cls.binary.Proto(index)
 }
 
 // constant pushes onto the stack the value of constant at index.
@@ -74,8 +78,44 @@ func (vm *v53) rk(index int) Value {
 }
 
 func execute(vm *v53) {
+	if vm.state.global.config.crash != nil {
+		defer func() {
+			if r := recover(); r != nil {
+				vm.state.crashed(r)
+			}
+		}()
+	}
+	var (
+		ring = vm.state.global.ring
+		rec  = vm.state.global.record
+	)
 	for cmd, instr := vm.fetch(); cmd != nil; cmd, instr = cmd(vm, instr) {
+		if ring != nil {
+			fr := vm.state.frame()
+			ring.record(fr.closure.binary, fr.pc-1, instr.Code())
+		}
+		if rec != nil {
+			rec.record(vm.state.frame(), instr)
+		}
+		if hook := vm.state.hook; hook != nil && hook.mask&(HookLine|HookCount) != 0 {
+			vm.state.traceExec(vm.state.frame())
+			if rec != nil && rec.rewound { // run the instruction StepBack rewound to
+				rec.rewound = false
+				fr := vm.state.frame()
+				instr = fr.code(fr.pc - 1)
+				cmd = ops[instr.Code()]
+			}
+		}
 		vm.trace(instr)
+		if atomic.LoadInt32(&vm.state.global.interrupted) != 0 {
+			vm.state.runInterrupts()
+		}
+		if left := vm.state.global.instrs; left != nil {
+			if *left--; *left < 0 {
+				*left = 0
+				panic(runtimeErr(ErrInstructionLimit))
+			}
+		}
 	}
 }
 
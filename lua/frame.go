@@ -10,7 +10,8 @@ const (
 	// callStatusHooked                 // call is running a debug hook
 	// callStatusFresh                  // call is running on a fresh invocation of exec
 	// callStatusYieldPCall             // call is yieldable protected call
-	callStatusTail // call was tail called
+	callStatusTail   // call was tail called
+	callStatusHooked // call is running a debug hook
 	// callStatusHookYield              // last hook called yielded
 	// callStatusLEQ                    // using __lt for __le
 	// callStatusFinalizer              // call is running a finalizer
@@ -36,6 +37,7 @@ type (
 		locals     []Value          // frame stack locals
 		state      *State           // thread state
 		depth      int              // call frame ID
+		base       int              // stack slots used by the frames below
 		fnID       int              // function index
 		rets       int              // # expected returns
 		pc         int              // last executed instruction pc
@@ -44,8 +46,12 @@ type (
 	}
 )
 
-// checkstack checks that there atleast needed slots available.
+// checkstack checks that there atleast needed slots available, growing the
+// stack if needed; it reports false if the stack would exceed DefaultStackMax.
 func (fr *Frame) checkstack(needed int) bool {
+	if needed > DefaultStackMax-fr.gettop() {
+		return false
+	}
 	if space := cap(fr.locals) - fr.gettop(); space < needed {
 		fr.extend(needed - space)
 	}
@@ -85,7 +91,7 @@ func (fr *Frame) settop(top int) {
 		}
 	case diff < 0: // new top > old top
 		for i := 0; i > diff; i-- {
-			fr.push(None)
+			fr.push(Nil(1))
 		}
 	}
 }
@@ -262,9 +268,10 @@ func (fr *Frame) local(index int) Value {
 
 // pushN pushes N values onto the frame's stack.
 //
-// TODO: ensure stack
+// The stack is grown once for all the values, so that pushing the many
+// arguments or results of a call stays linear.
 func (fr *Frame) pushN(vs []Value) {
-	// fmt.Printf("frame #%d: push %v\n", fr.depth, vs)
+	fr.checkstack(len(vs))
 	for _, v := range vs {
 		fr.push(v)
 	}
@@ -282,7 +289,7 @@ func (fr *Frame) copy(src, dst int) { fr.set(src, fr.get(dst)) }
 //
 // TODO: ensure stack
 func (fr *Frame) push(v Value) {
-	fr.locals = append(fr.locals, v)
+	fr.locals = append(fr.locals, stackValue(v))
 }
 
 // pop pops 1 value from the frame's stack.
@@ -298,15 +305,19 @@ func (fr *Frame) pop() Value {
 	return val
 }
 
-// popN pops N values from the frame's stack.
-//
-// TODO: ensure stack
+// popN pops N values from the frame's stack; missing values are None.
 func (fr *Frame) popN(n int) (vs []Value) {
 	if n > 0 {
 		vs = make([]Value, n)
-		for i := n - 1; i >= 0; i-- {
-			vs[i] = fr.pop()
+		top := fr.gettop() - n
+		for i := 0; top+i < 0; i++ { // stack underflow
+			vs[i] = None
 		}
+		if top < 0 {
+			top = 0
+		}
+		copy(vs[n-(fr.gettop()-top):], fr.locals[top:])
+		fr.locals = fr.locals[:top]
 	}
 	return vs
 }
@@ -337,7 +348,16 @@ func (fr *Frame) set(index int, value Value) {
 		fr.push(value)
 		return
 	}
-	fr.locals[index] = value
+	fr.locals[index] = stackValue(value)
+}
+
+// stackValue returns the value stored in a stack slot for v: an absent
+// value, such as a missing table field, is nil once on the stack.
+func stackValue(v Value) Value {
+	if v == nil || v == None {
+		return Nil(1)
+	}
+	return v
 }
 
 // get returns the value located in the frame's locals
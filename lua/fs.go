@@ -0,0 +1,77 @@
+package lua
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// File is a file opened by a FileSystem. Files that cannot seek, such as
+// pipes, return an error from Seek.
+type File interface {
+	io.Reader
+	io.Writer
+	io.Seeker
+	io.Closer
+}
+
+// FileSystem is the file system of the files that scripts name: the io
+// library opens files in it, os.remove and os.rename act on it, and dofile,
+// loadfile, require and the libraries that load files, such as data, csv, xml
+// and config, read from it. Embedders replace it with WithFileSystem to point
+// scripts at a virtual or sandboxed file system; names are passed as scripts
+// give them.
+type FileSystem interface {
+	// OpenFile opens the named file with the flags of os.OpenFile, such as
+	// os.O_RDONLY or os.O_WRONLY|os.O_CREATE|os.O_TRUNC, creating it with
+	// perm if needed.
+	OpenFile(name string, flag int, perm os.FileMode) (File, error)
+
+	// Remove removes the named file or empty directory.
+	Remove(name string) error
+
+	// Rename renames the file oldname to newname.
+	Rename(oldname, newname string) error
+}
+
+// OSFileSystem is the file system of the operating system, which states use
+// unless set with WithFileSystem.
+var OSFileSystem FileSystem = osFileSystem{}
+
+type osFileSystem struct{}
+
+func (osFileSystem) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	return os.OpenFile(name, flag, perm)
+}
+
+func (osFileSystem) Remove(name string) error { return os.Remove(name) }
+
+func (osFileSystem) Rename(oldname, newname string) error { return os.Rename(oldname, newname) }
+
+// WithFileSystem returns an Option that sets the file system of the state's
+// scripts (see FileSystem).
+func WithFileSystem(fs FileSystem) Option {
+	return func(cfg *config) {
+		cfg.fs = fs
+	}
+}
+
+// FileSystem returns the file system of the state's scripts, as set with
+// WithFileSystem.
+func (state *State) FileSystem() FileSystem {
+	if fs := state.global.config.fs; fs != nil {
+		return fs
+	}
+	return OSFileSystem
+}
+
+// ReadFile reads the named file of the state's file system (see FileSystem),
+// for the functions that read files named by scripts.
+func (state *State) ReadFile(name string) ([]byte, error) {
+	file, err := state.FileSystem().OpenFile(name, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ioutil.ReadAll(file)
+}
@@ -0,0 +1,213 @@
+package lua
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/Azure/golua/lua/binary"
+)
+
+// Interrupt asks the state to call fn from the goroutine running it, at the
+// next instruction boundary of a Lua function. Unlike other State methods,
+// Interrupt may be called from any goroutine; it is how other goroutines
+// inspect or stop a running script safely.
+//
+// fn receives the thread (main state or coroutine) that was running. Pending
+// interrupts are not called while only Go functions run.
+func (state *State) Interrupt(fn func(*State)) {
+	g := state.global
+	g.interruptMu.Lock()
+	g.interrupts = append(g.interrupts, fn)
+	atomic.StoreInt32(&g.interrupted, 1)
+	g.interruptMu.Unlock()
+}
+
+// runInterrupts calls the pending interrupts.
+func (state *State) runInterrupts() {
+	g := state.global
+	g.interruptMu.Lock()
+	fns := g.interrupts
+	g.interrupts = nil
+	atomic.StoreInt32(&g.interrupted, 0)
+	g.interruptMu.Unlock()
+	for _, fn := range fns {
+		fn(state)
+	}
+}
+
+// Traceback returns a traceback of the state's call stack, starting with the
+// running function and preceded by msg if it is not empty. With
+// WithResumeTraceback, the traceback of a coroutine goes on with the frames
+// of the threads that resumed it.
+func (state *State) Traceback(msg string) string {
+	return state.TracebackLevel(msg, 0)
+}
+
+// TracebackLevel is like Traceback, but starts at the function running at
+// level of the call stack, numbered as in GetStack.
+func (state *State) TracebackLevel(msg string, level int) string {
+	var b strings.Builder
+	if msg != "" {
+		b.WriteString(msg)
+		b.WriteByte('\n')
+	}
+	b.WriteString("stack traceback:")
+	for thread := state; thread != nil; thread = thread.resumer() {
+		if thread != state {
+			b.WriteString("\n\t(resumed from)")
+			level = 0
+		}
+		thread.traceback(&b, level)
+	}
+	state.tracebackInstructions(&b)
+	return b.String()
+}
+
+// resumer returns the thread that resumed the coroutine state, if it is
+// active and tracebacks go on with its frames; see WithResumeTraceback.
+func (state *State) resumer() *State {
+	if state.co == nil || !state.global.config.resumeTraceback {
+		return nil
+	}
+	return state.co.resumer
+}
+
+// traceback writes the frames of the state's call stack to b, skipping the
+// first level frames.
+func (state *State) traceback(b *strings.Builder, level int) {
+	for fr := state.frame(); fr != nil && fr != &state.base; fr = fr.prev {
+		cls := fr.function()
+		if cls == nil {
+			continue
+		}
+		if level > 0 {
+			level--
+			continue
+		}
+		var debug Debug
+		funcinfo(fr, &debug, cls)
+		switch name, what := callname(fr); {
+		case !cls.isLua():
+			fmt.Fprintf(b, "\n\t[Go]: in function '%s'", goname(cls))
+		case debug.what == "main":
+			fmt.Fprintf(b, "\n\t%s:%d: in main chunk", debug.short, currentLine(fr))
+		case what != "":
+			fmt.Fprintf(b, "\n\t%s:%d: in %s '%s'", debug.short, currentLine(fr), what, name)
+		default:
+			fmt.Fprintf(b, "\n\t%s:%d: in function <%s:%d>", debug.short, currentLine(fr), debug.short, debug.span[0])
+		}
+	}
+}
+
+// currentLine returns the line of the instruction being executed by the Lua
+// frame fr, or -1 if unknown. A function that has not started yet is at the
+// line of its first instruction.
+func currentLine(fr *Frame) int {
+	pc := fr.pc - 1
+	if pc < 0 {
+		pc = 0
+	}
+	return lineAt(fr.closure.binary, pc)
+}
+
+// lineAt returns the line of the instruction at pc of proto, or -1 if unknown.
+func lineAt(proto *binary.Prototype, pc int) int {
+	if pc >= 0 && pc < len(proto.PcLnTab) {
+		return int(proto.PcLnTab[pc])
+	}
+	return -1
+}
+
+// Hook is a debug hook, called by the thread it is set on for the events of
+// its mask. The debug record identifies the running function, for GetInfo
+// and GetLocal, and has the event; for line events, its current line is set.
+type Hook func(state *State, debug *Debug)
+
+// hooks is the hook set on a thread with SetHook.
+type hooks struct {
+	fn      Hook
+	mask    HookEvent
+	count   int    // instructions between count events
+	left    int    // instructions left until the next count event
+	oldfr   *Frame // frame of the last instruction traced for line events
+	oldpc   int    // pc of oldfr after its last traced instruction
+	running bool   // hooks do not run while a hook runs
+}
+
+// SetHook sets the debug hook of the thread, which is called for the events
+// in mask: HookCall when a function is called, before it runs; HookRets when
+// a function returns, before its results are moved to the caller; HookLine
+// when a Lua function starts, enters a new line of code or jumps back, even to
+// the same line; and HookCount after every count instructions of Lua
+// functions. While a hook runs, no hook is called. A nil hook or a zero mask
+// turns off the hook. Threads created by the thread inherit its hook.
+//
+// See https://www.lua.org/manual/5.3/manual.html#lua_sethook
+func (state *State) SetHook(hook Hook, mask HookEvent, count int) {
+	if hook == nil || mask == 0 {
+		state.hook = nil
+		return
+	}
+	state.hook = &hooks{fn: hook, mask: mask, count: count, left: count}
+}
+
+// GetHook returns the hook, mask and count set on the thread with SetHook.
+//
+// See https://www.lua.org/manual/5.3/manual.html#lua_gethook
+func (state *State) GetHook() (hook Hook, mask HookEvent, count int) {
+	if h := state.hook; h != nil {
+		return h.fn, h.mask, h.count
+	}
+	return nil, 0, 0
+}
+
+// callHook calls the hook for event in the frame fr, if the event is in its
+// mask and no hook is running. The stack of fr is left as the hook found it.
+func (state *State) callHook(fr *Frame, event HookEvent, line int) {
+	h := state.hook
+	if h == nil || h.running || h.mask&event == 0 {
+		return
+	}
+	h.running = true
+	fr.status |= callStatusHooked
+	defer func() {
+		h.running = false
+		fr.status &^= callStatusHooked
+	}()
+	top := fr.gettop()
+	h.fn(state, &Debug{frame: fr, event: event, active: line})
+	if rec := state.global.record; rec != nil && rec.rewound {
+		top = rec.top // registers restored by StepBack
+	}
+	fr.settop(top)
+}
+
+// traceExec calls the count and line hooks before the instruction of the Lua
+// frame fr at fr.pc-1 runs.
+func (state *State) traceExec(fr *Frame) {
+	h := state.hook
+	if h.running {
+		return
+	}
+	if h.mask&HookCount != 0 {
+		if h.left--; h.left == 0 {
+			h.left = h.count
+			state.callHook(fr, HookCount, -1)
+		}
+	}
+	if h.mask&HookLine != 0 {
+		if fr != h.oldfr { // entered fr, or returned to it from a call
+			h.oldfr, h.oldpc = fr, fr.pc-1
+		}
+		var (
+			proto = fr.closure.binary
+			pc    = fr.pc - 1
+			line  = lineAt(proto, pc)
+		)
+		if pc == 0 || fr.pc <= h.oldpc || line != lineAt(proto, h.oldpc-1) {
+			state.callHook(fr, HookLine, line)
+		}
+		h.oldpc = fr.pc
+	}
+}
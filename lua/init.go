@@ -0,0 +1,120 @@
+package lua
+
+import (
+	"fmt"
+	"strings"
+)
+
+// InitPolicy is what InitConfig does when a step of the initialization of a
+// state fails.
+type InitPolicy int
+
+const (
+	// InitAbort stops the initialization at the failed step: InitConfig.NewState
+	// closes the state and returns the error.
+	InitAbort InitPolicy = iota
+
+	// InitWarn writes the error to the error output of the state (see
+	// SetErrorOutput) and goes on with the next step.
+	InitWarn
+)
+
+// InitConfig describes how to initialize the states of an application, so
+// that every state gets the same libraries and warmup scripts. Its NewState
+// method is a factory for NewPool:
+//
+//	cfg := &lua.InitConfig{
+//		InitFuncs:   []func(*lua.State) error{openLibs},
+//		InitScripts: []string{"@prelude.lua", "strict = true"},
+//	}
+//	pool := lua.NewPool(8, cfg.NewState)
+//
+// The steps run in order: first InitFuncs, then InitScripts, each in the order
+// of its slice and on an empty stack. Steps run protected, so that a step that
+// raises an error fails like one that returns it.
+type InitConfig struct {
+	// Options are the options of the states created by NewState.
+	Options []Option
+
+	// InitFuncs are Go functions that open libraries, register functions or
+	// set globals.
+	InitFuncs []func(*State) error
+
+	// InitScripts are Lua chunks run after InitFuncs: a script of the form
+	// "@filename" runs the file, any other script is run as Lua code, as with
+	// the LUA_INIT environment variable.
+	InitScripts []string
+
+	// OnFailure is what to do when a step fails; InitAbort by default.
+	OnFailure InitPolicy
+}
+
+// NewState creates a state with the options of the configuration and
+// initializes it with Init. If the initialization is aborted, NewState
+// closes the state and returns the error.
+func (cfg *InitConfig) NewState() (*State, error) {
+	state := NewState(cfg.Options...)
+	if err := cfg.Init(state); err != nil {
+		state.Close()
+		return nil, err
+	}
+	return state, nil
+}
+
+// Init runs the steps of the configuration on state. It returns the error of
+// the first failed step unless the policy is InitWarn, in which case it only
+// returns nil.
+func (cfg *InitConfig) Init(state *State) error {
+	for i, fn := range cfg.InitFuncs {
+		fn := fn
+		err := state.initStep(func(state *State) error { return fn(state) })
+		if err = cfg.failed(state, err, fmt.Sprintf("init func #%d", i+1)); err != nil {
+			return err
+		}
+	}
+	for _, script := range cfg.InitScripts {
+		script := script
+		err := state.initStep(func(state *State) error {
+			var err error
+			if strings.HasPrefix(script, "@") {
+				err = state.LoadChunk(script[1:], nil, BinaryMode|TextMode)
+			} else {
+				err = state.LoadChunk("?", script, BinaryMode|TextMode)
+			}
+			if err == nil {
+				state.Call(0, 0)
+			}
+			return err
+		})
+		if err = cfg.failed(state, err, "init script "+chunkID(script)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// failed applies the failure policy to the error of the step what, if any.
+func (cfg *InitConfig) failed(state *State, err error, what string) error {
+	if err == nil {
+		return nil
+	}
+	err = fmt.Errorf("%s: %w", what, err)
+	if cfg.OnFailure == InitWarn {
+		fmt.Fprintln(state.ErrorOutput(), err)
+		return nil
+	}
+	return err
+}
+
+// initStep runs step protected, on an empty stack.
+func (state *State) initStep(step func(*State) error) error {
+	state.SetTop(0)
+	defer state.SetTop(0)
+	state.Push(Func(func(state *State) int {
+		if err := step(state); err != nil {
+			panic(err)
+		}
+		return 0
+	}))
+	return state.PCall(0, 0, 0)
+}
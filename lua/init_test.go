@@ -0,0 +1,60 @@
+package lua
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestInitConfig(t *testing.T) {
+	var steps []string
+	step := func(name string, err error) func(*State) error {
+		return func(state *State) error {
+			steps = append(steps, fmt.Sprint(name, state.Top()))
+			state.Push(name) // left on the stack
+			return err
+		}
+	}
+	cfg := &InitConfig{
+		InitFuncs: []func(*State) error{
+			step("a", nil),
+			func(state *State) error { state.Errorf("raised"); return nil },
+			step("b", nil),
+		},
+		InitScripts: []string{"@testdata/missing.lua"},
+	}
+
+	// Aborting stops at the first failure.
+	if state, err := cfg.NewState(); state != nil || err == nil || err.Error() != "init func #2: raised" {
+		t.Errorf("NewState() = %v, %v; want init func #2: raised", state, err)
+	}
+	if got := fmt.Sprint(steps); got != "[a0]" {
+		t.Errorf("steps = %s; want [a0]", got)
+	}
+
+	// Warning reports every failure and runs the other steps.
+	var warnings strings.Builder
+	steps = nil
+	cfg.OnFailure = InitWarn
+	cfg.InitFuncs = append([]func(*State) error{func(state *State) error {
+		state.SetErrorOutput(&warnings)
+		return nil
+	}}, cfg.InitFuncs...)
+	state, err := cfg.NewState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer state.Close()
+	if got := fmt.Sprint(steps, state.Top()); got != "[a0 b0] 0" {
+		t.Errorf("steps and top = %s; want [a0 b0] 0", got)
+	}
+	lines := strings.Split(strings.TrimSpace(warnings.String()), "\n")
+	if len(lines) != 2 || lines[0] != "init func #3: raised" || !strings.HasPrefix(lines[1], "init script testdata/missing.lua: ") {
+		t.Errorf("warnings:\n%s", warnings.String())
+	}
+
+	if err := (&InitConfig{InitFuncs: []func(*State) error{step("c", errors.New("failed"))}}).Init(state); err == nil || err.Error() != "init func #1: failed" {
+		t.Errorf("Init() = %v", err)
+	}
+}
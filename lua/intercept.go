@@ -0,0 +1,99 @@
+package lua
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Call describes a call to an intercepted function; see State.Intercept.
+type Call struct {
+	Name string  // name the function was intercepted with
+	Args []Value // arguments of the call
+	Rets []Value // results of the call, for the after interceptors
+}
+
+// interceptor is an interceptor installed with State.Intercept.
+type interceptor struct {
+	name          string
+	before, after func(*State, *Call)
+}
+
+// Intercept installs interceptors on calls to the function name, such as
+// "print" or "string.format": before is called before every call to it, with
+// the arguments, and after when the call returns, with the arguments and the
+// results the caller receives. Either may be nil. Interceptors are called by
+// the thread making the call; they must not change its stack, but may raise
+// errors, which the call raises. Interceptors apply to the function, whether
+// it is a Lua or a Go function and whatever name it is called by, so that
+// scripts need not change to be traced, have their arguments checked, or be
+// warned about deprecated functions:
+//
+//	remove, err := state.Intercept("os.time", func(state *lua.State, call *lua.Call) {
+//		log.Printf("%s is deprecated, use datetime.now", call.Name)
+//	}, nil)
+//
+// The name is a global or a path of fields from a global, resolved when the
+// interceptors are installed: a function assigned to the name afterwards is
+// not intercepted. A function may have several interceptors; the before
+// interceptors run in the order they were installed and the after
+// interceptors in the reverse order. Intercept returns the function that
+// removes the interceptors, or an error if name is not a function.
+func (state *State) Intercept(name string, before, after func(*State, *Call)) (remove func(), err error) {
+	cls, ok := state.lookup(name).(*Closure)
+	if !ok {
+		return nil, fmt.Errorf("cannot intercept '%s' (not a function)", name)
+	}
+	g := state.global
+	if g.intercepts == nil {
+		g.intercepts = make(map[*Closure][]*interceptor)
+	}
+	icpt := &interceptor{name, before, after}
+	g.intercepts[cls] = append(g.intercepts[cls], icpt)
+	return func() {
+		icpts := g.intercepts[cls]
+		for i, other := range icpts {
+			if other == icpt {
+				icpts = append(icpts[:i:i], icpts[i+1:]...)
+				break
+			}
+		}
+		if len(icpts) == 0 {
+			delete(g.intercepts, cls)
+		} else {
+			g.intercepts[cls] = icpts
+		}
+	}, nil
+}
+
+// lookup returns the value of the global name, or of a path of fields from a
+// global such as "string.format", or None if a part of the path is missing.
+func (state *State) lookup(name string) Value {
+	value := state.global.registry.getInt(GlobalsIndex)
+	for _, field := range strings.Split(name, ".") {
+		if IsNone(value) {
+			break
+		}
+		value = state.gettable(value, String(field), false)
+	}
+	return value
+}
+
+// intercept runs the frame fr, whose function has the interceptors icpts.
+func (state *State) intercept(fr *Frame, icpts []*interceptor) {
+	var (
+		caller = fr.caller()
+		args   = append([]Value(nil), fr.locals...)
+	)
+	for _, icpt := range icpts {
+		if icpt.before != nil {
+			icpt.before(state, &Call{Name: icpt.name, Args: args})
+		}
+	}
+	state.run(fr)
+	rets := caller.locals[fr.fnID-1:]
+	for i := len(icpts) - 1; i >= 0; i-- {
+		if icpt := icpts[i]; icpt.after != nil {
+			icpt.after(state, &Call{Name: icpt.name, Args: args, Rets: rets})
+		}
+	}
+}
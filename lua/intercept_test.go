@@ -0,0 +1,70 @@
+package lua
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestIntercept(t *testing.T) {
+	state := NewState()
+	defer state.Close()
+	registerSelect(state)
+
+	var log []string
+	trace := func(what string) func(*State, *Call) {
+		return func(state *State, call *Call) {
+			log = append(log, fmt.Sprintf("%s %s%v%v", what, call.Name, call.Args, call.Rets))
+		}
+	}
+	remove1, err := state.Intercept("select", trace("before1"), trace("after1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	remove2, err := state.Intercept("select", trace("before2"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Calls from Lua are intercepted.
+	runProto(t, state, wideSelect, []interface{}{"select"}, int64(2), "a", "b")
+	want := "[before1 select[2 2 a b][] before2 select[2 2 a b][] after1 select[2 2 a b][a b]]"
+	if got := fmt.Sprint(log); got != want {
+		t.Errorf("interceptors logged %s; want %s", got, want)
+	}
+
+	// The function can be intercepted by other names.
+	log = nil
+	remove2()
+	state.GetGlobal("select")
+	state.SetGlobal("choose")
+	remove3, err := state.Intercept("choose", func(state *State, call *Call) {
+		if n, ok := call.Args[0].(Int); ok && n < 0 {
+			state.Errorf("choose: negative index")
+		}
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state.GetGlobal("choose")
+	state.Push(-1)
+	state.Push("a")
+	if err := state.PCall(2, 1, 0); err == nil || err.Error() != "choose: negative index" {
+		t.Errorf("choose(-1, 'a') = %v; want the error of the interceptor", err)
+	}
+	if want := "[before1 select[-1 a][]]"; fmt.Sprint(log) != want {
+		t.Errorf("interceptors logged %s; want %s", log, want)
+	}
+
+	// Removed interceptors are not called.
+	log = nil
+	remove1()
+	remove3()
+	runProto(t, state, wideSelect, []interface{}{"select"}, int64(1), "a")
+	if len(log) != 0 {
+		t.Errorf("removed interceptors logged %s", log)
+	}
+
+	if _, err := state.Intercept("string.format", nil, nil); err == nil || err.Error() != "cannot intercept 'string.format' (not a function)" {
+		t.Errorf("Intercept('string.format') = %v; want an error", err)
+	}
+}
@@ -0,0 +1,223 @@
+package lua
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Azure/golua/lua/binary"
+)
+
+// ChunkLimits are the limits on the functions of the chunks loaded by a state,
+// DefaultChunkLimits unless set with WithChunkLimits.
+//
+// The functions of a chunk that exceeds them are not run: loading the chunk
+// fails with the syntax error luac reports for the same limit, such as
+//
+//	data.lua:1: too many constants (limit is 1000) in main function
+//
+// so that scripts written by generators fail early and the same way whether
+// they are loaded as text or as precompiled chunks. A zero field stands for
+// the default, which is also the largest value supported by the VM.
+type ChunkLimits struct {
+	Constants int // constants per function
+	UpValues  int // upvalues per function
+	Registers int // registers per function
+	Code      int // instructions per function
+	Nesting   int // depth of nested functions
+}
+
+// DefaultChunkLimits are the limits of the instruction format, which luac
+// enforces when it compiles a chunk.
+var DefaultChunkLimits = ChunkLimits{
+	Constants: 1 << 26,              // MAXARG_Ax + 1 (constants are loaded with LOADKX)
+	UpValues:  MaxUpValues,          // MAXUPVAL
+	Registers: 255,                  // MAXREGS
+	Code:      int(^uint32(0) >> 1), // MAX_INT in luac
+	Nesting:   200,                  // LUAI_MAXCCALLS
+}
+
+// WithChunkLimits returns an Option that lowers the limits on the functions
+// of the chunks loaded by the state. Limits above the defaults are not safe
+// and are lowered to the defaults.
+func WithChunkLimits(limits ChunkLimits) Option {
+	return func(cfg *config) {
+		clamp := func(limit *int, max int) {
+			if *limit <= 0 || *limit > max {
+				*limit = max
+			}
+		}
+		clamp(&limits.Constants, DefaultChunkLimits.Constants)
+		clamp(&limits.UpValues, DefaultChunkLimits.UpValues)
+		clamp(&limits.Registers, DefaultChunkLimits.Registers)
+		clamp(&limits.Code, DefaultChunkLimits.Code)
+		clamp(&limits.Nesting, DefaultChunkLimits.Nesting)
+		cfg.limits = &limits
+	}
+}
+
+// chunkLimits returns the limits on the chunks loaded by the state.
+func (state *State) chunkLimits() *ChunkLimits {
+	if limits := state.global.config.limits; limits != nil {
+		return limits
+	}
+	return &DefaultChunkLimits
+}
+
+// checkLimits returns the error for the first function of proto, or of its
+// nested functions, that exceeds limits, or nil.
+func checkLimits(proto *binary.Prototype, limits *ChunkLimits) error {
+	return checkProto(proto, proto.Source, 1, limits)
+}
+
+func checkProto(proto *binary.Prototype, source string, level int, limits *ChunkLimits) error {
+	if proto.Source != "" {
+		source = proto.Source
+	}
+	var (
+		line  = int(proto.SrcPos)
+		where = "main function"
+	)
+	if line != 0 {
+		where = fmt.Sprintf("function at line %d", line)
+	}
+	fail := func(msg string) error {
+		return fmt.Errorf("%s:%d: %s", chunkID(source), line, msg)
+	}
+	tooMany := func(what string, limit int) error {
+		return fail(fmt.Sprintf("too many %s (limit is %d) in %s", what, limit, where))
+	}
+	switch {
+	case level > limits.Nesting:
+		return tooMany("C levels", limits.Nesting)
+	case int(proto.Stack) > limits.Registers:
+		return fail("function or expression needs too many registers")
+	case len(proto.Consts) > limits.Constants:
+		return tooMany("constants", limits.Constants)
+	case len(proto.UpValues) > limits.UpValues:
+		return tooMany("upvalues", limits.UpValues)
+	case len(proto.Code) > limits.Code:
+		return tooMany("instructions", limits.Code)
+	}
+	for i := range proto.Protos {
+		if err := checkProto(&proto.Protos[i], source, level+1, limits); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DefaultUnpackLimit is the default maximum number of values that
+// table.unpack returns.
+const DefaultUnpackLimit = 1000000
+
+// WithUnpackLimit returns an Option that sets the maximum number of values
+// that table.unpack, and other functions that spread a list on the stack,
+// return; calls that would return more raise "too many results to unpack".
+// The limit cannot exceed the stack size of the state (see WithStackMax).
+func WithUnpackLimit(limit int) Option {
+	return func(cfg *config) {
+		if limit > DefaultStackMax {
+			limit = DefaultStackMax
+		}
+		cfg.unpack = limit
+	}
+}
+
+// UnpackLimit returns the maximum number of values a function such as
+// table.unpack may push onto the stack (see WithUnpackLimit): the unpack limit
+// of the state, lowered to its stack size.
+func (state *State) UnpackLimit() int {
+	limit := state.global.config.unpack
+	if limit <= 0 {
+		limit = DefaultUnpackLimit
+	}
+	if max := state.StackMax(); limit > max {
+		limit = max
+	}
+	return limit
+}
+
+// WithStackMax returns an Option that sets the stack size of the threads of
+// the state: the number of slots that the frames of a thread may use in all.
+// A call whose arguments or Lua frame would not fit raises a stack overflow
+// error, and CheckStack, and therefore table.unpack, check it before pushing
+// many values. The stack size cannot exceed DefaultStackMax, the default,
+// which is also the largest valid stack index.
+func WithStackMax(max int) Option {
+	return func(cfg *config) {
+		if max > DefaultStackMax {
+			max = DefaultStackMax
+		}
+		cfg.stack = max
+	}
+}
+
+// StackMax returns the stack size of the threads of the state (see
+// WithStackMax).
+func (state *State) StackMax() int {
+	if max := state.global.config.stack; max > 0 {
+		return max
+	}
+	return DefaultStackMax
+}
+
+// Limits are the implementation limits of a state, so that tools such as
+// validators and linters check scripts against the limits of the VM that
+// runs them rather than hard-coding values that drift from it. Scripts get
+// the same limits from _GOLUA.limits.
+type Limits struct {
+	MaxStack        int   // stack size of a thread (see WithStackMax)
+	MaxUpvalues     int   // upvalues of a closure
+	MaxCallDepth    int   // nested Go calls
+	MaxStringLength int   // length of a string in bytes
+	MaxUnpack       int   // values spread on the stack (see UnpackLimit)
+	MinInteger      int64 // smallest integer
+	MaxInteger      int64 // largest integer
+}
+
+// Limits returns the implementation limits of the state, which depend on
+// its options.
+func (state *State) Limits() Limits {
+	return Limits{
+		MaxStack:        state.StackMax(),
+		MaxUpvalues:     MaxUpValues,
+		MaxCallDepth:    MaxCalls,
+		MaxStringLength: MaxSize,
+		MaxUnpack:       state.UnpackLimit(),
+		MinInteger:      MinInt,
+		MaxInteger:      MaxInt,
+	}
+}
+
+// ErrInstructionLimit is the error raised in the scripts of a state that
+// executed the instructions allowed by SetInstructionLimit.
+var ErrInstructionLimit = errors.New("instruction limit exceeded")
+
+// SetInstructionLimit allows the scripts of the state, in all its threads, to
+// execute n more VM instructions, after which they are stopped with
+// ErrInstructionLimit, so that a server embedding scripts of several tenants
+// terminates infinite loops:
+//
+//	state.SetInstructionLimit(1e6)
+//	err := state.PCall(0, 0, 0) // errors.Is(err, lua.ErrInstructionLimit)
+//
+// The error is raised like other errors, but again at every following
+// instruction, so pcall does not let the script go on; Go functions run to
+// completion. The limit holds until it is set again, such as for the next
+// request; a limit <= 0 removes it.
+func (state *State) SetInstructionLimit(n int64) {
+	if n <= 0 {
+		state.global.instrs = nil
+		return
+	}
+	state.global.instrs = &n
+}
+
+// InstructionsLeft returns the number of instructions the scripts of the state
+// may still execute (see SetInstructionLimit), or -1 if there is no limit.
+func (state *State) InstructionsLeft() int64 {
+	if left := state.global.instrs; left != nil {
+		return *left
+	}
+	return -1
+}
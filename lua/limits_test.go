@@ -0,0 +1,134 @@
+package lua
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Azure/golua/lua/binary"
+	"github.com/Azure/golua/lua/vm"
+)
+
+func TestChunkLimits(t *testing.T) {
+	// local f = function() end; return 1, 2, 3
+	proto := binary.Prototype{
+		Source:   "@data.lua",
+		Vararg:   1,
+		Stack:    4,
+		Consts:   []interface{}{int64(1), int64(2), int64(3)},
+		UpValues: []binary.UpValue{{InStack: 1, Index: 0}},
+		UpNames:  []string{"_ENV"},
+		Code: []uint32{
+			iABx(vm.CLOSURE, 0, 0),
+			iABx(vm.LOADK, 1, 0),
+			iABx(vm.LOADK, 2, 1),
+			iABx(vm.LOADK, 3, 2),
+			iABC(vm.RETURN, 1, 4, 0),
+		},
+		Protos: []binary.Prototype{{
+			SrcPos: 3,
+			EndPos: 3,
+			Stack:  10,
+			Code:   []uint32{iABC(vm.RETURN, 0, 1, 0)},
+		}},
+	}
+	chunk := binary.Dump(&proto, false)
+
+	var tests = []struct {
+		limits ChunkLimits
+		want   string
+	}{
+		{ChunkLimits{}, ""},
+		{ChunkLimits{Registers: 300}, ""},
+		{ChunkLimits{Constants: 2}, "data.lua:0: too many constants (limit is 2) in main function"},
+		{ChunkLimits{Registers: 8}, "data.lua:3: function or expression needs too many registers"},
+		{ChunkLimits{Code: 4}, "data.lua:0: too many instructions (limit is 4) in main function"},
+		{ChunkLimits{Nesting: 1}, "data.lua:3: too many C levels (limit is 1) in function at line 3"},
+	}
+	for _, test := range tests {
+		state := NewState(WithChunkLimits(test.limits))
+		err := state.LoadChunk("data.lua", chunk, BinaryMode)
+		if test.want == "" {
+			if err != nil {
+				t.Errorf("%+v: %v", test.limits, err)
+			}
+		} else if err == nil || err.Error() != test.want {
+			t.Errorf("%+v: error = %v; want %q", test.limits, err, test.want)
+		}
+		state.Close()
+	}
+}
+
+func TestInstructionLimit(t *testing.T) {
+	state := NewState()
+	defer state.Close()
+
+	loop := []uint32{iAsBx(vm.JMP, 0, -1), iABC(vm.RETURN, 0, 1, 0)}   // while true do end
+	answer := []uint32{iABx(vm.LOADK, 0, 0), iABC(vm.RETURN, 0, 2, 0)} // return 42
+	run := func(code []uint32) error {
+		defer state.SetTop(0)
+		if err := loadProto(state, code, int64(42)); err != nil {
+			t.Fatal(err)
+		}
+		return state.PCall(0, 1, 0)
+	}
+
+	state.SetInstructionLimit(1000)
+	if err := run(loop); !errors.Is(err, ErrInstructionLimit) {
+		t.Fatalf("loop = %v; want ErrInstructionLimit", err)
+	}
+	if left := state.InstructionsLeft(); left != 0 {
+		t.Errorf("InstructionsLeft() = %d after the limit; want 0", left)
+	}
+	if err := run(answer); !errors.Is(err, ErrInstructionLimit) {
+		t.Errorf("answer after the limit = %v; want ErrInstructionLimit", err)
+	}
+	state.SetInstructionLimit(2)
+	if err := run(answer); err != nil {
+		t.Errorf("answer with 2 instructions = %v", err)
+	}
+	state.SetInstructionLimit(1)
+	if err := run(answer); !errors.Is(err, ErrInstructionLimit) {
+		t.Errorf("answer with 1 instruction = %v; want ErrInstructionLimit", err)
+	}
+	state.SetInstructionLimit(0)
+	if err := run(answer); err != nil || state.InstructionsLeft() != -1 {
+		t.Errorf("answer without limit = %v, %d left", err, state.InstructionsLeft())
+	}
+}
+
+func TestStackMax(t *testing.T) {
+	state := NewState(WithStackMax(1000))
+	defer state.Close()
+
+	// Each call of recurse keeps its 20 arguments on the stack while it calls
+	// itself again.
+	var depth int
+	var recurse Func
+	recurse = func(state *State) int {
+		depth++
+		if depth == 5 {
+			if !state.CheckStack(1000-5*20) || state.CheckStack(1000-5*20+1) {
+				t.Errorf("CheckStack at depth 5 does not see the %d slots in use", 5*20)
+			}
+		}
+		state.Push(recurse)
+		for i := 0; i < 20; i++ {
+			state.Push(i)
+		}
+		state.Call(20, 0)
+		return 0
+	}
+	state.Push(recurse)
+	for i := 0; i < 20; i++ {
+		state.Push(i)
+	}
+	if err := state.PCall(20, 0, 0); err == nil || err.Error() != "go: call stack overflow" {
+		t.Errorf("unbounded recursion = %v; want a stack overflow", err)
+	}
+	if depth != 1000/20 {
+		t.Errorf("recursion reached depth %d; want %d", depth, 1000/20)
+	}
+	if used := state.stackUsed(); used != 0 {
+		t.Errorf("stackUsed() = %d after the recursion; want 0", used)
+	}
+}
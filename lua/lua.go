@@ -59,6 +59,83 @@ func (state *State) LoadChunk(filename string, source interface{}, mode Mode) er
 	return nil
 }
 
+// LoadFrozen loads the precompiled chunk without copying it, and pushes it as a
+// Lua function like LoadChunk does. The functions of the chunk are decoded
+// when they are first used and its strings refer to chunk (see
+// binary.LoadFrozen), so that a program embedding large precompiled scripts,
+// such as with go:embed, only spends memory on the functions it runs.
+//
+// The functions of the chunk are checked against the chunk limits (see
+// WithChunkLimits), and the defines of the state are substituted in them (see
+// WithDefines), as they are decoded: LoadFrozen fails if the main function
+// exceeds the limits, and creating a closure of a nested function that
+// exceeds them raises the error.
+func (state *State) LoadFrozen(chunk string) error {
+	if state.global.closed {
+		return ErrClosed
+	}
+	c, err := binary.LoadFrozen(chunk)
+	if err != nil {
+		return err
+	}
+	if state.global.frozen == nil {
+		state.global.frozen = make(map[*binary.Prototype]frozen)
+	}
+	if err := state.prepare(&c.Entry, frozen{level: 1, env: 0}); err != nil {
+		delete(state.global.frozen, &c.Entry)
+		return err
+	}
+	state.frame().push(state.closure(&c))
+	return nil
+}
+
+// frozen is what the decoded functions of frozen chunks pass on to their
+// nested functions, which are prepared when they are decoded.
+type frozen struct {
+	source string // chunk name for the errors
+	level  int    // nesting level
+	env    int    // index of the _ENV upvalue, or -1
+	err    error  // limit exceeded by the function, if any
+}
+
+// prepare checks the decoded function proto of a frozen chunk against the
+// chunk limits and substitutes the defines in it, as load does for the
+// whole chunk, and records f for its nested functions.
+func (state *State) prepare(proto *binary.Prototype, f frozen) error {
+	if proto.Source != "" {
+		f.source = proto.Source
+	}
+	f.err = checkProto(proto, f.source, f.level, state.chunkLimits())
+	if defines := state.global.config.defines; f.err == nil && f.env >= 0 && len(defines) > 0 {
+		define(proto, defines, f.env)
+	}
+	state.global.frozen[proto] = f
+	return f.err
+}
+
+// thaw returns the nested function at index of parent, decoding and
+// preparing it first if parent is a function of a frozen chunk. It raises
+// the error of a function that exceeds the chunk limits.
+func (state *State) thaw(parent *binary.Prototype, index int) *binary.Prototype {
+	f, ok := state.global.frozen[parent]
+	if !ok {
+		return parent.Proto(index)
+	}
+	child := &parent.Protos[index]
+	if child.IsFrozen() {
+		parent.Proto(index)
+		env := -1
+		if f.env >= 0 {
+			env = upvalueEnv(child, f.env)
+		}
+		state.prepare(child, frozen{source: f.source, level: f.level + 1, env: env})
+	}
+	if err := state.global.frozen[child].err; err != nil {
+		state.panic(runtimeErr(err))
+	}
+	return child
+}
+
 // Exec loads and runs a Lua chunk returning the result (if any) or an error (if any).
 //
 // The Lua chunk may be provided via the filename of the source file, or via the
@@ -98,22 +175,23 @@ func (state *State) AtPanic(panicFn Func) Func {
 // status LUA_OK (to start a new coroutine) or LUA_YIELD (to resume a coroutine).
 //
 // See https://www.lua.org/manual/5.3/manual.html#lua_status
-func (state *State) Status() ThreadStatus { unimplemented("Status"); return ThreadError }
+func (state *State) Status() ThreadStatus {
+	switch c := state.co; {
+	case c == nil:
+	case c.status == coSuspended && c.started:
+		return ThreadYield
+	case c.err != nil:
+		return ThreadError
+	}
+	return ThreadOK
+}
 
 // Generates a Lua error, using the value at the top of the stack as the error object.
 //
 // This function does a long jump, and therefore never returns (see luaL_error).
 //
 // See https://www.lua.org/manual/5.3/manual.html#lua_error
-func (state *State) Error() int { return state.errorf("%v", state.frame().pop()) }
-
-// Destroys all objects in the given Lua state (calling the corresponding garbage-collection metamethods, if any) and
-// frees all dynamic memory used by this state. In several platforms, you may not need to call this function, because
-// all resources are naturally released when the host program ends. On the other hand, long-running programs that create
-// multiple states, such as daemons or web servers, will probably need to close states as soon as they are not needed.
-//
-// See https://www.lua.org/manual/5.3/manual.html#lua_close
-func (state *State) Close() { /* TODO */ }
+func (state *State) Error() int { return state.panic(valueErr(state.frame().pop())) }
 
 // Returns the address of the version number (a C static variable) stored in the Lua core. When called with a valid lua_State,
 // returns the address of the version used to create that state. When called with NULL, returns the address of the version
@@ -225,7 +303,7 @@ func (state *State) NewTable() { state.NewTableSize(0, 0) }
 func (state *State) Next(index int) (more bool) {
 	tbl, ok := state.get(index).(*table)
 	if !ok {
-		state.errorf("table expected")
+		state.Raise(MsgTableExpected)
 	}
 	var k, v Value
 	if k, v, more = tbl.next(state.frame().pop()); more {
@@ -264,8 +342,8 @@ func (state *State) SetMetaTableAt(index int) {
 // See https://www.lua.org/manual/5.3/manual.html#lua_gettable
 func (state *State) GetTable(index int) Type {
 	var (
-		key = state.frame().pop()
 		obj = state.get(index)
+		key = state.frame().pop()
 	)
 	val := state.gettable(obj, key, false)
 	state.frame().push(val)
@@ -281,9 +359,9 @@ func (state *State) GetTable(index int) Type {
 // See https://www.lua.org/manual/5.3/manual.html#lua_settable
 func (state *State) SetTable(index int) {
 	var (
+		obj = state.get(index)
 		val = state.frame().pop()
 		key = state.frame().pop()
-		obj = state.get(index)
 	)
 	state.settable(obj, key, val, false)
 }
@@ -325,6 +403,13 @@ func (state *State) SetField(index int, field string) {
 // See https://www.lua.org/manual/5.3/manual.html#lua_geti
 func (state *State) GetIndex(index int, entry int64) Type {
 	obj := state.get(index)
+	if tbl, ok := obj.(*table); ok {
+		// fast path: present entries do not need the metatable
+		if val := tbl.getInt(entry); !IsNone(val) {
+			state.frame().push(val)
+			return val.Type()
+		}
+	}
 	key := Int(entry)
 	val := state.gettable(obj, key, false)
 	state.frame().push(val)
@@ -351,8 +436,8 @@ func (state *State) SetIndex(index int, entry int64) {
 // See https://www.lua.org/manual/5.3/manual.html#lua_rawget
 func (state *State) RawGet(index int) Type {
 	var (
-		key = state.frame().pop()
 		obj = state.get(index)
+		key = state.frame().pop()
 	)
 	val := state.gettable(obj, key, true)
 	state.frame().push(val)
@@ -364,9 +449,9 @@ func (state *State) RawGet(index int) Type {
 // See https://www.lua.org/manual/5.3/manual.html#lua_rawset
 func (state *State) RawSet(index int) {
 	var (
+		obj = state.get(index)
 		val = state.frame().pop()
 		key = state.frame().pop()
-		obj = state.get(index)
 	)
 	state.settable(obj, key, val, true)
 }
@@ -415,12 +500,35 @@ func (state *State) RawGetIndex(index, entry int) Type {
 func (state *State) RawSetIndex(index, entry int) {
 	tbl, ok := state.get(index).(*table)
 	if !ok {
-		state.errorf("table expected")
+		state.Raise(MsgTableExpected)
 		return
 	}
 	tbl.setInt(int64(entry), state.Pop())
 }
 
+// SetList pops n values from the stack and stores them in the table at the
+// given index at the keys first, first+1, ..., first+n-1, in the order they
+// were pushed. The assignments are raw.
+//
+// SetList is the batch counterpart of RawSetIndex for filling large arrays,
+// such as data decoded by the host, without a constructor: the array part is
+// grown once per batch, so a table can be streamed in batches of any size.
+func (state *State) SetList(index int, first int64, n int) {
+	tbl, ok := state.get(index).(*table)
+	if !ok {
+		state.Raise(MsgTableExpected)
+		return
+	}
+	vs := state.frame().popN(n)
+	if first < 1 || first > int64(MaxSize-n) {
+		for i, v := range vs {
+			tbl.setInt(first+int64(i), v)
+		}
+		return
+	}
+	tbl.setList(int(first-1), vs)
+}
+
 // Pushes onto the stack the value t[k], where t is the table at the given index and
 // k is the pointer p represented as a light userdata.
 //
@@ -487,9 +595,26 @@ func (state *State) RawEqual(i1, i2 int) bool {
 //
 // See https://www.lua.org/manual/5.3/manual.html#lua_pcall
 func (state *State) PCall(args, rets, msgh int) (err error) {
+	if state.global.closed {
+		return ErrClosed
+	}
+	defer state.watch()()
+	state.global.pcalls++
+	defer func() { state.global.pcalls-- }()
+	defer func(msgh Value) { state.msgh = msgh }(state.msgh)
+	if state.msgh = nil; msgh != 0 {
+		state.msgh = state.get(msgh)
+	}
 	defer func(err *error) {
 		if r := recover(); r != nil {
+			if _, ok := r.(killed); ok {
+				panic(r) // the coroutine is being closed
+			}
 			if e, ok := r.(error); ok {
+				if h := state.msgh; h != nil { // raised before entering a frame
+					state.msgh = nil
+					e = state.callHandler(h, e)
+				}
 				*err = e
 			}
 		}
@@ -512,6 +637,7 @@ func (state *State) PCall(args, rets, msgh int) (err error) {
 // Note that the code above is balanced: at its end, the stack is back to its original configuration.
 // This is considered good programming practice.
 func (state *State) Call(args, rets int) {
+	state.checkClosed()
 	//checkNumStack(state, argN + 1)
 	//checkResults(state, argN, retN)
 	var (
@@ -524,7 +650,7 @@ func (state *State) Call(args, rets int) {
 
 	if !ok {
 		if !tryMetaCall(state, value, funcID, args, rets) {
-			state.errorf("attempt to call a %s value @ %d (%T)\n%v\n", value.Type(), funcID, value, state.frame().locals)
+			state.Raise(MsgCall, state.typeName(value))
 		}
 	} else {
 		state.call(&Frame{closure: c, fnID: funcID, rets: rets})
@@ -568,6 +694,7 @@ func (state *State) Require(module string, loader Func, global bool) {
 		state.Call(1, 1)             // call 'loader' to open module
 		state.PushIndex(-1)          // make copy of module (call result)
 		state.SetField(-3, module)   // LOADED[modname] = module
+		state.global.opened = append(state.global.opened, module)
 	}
 
 	state.Remove(-2) // remove LOADED table
@@ -584,14 +711,13 @@ func (state *State) Preload(module string, loader Func) {
 	state.Logf("preload %q", module)
 
 	state.GetSubTable(RegistryIndex, PreloadKey)
-	state.GetField(-1, module) // PRELOAD
+	state.GetField(-1, module) // PRELOAD[module]
 
 	if !Truth(state.get(-1)) { // package not already preloaded?
-		state.Pop()                  // remove field
 		state.PushClosure(loader, 0) // push loader
-		state.SetField(-2, module)   // PRELOAD[module] = loader
+		state.SetField(-3, module)   // PRELOAD[module] = loader
 	}
-	state.Remove(-2) // remove PRELOAD table
+	state.PopN(2) // remove field and PRELOAD table
 }
 
 func (state *State) Main(args ...string) error {
@@ -0,0 +1,157 @@
+// Package luajson converts between JSON and Lua values.
+//
+// JSON arrays become sequences and objects become tables with string keys;
+// numbers become integers when they are integral and fit, floats otherwise.
+// In the other direction a table whose keys are 1..n becomes an array and any
+// other table an object, which must have string or number keys.
+package luajson
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/Azure/golua/lua"
+)
+
+// maxDepth limits the nesting of tables converted to JSON, which also stops
+// conversion of cyclic tables.
+const maxDepth = 64
+
+// Unmarshal decodes the JSON value data into v, keeping numbers as json.Number
+// so that integers become Lua integers when pushed.
+func Unmarshal(data []byte, v *interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+// Push pushes the value v decoded by Unmarshal: arrays become sequences and
+// objects become tables with string keys.
+func Push(state *lua.State, v interface{}) {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			state.Push(i)
+		} else {
+			f, _ := v.Float64()
+			state.Push(f)
+		}
+	case []interface{}:
+		state.NewTableSize(len(v), 0)
+		for i, elem := range v {
+			Push(state, elem)
+			state.RawSetIndex(-2, i+1)
+		}
+	case map[string]interface{}:
+		state.NewTableSize(0, len(v))
+		for key, elem := range v {
+			Push(state, elem)
+			state.SetField(-2, key)
+		}
+	default: // nil, bool or string
+		state.Push(v)
+	}
+}
+
+// ToGo converts the Lua value at index to a value that can be encoded to
+// JSON. A table whose keys are 1..n becomes an array; any other table becomes
+// an object, and must have string or number keys.
+func ToGo(state *lua.State, index int) (interface{}, error) {
+	return toValue(state, index, 0)
+}
+
+// Decode decodes the JSON value data and pushes it.
+func Decode(state *lua.State, data []byte) error {
+	var v interface{}
+	if err := Unmarshal(data, &v); err != nil {
+		return err
+	}
+	Push(state, v)
+	return nil
+}
+
+// Encode returns the JSON encoding of the Lua value at index.
+func Encode(state *lua.State, index int) ([]byte, error) {
+	v, err := ToGo(state, index)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+func toValue(state *lua.State, index, depth int) (interface{}, error) {
+	switch state.TypeAt(index) {
+	case lua.NilType, lua.NoneType:
+		return nil, nil
+	case lua.BoolType:
+		return state.ToBool(index), nil
+	case lua.StringType:
+		return state.ToString(index), nil
+	case lua.NumberType:
+		if state.IsInt(index) {
+			return state.ToInt(index), nil
+		}
+		f := state.ToNumber(index)
+		if math.IsInf(f, 0) || math.IsNaN(f) {
+			return nil, fmt.Errorf("cannot convert %v to JSON", f)
+		}
+		return f, nil
+	case lua.TableType:
+		if depth >= maxDepth {
+			return nil, fmt.Errorf("table nested too deeply")
+		}
+		return tableValue(state, state.AbsIndex(index), depth+1)
+	}
+	return nil, fmt.Errorf("cannot convert %s to JSON", state.TypeAt(index))
+}
+
+func tableValue(state *lua.State, index, depth int) (interface{}, error) {
+	var (
+		object = make(map[string]interface{})
+		isList = true
+	)
+	state.Push(nil)
+	for state.Next(index) {
+		var key string
+		switch state.TypeAt(-2) {
+		case lua.StringType:
+			key = state.ToString(-2)
+			isList = false
+		case lua.NumberType:
+			if !state.IsInt(-2) || state.ToInt(-2) < 1 {
+				isList = false
+			}
+			// format without coercing the key on the stack, which would
+			// confuse Next.
+			state.PushIndex(-2)
+			key = state.ToString(-1)
+			state.Pop()
+		default:
+			err := fmt.Errorf("cannot convert %s key to JSON", state.TypeAt(-2))
+			state.PopN(2)
+			return nil, err
+		}
+		v, err := toValue(state, -1, depth)
+		if err != nil {
+			state.PopN(2)
+			return nil, err
+		}
+		object[key] = v
+		state.Pop()
+	}
+	if !isList || len(object) == 0 {
+		return object, nil
+	}
+	list := make([]interface{}, len(object))
+	for key, v := range object {
+		i, _ := strconv.Atoi(key)
+		if i > len(list) {
+			return object, nil
+		}
+		list[i-1] = v
+	}
+	return list, nil
+}
@@ -0,0 +1,60 @@
+package luajson
+
+import (
+	"database/sql/driver"
+	"fmt"
+
+	"github.com/Azure/golua/lua"
+)
+
+// Column holds a Lua value, typically a table, stored in a database column as
+// JSON. It implements driver.Valuer and sql.Scanner:
+//
+//	db.Exec("UPDATE players SET inventory = ? WHERE id = ?", luajson.Column{State: state, Data: inv}, id)
+//
+//	col := luajson.Column{State: state}
+//	row.Scan(&col) // col.Data is the decoded table
+type Column struct {
+	// State creates the tables of scanned values.
+	State *lua.State
+	Data  lua.Value
+}
+
+// Value implements driver.Valuer, returning the JSON encoding of the value
+// as a string.
+func (col Column) Value() (driver.Value, error) {
+	if col.State == nil {
+		return nil, fmt.Errorf("luajson: Column has no state")
+	}
+	col.State.Push(col.Data)
+	defer col.State.Pop()
+	b, err := Encode(col.State, -1)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan implements sql.Scanner, decoding JSON text; NULL scans as nil.
+func (col *Column) Scan(src interface{}) error {
+	if col.State == nil {
+		return fmt.Errorf("luajson: Column has no state")
+	}
+	var data []byte
+	switch src := src.(type) {
+	case nil:
+		col.Data = lua.Nil(1)
+		return nil
+	case []byte:
+		data = src
+	case string:
+		data = []byte(src)
+	default:
+		return fmt.Errorf("luajson: cannot scan %T into Column", src)
+	}
+	if err := Decode(col.State, data); err != nil {
+		return err
+	}
+	col.Data = col.State.Pop()
+	return nil
+}
@@ -0,0 +1,65 @@
+package luajson
+
+import (
+	"testing"
+
+	"github.com/Azure/golua/lua"
+)
+
+func TestColumn(t *testing.T) {
+	state := lua.NewState()
+	defer state.Close()
+
+	// inventory = {gold = 12, items = {"sword", "shield"}}
+	state.NewTable()
+	state.Push(12)
+	state.SetField(-2, "gold")
+	state.NewTable()
+	state.Push("sword")
+	state.RawSetIndex(-2, 1)
+	state.Push("shield")
+	state.RawSetIndex(-2, 2)
+	state.SetField(-2, "items")
+	inventory := state.Pop()
+
+	stored, err := Column{State: state, Data: inventory}.Value()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"gold":12,"items":["sword","shield"]}`; stored != want {
+		t.Errorf("Value() = %v; want %s", stored, want)
+	}
+
+	for _, src := range []interface{}{stored, []byte(stored.(string))} {
+		col := Column{State: state}
+		if err := col.Scan(src); err != nil {
+			t.Fatal(err)
+		}
+		state.Push(col.Data)
+		state.GetField(-1, "gold")
+		state.GetField(-2, "items")
+		state.RawGetIndex(-1, 2)
+		if gold, item := state.ToInt(-3), state.ToString(-1); !state.IsInt(-3) || gold != 12 || item != "shield" {
+			t.Errorf("Scan(%T): gold = %d, items[2] = %q", src, gold, item)
+		}
+		state.SetTop(0)
+	}
+
+	col := Column{State: state}
+	if err := col.Scan(nil); err != nil || col.Data != lua.Nil(1) {
+		t.Errorf("Scan(nil) = %v, %v; want nil", col.Data, err)
+	}
+	if err := col.Scan(int64(1)); err == nil || err.Error() != "luajson: cannot scan int64 into Column" {
+		t.Errorf("Scan(int64) error = %v", err)
+	}
+	if err := col.Scan("{"); err == nil {
+		t.Errorf("Scan of invalid JSON succeeded")
+	}
+	if _, err := (Column{Data: inventory}).Value(); err == nil || err.Error() != "luajson: Column has no state" {
+		t.Errorf("Value() without a state: error = %v", err)
+	}
+	fn := lua.Func(func(*lua.State) int { return 0 })
+	if _, err := (Column{State: state, Data: fn}).Value(); err == nil || err.Error() != "cannot convert function to JSON" {
+		t.Errorf("Value() of a function: error = %v", err)
+	}
+}
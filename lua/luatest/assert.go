@@ -0,0 +1,45 @@
+package luatest
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Azure/golua/lua"
+)
+
+// maxDiffs is the number of differences reported by assert_tables_equal.
+const maxDiffs = 20
+
+// RegisterAsserts registers the global assertion functions for Lua tests:
+//
+//	assert_tables_equal(actual, expected [, message])
+//
+// which raises an error listing the differences (see lua.Diff) unless actual
+// and expected are deeply equal.
+func RegisterAsserts(state *lua.State) {
+	state.Register("assert_tables_equal", lua.Func(assertTablesEqual))
+}
+
+// assert_tables_equal (actual, expected [, message])
+func assertTablesEqual(state *lua.State) int {
+	var (
+		actual   = state.CheckAny(1)
+		expected = state.CheckAny(2)
+		message  = state.OptString(3, "tables differ")
+	)
+	diffs := lua.Diff(expected, actual, lua.DiffOptions{Max: maxDiffs + 1})
+	if len(diffs) == 0 {
+		return 0
+	}
+	var b strings.Builder
+	b.WriteString(message)
+	for i, d := range diffs {
+		if i == maxDiffs {
+			b.WriteString("\n  ...")
+			break
+		}
+		b.WriteString("\n  ")
+		b.WriteString(d.String())
+	}
+	panic(fmt.Errorf("%s", b.String()))
+}
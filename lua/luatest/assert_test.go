@@ -0,0 +1,42 @@
+package luatest
+
+import (
+	"testing"
+
+	"github.com/Azure/golua/lua"
+)
+
+func TestAssertTablesEqual(t *testing.T) {
+	state := lua.NewState()
+	RegisterAsserts(state)
+	table := func(count int, name string) {
+		state.NewTable()
+		state.NewTable()
+		state.Push(count)
+		state.SetField(-2, "count")
+		state.SetIndex(-2, 1)
+		if name != "" {
+			state.Push(name)
+			state.SetField(-2, "name")
+		}
+	}
+	check := func(want string) {
+		t.Helper()
+		state.GetGlobal("assert_tables_equal")
+		state.Insert(-3)
+		err := state.PCall(2, 0, 0)
+		switch {
+		case want == "" && err != nil:
+			t.Errorf("unexpected error: %v", err)
+		case want != "" && (err == nil || err.Error() != want):
+			t.Errorf("got error %v, want %q", err, want)
+		}
+		state.SetTop(0)
+	}
+	table(1, "a")
+	table(1, "a")
+	check("")
+	table(3, "")
+	table(1, "a")
+	check("tables differ\n  changed [1].count: 1 -> 3\n  removed name: \"a\"")
+}
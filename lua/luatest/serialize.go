@@ -0,0 +1,169 @@
+package luatest
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/golua/lua"
+)
+
+// Serialize returns a deterministic, human-readable representation of value
+// in Lua constructor syntax. Table entries are printed one per line: first the
+// sequence 1..n without keys, then the other entries sorted by key (booleans,
+// then numbers, then strings, then other values). Tables reached again while
+// printing themselves are shown as <cycle>, and functions, threads and userdata
+// by their type only, so that the output does not depend on addresses.
+func Serialize(value lua.Value) string {
+	var b strings.Builder
+	serialize(&b, value, "", make(map[lua.Table]bool))
+	return b.String()
+}
+
+func serialize(b *strings.Builder, value lua.Value, indent string, seen map[lua.Table]bool) {
+	switch v := value.(type) {
+	case nil, lua.Nil:
+		b.WriteString("nil")
+	case lua.Bool:
+		b.WriteString(strconv.FormatBool(bool(v)))
+	case lua.Int:
+		b.WriteString(strconv.FormatInt(int64(v), 10))
+	case lua.Float:
+		b.WriteString(formatFloat(float64(v)))
+	case lua.String:
+		b.WriteString(strconv.Quote(string(v)))
+	case lua.Table:
+		if seen[v] {
+			b.WriteString("<cycle>")
+			return
+		}
+		seen[v] = true
+		defer delete(seen, v)
+		serializeTable(b, v, indent, seen)
+	case *lua.Object:
+		fmt.Fprintf(b, "<userdata %T>", v.Value())
+	default:
+		fmt.Fprintf(b, "<%s>", value.Type())
+	}
+}
+
+func serializeTable(b *strings.Builder, t lua.Table, indent string, seen map[lua.Table]bool) {
+	var (
+		keys []lua.Value
+		n    int64
+	)
+	for ; !lua.IsNone(t.Index(lua.Int(n + 1))); n++ {
+	}
+	t.ForEach(func(k, _ lua.Value) {
+		if i, ok := k.(lua.Int); !ok || i < 1 || int64(i) > n {
+			keys = append(keys, k)
+		}
+	})
+	if n == 0 && len(keys) == 0 {
+		b.WriteString("{}")
+		return
+	}
+	sortKeys(keys)
+	inner := indent + "  "
+	b.WriteString("{\n")
+	for i := int64(1); i <= n; i++ {
+		b.WriteString(inner)
+		serialize(b, t.Index(lua.Int(i)), inner, seen)
+		b.WriteString(",\n")
+	}
+	for _, k := range keys {
+		b.WriteString(inner)
+		if s, ok := k.(lua.String); ok && isName(string(s)) {
+			b.WriteString(string(s))
+		} else {
+			b.WriteString("[")
+			serialize(b, k, inner, seen)
+			b.WriteString("]")
+		}
+		b.WriteString(" = ")
+		serialize(b, t.Index(k), inner, seen)
+		b.WriteString(",\n")
+	}
+	b.WriteString(indent)
+	b.WriteString("}")
+}
+
+// sortKeys sorts table keys in serialization order.
+func sortKeys(keys []lua.Value) {
+	rank := func(v lua.Value) int {
+		switch v.(type) {
+		case lua.Bool:
+			return 0
+		case lua.Int, lua.Float:
+			return 1
+		case lua.String:
+			return 2
+		}
+		return 3
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if ra, rb := rank(a), rank(b); ra != rb {
+			return ra < rb
+		}
+		switch a := a.(type) {
+		case lua.Bool:
+			return !bool(a) && bool(b.(lua.Bool))
+		case lua.Int, lua.Float:
+			return toFloat(a) < toFloat(b)
+		case lua.String:
+			return a < b.(lua.String)
+		}
+		return Serialize(a) < Serialize(b)
+	})
+}
+
+func toFloat(v lua.Value) float64 {
+	if i, ok := v.(lua.Int); ok {
+		return float64(i)
+	}
+	return float64(v.(lua.Float))
+}
+
+// formatFloat formats f so that it reads back as the same float.
+func formatFloat(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "0/0"
+	case math.IsInf(f, 1):
+		return "1/0"
+	case math.IsInf(f, -1):
+		return "-1/0"
+	}
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
+}
+
+var keywords = map[string]bool{
+	"and": true, "break": true, "do": true, "else": true, "elseif": true,
+	"end": true, "false": true, "for": true, "function": true, "goto": true,
+	"if": true, "in": true, "local": true, "nil": true, "not": true,
+	"or": true, "repeat": true, "return": true, "then": true, "true": true,
+	"until": true, "while": true,
+}
+
+// isName reports whether s is a Lua name, which may be used as a field.
+func isName(s string) bool {
+	if s == "" || keywords[s] {
+		return false
+	}
+	for i, c := range s {
+		switch {
+		case c == '_', c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
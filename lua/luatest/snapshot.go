@@ -0,0 +1,169 @@
+// Package luatest provides helpers for testing Lua code from Go tests.
+package luatest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Azure/golua/lua"
+)
+
+// Snapshots compares values against golden files committed with the tests.
+//
+// Lua tests use it through the snapshot library (see Open):
+//
+//	snapshot.match("inventory", build_inventory())
+//
+// The value is serialized with Serialize and compared with the contents of the
+// golden file Dir/name.snap. When Update is set, typically from a test flag,
+// golden files are rewritten instead:
+//
+//	var update = flag.Bool("update", false, "update snapshot files")
+//
+//	snaps := &luatest.Snapshots{T: t, Dir: "testdata", Update: *update}
+//	state.Require("snapshot", snaps.Open, true)
+type Snapshots struct {
+	// T receives the failures of mismatched snapshots.
+	T testing.TB
+	// Dir is the directory of golden files.
+	Dir string
+	// Update rewrites golden files instead of comparing with them.
+	Update bool
+}
+
+// Open opens the snapshot library backed by the snapshots.
+func (snaps *Snapshots) Open(state *lua.State) int {
+	// Create 'snapshot' table.
+	var snapshotFuncs = map[string]lua.Func{
+		"match":     lua.Func(snaps.match),
+		"serialize": lua.Func(snapshotSerialize),
+	}
+	state.NewTableSize(0, len(snapshotFuncs))
+	state.SetFuncs(snapshotFuncs, 0)
+
+	// Return 'snapshot' table.
+	return 1
+}
+
+// Match compares the serialized value got with the golden file of the named
+// snapshot, or rewrites the file if snaps.Update is set. On a mismatch it
+// reports a line diff (see LineDiff) to snaps.T and returns it.
+func (snaps *Snapshots) Match(name, got string) (diff string, err error) {
+	if !validName(name) {
+		return "", fmt.Errorf("invalid snapshot name '%s'", name)
+	}
+	path := filepath.Join(snaps.Dir, name+".snap")
+	if snaps.Update {
+		if err := os.MkdirAll(snaps.Dir, 0777); err != nil {
+			return "", err
+		}
+		return "", ioutil.WriteFile(path, []byte(got+"\n"), 0666)
+	}
+	want, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", fmt.Errorf("no snapshot '%s' (run with updating enabled to create it)", name)
+	}
+	if err != nil {
+		return "", err
+	}
+	if diff = LineDiff(strings.TrimSuffix(string(want), "\n"), got); diff != "" && snaps.T != nil {
+		snaps.T.Helper()
+		snaps.T.Errorf("snapshot %s mismatch (-want +got):\n%s", name, diff)
+	}
+	return diff, nil
+}
+
+// snapshot.match (name, value)
+//
+// Compares value with the named snapshot. Returns true if they match;
+// otherwise returns false plus a diff of the serialized values.
+func (snaps *Snapshots) match(state *lua.State) int {
+	var (
+		name  = state.CheckString(1)
+		value = state.CheckAny(2)
+	)
+	diff, err := snaps.Match(name, Serialize(value))
+	if err != nil {
+		panic(err)
+	}
+	if diff != "" {
+		state.Push(false)
+		state.Push(diff)
+		return 2
+	}
+	state.Push(true)
+	return 1
+}
+
+// snapshot.serialize (value)
+//
+// Returns value serialized as it is stored in snapshots.
+func snapshotSerialize(state *lua.State) int {
+	state.Push(Serialize(state.CheckAny(1)))
+	return 1
+}
+
+// validName reports whether name may be used as a snapshot file name.
+func validName(name string) bool {
+	if name == "" || name[0] == '.' {
+		return false
+	}
+	for _, c := range name {
+		switch {
+		case c == '_', c == '-', c == '.':
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// LineDiff returns a line diff from want to got, with removed lines prefixed by
+// "-", added lines by "+" and common lines by a space, or "" if they are
+// equal.
+func LineDiff(want, got string) string {
+	if want == got {
+		return ""
+	}
+	var (
+		a = strings.Split(want, "\n")
+		b = strings.Split(got, "\n")
+	)
+	// lcs[i][j] is the length of the longest common subsequence of a[i:], b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	var out strings.Builder
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			out.WriteString("  " + a[i] + "\n")
+			i, j = i+1, j+1
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			out.WriteString("- " + a[i] + "\n")
+			i++
+		default:
+			out.WriteString("+ " + b[j] + "\n")
+			j++
+		}
+	}
+	return out.String()
+}
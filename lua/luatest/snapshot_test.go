@@ -0,0 +1,53 @@
+package luatest
+
+import (
+	"testing"
+
+	"github.com/Azure/golua/lua"
+)
+
+func TestSerialize(t *testing.T) {
+	state := lua.NewState()
+	state.NewTable()
+	state.Push("a")
+	state.SetIndex(-2, 1)
+	state.Push(2.0)
+	state.SetIndex(-2, 2)
+	state.Push(true)
+	state.SetField(-2, "end")
+	state.NewTable()
+	state.SetField(-2, "empty")
+	state.PushIndex(-1)
+	state.SetField(-2, "self")
+	state.Push("x")
+	state.SetIndex(-2, 10)
+
+	const want = `{
+  "a",
+  2.0,
+  [10] = "x",
+  empty = {},
+  ["end"] = true,
+  self = <cycle>,
+}`
+	if got := Serialize(state.CheckAny(-1)); got != want {
+		t.Errorf("Serialize:\n%s", LineDiff(want, got))
+	}
+}
+
+func TestMatch(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := (&Snapshots{Dir: dir, Update: true}).Match("value", "{\n  1,\n}"); err != nil {
+		t.Fatal(err)
+	}
+	snaps := &Snapshots{Dir: dir}
+	if diff, err := snaps.Match("value", "{\n  1,\n}"); diff != "" || err != nil {
+		t.Errorf("Match of same value = %q, %v", diff, err)
+	}
+	if diff, _ := snaps.Match("value", "{\n  2,\n}"); diff != "  {\n-   1,\n+   2,\n  }\n" {
+		t.Errorf("Match of other value = %q", diff)
+	}
+	if _, err := snaps.Match("../value", ""); err == nil {
+		t.Errorf("Match with invalid name succeeded")
+	}
+}
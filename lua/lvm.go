@@ -537,6 +537,9 @@ func (vm *v53) returns(instr vm.Instr) {
 		a = instr.A()
 		b = instr.B()
 	)
+	if vm.thread().hook != nil {
+		vm.thread().callHook(vm.thread().frame(), HookRets, -1)
+	}
 	if want := vm.thread().frame().rets; want != 0 {
 		b--
 		var (
@@ -546,13 +549,18 @@ func (vm *v53) returns(instr vm.Instr) {
 		if b == -1 {
 			retc = vm.thread().frame().gettop() - a
 		}
+		if want == MultRets {
+			rets = make([]Value, 0, retc)
+		} else {
+			rets = make([]Value, 0, want)
+		}
 		switch {
 		case want > retc: // # wanted > # returned
 			for i := a; i < a+retc; i++ {
 				rets = append(rets, vm.thread().frame().get(i))
 			}
 			for retc < want {
-				rets = append(rets, None)
+				rets = append(rets, Nil(1))
 				retc++
 			}
 
@@ -630,13 +638,13 @@ func (vm *v53) forprep(instr vm.Instr) {
 	// Try for values as numbers.
 	var f1, f2, f3 Float
 	if f1, ok1 = toFloat(init); !ok1 {
-		vm.thread().errorf("'for' init must be a number")
+		vm.thread().Raise(MsgForInit)
 	}
 	if f2, ok2 = toFloat(upto); !ok2 {
-		vm.thread().errorf("'for' limit must be a number")
+		vm.thread().Raise(MsgForLimit)
 	}
 	if f3, ok3 = toFloat(step); !ok3 {
-		vm.thread().errorf("'for' step must be a number")
+		vm.thread().Raise(MsgForStep)
 	}
 
 	vm.thread().frame().set(instr.A(), f1-f3)
@@ -716,15 +724,17 @@ func (vm *v53) setlist(instr vm.Instr) {
 		b = instr.B()
 		c = instr.C()
 	)
+	fr := vm.thread().frame()
 	if b == 0 {
-		b = vm.thread().frame().gettop() - a - 1
+		b = fr.gettop() - a - 1
 	}
-	o := (c - 1) * FieldsPerFlush
-	t := vm.thread().frame().get(a).(*table)
-	for i := 1; i <= b; i++ {
-		t.setInt(int64(o+i), vm.thread().frame().get(a+i))
+	if c == 0 { // block number is in the next EXTRAARG
+		c = fr.step(1).AX()
 	}
-	vm.thread().frame().popN(b)
+	o := (c - 1) * FieldsPerFlush
+	t := fr.get(a).(*table)
+	t.setList(o, fr.locals[a+1:a+1+b])
+	fr.settop(fr.gettop() - b)
 }
 
 // CLOSURE: Create a closure of a function prototype.
@@ -753,9 +763,17 @@ func (vm *v53) vararg(instr vm.Instr) {
 		a = instr.A()
 		b = instr.B()
 	)
+	if b == 0 { // top is set to the last vararg
+		// Fast path: the varargs are stack values already, so they are
+		// appended at once.
+		fr := vm.thread().frame()
+		fr.settop(a)
+		fr.locals = append(fr.locals, fr.vararg...)
+		return
+	}
 	for i, v := range vm.thread().frame().varargs(b - 1) {
-		if v == nil {
-			v = None
+		if v == nil { // missing varargs are nil
+			v = Nil(1)
 		}
 		vm.thread().frame().set(a+i, v)
 	}
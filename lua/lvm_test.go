@@ -0,0 +1,499 @@
+package lua
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Azure/golua/lua/binary"
+	"github.com/Azure/golua/lua/vm"
+)
+
+func iABC(op vm.Code, a, b, c int) uint32 {
+	return uint32(op) | uint32(a)<<6 | uint32(c)<<14 | uint32(b)<<23
+}
+
+func iABx(op vm.Code, a, bx int) uint32 {
+	return uint32(op) | uint32(a)<<6 | uint32(bx)<<14
+}
+
+func iAsBx(op vm.Code, a, sbx int) uint32 {
+	return iABx(op, a, sbx+vm.MaxArgSBX)
+}
+
+// rk returns the RK operand of constant index k.
+func rk(k int) int { return k | 1<<8 }
+
+// loadProto pushes a main chunk closure built from the instructions and constants.
+func loadProto(state *State, code []uint32, consts ...interface{}) error {
+	proto := binary.Prototype{
+		Source:   "@test.lua",
+		Vararg:   1,
+		Stack:    16,
+		Code:     code,
+		Consts:   consts,
+		UpValues: []binary.UpValue{{InStack: 1, Index: 0}},
+		UpNames:  []string{"_ENV"},
+	}
+	for i := range code {
+		proto.PcLnTab = append(proto.PcLnTab, uint32(i+1))
+	}
+	return state.LoadChunk("test", binary.Dump(&proto, false), 0)
+}
+
+// methodLoop is the chunk for
+//
+//	local obj, n = ...
+//	for i = 1, n do obj:update(i) end
+var methodLoop = []uint32{
+	iABC(vm.VARARG, 0, 3, 0),   // R0, R1 := ...
+	iABx(vm.LOADK, 2, 0),       // R2 := 1
+	iABC(vm.MOVE, 3, 1, 0),     // R3 := n
+	iABx(vm.LOADK, 4, 0),       // R4 := 1
+	iAsBx(vm.FORPREP, 2, 3),    // goto FORLOOP
+	iABC(vm.SELF, 6, 0, rk(1)), // R7 := obj; R6 := obj.update
+	iABC(vm.MOVE, 8, 5, 0),     // R8 := i
+	iABC(vm.CALL, 6, 3, 1),     // obj:update(i)
+	iAsBx(vm.FORLOOP, 2, -4),   // loop
+	iABC(vm.RETURN, 0, 1, 0),   // return
+}
+
+type counter struct{ n, sum int64 }
+
+func (c *counter) Methods() map[string]Func {
+	return map[string]Func{
+		"update": func(state *State) int {
+			c := state.ToUserData(1).Value().(counterValue).value()
+			c.n++
+			c.sum += state.CheckInt(2)
+			return 0
+		},
+	}
+}
+
+func (c *counter) value() *counter { return c }
+
+type counterValue interface{ value() *counter }
+
+// indexedCounter also resolves fields with Index.
+type indexedCounter struct{ *counter }
+
+func (c indexedCounter) Index(key Value) (Value, error) {
+	if key == String("n") {
+		return Int(c.n), nil
+	}
+	return Nil(1), nil
+}
+
+func runMethodLoop(tb testing.TB, state *State, obj interface{}, n int) {
+	if err := loadProto(state, methodLoop, int64(1), "update"); err != nil {
+		tb.Fatal(err)
+	}
+	state.Push(obj)
+	state.Push(n)
+	if err := state.PCall(2, 0, 0); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+func TestMethodCall(t *testing.T) {
+	state := NewState()
+	defer state.Close()
+
+	c := new(counter)
+	runMethodLoop(t, state, c, 10)
+	if c.n != 10 || c.sum != 55 {
+		t.Errorf("n, sum = %d, %d; want 10, 55", c.n, c.sum)
+	}
+
+	// Index still resolves keys that are not methods.
+	ic := indexedCounter{new(counter)}
+	runMethodLoop(t, state, ic, 10)
+	if got := state.gettable(valueOf(state, ic), String("n"), false); got != Int(10) {
+		t.Errorf("c.n = %v; want 10", got)
+	}
+}
+
+func BenchmarkMethodCall(b *testing.B) {
+	state := NewState()
+	defer state.Close()
+
+	b.ReportAllocs()
+	runMethodLoop(b, state, new(counter), b.N)
+}
+
+// runProto runs the chunk with the given arguments and returns its results.
+func runProto(tb testing.TB, state *State, code []uint32, consts []interface{}, args ...interface{}) []Value {
+	tb.Helper()
+	if err := loadProto(state, code, consts...); err != nil {
+		tb.Fatal(err)
+	}
+	for _, arg := range args {
+		state.Push(arg)
+	}
+	if err := state.PCall(len(args), MultRets, 0); err != nil {
+		tb.Fatal(err)
+	}
+	defer state.SetTop(0)
+	return append([]Value(nil), state.frame().locals...)
+}
+
+func typesOf(values []Value) (types []Type) {
+	for _, v := range values {
+		types = append(types, v.Type())
+	}
+	return types
+}
+
+func TestVarargs(t *testing.T) {
+	state := NewState()
+	defer state.Close()
+
+	state.Register("count", func(state *State) int {
+		state.Push(state.Top())
+		return 1
+	})
+	// Registers above the call hold stale values; count must only see the varargs.
+	count := []uint32{
+		iABx(vm.LOADK, 0, 1),
+		iABx(vm.LOADK, 1, 1),
+		iABx(vm.LOADK, 2, 1),
+		iABx(vm.LOADK, 3, 1),
+		iABx(vm.LOADK, 4, 1),
+		iABC(vm.GETTABUP, 1, 0, rk(0)),
+		iABC(vm.VARARG, 2, 0, 0),
+		iABC(vm.CALL, 1, 0, 2),
+		iABC(vm.RETURN, 1, 2, 0),
+	}
+	consts := []interface{}{"count", int64(1)}
+	if got := runProto(t, state, count, consts); got[0] != Int(0) {
+		t.Errorf("count() = %v; want 0", got[0])
+	}
+	if got := runProto(t, state, count, consts, nil, nil); got[0] != Int(2) {
+		t.Errorf("count(nil, nil) = %v; want 2", got[0])
+	}
+
+	// return ...
+	got := typesOf(runProto(t, state, []uint32{iABC(vm.VARARG, 0, 0, 0), iABC(vm.RETURN, 0, 0, 0)}, nil, nil, 1, nil))
+	if want := []Type{NilType, NumberType, NilType}; !reflect.DeepEqual(got, want) {
+		t.Errorf("return ... = %v; want %v", got, want)
+	}
+	// local a, b, c = ...; return a, b, c
+	got = typesOf(runProto(t, state, []uint32{iABC(vm.VARARG, 0, 4, 0), iABC(vm.RETURN, 0, 4, 0)}, nil, "x"))
+	if want := []Type{StringType, NilType, NilType}; !reflect.DeepEqual(got, want) {
+		t.Errorf("local a, b, c = ... = %v; want %v", got, want)
+	}
+}
+
+// wideSelect is the chunk for
+//
+//	local n = ...
+//	return select(n, ...)
+var wideSelect = []uint32{
+	iABC(vm.VARARG, 0, 2, 0),       // R0 := ...
+	iABC(vm.GETTABUP, 1, 0, rk(0)), // R1 := select
+	iABC(vm.MOVE, 2, 0, 0),         // R2 := n
+	iABC(vm.VARARG, 3, 0, 0),       // R3, ... := ...
+	iABC(vm.CALL, 1, 0, 0),         // R1, ... := select(n, ...)
+	iABC(vm.RETURN, 1, 0, 0),       // return R1, ...
+}
+
+func registerSelect(state *State) {
+	state.Register("select", func(state *State) int {
+		return state.Top() - int(state.CheckInt(1))
+	})
+}
+
+func TestWideVarargs(t *testing.T) {
+	state := NewState()
+	defer state.Close()
+	registerSelect(state)
+
+	const n = 1000
+	args := []interface{}{int64(n - 1)}
+	for i := 1; i < n; i++ {
+		args = append(args, int64(i))
+	}
+	got := runProto(t, state, wideSelect, []interface{}{"select"}, args...)
+	if len(got) != 2 || got[0] != Int(n-2) || got[1] != Int(n-1) {
+		t.Errorf("select(%d, ...) = %v; want [%d %d]", n-1, got, n-2, n-1)
+	}
+	args[0] = int64(1)
+	if got := runProto(t, state, wideSelect, []interface{}{"select"}, args...); len(got) != n {
+		t.Errorf("select(1, ...) returned %d values; want %d", len(got), n)
+	}
+}
+
+func BenchmarkWideVarargs(b *testing.B) {
+	state := NewState()
+	defer state.Close()
+	registerSelect(state)
+
+	args := []interface{}{int64(1)}
+	for i := 1; i < 1000; i++ {
+		args = append(args, int64(i))
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		runProto(b, state, wideSelect, []interface{}{"select"}, args...)
+	}
+}
+
+func TestAdjustment(t *testing.T) {
+	state := NewState()
+	defer state.Close()
+
+	// f(n) returns 10, 20, ..., n*10.
+	state.Register("f", func(state *State) int {
+		n := int(state.CheckInt(1))
+		for i := 1; i <= n; i++ {
+			state.Push(i * 10)
+		}
+		return n
+	})
+	consts := []interface{}{"f", "x", int64(0), int64(1), int64(2), int64(3)}
+	call := func(a, k, c int) []uint32 { // R(a) ... := f(K(k))
+		return []uint32{
+			iABC(vm.GETTABUP, a, 0, rk(0)),
+			iABx(vm.LOADK, a+1, k),
+			iABC(vm.CALL, a, 2, c),
+		}
+	}
+	code := func(parts ...[]uint32) (code []uint32) {
+		for _, part := range parts {
+			code = append(code, part...)
+		}
+		return code
+	}
+	var tests = []struct {
+		name string
+		code []uint32
+		want []Value
+	}{
+		{
+			"local a, b, c = f(1)",
+			code(call(0, 3, 4), []uint32{iABC(vm.RETURN, 0, 4, 0)}),
+			[]Value{Int(10), Nil(1), Nil(1)},
+		},
+		{
+			"local a, b = f(3)",
+			code(call(0, 5, 3), []uint32{iABC(vm.RETURN, 0, 3, 0)}),
+			[]Value{Int(10), Int(20)},
+		},
+		{
+			"return f(3), f(2)",
+			code(call(0, 5, 2), call(1, 4, 0), []uint32{iABC(vm.RETURN, 0, 0, 0)}),
+			[]Value{Int(10), Int(10), Int(20)},
+		},
+		{
+			"return f(0), f(0)",
+			code(call(0, 2, 2), call(1, 2, 0), []uint32{iABC(vm.RETURN, 0, 0, 0)}),
+			[]Value{Nil(1)},
+		},
+		{
+			"local t = {f(3), f(2)}; return #t, t[3]",
+			code([]uint32{iABC(vm.NEWTABLE, 0, 1, 0)}, call(1, 5, 2), call(2, 4, 0), []uint32{
+				iABC(vm.SETLIST, 0, 0, 1),
+				iABC(vm.LEN, 1, 0, 0),
+				iABC(vm.GETTABLE, 2, 0, rk(5)),
+				iABC(vm.RETURN, 1, 3, 0),
+			}),
+			[]Value{Int(3), Int(20)},
+		},
+		{
+			"return f(f(2))",
+			code([]uint32{iABC(vm.GETTABUP, 0, 0, rk(0))}, call(1, 4, 0), []uint32{
+				iABC(vm.CALL, 0, 0, 0),
+				iABC(vm.RETURN, 0, 0, 0),
+			}),
+			[]Value{Int(10), Int(20), Int(30), Int(40), Int(50), Int(60), Int(70), Int(80), Int(90), Int(100)},
+		},
+		{
+			"local t = {}; local a, b = t.x, t.x; return a, b",
+			[]uint32{
+				iABC(vm.NEWTABLE, 0, 0, 0),
+				iABC(vm.GETTABLE, 1, 0, rk(1)),
+				iABC(vm.GETTABLE, 2, 0, rk(1)),
+				iABC(vm.RETURN, 1, 3, 0),
+			},
+			[]Value{Nil(1), Nil(1)},
+		},
+	}
+	for _, test := range tests {
+		if got := runProto(t, state, test.code, consts); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v; want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestShortCircuit(t *testing.T) {
+	state := NewState()
+	defer state.Close()
+
+	var (
+		and = []uint32{ // local a, b = ...; return a and b
+			iABC(vm.VARARG, 0, 3, 0),
+			iABC(vm.TESTSET, 2, 0, 0),
+			iAsBx(vm.JMP, 0, 1),
+			iABC(vm.MOVE, 2, 1, 0),
+			iABC(vm.RETURN, 2, 2, 0),
+		}
+		or = []uint32{ // local a, b = ...; return a or b
+			iABC(vm.VARARG, 0, 3, 0),
+			iABC(vm.TESTSET, 2, 0, 1),
+			iAsBx(vm.JMP, 0, 1),
+			iABC(vm.MOVE, 2, 1, 0),
+			iABC(vm.RETURN, 2, 2, 0),
+		}
+		andOr = []uint32{ // local a, b, c = ...; return a and b or c
+			iABC(vm.VARARG, 0, 4, 0),
+			iABC(vm.TEST, 0, 0, 0),
+			iAsBx(vm.JMP, 0, 2),
+			iABC(vm.TESTSET, 3, 1, 1),
+			iAsBx(vm.JMP, 0, 1),
+			iABC(vm.MOVE, 3, 2, 0),
+			iABC(vm.RETURN, 3, 2, 0),
+		}
+		not = []uint32{ // local a = ...; return not a
+			iABC(vm.VARARG, 0, 2, 0),
+			iABC(vm.NOT, 1, 0, 0),
+			iABC(vm.RETURN, 1, 2, 0),
+		}
+	)
+	var tests = []struct {
+		name string
+		code []uint32
+		args []interface{}
+		want Value
+	}{
+		{"nil and 2", and, []interface{}{nil, 2}, Nil(1)},
+		{"false and 2", and, []interface{}{false, 2}, False},
+		{"0 and 2", and, []interface{}{0, 2}, Int(2)},
+		{"'' and nil", and, []interface{}{"", nil}, Nil(1)},
+		{"nil or false", or, []interface{}{nil, false}, False},
+		{"false or nil", or, []interface{}{false, nil}, Nil(1)},
+		{"0 or 2", or, []interface{}{0, 2}, Int(0)},
+		{"1 and 2 or 3", andOr, []interface{}{1, 2, 3}, Int(2)},
+		{"1 and false or 3", andOr, []interface{}{1, false, 3}, Int(3)},
+		{"nil and 2 or 3", andOr, []interface{}{nil, 2, 3}, Int(3)},
+		{"1 and nil or false", andOr, []interface{}{1, nil, false}, False},
+		{"not nil", not, []interface{}{nil}, True},
+		{"not 0", not, []interface{}{0}, False},
+	}
+	for _, test := range tests {
+		if got := runProto(t, state, test.code, nil, test.args...); len(got) != 1 || got[0] != test.want {
+			t.Errorf("%s = %v; want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestLoadFrozen(t *testing.T) {
+	state := NewState()
+	defer state.Close()
+
+	// local f = function() return "frozen" end
+	// return f()
+	proto := binary.Prototype{
+		Source: "@frozen.lua",
+		Vararg: 1,
+		Stack:  2,
+		Code: []uint32{
+			iABx(vm.CLOSURE, 0, 0),   // R0 := closure(f)
+			iABC(vm.CALL, 0, 1, 0),   // R0, ... := R0()
+			iABC(vm.RETURN, 0, 0, 0), // return R0, ...
+		},
+		UpValues: []binary.UpValue{{InStack: 1, Index: 0}},
+		UpNames:  []string{"_ENV"},
+		Protos: []binary.Prototype{{
+			Source: "@frozen.lua",
+			SrcPos: 1,
+			EndPos: 1,
+			Stack:  2,
+			Code: []uint32{
+				iABx(vm.LOADK, 0, 0),     // R0 := "frozen"
+				iABC(vm.RETURN, 0, 2, 0), // return R0
+			},
+			Consts: []interface{}{"frozen"},
+		}},
+	}
+	chunk := string(binary.Dump(&proto, false))
+
+	if err := state.LoadFrozen(chunk[:len(chunk)-1]); err == nil {
+		t.Error("LoadFrozen succeeded with a truncated chunk")
+	}
+	if err := state.LoadFrozen(chunk); err != nil {
+		t.Fatal(err)
+	}
+	main := state.frame().get(0).(*Closure).binary
+	if n := len(main.Protos[0].Code); n != 0 {
+		t.Errorf("nested function decoded before use (%d instructions)", n)
+	}
+	if err := state.PCall(0, MultRets, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got := state.ToString(-1); got != "frozen" {
+		t.Errorf("chunk returned %q; want \"frozen\"", got)
+	}
+	if n := len(main.Protos[0].Code); n != 2 {
+		t.Errorf("nested function has %d instructions once used; want 2", n)
+	}
+}
+
+func TestLoadFrozenPrepare(t *testing.T) {
+	// local f = function() return DEBUG end
+	// return f(), DEBUG
+	proto := binary.Prototype{
+		Source: "@frozen.lua",
+		Vararg: 1,
+		Stack:  2,
+		Code: []uint32{
+			iABx(vm.CLOSURE, 0, 0),         // R0 := closure(f)
+			iABC(vm.CALL, 0, 1, 2),         // R0 := R0()
+			iABC(vm.GETTABUP, 1, 0, rk(0)), // R1 := DEBUG
+			iABC(vm.RETURN, 0, 3, 0),       // return R0, R1
+		},
+		Consts:   []interface{}{"DEBUG"},
+		UpValues: []binary.UpValue{{InStack: 1, Index: 0}},
+		UpNames:  []string{"_ENV"},
+		Protos: []binary.Prototype{{
+			Source: "@frozen.lua",
+			SrcPos: 1,
+			EndPos: 1,
+			Stack:  2,
+			Code: []uint32{
+				iABC(vm.GETTABUP, 0, 0, rk(0)), // R0 := DEBUG
+				iABC(vm.RETURN, 0, 2, 0),       // return R0
+			},
+			Consts:   []interface{}{"DEBUG"},
+			UpValues: []binary.UpValue{{InStack: 0, Index: 0}},
+			UpNames:  []string{"_ENV"},
+		}},
+	}
+	chunk := string(binary.Dump(&proto, false))
+
+	state := NewState(WithDefines(map[string]interface{}{"DEBUG": true}))
+	defer state.Close()
+	if err := state.LoadFrozen(chunk); err != nil {
+		t.Fatal(err)
+	}
+	if err := state.PCall(0, 2, 0); err != nil {
+		t.Fatal(err)
+	}
+	if !state.ToBool(-2) || !state.ToBool(-1) {
+		t.Errorf("chunk returned %s, %s; want true, true", state.ToString(-2), state.ToString(-1))
+	}
+
+	state = NewState(WithChunkLimits(ChunkLimits{Constants: 1}))
+	defer state.Close()
+	proto.Protos[0].Consts = append(proto.Protos[0].Consts, "unused")
+	if err := state.LoadFrozen(string(binary.Dump(&proto, false))); err != nil {
+		t.Fatal(err)
+	}
+	err := state.PCall(0, 2, 0)
+	if want := "frozen.lua:1: too many constants (limit is 1) in function at line 1"; err == nil || err.Error() != want {
+		t.Errorf("got error %v; want %q", err, want)
+	}
+	proto.Consts = append(proto.Consts, "unused")
+	if err := state.LoadFrozen(string(binary.Dump(&proto, false))); err == nil {
+		t.Error("LoadFrozen succeeded with a main function over the limits")
+	}
+}
@@ -0,0 +1,212 @@
+package lua
+
+import (
+	"fmt"
+)
+
+// MsgID identifies the template of an error message raised by the VM or the
+// standard library. Templates are fmt format strings; the comment of each
+// MsgID lists its arguments.
+type MsgID int
+
+const (
+	MsgBadArgument    MsgID = iota // argument position, function name, message
+	MsgTypeExpected                // expected type, actual type (message of MsgBadArgument)
+	MsgValueExpected               // (none)
+	MsgNoIntRep                    // (none)
+	MsgCall                        // type of the called value
+	MsgIndexLoop                   // (none)
+	MsgNewIndexLoop                // (none)
+	MsgNewIndexMeta                // (none)
+	MsgArith                       // metamethod, left operand type, right operand type
+	MsgCompare                     // left operand type, right operand type
+	MsgConcat                      // left operand type, right operand type
+	MsgLength                      // operand type
+	MsgModZero                     // (none)
+	MsgDivZero                     // (none)
+	MsgForInit                     // (none)
+	MsgForLimit                    // (none)
+	MsgForStep                     // (none)
+	MsgStackOverflow               // (none)
+	MsgTableExpected               // (none)
+	MsgInvalidNextKey              // key
+	MsgToStringResult              // (none)
+	MsgMetaTable                   // (none)
+	MsgProtectedMeta               // (none)
+	MsgSelectIndex                 // (none)
+	MsgToNumberBase                // (none)
+	MsgTableOrString               // (none)
+	MsgNilOrTable                  // (none)
+	MsgConcatValue                 // value type, index
+	MsgInsertPosition              // (none)
+	MsgInsertArgs                  // (none)
+	MsgUnpackResults               // (none)
+	MsgFormatNoValue               // argument position
+	MsgFormatOption                // option character
+	MsgFormatFlags                 // (none)
+	MsgFormatWidth                 // (none)
+	MsgFormatLiteral               // (none)
+	MsgTableIndexNil               // (none)
+	MsgTableIndexNaN               // (none)
+	MsgIndex                       // type of the indexed value
+	MsgRemovePosition              // (none)
+	MsgResumeActive                // (none)
+	MsgResumeDead                  // (none)
+	MsgYieldOutside                // (none)
+	MsgCloseActive                 // status of the coroutine
+	MsgChanDirection               // operation, direction of the channel
+	MsgChanClosed                  // (none)
+	MsgChanValue                   // value type, element type of the channel
+	MsgErrorHandling               // (none)
+	MsgModuleAbsent                // module name, hint
+	MsgBadSelf                     // function name, message
+	MsgReplaceValue                // type of the replacement value
+	MsgReplacePercent              // (none)
+	MsgTooManyResults              // (none)
+	MsgStringTooLarge              // (none)
+	MsgPatTooComplex               // (none)
+	MsgPatFrontier                 // (none)
+	MsgPatEndsPercent              // (none)
+	MsgPatBracket                  // (none)
+	MsgPatBalance                  // (none)
+	MsgPatTooManyCaps              // (none)
+	MsgPatCapture                  // (none)
+	MsgPatCaptureIdx               // capture index
+	MsgPatUnfinished               // (none)
+	MsgCloseClosed                 // (none)
+	MsgStackIndex                  // index
+	MsgInvalidIndex                // index
+	MsgUpValueIndex                // index
+	MsgLevelRange                  // (none)
+	MsgFuncExpected                // (none)
+	MsgNoActivation                // (none)
+	MsgInfoOption                  // option character
+	MsgNotClosure                  // (none)
+	msgCount
+)
+
+// defaultMessages holds the default template of each message.
+var defaultMessages = [msgCount]string{
+	MsgBadArgument:    "bad argument #%d to '%s' (%s)",
+	MsgTypeExpected:   "%s expected, got %s",
+	MsgValueExpected:  "value expected",
+	MsgNoIntRep:       "number has no integer representation",
+	MsgCall:           "attempt to call a %s value",
+	MsgIndexLoop:      "'__index' chain too long; possible loop",
+	MsgNewIndexLoop:   "'__newindex' chain too long; possible loop",
+	MsgNewIndexMeta:   "meta method '__newindex' not a table or function",
+	MsgArith:          "attempt to apply %s on %v %v value",
+	MsgCompare:        "attempt to compare %s with %s",
+	MsgConcat:         "attempt to apply '__concat' on %v and %v values",
+	MsgLength:         "attempt to get length of %v value",
+	MsgModZero:        "attempt to perform n%%0",
+	MsgDivZero:        "attempt to divide by zero",
+	MsgForInit:        "'for' init must be a number",
+	MsgForLimit:       "'for' limit must be a number",
+	MsgForStep:        "'for' step must be a number",
+	MsgStackOverflow:  "go: call stack overflow",
+	MsgTableExpected:  "table expected",
+	MsgInvalidNextKey: "invalid key (%v) to 'next'",
+	MsgToStringResult: "'__tostring' must return a string",
+	MsgMetaTable:      "metatable must be table or nil",
+	MsgProtectedMeta:  "cannot change a protected metatable",
+	MsgSelectIndex:    "bad argument to 'select' (index out of range)",
+	MsgToNumberBase:   "bad argument to 'tonumber' (base out of range)",
+	MsgTableOrString:  "table or string expected",
+	MsgNilOrTable:     "nil or table expected",
+	MsgConcatValue:    "invalid value (%s) at index %d in table for 'concat'",
+	MsgInsertPosition: "bad argument #2 to 'insert' (position out of bounds)",
+	MsgInsertArgs:     "wrong number of arguments to 'insert'",
+	MsgUnpackResults:  "too many results to unpack",
+	MsgFormatNoValue:  "bad argument #%d to 'format' (no value)",
+	MsgFormatOption:   "invalid option '%%%c' to 'format'",
+	MsgFormatFlags:    "invalid format (repeated flags)",
+	MsgFormatWidth:    "invalid format (width or precision too long)",
+	MsgFormatLiteral:  "value has no literal form",
+	MsgTableIndexNil:  "table index is nil",
+	MsgTableIndexNaN:  "table index is NaN",
+	MsgIndex:          "attempt to index a %s value",
+	MsgRemovePosition: "bad argument #2 to 'remove' (position out of bounds)",
+	MsgResumeActive:   "cannot resume non-suspended coroutine",
+	MsgResumeDead:     "cannot resume dead coroutine",
+	MsgYieldOutside:   "attempt to yield from outside a coroutine",
+	MsgCloseActive:    "cannot close a %s coroutine",
+	MsgChanDirection:  "cannot %s a %s-only channel",
+	MsgChanClosed:     "send on closed channel",
+	MsgChanValue:      "cannot send a %s value on a channel of %s",
+	MsgErrorHandling:  "error in error handling",
+	MsgModuleAbsent:   "module '%s' not available in this build (%s)",
+	MsgBadSelf:        "calling '%s' on bad self (%s)",
+	MsgReplaceValue:   "invalid replacement value (a %s)",
+	MsgReplacePercent: "invalid use of '%%' in replacement string",
+	MsgTooManyResults: "too many results",
+	MsgStringTooLarge: "resulting string too large",
+	MsgPatTooComplex:  "pattern too complex",
+	MsgPatFrontier:    "missing '[' after '%%f' in pattern",
+	MsgPatEndsPercent: "malformed pattern (ends with '%%')",
+	MsgPatBracket:     "malformed pattern (missing ']')",
+	MsgPatBalance:     "malformed pattern (missing arguments to '%%b')",
+	MsgPatTooManyCaps: "too many captures",
+	MsgPatCapture:     "invalid pattern capture",
+	MsgPatCaptureIdx:  "invalid capture index %%%d",
+	MsgPatUnfinished:  "unfinished capture",
+	MsgCloseClosed:    "close of closed channel",
+	MsgStackIndex:     "unacceptable index (%d)",
+	MsgInvalidIndex:   "invalid index (%d)",
+	MsgUpValueIndex:   "upvalue index too large (%d)",
+	MsgLevelRange:     "level out of range",
+	MsgFuncExpected:   "function expected",
+	MsgNoActivation:   "no activation record",
+	MsgInfoOption:     "invalid option: %c",
+	MsgNotClosure:     "closure expected",
+}
+
+// Messages is a catalog of error message templates overriding the defaults;
+// see WithMessages.
+type Messages map[MsgID]string
+
+// DefaultMessage returns the default template of the message id.
+func DefaultMessage(id MsgID) string {
+	if id < 0 || id >= msgCount {
+		return fmt.Sprintf("unknown message %d", id)
+	}
+	return defaultMessages[id]
+}
+
+// WithMessages returns an Option that overrides error message templates, for
+// example to translate the messages shown to script authors or to match the
+// exact messages of the reference implementation. Messages missing from msgs
+// keep their default template.
+//
+// A template receives the arguments listed by its MsgID, in order; use explicit
+// argument indexes such as %[2]s to reorder them.
+func WithMessages(msgs Messages) Option {
+	return func(cfg *config) {
+		if cfg.messages == nil {
+			cfg.messages = make(Messages, len(msgs))
+		}
+		for id, msg := range msgs {
+			cfg.messages[id] = msg
+		}
+	}
+}
+
+// Message returns the template of the message id used by the state.
+func (state *State) Message(id MsgID) string {
+	if msg, ok := state.global.config.messages[id]; ok {
+		return msg
+	}
+	return DefaultMessage(id)
+}
+
+// Raise raises the error message id formatted with args.
+//
+// Like Errorf, this function never returns.
+func (state *State) Raise(id MsgID, args ...interface{}) int {
+	return state.panic(state.messageErr(id, args...))
+}
+
+// messageErr returns the error message id formatted with args.
+func (state *State) messageErr(id MsgID, args ...interface{}) error {
+	return runtimeErr(fmt.Errorf(state.Message(id), args...))
+}
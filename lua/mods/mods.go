@@ -0,0 +1,363 @@
+// Package mods loads mods, sets of scripts written by third parties, into a
+// Lua state so that they cannot clobber each other's globals or modules:
+//
+//	loader := mods.NewLoader(state, mods.WithModules("events"))
+//	mod, err := loader.Load("minimap", "mods/minimap")
+//
+// Each mod runs with its own global table (its _ENV). Reads of globals that
+// the mod did not set fall back to the shared globals allowed by the loader;
+// the shared globals and the tables reached from them are read-only views, so
+// that string.format = nil fails instead of breaking every other mod, and the
+// metatables of the mod's global table and of the views are protected. Each
+// mod also has its own require
+// and package.loaded: require("util") loads util.lua from the mod's directory
+// once per mod, and only the host modules allowed with WithModules are shared.
+package mods
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Azure/golua/lua"
+)
+
+// DefaultGlobals are the global names shared with mods by default: the safe
+// functions of the base library and the string, table, math, utf8 and
+// coroutine libraries. Functions that load code or reach the host (load,
+// dofile, require) and the io, os, debug and package libraries are left out.
+//
+// The mods get versions of rawget, rawset and getmetatable that cannot reach
+// around the views: rawget and rawset refuse shared tables, and getmetatable
+// returns nil for values other than tables, whose metatables, such as the
+// metatable of strings, are shared by all mods.
+var DefaultGlobals = []string{
+	"_GOLUA", "_VERSION", "assert", "error", "getmetatable", "ipairs", "next",
+	"pairs", "pcall", "print", "rawequal", "rawget", "rawlen", "rawset",
+	"select", "setmetatable", "tonumber", "tostring", "type", "xpcall",
+	"coroutine", "math", "string", "table", "utf8",
+}
+
+// Option is an optional configuration for NewLoader.
+type Option func(*Loader)
+
+// WithGlobals returns an Option that shares the given globals with mods
+// instead of DefaultGlobals.
+func WithGlobals(names ...string) Option {
+	return func(loader *Loader) {
+		loader.globals = names
+	}
+}
+
+// WithModules returns an Option that lets mods require the given host
+// modules. They are loaded with the global require and shared by all mods,
+// as read-only views if they are tables.
+func WithModules(names ...string) Option {
+	return func(loader *Loader) {
+		for _, name := range names {
+			loader.modules[name] = true
+		}
+	}
+}
+
+// Loader loads mods into a state. Like the state, it must not be used
+// concurrently.
+type Loader struct {
+	state   *lua.State
+	globals []string
+	modules map[string]bool
+	shared  lua.Value // view of the shared globals, built on first use
+	mods    map[string]*Mod
+	viewOf  map[lua.Value]lua.Value // read-only views of shared tables
+	views   map[lua.Value]bool      // values of viewOf
+}
+
+// NewLoader returns a loader of mods into state. The libraries whose globals
+// are shared must be open in state before the first mod is loaded.
+func NewLoader(state *lua.State, opts ...Option) *Loader {
+	loader := &Loader{
+		state:   state,
+		globals: DefaultGlobals,
+		modules: make(map[string]bool),
+		mods:    make(map[string]*Mod),
+		viewOf:  make(map[lua.Value]lua.Value),
+		views:   make(map[lua.Value]bool),
+	}
+	for _, opt := range opts {
+		opt(loader)
+	}
+	return loader
+}
+
+// Mod is a mod loaded by a Loader.
+type Mod struct {
+	Name   string // name of the mod
+	Dir    string // directory of the mod's scripts
+	loader *Loader
+	env    lua.Value // private global table
+	loaded lua.Value // private package.loaded
+}
+
+// Load creates the environment of mod name, whose scripts are in dir, and runs
+// its init.lua script in it. Mod names must be unique.
+func (loader *Loader) Load(name, dir string) (*Mod, error) {
+	if _, ok := loader.mods[name]; ok {
+		return nil, fmt.Errorf("mods: mod %q is already loaded", name)
+	}
+	var (
+		state = loader.state
+		mod   = &Mod{Name: name, Dir: dir, loader: loader}
+	)
+	state.NewTable()
+	mod.loaded = state.Pop()
+
+	// Create the mod's global table.
+	state.NewTable()
+	state.PushIndex(-1)
+	state.SetField(-2, "_G")
+	state.Push(lua.Func(mod.require))
+	state.SetField(-2, "require")
+
+	// Create its 'package' table.
+	state.NewTableSize(0, 2)
+	state.Push(mod.loaded)
+	state.SetField(-2, "loaded")
+	state.Push(strings.Join(mod.paths("?"), ";"))
+	state.SetField(-2, "path")
+	state.SetField(-2, "package")
+
+	// Fall back to the shared globals.
+	state.NewTableSize(0, 2)
+	state.Push(loader.sharedGlobals())
+	state.SetField(-2, "__index")
+	state.Push(false)
+	state.SetField(-2, "__metatable")
+	state.SetMetaTableAt(-2)
+	mod.env = state.Pop()
+
+	loader.mods[name] = mod
+	if err := mod.Exec("init.lua"); err != nil {
+		delete(loader.mods, name)
+		return nil, err
+	}
+	return mod, nil
+}
+
+// Mod returns the loaded mod name, or nil.
+func (loader *Loader) Mod(name string) *Mod { return loader.mods[name] }
+
+// sharedGlobals returns the view of the globals shared with the mods.
+func (loader *Loader) sharedGlobals() lua.Value {
+	if loader.shared != nil {
+		return loader.shared
+	}
+	state := loader.state
+	state.NewTableSize(0, len(loader.globals))
+	for _, name := range loader.globals {
+		state.GetGlobal(name)
+		switch name {
+		case "rawget", "rawset":
+			state.Push(loader.refuseViews(name, state.Pop()))
+		case "getmetatable":
+			state.Push(loader.tableMetaTables(state.Pop()))
+		}
+		state.SetField(-2, name)
+	}
+	loader.shared = loader.view(state, state.Pop(), "_G")
+	return loader.shared
+}
+
+// refuseViews returns a version of the function fn, named name, that raises
+// an argument error if its first argument is a shared table.
+func (loader *Loader) refuseViews(name string, fn lua.Value) lua.Func {
+	return func(state *lua.State) int {
+		if state.PushIndex(1); loader.views[state.Pop()] {
+			state.ArgError(1, "shared table")
+		}
+		state.Push(fn)
+		state.Insert(1)
+		state.Call(state.Top()-1, lua.MultRets)
+		return state.Top()
+	}
+}
+
+// tableMetaTables returns a version of getmetatable fn that returns nil for
+// values other than tables.
+func (loader *Loader) tableMetaTables(fn lua.Value) lua.Func {
+	return func(state *lua.State) int {
+		if state.CheckAny(1); state.TypeAt(1) != lua.TableType {
+			state.Push(nil)
+			return 1
+		}
+		state.Push(fn)
+		state.PushIndex(1)
+		state.Call(1, 1)
+		return 1
+	}
+}
+
+// Exec runs the script file of the mod, relative to its directory, in the
+// mod's environment.
+func (mod *Mod) Exec(file string) error {
+	state := mod.loader.state
+	if err := mod.load(filepath.Join(mod.Dir, file)); err != nil {
+		return err
+	}
+	return state.PCall(0, 0, 0)
+}
+
+// PushEnv pushes the global table of the mod onto the stack, for the host to
+// read the functions and values the mod defines.
+func (mod *Mod) PushEnv() { mod.loader.state.Push(mod.env) }
+
+// load loads the script file and pushes it with the mod's global table as its
+// _ENV upvalue.
+func (mod *Mod) load(file string) error {
+	state := mod.loader.state
+	if err := state.LoadChunk(file, nil, lua.BinaryMode|lua.TextMode); err != nil {
+		return err
+	}
+	state.Push(mod.env)
+	state.SetUpValue(-2, 1)
+	return nil
+}
+
+// paths returns the files where the module name is searched, in order.
+func (mod *Mod) paths(name string) []string {
+	name = strings.Replace(name, ".", string(filepath.Separator), -1)
+	return []string{
+		filepath.Join(mod.Dir, name+".lua"),
+		filepath.Join(mod.Dir, name, "init.lua"),
+	}
+}
+
+// require (modname)
+//
+// Loads the module modname of the mod, or one of the host modules shared with
+// mods, once per mod. The mod's modules are searched in its directory as
+// modname.lua and modname/init.lua; they run in the mod's environment and are also
+// stored in the global package.loaded under the name "mod.modname" for the
+// host to find.
+func (mod *Mod) require(state *lua.State) int {
+	name := state.CheckString(1)
+	state.SetTop(1)
+	state.Push(mod.loaded)
+	if state.GetField(2, name); !state.IsNoneOrNil(-1) {
+		return 1 // module is already loaded
+	}
+	state.Pop()
+
+	if mod.loader.modules[name] {
+		state.GetGlobal("require")
+		state.Push(name)
+		state.Call(1, 1)
+		if state.TypeAt(-1) == lua.TableType {
+			state.Push(mod.loader.view(state, state.Pop(), name))
+		}
+		state.PushIndex(-1)
+		state.SetField(2, name)
+		return 1
+	}
+
+	if strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		state.ArgError(1, fmt.Sprintf("invalid module name '%s'", name))
+	}
+	var (
+		file string
+		errs strings.Builder
+	)
+	for _, path := range mod.paths(name) {
+		if _, err := os.Stat(path); err == nil {
+			file = path
+			break
+		}
+		fmt.Fprintf(&errs, "\n\tno file '%s'", path)
+	}
+	if file == "" {
+		state.Errorf("module '%s' not found in mod '%s':%s", name, mod.Name, errs.String())
+	}
+	if err := mod.load(file); err != nil {
+		state.Errorf("error loading module '%s' from file '%s':\n\t%v", name, file, err)
+	}
+	state.Push(name)
+	state.Push(file)
+	state.Call(2, 1)
+	if state.IsNil(-1) {
+		state.Pop()
+		state.Push(true)
+	}
+
+	// LOADED["mod.name"] = module
+	state.GetField(lua.RegistryIndex, lua.LoadedKey)
+	state.PushIndex(-2)
+	state.SetField(-2, mod.Name+"."+name)
+	state.Pop()
+
+	state.PushIndex(-1)
+	state.SetField(2, name)
+	return 1
+}
+
+// view returns the read-only view of the table t, creating it in state on
+// first use; name is reported by attempts to modify it. The tables read
+// through a view are returned as views too, so that nested tables are
+// read-only as well.
+func (loader *Loader) view(state *lua.State, t lua.Value, name string) lua.Value {
+	if v, ok := loader.viewOf[t]; ok {
+		return v
+	}
+	state.NewTable()         // view
+	state.NewTableSize(0, 4) // metatable of view
+	state.Push(lua.Func(func(state *lua.State) int {
+		state.Push(t)
+		state.PushIndex(2)
+		state.RawGet(-2)
+		loader.viewValue(state, name, 2)
+		return 1
+	}))
+	state.SetField(-2, "__index")
+	state.Push(lua.Func(func(state *lua.State) int {
+		return state.Errorf("attempt to modify shared table '%s'", name)
+	}))
+	state.SetField(-2, "__newindex")
+	state.Push(lua.Func(func(state *lua.State) int {
+		state.Push(lua.Func(func(state *lua.State) int {
+			state.SetTop(2)
+			state.Push(t)
+			state.Replace(1)
+			if !state.Next(1) {
+				state.Push(nil)
+				return 1
+			}
+			loader.viewValue(state, name, -2)
+			return 2
+		}))
+		state.PushIndex(1)
+		state.Push(nil)
+		return 3
+	}))
+	state.SetField(-2, "__pairs")
+	state.Push(false)
+	state.SetField(-2, "__metatable")
+	state.SetMetaTableAt(-2)
+	v := state.Pop()
+	loader.viewOf[t] = v
+	loader.views[v] = true
+	return v
+}
+
+// viewValue replaces the value at the top of the stack, read from the shared
+// table name with the key at index, with its view if it is a table.
+func (loader *Loader) viewValue(state *lua.State, name string, index int) {
+	if state.TypeAt(-1) != lua.TableType {
+		return
+	}
+	state.PushIndex(index)
+	key := state.ToString(-1) // of a copy, which ToString may convert
+	state.Pop()
+	if name != "_G" {
+		key = name + "." + key
+	}
+	state.Push(loader.view(state, state.Pop(), key))
+}
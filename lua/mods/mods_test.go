@@ -0,0 +1,228 @@
+package mods
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Azure/golua/lua"
+	"github.com/Azure/golua/lua/binary"
+	"github.com/Azure/golua/lua/vm"
+	"github.com/Azure/golua/std"
+)
+
+func iABC(op vm.Code, a, b, c int) uint32 {
+	return uint32(op) | uint32(a)<<6 | uint32(c)<<14 | uint32(b)<<23
+}
+
+func iABx(op vm.Code, a, bx int) uint32 {
+	return uint32(op) | uint32(a)<<6 | uint32(bx)<<14
+}
+
+func rk(k int) int { return k | 1<<8 }
+
+// writeChunk writes a precompiled main chunk to dir/file.
+func writeChunk(t *testing.T, dir, file string, code []uint32, consts ...interface{}) {
+	t.Helper()
+	proto := binary.Prototype{
+		Source:   "@" + file,
+		Vararg:   1,
+		Stack:    8,
+		Code:     code,
+		Consts:   consts,
+		UpValues: []binary.UpValue{{InStack: 1, Index: 0}},
+		UpNames:  []string{"_ENV"},
+	}
+	for i := range code {
+		proto.PcLnTab = append(proto.PcLnTab, uint32(i+1))
+	}
+	path := filepath.Join(dir, file)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, binary.Dump(&proto, false), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mods")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// x = "a"; util = require("util")
+	writeChunk(t, dir, "a/init.lua", []uint32{
+		iABC(vm.SETTABUP, 0, rk(0), rk(1)),
+		iABC(vm.GETTABUP, 0, 0, rk(2)),
+		iABx(vm.LOADK, 1, 3),
+		iABC(vm.CALL, 0, 2, 2),
+		iABC(vm.SETTABUP, 0, rk(3), 0),
+		iABC(vm.RETURN, 0, 1, 0),
+	}, "x", "a", "require", "util")
+	// return {name = ...}
+	writeChunk(t, dir, "a/util.lua", []uint32{
+		iABC(vm.NEWTABLE, 0, 0, 1),
+		iABC(vm.VARARG, 1, 2, 0),
+		iABC(vm.SETTABLE, 0, rk(0), 1),
+		iABC(vm.RETURN, 0, 2, 0),
+	}, "name")
+	// y = x
+	writeChunk(t, dir, "b/init.lua", []uint32{
+		iABC(vm.GETTABUP, 0, 0, rk(0)),
+		iABC(vm.SETTABUP, 0, rk(1), 0),
+		iABC(vm.RETURN, 0, 1, 0),
+	}, "x", "y")
+	// string.x = 1
+	writeChunk(t, dir, "c/init.lua", []uint32{
+		iABC(vm.GETTABUP, 0, 0, rk(0)),
+		iABC(vm.SETTABLE, 0, rk(1), rk(2)),
+		iABC(vm.RETURN, 0, 1, 0),
+	}, "string", "x", int64(1))
+
+	state := lua.NewState()
+	defer state.Close()
+	std.Open(state)
+	loader := NewLoader(state)
+
+	a, err := loader.Load("a", filepath.Join(dir, "a"))
+	if err != nil {
+		t.Fatalf("Load(a): %v", err)
+	}
+	a.PushEnv()
+	if state.GetField(-1, "x"); state.ToString(-1) != "a" {
+		t.Errorf("a: x = %v; want a", state.ToString(-1))
+	}
+	if state.GetField(-2, "util"); state.GetField(-1, "name") != lua.StringType || state.ToString(-1) != "util" {
+		t.Errorf("a: util.name = %v; want util", state.ToString(-1))
+	}
+	state.SetTop(0)
+	if state.GetGlobal("x"); !state.IsNoneOrNil(-1) {
+		t.Errorf("mod a set global x")
+	}
+	state.GetGlobal("package")
+	state.GetField(-1, "loaded")
+	if state.GetField(-1, "a.util") != lua.TableType {
+		t.Errorf("package.loaded['a.util'] is not the module")
+	}
+	state.SetTop(0)
+
+	b, err := loader.Load("b", filepath.Join(dir, "b"))
+	if err != nil {
+		t.Fatalf("Load(b): %v", err)
+	}
+	b.PushEnv()
+	if state.GetField(-1, "y"); !state.IsNoneOrNil(-1) {
+		t.Errorf("mod b sees the globals of mod a")
+	}
+	state.SetTop(0)
+
+	if _, err := loader.Load("c", filepath.Join(dir, "c")); err == nil || !strings.Contains(err.Error(), "attempt to modify shared table 'string'") {
+		t.Errorf("Load(c) = %v; want shared table error", err)
+	}
+	if _, err := loader.Load("a", filepath.Join(dir, "a")); err == nil {
+		t.Errorf("loading mod a twice succeeded")
+	}
+}
+
+func TestLoaderIsolation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mods")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"a", "b"} {
+		writeChunk(t, dir, name+"/init.lua", []uint32{iABC(vm.RETURN, 0, 1, 0)})
+	}
+
+	state := lua.NewState()
+	defer state.Close()
+	std.Open(state)
+	loader := NewLoader(state)
+	a, err := loader.Load("a", filepath.Join(dir, "a"))
+	if err != nil {
+		t.Fatalf("Load(a): %v", err)
+	}
+	b, err := loader.Load("b", filepath.Join(dir, "b"))
+	if err != nil {
+		t.Fatalf("Load(b): %v", err)
+	}
+
+	// call calls the global fn of mod a with args, which may be globals of
+	// mod a given by name as "$name", and returns its error.
+	call := func(fn string, args ...interface{}) error {
+		defer state.SetTop(0)
+		a.PushEnv()
+		state.GetField(-1, fn)
+		for _, arg := range args {
+			if name, ok := arg.(string); ok && strings.HasPrefix(name, "$") {
+				state.GetField(1, name[1:])
+			} else {
+				state.Push(arg)
+			}
+		}
+		return state.PCall(len(args), 1, 0)
+	}
+	for _, attack := range []struct {
+		fn   string
+		args []interface{}
+	}{
+		{"rawset", []interface{}{"$string", "len", int64(1)}},
+		{"rawset", []interface{}{"$_GOLUA", "version", "x"}},
+		{"setmetatable", []interface{}{"$_G", nil}},
+		{"setmetatable", []interface{}{"$string", nil}},
+	} {
+		if err := call(attack.fn, attack.args...); err == nil {
+			t.Errorf("%s%v succeeded", attack.fn, attack.args)
+		}
+	}
+	if err := call("rawget", "$string", "len"); err == nil {
+		t.Errorf("rawget(string, 'len') succeeded")
+	}
+
+	// The metatables of the mod's globals and of strings are out of reach.
+	for _, arg := range []interface{}{"$_G", "$string", ""} {
+		a.PushEnv()
+		state.GetField(-1, "getmetatable")
+		if arg == "" {
+			state.Push(arg)
+		} else {
+			state.GetField(1, arg.(string)[1:])
+		}
+		state.Call(1, 1)
+		if state.ToBool(-1) {
+			t.Errorf("getmetatable(%v) = %v", arg, state.TypeAt(-1))
+		}
+		state.SetTop(0)
+	}
+
+	// Nested shared tables are read-only too.
+	state.Push(lua.Func(func(state *lua.State) int {
+		a.PushEnv()
+		state.GetField(-1, "_GOLUA")
+		state.GetField(-1, "limits")
+		state.Push(int64(0))
+		state.SetField(-2, "maxstack")
+		return 0
+	}))
+	if err := state.PCall(0, 0, 0); err == nil || !strings.Contains(err.Error(), "'_GOLUA.limits'") {
+		t.Errorf("_GOLUA.limits.maxstack = 0: %v", err)
+	}
+
+	// Mod b still sees the shared globals unchanged.
+	b.PushEnv()
+	if state.GetField(-1, "string"); state.GetField(-1, "len") != lua.FuncType {
+		t.Errorf("b: string.len is a %v", state.TypeAt(-1))
+	}
+	state.SetTop(0)
+	b.PushEnv()
+	state.GetField(-1, "_GOLUA")
+	state.GetField(-1, "limits")
+	if state.GetField(-1, "maxstack"); state.ToInt(-1) == 0 {
+		t.Errorf("b: _GOLUA.limits.maxstack = 0")
+	}
+	state.SetTop(0)
+}
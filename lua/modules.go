@@ -0,0 +1,118 @@
+package lua
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/Azure/golua/lua/vm"
+)
+
+// goModules holds the modules registered with RegisterModule.
+var goModules = struct {
+	sync.RWMutex
+	opens map[string]Func
+}{opens: make(map[string]Func)}
+
+// RegisterModule registers open as the opener of the Go module name, such as
+// "mygame.net", in every state: require(name) calls open with name and caches
+// the module it returns in package.loaded, as it does for the modules of
+// package.preload, which it looks into first. Go packages that implement Lua
+// modules usually register them from an init function.
+//
+// If RegisterModule is called twice with the same name or if open is nil, it
+// panics.
+func RegisterModule(name string, open Func) {
+	goModules.Lock()
+	defer goModules.Unlock()
+	if open == nil {
+		panic("lua: RegisterModule opener is nil")
+	}
+	if _, dup := goModules.opens[name]; dup {
+		panic("lua: RegisterModule called twice for module " + name)
+	}
+	goModules.opens[name] = open
+}
+
+// RegisteredModule returns the opener of the Go module name registered with
+// RegisterModule, and whether there is one.
+func RegisteredModule(name string) (Func, bool) {
+	goModules.RLock()
+	defer goModules.RUnlock()
+	open, ok := goModules.opens[name]
+	return open, ok
+}
+
+// stdModules holds the hints of the libraries of the Lua standard library,
+// which every state knows of even when they are not opened in it.
+var stdModules = map[string]string{
+	"coroutine": stdHint("coroutine", "coro"),
+	"debug":     stdHint("debug", "debug"),
+	"io":        stdHint("io", "io"),
+	"math":      stdHint("math", "math"),
+	"os":        stdHint("os", "os"),
+	"package":   stdHint("package", "pkg"),
+	"string":    stdHint("string", "str"),
+	"table":     stdHint("table", "table"),
+	"utf8":      stdHint("utf8", "utf8"),
+}
+
+func stdHint(name, pkg string) string {
+	return fmt.Sprintf("open the standard libraries with std.Open, or this one with state.Require(%q, %s.Open, true)", name, pkg)
+}
+
+// DeclareModule declares the module name, which scripts may expect although
+// this state may not provide it, such as a library that is only linked into
+// some builds of the host. Until the module is in package.loaded, scripts
+// that index it as a global or require it fail with
+//
+//	module 'net' not available in this build (hint)
+//
+// instead of an error about a nil value, where hint tells how to make the
+// module available. The libraries of the Lua standard library are declared
+// in every state.
+func (state *State) DeclareModule(name, hint string) {
+	if state.global.modules == nil {
+		state.global.modules = make(map[string]string)
+	}
+	state.global.modules[name] = hint
+}
+
+// AbsentModule returns the error for using the module name if it is declared
+// (see DeclareModule) and not in package.loaded, and nil otherwise.
+func (state *State) AbsentModule(name string) error {
+	hint, ok := state.global.modules[name]
+	if !ok {
+		if hint, ok = stdModules[name]; !ok {
+			return nil
+		}
+	}
+	if loaded, ok := state.global.registry.get(String(LoadedKey)).(*table); ok && Truth(loaded.get(String(name))) {
+		return nil
+	}
+	return state.messageErr(MsgModuleAbsent, name, hint)
+}
+
+// Opened returns the names of the libraries opened with Require, in the
+// order they were opened, such as "_G", "package" and "coroutine" after
+// std.Open.
+func (state *State) Opened() []string {
+	return append([]string(nil), state.global.opened...)
+}
+
+// indexErr returns the error for indexing object, which is not indexable. When
+// the running Lua function indexes a global that is an absent module, it is
+// the error of AbsentModule.
+func (state *State) indexErr(object Value) error {
+	fr := state.frame()
+	if _, ok := object.(Nil); ok && fr.closure != nil && fr.closure.isLua() && fr.pc > 0 {
+		switch instr := fr.code(fr.pc - 1); instr.Code() {
+		case vm.GETTABLE, vm.SELF:
+			if name, what := objname(fr.closure.binary, fr.pc-1, instr.B()); what == "global" {
+				if err := state.AbsentModule(name); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return state.messageErr(MsgIndex, state.typeName(object))
+}
@@ -0,0 +1,114 @@
+package lua
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Azure/golua/lua/binary"
+	"github.com/Azure/golua/lua/vm"
+)
+
+func TestAbsentModule(t *testing.T) {
+	state := NewState()
+	defer state.Close()
+	state.DeclareModule("net", "link the net package")
+
+	// return name.field
+	index := func(name string) error {
+		code := []uint32{
+			iABC(vm.GETTABUP, 0, 0, rk(0)),
+			iABC(vm.GETTABLE, 0, 0, rk(1)),
+			iABC(vm.RETURN, 0, 2, 0),
+		}
+		if err := loadProto(state, code, name, "field"); err != nil {
+			t.Fatal(err)
+		}
+		defer state.SetTop(0)
+		return state.PCall(0, 1, 0)
+	}
+	var tests = []struct {
+		name string
+		want string
+	}{
+		{"io", "module 'io' not available in this build (open the standard libraries with std.Open"},
+		{"net", "module 'net' not available in this build (link the net package)"},
+		{"foo", "attempt to index a nil value"},
+	}
+	for _, test := range tests {
+		if err := index(test.name); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s.field: error = %v; want %q", test.name, err, test.want)
+		}
+	}
+
+	state.Require("io", func(state *State) int { state.NewTable(); return 1 }, true)
+	state.Pop()
+	if err := index("io"); err != nil {
+		t.Errorf("io.field after Require: %v", err)
+	}
+	if err := state.AbsentModule("io"); err != nil {
+		t.Errorf("AbsentModule(io) after Require = %v", err)
+	}
+	if got := state.Opened(); !reflect.DeepEqual(got, []string{"io"}) {
+		t.Errorf("Opened() = %q; want [io]", got)
+	}
+}
+
+func TestLoadModule(t *testing.T) {
+	state := NewState()
+	defer state.Close()
+
+	// local function on_tick(a, b) end
+	// local function on_event(...) end
+	// return {on_tick = on_tick, on_event = on_event, on_load = 5}
+	fn := func(params, vararg byte, line uint32) binary.Prototype {
+		return binary.Prototype{SrcPos: line, Params: params, Vararg: vararg, Stack: 2,
+			Code: []uint32{iABC(vm.RETURN, 0, 1, 0)}, PcLnTab: []uint32{line}}
+	}
+	chunk := binary.Dump(&binary.Prototype{
+		Source: "@mod.lua",
+		Vararg: 1,
+		Stack:  4,
+		Code: []uint32{
+			iABC(vm.NEWTABLE, 0, 0, 3),
+			iABx(vm.CLOSURE, 1, 0),
+			iABC(vm.SETTABLE, 0, rk(0), 1),
+			iABx(vm.CLOSURE, 1, 1),
+			iABC(vm.SETTABLE, 0, rk(1), 1),
+			iABC(vm.SETTABLE, 0, rk(2), rk(3)),
+			iABC(vm.RETURN, 0, 2, 0),
+		},
+		Consts:   []interface{}{"on_tick", "on_event", "on_load", int64(5)},
+		UpValues: []binary.UpValue{{InStack: 1, Index: 0}},
+		UpNames:  []string{"_ENV"},
+		Protos:   []binary.Prototype{fn(2, 0, 1), fn(1, 1, 2)},
+		PcLnTab:  []uint32{3, 3, 3, 3, 3, 3, 3},
+	}, false)
+
+	ok := Contract{"on_tick": 2, "on_event": 3}
+	if err := state.LoadModule("mod.lua", chunk, ok); err != nil {
+		t.Fatal(err)
+	}
+	if state.Top() != 1 || state.TypeAt(-1) != TableType {
+		t.Fatalf("want the module table pushed, got %d values", state.Top())
+	}
+	state.Pop()
+
+	bad := Contract{"on_tick": 1, "on_event": AnyArity, "on_load": 0, "on_save": 1}
+	err := state.LoadModule("mod.lua", chunk, bad)
+	cerr, isContract := err.(*ContractError)
+	if !isContract {
+		t.Fatalf("want a contract error, got %v", err)
+	}
+	want := []string{
+		"'on_load' is a number, not a function",
+		"missing function 'on_save'",
+		"function 'on_tick' (line 1) takes 2 parameters, want 1",
+	}
+	if !reflect.DeepEqual(cerr.Problems, want) {
+		t.Errorf("problems:\n%q\nwant:\n%q", cerr.Problems, want)
+	}
+	if state.Top() != 0 {
+		t.Errorf("want nothing pushed, got %d values", state.Top())
+	}
+}
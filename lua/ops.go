@@ -1,7 +1,6 @@
 package lua
 
 import (
-	"fmt"
 	"math"
 	"strings"
 
@@ -98,8 +97,8 @@ func (state *State) compare(op Op, x, y Value, raw bool) bool {
 				return true
 			}
 		case *Object:
-			if y, ok := y.(*Object); ok {
-				return x.data == y.data
+			if y, ok := y.(*Object); ok && x.data == y.data {
+				return true
 			}
 		case *table:
 			if y, ok := y.(*table); ok && (x == y) {
@@ -169,7 +168,7 @@ func (state *State) compare(op Op, x, y Value, raw bool) bool {
 	case OpLe: // '<='
 		switch x := x.(type) {
 		case String:
-			// x (string) <= y (string)
+			// x (string) <= y (string), byte-wise regardless of the locale
 			if y, ok := y.(String); ok {
 				return x <= y
 			}
@@ -216,7 +215,7 @@ func (state *State) compare(op Op, x, y Value, raw bool) bool {
 	case OpLt: // '<'
 		switch x := x.(type) {
 		case String:
-			// x (string) < y (string)
+			// x (string) < y (string), byte-wise regardless of the locale
 			if y, ok := y.(String); ok {
 				return x < y
 			}
@@ -355,7 +354,7 @@ func (state *State) arith(op Op, x, y Value) Value {
 			m, _ := toInteger(x)
 			n, _ := toInteger(y)
 			if n == 0 {
-				panic(fmt.Errorf("attempt to perform n%%0"))
+				state.Raise(MsgModZero)
 			}
 			if n == -1 {
 				return Int(0)
@@ -369,21 +368,24 @@ func (state *State) arith(op Op, x, y Value) Value {
 		if n1, ok := toFloat(x); ok {
 			if n2, ok := toFloat(y); ok {
 				r := math.Mod(float64(n1), float64(n2))
+				if r*float64(n2) < 0 {
+					r += float64(n2) // the result has the sign of the divisor
+				}
 				return Float(r)
 			}
 		}
 		// try __mod
 		event = metaMod
 
-	case OpQuo: // '/'
+	case OpQuo: // '//'
 		if isInteger(x) && isInteger(y) {
 			m, _ := toInteger(x)
 			n, _ := toInteger(y)
 			if n == 0 {
-				panic(fmt.Errorf("attempt to divide by zero"))
+				state.Raise(MsgDivZero)
 			}
 			if n == -1 {
-				return m
+				return -m // avoid overflow with 0x80000...//-1
 			}
 			q := m / n
 			if (m^n) < 0 && m%n != 0 {
@@ -400,7 +402,7 @@ func (state *State) arith(op Op, x, y Value) Value {
 		// try __idiv
 		event = metaIdiv
 
-	case OpDiv: // '//'
+	case OpDiv: // '/'
 		if n1, ok := toFloat(x); ok {
 			if n2, ok := toFloat(y); ok {
 				return n1 / n2
@@ -487,6 +489,13 @@ func (state *State) arith(op Op, x, y Value) Value {
 	// try metamethod event
 	val, err := tryMetaBinary(state, x, y, event)
 	if err != nil {
+		if op >= OpOr && op <= OpNot { // bitwise on numbers without integer representation?
+			if _, ok := toNumber(x); ok {
+				if _, ok := toNumber(y); ok {
+					state.Raise(MsgNoIntRep)
+				}
+			}
+		}
 		panic(runtimeErr(err))
 	}
 	return val
@@ -589,9 +598,7 @@ func toInteger(v Value) (Int, bool) {
 	if num, ok := toNumber(v); ok {
 		switch num := num.(type) {
 		case Float:
-			if float64(int64(num)) == float64(num) {
-				return Int(num), true
-			}
+			return floatToInt(num)
 		case Int:
 			return num, true
 		}
@@ -599,6 +606,15 @@ func toInteger(v Value) (Int, bool) {
 	return Int(0), false
 }
 
+// floatToInt converts a float with an exact integer representation to an
+// integer, without relying on the conversion of out of range floats.
+func floatToInt(f Float) (Int, bool) {
+	if f >= -(1<<63) && f < 1<<63 && f == Float(math.Trunc(float64(f))) {
+		return Int(f), true
+	}
+	return Int(0), false
+}
+
 // toFloat converts a value to a float.
 //
 // Returns the float and true if successful; otherwise 0 and false.
@@ -673,16 +689,18 @@ func str2float(str string) (Float, bool) {
 	return Float(f64), ok
 }
 
+// shiftLeft shifts x left by y bits, or right if y is negative; vacant bits
+// are filled with zeros.
 func shiftLeft(x, y Int) Int {
-	if y >= 0 {
+	switch {
+	case y <= -64 || y >= 64:
+		return 0
+	case y < 0:
+		return Int(uint64(x) >> uint64(-y))
+	default:
 		return x << uint64(y)
 	}
-	return shiftRight(x, -y)
 }
 
-func shiftRight(x, y Int) Int {
-	if y >= 0 {
-		return Int(uint64(x) >> uint64(y))
-	}
-	return shiftLeft(x, -y)
-}
+// shiftRight shifts x right by y bits, or left if y is negative.
+func shiftRight(x, y Int) Int { return shiftLeft(x, -y) }
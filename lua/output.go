@@ -0,0 +1,33 @@
+package lua
+
+import (
+	"io"
+	"os"
+)
+
+// SetOutput sets the writer used for the standard output of scripts, that is
+// by print, io.write and io.stdout. It defaults to os.Stdout.
+//
+// Giving each state its own writer keeps the output of different scripts
+// apart instead of interleaving it on the process standard output.
+func (state *State) SetOutput(w io.Writer) { state.global.stdout = w }
+
+// SetErrorOutput sets the writer used for the error output of scripts, that is
+// by io.stderr. It defaults to os.Stderr.
+func (state *State) SetErrorOutput(w io.Writer) { state.global.stderr = w }
+
+// Output returns the writer set by SetOutput.
+func (state *State) Output() io.Writer {
+	if w := state.global.stdout; w != nil {
+		return w
+	}
+	return os.Stdout
+}
+
+// ErrorOutput returns the writer set by SetErrorOutput.
+func (state *State) ErrorOutput() io.Writer {
+	if w := state.global.stderr; w != nil {
+		return w
+	}
+	return os.Stderr
+}
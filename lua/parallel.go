@@ -0,0 +1,109 @@
+package lua
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/Azure/golua/lua/binary"
+)
+
+// MapResult is the outcome of ParallelMap for one input.
+type MapResult struct {
+	Value interface{} // first result of the call, converted to Go
+	Err   error       // error raised by the call, if any
+}
+
+// ParallelMap calls the same function with each of inputs as argument, on
+// states of pool running in parallel goroutines, and returns the results in
+// the order of inputs. The function is the main function of protoOrSource: a
+// *binary.Prototype, or the source or binary chunk in a string or []byte,
+// which is compiled once and loaded on every state it runs on; it receives its
+// input as its argument, such as with local x = ..., and its first result is
+// converted to Go.
+//
+// Inputs are pushed as with State.Push, and results convert to Go as with
+// State.ToGoValue.
+//
+// A failed call only fails its input. ParallelMap returns an error, and no
+// results, if the function cannot be compiled or no state can be checked out
+// of pool.
+func ParallelMap(pool *Pool, protoOrSource interface{}, inputs []interface{}) ([]MapResult, error) {
+	chunk, err := compileOnce(pool, protoOrSource)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		results = make([]MapResult, len(inputs))
+		next    = make(chan int)
+		wg      sync.WaitGroup
+	)
+	workers := cap(pool.slots)
+	if workers > len(inputs) {
+		workers = len(inputs)
+	}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			state, err := pool.Get(context.Background())
+			if err == nil {
+				defer pool.Put(state)
+				err = state.LoadChunk("=(map)", chunk, BinaryMode)
+			}
+			for i := range next {
+				if err != nil {
+					results[i].Err = err
+					continue
+				}
+				results[i] = state.mapCall(inputs[i])
+			}
+		}()
+	}
+	for i := range inputs {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return results, nil
+}
+
+// compileOnce returns the binary chunk of protoOrSource, compiling source
+// text on a state of pool.
+func compileOnce(pool *Pool, protoOrSource interface{}) ([]byte, error) {
+	var src []byte
+	switch v := protoOrSource.(type) {
+	case *binary.Prototype:
+		return binary.Dump(v, false), nil
+	case string:
+		src = []byte(v)
+	case []byte:
+		src = v
+	default:
+		return nil, fmt.Errorf("lua: ParallelMap of %T", protoOrSource)
+	}
+	if binary.IsChunk(src) {
+		return src, nil
+	}
+	state, err := pool.Get(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	defer pool.Put(state)
+	if err := state.LoadChunk("=(map)", src, TextMode); err != nil {
+		return nil, err
+	}
+	return state.Dump(false), nil
+}
+
+// mapCall calls the function at the bottom of the stack with input and returns
+// its first result, converted to Go.
+func (state *State) mapCall(input interface{}) (result MapResult) {
+	defer state.SetTop(1)
+	state.PushIndex(1)
+	state.Push(input)
+	if result.Err = state.PCall(1, 1, 0); result.Err == nil {
+		result.Value, result.Err = toGo(state.get(-1), nil)
+	}
+	return result
+}
@@ -0,0 +1,191 @@
+package lua
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrPoolClosed is the error returned by Get once the pool is shut down, and
+// raised in the scripts interrupted by Shutdown.
+var ErrPoolClosed = errors.New("lua: pool is shut down")
+
+// Pool is a bounded set of Lua states created on demand by a factory. States
+// are checked out with Get and returned with Put, so that each goroutine gets
+// exclusive use of a state for the duration of a call.
+type Pool struct {
+	newState func() (*State, error)
+	slots    chan struct{} // one token per state that may be checked out
+	done     chan struct{} // closed by Shutdown
+	mu       sync.Mutex
+	idle     []*State
+	inUse    map[*State]struct{}
+	drained  chan struct{} // closed when no state is in use after Shutdown
+	created  int
+	closed   bool
+}
+
+// PoolStats reports the occupancy of a Pool.
+type PoolStats struct {
+	Size    int `json:"size"`    // maximum number of states
+	Created int `json:"created"` // states created so far and not discarded
+	Idle    int `json:"idle"`    // states waiting to be checked out
+	InUse   int `json:"in_use"`  // states checked out
+}
+
+// NewPool returns a pool of at most size states created by newState.
+func NewPool(size int, newState func() (*State, error)) *Pool {
+	if size <= 0 {
+		size = 1
+	}
+	return &Pool{
+		newState: newState,
+		slots:    make(chan struct{}, size),
+		done:     make(chan struct{}),
+		inUse:    make(map[*State]struct{}),
+		drained:  make(chan struct{}),
+	}
+}
+
+// Get checks out an idle state, creating one if there is none, and waits for a
+// state to be returned if all of them are in use. Get returns ctx.Err() if ctx
+// is done first, and ErrPoolClosed once the pool is shut down.
+func (pool *Pool) Get(ctx context.Context) (*State, error) {
+	select {
+	case pool.slots <- struct{}{}:
+	case <-pool.done:
+		return nil, ErrPoolClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	pool.mu.Lock()
+	if pool.closed {
+		pool.mu.Unlock()
+		<-pool.slots
+		return nil, ErrPoolClosed
+	}
+	if n := len(pool.idle); n > 0 {
+		state := pool.idle[n-1]
+		pool.idle = pool.idle[:n-1]
+		pool.inUse[state] = struct{}{}
+		pool.mu.Unlock()
+		return state, nil
+	}
+	pool.created++
+	pool.mu.Unlock()
+	state, err := pool.newState()
+	if err != nil {
+		pool.discard(nil)
+		return nil, err
+	}
+	pool.mu.Lock()
+	pool.inUse[state] = struct{}{}
+	closed := pool.closed
+	pool.mu.Unlock()
+	if closed {
+		pool.Discard(state)
+		return nil, ErrPoolClosed
+	}
+	return state, nil
+}
+
+// Put returns a state checked out with Get to the pool, clearing its stack.
+// Once the pool is shut down, Put closes the state instead.
+func (pool *Pool) Put(state *State) {
+	pool.mu.Lock()
+	if pool.closed {
+		pool.mu.Unlock()
+		pool.Discard(state)
+		return
+	}
+	state.SetTop(0)
+	delete(pool.inUse, state)
+	pool.idle = append(pool.idle, state)
+	pool.mu.Unlock()
+	<-pool.slots
+}
+
+// Discard closes a state checked out with Get instead of returning it to the
+// pool, for example because it failed in a way that left it unusable. A new
+// state is created in its place when needed.
+func (pool *Pool) Discard(state *State) {
+	state.Close()
+	pool.discard(state)
+}
+
+func (pool *Pool) discard(state *State) {
+	pool.mu.Lock()
+	pool.created--
+	delete(pool.inUse, state)
+	pool.checkDrained()
+	pool.mu.Unlock()
+	<-pool.slots
+}
+
+// Shutdown shuts the pool down gracefully: Get stops handing out states, the
+// scripts running on checked-out states are interrupted at their next
+// instruction boundary with ErrPoolClosed, and Shutdown waits for the states
+// to be returned with Put or Discard. All states are closed, which runs their
+// finalizers (see State.Close).
+//
+// The interruption cannot be caught by pcall, but Go functions run to
+// completion; if ctx is done before all states are returned, Shutdown returns
+// ctx.Err() and the remaining states are closed when they are returned.
+// Shutting down a pool that is already shut down waits for the same states.
+func (pool *Pool) Shutdown(ctx context.Context) error {
+	pool.mu.Lock()
+	if !pool.closed {
+		pool.closed = true
+		close(pool.done)
+		for state := range pool.inUse {
+			state.Interrupt(interruptShutdown)
+		}
+		pool.checkDrained()
+	}
+	idle := pool.idle
+	pool.idle = nil
+	pool.created -= len(idle)
+	pool.mu.Unlock()
+
+	for _, state := range idle {
+		state.Close()
+	}
+	select {
+	case <-pool.drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// checkDrained closes pool.drained once the pool is shut down and no state is
+// in use. It must be called with pool.mu held.
+func (pool *Pool) checkDrained() {
+	if !pool.closed || len(pool.inUse) > 0 {
+		return
+	}
+	select {
+	case <-pool.drained:
+	default:
+		close(pool.drained)
+	}
+}
+
+// interruptShutdown raises ErrPoolClosed in the running script, and again at
+// every following instruction so that pcall cannot swallow it.
+func interruptShutdown(thread *State) {
+	thread.Interrupt(interruptShutdown)
+	panic(runtimeErr(ErrPoolClosed))
+}
+
+// Stats returns the current occupancy of the pool.
+func (pool *Pool) Stats() PoolStats {
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	return PoolStats{
+		Size:    cap(pool.slots),
+		Created: pool.created,
+		Idle:    len(pool.idle),
+		InUse:   pool.created - len(pool.idle),
+	}
+}
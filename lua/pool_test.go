@@ -0,0 +1,135 @@
+package lua
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/golua/lua/binary"
+	"github.com/Azure/golua/lua/vm"
+)
+
+func TestPoolShutdown(t *testing.T) {
+	var finalized int32
+	pool := NewPool(2, func() (*State, error) {
+		state := NewState()
+		state.NewTable()
+		state.NewTable()
+		state.PushClosure(func(*State) int { atomic.AddInt32(&finalized, 1); return 0 }, 0)
+		state.SetField(-2, "__gc")
+		state.SetMetaTableAt(-2)
+		state.Pop()
+		return state, nil
+	})
+	ctx := context.Background()
+
+	idle, err := pool.Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	busy, err := pool.Get(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.Put(idle)
+
+	// busy runs "while true do end" until it is interrupted.
+	result := make(chan error, 1)
+	go func() {
+		if err := loadProto(busy, []uint32{iAsBx(vm.JMP, 0, -1)}); err != nil {
+			result <- err
+			return
+		}
+		result <- busy.PCall(0, 0, 0)
+		pool.Put(busy)
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := pool.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if err := <-result; !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("interrupted script returned %v; want ErrPoolClosed", err)
+	}
+	if finalized := atomic.LoadInt32(&finalized); finalized != 2 {
+		t.Errorf("%d finalizers ran; want 2", finalized)
+	}
+	if _, err := pool.Get(ctx); !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("Get after Shutdown = %v; want ErrPoolClosed", err)
+	}
+	if stats := pool.Stats(); stats.Created != 0 {
+		t.Errorf("Stats after Shutdown = %+v", stats)
+	}
+}
+
+func TestParallelMap(t *testing.T) {
+	// local x = ...; return {x, x * 2}
+	proto := binary.Prototype{
+		Source: "@map.lua",
+		Vararg: 1,
+		Stack:  4,
+		Consts: []interface{}{int64(2)},
+		Code: []uint32{
+			iABC(vm.VARARG, 0, 2, 0),
+			iABC(vm.NEWTABLE, 1, 2, 0),
+			iABC(vm.MOVE, 2, 0, 0),
+			iABC(vm.MUL, 3, 0, rk(0)),
+			iABC(vm.SETLIST, 1, 2, 1),
+			iABC(vm.RETURN, 1, 2, 0),
+		},
+	}
+	pool := NewPool(3, func() (*State, error) { return NewState(), nil })
+	defer pool.Shutdown(context.Background())
+
+	inputs := []interface{}{1, 2.5, "x", 4, 5, 6, 7}
+	results, err := ParallelMap(pool, &proto, inputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, result := range results {
+		if inputs[i] == "x" {
+			if result.Err == nil {
+				t.Errorf("%v: no error", inputs[i])
+			}
+			continue
+		}
+		if result.Err != nil {
+			t.Errorf("%v: %v", inputs[i], result.Err)
+			continue
+		}
+		var want []interface{}
+		switch x := inputs[i].(type) {
+		case int:
+			want = []interface{}{int64(x), int64(2 * x)}
+		case float64:
+			want = []interface{}{x, 2 * x}
+		}
+		if !reflect.DeepEqual(result.Value, want) {
+			t.Errorf("%v: got %#v; want %#v", inputs[i], result.Value, want)
+		}
+	}
+	// return {[{}] = 1}, which has no Go map equivalent, fails its input only.
+	keyed := binary.Prototype{
+		Source: "@map.lua",
+		Vararg: 1,
+		Stack:  2,
+		Consts: []interface{}{int64(1)},
+		Code: []uint32{
+			iABC(vm.NEWTABLE, 0, 0, 1),
+			iABC(vm.NEWTABLE, 1, 0, 0),
+			iABC(vm.SETTABLE, 0, 1, rk(0)),
+			iABC(vm.RETURN, 0, 2, 0),
+		},
+	}
+	results, err = ParallelMap(pool, &keyed, []interface{}{1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := results[0].Err; err == nil || err.Error() != "cannot convert a table key to a Go map key" {
+		t.Errorf("table key: error = %v", err)
+	}
+}
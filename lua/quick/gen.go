@@ -0,0 +1,138 @@
+package quick
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/Azure/golua/lua"
+)
+
+// Gen generates random Lua values. size bounds the length of strings and
+// tables and grows as a check progresses, so that early cases are small.
+type Gen interface {
+	generate(r *rand.Rand, size int) *tree
+}
+
+type genFunc func(r *rand.Rand, size int) *tree
+
+func (fn genFunc) generate(r *rand.Rand, size int) *tree { return fn(r, size) }
+
+// edgeInts are integers that commonly expose conversion and overflow bugs.
+var edgeInts = []int64{
+	0, 1, -1, 2, math.MaxInt64, math.MinInt64, math.MaxInt32, math.MinInt32,
+	1 << 53, 1<<53 + 1, -(1 << 53), math.MaxUint32,
+}
+
+// edgeFloats are floats that commonly expose conversion and formatting bugs.
+var edgeFloats = []float64{
+	0, math.Copysign(0, -1), 0.5, -0.5, 1, -1, 0.1, 1e15, 1e16, 1e100, 1e308,
+	1 << 53, 1<<53 + 2, 1 << 63, -(1 << 63), math.MaxFloat64,
+	math.SmallestNonzeroFloat64, math.Inf(1), math.Inf(-1), math.NaN(),
+}
+
+// Ints generates integers: mostly small, sometimes edge cases such as the
+// minimum and maximum integers and 2^53.
+func Ints() Gen {
+	return genFunc(func(r *rand.Rand, size int) *tree {
+		if r.Intn(4) == 0 {
+			return scalar(lua.Int(edgeInts[r.Intn(len(edgeInts))]))
+		}
+		n := int64(size*size + 1)
+		return scalar(lua.Int(r.Int63n(2*n+1) - n))
+	})
+}
+
+// Floats generates floats: mostly random ones, sometimes edge cases such as
+// -0.0, infinities, NaN and integral values beyond 2^53.
+func Floats() Gen {
+	return genFunc(func(r *rand.Rand, size int) *tree {
+		switch r.Intn(4) {
+		case 0:
+			return scalar(lua.Float(edgeFloats[r.Intn(len(edgeFloats))]))
+		case 1:
+			return scalar(lua.Float(float64(r.Intn(2*size+1) - size)))
+		}
+		return scalar(lua.Float(r.NormFloat64() * float64(size)))
+	})
+}
+
+// Numbers generates integers and floats.
+func Numbers() Gen { return OneOf(Ints(), Floats()) }
+
+// Bools generates booleans.
+func Bools() Gen {
+	return genFunc(func(r *rand.Rand, size int) *tree {
+		return scalar(lua.Bool(r.Intn(2) == 0))
+	})
+}
+
+// Strings generates byte strings mixing letters, digits, spaces, pattern
+// metacharacters, NUL, invalid UTF-8 and multi-byte characters.
+func Strings() Gen {
+	return stringsOf("abcxyzABCXYZ0123456789 \t\n^$()%.[]*+-?",
+		"\x00", "\xff", "\x80", "é", "世", "\U0001F600")
+}
+
+// PatternStrings generates strings made mostly of Lua pattern
+// metacharacters and classes, for fuzzing pattern-matching functions.
+func PatternStrings() Gen {
+	return stringsOf("^$()%.[]*+-?ab1 ",
+		"%a", "%d", "%s", "%w", "%p", "%b()", "%f[%w]", "%1", "[^a]", "[a-z]")
+}
+
+// stringsOf generates strings concatenating the bytes of chars and the pieces.
+func stringsOf(chars string, pieces ...string) Gen {
+	for i := 0; i < len(chars); i++ {
+		pieces = append(pieces, chars[i:i+1])
+	}
+	return genFunc(func(r *rand.Rand, size int) *tree {
+		var b []byte
+		for n := r.Intn(size + 1); n > 0; n-- {
+			b = append(b, pieces[r.Intn(len(pieces))]...)
+		}
+		return scalar(lua.String(b))
+	})
+}
+
+// Arrays generates sequences of values from elem.
+func Arrays(elem Gen) Gen {
+	return genFunc(func(r *rand.Rand, size int) *tree {
+		t := &tree{table: true}
+		for i, n := 1, r.Intn(size+1); i <= n; i++ {
+			t.keys = append(t.keys, scalar(lua.Int(i)))
+			t.vals = append(t.vals, elem.generate(r, size/2))
+		}
+		return t
+	})
+}
+
+// Tables generates tables with keys from key and values from value. Keys
+// must not generate nil or NaN.
+func Tables(key, value Gen) Gen {
+	return genFunc(func(r *rand.Rand, size int) *tree {
+		t := &tree{table: true}
+		for n := r.Intn(size + 1); n > 0; n-- {
+			t.keys = append(t.keys, key.generate(r, size/2))
+			t.vals = append(t.vals, value.generate(r, size/2))
+		}
+		return t
+	})
+}
+
+// OneOf generates values from one of the generators, chosen at random.
+func OneOf(gens ...Gen) Gen {
+	return genFunc(func(r *rand.Rand, size int) *tree {
+		return gens[r.Intn(len(gens))].generate(r, size)
+	})
+}
+
+// Values generates any value that can be converted to and from data formats:
+// booleans, numbers, strings, and tables of them nested up to depth levels.
+func Values(depth int) Gen {
+	leaf := OneOf(Bools(), Ints(), Floats(), Strings())
+	if depth <= 0 {
+		return leaf
+	}
+	inner := Values(depth - 1)
+	return OneOf(leaf, leaf, Arrays(inner), Tables(OneOf(Ints(), Strings()), inner))
+}
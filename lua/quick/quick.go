@@ -0,0 +1,199 @@
+// Package quick implements property-based testing of Lua bindings and
+// libraries: it checks that a property holds for randomly generated Lua
+// values and, when it does not, shrinks the failing value to a minimal one.
+//
+//	err := quick.Check(state, quick.Values(2), func(state *lua.State, v lua.Value) error {
+//		// e.g. check that decode(encode(v)) is equal to v
+//	}, nil)
+package quick
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/Azure/golua/lua"
+	"github.com/Azure/golua/lua/luatest"
+)
+
+// Config configures Check.
+type Config struct {
+	// Count is the number of values checked; 100 by default.
+	Count int
+	// MaxSize is the size of the last values generated; 50 by default.
+	MaxSize int
+	// MaxShrinks bounds the number of shrinking steps; 1000 by default.
+	MaxShrinks int
+	// Seed seeds the generator; by default the current time is used.
+	Seed int64
+}
+
+// Failure is the error returned by Check when the property does not hold.
+type Failure struct {
+	// Value is the smallest failing value found.
+	Value lua.Value
+	// Err is the error returned by the property for Value.
+	Err error
+	// Seed reproduces the check when set in Config.Seed.
+	Seed int64
+	// Count is the number of values checked until the first failure.
+	Count int
+	// Shrinks is the number of successful shrinking steps.
+	Shrinks int
+}
+
+func (f *Failure) Error() string {
+	return fmt.Sprintf("property failed after %d values (seed %d, %d shrinks): %v\nvalue: %s",
+		f.Count, f.Seed, f.Shrinks, f.Err, luatest.Serialize(f.Value))
+}
+
+// Check checks that prop returns nil for values generated by gen. It returns
+// a *Failure for the smallest failing value found, or nil if the property
+// held. A panic in prop counts as a failure.
+func Check(state *lua.State, gen Gen, prop func(*lua.State, lua.Value) error, cfg *Config) error {
+	var c Config
+	if cfg != nil {
+		c = *cfg
+	}
+	if c.Count <= 0 {
+		c.Count = 100
+	}
+	if c.MaxSize <= 0 {
+		c.MaxSize = 50
+	}
+	if c.MaxShrinks <= 0 {
+		c.MaxShrinks = 1000
+	}
+	if c.Seed == 0 {
+		c.Seed = time.Now().UnixNano()
+	}
+	r := rand.New(rand.NewSource(c.Seed))
+	for i := 0; i < c.Count; i++ {
+		size := 1 + i*c.MaxSize/c.Count
+		t := gen.generate(r, size)
+		if err := run(state, t, prop); err != nil {
+			f := &Failure{Seed: c.Seed, Count: i + 1}
+			t, f.Err, f.Shrinks = shrink(state, t, err, prop, c.MaxShrinks)
+			f.Value = t.build(state)
+			return f
+		}
+	}
+	return nil
+}
+
+// run builds the value of t and checks the property for it.
+func run(state *lua.State, t *tree, prop func(*lua.State, lua.Value) error) (err error) {
+	top := state.Top()
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+		state.SetTop(top)
+	}()
+	return prop(state, t.build(state))
+}
+
+// shrink greedily replaces t by smaller failing values, up to max steps.
+func shrink(state *lua.State, t *tree, err error, prop func(*lua.State, lua.Value) error, max int) (*tree, error, int) {
+	steps := 0
+	for steps < max {
+		shrunk := false
+		for _, c := range t.shrink() {
+			if cerr := run(state, c, prop); cerr != nil {
+				t, err, shrunk = c, cerr, true
+				steps++
+				break
+			}
+		}
+		if !shrunk {
+			break
+		}
+	}
+	return t, err, steps
+}
+
+// tree is a generated value: a scalar, or a table with its entries in order.
+type tree struct {
+	value lua.Value
+	table bool
+	keys  []*tree
+	vals  []*tree
+}
+
+func scalar(v lua.Value) *tree { return &tree{value: v} }
+
+// build returns the Lua value of the tree.
+func (t *tree) build(state *lua.State) lua.Value {
+	if !t.table {
+		return t.value
+	}
+	state.NewTableSize(0, len(t.keys))
+	for i, k := range t.keys {
+		state.Push(k.build(state))
+		state.Push(t.vals[i].build(state))
+		state.SetTable(-3)
+	}
+	return state.Pop()
+}
+
+// shrink returns smaller variants of the tree, simplest first.
+func (t *tree) shrink() (out []*tree) {
+	if t.table {
+		// remove entries
+		for i := range t.keys {
+			c := &tree{table: true}
+			c.keys = append(append(c.keys, t.keys[:i]...), t.keys[i+1:]...)
+			c.vals = append(append(c.vals, t.vals[:i]...), t.vals[i+1:]...)
+			out = append(out, c)
+		}
+		// shrink values
+		for i, v := range t.vals {
+			for _, s := range v.shrink() {
+				c := &tree{table: true, keys: t.keys}
+				c.vals = append([]*tree(nil), t.vals...)
+				c.vals[i] = s
+				out = append(out, c)
+			}
+		}
+		return out
+	}
+	add := func(v lua.Value) {
+		if v != t.value {
+			out = append(out, scalar(v))
+		}
+	}
+	switch v := t.value.(type) {
+	case lua.Bool:
+		add(lua.Bool(false))
+	case lua.Int:
+		add(lua.Int(0))
+		add(v / 2)
+		if v > 0 {
+			add(v - 1)
+		} else if v < 0 {
+			add(v + 1)
+		}
+	case lua.Float:
+		f := float64(v)
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			add(lua.Float(0))
+			break
+		}
+		add(lua.Float(0))
+		add(lua.Float(math.Trunc(f)))
+		add(lua.Float(f / 2))
+	case lua.String:
+		s := string(v)
+		if s == "" {
+			break
+		}
+		add(lua.String(""))
+		add(lua.String(s[:len(s)/2]))
+		add(lua.String(s[len(s)/2:]))
+		for i := 0; i < len(s) && i < 16; i++ {
+			add(lua.String(s[:i] + s[i+1:]))
+		}
+	}
+	return out
+}
@@ -0,0 +1,43 @@
+package quick
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Azure/golua/lua"
+)
+
+func TestCheckShrinks(t *testing.T) {
+	state := lua.NewState()
+	err := Check(state, Arrays(Ints()), func(state *lua.State, v lua.Value) error {
+		state.Push(v)
+		for i := int64(1); i <= int64(state.RawLen(-1)); i++ {
+			state.GetIndex(-1, i)
+			if n := state.ToInt(-1); n >= 100 {
+				return fmt.Errorf("element %d is %d", i, n)
+			}
+			state.Pop()
+		}
+		return nil
+	}, &Config{Seed: 1})
+	f, ok := err.(*Failure)
+	if !ok {
+		t.Fatalf("Check = %v, want a failure", err)
+	}
+	if got, want := f.Err.Error(), "element 1 is 100"; got != want {
+		t.Errorf("shrunk failure = %q, want %q\n%v", got, want, f)
+	}
+}
+
+func TestCheckHolds(t *testing.T) {
+	state := lua.NewState()
+	err := Check(state, Values(2), func(state *lua.State, v lua.Value) error {
+		if diffs := lua.Diff(v, v, lua.DiffOptions{}); len(diffs) > 0 {
+			return fmt.Errorf("%v", diffs[0])
+		}
+		return nil
+	}, &Config{Seed: 1})
+	if err != nil {
+		t.Error(err)
+	}
+}
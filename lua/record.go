@@ -0,0 +1,128 @@
+package lua
+
+import (
+	"fmt"
+
+	"github.com/Azure/golua/lua/vm"
+)
+
+// recording is a fixed-size ring buffer of the register writes of executed
+// instructions; see WithRecordMode.
+type recording struct {
+	steps   []step
+	next    int  // index of the next step to write
+	size    int  // number of steps recorded
+	rewound bool // StepBack rewound the running frame
+	top     int  // stack top of the rewound frame
+}
+
+// step holds the registers of frame from lo to top as they were before the
+// instruction at pc ran.
+type step struct {
+	frame *Frame
+	pc    int
+	lo    int
+	top   int
+	saved []Value
+}
+
+// WithRecordMode returns an Option that records the registers that the last n
+// executed instructions overwrite, so that a debugger can step the running
+// function back with StepBack. Recording copies registers on every
+// instruction, so it is meant for reproducing bugs rather than for production.
+func WithRecordMode(n int) Option {
+	return func(cfg *config) {
+		cfg.record = n
+	}
+}
+
+// record records the registers that the instruction at fr.pc-1, about to run,
+// may overwrite.
+func (rec *recording) record(fr *Frame, instr vm.Instr) {
+	s := &rec.steps[rec.next]
+	s.frame, s.pc, s.top = fr, fr.pc-1, fr.gettop()
+	s.lo = s.top
+	if op := instr.Code(); op.Mask().SetA() || op == vm.TFORCALL || op == vm.SETLIST {
+		if a := instr.A(); a < s.top {
+			s.lo = a
+		}
+	}
+	s.saved = append(s.saved[:0], fr.locals[s.lo:s.top]...)
+	if rec.next++; rec.next == len(rec.steps) {
+		rec.next = 0
+	}
+	if rec.size < len(rec.steps) {
+		rec.size++
+	}
+}
+
+// last returns the i'th most recent step.
+func (rec *recording) last(i int) *step {
+	return &rec.steps[(rec.next-1-i+2*len(rec.steps))%len(rec.steps)]
+}
+
+// StepBack steps the function of debug back by up to n instructions, and
+// returns the number of instructions it stepped back. It must be called from
+// a line or count hook, with the Debug passed to the hook, in a state created
+// with WithRecordMode.
+//
+// The registers of the function are restored to their values before those
+// instructions ran, and the function resumes from the first of them when the
+// hook returns, so a debugger can inspect the locals again with GetLocal, or
+// run the same code again with different values. Only registers are restored:
+// tables, upvalues and globals written in the meantime, and the effects of the
+// functions called, are not undone. StepBack does not step back further than
+// the call of the function, nor than the oldest recorded instruction.
+func (state *State) StepBack(debug *Debug, n int) (int, error) {
+	rec := state.global.record
+	switch fr := debug.frame; {
+	case rec == nil:
+		return 0, fmt.Errorf("lua: StepBack needs WithRecordMode")
+	case fr == nil || fr.status&callStatusHooked == 0 || (debug.event != HookLine && debug.event != HookCount):
+		return 0, fmt.Errorf("lua: StepBack outside of a line or count hook")
+	}
+	var (
+		fr     = debug.frame
+		undo   []*step
+		steps  int
+		cutoff int // number of recorded steps to drop
+	)
+	// The most recent step of fr is the instruction about to run.
+	for i := 0; i < rec.size && steps <= n; i++ {
+		s := rec.last(i)
+		if s.frame != fr {
+			if isCaller(s.frame, fr) {
+				break // fr was called here
+			}
+			continue // called by fr
+		}
+		undo, cutoff = append(undo, s), i
+		steps++
+	}
+	if steps--; steps <= 0 {
+		return 0, nil
+	}
+	for _, s := range undo {
+		if cap(fr.locals) < s.top {
+			fr.extend(s.top - len(fr.locals))
+		}
+		fr.locals = fr.locals[:s.top]
+		copy(fr.locals[s.lo:], s.saved)
+	}
+	// The oldest step undone stays recorded as the instruction about to run.
+	rec.next = (rec.next - cutoff + len(rec.steps)) % len(rec.steps)
+	rec.size -= cutoff
+	rec.rewound, rec.top = true, fr.gettop()
+	fr.pc = undo[len(undo)-1].pc + 1
+	return steps, nil
+}
+
+// isCaller reports whether caller is on the call stack under fr.
+func isCaller(caller, fr *Frame) bool {
+	for fr = fr.caller(); fr != nil; fr = fr.caller() {
+		if fr == caller {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,110 @@
+package lua
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Azure/golua/lua/binary"
+	"github.com/Azure/golua/lua/vm"
+)
+
+// Executed is an instruction recorded by the instruction trace; see
+// WithInstructionTrace.
+type Executed struct {
+	Source string  // chunk of the function
+	Line   int     // source line, or -1 if unknown
+	PC     int     // index of the instruction in the function's code
+	Op     vm.Code // instruction opcode
+}
+
+// String returns the entry formatted as "source:line: [pc] OPCODE".
+func (x Executed) String() string {
+	return fmt.Sprintf("%s:%d: [%d] %v", chunkID(x.Source), x.Line, x.PC, x.Op)
+}
+
+// ring is a fixed-size ring buffer of executed instructions.
+type ring struct {
+	entries []executed
+	next    int // index of the next entry to write
+	full    bool
+}
+
+type executed struct {
+	proto *binary.Prototype
+	pc    int
+	op    vm.Code
+}
+
+// WithInstructionTrace returns an Option that records the last n executed
+// instructions of the state in a ring buffer, available from
+// State.RecentInstructions and included in crash reports, and in tracebacks
+// with WithTracebackInstructions. Recording an instruction is a few stores,
+// so it can stay enabled in production.
+func WithInstructionTrace(n int) Option {
+	return func(cfg *config) {
+		cfg.ring = n
+	}
+}
+
+// WithTracebackInstructions returns an Option that appends the instructions
+// recorded by the instruction trace (see WithInstructionTrace) to the
+// tracebacks of the state, such as those of debug.traceback or of a message
+// handler, so that the errors of a script are reported with the instructions
+// that led to them.
+func WithTracebackInstructions(enable bool) Option {
+	return func(cfg *config) {
+		cfg.ringTraceback = enable
+	}
+}
+
+// record records the instruction at pc of the frame's function.
+func (r *ring) record(proto *binary.Prototype, pc int, op vm.Code) {
+	r.entries[r.next] = executed{proto, pc, op}
+	if r.next++; r.next == len(r.entries) {
+		r.next, r.full = 0, true
+	}
+}
+
+// RecentInstructions returns the last executed instructions, oldest first, if
+// the instruction trace is enabled. The trace is kept after errors, so it can
+// be inspected once PCall returns.
+func (state *State) RecentInstructions() []Executed {
+	r := state.global.ring
+	if r == nil {
+		return nil
+	}
+	entries := append([]executed{}, r.entries[:r.next]...)
+	if r.full {
+		entries = append(r.entries[r.next:len(r.entries):len(r.entries)], entries...)
+	}
+	trace := make([]Executed, len(entries))
+	for i, x := range entries {
+		trace[i] = Executed{Source: x.proto.Source, Line: -1, PC: x.pc, Op: x.op}
+		if x.pc < len(x.proto.PcLnTab) {
+			trace[i].Line = int(x.proto.PcLnTab[x.pc])
+		}
+	}
+	return trace
+}
+
+// tracebackInstructions writes the recent instructions to the traceback b,
+// if the state appends them to tracebacks.
+func (state *State) tracebackInstructions(b *strings.Builder) {
+	if !state.global.config.ringTraceback {
+		return
+	}
+	for i, x := range state.RecentInstructions() {
+		if i == 0 {
+			b.WriteString("\nrecent instructions:")
+		}
+		fmt.Fprintf(b, "\n\t%v", x)
+	}
+}
+
+// DumpInstructions writes the last executed instructions to w, one per line.
+func (state *State) DumpInstructions(w io.Writer) {
+	for _, x := range state.RecentInstructions() {
+		fmt.Fprintln(w, x)
+	}
+}
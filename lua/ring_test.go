@@ -0,0 +1,58 @@
+package lua
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Azure/golua/lua/vm"
+)
+
+func TestInstructionTrace(t *testing.T) {
+	state := NewState(WithInstructionTrace(3), WithTracebackInstructions(true))
+	defer state.Close()
+
+	// local a, b = 1, 2
+	// return a + b
+	code := []uint32{
+		iABx(vm.LOADK, 0, 0),     // R0 := 1
+		iABx(vm.LOADK, 1, 1),     // R1 := 2
+		iABC(vm.ADD, 0, 0, 1),    // R0 := R0 + R1
+		iABC(vm.RETURN, 0, 2, 0), // return R0
+	}
+	runProto(t, state, code, []interface{}{int64(1), int64(2)})
+	want := []Executed{
+		{Source: "@test.lua", Line: 2, PC: 1, Op: vm.LOADK},
+		{Source: "@test.lua", Line: 3, PC: 2, Op: vm.ADD},
+		{Source: "@test.lua", Line: 4, PC: 3, Op: vm.RETURN},
+	}
+	got := state.RecentInstructions()
+	if len(got) != len(want) {
+		t.Fatalf("RecentInstructions() = %v; want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("RecentInstructions()[%d] = %v; want %v", i, got[i], want[i])
+		}
+	}
+
+	// local a = nil
+	// return a + a
+	state.Push(Func(func(state *State) int {
+		state.Push(state.Traceback(state.ToString(1)))
+		return 1
+	}))
+	if err := loadProto(state, []uint32{
+		iABC(vm.LOADNIL, 0, 0, 0), // R0 := nil
+		iABC(vm.ADD, 0, 0, 0),     // R0 := R0 + R0
+		iABC(vm.RETURN, 0, 2, 0),  // return R0
+	}); err != nil {
+		t.Fatal(err)
+	}
+	err := state.PCall(0, 1, 1)
+	if err == nil {
+		t.Fatal("PCall succeeded with an arithmetic error")
+	}
+	if want := "\nrecent instructions:\n\ttest.lua:4: [3] RETURN\n\ttest.lua:1: [0] LOADNIL\n\ttest.lua:2: [1] ADD"; !strings.HasSuffix(err.Error(), want) {
+		t.Errorf("traceback %q does not end with %q", err, want)
+	}
+}
@@ -0,0 +1,169 @@
+// Package rpc exposes whitelisted Lua functions over JSON-over-HTTP.
+//
+// A request is a POST whose body is a JSON object naming the function and its
+// arguments:
+//
+//	{"method": "admin.kick", "params": ["player42", "spamming"]}
+//
+// The function is called on a state checked out from a lua.Pool, and its
+// results are returned as a JSON array:
+//
+//	{"result": [true]}
+//
+// If the function raises an error, the response holds its message instead:
+//
+//	{"error": "no such player"}
+package rpc
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime"
+	"strings"
+
+	"github.com/Azure/golua/lua"
+	"github.com/Azure/golua/lua/luajson"
+)
+
+// MaxBodySize is the maximum size of a request body.
+const MaxBodySize = 1 << 20
+
+// Request is the body of an RPC request.
+type Request struct {
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+// Response is the body of an RPC response.
+type Response struct {
+	Result []interface{} `json:"result,omitempty"`
+	Error  string        `json:"error,omitempty"`
+}
+
+// Server is an http.Handler calling exported Lua functions.
+type Server struct {
+	pool  *lua.Pool
+	funcs map[string][]string
+}
+
+// NewServer returns a server calling functions on states from pool. The
+// functions named by exports are exported under their own name; see Export.
+func NewServer(pool *lua.Pool, exports ...string) *Server {
+	s := &Server{pool: pool, funcs: make(map[string][]string)}
+	for _, name := range exports {
+		s.Export(name, name)
+	}
+	return s
+}
+
+// Export exports the function at path as method. The path is the name of a
+// global, optionally followed by dot-separated fields, such as "admin.kick".
+// Only exported functions can be called.
+func (s *Server) Export(method, path string) {
+	s.funcs[method] = strings.Split(path, ".")
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		reply(w, http.StatusMethodNotAllowed, &Response{Error: "method not allowed"})
+		return
+	}
+	var req Request
+	if err := json.NewDecoder(io.LimitReader(r.Body, MaxBodySize)).Decode(&req); err != nil {
+		reply(w, http.StatusBadRequest, &Response{Error: fmt.Sprintf("invalid request: %v", err)})
+		return
+	}
+	path, ok := s.funcs[req.Method]
+	if !ok {
+		reply(w, http.StatusNotFound, &Response{Error: fmt.Sprintf("unknown method %q", req.Method)})
+		return
+	}
+	args := make([]interface{}, len(req.Params))
+	for i, param := range req.Params {
+		if err := luajson.Unmarshal(param, &args[i]); err != nil {
+			reply(w, http.StatusBadRequest, &Response{Error: fmt.Sprintf("invalid parameter %d: %v", i+1, err)})
+			return
+		}
+	}
+	state, err := s.pool.Get(r.Context())
+	if err != nil {
+		reply(w, http.StatusServiceUnavailable, &Response{Error: err.Error()})
+		return
+	}
+	result, err := call(state, path, args)
+	if err != nil {
+		if failed(err) {
+			// The state may be left mid-call; do not reuse it.
+			s.pool.Discard(state)
+		} else {
+			s.pool.Put(state)
+		}
+		reply(w, http.StatusOK, &Response{Error: err.Error()})
+		return
+	}
+	s.pool.Put(state)
+	if result == nil {
+		result = []interface{}{}
+	}
+	reply(w, http.StatusOK, &Response{Result: result})
+}
+
+// call calls the function at path with args and returns its results.
+func call(state *lua.State, path []string, args []interface{}) (results []interface{}, err error) {
+	state.SetTop(0)
+	// The path is resolved in the protected call, since its lookups may run
+	// __index metamethods that raise errors.
+	state.Push(lua.Func(func(state *lua.State) int {
+		state.GetGlobal(path[0])
+		for i, field := range path[1:] {
+			if state.TypeAt(-1) != lua.TableType {
+				return state.Errorf("'%s' is not a table", strings.Join(path[:i+1], "."))
+			}
+			state.GetField(-1, field)
+			state.Remove(-2)
+		}
+		if !state.IsFunc(-1) {
+			return state.Errorf("'%s' is not a function", strings.Join(path, "."))
+		}
+		state.Insert(1)
+		state.Call(state.Top()-1, lua.MultRets)
+		return state.Top()
+	}))
+	for _, arg := range args {
+		luajson.Push(state, arg)
+	}
+	if err := state.PCall(len(args), lua.MultRets, 0); err != nil {
+		return nil, err
+	}
+	for i := 1; i <= state.Top(); i++ {
+		v, err := luajson.ToGo(state, i)
+		if err != nil {
+			return nil, fmt.Errorf("result %d: %v", i, err)
+		}
+		results = append(results, v)
+	}
+	state.SetTop(0)
+	return results, nil
+}
+
+// failed reports whether err is a failure of the state rather than an error
+// raised by Lua code, such as a Go panic or an interrupted call, after which
+// the state cannot be reused.
+func failed(err error) bool {
+	var rerr runtime.Error
+	return errors.As(err, &rerr) ||
+		errors.Is(err, lua.ErrInterrupted) ||
+		errors.Is(err, lua.ErrInstructionLimit) ||
+		errors.Is(err, lua.ErrClosed)
+}
+
+func reply(w http.ResponseWriter, code int, resp *Response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,110 @@
+package rpc
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Azure/golua/lua"
+)
+
+func newTestPool() *lua.Pool {
+	return lua.NewPool(2, func() (*lua.State, error) {
+		state := lua.NewState()
+		state.NewTable()
+		state.Push(lua.Func(func(state *lua.State) int {
+			name := state.CheckString(1)
+			if name == "nobody" {
+				state.Errorf("no such player")
+			}
+			state.NewTable()
+			state.Push(name)
+			state.SetField(-2, "kicked")
+			state.Push(state.OptInt(2, 0))
+			state.SetField(-2, "minutes")
+			state.Push(true)
+			return 2
+		}))
+		state.SetField(-2, "kick")
+		state.SetGlobal("admin")
+		return state, nil
+	})
+}
+
+func TestServer(t *testing.T) {
+	var tests = []struct {
+		method string
+		body   string
+		code   int
+		want   string
+	}{
+		{http.MethodPost, `{"method": "kick", "params": ["p42", 10]}`, 200, `{"result":[{"kicked":"p42","minutes":10},true]}`},
+		{http.MethodPost, `{"method": "kick", "params": ["nobody"]}`, 200, `{"error":"no such player"}`},
+		{http.MethodPost, `{"method": "admin.kick", "params": []}`, 404, `{"error":"unknown method \"admin.kick\""}`},
+		{http.MethodPost, `{"method": `, 400, ``},
+		{http.MethodGet, ``, 405, `{"error":"method not allowed"}`},
+	}
+	pool := newTestPool()
+	server := NewServer(pool)
+	server.Export("kick", "admin.kick")
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest(test.method, "/", strings.NewReader(test.body)))
+		if w.Code != test.code {
+			t.Errorf("%s %s: status %d; want %d", test.method, test.body, w.Code, test.code)
+		}
+		if got := strings.TrimSpace(w.Body.String()); test.want != "" && got != test.want {
+			t.Errorf("%s %s: got %s; want %s", test.method, test.body, got, test.want)
+		}
+	}
+	if stats := pool.Stats(); stats.InUse != 0 {
+		t.Errorf("%d states still in use", stats.InUse)
+	}
+}
+
+func TestServerErrors(t *testing.T) {
+	pool := lua.NewPool(1, func() (*lua.State, error) {
+		state := lua.NewState()
+		// locked = setmetatable({}, {__index = function() error("locked") end})
+		state.NewTable()
+		state.NewTable()
+		state.Push(lua.Func(func(state *lua.State) int {
+			return state.Errorf("locked")
+		}))
+		state.SetField(-2, "__index")
+		state.SetMetaTableAt(-2)
+		state.SetGlobal("locked")
+		state.Register("crash", func(state *lua.State) int {
+			var list []int
+			return list[state.Top()]
+		})
+		return state, nil
+	})
+	server := NewServer(pool, "crash")
+	server.Export("open", "locked.open")
+
+	var tests = []struct {
+		method  string
+		want    string
+		created int // states left in the pool
+	}{
+		{"open", `{"error":"locked"}`, 1},
+		{"crash", ``, 0},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		body := `{"method": "` + test.method + `", "params": []}`
+		server.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+		if got := strings.TrimSpace(w.Body.String()); test.want != "" && got != test.want {
+			t.Errorf("%s: got %s; want %s", test.method, got, test.want)
+		}
+		stats := pool.Stats()
+		if stats.InUse != 0 {
+			t.Errorf("%s: %d states still in use", test.method, stats.InUse)
+		}
+		if stats.Created != test.created {
+			t.Errorf("%s: %d states in the pool; want %d", test.method, stats.Created, test.created)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package lua
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// SlowCall describes a protected call that exceeded the slow-call threshold;
+// see State.SetSlowLog.
+type SlowCall struct {
+	Start     time.Time     `json:"start"`     // when the call started
+	Duration  time.Duration `json:"duration"`  // how long the call ran
+	Threshold time.Duration `json:"threshold"` // the threshold that was exceeded
+	Traceback string        `json:"traceback"` // call stack at the threshold, if Lua code was running
+}
+
+type slowLog struct {
+	threshold time.Duration
+	report    func(SlowCall)
+}
+
+// SetSlowLog makes the state report every outermost PCall running longer than
+// threshold to report, like a slow query log. When the threshold is reached,
+// the traceback of the running script is captured at the next instruction
+// boundary; report is called from the goroutine running the state once the
+// call completes.
+//
+// A threshold <= 0 or a nil report disables the slow log.
+func (state *State) SetSlowLog(threshold time.Duration, report func(SlowCall)) {
+	if threshold <= 0 || report == nil {
+		state.global.slowlog = nil
+		return
+	}
+	state.global.slowlog = &slowLog{threshold, report}
+}
+
+// watch starts watching the outermost protected call, returning the function
+// to call when it completes.
+func (state *State) watch() (done func()) {
+	log := state.global.slowlog
+	if log == nil || state.global.pcalls > 0 {
+		return func() {}
+	}
+	var (
+		start    = time.Now()
+		trace    = make(chan string, 1)
+		finished int32 // set once the call completed
+		timer    = time.AfterFunc(log.threshold, func() {
+			state.Interrupt(func(thread *State) {
+				if atomic.LoadInt32(&finished) != 0 {
+					return // interrupted after the call completed
+				}
+				select {
+				case trace <- thread.Traceback(""):
+				default:
+				}
+			})
+		})
+	)
+	return func() {
+		atomic.StoreInt32(&finished, 1)
+		duration := time.Since(start)
+		if !timer.Stop() && duration >= log.threshold {
+			call := SlowCall{
+				Start:     start,
+				Duration:  duration,
+				Threshold: log.threshold,
+			}
+			select {
+			case call.Traceback = <-trace:
+			default:
+			}
+			log.report(call)
+		}
+	}
+}
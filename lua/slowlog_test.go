@@ -0,0 +1,68 @@
+package lua
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Azure/golua/lua/vm"
+)
+
+func TestSlowLog(t *testing.T) {
+	state := NewState()
+	defer state.Close()
+
+	var reports []SlowCall
+	state.SetSlowLog(20*time.Millisecond, func(call SlowCall) {
+		reports = append(reports, call)
+	})
+	sleep := Func(func(state *State) int {
+		time.Sleep(time.Duration(state.OptInt(1, 5)) * time.Millisecond)
+		return 0
+	})
+
+	// local f, n = ...
+	// for i = 1, n do f() end
+	loop := []uint32{
+		iABC(vm.VARARG, 0, 3, 0), // R0, R1 := ...
+		iABx(vm.LOADK, 2, 0),     // R2 := 1
+		iABC(vm.MOVE, 3, 1, 0),   // R3 := n
+		iABx(vm.LOADK, 4, 0),     // R4 := 1
+		iAsBx(vm.FORPREP, 2, 2),  // goto FORLOOP
+		iABC(vm.MOVE, 6, 0, 0),   // R6 := f
+		iABC(vm.CALL, 6, 1, 1),   // f()
+		iAsBx(vm.FORLOOP, 2, -3), // if loop then goto MOVE
+		iABC(vm.RETURN, 0, 1, 0), // return
+	}
+
+	// A slow call is reported with the traceback of the running script.
+	runProto(t, state, loop, []interface{}{int64(1)}, sleep, 10)
+	if len(reports) != 1 {
+		t.Fatalf("slow call reported %d times; want once", len(reports))
+	}
+	if call := reports[0]; call.Duration < call.Threshold || !strings.Contains(call.Traceback, "test.lua:") {
+		t.Errorf("slow call reported as %+v", call)
+	}
+
+	// A fast call is not reported.
+	runProto(t, state, loop, []interface{}{int64(1)}, sleep, 0)
+	if len(reports) != 1 {
+		t.Errorf("fast call reported: %+v", reports[1:])
+	}
+
+	// A call that completes before its interrupt runs, when no script runs
+	// after the threshold, is reported without a traceback, and the
+	// interrupt left queued does not report the next call.
+	state.Push(sleep)
+	state.Push(30)
+	if err := state.PCall(1, 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 2 || reports[1].Traceback != "" {
+		t.Fatalf("slow Go call reported as %+v", reports[1:])
+	}
+	runProto(t, state, loop, []interface{}{int64(1)}, sleep, 0)
+	if len(reports) != 2 {
+		t.Errorf("call after a slow Go call reported: %+v", reports[2:])
+	}
+}
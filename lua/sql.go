@@ -0,0 +1,121 @@
+package lua
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// The scalar value types implement driver.Valuer and sql.Scanner, so that
+// values handed to Go code can be passed directly as SQL parameters and
+// scanned back from query results. Tables can be stored as JSON with
+// luajson.Column.
+
+// Value implements driver.Valuer; nil is stored as NULL.
+func (x Nil) Value() (driver.Value, error) { return nil, nil }
+
+// Scan implements sql.Scanner; only NULL can be scanned into nil.
+func (x *Nil) Scan(src interface{}) error {
+	if src != nil {
+		return scanError(src, "nil")
+	}
+	*x = Nil(1)
+	return nil
+}
+
+// Value implements driver.Valuer.
+func (x Bool) Value() (driver.Value, error) { return bool(x), nil }
+
+// Scan implements sql.Scanner, accepting booleans, the integers 0 and 1, and
+// strings accepted by strconv.ParseBool.
+func (x *Bool) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case bool:
+		*x = Bool(src)
+		return nil
+	case int64:
+		if src == 0 || src == 1 {
+			*x = Bool(src == 1)
+			return nil
+		}
+	case []byte, string:
+		if b, err := strconv.ParseBool(scanString(src)); err == nil {
+			*x = Bool(b)
+			return nil
+		}
+	}
+	return scanError(src, "boolean")
+}
+
+// Value implements driver.Valuer.
+func (x Int) Value() (driver.Value, error) { return int64(x), nil }
+
+// Scan implements sql.Scanner, accepting integers, floats with an exact
+// integer representation, and strings of decimal integers.
+func (x *Int) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case int64:
+		*x = Int(src)
+		return nil
+	case float64:
+		if src == math.Trunc(src) && src >= math.MinInt64 && src < math.MaxInt64 {
+			*x = Int(int64(src))
+			return nil
+		}
+	case []byte, string:
+		if i, err := strconv.ParseInt(scanString(src), 10, 64); err == nil {
+			*x = Int(i)
+			return nil
+		}
+	}
+	return scanError(src, "integer")
+}
+
+// Value implements driver.Valuer.
+func (x Float) Value() (driver.Value, error) { return float64(x), nil }
+
+// Scan implements sql.Scanner, accepting floats, integers and numeric strings.
+func (x *Float) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case float64:
+		*x = Float(src)
+		return nil
+	case int64:
+		*x = Float(src)
+		return nil
+	case []byte, string:
+		if f, err := strconv.ParseFloat(scanString(src), 64); err == nil {
+			*x = Float(f)
+			return nil
+		}
+	}
+	return scanError(src, "float")
+}
+
+// Value implements driver.Valuer.
+func (x String) Value() (driver.Value, error) { return string(x), nil }
+
+// Scan implements sql.Scanner, accepting strings and bytes.
+func (x *String) Scan(src interface{}) error {
+	switch src.(type) {
+	case []byte, string:
+		*x = String(scanString(src))
+		return nil
+	}
+	return scanError(src, "string")
+}
+
+func scanString(src interface{}) string {
+	if b, ok := src.([]byte); ok {
+		return string(b)
+	}
+	return src.(string)
+}
+
+func scanError(src interface{}, typ string) error {
+	if src == nil {
+		return fmt.Errorf("lua: cannot scan NULL into %s", typ)
+	}
+	return fmt.Errorf("lua: cannot scan %T (%v) into %s", src, src, typ)
+}
@@ -0,0 +1,68 @@
+package lua
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestSQLValues(t *testing.T) {
+	var tests = []struct {
+		value driver.Valuer
+		want  driver.Value
+	}{
+		{Nil(1), nil},
+		{Bool(true), true},
+		{Int(-3), int64(-3)},
+		{Float(0.25), 0.25},
+		{String("a\x00b"), "a\x00b"},
+	}
+	for _, tt := range tests {
+		got, err := tt.value.Value()
+		if err != nil || got != tt.want || !driver.IsValue(got) {
+			t.Errorf("%#v.Value() = %#v, %v; want %#v", tt.value, got, err, tt.want)
+		}
+	}
+}
+
+func TestSQLScan(t *testing.T) {
+	var tests = []struct {
+		into sql.Scanner
+		src  interface{}
+		want string // the scanned value or the error
+	}{
+		{new(Nil), nil, "nil"},
+		{new(Nil), int64(0), "lua: cannot scan int64 (0) into nil"},
+		{new(Bool), true, "true"},
+		{new(Bool), int64(0), "false"},
+		{new(Bool), []byte("TRUE"), "true"},
+		{new(Bool), int64(2), "lua: cannot scan int64 (2) into boolean"},
+		{new(Bool), nil, "lua: cannot scan NULL into boolean"},
+		{new(Int), int64(math.MinInt64), "-9223372036854775808"},
+		{new(Int), 42.0, "42"},
+		{new(Int), "-17", "-17"},
+		{new(Int), 0.5, "lua: cannot scan float64 (0.5) into integer"},
+		{new(Int), math.Exp2(63), "lua: cannot scan float64 (9.223372036854776e+18) into integer"},
+		{new(Int), "1e3", "lua: cannot scan string (1e3) into integer"},
+		{new(Float), 1.5, "1.5"},
+		{new(Float), int64(7), "7.0"},
+		{new(Float), []byte("2.5e-1"), "0.25"},
+		{new(Float), true, "lua: cannot scan bool (true) into float"},
+		{new(String), []byte("bytes"), "bytes"},
+		{new(String), "text", "text"},
+		{new(String), int64(1), "lua: cannot scan int64 (1) into string"},
+	}
+	for _, tt := range tests {
+		got := ""
+		if err := tt.into.Scan(tt.src); err != nil {
+			got = err.Error()
+		} else {
+			got = fmt.Sprint(tt.into)
+		}
+		if got != tt.want {
+			t.Errorf("%T.Scan(%#v) = %s; want %s", tt.into, tt.src, got, tt.want)
+		}
+	}
+}
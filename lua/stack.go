@@ -10,12 +10,26 @@ import (
 // either because it would cause the stack to be larger than a fixed maximum size (typically
 // at least several thousand elements) or because it cannot allocate memory for the extra space.
 //
+// The maximum size is the stack size of the state (see WithStackMax), shared by all the frames of
+// the thread, so that a function called deep in a recursion has less space than the main chunk.
+//
 // This function never shrinks the stack; if the stack already has space for the extra slots, it
 // is left unchanged.
 func (state *State) CheckStack(needed int) bool {
+	if needed > state.StackMax()-state.stackUsed() {
+		return false
+	}
 	return state.frame().checkstack(needed)
 }
 
+// stackUsed returns the number of stack slots used by the frames of the thread.
+func (state *State) stackUsed() int {
+	if fr := state.frame(); fr != nil {
+		return fr.base + fr.gettop()
+	}
+	return 0
+}
+
 // AbsIndex converts the acceptable index idx into an equivalent absolute index;
 // that is, one that does not depend on the stack top).
 func (state *State) AbsIndex(index int) int {
@@ -35,7 +49,10 @@ func (state *State) DumpStack(w io.Writer) {
 // (therefore replacing the value at that given index), and then pops the top element.
 //
 // See https://www.lua.org/manual/5.3/manual.html#lua_replace
-func (state *State) Replace(index int) { state.frame().replace(index) }
+func (state *State) Replace(index int) {
+	index = state.AbsIndex(index)
+	state.set(index, state.frame().pop())
+}
 
 // rotate rotates the stack elements between the valid index and the top of the stack.
 //
@@ -87,6 +104,10 @@ func (state *State) XMove(dst *State, n int) {
 //
 // See https://www.lua.org/manual/5.3/manual.html#lua_settop
 func (state *State) SetTop(top int) {
+	if top == 0 {
+		state.frame().settop(0)
+		return
+	}
 	if top = state.frame().absindex(top); top < 0 {
 		panic(runtimeErr(fmt.Errorf("stack underflow!")))
 	}
@@ -2,11 +2,14 @@ package lua
 
 import (
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"reflect"
 	"strings"
+	"sync"
 
 	"github.com/Azure/golua/lua/binary"
 	"github.com/Azure/golua/lua/syntax"
@@ -55,6 +58,11 @@ type (
 		global *global
 		base   Frame // base call frame
 		calls  int   // call count
+
+		co    *coroutine // nil for the main thread
+		guard *leakGuard // see WithLeakReports
+		hook  *hooks     // see SetHook
+		msgh  Value      // message handler of the running PCall, if any
 	}
 
 	// 'global state', shared by all threads of a main state.
@@ -65,6 +73,39 @@ type (
 		thread0  *State
 		config   *config
 		panicFn  Func
+
+		interrupted int32 // non-zero when interrupts are pending
+		interruptMu sync.Mutex
+		interrupts  []func(*State)
+
+		slowlog    *slowLog
+		ring       *ring                        // instruction trace
+		record     *recording                   // register writes, see WithRecordMode
+		instrs     *int64                       // instructions left, see SetInstructionLimit
+		intercepts map[*Closure][]*interceptor  // see Intercept
+		deprecated map[string]bool              // names warned about, see Deprecate
+		modules    map[string]string            // hints of declared modules, see DeclareModule
+		frozen     map[*binary.Prototype]frozen // decoded functions of frozen chunks, see LoadFrozen
+		opened     []string                     // libraries opened with Require
+
+		threads    map[*coroutine]bool // suspended coroutines
+		idle       []chan job          // pooled goroutines, see WithThreadPool
+		poolHits   int
+		poolMisses int
+
+		typeNames map[reflect.Type]string  // see RegisterTypeName
+		methods   map[reflect.Type]*table  // see HasMethods
+		bound     map[interface{}]*Closure // methods of bound values, see Bind
+
+		finobj  map[Value]int // values with a __gc metamethod and their mark order, see Close
+		marks   int           // number of values marked in finobj
+		closing bool          // Close is running the finalizers
+		closed  bool          // Close has been called
+
+		leaked map[Value]int // marks of the resources to close if the state leaks, see CloseOnLeak
+
+		stdout, stderr io.Writer // see SetOutput and SetErrorOutput
+		pcalls  int // depth of nested PCalls
 	}
 )
 
@@ -96,7 +137,17 @@ func NewState(opts ...Option) *State {
 		version:  &version,
 		thread0:  state,
 		config:   &cfg,
+		threads:  make(map[*coroutine]bool),
 	})
+	if cfg.ring > 0 {
+		state.global.ring = &ring{entries: make([]executed, cfg.ring)}
+	}
+	if cfg.record > 0 {
+		state.global.record = &recording{steps: make([]step, cfg.record)}
+	}
+	if cfg.leaks != nil {
+		state.guard = newLeakGuard(cfg.leaks)
+	}
 
 	return state
 }
@@ -140,6 +191,7 @@ func (state *State) panic(err error) int { panic(err) }
 // enter enters a new call frame.
 func (state *State) enter(fr *Frame) *Frame {
 	state.ensure()
+	fr.base = state.stackUsed()
 	fp := state.base.prev.next
 	state.base.prev.next = fr
 	fr.prev = state.base.prev
@@ -225,23 +277,77 @@ func (state *State) isValid(index int) bool {
 func (state *State) call(fr *Frame) {
 	// Check that we are below the recursion / call max.
 	if state.calls >= MaxCalls {
-		state.Errorf("go: call stack overflow")
+		state.Raise(MsgStackOverflow)
 	}
 
 	// Ensure stack space for new call frame.
 	fr.checkstack(InitialStackNew)
 
-	// Push arguments and pop function.
-	args := state.frame().popN(state.frame().gettop() - fr.fnID + 1)[1:]
+	// Push arguments and pop function. The arguments are moved with
+	// a single copy, however many there are.
+	caller := state.frame()
+	args := caller.locals[fr.fnID:]
+	caller.locals = caller.locals[:fr.fnID-1]
 
 	// Enter and leave frame on return.
 	defer state.leave(state.enter(fr))
+	if state.msgh != nil {
+		defer state.handleError()
+	}
 
 	fr.pushN(args)
+	if state.stackUsed() > state.StackMax() {
+		state.Raise(MsgStackOverflow)
+	}
+
+	// Run the function, through its interceptors if any (see Intercept).
+	if icpts := state.global.intercepts[fr.closure]; icpts != nil {
+		state.intercept(fr, icpts)
+		return
+	}
+	state.run(fr)
+}
+
+// handleError calls the message handler of the running PCall with the error
+// being raised, from the frame that raises it so that the handler can inspect
+// its stack, and raises the result of the handler instead. A handler handles
+// one error only.
+func (state *State) handleError() {
+	if r := recover(); r != nil {
+		if err, ok := r.(error); ok && state.msgh != nil {
+			h := state.msgh
+			state.msgh = nil
+			r = state.callHandler(h, err)
+		}
+		panic(r)
+	}
+}
 
+// callHandler calls the message handler h with the error object of err, and
+// returns the error with its result as the error object.
+func (state *State) callHandler(h Value, err error) (handled error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(killed); ok {
+				panic(r)
+			}
+			handled = state.messageErr(MsgErrorHandling)
+		}
+	}()
+	state.Push(h)
+	state.Push(errorObject(err))
+	state.Call(1, 1)
+	return valueErr(state.frame().pop())
+}
+
+// run runs the function of the frame fr, whose arguments are on its stack.
+func (state *State) run(fr *Frame) {
 	// Is it a Lua closure?
 	if fr.function().isLua() {
-		// Ensure stack has space.
+		// Ensure stack has space, within the stack size of the thread.
+		if fr.base+fr.closure.binary.StackSize() > state.StackMax() {
+			state.Raise(MsgStackOverflow)
+		}
 		fr.checkstack(fr.closure.binary.StackSize())
 
 		// Adjust the stack; params is the # of fixed real
@@ -251,7 +357,7 @@ func (state *State) call(fr *Frame) {
 		switch params := fr.closure.binary.NumParams(); {
 		case fr.gettop() < params: // # arguments < # parameters
 			for fr.gettop() < params {
-				fr.push(None) // nil to top
+				fr.push(Nil(1)) // nil to top
 			}
 		case fr.gettop() > params: // # arguments > # parameters
 			extras := fr.popN(fr.gettop() - params)
@@ -260,16 +366,27 @@ func (state *State) call(fr *Frame) {
 			}
 		}
 
+		if state.hook != nil {
+			state.callHook(fr, HookCall, -1)
+		}
+
 		// Execute the closure.
 		execute(&v53{state})
 		return
 	} else if fr.function().isGo() {
 		// Otherwise Go closure.
-		if rets := fr.popN(fr.function().native(state)); fr.rets != 0 {
+		if state.hook != nil {
+			state.callHook(fr, HookCall, -1)
+		}
+		retc := fr.function().native(state)
+		if state.hook != nil {
+			state.callHook(fr, HookRets, -1)
+		}
+		if rets := fr.popN(retc); fr.rets != 0 {
 			switch retc := len(rets); {
 			case retc < fr.rets:
 				for retc < fr.rets {
-					rets = append(rets, None)
+					rets = append(rets, Nil(1))
 					retc++
 				}
 			case retc > fr.rets:
@@ -293,6 +410,9 @@ func (state *State) load(filename string, source interface{}) (*Closure, error)
 		src []byte
 		err error
 	)
+	if state.global.closed {
+		return nil, ErrClosed
+	}
 	if src, err = syntax.Source(filename, source); err != nil {
 		return nil, err
 	}
@@ -319,19 +439,31 @@ func (state *State) load(filename string, source interface{}) (*Closure, error)
 	if err != nil {
 		return nil, err
 	}
+	if err := checkLimits(&chunk.Entry, state.chunkLimits()); err != nil {
+		return nil, err
+	}
+	if defines := state.global.config.defines; len(defines) > 0 {
+		define(&chunk.Entry, defines, 0)
+	}
 
+	return state.closure(&chunk), nil
+}
+
+// closure returns the closure for the main function of chunk, whose first
+// upvalue, if any, is the globals table.
+func (state *State) closure(chunk *binary.Chunk) *Closure {
 	cls := newLuaClosure(&chunk.Entry)
 	if len(cls.upvals) > 0 {
 		globals := state.global.registry.getInt(GlobalsIndex)
 		cls.upvals[0] = &upValue{index: -1, value: globals}
 	}
-	return cls, nil
+	return cls
 }
 
 func (state *State) gettable(obj, key Value, raw bool) Value {
 	// fmt.Printf("%v[%v] (%t)\n", obj, key, raw)
 	if tbl, ok := obj.(*table); ok {
-		if val := tbl.get(key); !IsNone(val) || raw || IsNone(state.metafield(tbl, "__index")) {
+		if val := tbl.get(key); !IsNone(val) || raw || IsNone(state.metamethod(tbl, metaIndex)) {
 			return val
 		}
 		val, err := tryMetaIndex(state, tbl, key)
@@ -372,16 +504,28 @@ func (state *State) metafield(value Value, event string) Value {
 	return None
 }
 
+// metamethod is like metafield for the metamethod of event.
+func (state *State) metamethod(value Value, event metaEvent) Value {
+	if obj := state.getmetatable(value, true); !IsNone(obj) {
+		if tbl, ok := obj.(*table); ok && tbl != nil {
+			return tbl.get(event2key[event])
+		}
+	}
+	return None
+}
+
 func (state *State) setmetatable(value, meta Value) {
 	mt, ok := meta.(*table)
 	if !ok && !IsNone(meta) {
-		state.errorf("metatable must be table or nil")
+		state.Raise(MsgMetaTable)
 	}
 	switch v := value.(type) {
 	case *Object:
 		v.meta = mt
+		state.checkFinalizer(v, mt)
 	case *table:
 		v.meta = mt
+		state.checkFinalizer(v, mt)
 	default:
 		state.global.builtins[v.Type()] = mt
 	}
@@ -450,7 +594,7 @@ func (state *State) get(index int) Value {
 	//
 	case index > 0:
 		if index > cap(frame.locals) {
-			state.errorf("unacceptable index (%d)", index)
+			state.Raise(MsgStackIndex, index)
 		}
 		if index > frame.gettop() {
 			return None
@@ -463,7 +607,7 @@ func (state *State) get(index int) Value {
 		//state.Logf("get %d (absolute = %d)", index, frame.absindex(index))
 		// Debug(state)
 		if index = frame.absindex(index); index < 1 || index > frame.gettop() {
-			state.errorf("invalid index (%d)", index)
+			state.Raise(MsgInvalidIndex, index)
 		}
 		return frame.get(index - 1)
 	//
@@ -476,9 +620,9 @@ func (state *State) get(index int) Value {
 	//
 	default:
 		if index = RegistryIndex - index; index >= MaxUpValues {
-			state.errorf("upvalue index too large (%d)", index)
+			state.Raise(MsgUpValueIndex, index)
 		}
-		if nups := len(frame.closure.upvals); nups == 0 || nups > index {
+		if nups := len(frame.closure.upvals); nups == 0 || nups < index {
 			return None
 		}
 		return frame.getUp(index - 1).get()
@@ -492,7 +636,7 @@ func (state *State) set(index int, value Value) {
 	//
 	case index > 0:
 		if index > cap(frame.locals) {
-			state.errorf("unacceptable index (%d)", index)
+			state.Raise(MsgStackIndex, index)
 		}
 		if index > frame.gettop() {
 			return
@@ -504,7 +648,7 @@ func (state *State) set(index int, value Value) {
 	//
 	case !isPseudoIndex(index):
 		if index = frame.absindex(index); index < 1 || index > frame.gettop() {
-			state.errorf("invalid index (%d)", index)
+			state.Raise(MsgInvalidIndex, index)
 		}
 		frame.set(index-1, value)
 		return
@@ -520,9 +664,9 @@ func (state *State) set(index int, value Value) {
 	//
 	default:
 		if index = RegistryIndex - index; index >= MaxUpValues {
-			state.errorf("upvalue index too large (%d)", index)
+			state.Raise(MsgUpValueIndex, index)
 		}
-		if nups := len(frame.closure.upvals); nups == 0 || nups > index {
+		if nups := len(frame.closure.upvals); nups == 0 || nups < index {
 			return
 		}
 		frame.setUp(index-1, value)
@@ -0,0 +1,40 @@
+package lua
+
+import (
+	"sort"
+)
+
+// Stats is a snapshot of a state's usage, reported by State.Stats.
+type Stats struct {
+	StackSize int         `json:"stack_size"` // number of values on the current frame's stack
+	CallDepth int         `json:"call_depth"` // number of active call frames
+	Modules   []string    `json:"modules"`    // names of the modules in package.loaded, sorted
+	Threads   ThreadStats `json:"threads"`    // coroutines of the state
+}
+
+// Stats returns a snapshot of the state's usage. Like other State methods, it
+// must not be called while the state is running on another goroutine.
+func (state *State) Stats() Stats {
+	stats := Stats{
+		StackSize: state.Top(),
+		CallDepth: state.calls,
+		Threads: ThreadStats{
+			Suspended: len(state.global.threads),
+			Idle:      len(state.global.idle),
+			Hits:      state.global.poolHits,
+			Misses:    state.global.poolMisses,
+		},
+	}
+	if state.GetField(RegistryIndex, LoadedKey) == TableType {
+		state.Push(nil)
+		for state.Next(-2) {
+			if name, ok := state.get(-2).(String); ok {
+				stats.Modules = append(stats.Modules, string(name))
+			}
+			state.Pop()
+		}
+	}
+	state.Pop()
+	sort.Strings(stats.Modules)
+	return stats
+}
@@ -0,0 +1,206 @@
+package lua
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// CheckStruct checks whether the function argument at index is a table, and
+// sets the fields of the struct that ptr points to from the fields of the
+// table with the same names, or the names in their lua tags:
+//
+//	var cfg struct {
+//		Host    string   `lua:"host"`
+//		Port    int      `lua:"port,required"`
+//		Tags    []string `lua:"tags"`
+//		Verbose bool     `lua:"-"` // not set from Lua
+//	}
+//	state.CheckStruct(1, &cfg)
+//
+// Fields absent from the table keep their values, unless their tag has the
+// required option. Values convert as with PushChannel; nested structs,
+// pointers to structs, slices, arrays and maps are set from nested tables,
+// and interface{} fields as with ToGoValue. A field that does not convert
+// raises an argument error naming it, such as
+//
+//	bad argument #1 to 'connect' (field 'port': number expected, got string)
+func (state *State) CheckStruct(index int, ptr interface{}) {
+	state.checkTable(index, ptr, reflect.Struct, "CheckStruct")
+}
+
+// CheckSlice checks whether the function argument at index is a sequence, and
+// sets the slice that ptr points to to its elements, which convert as the
+// fields of CheckStruct:
+//
+//	var hosts []string
+//	state.CheckSlice(1, &hosts)
+func (state *State) CheckSlice(index int, ptr interface{}) {
+	state.checkTable(index, ptr, reflect.Slice, "CheckSlice")
+}
+
+// checkTable sets the value of kind that ptr points to from the table at
+// index.
+func (state *State) checkTable(index int, ptr interface{}, kind reflect.Kind, caller string) {
+	rv := reflect.ValueOf(ptr)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != kind {
+		panic(fmt.Sprintf("lua: %s of %T", caller, ptr))
+	}
+	state.CheckType(index, TableType)
+	d := decoder{state: state, seen: make(map[*table]bool)}
+	if err := d.decode(rv.Elem(), state.get(index), ""); err != "" {
+		argError(state, index, err)
+	}
+}
+
+// decoder sets Go values from Lua values for CheckStruct and CheckSlice.
+type decoder struct {
+	state *State
+	seen  map[*table]bool // tables being decoded
+}
+
+// decode sets dst from v, and returns the error message of the value at path
+// if it does not convert, or "".
+func (d *decoder) decode(dst reflect.Value, v Value, path string) string {
+	t, isTable := v.(*table)
+	switch kind := dst.Kind(); {
+	case isTable && (kind == reflect.Struct || kind == reflect.Slice || kind == reflect.Array || kind == reflect.Map):
+		if d.seen[t] {
+			return fmt.Sprintf("field '%s' contains itself", path)
+		}
+		d.seen[t] = true
+		defer delete(d.seen, t)
+		switch kind {
+		case reflect.Struct:
+			return d.decodeStruct(dst, t, path)
+		case reflect.Map:
+			return d.decodeMap(dst, t, path)
+		}
+		return d.decodeList(dst, t, path)
+	case isTable && kind == reflect.Ptr && dst.Type().Elem().Kind() == reflect.Struct:
+		if dst.IsNil() {
+			dst.Set(reflect.New(dst.Type().Elem()))
+		}
+		return d.decode(dst.Elem(), v, path)
+	case kind == reflect.Interface && dst.NumMethod() == 0:
+		x, err := toGo(v, nil)
+		if err != nil {
+			return fmt.Sprintf("field '%s': %v", path, err)
+		}
+		if x == nil {
+			dst.Set(reflect.Zero(dst.Type()))
+		} else {
+			dst.Set(reflect.ValueOf(x))
+		}
+		return ""
+	case kind == reflect.Slice && dst.Type().Elem().Kind() == reflect.Uint8:
+		if s, ok := v.(String); ok {
+			dst.SetBytes([]byte(s))
+			return ""
+		}
+	}
+	x, ok := goValue(v, dst.Type())
+	if !ok {
+		if isNumberKind(dst.Kind()) && isNumber(v) {
+			return fmt.Sprintf("field '%s': %s", path, d.state.Message(MsgNoIntRep))
+		}
+		return fmt.Sprintf("field '%s': %s expected, got %s", path, luaTypeOf(dst.Type()), d.state.typeName(v))
+	}
+	dst.Set(x)
+	return ""
+}
+
+// decodeStruct sets the fields of the struct dst from t.
+func (d *decoder) decodeStruct(dst reflect.Value, t *table, path string) string {
+	typ := dst.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		tag := f.Tag.Get("lua")
+		if tag == "-" {
+			continue
+		}
+		name, opts := tag, ""
+		if i := strings.IndexByte(tag, ','); i >= 0 {
+			name, opts = tag[:i], tag[i+1:]
+		}
+		if name == "" {
+			name = f.Name
+		}
+		at := name
+		if path != "" {
+			at = path + "." + name
+		}
+		v := t.get(String(name))
+		if IsNone(v) {
+			if opts == "required" {
+				return fmt.Sprintf("field '%s' missing", at)
+			}
+			continue
+		}
+		if err := d.decode(dst.Field(i), v, at); err != "" {
+			return err
+		}
+	}
+	return ""
+}
+
+// decodeList sets the slice or array dst from the sequence t.
+func (d *decoder) decodeList(dst reflect.Value, t *table, path string) string {
+	n := t.length()
+	if dst.Kind() == reflect.Slice {
+		dst.Set(reflect.MakeSlice(dst.Type(), n, n))
+	} else if n > dst.Len() {
+		return fmt.Sprintf("field '%s': at most %d elements expected, got %d", path, dst.Len(), n)
+	}
+	for i := 0; i < n; i++ {
+		if err := d.decode(dst.Index(i), t.list[i], fmt.Sprintf("%s[%d]", path, i+1)); err != "" {
+			return err
+		}
+	}
+	return ""
+}
+
+// decodeMap sets the map dst from the entries of t.
+func (d *decoder) decodeMap(dst reflect.Value, t *table, path string) (err string) {
+	typ := dst.Type()
+	m := reflect.MakeMapWithSize(typ, len(t.list)+len(t.hash))
+	t.ForEach(func(k, v Value) {
+		if err != "" {
+			return
+		}
+		key := reflect.New(typ.Key()).Elem()
+		if err = d.decode(key, k, fmt.Sprintf("%s[%v]", path, k)); err != "" {
+			return
+		}
+		if key.Kind() == reflect.Interface && !hashable(key.Interface()) {
+			err = fmt.Sprintf("field '%s': cannot convert a %s key to a Go map key", path, k.Type())
+			return
+		}
+		val := reflect.New(typ.Elem()).Elem()
+		err = d.decode(val, v, fmt.Sprintf("%s[%v]", path, k))
+		m.SetMapIndex(key, val)
+	})
+	if err == "" {
+		dst.Set(m)
+	}
+	return err
+}
+
+// luaTypeOf returns the name of the Lua type that converts to the Go type typ,
+// for error messages.
+func luaTypeOf(typ reflect.Type) string {
+	switch kind := typ.Kind(); {
+	case isNumberKind(kind):
+		return "number"
+	case kind == reflect.String:
+		return "string"
+	case kind == reflect.Bool:
+		return "boolean"
+	case kind == reflect.Struct, kind == reflect.Slice, kind == reflect.Array, kind == reflect.Map:
+		return "table"
+	}
+	return typ.String()
+}
@@ -0,0 +1,92 @@
+package lua
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCheckStruct(t *testing.T) {
+	type server struct {
+		Host string `lua:"host"`
+		Port int    `lua:"port,required"`
+	}
+	type config struct {
+		Name    string
+		Servers []server          `lua:"servers"`
+		Primary *server           `lua:"primary"`
+		Limits  map[string]uint16 `lua:"limits"`
+		Extra   interface{}       `lua:"extra"`
+		Index   map[interface{}]int
+		Verbose bool `lua:"-"`
+	}
+	state := NewState()
+	defer state.Close()
+
+	check := func(arg interface{}, ptr interface{}) error {
+		state.Push(Func(func(state *State) int {
+			state.CheckStruct(1, ptr)
+			return 0
+		}))
+		if err := state.PushValue(arg); err != nil {
+			t.Fatal(err)
+		}
+		return state.PCall(1, 0, 0)
+	}
+
+	cfg := config{Name: "default", Verbose: true}
+	err := check(map[string]interface{}{
+		"servers": []interface{}{map[string]interface{}{"host": "a", "port": 80}},
+		"primary": map[string]interface{}{"port": 8080.0},
+		"limits":  map[string]interface{}{"conns": 10},
+		"extra":   []interface{}{1, "x"},
+		"Verbose": false,
+	}, &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := config{
+		Name:    "default",
+		Servers: []server{{"a", 80}},
+		Primary: &server{Port: 8080},
+		Limits:  map[string]uint16{"conns": 10},
+		Extra:   []interface{}{int64(1), "x"},
+		Verbose: true,
+	}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Errorf("CheckStruct:\n got %+v\nwant %+v", cfg, want)
+	}
+
+	// {[{}] = 1}
+	state.NewTable()
+	state.NewTable()
+	state.Push(1)
+	state.SetTable(-3)
+	tableKey := state.Pop()
+
+	for _, test := range []struct {
+		arg  interface{}
+		want string
+	}{
+		{map[string]interface{}{"Name": 1}, "bad argument #1 to '?' (field 'Name': string expected, got number)"},
+		{map[string]interface{}{"primary": map[string]interface{}{}}, "bad argument #1 to '?' (field 'primary.port' missing)"},
+		{map[string]interface{}{"servers": []interface{}{map[string]interface{}{"port": 1.5}}}, "bad argument #1 to '?' (field 'servers[1].port': number has no integer representation)"},
+		{"config", "bad argument #1 to '?' (table expected, got string)"},
+		{map[string]interface{}{"extra": tableKey}, "bad argument #1 to '?' (field 'extra': cannot convert a table key to a Go map key)"},
+		{map[string]interface{}{"Index": tableKey}, "bad argument #1 to '?' (field 'Index': cannot convert a table key to a Go map key)"},
+	} {
+		var cfg config
+		if err := check(test.arg, &cfg); err == nil || err.Error() != test.want {
+			t.Errorf("CheckStruct(%v) = %v; want %q", test.arg, err, test.want)
+		}
+	}
+
+	var hosts []string
+	state.Push(Func(func(state *State) int {
+		state.CheckSlice(1, &hosts)
+		return 0
+	}))
+	state.PushValue([]string{"a", "b"})
+	if err := state.PCall(1, 0, 0); err != nil || !reflect.DeepEqual(hosts, []string{"a", "b"}) {
+		t.Errorf("CheckSlice = %q, %v", hosts, err)
+	}
+}
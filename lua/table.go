@@ -37,8 +37,9 @@ type table struct {
 	meta *table
 
 	// iterator state
-	iter []Value
-	keys map[Value]int
+	iter  []Value
+	keys  map[Value]int
+	grown bool // a key was added during the traversal
 }
 
 func (x *table) String() string { return fmt.Sprintf("table: %p", x) }
@@ -48,7 +49,9 @@ func (x *table) Type() Type     { return TableType }
 func (x *table) ForEach(fn func(Value, Value)) {
 	if x.list != nil {
 		for i, v := range x.list {
-			fn(Int(i), v)
+			if !IsNone(v) {
+				fn(Int(i+1), v)
+			}
 		}
 	}
 	if x.hash != nil {
@@ -97,9 +100,15 @@ func (t *table) rehash() {
 }
 
 func (t *table) set(k, v Value) {
-	if IsNone(k) {
-		return
+	switch k := k.(type) {
+	case Float:
+		if math.IsNaN(float64(k)) {
+			panic(t.state.messageErr(MsgTableIndexNaN))
+		}
+	case nil, Nil:
+		panic(t.state.messageErr(MsgTableIndexNil))
 	}
+	k = normKey(k)
 	isNone := IsNone(v)
 	if n, ok := k.(Number); ok {
 		i := arrayIndex(n) - 1
@@ -113,6 +122,7 @@ func (t *table) set(k, v Value) {
 		if i == len(t.list) {
 			if !isNone {
 				t.list = append(t.list, v)
+				t.grown = t.keys != nil
 			}
 			return
 		}
@@ -122,6 +132,11 @@ func (t *table) set(k, v Value) {
 		delete(t.hash, k)
 		return
 	}
+	if t.keys != nil {
+		if _, ok := t.hash[k]; !ok {
+			t.grown = true
+		}
+	}
 	t.hash[k] = v
 }
 
@@ -129,6 +144,7 @@ func (t *table) get(k Value) Value {
 	if IsNone(k) {
 		return None
 	}
+	k = normKey(k)
 	if n, ok := k.(Number); ok {
 		i := arrayIndex(n) - 1
 		// fmt.Printf("table[%v] (%T) @ %d\n", k, k, i)
@@ -147,6 +163,9 @@ func (t *table) getStr(key string) Value {
 }
 
 func (t *table) getInt(key int64) Value {
+	if key >= 1 && key <= int64(len(t.list)) {
+		return t.list[key-1]
+	}
 	return t.get(Int(key))
 }
 
@@ -158,6 +177,38 @@ func (t *table) setInt(key int64, value Value) {
 	t.set(Int(key), value)
 }
 
+// setList sets t[o+1], ..., t[o+len(vs)] to the values vs. If they extend the
+// array part, it is grown once for all of them, so that filling a table in
+// batches, as SETLIST does for a constructor, does not reallocate or move
+// entries to the hash part; the slots that NEWTABLE reserved beyond the last
+// value are then released.
+func (t *table) setList(o int, vs []Value) {
+	n := o + len(vs)
+	if o > len(t.list) {
+		for i, v := range vs {
+			t.setInt(int64(o+i+1), v)
+		}
+		return
+	}
+	if n > cap(t.list) {
+		list := make([]Value, len(t.list), n)
+		copy(list, t.list)
+		t.list = list
+	}
+	if n > len(t.list) {
+		t.list = t.list[:n]
+	}
+	for i, v := range vs {
+		t.list[o+i] = v
+	}
+	if len(t.hash) > 0 { // keys now in the array part
+		for i := o + 1; i <= n; i++ {
+			delete(t.hash, Int(i))
+		}
+	}
+	t.rehash()
+}
+
 func (t *table) exists(key Value) bool {
 	return !IsNone(t.get(key))
 }
@@ -166,8 +217,19 @@ func (t *table) length() int {
 	return len(t.list)
 }
 
+// next returns the entry that follows key in a traversal of t, or the first
+// entry if key is nil, and whether there is one.
+//
+// A traversal visits the keys of the table when it started, skipping those
+// cleared since. Adding keys during a traversal is not allowed, as in Lua; in a
+// state created with WithChecks, the next call of next raises "invalid key to
+// 'next'" for it rather than leaving the new keys out.
 func (t *table) next(key Value) (k, v Value, more bool) {
+	if t.grown && !IsNone(key) && t.state.global.config.check {
+		panic(t.state.messageErr(MsgInvalidNextKey, key))
+	}
 	if IsNone(key) || t.keys == nil { // first iteration?
+		t.grown = false
 		t.keys = make(map[Value]int, len(t.hash))
 		t.iter = make([]Value, 0, len(t.hash))
 		for k := range t.hash {
@@ -184,16 +246,18 @@ func (t *table) next(key Value) (k, v Value, more bool) {
 			}
 		}
 	} else {
-		if index = index - len(t.list); index < len(t.iter) {
+		for index -= len(t.list); index < len(t.iter); index++ {
 			k := t.iter[index]
-			v := t.hash[k]
-			return k, v, true
+			if v, ok := t.hash[k]; ok {
+				return k, v, true
+			}
 		}
 	}
 
 	// Key did not exist or iteration ended.
 	t.iter = nil
 	t.keys = nil
+	t.grown = false
 
 	return None, None, false
 }
@@ -205,6 +269,7 @@ func (t *table) iterKey(key Value) (index int) {
 	if IsNone(key) {
 		return 0
 	} // first iteration?
+	key = normKey(key)
 	index = arrayIndex(key)
 	if index != 0 && index <= len(t.list) { // key in array?
 		return index // found index
@@ -212,23 +277,34 @@ func (t *table) iterKey(key Value) (index int) {
 	// otherwise key is in hash part.
 	var found bool
 	if index, found = t.keys[key]; !found {
-		panic(runtimeErr(fmt.Errorf("invalid key (%v) to 'next'", key)))
+		panic(t.state.messageErr(MsgInvalidNextKey, key))
 	}
 	// hash elements are numbered after array ones.
 	return index + 1 + len(t.list)
 }
 
-const maxInt = int(^uint(0) >> 1)
+// normKey converts a float key with an integral value to an integer, so that
+// keys that are equal numbers, such as 2 and 2.0 or 0 and -0.0, index the same
+// entry as in Lua 5.3.
+func normKey(key Value) Value {
+	if f, ok := key.(Float); ok {
+		if x := float64(f); x >= math.MinInt64 && x < math.MaxInt64 && x == math.Trunc(x) {
+			return Int(int64(x))
+		}
+	}
+	return key
+}
 
 func arrayIndex(val Value) int {
 	switch val := val.(type) {
 	case Float:
-		if x, ok := float2int(float64(val)); ok {
+		if x, ok := float2int(float64(val)); ok && x > 0 {
 			return x
 		}
 	case Int:
-		if x := int(val); x > 0 && x < maxInt {
-			return x
+		// compare before converting, which truncates on 32-bit platforms.
+		if val > 0 && int64(val) < int64(MaxSize) {
+			return int(val)
 		}
 	}
 	return 0
@@ -238,9 +314,22 @@ func float2int(f64 float64) (int, bool) {
 	if math.IsInf(f64, 0) || math.IsNaN(f64) {
 		return 0, false
 	} else {
-		if i64 := int64(f64); float64(i64) == f64 {
+		if i64 := int64(f64); float64(i64) == f64 && i64 > -int64(MaxSize) && i64 < int64(MaxSize) {
 			return int(i64), true
 		}
 		return 0, false
 	}
 }
+
+// ClampInt converts the Lua integer n to an int, saturating at -MaxSize and
+// MaxSize instead of truncating on 32-bit platforms. Positions and counts
+// beyond any string or table size keep their meaning when clamped.
+func ClampInt(n int64) int {
+	switch {
+	case n > int64(MaxSize):
+		return MaxSize
+	case n < -int64(MaxSize):
+		return -MaxSize
+	}
+	return int(n)
+}
@@ -0,0 +1,153 @@
+package lua
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/Azure/golua/lua/vm"
+)
+
+func TestTableKeys(t *testing.T) {
+	state := NewState()
+	defer state.Close()
+
+	tbl := newTable(state, 0, 0)
+	tbl.set(Float(2), String("two"))
+	tbl.set(Float(math.Copysign(0, -1)), String("zero"))
+	tbl.set(Float(1<<60), String("big"))
+	tbl.set(Float(1.5), String("half"))
+
+	var tests = []struct {
+		key  Value
+		want Value
+	}{
+		{Int(2), String("two")},
+		{Float(2), String("two")},
+		{Int(0), String("zero")},
+		{Float(0), String("zero")},
+		{Int(1 << 60), String("big")},
+		{Float(1.5), String("half")},
+		{Float(math.NaN()), None},
+	}
+	for _, test := range tests {
+		if got := tbl.get(test.key); got != test.want {
+			t.Errorf("t[%v] = %v; want %v", test.key, got, test.want)
+		}
+	}
+	tbl.ForEach(func(k, _ Value) {
+		if f, ok := k.(Float); ok && f != 1.5 {
+			t.Errorf("key %v stored as a float", k)
+		}
+	})
+
+	for _, key := range []Value{Float(math.NaN()), Nil(1), nil} {
+		err := func() (err error) {
+			defer func() { err, _ = recover().(error) }()
+			tbl.set(key, True)
+			return nil
+		}()
+		want := "table index is nil"
+		if _, ok := key.(Float); ok {
+			want = "table index is NaN"
+		}
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("t[%v] = true: error = %v; want %s", key, err, want)
+		}
+	}
+}
+
+func TestTableConstructor(t *testing.T) {
+	state := NewState()
+	defer state.Close()
+
+	// local t = {...}; return t, #t
+	// NEWTABLE reserves 18 slots for 17 values.
+	list := []uint32{
+		iABC(vm.NEWTABLE, 0, 17, 0),
+		iABC(vm.VARARG, 1, 0, 0),
+		iABC(vm.SETLIST, 0, 0, 1),
+		iABC(vm.LEN, 1, 0, 0),
+		iABC(vm.RETURN, 0, 3, 0),
+	}
+	args := make([]interface{}, 17)
+	for i := range args {
+		args[i] = int64(i + 1)
+	}
+	if got := runProto(t, state, list, nil, args...); got[1] != Int(17) {
+		t.Errorf("#{...} = %v; want 17", got[1])
+	}
+
+	// Block 2 of a SETLIST whose block number is in EXTRAARG.
+	list[2] = iABC(vm.SETLIST, 0, 0, 0)
+	list = append(list[:3], append([]uint32{uint32(vm.EXTRAARG) | 2<<6}, list[3:]...)...)
+	got := runProto(t, state, list, nil, args...)
+	if tbl := got[0].(*table); tbl.getInt(FieldsPerFlush+1) != Int(1) || tbl.getInt(FieldsPerFlush+17) != Int(17) {
+		t.Errorf("SETLIST with EXTRAARG did not set t[%d] to t[%d]", FieldsPerFlush+1, FieldsPerFlush+17)
+	}
+}
+
+func TestSetList(t *testing.T) {
+	state := NewState()
+	defer state.Close()
+
+	state.NewTable()
+	for first := int64(1); first <= 10; first += 5 {
+		for i := first; i < first+5; i++ {
+			state.Push(i * 10)
+		}
+		state.SetList(-6, first, 5)
+	}
+	if n := state.RawLen(-1); n != 10 {
+		t.Errorf("#t = %d; want 10", n)
+	}
+	if state.RawGetIndex(-1, 7); state.ToInt(-1) != 70 {
+		t.Errorf("t[7] = %v; want 70", state.ToString(-1))
+	}
+}
+
+func TestTableNextMutation(t *testing.T) {
+	traverse := func(state *State, mutate func(tbl *table, k Value)) (keys int, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = r.(error)
+			}
+		}()
+		tbl := newTable(state, 0, 0)
+		for _, k := range []string{"a", "b", "c"} {
+			tbl.set(String(k), True)
+		}
+		for k, _, more := tbl.next(None); more; k, _, more = tbl.next(k) {
+			keys++
+			mutate(tbl, k)
+		}
+		return keys, nil
+	}
+
+	state := NewState()
+	defer state.Close()
+	// Clearing and assigning existing fields is allowed.
+	keys, err := traverse(state, func(tbl *table, k Value) {
+		tbl.set(k, None)
+		tbl.set(String("a"), False)
+	})
+	if err != nil || keys == 0 || keys > 3 {
+		t.Errorf("clearing during traversal: %d keys, %v", keys, err)
+	}
+	// Adding keys goes unnoticed by default.
+	if _, err := traverse(state, func(tbl *table, k Value) { tbl.set(String("new"), True) }); err != nil {
+		t.Errorf("adding during traversal without checks: %v", err)
+	}
+
+	checked := NewState(WithChecks(true))
+	defer checked.Close()
+	for name, add := range map[string]func(tbl *table){
+		"hash":  func(tbl *table) { tbl.set(String("new"), True) },
+		"array": func(tbl *table) { tbl.set(Int(1), True) },
+	} {
+		keys, err := traverse(checked, func(tbl *table, k Value) { add(tbl) })
+		if err == nil || !strings.Contains(err.Error(), "to 'next'") || keys != 1 {
+			t.Errorf("adding to the %s part during traversal: %d keys, %v", name, keys, err)
+		}
+	}
+}
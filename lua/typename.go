@@ -0,0 +1,59 @@
+package lua
+
+import (
+	"reflect"
+)
+
+// RegisterTypeName sets the name shown in error messages for userdata holding
+// Go values of the same type as sample, so that a binding can report
+// "Entity expected, got Item" instead of "userdata expected, got userdata":
+//
+//	state.RegisterTypeName((*Entity)(nil), "Entity")
+//
+// The name is shared by all threads of the state.
+func (state *State) RegisterTypeName(sample interface{}, name string) {
+	g := state.global
+	if g.typeNames == nil {
+		g.typeNames = make(map[reflect.Type]string)
+	}
+	g.typeNames[reflect.TypeOf(sample)] = name
+}
+
+// TypeName returns the name of the type of the value at index as shown in
+// error messages: the name registered for the Go type of a userdata (see
+// RegisterTypeName), the __name field of its metatable if it is a string, or
+// else the name of its Lua type.
+func (state *State) TypeName(index int) string {
+	return state.typeName(state.valueAt(index))
+}
+
+// typeName returns the name of the type of v as shown in error messages.
+func (state *State) typeName(v Value) string {
+	if udata, ok := v.(*Object); ok && udata.data != nil {
+		if name, ok := state.global.typeNames[reflect.TypeOf(udata.data)]; ok {
+			return name
+		}
+	}
+	if name, ok := state.metafield(v, "__name").(String); ok {
+		return string(name)
+	}
+	return v.Type().String()
+}
+
+// CheckGoValue checks whether the function argument at index is a userdata
+// holding a Go value of the same type as sample, and returns the value. The
+// error names both types with their registered names:
+//
+//	entity := state.CheckGoValue(1, (*Entity)(nil)).(*Entity)
+func (state *State) CheckGoValue(index int, sample interface{}) interface{} {
+	typ := reflect.TypeOf(sample)
+	if udata := state.ToUserData(index); udata != nil && reflect.TypeOf(udata.data) == typ {
+		return udata.data
+	}
+	want, ok := state.global.typeNames[typ]
+	if !ok {
+		want = typ.String()
+	}
+	typeError(state, index, want)
+	return nil
+}
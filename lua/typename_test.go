@@ -0,0 +1,119 @@
+package lua
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/Azure/golua/lua/vm"
+)
+
+type item struct{}
+
+func TestTypeNameErrors(t *testing.T) {
+	state := NewState()
+	defer state.Close()
+
+	state.RegisterTypeName((*entity)(nil), "Entity")
+	state.RegisterTypeName((*item)(nil), "Item")
+	check := func(arg int) Func {
+		return func(state *State) int {
+			state.CheckGoValue(arg, (*entity)(nil))
+			return 0
+		}
+	}
+	state.Push(check(1))
+	state.SetGlobal("attack")
+	state.NewTable()
+	state.Push(check(2))
+	state.SetField(-2, "hit")
+	state.Push(check(1))
+	state.SetField(-2, "heal")
+	obj := state.Pop()
+
+	// attack(...)
+	call := []uint32{
+		iABC(vm.GETTABUP, 0, 0, rk(0)), // R0 := attack
+		iABC(vm.VARARG, 1, 0, 0),       // R1... := ...
+		iABC(vm.CALL, 0, 0, 1),         // attack(...)
+		iABC(vm.RETURN, 0, 1, 0),       // return
+	}
+	// local obj, x = ...; obj:<method>(x)
+	method := []uint32{
+		iABC(vm.VARARG, 0, 3, 0),   // R0, R1 := ...
+		iABC(vm.SELF, 2, 0, rk(0)), // R3 := obj; R2 := obj.<method>
+		iABC(vm.MOVE, 4, 1, 0),     // R4 := x
+		iABC(vm.CALL, 2, 3, 1),     // obj:<method>(x)
+		iABC(vm.RETURN, 0, 1, 0),   // return
+	}
+	tests := []struct {
+		code []uint32
+		name string
+		args []interface{}
+		want string
+	}{
+		{call, "attack", []interface{}{&item{}}, "bad argument #1 to 'attack' (Entity expected, got Item)"},
+		{method, "hit", []interface{}{obj, &item{}}, "bad argument #1 to 'hit' (Entity expected, got Item)"},
+		{method, "heal", []interface{}{obj, &entity{}}, "calling 'heal' on bad self (Entity expected, got table)"},
+	}
+	for _, tt := range tests {
+		if err := loadProto(state, tt.code, tt.name); err != nil {
+			t.Fatal(err)
+		}
+		for _, arg := range tt.args {
+			state.Push(arg)
+		}
+		if err := state.PCall(len(tt.args), 0, 0); err == nil || err.Error() != tt.want {
+			t.Errorf("%s: error = %v; want %q", tt.name, err, tt.want)
+		}
+		state.SetTop(0)
+	}
+
+	// Called from Go, the function is named after its field in package.loaded.
+	state.GetSubTable(RegistryIndex, LoadedKey)
+	state.RawGetIndex(RegistryIndex, GlobalsIndex)
+	state.SetField(-2, "_G")
+	state.Pop()
+	state.GetGlobal("attack")
+	state.Push(&item{})
+	if err, want := state.PCall(1, 0, 0), "bad argument #1 to 'attack' (Entity expected, got Item)"; err == nil || err.Error() != want {
+		t.Errorf("attack from Go: error = %v; want %q", err, want)
+	}
+}
+
+func TestTypeNameMeta(t *testing.T) {
+	state := NewState()
+	defer state.Close()
+
+	// setmetatable({}, {__name = "MyType"})
+	state.NewTable()
+	state.NewTable()
+	state.Push("MyType")
+	state.SetField(-2, "__name")
+	state.SetMetaTableAt(-2)
+	obj := state.Pop()
+
+	state.Push(obj)
+	if got := state.ToStringMeta(-1); !regexp.MustCompile(`^MyType: 0x[0-9a-f]+$`).MatchString(got) {
+		t.Errorf("tostring = %q; want MyType: 0x...", got)
+	}
+	state.SetTop(0)
+
+	state.Register("count", func(state *State) int {
+		state.CheckInt(1)
+		return 0
+	})
+	// count(...)
+	code := []uint32{
+		iABC(vm.GETTABUP, 0, 0, rk(0)), // R0 := count
+		iABC(vm.VARARG, 1, 0, 0),       // R1... := ...
+		iABC(vm.CALL, 0, 0, 1),         // count(...)
+		iABC(vm.RETURN, 0, 1, 0),       // return
+	}
+	if err := loadProto(state, code, "count"); err != nil {
+		t.Fatal(err)
+	}
+	state.Push(obj)
+	if err, want := state.PCall(1, 0, 0), "bad argument #1 to 'count' (number expected, got MyType)"; err == nil || err.Error() != want {
+		t.Errorf("count(obj): error = %v; want %q", err, want)
+	}
+}
@@ -2,9 +2,11 @@ package lua
 
 import (
 	"fmt"
+	"math"
 	"math/big"
 	"reflect"
 	"runtime"
+	"strings"
 )
 
 type Type int
@@ -84,10 +86,31 @@ type Table interface {
 
 type Float float64
 
-func (x Float) String() string { return fmt.Sprintf("%.14g", float64(x)) }
+func (x Float) String() string { return formatFloat(float64(x)) }
 func (x Float) Type() Type     { return NumberType }
 func (Float) number()          {}
 
+// formatFloat formats f as Lua 5.3 does ("%.14g"), adding ".0" to floats that
+// would read as integers: 3.0 is "3.0" while the integer 3 is "3".
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, +1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		if math.Signbit(f) {
+			return "-nan"
+		}
+		return "nan"
+	}
+	s := fmt.Sprintf("%.14g", f)
+	if strings.Trim(s, "-0123456789") == "" { // looks like an int?
+		s += ".0"
+	}
+	return s
+}
+
 type String string
 
 func (x String) String() string { return string(x) }
@@ -197,8 +220,7 @@ func toString(value Value) (string, bool) {
 	case String:
 		return string(value), true
 	case Float:
-		s := fmt.Sprintf("%v", float64(value))
-		return s, true
+		return formatFloat(float64(value)), true
 	case Int:
 		s := fmt.Sprintf("%v", int64(value))
 		return s, true
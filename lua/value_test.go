@@ -0,0 +1,161 @@
+package lua
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestNumberToString(t *testing.T) {
+	state := NewState()
+	defer state.Close()
+
+	var tests = []struct {
+		value Value
+		want  string
+	}{
+		{Int(3), "3"},
+		{Float(3), "3.0"},
+		{Float(-0.0), "0.0"},
+		{Float(math.Copysign(0, -1)), "-0.0"},
+		{Float(0.1), "0.1"},
+		{Float(-2.5), "-2.5"},
+		{Float(1e15), "1e+15"},
+		{Float(1e14), "1e+14"},
+		{Float(123456789012345), "1.2345678901234e+14"},
+		{Float(1e100), "1e+100"},
+		{Int(math.MaxInt64), "9223372036854775807"},
+		{Int(math.MinInt64), "-9223372036854775808"},
+		{Float(math.MaxInt64), "9.2233720368548e+18"},
+		{Float(math.Inf(1)), "inf"},
+		{Float(math.Inf(-1)), "-inf"},
+		{Float(math.NaN()), "nan"},
+		{Float(-math.NaN()), "-nan"},
+	}
+	for _, test := range tests {
+		state.Push(test.value)
+		if got := state.ToStringMeta(-1); got != test.want {
+			t.Errorf("tostring(%#v) = %q; want %q", test.value, got, test.want)
+		}
+		if got, _ := state.TryString(-2); got != test.want {
+			t.Errorf("%#v .. '' = %q; want %q", test.value, got, test.want)
+		}
+		state.SetTop(0)
+	}
+}
+
+func TestArith(t *testing.T) {
+	state := NewState()
+	defer state.Close()
+
+	arith := func(op Op, x, y Value) (Value, error) {
+		state.Push(Func(func(state *State) int {
+			state.Arith(op)
+			return 1
+		}))
+		state.Push(x)
+		state.Push(y)
+		if err := state.PCall(2, 1, 0); err != nil {
+			return nil, err
+		}
+		return state.Pop(), nil
+	}
+	var tests = []struct {
+		op   Op
+		x, y Value
+		want Value // or the error message
+	}{
+		{OpQuo, Int(7), Int(2), Int(3)},
+		{OpQuo, Int(-7), Int(2), Int(-4)},
+		{OpQuo, Int(7), Int(-1), Int(-7)},
+		{OpQuo, Int(math.MinInt64), Int(-1), Int(math.MinInt64)},
+		{OpQuo, Float(7), Int(2), Float(3)},
+		{OpQuo, Int(1), Int(0), String("attempt to divide by zero")},
+		{OpDiv, Int(7), Int(2), Float(3.5)},
+		{OpMod, Int(-1), Int(3), Int(2)},
+		{OpMod, Float(-1), Float(3), Float(2)},
+		{OpMod, Float(1), Float(-3), Float(-2)},
+		{OpMod, Float(5.5), Float(2), Float(1.5)},
+		{OpMod, Int(1), Int(0), String("attempt to perform n%0")},
+		{OpAnd, Int(6), Float(3), Int(2)},
+		{OpOr, String("8"), Int(1), Int(9)},
+		{OpXor, Int(5), Int(1), Int(4)},
+		{OpLsh, Int(1), Int(63), Int(math.MinInt64)},
+		{OpLsh, Int(1), Int(64), Int(0)},
+		{OpLsh, Int(-1), Int(-60), Int(15)},
+		{OpRsh, Int(-1), Int(60), Int(15)},
+		{OpRsh, Int(1), Int(math.MinInt64), Int(0)},
+		{OpLsh, Int(1), Int(math.MinInt64), Int(0)},
+		{OpAnd, Float(1.5), Int(1), String("number has no integer representation")},
+		{OpOr, Float(math.Exp2(63)), Int(1), String("number has no integer representation")},
+	}
+	for _, test := range tests {
+		got, err := arith(test.op, test.x, test.y)
+		if err != nil {
+			got = String(err.Error())
+		}
+		if got != test.want {
+			t.Errorf("arith(%d, %v, %v) = %v (%T); want %v (%T)", test.op, test.x, test.y, got, got, test.want, test.want)
+		}
+	}
+}
+
+func TestPushValue(t *testing.T) {
+	state := NewState()
+	defer state.Close()
+
+	in := map[string]interface{}{
+		"name":  "orc",
+		"level": 3,
+		"speed": 1.5,
+		"tags":  []string{"green", "angry"},
+		"stats": map[string]int{"hp": 10},
+		"loot":  []interface{}{[]byte("gold"), nil, true},
+		"grid":  [2][2]int{{1, 2}, {3, 4}},
+		"none":  map[string]int(nil),
+	}
+	if err := state.PushValue(in); err != nil {
+		t.Fatal(err)
+	}
+	state.GetField(-1, "tags")
+	state.GetIndex(-1, 2)
+	if s := state.ToString(-1); s != "angry" {
+		t.Errorf("tags[2] = %q", s)
+	}
+	state.PopN(2)
+	got, err := state.ToGoValue(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"name":  "orc",
+		"level": int64(3),
+		"speed": 1.5,
+		"tags":  []interface{}{"green", "angry"},
+		"stats": map[string]interface{}{"hp": int64(10)},
+		"loot":  map[interface{}]interface{}{int64(1): "gold", int64(3): true},
+		"grid":  []interface{}{[]interface{}{int64(1), int64(2)}, []interface{}{int64(3), int64(4)}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip:\n got %v\nwant %v", got, want)
+	}
+	state.Pop()
+
+	cycle := []interface{}{1, nil}
+	cycle[1] = cycle
+	if err := state.PushValue(cycle); err == nil || state.Top() != 0 {
+		t.Errorf("PushValue of a cycle: %v, %d values pushed", err, state.Top())
+	}
+	if err := state.PushValue(map[float64]int{math.NaN(): 1}); err == nil {
+		t.Error("PushValue of a NaN key succeeded")
+	}
+
+	// {[{}] = 1} has no Go map equivalent.
+	state.NewTable()
+	state.NewTable()
+	state.Push(1)
+	state.SetTable(-3)
+	if _, err := state.ToGoValue(-1); err == nil || err.Error() != "cannot convert a table key to a Go map key" {
+		t.Errorf("ToGoValue of a table with a table key: error = %v", err)
+	}
+}
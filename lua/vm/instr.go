@@ -59,3 +59,18 @@ func args(instr Instr) string {
 	}
 	panic(fmt.Sprintf("ir: unknown op mode: %d", instr.Code().Mode()))
 }
+
+// ABC returns the iABC instruction op A B C.
+func ABC(op Code, a, b, c int) Instr {
+	return Instr(op) | Instr(a)<<6 | Instr(c)<<14 | Instr(b)<<23
+}
+
+// ABx returns the iABx instruction op A Bx.
+func ABx(op Code, a, bx int) Instr {
+	return Instr(op) | Instr(a)<<6 | Instr(bx)<<14
+}
+
+// AsBx returns the iAsBx instruction op A sBx.
+func AsBx(op Code, a, sbx int) Instr {
+	return ABx(op, a, sbx+MaxArgSBX)
+}
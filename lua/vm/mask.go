@@ -76,7 +76,7 @@ func (mask Mask) C(carg ArgMask) bool { return carg == ArgMask((mask>>2)&3) }
 
 func (mask Mask) Mode() Mode { return Mode(mask & 3) }
 
-func (mask Mask) SetA() bool { return mask&(1<<6) == 1 }
+func (mask Mask) SetA() bool { return mask&(1<<6) != 0 }
 
 func (mask Mask) Test() bool { return mask&(1<<7) == 1 }
 
@@ -1,25 +1,30 @@
-// string: 's' (prefix), 'z' (varlen), 'c' (fixed)
-// float:  'f', 'd', 'n'
-// sint:   'b', 'h', 'l', 'j', 'i'
-// uint:   'B', 'H', 'L', 'J', 'I', 'T'
+// Package packer implements the binary packing of Lua 5.3, as used by
+// string.pack, string.unpack and string.packsize.
 //
-// padding, space, config options: "xX <=>!"
+// A format string is a sequence of options:
 //
-// values options: "bBhHlLjJTiIfdnczs"
-//		i[n], I[n]
-//		b, B
-//		h, H
-//		l, L
-//		j, J
-//		T
-//		f
-//		d
-//		n
-//		z
-//		cn
-//		s[n]
+//	<       sets little endian
+//	>       sets big endian
+//	=       sets native endian (little endian)
+//	![n]    sets maximum alignment to n (default is native alignment, 8)
+//	b, B    a signed, unsigned char
+//	h, H    a signed, unsigned short (2 bytes)
+//	l, L    a signed, unsigned long (8 bytes)
+//	j, J    a lua_Integer, lua_Unsigned (8 bytes)
+//	T       a size_t (8 bytes)
+//	i[n]    a signed int with n bytes (default is 4)
+//	I[n]    an unsigned int with n bytes (default is 4)
+//	f, d, n a float, a double, a lua_Number (a double)
+//	s[n]    a string preceded by its length coded as an unsigned
+//	        integer with n bytes (default is 8)
+//	z       a zero-terminated string
+//	cn      a fixed-sized string with n bytes
+//	x       one byte of padding
+//	Xop     an empty item that aligns according to option op
+//	' '     (empty space) ignored
 //
-// options "!n", "sn", "in", "In" ; 1 <= n <= 16
+// For options "!n", "sn", "in" and "In", n can be any integer between 1 and
+// 16.
 //
 // Alignment works as follows: For each option, the format gets extra
 // padding until the data starts at an offset that is a multiple of
@@ -29,58 +34,374 @@
 //
 // Any format string starts as if prefixed by "!1=", that is, with maximum
 // alignment of 1 (no alignment) and native endianness.
+//
+// The implementation is a port of the one of the reference implementation
+// (lstrlib.c), and reports the same errors.
+//
+// See https://www.lua.org/manual/5.3/manual.html#6.4.2
 package packer
 
-type (
-	Unpacker interface {
-		Unpack(state State) error
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+const (
+	maxIntSize = 16                 // maximum size for the binary representation of an integer
+	maxAlign   = 8                  // native alignment: that of a double, a pointer or a lua_Integer
+	intSize    = 8                  // size of a lua_Integer
+	maxSize    = int(^uint(0) >> 1) // maximum size of a packed string
+	padByte    = 0x00               // value used for padding
+)
+
+// ArgError is an error in an argument of string.pack, string.unpack or
+// string.packsize, numbered from 1 as in Lua.
+type ArgError struct {
+	Arg int
+	Msg string
+}
+
+func (err *ArgError) Error() string { return fmt.Sprintf("bad argument #%d (%s)", err.Arg, err.Msg) }
+
+// Values supplies the values to pack: the methods return argument arg of
+// string.pack, numbered from 1 as in Lua, converted to an integer, a float or
+// a string.
+type Values interface {
+	Int(arg int) int64
+	Float(arg int) float64
+	String(arg int) string
+}
+
+// Pack returns the values packed according to format.
+func Pack(format string, values Values) (b []byte, err error) {
+	defer catch(&err)
+	h := newHeader(format)
+	for arg := 1; h.more(); {
+		opt, size, align := h.details(len(b))
+		for ; align > 0; align-- {
+			b = append(b, padByte)
+		}
+		arg++
+		switch opt {
+		case kInt:
+			n := values.Int(arg)
+			if size < intSize {
+				lim := int64(1) << (size*8 - 1)
+				check(-lim <= n && n < lim, arg, "integer overflow")
+			}
+			b = packInt(b, uint64(n), h.little, size, n < 0)
+		case kUint:
+			n := values.Int(arg)
+			if size < intSize {
+				check(uint64(n) < uint64(1)<<(size*8), arg, "unsigned overflow")
+			}
+			b = packInt(b, uint64(n), h.little, size, false)
+		case kFloat:
+			n := values.Float(arg)
+			if size == 4 {
+				b = packInt(b, uint64(math.Float32bits(float32(n))), h.little, size, false)
+			} else {
+				b = packInt(b, math.Float64bits(n), h.little, size, false)
+			}
+		case kChar:
+			s := values.String(arg)
+			check(len(s) <= size, arg, "string longer than given size")
+			b = append(b, s...)
+			for n := len(s); n < size; n++ {
+				b = append(b, padByte)
+			}
+		case kString:
+			s := values.String(arg)
+			check(size >= intSize || uint64(len(s)) < uint64(1)<<(size*8), arg, "string length does not fit in given size")
+			b = packInt(b, uint64(len(s)), h.little, size, false)
+			b = append(b, s...)
+		case kZstr:
+			s := values.String(arg)
+			check(strings.IndexByte(s, 0) < 0, arg, "string contains zeros")
+			b = append(b, s...)
+			b = append(b, 0)
+		case kPadding:
+			b = append(b, padByte)
+			arg--
+		default: // kPaddAlign, kNop
+			arg--
+		}
+	}
+	return b, nil
+}
+
+// Unpack returns the values packed in data according to format, starting at
+// data[pos], and the position of the first unread byte. Integers are returned
+// as int64, floats as float64 and strings as string.
+func Unpack(format, data string, pos int) (values []interface{}, next int, err error) {
+	defer catch(&err)
+	check(pos >= 0 && pos <= len(data), 3, "initial position out of string")
+	h := newHeader(format)
+	for h.more() {
+		opt, size, align := h.details(pos)
+		check(align+size <= len(data)-pos, 2, "data string too short")
+		pos += align
+		switch opt {
+		case kInt, kUint:
+			values = append(values, unpackInt(data[pos:], h.little, size, opt == kInt))
+		case kFloat:
+			n := uint64(unpackInt(data[pos:], h.little, size, false))
+			if size == 4 {
+				values = append(values, float64(math.Float32frombits(uint32(n))))
+			} else {
+				values = append(values, math.Float64frombits(n))
+			}
+		case kChar:
+			values = append(values, data[pos:pos+size])
+		case kString:
+			n := uint64(unpackInt(data[pos:], h.little, size, false))
+			check(n <= uint64(len(data)-pos-size), 2, "data string too short")
+			values = append(values, data[pos+size:pos+size+int(n)])
+			pos += int(n)
+		case kZstr:
+			n := strings.IndexByte(data[pos:], 0)
+			check(n >= 0, 2, "unfinished string for format 'z'")
+			values = append(values, data[pos:pos+n])
+			pos += n + 1
+		}
+		pos += size
 	}
+	return values, pos, nil
+}
 
-	Packer interface {
-		Pack(state State) error
+// Size returns the size of the strings packed according to format, which
+// cannot have the variable-length options 's' or 'z'.
+func Size(format string) (size int, err error) {
+	defer catch(&err)
+	h := newHeader(format)
+	for h.more() {
+		opt, n, align := h.details(size)
+		check(opt != kString && opt != kZstr, 1, "variable-length format")
+		n += align
+		check(size <= maxSize-n, 1, "format result too large")
+		size += n
 	}
+	return size, nil
+}
+
+// packError is an error in a format string.
+type packError string
+
+func (err packError) Error() string { return string(err) }
 
-	Option interface {
-		Format() (verb rune)
-		Width() (width uint)
-		Align() (align uint)
+// catch recovers an error raised while packing into err.
+func catch(err *error) {
+	switch r := recover().(type) {
+	case nil:
+	case packError:
+		*err = r
+	case *ArgError:
+		*err = r
+	default:
+		panic(r)
 	}
+}
 
-	State interface {
-		Option() Option
+// check raises an ArgError for arg unless ok.
+func check(ok bool, arg int, msg string) {
+	if !ok {
+		panic(&ArgError{Arg: arg, Msg: msg})
 	}
+}
+
+func errorf(format string, args ...interface{}) {
+	panic(packError(fmt.Sprintf(format, args...)))
+}
+
+type kOption int
+
+const (
+	kInt       kOption = iota // signed integers
+	kUint                     // unsigned integers
+	kFloat                    // floating-point numbers
+	kChar                     // fixed-length strings
+	kString                   // strings with prefixed length
+	kZstr                     // zero-terminated strings
+	kPadding                  // padding
+	kPaddAlign                // padding for alignment
+	kNop                      // no-op (configuration or spaces)
 )
 
-// Unpack returns the values packed in string s (see Pack) according to the
-// format string. An optional pos marks where to start reading in s (default
-// is 1). After the read values, this function also returns the index of the
-// first unread byte in s.
-func Unpack(format string, values ...interface{}) (int, error) {
-	p, err := newState(format)
-	if err != nil {
-		return 0, err
+// header is the state of a format string being read.
+type header struct {
+	format   string
+	pos      int
+	little   bool
+	maxAlign int
+}
+
+func newHeader(format string) *header {
+	return &header{format: format, little: true, maxAlign: 1}
+}
+
+func (h *header) more() bool { return h.pos < len(h.format) }
+
+func (h *header) digit() bool {
+	return h.more() && '0' <= h.format[h.pos] && h.format[h.pos] <= '9'
+}
+
+// num reads a number, or returns df if there is none.
+func (h *header) num(df int) int {
+	if !h.digit() {
+		return df
+	}
+	a := 0
+	for {
+		a = a*10 + int(h.format[h.pos]-'0')
+		h.pos++
+		if !h.digit() || a > (maxSize-9)/10 {
+			return a
+		}
+	}
+}
+
+// numLimit reads the size of an integer, or returns df if there is none.
+func (h *header) numLimit(df int) int {
+	sz := h.num(df)
+	if sz > maxIntSize || sz <= 0 {
+		errorf("integral size (%d) out of limits [1,%d]", sz, maxIntSize)
 	}
-	return p.Unpack(values...)
+	return sz
 }
 
-// Pack returns a binary string containing the values v1, v2, etc. packed
-// (that is, serialized in binary form) according to the format string
-// format string.
-func Pack(format string, values ...interface{}) ([]byte, error) {
-	p, err := newState(format)
-	if err != nil {
-		return nil, err
+// option reads an option and returns its type and size.
+func (h *header) option() (kOption, int) {
+	opt := h.format[h.pos]
+	h.pos++
+	switch opt {
+	case 'b':
+		return kInt, 1
+	case 'B':
+		return kUint, 1
+	case 'h':
+		return kInt, 2
+	case 'H':
+		return kUint, 2
+	case 'l', 'j':
+		return kInt, 8
+	case 'L', 'J', 'T':
+		return kUint, 8
+	case 'f':
+		return kFloat, 4
+	case 'd', 'n':
+		return kFloat, 8
+	case 'i':
+		return kInt, h.numLimit(4)
+	case 'I':
+		return kUint, h.numLimit(4)
+	case 's':
+		return kString, h.numLimit(8)
+	case 'c':
+		size := h.num(-1)
+		if size == -1 {
+			errorf("missing size for format option 'c'")
+		}
+		return kChar, size
+	case 'z':
+		return kZstr, 0
+	case 'x':
+		return kPadding, 1
+	case 'X':
+		return kPaddAlign, 0
+	case ' ':
+	case '<', '=':
+		h.little = true
+	case '>':
+		h.little = false
+	case '!':
+		h.maxAlign = h.numLimit(maxAlign)
+	default:
+		errorf("invalid format option '%c'", opt)
 	}
-	return p.Pack(values...)
+	return kNop, 0
 }
 
-// Size returns the size of a string resulting from string.pack with the
-// given format string. The format string cannot have the variable-length
-// options 's' or 'z'.
-func Size(format string) (int, error) {
-	p, err := newState(format)
-	if err != nil {
-		return 0, err
+// details reads an option and returns its type, its size and the padding
+// that aligns it after total bytes.
+func (h *header) details(total int) (opt kOption, size, ntoalign int) {
+	opt, size = h.option()
+	align := size // usually, alignment follows size
+	if opt == kPaddAlign {
+		if !h.more() {
+			check(false, 1, "invalid next option for option 'X'")
+		}
+		var next kOption
+		if next, align = h.option(); next == kChar || align == 0 {
+			check(false, 1, "invalid next option for option 'X'")
+		}
+	}
+	if align <= 1 || opt == kChar {
+		return opt, size, 0
+	}
+	if align > h.maxAlign {
+		align = h.maxAlign
+	}
+	if align&(align-1) != 0 { // is 'align' not a power of 2?
+		check(false, 1, "format asks for alignment not power of 2")
+	}
+	return opt, size, (align - total&(align-1)) & (align - 1)
+}
+
+// packInt appends the size bytes of n to b; integers larger than 8 bytes are
+// sign-extended if neg.
+func packInt(b []byte, n uint64, little bool, size int, neg bool) []byte {
+	buf := make([]byte, size)
+	for i := 0; i < size; i++ {
+		c := byte(n)
+		if i >= intSize {
+			c = 0
+			if neg {
+				c = 0xff
+			}
+		}
+		if little {
+			buf[i] = c
+		} else {
+			buf[size-1-i] = c
+		}
+		n >>= 8
+	}
+	return append(b, buf...)
+}
+
+// unpackInt reads an integer of size bytes from s; integers larger than 8
+// bytes must fit in a Lua integer.
+func unpackInt(s string, little bool, size int, signed bool) int64 {
+	at := func(i int) byte {
+		if little {
+			return s[i]
+		}
+		return s[size-1-i]
+	}
+	limit := size
+	if limit > intSize {
+		limit = intSize
+	}
+	var res uint64
+	for i := limit - 1; i >= 0; i-- {
+		res = res<<8 | uint64(at(i))
+	}
+	if size < intSize {
+		if signed {
+			mask := uint64(1) << (size*8 - 1)
+			res = (res ^ mask) - mask // do sign extension
+		}
+	} else if size > intSize { // must check unread bytes
+		var mask byte
+		if signed && int64(res) < 0 {
+			mask = 0xff
+		}
+		for i := limit; i < size; i++ {
+			if at(i) != mask {
+				errorf("%d-byte integer does not fit into Lua Integer", size)
+			}
+		}
 	}
-	return p.Size()
+	return int64(res)
 }
@@ -0,0 +1,340 @@
+package pattern
+
+// Port of the pattern matcher of lstrlib.c. Positions in the subject (s) and
+// in the pattern (p) are indices; -1 stands for a failed match. Errors in the
+// pattern are raised by panicking with a patternError.
+
+type capture struct {
+	init int // start of the capture in the subject
+	len  int // length, capUnfinished or capPosition
+}
+
+type matchState struct {
+	src     string
+	pat     string
+	level   int // number of captures, finished or not
+	depth   int // remaining recursion depth
+	capture [MaxCaptures]capture
+}
+
+// match matches the pattern from pat[p] against the subject from src[s] and
+// returns the end of the match, or -1.
+func (ms *matchState) match(s, p int) int {
+	if ms.depth == 0 {
+		panic(&Error{Code: ErrTooComplex})
+	}
+	ms.depth--
+	s = ms.doMatch(s, p)
+	ms.depth++
+	return s
+}
+
+func (ms *matchState) doMatch(s, p int) int {
+	for p < len(ms.pat) {
+		switch ms.pat[p] {
+		case '(': // start capture
+			if p+1 < len(ms.pat) && ms.pat[p+1] == ')' { // position capture?
+				return ms.startCapture(s, p+2, capPosition)
+			}
+			return ms.startCapture(s, p+1, capUnfinished)
+		case ')': // end capture
+			return ms.endCapture(s, p+1)
+		case '$':
+			if p+1 == len(ms.pat) { // is the '$' the last char in pattern?
+				if s != len(ms.src) {
+					return -1
+				}
+				return s
+			}
+		case '%':
+			if p+1 >= len(ms.pat) {
+				break // malformed, reported by classEnd
+			}
+			switch c := ms.pat[p+1]; {
+			case c == 'b': // balanced string?
+				if s = ms.matchBalance(s, p+2); s == -1 {
+					return -1
+				}
+				p += 4
+				continue
+			case c == 'f': // frontier?
+				p += 2
+				if p >= len(ms.pat) || ms.pat[p] != '[' {
+					panic(&Error{Code: ErrFrontier})
+				}
+				ep := ms.classEnd(p) // points to what is next
+				var prev, cur byte
+				if s > 0 {
+					prev = ms.src[s-1]
+				}
+				if s < len(ms.src) {
+					cur = ms.src[s]
+				}
+				if ms.matchBracketClass(prev, p, ep-1) || !ms.matchBracketClass(cur, p, ep-1) {
+					return -1
+				}
+				p = ep
+				continue
+			case isdigit(c): // capture results (%0-%9)?
+				if s = ms.matchCapture(s, c); s == -1 {
+					return -1
+				}
+				p += 2
+				continue
+			}
+		}
+		// pattern class plus optional suffix
+		ep := ms.classEnd(p) // points to optional suffix
+		var suffix byte
+		if ep < len(ms.pat) {
+			suffix = ms.pat[ep]
+		}
+		if !ms.singleMatch(s, p, ep) { // does not match at least once?
+			if suffix == '*' || suffix == '?' || suffix == '-' { // accept empty?
+				p = ep + 1
+				continue
+			}
+			return -1
+		}
+		// matched once
+		switch suffix {
+		case '?': // optional
+			if res := ms.match(s+1, ep+1); res != -1 {
+				return res
+			}
+			p = ep + 1
+		case '+': // 1 or more repetitions
+			return ms.maxExpand(s+1, p, ep)
+		case '*': // 0 or more repetitions
+			return ms.maxExpand(s, p, ep)
+		case '-': // 0 or more repetitions (minimum)
+			return ms.minExpand(s, p, ep)
+		default: // no suffix
+			s++
+			p = ep
+		}
+	}
+	return s // end of pattern
+}
+
+// classEnd returns the end of the single character class at pat[p].
+func (ms *matchState) classEnd(p int) int {
+	c := ms.pat[p]
+	p++
+	switch c {
+	case '%':
+		if p >= len(ms.pat) {
+			panic(&Error{Code: ErrEndsWithPercent})
+		}
+		return p + 1
+	case '[':
+		if p < len(ms.pat) && ms.pat[p] == '^' {
+			p++
+		}
+		for { // look for a ']'
+			if p >= len(ms.pat) {
+				panic(&Error{Code: ErrMissingBracket})
+			}
+			c := ms.pat[p]
+			p++
+			if c == '%' && p < len(ms.pat) {
+				p++ // skip escapes (e.g. '%]')
+			}
+			if p < len(ms.pat) && ms.pat[p] == ']' {
+				return p + 1
+			}
+		}
+	}
+	return p
+}
+
+// singleMatch reports whether src[s] matches the class pat[p:ep].
+func (ms *matchState) singleMatch(s, p, ep int) bool {
+	if s >= len(ms.src) {
+		return false
+	}
+	c := ms.src[s]
+	switch ms.pat[p] {
+	case '.':
+		return true // matches any char
+	case '%':
+		return matchClass(c, ms.pat[p+1])
+	case '[':
+		return ms.matchBracketClass(c, p, ep-1)
+	default:
+		return ms.pat[p] == c
+	}
+}
+
+// matchBracketClass reports whether c is in the set pat[p:ec+1], where pat[p]
+// is '[' and pat[ec] is ']'.
+func (ms *matchState) matchBracketClass(c byte, p, ec int) bool {
+	sig := true
+	if ms.pat[p+1] == '^' {
+		sig = false
+		p++ // skip the '^'
+	}
+	for p++; p < ec; p++ {
+		switch {
+		case ms.pat[p] == '%':
+			p++
+			if matchClass(c, ms.pat[p]) {
+				return sig
+			}
+		case ms.pat[p+1] == '-' && p+2 < ec:
+			p += 2
+			if ms.pat[p-2] <= c && c <= ms.pat[p] {
+				return sig
+			}
+		case ms.pat[p] == c:
+			return sig
+		}
+	}
+	return !sig
+}
+
+// matchBalance matches %bxy at pat[p-2].
+func (ms *matchState) matchBalance(s, p int) int {
+	if p+1 >= len(ms.pat) {
+		panic(&Error{Code: ErrBalance})
+	}
+	if s >= len(ms.src) || ms.src[s] != ms.pat[p] {
+		return -1
+	}
+	b, e := ms.pat[p], ms.pat[p+1]
+	cont := 1
+	for s++; s < len(ms.src); s++ {
+		switch ms.src[s] {
+		case e:
+			if cont--; cont == 0 {
+				return s + 1
+			}
+		case b:
+			cont++
+		}
+	}
+	return -1 // string ends out of balance
+}
+
+func (ms *matchState) maxExpand(s, p, ep int) int {
+	i := 0 // counts maximum expand for item
+	for ms.singleMatch(s+i, p, ep) {
+		i++
+	}
+	// keeps trying to match with the maximum repetitions
+	for ; i >= 0; i-- {
+		if res := ms.match(s+i, ep+1); res != -1 {
+			return res
+		}
+	}
+	return -1
+}
+
+func (ms *matchState) minExpand(s, p, ep int) int {
+	for {
+		if res := ms.match(s, ep+1); res != -1 {
+			return res
+		}
+		if !ms.singleMatch(s, p, ep) {
+			return -1
+		}
+		s++ // try with one more repetition
+	}
+}
+
+func (ms *matchState) startCapture(s, p, what int) int {
+	if ms.level >= MaxCaptures {
+		panic(&Error{Code: ErrTooManyCaptures})
+	}
+	ms.capture[ms.level] = capture{init: s, len: what}
+	ms.level++
+	res := ms.match(s, p)
+	if res == -1 { // match failed?
+		ms.level-- // undo capture
+	}
+	return res
+}
+
+func (ms *matchState) endCapture(s, p int) int {
+	l := ms.captureToClose()
+	ms.capture[l].len = s - ms.capture[l].init // close capture
+	res := ms.match(s, p)
+	if res == -1 { // match failed?
+		ms.capture[l].len = capUnfinished // undo capture
+	}
+	return res
+}
+
+func (ms *matchState) captureToClose() int {
+	for level := ms.level - 1; level >= 0; level-- {
+		if ms.capture[level].len == capUnfinished {
+			return level
+		}
+	}
+	panic(&Error{Code: ErrInvalidCapture})
+}
+
+// matchCapture matches a back reference %l to a previous capture.
+func (ms *matchState) matchCapture(s int, l byte) int {
+	cap := ms.capture[ms.checkCapture(l)]
+	if cap.len >= 0 && len(ms.src)-s >= cap.len && ms.src[cap.init:cap.init+cap.len] == ms.src[s:s+cap.len] {
+		return s + cap.len
+	}
+	return -1
+}
+
+func (ms *matchState) checkCapture(l byte) int {
+	i := int(l) - '1'
+	if i < 0 || i >= ms.level || ms.capture[i].len == capUnfinished {
+		panic(&Error{Code: ErrCaptureIndex, Index: i + 1})
+	}
+	return i
+}
+
+// matchClass reports whether c is in the class %cl; the classes follow the C
+// locale, where bytes outside ASCII belong to none of them. The class %z of
+// Lua 5.1, the zero byte, is still supported.
+func matchClass(c, cl byte) bool {
+	var res bool
+	switch cl | 0x20 { // lower case
+	case 'a':
+		res = isalpha(c)
+	case 'c':
+		res = iscntrl(c)
+	case 'd':
+		res = isdigit(c)
+	case 'g':
+		res = isgraph(c)
+	case 'l':
+		res = islower(c)
+	case 'p':
+		res = ispunct(c)
+	case 's':
+		res = isspace(c)
+	case 'u':
+		res = isupper(c)
+	case 'w':
+		res = isalnum(c)
+	case 'x':
+		res = isxdigit(c)
+	case 'z': // deprecated, kept for Lua 5.1 patterns
+		res = c == 0
+	default:
+		return cl == c
+	}
+	if isupper(cl) {
+		return !res
+	}
+	return res
+}
+
+func isalpha(c byte) bool  { return islower(c) || isupper(c) }
+func isalnum(c byte) bool  { return isalpha(c) || isdigit(c) }
+func iscntrl(c byte) bool  { return c < ' ' || c == 0x7f }
+func isdigit(c byte) bool  { return '0' <= c && c <= '9' }
+func isgraph(c byte) bool  { return '!' <= c && c <= '~' }
+func islower(c byte) bool  { return 'a' <= c && c <= 'z' }
+func isupper(c byte) bool  { return 'A' <= c && c <= 'Z' }
+func ispunct(c byte) bool  { return isgraph(c) && !isalnum(c) }
+func isspace(c byte) bool  { return c == ' ' || ('\t' <= c && c <= '\r') }
+func isxdigit(c byte) bool { return isdigit(c) || ('a' <= c|0x20 && c|0x20 <= 'f') }
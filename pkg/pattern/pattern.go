@@ -1,68 +1,268 @@
-// TODO: capture index (e.g. %1)
-// TODO: balanced captures
-// TODO: frontier patterns
-// TODO: introduce opSave
-// TODO: eliminate opClose
-// TODO: pattern sets
-// TODO: replace
-// TODO: anchor tail $
+// Package pattern implements Lua 5.3 patterns, as used by string.find,
+// string.match, string.gmatch and string.gsub.
+//
+// The matcher is a port of the backtracking matcher of the reference
+// implementation (lstrlib.c): patterns are interpreted as they are matched,
+// byte by byte, and character classes follow the C locale. Malformed patterns
+// are reported when the matcher reaches the malformed part, with the messages
+// of the reference implementation, such as "malformed pattern (missing ']')".
+//
+// See https://www.lua.org/manual/5.3/manual.html#6.4.1
 package pattern
 
 import (
 	"fmt"
+	"strings"
 )
 
-// MatchIndexAll matches all items in text that match the pattern expr upto limit
-// (or all if limit is -1). The matches are returned as a two-dimensional slice
-// of integer offsets that points to the boundaries of any captures found in the
-// match.
-func MatchIndexAll(text, expr string, limit int) (captures [][]int) {
-	return MustCompile(expr).MatchIndexAll(text, limit)
+const (
+	// MaxCaptures is the maximum number of captures of a pattern.
+	MaxCaptures = 32
+
+	// maxMatchDepth is the maximum recursion depth of the matcher (the
+	// MAXCCALLS of the reference implementation).
+	maxMatchDepth = 200
+
+	capUnfinished = -1 // length of a capture still open
+	capPosition   = -2 // length of a position capture
+)
+
+// specials are the characters that make a string a pattern.
+const specials = "^$*+?.([%-"
+
+// Pattern is a Lua pattern.
+type Pattern struct {
+	expr   string // pattern without its anchor
+	anchor bool   // whether the pattern starts with '^'
 }
 
-// MatchIndex matches the first item in text to match the pattern expr. The matche
-// is returned as a slice of integer offsets that points to the boundaries of any
-// captures found in the match.
-func MatchIndex(text, expr string) (captures []int) {
-	return MustCompile(expr).MatchIndex(text)
+// Compile returns the pattern expr. Patterns are interpreted when they are
+// matched, so Compile does not fail: errors are returned by the methods
+// matching the pattern.
+func Compile(expr string) *Pattern {
+	if strings.HasPrefix(expr, "^") {
+		return &Pattern{expr: expr[1:], anchor: true}
+	}
+	return &Pattern{expr: expr}
 }
 
-// MatchAll is like MatchIndexAll excepts that the capture string are returned
-// instead of the positions.
-func MatchAll(text, expr string, limit int) (captures [][]string) {
-	return MustCompile(expr).MatchAll(text, limit)
+// Anchored reports whether the pattern starts with '^', so that it only
+// matches at the start of the subject, or at the initial position given to
+// Find.
+func (patt *Pattern) Anchored() bool { return patt.anchor }
+
+// IsPlain reports whether expr has no special characters, in which case it
+// only matches itself and can be searched for as a plain string.
+func IsPlain(expr string) bool { return !strings.ContainsAny(expr, specials) }
+
+// Find returns the first match of the pattern in src that starts at init or
+// after, or nil.
+func (patt *Pattern) Find(src string, init int) (m *Match, err error) {
+	for pos := init; pos <= len(src); pos++ {
+		if m, err = patt.MatchAt(src, pos); m != nil || err != nil || patt.anchor {
+			return m, err
+		}
+	}
+	return nil, nil
 }
 
-// Match is like MatchIndex except that the capture are returned instead of the
-// positions.
-func Match(text, expr string) (captures []string) {
-	return MustCompile(expr).Match(text)
+// MatchAt returns the match of the pattern that starts at src[pos], or nil.
+// The anchor of the pattern, if any, is ignored.
+func (patt *Pattern) MatchAt(src string, pos int) (m *Match, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(*Error)
+			if !ok {
+				panic(r)
+			}
+			m, err = nil, e
+		}
+	}()
+	ms := matchState{src: src, pat: patt.expr, depth: maxMatchDepth}
+	if end := ms.match(pos, 0); end != -1 {
+		return &Match{
+			Start: pos,
+			End:   end,
+			src:   src,
+			caps:  append([]capture(nil), ms.capture[:ms.level]...),
+		}, nil
+	}
+	return nil, nil
 }
 
-// ReplaceAll replaces all (or upto limit) matches of text in expr using the
-// provided Replacer repl. The new string and number of replacements made is
-// returned. If no match was made, "", 0 is returned.
-func ReplaceAll(text, expr string, repl Replacer, limit int) (string, int) {
-	return MustCompile(expr).ReplaceAll(text, repl, limit)
+// Match is a match of a pattern.
+type Match struct {
+	Start, End int // bounds of the match: src[Start:End]
+
+	src  string
+	caps []capture
 }
 
-// Replace replaces the first match of text in expr using the provided Replacer
-// repl. On success, the replaced string and number of replacements is returned;
-// Otherwise "", 0 is returned.
-func Replace(text, expr string, repl Replacer) (string, int) {
-	return MustCompile(expr).Replace(text, repl)
+// String returns the matched string.
+func (m *Match) String() string { return m.src[m.Start:m.End] }
+
+// NumCaptures returns the number of captures of the pattern.
+func (m *Match) NumCaptures() int { return len(m.caps) }
+
+// Capture returns capture i, numbered from 0: the captured string, or for a
+// position capture "()" its position as an int64 counted from 1 as in Lua.
+// If the pattern has no captures, capture 0 is the whole match.
+func (m *Match) Capture(i int) (interface{}, error) {
+	if i >= len(m.caps) {
+		if i != 0 {
+			return nil, &Error{Code: ErrCaptureIndex, Index: i + 1}
+		}
+		return m.String(), nil
+	}
+	switch cap := m.caps[i]; cap.len {
+	case capUnfinished:
+		return nil, &Error{Code: ErrUnfinishedCapture}
+	case capPosition:
+		return int64(cap.init + 1), nil
+	default:
+		return m.src[cap.init : cap.init+cap.len], nil
+	}
+}
+
+// Captures returns the captures of the match, as Capture does. If the pattern
+// has no captures, it returns the whole match if whole is true, and nothing
+// otherwise.
+func (m *Match) Captures(whole bool) ([]interface{}, error) {
+	n := len(m.caps)
+	if n == 0 && whole {
+		n = 1
+	}
+	caps := make([]interface{}, n)
+	for i := range caps {
+		cap, err := m.Capture(i)
+		if err != nil {
+			return nil, err
+		}
+		caps[i] = cap
+	}
+	return caps, nil
 }
 
-const debug = false
+// bounds returns the bounds of the whole match followed by those of the
+// captures; a position capture is empty.
+func (m *Match) bounds() []int {
+	loc := []int{m.Start, m.End}
+	for _, cap := range m.caps {
+		switch cap.len {
+		case capUnfinished:
+			panic(&Error{Code: ErrUnfinishedCapture})
+		case capPosition:
+			loc = append(loc, cap.init, cap.init)
+		default:
+			loc = append(loc, cap.init, cap.init+cap.len)
+		}
+	}
+	return loc
+}
+
+// Error is an error in a pattern, or in the use of its captures. Its Code
+// tells the errors apart, so that their messages can be replaced.
+type Error struct {
+	Code  ErrorCode
+	Index int // capture index of ErrCaptureIndex, counted from 1
+}
+
+// ErrorCode identifies the error of an Error.
+type ErrorCode int
+
+const (
+	ErrTooComplex        ErrorCode = iota // pattern too complex
+	ErrFrontier                           // missing '[' after '%f' in pattern
+	ErrEndsWithPercent                    // malformed pattern (ends with '%')
+	ErrMissingBracket                     // malformed pattern (missing ']')
+	ErrBalance                            // malformed pattern (missing arguments to '%b')
+	ErrTooManyCaptures                    // too many captures
+	ErrInvalidCapture                     // invalid pattern capture
+	ErrCaptureIndex                       // invalid capture index %n
+	ErrUnfinishedCapture                  // unfinished capture
+)
+
+// errorMessages holds the messages of the reference implementation.
+var errorMessages = [...]string{
+	ErrTooComplex:        "pattern too complex",
+	ErrFrontier:          "missing '[' after '%f' in pattern",
+	ErrEndsWithPercent:   "malformed pattern (ends with '%')",
+	ErrMissingBracket:    "malformed pattern (missing ']')",
+	ErrBalance:           "malformed pattern (missing arguments to '%b')",
+	ErrTooManyCaptures:   "too many captures",
+	ErrInvalidCapture:    "invalid pattern capture",
+	ErrCaptureIndex:      "invalid capture index %%%d",
+	ErrUnfinishedCapture: "unfinished capture",
+}
+
+func (err *Error) Error() string {
+	if err.Code == ErrCaptureIndex {
+		return fmt.Sprintf(errorMessages[err.Code], err.Index)
+	}
+	return errorMessages[err.Code]
+}
+
+// FindAllIndex returns the bounds of the matches of the pattern expr in text,
+// at most limit of them if limit > 0. The bounds of a match are those of the
+// whole match followed by those of its captures. It panics if expr is
+// malformed.
+func FindAllIndex(text, expr string, limit int) (matches [][]int) {
+	patt := Compile(expr)
+	for pos, last := 0, -1; pos <= len(text); {
+		m, err := patt.MatchAt(text, pos)
+		if err != nil {
+			panic(err)
+		}
+		if m != nil && m.End != last { // skip an empty match after the last one
+			matches = append(matches, m.bounds())
+			if limit > 0 && len(matches) >= limit {
+				break
+			}
+			pos, last = m.End, m.End
+		} else {
+			pos++
+		}
+		if patt.anchor {
+			break
+		}
+	}
+	return matches
+}
+
+// FindIndex returns the bounds of the first match of the pattern expr in text,
+// as FindAllIndex does, or nil. It panics if expr is malformed.
+func FindIndex(text, expr string) []int {
+	m, err := Compile(expr).Find(text, 0)
+	if err != nil {
+		panic(err)
+	}
+	if m == nil {
+		return nil
+	}
+	return m.bounds()
+}
+
+// FindAllString is like FindAllIndex but returns the matched strings instead
+// of their bounds.
+func FindAllString(text, expr string, limit int) (matches [][]string) {
+	for _, loc := range FindAllIndex(text, expr, limit) {
+		matches = append(matches, substrings(text, loc))
+	}
+	return matches
+}
 
-func trace(msg string) {
-	if debug {
-		fmt.Printf("# %s\n", msg)
+// FindString is like FindIndex but returns the matched strings instead of
+// their bounds.
+func FindString(text, expr string) []string {
+	if loc := FindIndex(text, expr); loc != nil {
+		return substrings(text, loc)
 	}
+	return nil
 }
 
-func traceVM(src string, sp, ip int, inst instr) {
-	if debug {
-		fmt.Printf("[sp=%d|ip=%d] %v (src = %q)\n", sp, ip, inst, src[sp:])
+func substrings(text string, loc []int) (s []string) {
+	for i := 0; i < len(loc); i += 2 {
+		s = append(s, text[loc[i]:loc[i+1]])
 	}
+	return s
 }
@@ -109,3 +109,31 @@ func TestMatchAndCaptures(t *testing.T) {
 		}
 	}
 }
+
+func TestCollate(t *testing.T) {
+	var tests = []struct {
+		locale string
+		words  []string // in sorted order
+	}{
+		{"", []string{"Äpfel", "apple", "Apple", "banana", "cote", "coté", "côte", "côté", "Zebra"}},
+		{"en_US.UTF-8", []string{"10", "9a", "ab", "Ab", "Ac", "æ", "Strasse", "Straße", "Strat"}},
+		{"sv_SE", []string{"Zebra", "år", "ärta", "öl"}},
+		{"da", []string{"Zebra", "ært", "øl", "år"}},
+		{"es", []string{"nube", "ñu", "oso"}},
+		{"C", []string{"Zebra", "apple", "Äpfel"}},
+	}
+	for _, test := range tests {
+		for i := 1; i < len(test.words); i++ {
+			a, b := test.words[i-1], test.words[i]
+			if cmp := Collate(a, b, test.locale); cmp != -1 {
+				t.Errorf("%s: Collate(%q, %q) = %d; want -1", test.locale, a, b, cmp)
+			}
+			if cmp := Collate(b, a, test.locale); cmp != +1 {
+				t.Errorf("%s: Collate(%q, %q) = %d; want +1", test.locale, b, a, cmp)
+			}
+		}
+	}
+	if cmp := Collate("ÅR", "ÅR", "sv"); cmp != 0 {
+		t.Errorf("Collate equal strings = %d; want 0", cmp)
+	}
+}
@@ -0,0 +1,176 @@
+package strings
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Collate compares a and b for display sorting in the given locale and returns
+// -1, 0 or +1. Unlike the byte-wise order of the Lua relational operators,
+// letters sort case- and accent-insensitively first ("Äpfel" < "apple" <
+// "banana"); accents and then case only break ties, and byte order breaks any
+// remaining tie so that only equal strings compare equal.
+//
+// The locale is a language tag such as "sv" or "de_DE.UTF-8". The languages
+// of the Latin script with their own alphabet order (da, es, fi, nb, nn, no,
+// sv and tr) are tailored; other locales use the root order. The "C" and
+// "POSIX" locales compare bytes.
+func Collate(a, b, locale string) int {
+	lang := language(locale)
+	if lang == "c" || lang == "posix" {
+		return strings.Compare(a, b)
+	}
+	var (
+		tailor = tailorings[lang]
+		ka     = collationKey(a, tailor)
+		kb     = collationKey(b, tailor)
+	)
+	for level := 0; level < 3; level++ {
+		if cmp := compareLevel(ka, kb, level); cmp != 0 {
+			return cmp
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+// language returns the lower-case language subtag of locale.
+func language(locale string) string {
+	if i := strings.IndexAny(locale, "_-.@"); i >= 0 {
+		locale = locale[:i]
+	}
+	return strings.ToLower(locale)
+}
+
+// A weight holds the primary (base letter), secondary (accent) and tertiary
+// (case) weights of a collation element.
+type weight [3]int
+
+const (
+	lowerCase = 0
+	upperCase = 1
+)
+
+// collationKey returns the collation elements of s.
+func collationKey(s string, tailor map[rune]int) (key []weight) {
+	for len(s) > 0 {
+		r, n := utf8.DecodeRuneInString(s)
+		if r == utf8.RuneError && n == 1 { // invalid bytes sort after all runes
+			key = append(key, weight{primary(unicode.MaxRune + 1 + rune(s[0])), 0, lowerCase})
+			s = s[n:]
+			continue
+		}
+		s = s[n:]
+		caseWeight := lowerCase
+		if unicode.IsUpper(r) {
+			caseWeight = upperCase
+		}
+		lower := unicode.ToLower(r)
+		if w, ok := tailor[lower]; ok {
+			key = append(key, weight{w, 0, caseWeight})
+			continue
+		}
+		if exp, ok := expansions[lower]; ok {
+			for _, e := range exp {
+				key = append(key, weight{primary(e), int(lower), caseWeight})
+			}
+			continue
+		}
+		if base, ok := accented[lower]; ok {
+			key = append(key, weight{primary(base), int(lower), caseWeight})
+			continue
+		}
+		key = append(key, weight{primary(lower), 0, caseWeight})
+	}
+	return key
+}
+
+// primary returns the primary weight of the unaccented lower-case rune r,
+// leaving room to tailor letters between two runes.
+func primary(r rune) int { return int(r) << 4 }
+
+// compareLevel compares the weights of the keys at level.
+func compareLevel(a, b []weight, level int) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i][level] != b[i][level] {
+			if a[i][level] < b[i][level] {
+				return -1
+			}
+			return +1
+		}
+	}
+	switch {
+	case len(a) < len(b):
+		return -1
+	case len(a) > len(b):
+		return +1
+	}
+	return 0
+}
+
+// accented maps the accented Latin letters to their base letter.
+var accented = make(map[rune]rune)
+
+func init() {
+	for _, group := range strings.Fields(
+		"aàáâãäåāăą cçćĉċč dďđð eèéêëēĕėęě gĝğġģ hĥħ iìíîïĩīĭįı jĵ kķ lĺļľŀł " +
+			"nñńņň oòóôõöøōŏő rŕŗř sśŝşš tţťŧ uùúûüũūŭůűų wŵ yýÿŷ zźżž",
+	) {
+		base, n := utf8.DecodeRuneInString(group)
+		for _, r := range group[n:] {
+			accented[r] = base
+		}
+	}
+}
+
+// expansions maps the Latin ligatures and special letters to the letters
+// they sort as.
+var expansions = map[rune]string{
+	'æ': "ae",
+	'œ': "oe",
+	'ß': "ss",
+	'þ': "th",
+	'ĳ': "ij",
+}
+
+// tailorings holds the primary weights of the letters sorted as separate
+// letters of the alphabet by a language.
+var tailorings = map[string]map[rune]int{
+	"da": after('z', "æä", "øö", "å"),
+	"es": after('n', "ñ"),
+	"fi": after('z', "å", "äæ", "öø"),
+	"nb": after('z', "æä", "øö", "å"),
+	"nn": after('z', "æä", "øö", "å"),
+	"no": after('z', "æä", "øö", "å"),
+	"sv": after('z', "å", "äæ", "öø"),
+	"tr": merge(after('c', "ç"), after('g', "ğ"), before('i', "ı"), after('o', "ö"), after('s', "ş"), after('u', "ü")),
+}
+
+// after returns primary weights that sort the letters right after base, in
+// order. The letters of each group sort as the same letter.
+func after(base rune, groups ...string) map[rune]int {
+	weights := make(map[rune]int)
+	for i, group := range groups {
+		for _, r := range group {
+			weights[r] = primary(base) + i + 1
+		}
+	}
+	return weights
+}
+
+// before returns primary weights that sort the letter right before base.
+func before(base rune, letter string) map[rune]int {
+	r, _ := utf8.DecodeRuneInString(letter)
+	return map[rune]int{r: primary(base) - 1}
+}
+
+// merge merges the tailorings of several letters.
+func merge(tailorings ...map[rune]int) map[rune]int {
+	weights := make(map[rune]int)
+	for _, t := range tailorings {
+		for r, w := range t {
+			weights[r] = w
+		}
+	}
+	return weights
+}
@@ -113,7 +113,7 @@ func GsubStrAll(text, expr, replace string, limit int) (repl string, count int)
 		b strings.Builder
 		i = 0
 	)
-	for _, caps := range pattern.MatchIndexAll(text, expr, limit) {
+	for _, caps := range pattern.FindAllIndex(text, expr, limit) {
 		gsub := capRE.ReplaceAllStringFunc(replace, func(k string) string {
 			if i := k[1] - '0'; 0 <= i && i <= 9 {
 				// TODO: check that i is valid capture index
@@ -14,14 +14,14 @@ type String string
 // specifies captures, the captures are returned as well; otherwise ""
 // if no match was made and nil if no captures captured.
 func (str String) MatchAll(text string, limit int) (captures [][]string) {
-	return pattern.MatchAll(text, string(str), limit)
+	return pattern.FindAllString(text, string(str), limit)
 }
 
 // Match returns the first match found in str. If str is a pattern that
 // specifies captures, the captures are returned as well; otherwise ""
 // if no match was made and nil if no captures captured.
 func (str String) Match(text string) (captures []string) {
-	return pattern.Match(text, string(str))
+	return pattern.FindString(text, string(str))
 }
 
 // FindAll returns the start and end position of the string text found in str.
@@ -30,7 +30,7 @@ func (str String) Match(text string) (captures []string) {
 // are returns as a slice of strings; otherwise if no match or no captures
 // then captures will be nil.
 func (str String) FindAll(text string, limit int) [][]int {
-	return pattern.MatchIndexAll(text, string(str), limit)
+	return pattern.FindAllIndex(text, string(str), limit)
 }
 
 // Find returns the start and end position of the string text found in str.
@@ -39,7 +39,7 @@ func (str String) FindAll(text string, limit int) [][]int {
 // are returns as a slice of strings; otherwise if no match or no captures
 // then captures will be nil.
 func (str String) Find(text string) []int {
-	return pattern.MatchIndex(text, string(str))
+	return pattern.FindIndex(text, string(str))
 }
 
 // Gsub returns a copy of text in which all (or the upto limit if > 0) occurrences of
@@ -0,0 +1,640 @@
+// Package toml decodes TOML documents (https://toml.io/en/v1.0.0) into
+// generic Go values: tables become map[string]interface{}, arrays
+// []interface{}, integers int64, floats float64, booleans bool, and strings
+// and date-times string.
+package toml
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Error is a syntax error in a TOML document.
+type Error struct {
+	Line int
+	Msg  string
+}
+
+func (e *Error) Error() string { return fmt.Sprintf("toml: line %d: %s", e.Line, e.Msg) }
+
+// Decode decodes the TOML document text.
+func Decode(text string) (doc map[string]interface{}, err error) {
+	p := &parser{text: text, line: 1}
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(*Error)
+			if !ok {
+				panic(r)
+			}
+			doc, err = nil, e
+		}
+	}()
+	root := newTable()
+	p.parse(root)
+	return root.value().(map[string]interface{}), nil
+}
+
+// table is a table being decoded.
+type table struct {
+	entries map[string]interface{} // values, *table or *tableArray
+	keys    []string
+	defined bool // defined by a header or as a value, so it cannot be reopened by a header
+	dotted  bool // created by dotted keys, so it cannot be reopened by a header
+	inline  bool // an inline table, which cannot be extended
+}
+
+// tableArray is an array of tables being decoded.
+type tableArray struct {
+	tables []*table
+}
+
+func newTable() *table { return &table{entries: make(map[string]interface{})} }
+
+// value returns the decoded value of the table.
+func (t *table) value() interface{} {
+	m := make(map[string]interface{}, len(t.entries))
+	for k, v := range t.entries {
+		m[k] = decoded(v)
+	}
+	return m
+}
+
+func decoded(v interface{}) interface{} {
+	switch v := v.(type) {
+	case *table:
+		return v.value()
+	case *tableArray:
+		list := make([]interface{}, len(v.tables))
+		for i, t := range v.tables {
+			list[i] = t.value()
+		}
+		return list
+	case []interface{}:
+		for i, elem := range v {
+			v[i] = decoded(elem)
+		}
+	}
+	return v
+}
+
+type parser struct {
+	text string
+	pos  int
+	line int
+}
+
+func (p *parser) errorf(format string, args ...interface{}) {
+	panic(&Error{Line: p.line, Msg: fmt.Sprintf(format, args...)})
+}
+
+func (p *parser) eof() bool { return p.pos >= len(p.text) }
+
+func (p *parser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.text[p.pos]
+}
+
+func (p *parser) next() byte {
+	c := p.peek()
+	p.pos++
+	if c == '\n' {
+		p.line++
+	}
+	return c
+}
+
+func (p *parser) consume(s string) bool {
+	if strings.HasPrefix(p.text[p.pos:], s) {
+		for range s {
+			p.next()
+		}
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(s string) {
+	if !p.consume(s) {
+		p.errorf("expected %q", s)
+	}
+}
+
+// skipSpace skips spaces and tabs.
+func (p *parser) skipSpace() {
+	for c := p.peek(); c == ' ' || c == '\t'; c = p.peek() {
+		p.next()
+	}
+}
+
+// skipComment skips a comment up to the end of line.
+func (p *parser) skipComment() {
+	if p.peek() == '#' {
+		for !p.eof() && p.peek() != '\n' {
+			if c := p.next(); c < 0x20 && c != '\t' && c != '\r' || c == 0x7f {
+				p.errorf("control character in comment")
+			}
+		}
+	}
+}
+
+// endLine expects the end of a line, possibly after a comment.
+func (p *parser) endLine() {
+	p.skipSpace()
+	p.skipComment()
+	p.consume("\r")
+	if !p.eof() && !p.consume("\n") {
+		p.errorf("expected end of line, found %q", p.peek())
+	}
+}
+
+// skipBlank skips whitespace, comments and newlines.
+func (p *parser) skipBlank() {
+	for {
+		p.skipSpace()
+		p.skipComment()
+		if !p.consume("\n") && !p.consume("\r\n") {
+			return
+		}
+	}
+}
+
+func (p *parser) parse(root *table) {
+	current := root
+	for p.skipBlank(); !p.eof(); p.skipBlank() {
+		switch {
+		case p.consume("[["):
+			p.skipSpace()
+			keys := p.key()
+			p.skipSpace()
+			p.expect("]]")
+			current = p.appendTable(root, keys)
+		case p.consume("["):
+			p.skipSpace()
+			keys := p.key()
+			p.skipSpace()
+			p.expect("]")
+			current = p.openTable(root, keys)
+		default:
+			p.keyValue(current)
+		}
+		p.endLine()
+	}
+}
+
+// keyValue parses a key/value pair into t.
+func (p *parser) keyValue(t *table) {
+	keys := p.key()
+	p.skipSpace()
+	p.expect("=")
+	p.skipSpace()
+	value := p.value()
+	for _, k := range keys[:len(keys)-1] {
+		switch v := t.entries[k].(type) {
+		case nil:
+			sub := newTable()
+			sub.dotted = true
+			t.set(k, sub)
+			t = sub
+		case *table:
+			if v.inline || v.defined && !v.dotted {
+				p.errorf("cannot extend table '%s' with dotted keys", k)
+			}
+			t = v
+		default:
+			p.errorf("key '%s' is already defined", k)
+		}
+	}
+	k := keys[len(keys)-1]
+	if _, ok := t.entries[k]; ok {
+		p.errorf("key '%s' is already defined", k)
+	}
+	if sub, ok := value.(*table); ok {
+		sub.defined = true
+	}
+	t.set(k, value)
+}
+
+func (t *table) set(k string, v interface{}) {
+	t.entries[k] = v
+	t.keys = append(t.keys, k)
+}
+
+// walk returns the table at the path keys below root, creating implicit
+// tables; the last element of an array of tables is used.
+func (p *parser) walk(root *table, keys []string) *table {
+	t := root
+	for _, k := range keys {
+		switch v := t.entries[k].(type) {
+		case nil:
+			sub := newTable()
+			t.set(k, sub)
+			t = sub
+		case *table:
+			if v.inline {
+				p.errorf("cannot extend inline table '%s'", k)
+			}
+			t = v
+		case *tableArray:
+			t = v.tables[len(v.tables)-1]
+		default:
+			p.errorf("key '%s' is not a table", k)
+		}
+	}
+	return t
+}
+
+// openTable handles the table header [keys].
+func (p *parser) openTable(root *table, keys []string) *table {
+	parent := p.walk(root, keys[:len(keys)-1])
+	k := keys[len(keys)-1]
+	switch v := parent.entries[k].(type) {
+	case nil:
+		t := newTable()
+		t.defined = true
+		parent.set(k, t)
+		return t
+	case *table:
+		if v.defined || v.dotted || v.inline {
+			p.errorf("table '%s' is already defined", strings.Join(keys, "."))
+		}
+		v.defined = true
+		return v
+	}
+	p.errorf("key '%s' is already defined", strings.Join(keys, "."))
+	return nil
+}
+
+// appendTable handles the array of tables header [[keys]].
+func (p *parser) appendTable(root *table, keys []string) *table {
+	parent := p.walk(root, keys[:len(keys)-1])
+	k := keys[len(keys)-1]
+	t := newTable()
+	t.defined = true
+	switch v := parent.entries[k].(type) {
+	case nil:
+		parent.set(k, &tableArray{tables: []*table{t}})
+	case *tableArray:
+		v.tables = append(v.tables, t)
+	default:
+		p.errorf("key '%s' is not an array of tables", strings.Join(keys, "."))
+	}
+	return t
+}
+
+// key parses a possibly dotted key.
+func (p *parser) key() (keys []string) {
+	for {
+		p.skipSpace()
+		switch c := p.peek(); {
+		case c == '"':
+			if strings.HasPrefix(p.text[p.pos:], `"""`) {
+				p.errorf("multi-line string used as key")
+			}
+			keys = append(keys, p.basicString())
+		case c == '\'':
+			if strings.HasPrefix(p.text[p.pos:], `'''`) {
+				p.errorf("multi-line string used as key")
+			}
+			keys = append(keys, p.literalString())
+		case isBare(c):
+			start := p.pos
+			for isBare(p.peek()) {
+				p.next()
+			}
+			keys = append(keys, p.text[start:p.pos])
+		default:
+			p.errorf("expected a key, found %q", c)
+		}
+		p.skipSpace()
+		if !p.consume(".") {
+			return keys
+		}
+	}
+}
+
+func isBare(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+func (p *parser) value() interface{} {
+	switch c := p.peek(); {
+	case c == '"':
+		if strings.HasPrefix(p.text[p.pos:], `"""`) {
+			return p.multiBasicString()
+		}
+		return p.basicString()
+	case c == '\'':
+		if strings.HasPrefix(p.text[p.pos:], `'''`) {
+			return p.multiLiteralString()
+		}
+		return p.literalString()
+	case c == '[':
+		return p.array()
+	case c == '{':
+		return p.inlineTable()
+	case p.consume("true"):
+		return true
+	case p.consume("false"):
+		return false
+	}
+	return p.scalar()
+}
+
+func (p *parser) array() []interface{} {
+	p.expect("[")
+	list := []interface{}{}
+	for {
+		p.skipBlank()
+		if p.consume("]") {
+			return list
+		}
+		list = append(list, p.value())
+		p.skipBlank()
+		if !p.consume(",") {
+			p.skipBlank()
+			p.expect("]")
+			return list
+		}
+	}
+}
+
+func (p *parser) inlineTable() *table {
+	p.expect("{")
+	t := newTable()
+	p.skipSpace()
+	if !p.consume("}") {
+		for {
+			p.keyValue(t)
+			p.skipSpace()
+			if p.consume("}") {
+				break
+			}
+			p.expect(",")
+			p.skipSpace()
+		}
+	}
+	markInline(t)
+	return t
+}
+
+// markInline marks t and the tables created in it by dotted keys as inline.
+func markInline(t *table) {
+	t.inline = true
+	for _, v := range t.entries {
+		if sub, ok := v.(*table); ok {
+			markInline(sub)
+		}
+	}
+}
+
+// scalar parses a number or a date-time.
+func (p *parser) scalar() interface{} {
+	start := p.pos
+	for c := p.peek(); isBare(c) || c == '.' || c == '+' || c == ':'; c = p.peek() {
+		p.next()
+	}
+	tok := p.text[start:p.pos]
+	if isDate(tok) && p.peek() == ' ' && p.pos+1 < len(p.text) && isDigit(p.text[p.pos+1]) {
+		p.next() // date and time separated by a space
+		for c := p.peek(); isBare(c) || c == '.' || c == '+' || c == ':'; c = p.peek() {
+			p.next()
+		}
+		tok = p.text[start:p.pos]
+	}
+	if tok == "" {
+		p.errorf("expected a value, found %q", p.peek())
+	}
+	if v, ok := number(tok); ok {
+		return v
+	}
+	if isDate(tok) || isTime(tok) {
+		return tok
+	}
+	p.errorf("invalid value '%s'", tok)
+	return nil
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+// isDate reports whether tok starts with a full date YYYY-MM-DD.
+func isDate(tok string) bool {
+	return len(tok) >= 10 && tok[4] == '-' && tok[7] == '-' &&
+		strings.Trim(tok[:4]+tok[5:7]+tok[8:10], "0123456789") == ""
+}
+
+// isTime reports whether tok is a local time HH:MM:SS.
+func isTime(tok string) bool {
+	return len(tok) >= 8 && tok[2] == ':' && tok[5] == ':' &&
+		strings.Trim(tok[:2]+tok[3:5]+tok[6:8], "0123456789") == ""
+}
+
+// number converts an integer or float token.
+func number(tok string) (interface{}, bool) {
+	switch strings.TrimLeft(tok, "+-") {
+	case "inf":
+		if tok[0] == '-' {
+			return math.Inf(-1), true
+		}
+		return math.Inf(1), true
+	case "nan":
+		return math.NaN(), true
+	}
+	if !validUnderscores(tok) {
+		return nil, false
+	}
+	s := strings.Replace(tok, "_", "", -1)
+	if len(s) > 2 && s[0] == '0' {
+		base := 0
+		switch s[1] {
+		case 'x':
+			base = 16
+		case 'o':
+			base = 8
+		case 'b':
+			base = 2
+		}
+		if base != 0 {
+			i, err := strconv.ParseInt(s[2:], base, 64)
+			return i, err == nil
+		}
+	}
+	digits := strings.TrimLeft(s, "+-")
+	if len(digits) > 1 && digits[0] == '0' && isDigit(digits[1]) {
+		return nil, false // leading zeros
+	}
+	if strings.ContainsAny(s, ".eE") {
+		if strings.Contains(s, ".e") || strings.HasSuffix(s, ".") || strings.HasPrefix(digits, ".") {
+			return nil, false
+		}
+		f, err := strconv.ParseFloat(s, 64)
+		return f, err == nil
+	}
+	i, err := strconv.ParseInt(s, 10, 64)
+	return i, err == nil
+}
+
+// validUnderscores reports whether each underscore in tok is between digits.
+func validUnderscores(tok string) bool {
+	for i := 0; i < len(tok); i++ {
+		if tok[i] == '_' && (i == 0 || i == len(tok)-1 || !isHexDigit(tok[i-1]) || !isHexDigit(tok[i+1])) {
+			return false
+		}
+	}
+	return true
+}
+
+func isHexDigit(c byte) bool {
+	return isDigit(c) || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+func (p *parser) basicString() string {
+	p.expect(`"`)
+	var b strings.Builder
+	for {
+		switch c := p.peek(); {
+		case p.eof() || c == '\n':
+			p.errorf("unterminated string")
+		case c == '"':
+			p.next()
+			return b.String()
+		case c == '\\':
+			p.escape(&b)
+		default:
+			p.char(&b)
+		}
+	}
+}
+
+func (p *parser) multiBasicString() string {
+	p.expect(`"""`)
+	p.consume("\r")
+	p.consume("\n") // a newline right after the delimiter is trimmed
+	var b strings.Builder
+	for {
+		switch c := p.peek(); {
+		case p.eof():
+			p.errorf("unterminated string")
+		case strings.HasPrefix(p.text[p.pos:], `"""`):
+			p.expect(`"""`)
+			for i := 0; i < 2 && p.consume(`"`); i++ { // up to 2 quotes before the delimiter
+				b.WriteByte('"')
+			}
+			return b.String()
+		case c == '\\':
+			if p.lineEndingBackslash() {
+				continue
+			}
+			p.escape(&b)
+		case c == '\n':
+			b.WriteByte(p.next())
+		default:
+			p.char(&b)
+		}
+	}
+}
+
+// lineEndingBackslash skips a backslash at the end of a line together with
+// the whitespace and newlines that follow it.
+func (p *parser) lineEndingBackslash() bool {
+	i := p.pos + 1
+	for i < len(p.text) && (p.text[i] == ' ' || p.text[i] == '\t' || p.text[i] == '\r') {
+		i++
+	}
+	if i >= len(p.text) || p.text[i] != '\n' {
+		return false
+	}
+	for p.pos <= i {
+		p.next()
+	}
+	for c := p.peek(); c == ' ' || c == '\t' || c == '\n' || c == '\r'; c = p.peek() {
+		p.next()
+	}
+	return true
+}
+
+func (p *parser) escape(b *strings.Builder) {
+	p.expect(`\`)
+	switch c := p.next(); c {
+	case 'b':
+		b.WriteByte('\b')
+	case 't':
+		b.WriteByte('\t')
+	case 'n':
+		b.WriteByte('\n')
+	case 'f':
+		b.WriteByte('\f')
+	case 'r':
+		b.WriteByte('\r')
+	case '"':
+		b.WriteByte('"')
+	case '\\':
+		b.WriteByte('\\')
+	case 'u', 'U':
+		n := 4
+		if c == 'U' {
+			n = 8
+		}
+		if p.pos+n > len(p.text) {
+			p.errorf("invalid unicode escape")
+		}
+		r, err := strconv.ParseUint(p.text[p.pos:p.pos+n], 16, 32)
+		if err != nil || !utf8.ValidRune(rune(r)) {
+			p.errorf("invalid unicode escape")
+		}
+		p.pos += n
+		b.WriteRune(rune(r))
+	default:
+		p.errorf("invalid escape sequence '\\%c'", c)
+	}
+}
+
+// char copies a character of a string, rejecting control characters.
+func (p *parser) char(b *strings.Builder) {
+	c := p.next()
+	if c < 0x20 && c != '\t' || c == 0x7f {
+		p.errorf("control character in string")
+	}
+	b.WriteByte(c)
+}
+
+func (p *parser) literalString() string {
+	p.expect("'")
+	start := p.pos
+	for c := p.peek(); c != '\''; c = p.peek() {
+		if p.eof() || c == '\n' {
+			p.errorf("unterminated string")
+		}
+		p.char(&strings.Builder{})
+	}
+	s := p.text[start:p.pos]
+	p.next()
+	return s
+}
+
+func (p *parser) multiLiteralString() string {
+	p.expect("'''")
+	p.consume("\r")
+	p.consume("\n")
+	start := p.pos
+	for !strings.HasPrefix(p.text[p.pos:], "'''") {
+		if p.eof() {
+			p.errorf("unterminated string")
+		}
+		if p.peek() == '\n' {
+			p.next()
+		} else {
+			p.char(&strings.Builder{})
+		}
+	}
+	end := p.pos
+	p.expect("'''")
+	for i := 0; i < 2 && p.consume("'"); i++ { // up to 2 quotes before the delimiter
+		end++
+	}
+	return p.text[start:end]
+}
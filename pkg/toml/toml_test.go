@@ -0,0 +1,85 @@
+package toml
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	const doc = `# config
+title = "TOML \"example\" \u00e9"
+path = 'C:\Users'
+multi = """
+Roses are red \
+   Violets are blue"""
+lines = '''
+first
+second'''
+int = +1_000
+hex = 0xDEAD_beef
+float = -3.5e2
+date = 1979-05-27 07:32:00Z
+site."google.com" = true
+
+[owner]
+name = "Tom"
+dob = 1979-05-27T07:32:00-08:00
+
+[database]
+ports = [ 8000, 8001,
+  8002, # trailing comma next
+]
+data = [ ["gamma", "delta"], [1, 2] ]
+point = { x = 1, y.z = 2 }
+
+[[fruits]]
+name = "apple"
+
+[fruits.physical]
+color = "red"
+
+[[fruits]]
+name = "banana"
+`
+	got, err := Decode(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `{"database":{"data":[["gamma","delta"],[1,2]],"point":{"x":1,"y":{"z":2}},"ports":[8000,8001,8002]},` +
+		`"date":"1979-05-27 07:32:00Z","float":-350,"fruits":[{"name":"apple","physical":{"color":"red"}},{"name":"banana"}],` +
+		`"hex":3735928559,"int":1000,"lines":"first\nsecond","multi":"Roses are red Violets are blue",` +
+		`"owner":{"dob":"1979-05-27T07:32:00-08:00","name":"Tom"},"path":"C:\\Users","site":{"google.com":true},"title":"TOML \"example\" é"}`
+	if b, _ := json.Marshal(got); string(b) != want {
+		t.Errorf("Decode =\n%s\nwant\n%s", b, want)
+	}
+	if _, ok := got["int"].(int64); !ok {
+		t.Errorf("int decoded as %T, want int64", got["int"])
+	}
+	if f, err := Decode("x = -inf"); err != nil || !math.IsInf(f["x"].(float64), -1) {
+		t.Errorf("Decode -inf = %v, %v", f, err)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	for _, doc := range []string{
+		"a = 1\na = 2",
+		"[a]\n[a]",
+		"a = { b = 1 }\n[a]",
+		"a.b = 1\n[a.b]",
+		"a = 01",
+		"a = 1__0",
+		"a = \"open",
+		"a = 1 b = 2",
+		"[[a]]\n[a]",
+		"a = [1,,2]",
+		"a = { b = 1, }",
+	} {
+		if _, err := Decode(doc); err == nil {
+			t.Errorf("Decode(%q) succeeded", doc)
+		}
+	}
+	if _, err := Decode("a = 1\nb = ?"); err == nil || err.Error() != "toml: line 2: expected a value, found '?'" {
+		t.Errorf("error = %v", err)
+	}
+}
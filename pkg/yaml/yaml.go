@@ -0,0 +1,640 @@
+// Package yaml decodes YAML documents into generic Go values: mappings become
+// map[string]interface{}, sequences []interface{}, and scalars are resolved
+// with the YAML 1.2 core schema into nil, bool, int64, float64 or string.
+//
+// The package supports the subset of YAML used in configuration files: block
+// mappings and sequences, flow collections, plain, quoted, literal (|) and
+// folded (>) scalars, and comments. Anchors, aliases, tags, complex keys and
+// multiple documents are not supported and reported as errors. Mapping keys
+// are always strings.
+package yaml
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Error is a syntax error in a YAML document.
+type Error struct {
+	Line int
+	Msg  string
+}
+
+func (e *Error) Error() string { return fmt.Sprintf("yaml: line %d: %s", e.Line, e.Msg) }
+
+// Decode decodes the YAML document text.
+func Decode(text string) (doc interface{}, err error) {
+	p := &parser{lines: strings.Split(strings.Replace(text, "\r\n", "\n", -1), "\n")}
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(*Error)
+			if !ok {
+				panic(r)
+			}
+			doc, err = nil, e
+		}
+	}()
+	p.start()
+	doc = p.block(0)
+	if p.skip(); p.i < len(p.lines) {
+		if p.content() == "---" {
+			p.errorf("multiple documents are not supported")
+		}
+		if p.content() != "..." {
+			p.errorf("unexpected content")
+		}
+	}
+	return doc, nil
+}
+
+type parser struct {
+	lines []string
+	i     int // current line
+}
+
+func (p *parser) errorf(format string, args ...interface{}) {
+	panic(&Error{Line: p.i + 1, Msg: fmt.Sprintf(format, args...)})
+}
+
+// start skips directives and the document start marker.
+func (p *parser) start() {
+	for p.skip(); p.i < len(p.lines); p.skip() {
+		switch c := p.content(); {
+		case strings.HasPrefix(c, "%"):
+			p.i++
+		case c == "---":
+			p.i++
+			return
+		case strings.HasPrefix(c, "--- "):
+			p.lines[p.i] = strings.Repeat(" ", 4) + c[4:]
+			return
+		default:
+			return
+		}
+	}
+}
+
+// skip skips blank and comment lines.
+func (p *parser) skip() {
+	for ; p.i < len(p.lines); p.i++ {
+		if c := p.content(); c != "" {
+			return
+		}
+	}
+}
+
+// indent returns the indentation of the current line.
+func (p *parser) indent() int {
+	line := p.lines[p.i]
+	n := len(line) - len(strings.TrimLeft(line, " "))
+	if n < len(line) && line[n] == '\t' {
+		p.errorf("tabs cannot be used for indentation")
+	}
+	return n
+}
+
+// content returns the current line without indentation and comment.
+func (p *parser) content() string {
+	return stripComment(strings.TrimLeft(p.lines[p.i], " "))
+}
+
+// stripComment removes a trailing comment and spaces from s.
+func stripComment(s string) string {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote == '\'' && c == '\'':
+			if i+1 < len(s) && s[i+1] == '\'' {
+				i++
+			} else {
+				quote = 0
+			}
+		case quote == '"' && c == '\\':
+			i++
+		case quote == '"' && c == '"':
+			quote = 0
+		case quote != 0:
+		case (c == '\'' || c == '"') && (i == 0 || strings.IndexByte(" [{,:-?", s[i-1]) >= 0):
+			quote = c
+		case c == '#' && (i == 0 || s[i-1] == ' ' || s[i-1] == '\t'):
+			return strings.TrimRight(s[:i], " \t")
+		}
+	}
+	return strings.TrimRight(s, " \t")
+}
+
+// block parses the node starting at the current line, which must be indented
+// by at least min; it returns nil if there is none.
+func (p *parser) block(min int) interface{} {
+	if p.skip(); p.i >= len(p.lines) {
+		return nil
+	}
+	indent, c := p.indent(), p.content()
+	switch {
+	case indent < min || c == "---" || c == "...":
+		return nil
+	case isSeqItem(c):
+		return p.sequence(indent)
+	case p.isMapping(c):
+		return p.mapping(indent)
+	}
+	v := p.inline(c)
+	p.i++
+	return v
+}
+
+func isSeqItem(c string) bool { return c == "-" || strings.HasPrefix(c, "- ") }
+
+// sequence parses a block sequence whose items are indented by indent.
+func (p *parser) sequence(indent int) []interface{} {
+	list := []interface{}{}
+	for p.skip(); p.i < len(p.lines) && p.indent() == indent && isSeqItem(p.content()); p.skip() {
+		c := p.content()
+		rest := strings.TrimLeft(c[1:], " ")
+		if rest == "" {
+			p.i++
+			list = append(list, p.block(indent+1))
+			continue
+		}
+		// Parse the rest of the line as a node indented past the dash, so that
+		// "- key: value" starts a mapping continued by the following lines.
+		inner := indent + len(c) - len(rest)
+		p.lines[p.i] = strings.Repeat(" ", inner) + rest
+		list = append(list, p.block(inner))
+	}
+	if p.i < len(p.lines) && p.indent() > indent {
+		p.errorf("bad indentation of a sequence entry")
+	}
+	return list
+}
+
+// mapping parses a block mapping whose keys are indented by indent.
+func (p *parser) mapping(indent int) map[string]interface{} {
+	m := make(map[string]interface{})
+	for p.skip(); p.i < len(p.lines) && p.indent() == indent; p.skip() {
+		c := p.content()
+		if isSeqItem(c) || c == "---" || c == "..." {
+			break
+		}
+		if !p.isMapping(c) {
+			p.errorf("expected a mapping key")
+		}
+		key, rest := p.splitKey(c)
+		if _, ok := m[key]; ok {
+			p.errorf("duplicate key '%s'", key)
+		}
+		switch {
+		case rest == "":
+			p.i++
+			if p.skip(); p.i < len(p.lines) && p.indent() == indent && isSeqItem(p.content()) {
+				m[key] = p.sequence(indent) // sequences may be at the indentation of the key
+			} else {
+				m[key] = p.block(indent + 1)
+			}
+		case rest[0] == '|' || rest[0] == '>':
+			m[key] = p.blockScalar(rest, indent)
+		default:
+			m[key] = p.inline(rest)
+			p.i++
+		}
+	}
+	if p.i < len(p.lines) && p.indent() > indent {
+		p.errorf("bad indentation of a mapping entry")
+	}
+	return m
+}
+
+// isMapping reports whether the line content c starts with a mapping key.
+func (p *parser) isMapping(c string) bool {
+	_, ok := p.keyEnd(c)
+	return ok
+}
+
+// keyEnd returns the index of the colon ending the key at the start of c.
+func (p *parser) keyEnd(c string) (int, bool) {
+	if c == "" || c[0] == '[' || c[0] == '{' {
+		return 0, false
+	}
+	if c[0] == '?' && (len(c) == 1 || c[1] == ' ') {
+		p.errorf("complex keys are not supported")
+	}
+	i := 0
+	if c[0] == '"' || c[0] == '\'' {
+		_, n := quoted(c)
+		if n < 0 {
+			return 0, false
+		}
+		i = n
+		for i < len(c) && c[i] == ' ' {
+			i++
+		}
+		return i, i < len(c) && c[i] == ':' && (i+1 == len(c) || c[i+1] == ' ')
+	}
+	for ; i < len(c); i++ {
+		if c[i] == ':' && (i+1 == len(c) || c[i+1] == ' ') {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// splitKey splits the line content c into its key and the value after it.
+func (p *parser) splitKey(c string) (string, string) {
+	end, _ := p.keyEnd(c)
+	key := strings.TrimRight(c[:end], " ")
+	if key != "" && (key[0] == '"' || key[0] == '\'') {
+		s, _ := quoted(key)
+		key = s
+	} else if strings.HasPrefix(key, "<<") {
+		p.errorf("merge keys are not supported")
+	}
+	return key, strings.TrimLeft(c[end+1:], " ")
+}
+
+// inline parses the value c on the current line, which may be a flow
+// collection continued on the following lines.
+func (p *parser) inline(c string) interface{} {
+	switch c[0] {
+	case '&', '*', '!':
+		p.errorf("anchors, aliases and tags are not supported")
+	case '|', '>':
+		p.errorf("block scalar not allowed here")
+	case '[', '{':
+		text := c
+		for first := p.i; !balanced(text); {
+			if p.i++; p.i >= len(p.lines) {
+				p.i = first
+				p.errorf("unterminated flow collection")
+			}
+			text += " " + p.content()
+		}
+		f := &flow{p: p, s: text}
+		v := f.value()
+		if f.skipSpace(); f.pos < len(f.s) {
+			p.errorf("unexpected '%s' after flow collection", f.s[f.pos:])
+		}
+		return v
+	case '"', '\'':
+		s, n := quoted(c)
+		if n < 0 {
+			p.errorf("unterminated string")
+		}
+		if strings.TrimSpace(c[n:]) != "" {
+			p.errorf("unexpected '%s' after string", strings.TrimSpace(c[n:]))
+		}
+		return s
+	}
+	return resolve(c)
+}
+
+// balanced reports whether the flow brackets of s are closed.
+func balanced(s string) bool {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"', '\'':
+			_, n := quoted(s[i:])
+			if n < 0 {
+				return false
+			}
+			i += n - 1
+		case '[', '{':
+			depth++
+		case ']', '}':
+			depth--
+		}
+	}
+	return depth <= 0
+}
+
+// blockScalar parses a literal or folded scalar whose header is h, in the
+// value of a key indented by indent.
+func (p *parser) blockScalar(h string, indent int) string {
+	folded := h[0] == '>'
+	chomp := byte(0)
+	blockIndent := 0
+	for _, c := range h[1:] {
+		switch {
+		case c == '-' || c == '+':
+			chomp = byte(c)
+		case c >= '1' && c <= '9':
+			blockIndent = indent + int(c-'0')
+		default:
+			p.errorf("invalid block scalar header '%s'", h)
+		}
+	}
+	p.i++
+	var lines []string
+	for ; p.i < len(p.lines); p.i++ {
+		line := p.lines[p.i]
+		if strings.TrimSpace(line) == "" {
+			lines = append(lines, "")
+			continue
+		}
+		n := len(line) - len(strings.TrimLeft(line, " "))
+		if blockIndent == 0 {
+			blockIndent = n
+		}
+		if n < blockIndent || n <= indent {
+			break
+		}
+		lines = append(lines, line[blockIndent:])
+	}
+	// trailing blank lines are subject to chomping
+	trailing := 0
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+		trailing++
+	}
+	var b strings.Builder
+	for i, line := range lines {
+		if i > 0 {
+			prev := lines[i-1]
+			switch {
+			case !folded || prev == "" || prev[0] == ' ':
+				b.WriteByte('\n')
+			case line == "":
+				// the line break is folded into the blank line
+			case line[0] == ' ':
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteString(line)
+	}
+	switch {
+	case len(lines) == 0 || chomp == '-':
+	case chomp == '+':
+		b.WriteString(strings.Repeat("\n", trailing+1))
+	default:
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// flow parses flow collections.
+type flow struct {
+	p   *parser
+	s   string
+	pos int
+}
+
+func (f *flow) skipSpace() {
+	for f.pos < len(f.s) && f.s[f.pos] == ' ' {
+		f.pos++
+	}
+}
+
+func (f *flow) value() interface{} {
+	f.skipSpace()
+	if f.pos >= len(f.s) {
+		f.p.errorf("unexpected end of flow collection")
+	}
+	switch c := f.s[f.pos]; c {
+	case '[':
+		f.pos++
+		list := []interface{}{}
+		for f.skipSpace(); !f.consume(']'); f.skipSpace() {
+			list = append(list, f.value())
+			if f.skipSpace(); !f.consume(',') {
+				f.skipSpace()
+				f.expect(']')
+				break
+			}
+		}
+		return list
+	case '{':
+		f.pos++
+		m := make(map[string]interface{})
+		for f.skipSpace(); !f.consume('}'); f.skipSpace() {
+			key := f.scalar(true)
+			k, ok := key.(string)
+			if !ok {
+				k = fmt.Sprint(key)
+			}
+			if _, dup := m[k]; dup {
+				f.p.errorf("duplicate key '%s'", k)
+			}
+			f.skipSpace()
+			if f.consume(':') {
+				m[k] = f.value()
+			} else {
+				m[k] = nil
+			}
+			if f.skipSpace(); !f.consume(',') {
+				f.skipSpace()
+				f.expect('}')
+				break
+			}
+		}
+		return m
+	case '&', '*', '!':
+		f.p.errorf("anchors, aliases and tags are not supported")
+	}
+	return f.scalar(false)
+}
+
+// scalar parses a flow scalar; keys end at a colon.
+func (f *flow) scalar(key bool) interface{} {
+	rest := f.s[f.pos:]
+	if rest != "" && (rest[0] == '"' || rest[0] == '\'') {
+		s, n := quoted(rest)
+		if n < 0 {
+			f.p.errorf("unterminated string")
+		}
+		f.pos += n
+		return s
+	}
+	end := 0
+	for ; end < len(rest); end++ {
+		c := rest[end]
+		if c == ',' || c == ']' || c == '}' || c == '[' || c == '{' {
+			break
+		}
+		if c == ':' && (end+1 == len(rest) || strings.IndexByte(" ,]}", rest[end+1]) >= 0) {
+			break
+		}
+	}
+	f.pos += end
+	plain := strings.TrimSpace(rest[:end])
+	if key {
+		return plain
+	}
+	return resolve(plain)
+}
+
+func (f *flow) consume(c byte) bool {
+	if f.pos < len(f.s) && f.s[f.pos] == c {
+		f.pos++
+		return true
+	}
+	return false
+}
+
+func (f *flow) expect(c byte) {
+	if !f.consume(c) {
+		f.p.errorf("expected '%c' in flow collection", c)
+	}
+}
+
+// quoted decodes the quoted string at the start of s and returns it with the
+// length of its source, or -1 if it is not terminated.
+func quoted(s string) (string, int) {
+	var b strings.Builder
+	if s[0] == '\'' {
+		for i := 1; i < len(s); i++ {
+			if s[i] == '\'' {
+				if i+1 < len(s) && s[i+1] == '\'' {
+					b.WriteByte('\'')
+					i++
+					continue
+				}
+				return b.String(), i + 1
+			}
+			b.WriteByte(s[i])
+		}
+		return "", -1
+	}
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"':
+			return b.String(), i + 1
+		case '\\':
+			if i++; i >= len(s) {
+				return "", -1
+			}
+			n := 0
+			switch s[i] {
+			case '0':
+				b.WriteByte(0)
+			case 'a':
+				b.WriteByte('\a')
+			case 'b':
+				b.WriteByte('\b')
+			case 't', '\t':
+				b.WriteByte('\t')
+			case 'n':
+				b.WriteByte('\n')
+			case 'v':
+				b.WriteByte('\v')
+			case 'f':
+				b.WriteByte('\f')
+			case 'r':
+				b.WriteByte('\r')
+			case 'e':
+				b.WriteByte(0x1b)
+			case ' ', '"', '/', '\\':
+				b.WriteByte(s[i])
+			case 'x':
+				n = 2
+			case 'u':
+				n = 4
+			case 'U':
+				n = 8
+			default:
+				return "", -1
+			}
+			if n > 0 {
+				if i+n >= len(s) {
+					return "", -1
+				}
+				r, err := strconv.ParseUint(s[i+1:i+1+n], 16, 32)
+				if err != nil {
+					return "", -1
+				}
+				if n == 2 {
+					b.WriteByte(byte(r))
+				} else if utf8.ValidRune(rune(r)) {
+					b.WriteRune(rune(r))
+				} else {
+					return "", -1
+				}
+				i += n
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", -1
+}
+
+// resolve resolves a plain scalar with the core schema.
+func resolve(s string) interface{} {
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	case ".inf", ".Inf", ".INF", "+.inf", "+.Inf", "+.INF":
+		return math.Inf(1)
+	case "-.inf", "-.Inf", "-.INF":
+		return math.Inf(-1)
+	case ".nan", ".NaN", ".NAN":
+		return math.NaN()
+	}
+	if strings.HasPrefix(s, "0x") {
+		if i, err := strconv.ParseInt(s[2:], 16, 64); err == nil {
+			return i
+		}
+		return s
+	}
+	if strings.HasPrefix(s, "0o") {
+		if i, err := strconv.ParseInt(s[2:], 8, 64); err == nil {
+			return i
+		}
+		return s
+	}
+	if isInt(s) {
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i
+		}
+		f, _ := strconv.ParseFloat(s, 64)
+		return f
+	}
+	if isFloat(s) {
+		f, _ := strconv.ParseFloat(s, 64)
+		return f
+	}
+	return s
+}
+
+// isInt reports whether s matches [-+]?[0-9]+.
+func isInt(s string) bool {
+	if s != "" && (s[0] == '+' || s[0] == '-') {
+		s = s[1:]
+	}
+	return s != "" && strings.Trim(s, "0123456789") == ""
+}
+
+// isFloat reports whether s matches [-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?.
+func isFloat(s string) bool {
+	if s != "" && (s[0] == '+' || s[0] == '-') {
+		s = s[1:]
+	}
+	mantissa, exp := s, ""
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		mantissa, exp = s[:i], s[i+1:]
+		if exp != "" && (exp[0] == '+' || exp[0] == '-') {
+			exp = exp[1:]
+		}
+		if exp == "" || strings.Trim(exp, "0123456789") != "" {
+			return false
+		}
+	}
+	intPart, frac := mantissa, ""
+	if i := strings.IndexByte(mantissa, '.'); i >= 0 {
+		intPart, frac = mantissa[:i], mantissa[i+1:]
+		if intPart == "" && frac == "" {
+			return false
+		}
+	} else if intPart == "" {
+		return false
+	}
+	return strings.Trim(intPart, "0123456789") == "" && strings.Trim(frac, "0123456789") == ""
+}
@@ -0,0 +1,82 @@
+package yaml
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	const doc = `%YAML 1.2
+---
+# game config
+name: "Dragon \u00e9"   # comment
+title: It's a 'quote' # not a string
+level: 12
+speed: 1.5
+hex: 0x1F
+enabled: yes
+off: False
+none: ~
+url: http://example.com/a#b
+tags: [fire, "ice", 3]
+stats: {hp: 100, mp: 20.5}
+drops:
+- item: sword
+  chance: 0.25
+- item: shield
+  extra:
+    - 1
+    - - 2
+      - 3
+nested:
+  deep:
+    key: 'single ''quoted'''
+literal: |
+  line one
+    indented
+
+  line three
+folded: >-
+  folded
+  text
+
+  para
+keep: |+
+  kept
+
+empty:
+...
+`
+	got, err := Decode(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = `{"drops":[{"chance":0.25,"item":"sword"},{"extra":[1,[2,3]],"item":"shield"}],"empty":null,` +
+		`"enabled":"yes","folded":"folded text\npara","hex":31,"keep":"kept\n\n","level":12,` +
+		`"literal":"line one\n  indented\n\nline three\n","name":"Dragon é","nested":{"deep":{"key":"single 'quoted'"}},` +
+		`"none":null,"off":false,"speed":1.5,"stats":{"hp":100,"mp":20.5},"tags":["fire","ice",3],` +
+		`"title":"It's a 'quote'","url":"http://example.com/a#b"}`
+	if b, _ := json.Marshal(got); string(b) != want {
+		t.Errorf("Decode =\n%s\nwant\n%s", b, want)
+	}
+	if _, ok := got.(map[string]interface{})["level"].(int64); !ok {
+		t.Errorf("level decoded as %T, want int64", got.(map[string]interface{})["level"])
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct{ doc, err string }{
+		{"a: 1\na: 2", "yaml: line 2: duplicate key 'a'"},
+		{"a: &x 1", "yaml: line 1: anchors, aliases and tags are not supported"},
+		{"a: [1, 2", "yaml: line 1: unterminated flow collection"},
+		{"a:\n  - 1\n   - 2", "yaml: line 3: bad indentation of a sequence entry"},
+		{"a: 1\n---\nb: 2", "yaml: line 2: multiple documents are not supported"},
+		{"a: \"x", "yaml: line 1: unterminated string"},
+		{"a:\n\tb: 1", "yaml: line 2: tabs cannot be used for indentation"},
+	}
+	for _, test := range tests {
+		if _, err := Decode(test.doc); err == nil || err.Error() != test.err {
+			t.Errorf("Decode(%q) error = %v, want %s", test.doc, err, test.err)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package ai
+
+import (
+	"github.com/Azure/golua/lua"
+)
+
+const (
+	treeTypeName     = "ai.tree"
+	instanceTypeName = "ai.instance"
+	fsmTypeName      = "ai.fsm"
+)
+
+//
+// Lua Extension Library -- ai
+//
+
+// Open opens the ai library, which runs behavior trees and finite state
+// machines natively. Their structure is described once with Lua tables whose
+// leaves are Lua functions:
+//
+//	local ai = require "ai"
+//	local tree = ai.tree{"selector",
+//	    {"sequence", see_enemy, attack},
+//	    {"repeat", patrol},
+//	}
+//	local brain = tree:new()        -- per-agent instance
+//	brain:tick(npc)                 --> "success", "failure" or "running"
+//
+// Node kinds are "sequence", "selector", "parallel" (with an optional success
+// threshold as second element), "invert", "succeed", "fail", "repeat" (with an
+// optional count) and "action". A bare function is an action; it is called with
+// the tick context and returns true or "success", "running", or anything else
+// for failure. Running sequences and selectors resume at the running child on
+// the next tick.
+//
+// State machines are created with ai.fsm{initial = name, states = {...}}; each
+// state is a table with optional enter, update and exit callbacks (or just an
+// update function). When update returns the name of a state, the machine
+// transitions to it.
+//
+// Trees and machines can also be ticked from Go with Instance.Tick and FSM.Tick.
+//
+// The library is not opened by default; it is available through require "ai".
+func Open(state *lua.State) int {
+	// Create 'ai' table.
+	var aiFuncs = map[string]lua.Func{
+		"fsm":  lua.Func(aiFSM),
+		"tree": lua.Func(aiTree),
+	}
+	state.NewTableSize(0, len(aiFuncs))
+	state.SetFuncs(aiFuncs, 0)
+	createMetaTable(state, treeTypeName, map[string]lua.Func{
+		"new": lua.Func(treeNew),
+	})
+	createMetaTable(state, instanceTypeName, map[string]lua.Func{
+		"reset": lua.Func(instanceReset),
+		"tick":  lua.Func(instanceTick),
+	})
+	createMetaTable(state, fsmTypeName, map[string]lua.Func{
+		"state":      lua.Func(fsmCurrent),
+		"tick":       lua.Func(fsmTick),
+		"transition": lua.Func(fsmTransition),
+	})
+
+	// Return 'ai' table.
+	return 1
+}
+
+func createMetaTable(state *lua.State, name string, funcs map[string]lua.Func) {
+	state.NewMetaTable(name)
+	state.PushIndex(-1)           // push metatable
+	state.SetField(-2, "__index") // metatable.__index = metatable
+	state.SetFuncs(funcs, 0)
+	state.Pop()
+}
+
+// ai.tree (spec)
+//
+// Compiles the behavior tree described by spec and returns its definition.
+func aiTree(state *lua.State) int {
+	state.CheckAny(1)
+	state.Push(compileTree(state, 1))
+	state.SetMetaTable(treeTypeName)
+	return 1
+}
+
+// tree:new ()
+//
+// Returns a new instance of the tree with its own running state.
+func treeNew(state *lua.State) int {
+	tree := state.CheckUserData(1, treeTypeName).(*Tree)
+	state.Push(tree.NewInstance())
+	state.SetMetaTable(instanceTypeName)
+	return 1
+}
+
+// instance:tick ([ctx])
+//
+// Ticks the tree once with the context ctx passed to every action, and returns
+// "success", "failure" or "running".
+func instanceTick(state *lua.State) int {
+	inst := CheckInstance(state, 1)
+	state.Push(inst.Tick(state, context(state, 2)).String())
+	return 1
+}
+
+// instance:reset ()
+//
+// Forgets which nodes are running, so that the next tick starts from scratch.
+func instanceReset(state *lua.State) int {
+	CheckInstance(state, 1).Reset()
+	return 0
+}
+
+// ai.fsm (spec)
+//
+// Returns a new state machine described by spec, after calling the enter
+// callback of its initial state with the optional context ctx.
+func aiFSM(state *lua.State) int {
+	m := compileFSM(state, 1)
+	m.Start(state, context(state, 2))
+	state.Push(m)
+	state.SetMetaTable(fsmTypeName)
+	return 1
+}
+
+// fsm:tick ([ctx])
+//
+// Calls the update callback of the current state and performs the transition
+// it requests, if any. Returns the name of the resulting state.
+func fsmTick(state *lua.State) int {
+	m := CheckFSM(state, 1)
+	m.Tick(state, context(state, 2))
+	state.Push(m.Current())
+	return 1
+}
+
+// fsm:transition (name [, ctx])
+//
+// Transitions to the state name, calling the exit and enter callbacks.
+func fsmTransition(state *lua.State) int {
+	m := CheckFSM(state, 1)
+	name := state.CheckString(2)
+	m.Transition(state, context(state, 3), name)
+	return 0
+}
+
+// fsm:state ()
+//
+// Returns the name of the current state.
+func fsmCurrent(state *lua.State) int {
+	state.Push(CheckFSM(state, 1).Current())
+	return 1
+}
+
+// context returns the optional tick context at index.
+func context(state *lua.State, index int) lua.Value {
+	if state.IsNone(index) {
+		return lua.None
+	}
+	return state.CheckAny(index)
+}
+
+// CheckInstance checks whether the function argument at index is a behavior
+// tree instance and returns it.
+func CheckInstance(state *lua.State, index int) *Instance {
+	return state.CheckUserData(index, instanceTypeName).(*Instance)
+}
+
+// CheckFSM checks whether the function argument at index is a state machine
+// and returns it.
+func CheckFSM(state *lua.State, index int) *FSM {
+	return state.CheckUserData(index, fsmTypeName).(*FSM)
+}
@@ -0,0 +1,105 @@
+package ai
+
+import (
+	"github.com/Azure/golua/lua"
+)
+
+// fsmState holds the callbacks of a state machine state.
+type fsmState struct {
+	enter, update, exit lua.Value
+}
+
+// FSM is a finite state machine whose states are described by Lua callbacks.
+type FSM struct {
+	states  map[string]*fsmState
+	current string
+}
+
+// compileFSM compiles the state machine spec at index:
+//
+//	{initial = "idle", states = {idle = {enter = f, update = g, exit = h}, ...}}
+//
+// A state may also be given as a single update function.
+func compileFSM(state *lua.State, index int) *FSM {
+	index = state.AbsIndex(index)
+	state.CheckType(index, lua.TableType)
+	m := &FSM{states: make(map[string]*fsmState)}
+	if state.GetField(index, "states") != lua.TableType {
+		state.Errorf("state machine expects a 'states' table")
+	}
+	state.Push(nil)
+	for state.Next(-2) {
+		if state.TypeAt(-2) != lua.StringType {
+			state.Errorf("state name must be a string")
+		}
+		name := state.ToString(-2)
+		s := new(fsmState)
+		switch state.TypeAt(-1) {
+		case lua.FuncType:
+			s.update = state.CheckAny(-1)
+		case lua.TableType:
+			for _, cb := range []struct {
+				name string
+				fn   *lua.Value
+			}{{"enter", &s.enter}, {"update", &s.update}, {"exit", &s.exit}} {
+				if state.GetField(-1, cb.name); state.IsFunc(-1) {
+					*cb.fn = state.CheckAny(-1)
+				} else if !state.IsNoneOrNil(-1) {
+					state.Errorf("'%s' of state '%s' must be a function", cb.name, name)
+				}
+				state.Pop()
+			}
+		default:
+			state.Errorf("invalid state '%s' (%s)", name, state.TypeAt(-1))
+		}
+		m.states[name] = s
+		state.Pop()
+	}
+	state.Pop()
+	state.GetField(index, "initial")
+	m.current = state.ToString(-1)
+	state.Pop()
+	if _, ok := m.states[m.current]; !ok {
+		state.Errorf("unknown initial state '%s'", m.current)
+	}
+	return m
+}
+
+// Current returns the name of the current state.
+func (m *FSM) Current() string { return m.current }
+
+// Start calls the enter callback of the current state.
+func (m *FSM) Start(state *lua.State, ctx lua.Value) {
+	call(state, m.states[m.current].enter, ctx)
+}
+
+// Tick calls the update callback of the current state with ctx. If it returns
+// the name of a state, the machine transitions to that state.
+func (m *FSM) Tick(state *lua.State, ctx lua.Value) {
+	if next := call(state, m.states[m.current].update, ctx); next.Type() == lua.StringType {
+		m.Transition(state, ctx, next.String())
+	}
+}
+
+// Transition leaves the current state and enters the named state, calling the
+// exit and enter callbacks. Transitioning to the current state re-enters it.
+func (m *FSM) Transition(state *lua.State, ctx lua.Value, name string) {
+	next, ok := m.states[name]
+	if !ok {
+		state.Errorf("unknown state '%s'", name)
+	}
+	call(state, m.states[m.current].exit, ctx)
+	m.current = name
+	call(state, next.enter, ctx)
+}
+
+// call calls the optional callback fn with ctx and returns its first result.
+func call(state *lua.State, fn, ctx lua.Value) lua.Value {
+	if fn == nil {
+		return lua.None
+	}
+	state.Push(fn)
+	state.Push(ctx)
+	state.Call(1, 1)
+	return state.Pop()
+}
@@ -0,0 +1,242 @@
+package ai
+
+import (
+	"fmt"
+
+	"github.com/Azure/golua/lua"
+)
+
+// Status is the result of ticking a behavior tree node.
+type Status int
+
+const (
+	Failure Status = iota
+	Success
+	Running
+)
+
+var statusNames = [...]string{Failure: "failure", Success: "success", Running: "running"}
+
+func (s Status) String() string { return statusNames[s] }
+
+type nodeKind int
+
+const (
+	actionNode nodeKind = iota
+	sequenceNode
+	selectorNode
+	parallelNode
+	inverterNode
+	succeederNode
+	failerNode
+	repeatNode
+)
+
+var nodeKinds = map[string]nodeKind{
+	"action":   actionNode,
+	"sequence": sequenceNode,
+	"selector": selectorNode,
+	"parallel": parallelNode,
+	"invert":   inverterNode,
+	"succeed":  succeederNode,
+	"fail":     failerNode,
+	"repeat":   repeatNode,
+}
+
+// node is a compiled behavior tree node. Composite nodes keep their running
+// child in the instance memory slot id.
+type node struct {
+	id       int
+	kind     nodeKind
+	action   lua.Value // action function for leaves
+	children []*node
+	count    int // repeat count or parallel success threshold
+}
+
+// Tree is a compiled behavior tree definition shared by its instances.
+type Tree struct {
+	root  *node
+	nodes int
+}
+
+// compileTree compiles the node spec at index.
+func compileTree(state *lua.State, index int) *Tree {
+	t := new(Tree)
+	t.root = t.compile(state, state.AbsIndex(index))
+	return t
+}
+
+// compile compiles the node at index, which is either an action function or a
+// table {kind, children...} whose first element names the node kind.
+func (t *Tree) compile(state *lua.State, index int) *node {
+	n := &node{id: t.nodes}
+	t.nodes++
+	switch state.TypeAt(index) {
+	case lua.FuncType:
+		n.kind, n.action = actionNode, state.CheckAny(index)
+		return n
+	case lua.TableType:
+	default:
+		state.Errorf("invalid behavior tree node (%s)", state.TypeAt(index))
+	}
+	state.RawGetIndex(index, 1)
+	name, ok := state.TryString(-1)
+	state.Pop()
+	kind, known := nodeKinds[name]
+	if !ok || !known {
+		state.Errorf("unknown behavior tree node kind '%v'", name)
+	}
+	n.kind = kind
+	first := 2
+	switch kind {
+	case actionNode:
+		state.RawGetIndex(index, 2)
+		if !state.IsFunc(-1) {
+			state.Errorf("action node expects a function")
+		}
+		n.action = state.Pop()
+		return n
+	case repeatNode, parallelNode:
+		state.RawGetIndex(index, 2)
+		if count, ok := state.TryInt(-1); ok && state.IsNumber(-1) {
+			n.count = int(count)
+			first = 3
+		}
+		state.Pop()
+	}
+	for i, size := first, state.RawLen(index); i <= size; i++ {
+		state.RawGetIndex(index, i)
+		n.children = append(n.children, t.compile(state, state.AbsIndex(-1)))
+		state.Pop()
+	}
+	switch kind {
+	case inverterNode, succeederNode, failerNode, repeatNode:
+		if len(n.children) != 1 {
+			state.Errorf("'%s' node expects exactly one child", name)
+		}
+	default:
+		if len(n.children) == 0 {
+			state.Errorf("'%s' node expects children", name)
+		}
+	}
+	if kind == parallelNode && n.count <= 0 {
+		n.count = len(n.children)
+	}
+	return n
+}
+
+// Instance is a behavior tree together with the per-agent memory of which
+// children are running.
+type Instance struct {
+	tree    *Tree
+	running []int // running child (+1) or repeat iteration per node
+}
+
+// NewInstance returns a fresh instance of the tree.
+func (t *Tree) NewInstance() *Instance {
+	return &Instance{tree: t, running: make([]int, t.nodes)}
+}
+
+// Tick runs the tree once for the agent ctx and returns the status of the root.
+func (inst *Instance) Tick(state *lua.State, ctx lua.Value) Status {
+	return inst.tick(state, inst.tree.root, ctx)
+}
+
+// Reset forgets all running nodes.
+func (inst *Instance) Reset() {
+	for i := range inst.running {
+		inst.running[i] = 0
+	}
+}
+
+func (inst *Instance) tick(state *lua.State, n *node, ctx lua.Value) Status {
+	switch n.kind {
+	case actionNode:
+		return callAction(state, n.action, ctx)
+	case sequenceNode, selectorNode:
+		// resume at the running child, if any
+		stop := Failure
+		if n.kind == sequenceNode {
+			stop = Success
+		}
+		for i := inst.running[n.id]; i < len(n.children); i++ {
+			switch status := inst.tick(state, n.children[i], ctx); {
+			case status == Running:
+				inst.running[n.id] = i
+				return Running
+			case status != stop:
+				inst.running[n.id] = 0
+				return status
+			}
+		}
+		inst.running[n.id] = 0
+		return stop
+	case parallelNode:
+		succeeded, failed := 0, 0
+		for _, child := range n.children {
+			switch inst.tick(state, child, ctx) {
+			case Success:
+				succeeded++
+			case Failure:
+				failed++
+			}
+		}
+		switch {
+		case succeeded >= n.count:
+			return Success
+		case failed > len(n.children)-n.count:
+			return Failure
+		}
+		return Running
+	case inverterNode:
+		switch inst.tick(state, n.children[0], ctx) {
+		case Success:
+			return Failure
+		case Failure:
+			return Success
+		}
+		return Running
+	case succeederNode, failerNode:
+		if inst.tick(state, n.children[0], ctx) == Running {
+			return Running
+		}
+		if n.kind == succeederNode {
+			return Success
+		}
+		return Failure
+	case repeatNode:
+		// repeat the child until it fails or has succeeded count times
+		// (forever if count is 0); a running child suspends the loop.
+		for n.count <= 0 || inst.running[n.id] < n.count {
+			switch inst.tick(state, n.children[0], ctx) {
+			case Running:
+				return Running
+			case Failure:
+				inst.running[n.id] = 0
+				return Failure
+			}
+			inst.running[n.id]++
+			if n.count <= 0 {
+				return Running
+			}
+		}
+		inst.running[n.id] = 0
+		return Success
+	}
+	panic(fmt.Errorf("unknown node kind %d", n.kind))
+}
+
+// callAction calls a leaf action and converts its result to a Status: true or
+// "success" is success, "running" is running and anything else is failure.
+func callAction(state *lua.State, action, ctx lua.Value) Status {
+	state.Push(action)
+	state.Push(ctx)
+	state.Call(1, 1)
+	switch result := state.Pop(); result {
+	case lua.Bool(true), lua.String("success"):
+		return Success
+	case lua.String("running"):
+		return Running
+	}
+	return Failure
+}
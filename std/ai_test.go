@@ -0,0 +1,178 @@
+package std
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Azure/golua/lua"
+)
+
+func TestBehaviorTree(t *testing.T) {
+	state := lua.NewState()
+	defer state.Close()
+	Open(state)
+	lib := require(t, state, "ai")
+
+	var log []string
+	// act returns an action that logs its name and returns results in turn,
+	// repeating the last one.
+	act := func(name string, results ...interface{}) lua.Func {
+		return func(state *lua.State) int {
+			log = append(log, name)
+			state.Push(results[0])
+			if len(results) > 1 {
+				results = results[1:]
+			}
+			return 1
+		}
+	}
+	compile := func(spec ...interface{}) (lua.Value, error) {
+		state.Push(lib)
+		state.GetField(-1, "tree")
+		if err := state.PushValue(spec); err != nil {
+			t.Fatal(err)
+		}
+		defer state.SetTop(0)
+		if err := state.PCall(1, 1, 0); err != nil {
+			return nil, err
+		}
+		return state.CheckAny(-1), nil
+	}
+
+	var tests = []struct {
+		spec []interface{}
+		want string // statuses and logs of successive ticks
+	}{
+		{
+			[]interface{}{"sequence", act("a", true), act("b", "running", "running", "success")},
+			"running[a b] running[b] success[b] success[a b]",
+		},
+		{
+			[]interface{}{"selector", act("a", false), act("b", "running", "failure"), act("c", "success")},
+			"running[a b] success[b c] success[a b c] success[a b c]",
+		},
+		{
+			[]interface{}{"parallel", 2, act("a", true), act("b", false, true), act("c", "running", false)},
+			"running[a b c] success[a b c] success[a b c] success[a b c]",
+		},
+		{
+			[]interface{}{"parallel", act("a", true), act("b", "running", false)},
+			"running[a b] failure[a b] failure[a b] failure[a b]",
+		},
+		{
+			[]interface{}{"invert", []interface{}{"action", act("a", true, "running", "nope")}},
+			"failure[a] running[a] success[a] success[a]",
+		},
+		{
+			[]interface{}{"succeed", []interface{}{"fail", act("a", true, "running", false)}},
+			"success[a] running[a] success[a] success[a]",
+		},
+		{
+			[]interface{}{"repeat", 2, act("a", true, "running", true, false)},
+			"running[a a] success[a] failure[a] failure[a]",
+		},
+		{
+			[]interface{}{"repeat", act("a", true, true, false, true)},
+			"running[a] running[a] failure[a] running[a]",
+		},
+	}
+	for i, tt := range tests {
+		tree, err := compile(tt.spec...)
+		if err != nil {
+			t.Fatalf("#%d: %v", i, err)
+		}
+		state.Push(tree)
+		state.GetField(-1, "new")
+		state.Insert(-2)
+		state.Call(1, 1)
+		brain := state.Pop()
+		var got []string
+		for tick := 0; tick < 4; tick++ {
+			log = nil
+			status := callMethod(t, state, brain, "tick", "npc")
+			got = append(got, status[0].(string)+"["+strings.Join(log, " ")+"]")
+		}
+		if strings.Join(got, " ") != tt.want {
+			t.Errorf("#%d: ticks = %s; want %s", i, strings.Join(got, " "), tt.want)
+		}
+	}
+
+	for _, tt := range []struct {
+		spec []interface{}
+		want string
+	}{
+		{[]interface{}{"loop", act("a", true)}, "unknown behavior tree node kind 'loop'"},
+		{[]interface{}{"invert", act("a", true), act("b", true)}, "'invert' node expects exactly one child"},
+		{[]interface{}{"sequence"}, "'sequence' node expects children"},
+		{[]interface{}{"selector", 42}, "invalid behavior tree node (number)"},
+		{[]interface{}{"action", "a"}, "action node expects a function"},
+	} {
+		if _, err := compile(tt.spec...); err == nil || !strings.HasSuffix(err.Error(), tt.want) {
+			t.Errorf("compile %v: error = %v; want %q", tt.spec, err, tt.want)
+		}
+	}
+}
+
+func TestStateMachine(t *testing.T) {
+	state := lua.NewState()
+	defer state.Close()
+	Open(state)
+	lib := require(t, state, "ai")
+
+	var log []string
+	logs := func(name string, next interface{}) lua.Func {
+		return func(state *lua.State) int {
+			log = append(log, name+":"+state.ToString(1))
+			state.Push(next)
+			return 1
+		}
+	}
+	state.Push(lib)
+	state.GetField(-1, "fsm")
+	state.PushValue(map[string]interface{}{
+		"initial": "idle",
+		"states": map[string]interface{}{
+			"idle": map[string]interface{}{
+				"enter":  logs("enter idle", nil),
+				"update": logs("update idle", "walk"),
+			},
+			"walk": map[string]interface{}{
+				"update": logs("update walk", nil),
+				"exit":   logs("exit walk", nil),
+			},
+		},
+	})
+	state.Call(1, 1)
+	machine := state.Pop()
+	state.Pop()
+
+	step := func(name string, args ...interface{}) string {
+		log = nil
+		callMethod(t, state, machine, name, args...)
+		current := callMethod(t, state, machine, "state")[0]
+		return current.(string) + " " + strings.Join(log, ",")
+	}
+	var tests = []struct {
+		method string
+		args   []interface{}
+		want   string
+	}{
+		{"tick", []interface{}{"1"}, "walk update idle:1"},
+		{"tick", []interface{}{"2"}, "walk update walk:2"},
+		{"transition", []interface{}{"idle", "3"}, "idle exit walk:3,enter idle:3"},
+		{"transition", []interface{}{"idle", "4"}, "idle enter idle:4"},
+	}
+	for i, tt := range tests {
+		if got := step(tt.method, tt.args...); got != tt.want {
+			t.Errorf("#%d: %s%v = %q; want %q", i, tt.method, tt.args, got, tt.want)
+		}
+	}
+
+	state.Push(machine)
+	state.GetField(-1, "transition")
+	state.Insert(-2)
+	state.Push("run")
+	if err := state.PCall(2, 0, 0); err == nil || !strings.HasSuffix(err.Error(), "unknown state 'run'") {
+		t.Errorf("transition to an unknown state: error = %v", err)
+	}
+}
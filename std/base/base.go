@@ -1,10 +1,9 @@
 package base
 
 import (
-	"fmt"
+	"io"
 	"os"
 	"runtime"
-	"strconv"
 	"strings"
 
 	"github.com/Azure/golua/lua"
@@ -72,7 +71,7 @@ func baseAssert(state *lua.State) int {
 	}
 	state.CheckAny(1)
 	state.Remove(1)
-	state.Push("assert failed!")
+	state.Push("assertion failed!")
 	state.SetTop(1)
 	return baseError(state)
 }
@@ -95,6 +94,10 @@ func baseDoFile(state *lua.State) int {
 	if file == "" {
 		file = "stdin"
 		src = os.Stdin
+	} else if b, err := state.ReadFile(file); err != nil {
+		panic(err)
+	} else {
+		src = b
 	}
 	if err := state.LoadChunk(file, src, 0); err != nil {
 		panic(err)
@@ -142,23 +145,29 @@ func baseGetMetaTable(state *lua.State) int {
 
 // ipairs(t)
 //
+// Iterates over t[1], t[2], ... up to the first nil value. The access is not
+// raw, so proxy tables iterate over the values of their __index metamethod.
+//
 // See https://www.lua.org/manual/5.3/manual.html#pdf-ipairs
 func baseIPairs(state *lua.State) int {
-	ipairs := func(state *lua.State) int { // iterator function
-		i := state.CheckInt(2) + 1
-		state.Push(i)
-		if t := state.GetIndex(1, i); t == lua.NilType || t == lua.NoneType {
-			return 1
-		}
-		return 2
-	}
 	state.CheckAny(1)
-	state.PushClosure(ipairs, 0) // return iterator,
-	state.PushIndex(1)           // state,
-	state.Push(0)                // initial value
+	state.PushClosure(ipairsAux, 0) // return iterator,
+	state.PushIndex(1)              // state,
+	state.Push(0)                   // initial value
 	return 3
 }
 
+// ipairsAux is the iterator function of ipairs. Values present in a table are
+// returned without looking up its metatable; only absent ones go to __index.
+func ipairsAux(state *lua.State) int {
+	i := state.CheckInt(2) + 1
+	state.Push(i)
+	if t := state.GetIndex(1, i); t == lua.NilType || t == lua.NoneType {
+		return 1 // first nil ends the loop
+	}
+	return 2
+}
+
 // loadfile([filename [, mode [, env]]])
 //
 // See https://www.lua.org/manual/5.3/manual.html#pdf-loadfile
@@ -177,7 +186,17 @@ func baseLoadFile(state *lua.State) int {
 	if !state.IsNone(3) {
 		env = 3
 	}
-	if err := state.LoadChunk(name, nil, mode); err != nil {
+	var src interface{} = os.Stdin
+	if name != "" {
+		b, err := state.ReadFile(name)
+		if err != nil {
+			state.Push(nil)
+			state.Push(err.Error())
+			return 2
+		}
+		src = b
+	}
+	if err := state.LoadChunk(name, src, mode); err != nil {
 		// error message is on top of the stack
 		state.Push(nil)
 		state.Push(err.Error())
@@ -291,9 +310,10 @@ func basePairs(state *lua.State) int {
 //
 // See https://www.lua.org/manual/5.3/manual.html#pdf-pcall
 func basePCall(state *lua.State) int {
-	if err := state.PCall(state.Top()-1, -1, 0); err != nil {
+	state.CheckAny(1)
+	if err := state.PCall(state.Top()-1, lua.MultRets, 0); err != nil {
 		state.Push(false)
-		state.Push(err.Error())
+		state.PushError(err)
 		return 2
 	}
 	state.Push(true)
@@ -303,28 +323,21 @@ func basePCall(state *lua.State) int {
 
 // print(...)
 //
+// Converts each argument to a string following the same rules as tostring,
+// including __tostring and __name, and prints them separated by tabs.
+//
 // See https://www.lua.org/manual/5.3/manual.html#pdf-print
 func basePrint(state *lua.State) int {
-	var (
-		n = state.Top()
-		i = 1
-	)
-	state.GetGlobal("tostring")
-	for ; i <= n; i++ {
-		state.PushIndex(-1)
-		state.PushIndex(i)
-		state.Call(1, 1)
-		str, ok := state.TryString(-1)
-		if !ok {
-			panic(fmt.Errorf("'tostring' must return a string to 'print'"))
-		}
+	out := state.Output()
+	for i, n := 1, state.Top(); i <= n; i++ {
+		str := state.ToStringMeta(i)
 		if i > 1 {
-			fmt.Print("\t")
+			io.WriteString(out, "\t")
 		}
-		fmt.Print(str)
+		io.WriteString(out, str)
 		state.Pop()
 	}
-	fmt.Println()
+	io.WriteString(out, "\n")
 	return 0
 }
 
@@ -349,7 +362,7 @@ func baseRawEqual(state *lua.State) int {
 // See https://www.lua.org/manual/5.3/manual.html#pdf-rawlen
 func baseRawLen(state *lua.State) int {
 	if t := state.TypeAt(1); t != lua.StringType && t != lua.TableType {
-		panic(fmt.Errorf("table or string expected"))
+		state.Raise(lua.MsgTableOrString)
 	}
 	state.Push(state.RawLen(1))
 	return 1
@@ -412,7 +425,7 @@ func baseSelect(state *lua.State) int {
 		sel = top + sel
 	}
 	if sel < 1 {
-		panic(fmt.Errorf("bad argument to 'select' (index out of range)"))
+		state.Raise(lua.MsgSelectIndex)
 	}
 	return int(top - sel)
 }
@@ -422,11 +435,11 @@ func baseSelect(state *lua.State) int {
 // See https://www.lua.org/manual/5.3/manual.html#pdf-setmetatable
 func baseSetMetaTable(state *lua.State) int {
 	if t := state.TypeAt(2); t != lua.NilType && t != lua.TableType {
-		state.Errorf("nil or table expected")
+		state.Raise(lua.MsgNilOrTable)
 	}
 	state.CheckType(1, lua.TableType)
 	if state.GetMetaField(1, "__metatable") != lua.NoneType {
-		state.Errorf("cannot change a protected metatable")
+		state.Raise(lua.MsgProtectedMeta)
 	}
 	state.SetTop(2)
 	state.SetMetaTableAt(1)
@@ -465,20 +478,52 @@ func baseToNumber(state *lua.State) int {
 		}
 		return 1
 	}
-	str := strings.ToLower(strings.TrimSpace(state.CheckString(1)))
 	base := state.CheckInt(2)
+	state.CheckType(1, lua.StringType) // no numbers as strings
 	if base < 2 || base > 36 {
-		panic(fmt.Errorf("bad argument to 'tonumber' (base out of range)"))
+		state.Raise(lua.MsgToNumberBase)
 	}
-	num, err := strconv.ParseInt(str, int(base), 64)
-	if err != nil {
+	if n, ok := strToInt(state.ToString(1), base); ok {
+		state.Push(n)
+	} else {
 		state.Push(nil)
-		return 1
 	}
-	state.Push(num)
 	return 1
 }
 
+// strToInt converts the numeral s in base to an integer, which wraps around
+// on overflow as in Lua.
+func strToInt(s string, base int64) (int64, bool) {
+	s = strings.TrimSpace(s)
+	neg := strings.HasPrefix(s, "-")
+	if neg || strings.HasPrefix(s, "+") {
+		s = s[1:]
+	}
+	if s == "" {
+		return 0, false
+	}
+	var n uint64
+	for _, c := range strings.ToUpper(s) {
+		var digit int64
+		switch {
+		case c >= '0' && c <= '9':
+			digit = int64(c - '0')
+		case c >= 'A' && c <= 'Z':
+			digit = int64(c-'A') + 10
+		default:
+			return 0, false
+		}
+		if digit >= base {
+			return 0, false
+		}
+		n = n*uint64(base) + uint64(digit)
+	}
+	if neg {
+		n = -n
+	}
+	return int64(n), true
+}
+
 // tostring(v)
 //
 // Receives a value of any type and converts it to a string in a human-readable
@@ -540,11 +585,34 @@ func baseType(state *lua.State) int {
 //
 //  "isrunning": returns a boolean that tells whether the collector is running (i.e., not stopped).
 //
+// As an extension, "count" also returns a table reporting the coroutines of the state:
+// the number of suspended coroutines (field suspended), of pooled goroutines ready to run
+// new coroutines (idle, see lua.WithThreadPool), and of coroutines started on a pooled
+// goroutine (hits) or on a new one (misses).
+//
 // See https://www.lua.org/manual/5.3/manual.html#pdf-collectgarbage
 func baseGC(state *lua.State) int {
 	// TODO: finish him
 	switch opt := state.OptString(1, "collect"); opt {
-	// case "count":
+	case "count": // the Go heap, which holds the memory of the state
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		state.Push(float64(stats.HeapAlloc) / 1024)
+		threads := state.Stats().Threads
+		state.NewTableSize(0, 4)
+		for _, field := range []struct {
+			name  string
+			value int
+		}{
+			{"suspended", threads.Suspended},
+			{"idle", threads.Idle},
+			{"hits", threads.Hits},
+			{"misses", threads.Misses},
+		} {
+			state.Push(field.value)
+			state.SetField(-2, field.name)
+		}
+		return 2
 	// case "stop":
 	// case "restart":
 	// case "setpause":
@@ -562,10 +630,24 @@ func baseGC(state *lua.State) int {
 	return 1
 }
 
+// xpcall(f, msgh [, arg1, ...])
+//
+// This function is similar to pcall, except that it sets a new message handler
+// msgh. The handler is called with the error object where the error happens,
+// before the stack unwinds, so that it can gather information such as a
+// traceback, and its result is returned as the error object.
+//
 // See https://www.lua.org/manual/5.3/manual.html#pdf-xpcall
 func baseXpcall(state *lua.State) int {
-	unimplemented("base: xpcall")
-	return 0
+	n := state.Top()
+	state.CheckType(2, lua.FuncType) // check error function
+	state.Push(true)                 // first result if no errors
+	state.PushIndex(1)               // function
+	state.Rotate(3, 2)               // move them below function's arguments
+	if err := state.PCall(n-2, lua.MultRets, 2); err != nil {
+		state.Push(false)
+		state.PushError(err)
+		return 2
+	}
+	return state.Top() - 2
 }
-
-func unimplemented(msg string) { panic(fmt.Errorf(msg)) }
@@ -0,0 +1,128 @@
+package std
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Azure/golua/lua"
+)
+
+func TestBase(t *testing.T) {
+	state := lua.NewState()
+	defer state.Close()
+	Open(state)
+
+	var traceback string
+	state.Register("handler", func(state *lua.State) int {
+		traceback = state.Traceback("")
+		state.Push("handled: " + state.ToString(1))
+		return 1
+	})
+
+	for _, test := range []struct {
+		fn   string
+		args []interface{}
+		want string
+	}{
+		{"tonumber", []interface{}{"ff", int64(16)}, "[255]"},
+		{"tonumber", []interface{}{" -z ", int64(36)}, "[-35]"},
+		{"tonumber", []interface{}{"ffffffffffffffff", int64(16)}, "[-1]"},
+		{"tonumber", []interface{}{"8", int64(8)}, "[nil]"},
+		{"tonumber", []interface{}{"0x10"}, "[16]"},
+		{"select", []interface{}{int64(-1), "a", "b"}, "[b]"},
+		{"select", []interface{}{"#", "a", "b"}, "[2]"},
+		{"rawequal", []interface{}{"a", "a"}, "[boolean]"},
+	} {
+		if got := fmt.Sprint(call(t, state, "", test.fn, test.args...)); got != test.want {
+			t.Errorf("%s%v = %s; want %s", test.fn, test.args, got, test.want)
+		}
+	}
+
+	// pcall returns error objects that are not strings.
+	state.GetGlobal("pcall")
+	state.GetGlobal("error")
+	state.NewTable()
+	state.Call(2, 2)
+	if state.ToBool(-2) || state.TypeAt(-1) != lua.TableType {
+		t.Errorf("pcall(error, {}) = %v, %v; want false, table", state.ToBool(-2), state.TypeAt(-1))
+	}
+	state.PopN(2)
+
+	// The message handler of xpcall runs before the stack unwinds.
+	state.GetGlobal("xpcall")
+	state.GetGlobal("error")
+	state.GetGlobal("handler")
+	state.Push("boom")
+	state.Push(0)
+	state.Call(4, 2)
+	if got := state.ToString(-1); got != "handled: boom" {
+		t.Errorf("xpcall(error, handler, 'boom', 0) = %s; want handled: boom", got)
+	}
+	if !strings.Contains(traceback, "baseError") {
+		t.Errorf("handler traceback misses the error function:\n%s", traceback)
+	}
+	state.PopN(2)
+}
+
+func TestIPairs(t *testing.T) {
+	state := lua.NewState()
+	defer state.Close()
+	Open(state)
+
+	ipairs := func(v lua.Value) (s []string) {
+		state.GetGlobal("ipairs")
+		state.Push(v)
+		state.Call(1, 3)
+		for i := 0; i < 10; i++ {
+			state.PushIndex(-3)
+			state.PushIndex(-3)
+			state.PushIndex(-3)
+			if state.Call(2, 2); state.IsNil(-2) {
+				break
+			}
+			s = append(s, state.ToString(-2)+"="+state.ToString(-1))
+			state.Pop() // value
+			state.Replace(-2)
+		}
+		state.SetTop(0)
+		return s
+	}
+
+	// {10, 20, nil, 40} with __index = {[3] = 30, [5] = 50}
+	state.NewTable()
+	for _, i := range []int{1, 2, 4} {
+		state.Push(i * 10)
+		state.RawSetIndex(-2, i)
+	}
+	state.NewTable()
+	state.NewTable()
+	for _, i := range []int{3, 5} {
+		state.Push(i * 10)
+		state.RawSetIndex(-2, i)
+	}
+	state.SetField(-2, "__index")
+	state.SetMetaTableAt(-2)
+	partial := state.Pop()
+	if got, want := fmt.Sprint(ipairs(partial)), "[1=10 2=20 3=30 4=40 5=50]"; got != want {
+		t.Errorf("ipairs(partial) = %s; want %s", got, want)
+	}
+
+	// proxy of three values, with an __index function
+	state.NewTable()
+	state.NewTable()
+	state.Push(func(state *lua.State) int {
+		if i := state.CheckInt(2); i <= 3 {
+			state.Push(i * i)
+		} else {
+			state.Push(nil)
+		}
+		return 1
+	})
+	state.SetField(-2, "__index")
+	state.SetMetaTableAt(-2)
+	proxy := state.Pop()
+	if got, want := fmt.Sprint(ipairs(proxy)), "[1=1 2=4 3=9]"; got != want {
+		t.Errorf("ipairs(proxy) = %s; want %s", got, want)
+	}
+}
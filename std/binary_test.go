@@ -0,0 +1,175 @@
+package std
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/Azure/golua/lua"
+)
+
+// blob is binary data with embedded zeros and bytes that are not valid UTF-8.
+const blob = "a\x00b\x00\xff\xfe\nc"
+
+// call calls lib.fn with args and returns its results converted to strings
+// and numbers, or the error raised.
+func call(t *testing.T, state *lua.State, lib, fn string, args ...interface{}) []interface{} {
+	t.Helper()
+	top := state.Top()
+	defer state.SetTop(top)
+	if lib == "" {
+		state.GetGlobal(fn)
+	} else {
+		state.GetGlobal(lib)
+		state.GetField(-1, fn)
+		state.Remove(-2)
+	}
+	for _, arg := range args {
+		state.Push(arg)
+	}
+	if err := state.PCall(len(args), lua.MultRets, 0); err != nil {
+		t.Fatalf("%s.%s: %v", lib, fn, err)
+	}
+	return results(state, top+1)
+}
+
+// require returns the library name, as require does.
+func require(t *testing.T, state *lua.State, name string) lua.Value {
+	t.Helper()
+	state.GetGlobal("require")
+	state.Push(name)
+	if err := state.PCall(1, 1, 0); err != nil {
+		t.Fatalf("require %q: %v", name, err)
+	}
+	return state.Pop()
+}
+
+// results returns the values of the stack from index on as call does.
+func results(state *lua.State, index int) []interface{} {
+	var res []interface{}
+	for i := index; i <= state.Top(); i++ {
+		switch state.TypeAt(i) {
+		case lua.StringType:
+			res = append(res, state.ToString(i))
+		case lua.NumberType:
+			res = append(res, state.ToInt(i))
+		default:
+			res = append(res, state.TypeAt(i).String())
+		}
+	}
+	return res
+}
+
+func TestBinaryStrings(t *testing.T) {
+	state := lua.NewState()
+	defer state.Close()
+	Open(state)
+
+	var tests = []struct {
+		lib, fn string
+		args    []interface{}
+		want    []interface{}
+	}{
+		{"string", "len", []interface{}{blob}, []interface{}{int64(8)}},
+		{"string", "sub", []interface{}{blob, 2, 5}, []interface{}{"\x00b\x00\xff"}},
+		{"string", "upper", []interface{}{blob}, []interface{}{"A\x00B\x00\xff\xfe\nC"}},
+		{"string", "lower", []interface{}{"A\x00\xc9"}, []interface{}{"a\x00\xc9"}},
+		{"string", "reverse", []interface{}{blob}, []interface{}{"c\n\xfe\xff\x00b\x00a"}},
+		{"string", "rep", []interface{}{"\x00", 3, "\xff"}, []interface{}{"\x00\xff\x00\xff\x00"}},
+		{"string", "byte", []interface{}{blob, 4, 5}, []interface{}{int64(0), int64(255)}},
+		{"string", "char", []interface{}{0, 255, 0}, []interface{}{"\x00\xff\x00"}},
+		{"string", "find", []interface{}{blob, "\x00", 3, true}, []interface{}{int64(4), int64(4)}},
+		{"string", "find", []interface{}{blob, "\xff\xfe"}, []interface{}{int64(5), int64(6)}},
+		{"string", "find", []interface{}{blob, "%z"}, []interface{}{int64(2), int64(2)}},
+		{"string", "find", []interface{}{blob, "%s"}, []interface{}{int64(7), int64(7)}},
+		{"string", "gsub", []interface{}{blob, "%z", "0"}, []interface{}{"a0b0\xff\xfe\nc", int64(2)}},
+		{"string", "gsub", []interface{}{blob, "\xff", "\x00"}, []interface{}{"a\x00b\x00\x00\xfe\nc", int64(1)}},
+		{"string", "format", []interface{}{"%s", blob}, []interface{}{blob}},
+		{"string", "format", []interface{}{"%q", "\x00\x001"}, []interface{}{`"\0\0001"`}},
+		{"string", "format", []interface{}{"\x00%d", 1}, []interface{}{"\x001"}},
+		{"", "tostring", []interface{}{blob}, []interface{}{blob}},
+		{"", "tonumber", []interface{}{"1\x00"}, []interface{}{"nil"}},
+		{"utf8", "len", []interface{}{"\x00\x00"}, []interface{}{int64(2)}},
+	}
+	for _, test := range tests {
+		got := call(t, state, test.lib, test.fn, test.args...)
+		if len(got) != len(test.want) {
+			t.Errorf("%s.%s%q = %q; want %q", test.lib, test.fn, test.args, got, test.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("%s.%s%q = %q; want %q", test.lib, test.fn, test.args, got, test.want)
+				break
+			}
+		}
+	}
+
+	// Keys differing after a zero are distinct.
+	state.NewTable()
+	state.Push(1)
+	state.SetField(-2, "a")
+	state.Push(2)
+	state.SetField(-2, "a\x00b")
+	if state.GetField(-1, "a"); state.ToInt(-1) != 1 {
+		t.Errorf("t['a'] = %d; want 1", state.ToInt(-1))
+	}
+	if state.GetField(-2, "a\x00b"); state.ToInt(-1) != 2 {
+		t.Errorf("t['a\\0b'] = %d; want 2", state.ToInt(-1))
+	}
+	state.SetTop(0)
+
+	state.Push(blob)
+	state.Push(1)
+	state.Push("\x00")
+	state.Concat(3)
+	if got := state.ToString(-1); got != blob+"1\x00" {
+		t.Errorf("concat = %q; want %q", got, blob+"1\x00")
+	}
+	state.Pop()
+}
+
+func TestBinaryFiles(t *testing.T) {
+	state := lua.NewState()
+	defer state.Close()
+	Open(state)
+
+	name := filepath.Join(t.TempDir(), "blob")
+	open := func(mode string) lua.Value {
+		state.GetGlobal("io")
+		state.GetField(-1, "open")
+		state.Push(name)
+		state.Push(mode)
+		state.Call(2, 1)
+		file := state.CheckAny(-1)
+		state.SetTop(0)
+		return file
+	}
+	invoke := func(file lua.Value, fn string, args ...interface{}) []interface{} {
+		state.Push(file)
+		state.GetField(-1, fn)
+		state.Remove(-2)
+		state.Push(file)
+		for _, arg := range args {
+			state.Push(arg)
+		}
+		state.Call(1+len(args), 1)
+		defer state.SetTop(0)
+		if state.TypeAt(-1) == lua.StringType {
+			return []interface{}{state.ToString(-1)}
+		}
+		return []interface{}{state.TypeAt(-1).String()}
+	}
+
+	file := open("wb")
+	invoke(file, "write", blob, "\x00")
+	invoke(file, "close")
+
+	file = open("rb")
+	if got := invoke(file, "read", "l")[0]; got != "a\x00b\x00\xff\xfe" {
+		t.Errorf("read('l') = %q", got)
+	}
+	if got := invoke(file, "read", "a")[0]; got != "c\x00" {
+		t.Errorf("read('a') = %q", got)
+	}
+	invoke(file, "close")
+}
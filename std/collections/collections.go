@@ -0,0 +1,366 @@
+package collections
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/Azure/golua/lua"
+)
+
+const (
+	setTypeName = "collections.set"
+	mapTypeName = "collections.ordered_map"
+)
+
+//
+// Lua Extension Library -- collections
+//
+
+// Open opens the collections library. The library provides natively implemented
+// sets and insertion-ordered maps. Both iterate deterministically in insertion
+// order with pairs and report their size with the length operator #.
+//
+//	local collections = require "collections"
+//	local s = collections.set{"a", "b"}
+//	s:add("c")
+//	print(#s, s:contains("b")) --> 3 true
+//
+// The library is not opened by default; it is available through require "collections".
+func Open(state *lua.State) int {
+	// Create 'collections' table.
+	var collectionFuncs = map[string]lua.Func{
+		"set":         lua.Func(newSet),
+		"ordered_map": lua.Func(newOrderedMap),
+	}
+	state.NewTableSize(0, len(collectionFuncs))
+	state.SetFuncs(collectionFuncs, 0)
+	createSetMetaTable(state)
+	createMapMetaTable(state)
+
+	// Return 'collections' table.
+	return 1
+}
+
+// createSetMetaTable creates the metatable for sets.
+func createSetMetaTable(state *lua.State) {
+	var funcs = map[string]lua.Func{
+		"add":        lua.Func(setAdd),
+		"contains":   lua.Func(setContains),
+		"difference": lua.Func(setDifference),
+		"intersect":  lua.Func(setIntersect),
+		"remove":     lua.Func(setRemove),
+		"union":      lua.Func(setUnion),
+		"__len":      lua.Func(setLen),
+		"__pairs":    lua.Func(setPairs),
+		"__tostring": lua.Func(setToString),
+	}
+	state.NewMetaTable(setTypeName)
+	state.PushIndex(-1)           // push metatable
+	state.SetField(-2, "__index") // metatable.__index = metatable
+	state.SetFuncs(funcs, 0)
+	state.Pop()
+}
+
+// createMapMetaTable creates the metatable for ordered maps.
+func createMapMetaTable(state *lua.State) {
+	var funcs = map[string]lua.Func{
+		"delete":     lua.Func(mapDelete),
+		"get":        lua.Func(mapGet),
+		"has":        lua.Func(mapHas),
+		"set":        lua.Func(mapSet),
+		"__len":      lua.Func(mapLen),
+		"__pairs":    lua.Func(mapPairs),
+		"__tostring": lua.Func(mapToString),
+	}
+	state.NewMetaTable(mapTypeName)
+	state.PushIndex(-1)           // push metatable
+	state.SetField(-2, "__index") // metatable.__index = metatable
+	state.SetFuncs(funcs, 0)
+	state.Pop()
+}
+
+// collections.set ([list])
+//
+// Returns a new set holding the elements of the optional sequence list.
+func newSet(state *lua.State) int {
+	set := newOrdered()
+	if !state.IsNoneOrNil(1) {
+		state.CheckType(1, lua.TableType)
+		for i, n := 1, state.RawLen(1); i <= n; i++ {
+			state.RawGetIndex(1, i)
+			set.put(state, state.Pop(), lua.Bool(true))
+		}
+	}
+	pushSet(state, set)
+	return 1
+}
+
+// set:add (value)
+//
+// Adds value to the set.
+func setAdd(state *lua.State) int {
+	toSet(state, 1).put(state, state.CheckAny(2), lua.Bool(true))
+	return 0
+}
+
+// set:remove (value)
+//
+// Removes value from the set and returns true if it was present.
+func setRemove(state *lua.State) int {
+	state.Push(toSet(state, 1).delete(state, state.CheckAny(2)))
+	return 1
+}
+
+// set:contains (value)
+//
+// Returns true if value is in the set.
+func setContains(state *lua.State) int {
+	_, ok := toSet(state, 1).get(state, state.CheckAny(2))
+	state.Push(ok)
+	return 1
+}
+
+// set:union (other)
+//
+// Returns a new set with the elements that are in either set.
+func setUnion(state *lua.State) int {
+	s1, s2 := toSet(state, 1), toSet(state, 2)
+	set := newOrdered()
+	for _, s := range []*ordered{s1, s2} {
+		for n := s.head; n != nil; n = n.next {
+			set.put(state, n.key, n.val)
+		}
+	}
+	pushSet(state, set)
+	return 1
+}
+
+// set:intersect (other)
+//
+// Returns a new set with the elements that are in both sets.
+func setIntersect(state *lua.State) int {
+	s1, s2 := toSet(state, 1), toSet(state, 2)
+	set := newOrdered()
+	for n := s1.head; n != nil; n = n.next {
+		if _, ok := s2.get(state, n.key); ok {
+			set.put(state, n.key, n.val)
+		}
+	}
+	pushSet(state, set)
+	return 1
+}
+
+// set:difference (other)
+//
+// Returns a new set with the elements of the set that are not in other.
+func setDifference(state *lua.State) int {
+	s1, s2 := toSet(state, 1), toSet(state, 2)
+	set := newOrdered()
+	for n := s1.head; n != nil; n = n.next {
+		if _, ok := s2.get(state, n.key); !ok {
+			set.put(state, n.key, n.val)
+		}
+	}
+	pushSet(state, set)
+	return 1
+}
+
+func setLen(state *lua.State) int {
+	state.Push(toSet(state, 1).size)
+	return 1
+}
+
+func setPairs(state *lua.State) int {
+	return pairs(state, toSet(state, 1))
+}
+
+func setToString(state *lua.State) int {
+	set := toSet(state, 1)
+	state.Push(fmt.Sprintf("set (%d): %p", set.size, set))
+	return 1
+}
+
+// collections.ordered_map ()
+//
+// Returns a new empty map that remembers the order in which keys were first
+// inserted.
+func newOrderedMap(state *lua.State) int {
+	state.Push(newOrdered())
+	state.SetMetaTable(mapTypeName)
+	return 1
+}
+
+// map:get (key)
+//
+// Returns the value associated with key, or nil.
+func mapGet(state *lua.State) int {
+	val, _ := toMap(state, 1).get(state, state.CheckAny(2))
+	state.Push(val)
+	return 1
+}
+
+// map:set (key, value)
+//
+// Associates value with key. A new key is placed after all existing keys;
+// updating an existing key keeps its position. Setting a key to nil removes it.
+func mapSet(state *lua.State) int {
+	m := toMap(state, 1)
+	key := state.CheckAny(2)
+	if state.IsNoneOrNil(3) {
+		m.delete(state, key)
+	} else {
+		m.put(state, key, state.CheckAny(3))
+	}
+	return 0
+}
+
+// map:delete (key)
+//
+// Removes key from the map and returns true if it was present.
+func mapDelete(state *lua.State) int {
+	state.Push(toMap(state, 1).delete(state, state.CheckAny(2)))
+	return 1
+}
+
+// map:has (key)
+//
+// Returns true if key is in the map.
+func mapHas(state *lua.State) int {
+	_, ok := toMap(state, 1).get(state, state.CheckAny(2))
+	state.Push(ok)
+	return 1
+}
+
+func mapLen(state *lua.State) int {
+	state.Push(toMap(state, 1).size)
+	return 1
+}
+
+func mapPairs(state *lua.State) int {
+	return pairs(state, toMap(state, 1))
+}
+
+func mapToString(state *lua.State) int {
+	m := toMap(state, 1)
+	state.Push(fmt.Sprintf("ordered_map (%d): %p", m.size, m))
+	return 1
+}
+
+func pushSet(state *lua.State, set *ordered) {
+	state.Push(set)
+	state.SetMetaTable(setTypeName)
+}
+
+func toSet(state *lua.State, index int) *ordered {
+	return state.CheckUserData(index, setTypeName).(*ordered)
+}
+
+func toMap(state *lua.State, index int) *ordered {
+	return state.CheckUserData(index, mapTypeName).(*ordered)
+}
+
+// pairs returns an iterator over the entries of o in insertion order. Entries
+// may be removed while iterating.
+func pairs(state *lua.State, o *ordered) int {
+	var next *node
+	started := false
+	state.Push(lua.Func(func(state *lua.State) int {
+		if !started {
+			next, started = o.head, true
+		}
+		for next != nil && next.dead {
+			next = next.next
+		}
+		if next == nil {
+			state.Push(nil)
+			return 1
+		}
+		state.Push(next.key)
+		state.Push(next.val)
+		next = next.next
+		return 2
+	}))
+	state.PushIndex(1)
+	state.Push(nil)
+	return 3
+}
+
+// node is an entry of an ordered collection.
+type node struct {
+	key, val   lua.Value
+	prev, next *node
+	dead       bool
+}
+
+// ordered is a hash map whose entries are also linked in insertion order.
+type ordered struct {
+	nodes      map[lua.Value]*node
+	head, tail *node
+	size       int
+}
+
+func newOrdered() *ordered {
+	return &ordered{nodes: make(map[lua.Value]*node)}
+}
+
+func (o *ordered) get(state *lua.State, key lua.Value) (lua.Value, bool) {
+	if n, ok := o.nodes[normKey(state, key)]; ok {
+		return n.val, true
+	}
+	return nil, false
+}
+
+func (o *ordered) put(state *lua.State, key, val lua.Value) {
+	key = normKey(state, key)
+	if n, ok := o.nodes[key]; ok {
+		n.val = val
+		return
+	}
+	n := &node{key: key, val: val, prev: o.tail}
+	if o.tail != nil {
+		o.tail.next = n
+	} else {
+		o.head = n
+	}
+	o.tail = n
+	o.nodes[key] = n
+	o.size++
+}
+
+// delete unlinks the entry for key. The removed node keeps its next pointer
+// so that iterators positioned on it can continue.
+func (o *ordered) delete(state *lua.State, key lua.Value) bool {
+	key = normKey(state, key)
+	n, ok := o.nodes[key]
+	if !ok {
+		return false
+	}
+	if n.prev != nil {
+		n.prev.next = n.next
+	} else {
+		o.head = n.next
+	}
+	if n.next != nil {
+		n.next.prev = n.prev
+	} else {
+		o.tail = n.prev
+	}
+	n.dead = true
+	delete(o.nodes, key)
+	o.size--
+	return true
+}
+
+// normKey converts float keys with an exact integer value to integers, so that
+// keys compare as they do in Lua tables, and rejects NaN keys.
+func normKey(state *lua.State, key lua.Value) lua.Value {
+	if f, ok := key.(lua.Float); ok {
+		if math.IsNaN(float64(f)) {
+			state.Errorf("key is NaN")
+		}
+		if i := lua.Int(f); lua.Float(i) == f {
+			return i
+		}
+	}
+	return key
+}
@@ -0,0 +1,107 @@
+package std
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Azure/golua/lua"
+)
+
+// entries returns the keys and values of obj in the order of its __pairs.
+func entries(t *testing.T, state *lua.State, obj lua.Value) (kvs []interface{}) {
+	t.Helper()
+	state.Push(obj)
+	state.GetField(-1, "__pairs")
+	state.Insert(-2)
+	state.Call(1, 3)
+	for {
+		state.PushIndex(-3)
+		state.PushIndex(-3)
+		state.PushIndex(-3)
+		state.Call(2, 2)
+		if state.IsNil(-2) {
+			state.SetTop(state.Top() - 5)
+			return kvs
+		}
+		kvs = append(kvs, results(state, state.Top()-1)...)
+		state.Replace(-3) // the control variable
+		state.Pop()
+	}
+}
+
+func TestCollections(t *testing.T) {
+	state := lua.NewState()
+	defer state.Close()
+	Open(state)
+	lib := require(t, state, "collections")
+
+	newSet := func(values ...interface{}) lua.Value {
+		state.Push(lib)
+		state.GetField(-1, "set")
+		state.Push(seq(state, values...))
+		state.Call(1, 1)
+		defer state.Pop()
+		return state.Pop()
+	}
+	s := newSet("b", "a", 1)
+	callMethod(t, state, s, "add", 1.0) // the same key as 1
+	callMethod(t, state, s, "add", "c")
+	if got := fmt.Sprint(callMethod(t, state, s, "__len"), entries(t, state, s)); got != "[4] [b boolean a boolean 1 boolean c boolean]" {
+		t.Errorf("set = %s", got)
+	}
+	contains := func(v interface{}) bool {
+		state.Push(s)
+		state.GetField(-1, "contains")
+		state.Insert(-2)
+		state.Push(v)
+		state.Call(2, 1)
+		defer state.Pop()
+		return state.ToBool(-1)
+	}
+	if !contains("a") || !contains(1.0) || contains("z") {
+		t.Errorf("contains('a'), contains(1.0), contains('z') = %t, %t, %t; want true, true, false", contains("a"), contains(1.0), contains("z"))
+	}
+
+	other := newSet("c", "d", "a")
+	var tests = []struct {
+		op   string
+		want string
+	}{
+		{"union", "[b a 1 c d]"},
+		{"intersect", "[a c]"},
+		{"difference", "[b 1]"},
+	}
+	for _, tt := range tests {
+		state.Push(s)
+		state.GetField(-1, tt.op)
+		state.Insert(-2)
+		state.Push(other)
+		state.Call(2, 1)
+		var keys []interface{}
+		for i, kv := range entries(t, state, state.Pop()) {
+			if i%2 == 0 {
+				keys = append(keys, kv)
+			}
+		}
+		if got := fmt.Sprint(keys); got != tt.want {
+			t.Errorf("set:%s(other) = %s; want %s", tt.op, got, tt.want)
+		}
+	}
+
+	// Ordered maps keep the first insertion order, also across updates.
+	state.Push(lib)
+	state.GetField(-1, "ordered_map")
+	state.Call(0, 1)
+	m := state.Pop()
+	state.Pop()
+	for _, kv := range [][]interface{}{{"z", 1}, {"a", 2}, {"m", 3}, {"z", 4}, {2.0, "two"}} {
+		callMethod(t, state, m, "set", kv...)
+	}
+	callMethod(t, state, m, "set", "a", nil)
+	if got := fmt.Sprint(entries(t, state, m)); got != "[z 4 m 3 2 two]" {
+		t.Errorf("ordered map = %s; want [z 4 m 3 2 two]", got)
+	}
+	if got := fmt.Sprint(callMethod(t, state, m, "get", 2), callMethod(t, state, m, "delete", "m"), callMethod(t, state, m, "__len")); got != "[two] [boolean] [2]" {
+		t.Errorf("get, delete and # = %s", got)
+	}
+}
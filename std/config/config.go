@@ -0,0 +1,122 @@
+package config
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/Azure/golua/lua"
+	"github.com/Azure/golua/lua/luajson"
+	"github.com/Azure/golua/pkg/toml"
+	"github.com/Azure/golua/pkg/yaml"
+)
+
+//
+// Lua Extension Library -- config
+//
+
+// Open opens the config library, which decodes configuration files written in
+// JSON, YAML or TOML into Lua tables:
+//
+//	local cfg = config.load("monsters.yaml")
+//	print(cfg.dragon.hp)
+//
+// All formats follow the typing rules of JSON decoding: mappings become tables
+// with string keys, sequences become arrays indexed from 1, integers become
+// Lua integers and other numbers floats, and null values are absent. YAML
+// scalars are resolved with the YAML 1.2 core schema, so yes and no are
+// strings; TOML dates and times are strings.
+//
+// The library is not opened by default; it is available through require "config".
+func Open(state *lua.State) int {
+	// Create 'config' table.
+	var configFuncs = map[string]lua.Func{
+		"decode": lua.Func(configDecode),
+		"load":   lua.Func(configLoad),
+	}
+	state.NewTableSize(0, len(configFuncs))
+	state.SetFuncs(configFuncs, 0)
+
+	// Return 'config' table.
+	return 1
+}
+
+// config.decode (text, format)
+//
+// Decodes text, in the given format ("json", "yaml" or "toml"), and returns
+// its value. In case of errors this function returns nil, plus a string
+// describing the error.
+func configDecode(state *lua.State) int {
+	text := state.CheckString(1)
+	format := checkFormat(state, 2, state.CheckString(2))
+	if err := decode(state, text, format); err != nil {
+		state.Push(nil)
+		state.Push(err.Error())
+		return 2
+	}
+	return 1
+}
+
+// config.load (filename [, format])
+//
+// Reads the file named filename and returns its decoded value. The format is
+// inferred from the file extension (.json, .yaml, .yml or .toml) unless given.
+// In case of errors this function returns nil, plus a string describing the
+// error.
+func configLoad(state *lua.State) int {
+	name := state.CheckString(1)
+	var format string
+	if state.IsNoneOrNil(2) {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(name)), ".")
+		if format == "yml" {
+			format = "yaml"
+		}
+		if !isFormat(format) {
+			state.ArgError(1, fmt.Sprintf("cannot infer the format of '%s'", name))
+		}
+	} else {
+		format = checkFormat(state, 2, state.CheckString(2))
+	}
+	text, err := state.ReadFile(name)
+	if err != nil {
+		return state.FileResult(err, name)
+	}
+	if err := decode(state, string(text), format); err != nil {
+		state.Push(nil)
+		state.Push(fmt.Sprintf("%s: %v", name, err))
+		return 2
+	}
+	return 1
+}
+
+// decode decodes text in format and pushes its value.
+func decode(state *lua.State, text, format string) error {
+	switch format {
+	case "json":
+		return luajson.Decode(state, []byte(text))
+	case "yaml":
+		v, err := yaml.Decode(text)
+		if err != nil {
+			return err
+		}
+		luajson.Push(state, v)
+	case "toml":
+		v, err := toml.Decode(text)
+		if err != nil {
+			return err
+		}
+		luajson.Push(state, v)
+	}
+	return nil
+}
+
+func isFormat(format string) bool {
+	return format == "json" || format == "yaml" || format == "toml"
+}
+
+func checkFormat(state *lua.State, arg int, format string) string {
+	if !isFormat(format) {
+		state.ArgError(arg, fmt.Sprintf("invalid format '%s'", format))
+	}
+	return format
+}
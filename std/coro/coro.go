@@ -1,7 +1,7 @@
 package coro
 
 import (
-	"fmt"
+	"runtime"
 
 	"github.com/Azure/golua/lua"
 )
@@ -10,9 +10,20 @@ import (
 // Lua Standard Library -- coroutine
 //
 
+// Open opens the coroutine library. Coroutines run on goroutines of their
+// own (see lua.State.NewThread), so they can yield from anywhere, including
+// from Go functions they call, such as pcall or the callbacks of a host.
+//
+// Besides the functions of Lua 5.3, the library has coroutine.close from
+// Lua 5.4. Unlike in Lua, a coroutine of coroutine.create suspended in a
+// yield is not collected when it becomes unreachable, since its goroutine is
+// blocked: scripts of long-lived states should close the coroutines they stop
+// resuming, or they stay in memory until the state is closed. The coroutines
+// of coroutine.wrap are closed once their function is collected.
 func Open(state *lua.State) int {
 	// Create 'coroutine' table.
 	var coroutineFuncs = map[string]lua.Func{
+		"close":       lua.Func(coroutineClose),
 		"create":      lua.Func(coroutineCreate),
 		"resume":      lua.Func(coroutineResume),
 		"running":     lua.Func(coroutineRunning),
@@ -21,46 +32,168 @@ func Open(state *lua.State) int {
 		"yield":       lua.Func(coroutineYield),
 		"isyieldable": lua.Func(coroutineIsYieldable),
 	}
-	state.NewTableSize(0, 7)
+	state.NewTableSize(0, 8)
 	state.SetFuncs(coroutineFuncs, 0)
 
 	// Return 'coroutine' table.
 	return 1
 }
 
+// coroutine.close (co)
+//
+// Closes coroutine co, that is, puts the coroutine in a dead state, unwinding
+// it if it is suspended in a yield. The given coroutine must be dead or
+// suspended. Returns true, or false plus the error object if the coroutine
+// died in error.
+//
+// See https://www.lua.org/manual/5.4/manual.html#pdf-coroutine.close
+func coroutineClose(state *lua.State) int {
+	if err := state.CloseThread(getco(state)); err != nil {
+		state.Push(false)
+		state.PushError(err)
+		return 2
+	}
+	state.Push(true)
+	return 1
+}
+
+// coroutine.create (f)
+//
+// Creates a new coroutine, with body f. f must be a function. Returns this new
+// coroutine, an object with type "thread".
+//
+// See https://www.lua.org/manual/5.3/manual.html#pdf-coroutine.create
 func coroutineCreate(state *lua.State) int {
-	unimplemented("coroutine")
-	return 0
+	state.CheckType(1, lua.FuncType)
+	co := state.NewThread()
+	state.PushIndex(1) // move function to top
+	state.XMove(co, 1) // move function from state to co
+	return 1
 }
 
+// coroutine.resume (co [, val1, ···])
+//
+// Starts or continues the execution of coroutine co. The first time you resume
+// a coroutine, it starts running its body. The values val1, ... are passed as
+// the arguments to the body function. If the coroutine has yielded, resume
+// restarts it; the values val1, ... are passed as the results from the yield.
+//
+// If the coroutine runs without any errors, resume returns true plus any values
+// passed to yield (when the coroutine yields) or any values returned by the
+// body function (when the coroutine terminates). If there is any error, resume
+// returns false plus the error object.
+//
+// See https://www.lua.org/manual/5.3/manual.html#pdf-coroutine.resume
 func coroutineResume(state *lua.State) int {
-	unimplemented("coroutine")
-	return 0
+	co := getco(state)
+	rets, err := state.Resume(co, state.Top()-1)
+	if err != nil {
+		state.Push(false)
+		state.PushError(err)
+		return 2
+	}
+	state.Push(true)
+	state.Insert(-(rets + 1))
+	return rets + 1
 }
 
+// coroutine.running ()
+//
+// Returns the running coroutine plus a boolean, true when the running coroutine
+// is the main one.
+//
+// See https://www.lua.org/manual/5.3/manual.html#pdf-coroutine.running
 func coroutineRunning(state *lua.State) int {
-	unimplemented("coroutine")
-	return 0
+	state.Push(state.PushThread())
+	return 2
 }
 
+// coroutine.status (co)
+//
+// Returns the status of coroutine co, as a string: "running", if the coroutine
+// is running (that is, it called status); "suspended", if the coroutine is
+// suspended in a call to yield, or if it has not started running yet; "normal"
+// if the coroutine is active but not running (that is, it has resumed another
+// coroutine); and "dead" if the coroutine has finished its body function, or if
+// it has stopped with an error.
+//
+// See https://www.lua.org/manual/5.3/manual.html#pdf-coroutine.status
 func coroutineStatus(state *lua.State) int {
-	unimplemented("coroutine")
-	return 0
+	state.Push(state.CoStatus(getco(state)))
+	return 1
 }
 
+// coroutine.wrap (f)
+//
+// Creates a new coroutine, with body f. f must be a function. Returns a function
+// that resumes the coroutine each time it is called. Any arguments passed to the
+// function behave as the extra arguments to resume. Returns the same values
+// returned by resume, except the first boolean. In case of error, propagates
+// the error.
+//
+// Since the function is the only reference to its coroutine, the coroutine
+// is closed once the function is collected, so that generators abandoned in
+// the middle of an iteration do not keep their goroutines.
+//
+// See https://www.lua.org/manual/5.3/manual.html#pdf-coroutine.wrap
 func coroutineWrap(state *lua.State) int {
-	unimplemented("coroutine")
-	return 0
+	coroutineCreate(state)
+	ref := &wrapRef{state.ToThread(-1)}
+	runtime.SetFinalizer(ref, func(ref *wrapRef) {
+		state.Interrupt(ref.close)
+	})
+	state.Push(ref)
+	state.PushClosure(coroutineAuxWrap, 2)
+	return 1
+}
+
+// wrapRef is held by a function returned by wrap, to close its coroutine once
+// the function is collected.
+type wrapRef struct{ co *lua.State }
+
+// close closes the coroutine if it is suspended; it is called from the thread
+// running when the function was collected.
+func (ref *wrapRef) close(thread *lua.State) {
+	if thread.CoStatus(ref.co) == "suspended" {
+		thread.CloseThread(ref.co)
+	}
+}
+
+// coroutineAuxWrap resumes the coroutine of a function returned by wrap.
+func coroutineAuxWrap(state *lua.State) int {
+	rets, err := state.Resume(state.ToThread(lua.UpValueIndex(1)), state.Top())
+	if err != nil {
+		panic(err)
+	}
+	return rets
 }
 
+// coroutine.yield (···)
+//
+// Suspends the execution of the calling coroutine. Any arguments to yield are
+// passed as extra results to resume.
+//
+// See https://www.lua.org/manual/5.3/manual.html#pdf-coroutine.yield
 func coroutineYield(state *lua.State) int {
-	unimplemented("coroutine")
-	return 0
+	return state.Yield(state.Top())
 }
 
+// coroutine.isyieldable ()
+//
+// Returns true when the running coroutine can yield. A running coroutine is
+// yieldable if it is not the main thread.
+//
+// See https://www.lua.org/manual/5.3/manual.html#pdf-coroutine.isyieldable
 func coroutineIsYieldable(state *lua.State) int {
-	unimplemented("coroutine")
-	return 0
+	state.Push(state.IsYieldable())
+	return 1
 }
 
-func unimplemented(msg string) { panic(fmt.Errorf(msg)) }
+// getco returns the coroutine argument #1.
+func getco(state *lua.State) *lua.State {
+	co := state.ToThread(1)
+	if co == nil {
+		state.ArgError(1, "coroutine expected")
+	}
+	return co
+}
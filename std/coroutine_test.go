@@ -0,0 +1,143 @@
+package std
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+
+	"github.com/Azure/golua/lua"
+	"github.com/Azure/golua/lua/binary"
+	"github.com/Azure/golua/lua/vm"
+)
+
+func TestCoroutine(t *testing.T) {
+	state := lua.NewState()
+	defer state.Close()
+	Open(state)
+
+	// The body yields through pcall, a Go function.
+	body := func(state *lua.State) int {
+		state.GetGlobal("pcall")
+		state.GetGlobal("coroutine")
+		state.GetField(-1, "yield")
+		state.Remove(-2)
+		state.Push(state.IsYieldable())
+		state.Call(2, 2) // pcall(coroutine.yield, yieldable)
+		return 2
+	}
+	state.GetGlobal("coroutine")
+	state.GetField(-1, "wrap")
+	state.Push(lua.Func(body))
+	state.Call(1, 1)
+	state.SetGlobal("gen")
+	state.Pop()
+
+	if got := fmt.Sprint(call(t, state, "", "gen")); got != "[boolean]" {
+		t.Errorf("first gen() = %s; want [boolean]", got)
+	}
+	if got := fmt.Sprint(call(t, state, "", "gen", "done")); got != "[boolean done]" {
+		t.Errorf("second gen() = %s; want [boolean done]", got)
+	}
+	state.GetGlobal("gen")
+	if err := state.PCall(0, 0, 0); err == nil || err.Error() != "cannot resume dead coroutine" {
+		t.Errorf("third gen(): %v", err)
+	}
+
+	if got := fmt.Sprint(call(t, state, "coroutine", "isyieldable")); got != "[boolean]" {
+		t.Errorf("coroutine.isyieldable() = %s", got)
+	}
+	if got := fmt.Sprint(call(t, state, "coroutine", "running")); got != "[thread boolean]" {
+		t.Errorf("coroutine.running() = %s", got)
+	}
+}
+
+func TestCoroutineStats(t *testing.T) {
+	state := lua.NewState(lua.WithThreadPool(4))
+	defer state.Close()
+	Open(state)
+
+	state.GetGlobal("coroutine")
+	state.GetField(-1, "wrap")
+	state.Push(lua.Func(func(state *lua.State) int { return 0 }))
+	state.Call(1, 1)
+	state.Call(0, 0) // the goroutine goes back to the pool
+	state.Pop()
+
+	state.GetGlobal("collectgarbage")
+	state.Push("count")
+	state.Call(1, 2)
+	if state.TypeAt(-2) != lua.NumberType || state.TypeAt(-1) != lua.TableType {
+		t.Fatalf("collectgarbage('count') = %v, %v; want number, table", state.TypeAt(-2), state.TypeAt(-1))
+	}
+	for field, want := range map[string]int64{"suspended": 0, "idle": 1, "hits": 0, "misses": 1} {
+		state.GetField(-1, field)
+		if got := state.ToInt(-1); got != want {
+			t.Errorf("collectgarbage('count').%s = %v; want %d", field, state.ToString(-1), want)
+		}
+		state.Pop()
+	}
+}
+
+func TestCoroutineErrorObjects(t *testing.T) {
+	state := lua.NewState()
+	defer state.Close()
+	Open(state)
+
+	body := lua.Func(func(state *lua.State) int {
+		state.GetGlobal("error")
+		state.NewTable()
+		state.Call(1, 0) // error({})
+		return 0
+	})
+	for _, fn := range []string{"resume", "close"} {
+		state.GetGlobal("coroutine")
+		state.GetField(-1, "create")
+		state.Push(body)
+		state.Call(1, 1)
+		state.GetField(1, "resume")
+		state.PushIndex(2)
+		state.Call(1, 2)
+		if fn == "close" {
+			state.SetTop(2)
+			state.GetField(1, "close")
+			state.PushIndex(2)
+			state.Call(1, 2)
+		}
+		if state.ToBool(-2) || state.TypeAt(-1) != lua.TableType {
+			t.Errorf("coroutine.%s = %v, %v; want false, table", fn, state.ToBool(-2), state.TypeAt(-1))
+		}
+		state.SetTop(0)
+	}
+}
+
+func TestCoroutineWrapCollected(t *testing.T) {
+	state := lua.NewState()
+	defer state.Close()
+	Open(state)
+
+	// return
+	proto := binary.Prototype{
+		Source: "@gc.lua",
+		Vararg: 1,
+		Stack:  2,
+		Code:   []uint32{uint32(vm.ABC(vm.RETURN, 0, 1, 0))},
+	}
+	chunk := binary.Dump(&proto, false)
+
+	state.GetGlobal("coroutine")
+	state.GetField(-1, "wrap")
+	state.Push(lua.Func(func(state *lua.State) int { return state.Yield(0) }))
+	state.Call(1, 1)
+	state.Call(0, 0) // suspended in the yield
+	state.SetTop(0)
+	for i := 0; i < 10 && state.Stats().Threads.Suspended > 0; i++ {
+		runtime.GC()
+		// The coroutine is closed at an instruction boundary.
+		if err := state.ExecChunk("gc.lua", chunk, lua.BinaryMode); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := state.Stats().Threads.Suspended; got != 0 {
+		t.Errorf("%d suspended coroutines after their wrap function was collected", got)
+	}
+}
@@ -0,0 +1,339 @@
+package csv
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Azure/golua/lua"
+)
+
+const writerTypeName = "csv.writer"
+
+// chunkSize is the number of bytes requested from readers at a time.
+const chunkSize = 32 * 1024
+
+//
+// Lua Extension Library -- csv
+//
+
+// Open opens the csv library, which reads and writes comma-separated values
+// in the format of RFC 4180. Files are streamed: csv.open returns an iterator
+// reading one record at a time, and writers buffer a bounded amount of output,
+// so files of any size are processed in constant memory.
+//
+//	for row in csv.open(io.open("items.csv"), {header = true}) do
+//	    print(row.name, row.price)
+//	end
+//
+//	local out = csv.writer(io.open("out.csv", "w"))
+//	out:write({"name", "note"})
+//	out:write({"sword", 'says "hi", twice'})
+//	out:flush()
+//
+// Readers are files, or other objects with a read method called as
+// file:read(n), or functions returning successive chunks of the data and nil
+// or the empty string at the end. Writers are files, or other objects with a
+// write method called as file:write(s), or functions called with successive
+// chunks of the output.
+//
+// The library is not opened by default; it is available through require "csv".
+func Open(state *lua.State) int {
+	// Create 'csv' table.
+	var csvFuncs = map[string]lua.Func{
+		"open":   lua.Func(csvOpen),
+		"writer": lua.Func(csvWriter),
+	}
+	state.NewTableSize(0, len(csvFuncs))
+	state.SetFuncs(csvFuncs, 0)
+	createWriterMetaTable(state)
+
+	// Return 'csv' table.
+	return 1
+}
+
+// createWriterMetaTable creates the metatable for writers.
+func createWriterMetaTable(state *lua.State) {
+	var funcs = map[string]lua.Func{
+		"flush":      lua.Func(writerFlush),
+		"write":      lua.Func(writerWrite),
+		"__tostring": lua.Func(writerToString),
+	}
+	state.NewMetaTable(writerTypeName)
+	state.PushIndex(-1)           // push metatable
+	state.SetField(-2, "__index") // metatable.__index = metatable
+	state.SetFuncs(funcs, 0)
+	state.Pop()
+}
+
+// options are the options of csv.open and csv.writer.
+type options struct {
+	sep     rune
+	comment rune
+	header  bool
+	trim    bool
+	crlf    bool
+}
+
+// checkOptions checks the options table at arg, whose fields are sep (the
+// field separator, "," by default), and for readers comment (a character
+// starting comment lines), header (whether the first record names the
+// fields), and trim (whether leading spaces of fields are ignored), and for
+// writers crlf (whether lines end with \r\n).
+func checkOptions(state *lua.State, arg int) (opts options) {
+	opts.sep = ','
+	if state.IsNoneOrNil(arg) {
+		return opts
+	}
+	state.CheckType(arg, lua.TableType)
+	char := func(field string, def rune) rune {
+		defer state.Pop()
+		if state.GetField(arg, field); state.IsNoneOrNil(-1) {
+			return def
+		}
+		s := []rune(state.ToString(-1))
+		if len(s) != 1 || s[0] == '"' || s[0] == '\r' || s[0] == '\n' {
+			state.ArgError(arg, fmt.Sprintf("invalid %s '%s'", field, string(s)))
+		}
+		return s[0]
+	}
+	flag := func(field string) bool {
+		state.GetField(arg, field)
+		defer state.Pop()
+		return state.ToBool(-1)
+	}
+	opts.sep = char("sep", ',')
+	opts.comment = char("comment", 0)
+	opts.header = flag("header")
+	opts.trim = flag("trim")
+	opts.crlf = flag("crlf")
+	return opts
+}
+
+// csv.open (reader [, options])
+//
+// Returns an iterator function that, each time it is called, reads the next
+// record from reader and returns it as a sequence of strings, or as a table
+// keyed by field names if options.header is true. When the reader is a file
+// name, the file is opened and closed when the iterator reaches the end of the
+// data. A malformed record raises an error giving its line.
+func csvOpen(state *lua.State) int {
+	var (
+		opts = checkOptions(state, 2)
+		r    = &reader{obj: state.CheckAny(1)}
+		file lua.File
+	)
+	if name, ok := r.obj.(lua.String); ok {
+		f, err := state.FileSystem().OpenFile(string(name), os.O_RDONLY, 0)
+		if err != nil {
+			return state.FileResult(err, string(name))
+		}
+		file = f
+	}
+	var src io.Reader = r
+	if file != nil {
+		src = file
+	}
+	cr := csv.NewReader(src)
+	cr.Comma = opts.sep
+	cr.Comment = opts.comment
+	cr.TrimLeadingSpace = opts.trim
+	cr.FieldsPerRecord = -1
+	cr.ReuseRecord = true
+	var (
+		header []string
+		done   bool
+	)
+	next := func(state *lua.State) int {
+		if done {
+			return 0
+		}
+		r.state = state
+		record, err := cr.Read()
+		if err == nil && opts.header && header == nil {
+			header = append([]string(nil), record...)
+			record, err = cr.Read()
+		}
+		if err != nil {
+			done = true
+			if file != nil {
+				file.Close()
+			}
+			if err == io.EOF {
+				return 0
+			}
+			var perr *csv.ParseError
+			if errors.As(err, &perr) && perr.Err != nil {
+				return state.Errorf("csv: line %d: %v", perr.Line, perr.Err)
+			}
+			return state.Errorf("csv: %v", err)
+		}
+		if header == nil {
+			state.NewTableSize(len(record), 0)
+			for i, field := range record {
+				state.Push(field)
+				state.RawSetIndex(-2, i+1)
+			}
+			return 1
+		}
+		state.NewTableSize(0, len(header))
+		for i, field := range record {
+			state.Push(field)
+			if i < len(header) {
+				state.SetField(-2, header[i])
+			} else {
+				state.RawSetIndex(-2, i+1)
+			}
+		}
+		return 1
+	}
+	state.Push(lua.Func(next))
+	return 1
+}
+
+// reader reads the data of a Lua reader.
+type reader struct {
+	state *lua.State
+	obj   lua.Value
+	rest  string // unread part of the last chunk
+	eof   bool
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	if r.rest == "" && !r.eof {
+		r.rest, r.eof = r.chunk(len(p))
+	}
+	if r.rest == "" {
+		return 0, io.EOF
+	}
+	n := copy(p, r.rest)
+	r.rest = r.rest[n:]
+	return n, nil
+}
+
+// chunk reads the next chunk of data, of at most size bytes for files.
+func (r *reader) chunk(size int) (string, bool) {
+	state := r.state
+	top := state.Top()
+	defer state.SetTop(top)
+	if size < chunkSize {
+		size = chunkSize
+	}
+	state.Push(r.obj)
+	if state.TypeAt(-1) == lua.FuncType {
+		state.Call(0, 1)
+	} else {
+		state.GetField(-1, "read")
+		state.Insert(-2)
+		state.Push(size)
+		state.Call(2, 2)
+		if state.IsNoneOrNil(-2) && !state.IsNoneOrNil(-1) {
+			state.Errorf("csv: %s", state.ToString(-1))
+		}
+		state.Pop()
+	}
+	if state.IsNoneOrNil(-1) {
+		return "", true
+	}
+	if t := state.TypeAt(-1); t != lua.StringType && t != lua.NumberType {
+		state.Errorf("csv: reader returned a %s", t)
+	}
+	s := state.ToString(-1)
+	return s, s == ""
+}
+
+// writer is a csv writer of a Lua writer.
+type writer struct {
+	state *lua.State
+	obj   lua.Value
+	csv   *csv.Writer
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	state := w.state
+	top := state.Top()
+	defer state.SetTop(top)
+	state.Push(w.obj)
+	if state.TypeAt(-1) == lua.FuncType {
+		state.Push(string(p))
+		state.Call(1, 0)
+		return len(p), nil
+	}
+	state.GetField(-1, "write")
+	state.Insert(-2)
+	state.Push(string(p))
+	state.Call(2, 2)
+	if state.IsNoneOrNil(-2) && !state.IsNoneOrNil(-1) {
+		return 0, errors.New(state.ToString(-1))
+	}
+	return len(p), nil
+}
+
+// csv.writer (writer [, options])
+//
+// Returns a csv writer writing records to writer. Fields are quoted when
+// needed.
+func csvWriter(state *lua.State) int {
+	opts := checkOptions(state, 2)
+	w := &writer{obj: state.CheckAny(1)}
+	w.csv = csv.NewWriter(w)
+	w.csv.Comma = opts.sep
+	w.csv.UseCRLF = opts.crlf
+	state.Push(w)
+	state.SetMetaTable(writerTypeName)
+	return 1
+}
+
+func toWriter(state *lua.State) *writer {
+	w := state.CheckUserData(1, writerTypeName).(*writer)
+	w.state = state
+	return w
+}
+
+// writer:write (record)
+//
+// Writes the sequence record, whose values must be strings or numbers; nil
+// values are written as empty fields. Returns the writer. Output is buffered
+// until writer:flush is called or the buffer is full.
+func writerWrite(state *lua.State) int {
+	w := toWriter(state)
+	state.CheckType(2, lua.TableType)
+	n := state.RawLen(2)
+	record := make([]string, n)
+	for i := 1; i <= n; i++ {
+		state.RawGetIndex(2, i)
+		switch state.TypeAt(-1) {
+		case lua.NilType, lua.NoneType:
+		case lua.StringType, lua.NumberType:
+			record[i-1] = state.ToString(-1)
+		default:
+			state.Errorf("invalid value (a %s) at index %d in record", state.TypeAt(-1), i)
+		}
+		state.Pop()
+	}
+	if err := w.csv.Write(record); err != nil {
+		return state.FileResult(err, "")
+	}
+	state.PushIndex(1)
+	return 1
+}
+
+// writer:flush ()
+//
+// Writes any buffered records to the underlying writer. Returns the writer.
+func writerFlush(state *lua.State) int {
+	w := toWriter(state)
+	w.csv.Flush()
+	if err := w.csv.Error(); err != nil {
+		return state.FileResult(err, "")
+	}
+	state.PushIndex(1)
+	return 1
+}
+
+func writerToString(state *lua.State) int {
+	state.Push(fmt.Sprintf("csv.writer: %p", toWriter(state)))
+	return 1
+}
@@ -0,0 +1,185 @@
+package std
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/Azure/golua/lua"
+)
+
+func TestCSVReader(t *testing.T) {
+	state := lua.NewState(lua.WithFileSystem(memFS{"items.csv": "name,price\nsword,12\nshield,8,rare\n"}))
+	defer state.Close()
+	Open(state)
+	lib := require(t, state, "csv")
+
+	// chunks returns a reader function returning the given chunks, then nil.
+	chunks := func(data ...string) lua.Func {
+		return func(state *lua.State) int {
+			if len(data) == 0 {
+				return 0
+			}
+			state.Push(data[0])
+			data = data[1:]
+			return 1
+		}
+	}
+	// rows reads all the records of csv.open(args...), formatting fields as
+	// key=value and records as [fields], or returns the error.
+	rows := func(args ...interface{}) string {
+		defer state.SetTop(0)
+		state.Push(lib)
+		state.GetField(-1, "open")
+		for _, arg := range args {
+			state.Push(arg)
+		}
+		if err := state.PCall(len(args), 2, 0); err != nil {
+			return err.Error()
+		}
+		if state.IsNil(-2) {
+			return state.ToString(-1)
+		}
+		state.Pop()
+		next := state.Pop()
+		var out []string
+		for {
+			state.Push(next)
+			if err := state.PCall(0, 1, 0); err != nil {
+				return strings.Join(append(out, err.Error()), " ")
+			}
+			if state.IsNil(-1) {
+				return strings.Join(out, " ")
+			}
+			var fields []string
+			state.Push(nil)
+			for state.Next(-2) {
+				state.PushIndex(-2) // ToString would convert the key in place
+				fields = append(fields, fmt.Sprintf("%s=%s", state.ToString(-1), state.ToString(-2)))
+				state.PopN(2)
+			}
+			state.Pop()
+			if strings.HasPrefix(fields[0], "1=") {
+				for i := range fields {
+					fields[i] = fields[i][strings.Index(fields[i], "=")+1:]
+				}
+			} else {
+				sort.Strings(fields)
+			}
+			out = append(out, "["+strings.Join(fields, "|")+"]")
+		}
+	}
+	options := func(fields map[string]interface{}) lua.Value {
+		state.PushValue(fields)
+		return state.Pop()
+	}
+
+	var tests = []struct {
+		args []interface{}
+		want string
+	}{
+		// Records and quoted fields span chunks.
+		{
+			[]interface{}{chunks("a,\"b", "\"\"c\nd\",e\nf", ",g\n", "")},
+			`[a|b"c` + "\n" + `d|e] [f|g]`,
+		},
+		{[]interface{}{chunks()}, ""},
+		{[]interface{}{chunks("x,y")}, "[x|y]"},
+		{[]interface{}{"items.csv"}, "[name|price] [sword|12] [shield|8|rare]"},
+		{
+			[]interface{}{"items.csv", options(map[string]interface{}{"header": true})},
+			"[name=sword|price=12] [3=rare|name=shield|price=8]",
+		},
+		{
+			[]interface{}{chunks("# comment\na; b\n"), options(map[string]interface{}{"sep": ";", "comment": "#", "trim": true})},
+			"[a|b]",
+		},
+		{[]interface{}{chunks("a,b\nc,d\"e\n")}, `[a|b] csv: line 2: bare " in non-quoted-field`},
+		{[]interface{}{lua.Func(func(state *lua.State) int { state.Push(true); return 1 })}, "csv: reader returned a boolean"},
+		{[]interface{}{chunks(), options(map[string]interface{}{"sep": `"`})}, `bad argument #2 to 'csv.open' (invalid sep '"')`},
+		{[]interface{}{"missing.csv"}, "missing.csv: open missing.csv: file does not exist"},
+	}
+	for i, tt := range tests {
+		if got := rows(tt.args...); got != tt.want {
+			t.Errorf("#%d: rows = %s; want %s", i, got, tt.want)
+		}
+	}
+
+	// Records are read as they are needed.
+	var requests int
+	infinite := lua.Func(func(state *lua.State) int {
+		requests++
+		state.Push("1,2\n")
+		return 1
+	})
+	state.Push(lib)
+	state.GetField(-1, "open")
+	state.Push(infinite)
+	state.Call(1, 1)
+	for i := 0; i < 3; i++ {
+		state.PushIndex(-1)
+		state.Call(0, 1)
+		state.Pop()
+	}
+	if requests > 3 {
+		t.Errorf("reading 3 records made %d requests", requests)
+	}
+	state.SetTop(0)
+}
+
+func TestCSVWriter(t *testing.T) {
+	state := lua.NewState()
+	defer state.Close()
+	Open(state)
+	lib := require(t, state, "csv")
+
+	var out strings.Builder
+	sink := lua.Func(func(state *lua.State) int {
+		out.WriteString(state.CheckString(1))
+		return 0
+	})
+	// write writes the records with a writer of the given options, and
+	// returns the output or the error.
+	write := func(opts map[string]interface{}, records ...[]interface{}) string {
+		out.Reset()
+		defer state.SetTop(0)
+		state.Push(lib)
+		state.GetField(-1, "writer")
+		state.Push(sink)
+		state.PushValue(opts)
+		state.Call(2, 1)
+		for _, record := range records {
+			state.GetField(-1, "write")
+			state.Insert(-2)
+			state.PushValue(record)
+			if err := state.PCall(2, 1, 0); err != nil {
+				return err.Error()
+			}
+		}
+		if out.Len() != 0 {
+			t.Errorf("records written before flush: %q", out.String())
+		}
+		state.GetField(-1, "flush")
+		state.Insert(-2)
+		state.Call(1, 1)
+		return out.String()
+	}
+
+	var tests = []struct {
+		opts    map[string]interface{}
+		records [][]interface{}
+		want    string
+	}{
+		{nil, [][]interface{}{{"name", "note"}, {"sword", `says "hi", twice`}}, "name,note\nsword,\"says \"\"hi\"\", twice\"\n"},
+		{nil, [][]interface{}{{1, 2.5, "a\nb"}}, "1,2.5,\"a\nb\"\n"},
+		{nil, [][]interface{}{{"a", nil, "c"}}, "a,,c\n"},
+		{map[string]interface{}{"sep": "\t", "crlf": true}, [][]interface{}{{"a", "b,c"}, {"d"}}, "a\tb,c\r\nd\r\n"},
+		{nil, [][]interface{}{{"a", true}}, "invalid value (a boolean) at index 2 in record"},
+	}
+	for i, tt := range tests {
+		if got := write(tt.opts, tt.records...); got != tt.want {
+			t.Errorf("#%d: output = %q; want %q", i, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package data
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"runtime"
+
+	"github.com/Azure/golua/lua"
+)
+
+const (
+	blobTypeName  = "data.blob"
+	arrayTypeName = "data.array"
+)
+
+//
+// Lua Extension Library -- data
+//
+
+// Open opens the data library. The library gives scripts read-only access to
+// large binary data files (navigation meshes, loot tables, ...) without copying
+// them into Lua: files are memory-mapped where the system supports it, and
+// values are decoded on access by bounds-checked readers.
+//
+// Offsets are byte offsets from the start of the file, starting at 0; arrays
+// are indexed from 1 like Lua sequences:
+//
+//	local mesh = data.open("nav.bin")
+//	local count = mesh:read("u32", 0)
+//	local xs = mesh:array("f32", 4, count, 12)
+//	print(#xs, xs[1])
+//
+// The value types are "i8", "u8", "i16", "u16", "i32", "u32", "i64", "u64"
+// (returned as a wrapped-around integer), "f32" and "f64".
+//
+// The library is not opened by default; it is available through require "data".
+func Open(state *lua.State) int {
+	// Create 'data' table.
+	var dataFuncs = map[string]lua.Func{
+		"open": lua.Func(dataOpen),
+	}
+	state.NewTableSize(0, len(dataFuncs))
+	state.SetFuncs(dataFuncs, 0)
+	createBlobMetaTable(state)
+	createArrayMetaTable(state)
+
+	// Return 'data' table.
+	return 1
+}
+
+// createBlobMetaTable creates the metatable for data files.
+func createBlobMetaTable(state *lua.State) {
+	var funcs = map[string]lua.Func{
+		"array":      lua.Func(blobArray),
+		"close":      lua.Func(blobClose),
+		"len":        lua.Func(blobLen),
+		"read":       lua.Func(blobRead),
+		"string":     lua.Func(blobString),
+		"__len":      lua.Func(blobLen),
+		"__tostring": lua.Func(blobToString),
+	}
+	state.NewMetaTable(blobTypeName)
+	state.PushIndex(-1)           // push metatable
+	state.SetField(-2, "__index") // metatable.__index = metatable
+	state.SetFuncs(funcs, 0)
+	state.Pop()
+}
+
+// createArrayMetaTable creates the metatable for array readers.
+func createArrayMetaTable(state *lua.State) {
+	var funcs = map[string]lua.Func{
+		"__index":    lua.Func(arrayIndex),
+		"__len":      lua.Func(arrayLen),
+		"__tostring": lua.Func(arrayToString),
+	}
+	state.NewMetaTable(arrayTypeName)
+	state.SetFuncs(funcs, 0)
+	state.Pop()
+}
+
+// data.open (filename [, order])
+//
+// Maps the file named filename for reading and returns it. order is the byte
+// order of the values in the file, "little" (the default) or "big". In case
+// of errors this function returns nil, plus a string describing the error.
+func dataOpen(state *lua.State) int {
+	var (
+		name  = state.CheckString(1)
+		order binary.ByteOrder
+	)
+	switch opt := state.OptString(2, "little"); opt {
+	case "little":
+		order = binary.LittleEndian
+	case "big":
+		order = binary.BigEndian
+	default:
+		state.ArgError(2, fmt.Sprintf("invalid byte order '%s'", opt))
+	}
+	b, err := open(state.FileSystem(), name, order)
+	if err != nil {
+		return state.FileResult(err, name)
+	}
+	state.Push(b)
+	state.SetMetaTable(blobTypeName)
+	return 1
+}
+
+// blob:read (type, offset)
+//
+// Returns the value of the given type at offset.
+func blobRead(state *lua.State) int {
+	b := toBlob(state)
+	t := checkType(state, 2)
+	off := checkRange(state, b, 3, int64(t.size))
+	state.Push(t.decode(b.order, b.data[off:]))
+	return 1
+}
+
+// blob:string (offset, n)
+//
+// Returns the n bytes at offset as a string.
+func blobString(state *lua.State) int {
+	b := toBlob(state)
+	n := state.CheckInt(3)
+	state.ArgCheck(n >= 0, 3, "negative length")
+	off := checkRange(state, b, 2, n)
+	state.Push(string(b.data[off : off+int(n)]))
+	return 1
+}
+
+// blob:array (type, offset, count [, stride])
+//
+// Returns a reader over count values of the given type, the first at offset
+// and each following one stride bytes (by default the size of the type) after
+// the previous one. The reader is indexed from 1 and its length is count;
+// indexing it outside of this range returns nil.
+func blobArray(state *lua.State) int {
+	b := toBlob(state)
+	t := checkType(state, 2)
+	var (
+		count  = state.CheckInt(4)
+		stride = state.OptInt(5, int64(t.size))
+	)
+	state.ArgCheck(count >= 0, 4, "negative count")
+	state.ArgCheck(stride >= int64(t.size), 5, "stride smaller than the type")
+	var size int64
+	if count > 0 {
+		if count-1 > (int64(len(b.data))-int64(t.size))/stride {
+			state.ArgError(4, "out of bounds")
+		}
+		size = (count-1)*stride + int64(t.size)
+	}
+	off := checkRange(state, b, 3, size)
+	state.Push(&array{blob: b, kind: t, off: off, count: int(count), stride: int(stride)})
+	state.SetMetaTable(arrayTypeName)
+	return 1
+}
+
+// blob:close ()
+//
+// Unmaps the file. Any later access to the file or its arrays raises an error.
+func blobClose(state *lua.State) int {
+	b := state.CheckUserData(1, blobTypeName).(*blob)
+	if err := b.close(); err != nil {
+		return state.FileResult(err, "")
+	}
+	state.Push(true)
+	return 1
+}
+
+// blob:len ()
+//
+// Returns the size of the file in bytes.
+func blobLen(state *lua.State) int {
+	state.Push(len(toBlob(state).data))
+	return 1
+}
+
+func blobToString(state *lua.State) int {
+	b := state.CheckUserData(1, blobTypeName).(*blob)
+	if b.closed {
+		state.Push(fmt.Sprintf("data (closed): %p", b))
+	} else {
+		state.Push(fmt.Sprintf("data (%d bytes): %p", len(b.data), b))
+	}
+	return 1
+}
+
+func arrayIndex(state *lua.State) int {
+	a := toArray(state)
+	i, ok := state.TryInt(2)
+	if !ok || i < 1 || i > int64(a.count) {
+		state.Push(nil)
+		return 1
+	}
+	off := a.off + int(i-1)*a.stride
+	state.Push(a.kind.decode(a.blob.order, a.blob.data[off:]))
+	return 1
+}
+
+func arrayLen(state *lua.State) int {
+	state.Push(toArray(state).count)
+	return 1
+}
+
+func arrayToString(state *lua.State) int {
+	a := state.CheckUserData(1, arrayTypeName).(*array)
+	state.Push(fmt.Sprintf("data.array (%s, %d): %p", a.kind.name, a.count, a))
+	return 1
+}
+
+func toBlob(state *lua.State) *blob {
+	b := state.CheckUserData(1, blobTypeName).(*blob)
+	if b.closed {
+		panic(fmt.Errorf("attempt to use a closed data file"))
+	}
+	return b
+}
+
+func toArray(state *lua.State) *array {
+	a := state.CheckUserData(1, arrayTypeName).(*array)
+	if a.blob.closed {
+		panic(fmt.Errorf("attempt to use a closed data file"))
+	}
+	return a
+}
+
+// checkType returns the value type named by the argument at arg.
+func checkType(state *lua.State, arg int) kind {
+	name := state.CheckString(arg)
+	for _, t := range kinds {
+		if t.name == name {
+			return t
+		}
+	}
+	state.ArgError(arg, fmt.Sprintf("invalid type '%s'", name))
+	return kind{}
+}
+
+// checkRange returns the offset at arg, checking that size bytes from it are
+// within the file.
+func checkRange(state *lua.State, b *blob, arg int, size int64) int {
+	off := state.CheckInt(arg)
+	if off < 0 || off > int64(len(b.data)) || size > int64(len(b.data))-off {
+		state.ArgError(arg, "out of bounds")
+	}
+	return int(off)
+}
+
+// blob is a memory-mapped data file.
+type blob struct {
+	data   []byte
+	order  binary.ByteOrder
+	unmap  func() error
+	closed bool
+}
+
+// open opens the data file name of fs. Files of the operating system are
+// memory-mapped; files of other file systems are read into memory.
+func open(fs lua.FileSystem, name string, order binary.ByteOrder) (*blob, error) {
+	file, err := fs.OpenFile(name, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	size, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if int64(int(size)) != size {
+		return nil, fmt.Errorf("file too large")
+	}
+	var (
+		data  []byte
+		unmap = func() error { return nil }
+	)
+	if f, ok := file.(*os.File); ok {
+		data, unmap, err = mapFile(f, int(size))
+	} else if _, err = file.Seek(0, io.SeekStart); err == nil {
+		data = make([]byte, size)
+		_, err = io.ReadFull(file, data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	b := &blob{data: data, order: order, unmap: unmap}
+	runtime.SetFinalizer(b, (*blob).close)
+	return b, nil
+}
+
+func (b *blob) close() error {
+	if b.closed {
+		return nil
+	}
+	b.closed = true
+	b.data = nil
+	runtime.SetFinalizer(b, nil)
+	return b.unmap()
+}
+
+// array is a reader over values of a data file.
+type array struct {
+	blob   *blob
+	kind   kind
+	off    int
+	count  int
+	stride int
+}
+
+// kind is a value type of data files.
+type kind struct {
+	name   string
+	size   int
+	decode func(binary.ByteOrder, []byte) interface{}
+}
+
+var kinds = []kind{
+	{"i8", 1, func(_ binary.ByteOrder, b []byte) interface{} { return int64(int8(b[0])) }},
+	{"u8", 1, func(_ binary.ByteOrder, b []byte) interface{} { return int64(b[0]) }},
+	{"i16", 2, func(o binary.ByteOrder, b []byte) interface{} { return int64(int16(o.Uint16(b))) }},
+	{"u16", 2, func(o binary.ByteOrder, b []byte) interface{} { return int64(o.Uint16(b)) }},
+	{"i32", 4, func(o binary.ByteOrder, b []byte) interface{} { return int64(int32(o.Uint32(b))) }},
+	{"u32", 4, func(o binary.ByteOrder, b []byte) interface{} { return int64(o.Uint32(b)) }},
+	{"i64", 8, func(o binary.ByteOrder, b []byte) interface{} { return int64(o.Uint64(b)) }},
+	{"u64", 8, func(o binary.ByteOrder, b []byte) interface{} { return int64(o.Uint64(b)) }},
+	{"f32", 4, func(o binary.ByteOrder, b []byte) interface{} { return float64(math.Float32frombits(o.Uint32(b))) }},
+	{"f64", 8, func(o binary.ByteOrder, b []byte) interface{} { return math.Float64frombits(o.Uint64(b)) }},
+}
@@ -0,0 +1,18 @@
+//go:build !aix && !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !aix,!darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package data
+
+import (
+	"io"
+	"os"
+)
+
+// mapFile reads size bytes of the file into memory on systems without mmap.
+func mapFile(file *os.File, size int) ([]byte, func() error, error) {
+	b := make([]byte, size)
+	if _, err := io.ReadFull(file, b); err != nil {
+		return nil, nil, err
+	}
+	return b, func() error { return nil }, nil
+}
@@ -0,0 +1,152 @@
+package std
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Azure/golua/lua"
+)
+
+// dataFile is a data file: a little-endian u32, -1 as an i32, 1.0 as an f32 and
+// "hi!" with a trailing zero.
+const dataFile = "\x01\x02\x03\x04\xff\xff\xff\xff\x00\x00\x80\x3fhi!\x00"
+
+func TestData(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "record.bin")
+	if err := ioutil.WriteFile(name, []byte(dataFile), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Files of the operating system are memory-mapped, others are read.
+	for _, fs := range []lua.FileSystem{nil, memFS{name: dataFile}} {
+		var opts []lua.Option
+		if fs != nil {
+			opts = append(opts, lua.WithFileSystem(fs))
+		}
+		testData(t, lua.NewState(opts...), name)
+	}
+}
+
+func testData(t *testing.T, state *lua.State, name string) {
+	defer state.Close()
+	Open(state)
+	lib := require(t, state, "data")
+
+	// try calls obj[fn](args...), or index obj if fn is empty, and returns
+	// its results or error.
+	try := func(obj lua.Value, fn string, args ...interface{}) string {
+		defer state.SetTop(0)
+		state.Push(obj)
+		if fn == "" {
+			state.Push(lua.Func(func(state *lua.State) int {
+				state.GetIndex(1, state.CheckInt(2))
+				return 1
+			}))
+			state.Insert(-2)
+		} else {
+			state.GetField(-1, fn)
+			if obj != lib {
+				state.Insert(-2)
+			} else {
+				state.Remove(-2)
+			}
+		}
+		for _, arg := range args {
+			state.Push(arg)
+		}
+		if err := state.PCall(state.Top()-1, lua.MultRets, 0); err != nil {
+			return err.Error()
+		}
+		return fmt.Sprint(results(state, 1))
+	}
+	open := func(order ...interface{}) lua.Value {
+		state.Push(lib)
+		state.GetField(-1, "open")
+		state.Push(name)
+		for _, arg := range order {
+			state.Push(arg)
+		}
+		state.Call(1+len(order), 1)
+		defer state.SetTop(0)
+		return state.CheckAny(-1)
+	}
+	file := open()
+
+	var tests = []struct {
+		fn   string
+		args []interface{}
+		want string
+	}{
+		{"len", nil, "[16]"},
+		{"read", []interface{}{"u32", 0}, "[67305985]"},
+		{"read", []interface{}{"u16", 2}, "[1027]"},
+		{"read", []interface{}{"i32", 4}, "[-1]"},
+		{"read", []interface{}{"u32", 4}, "[4294967295]"},
+		{"read", []interface{}{"i8", 7}, "[-1]"},
+		{"read", []interface{}{"f32", 8}, "[1]"},
+		{"read", []interface{}{"u32", 12}, "[2189672]"},
+		{"read", []interface{}{"u8", 15}, "[0]"},
+		{"read", []interface{}{"u32", 13}, "bad argument #3 to '?' (out of bounds)"},
+		{"read", []interface{}{"u8", 16}, "bad argument #3 to '?' (out of bounds)"},
+		{"read", []interface{}{"u8", -1}, "bad argument #3 to '?' (out of bounds)"},
+		{"read", []interface{}{"i64", 9}, "bad argument #3 to '?' (out of bounds)"},
+		{"read", []interface{}{"u8", int64(1) << 62}, "bad argument #3 to '?' (out of bounds)"},
+		{"read", []interface{}{"u128", 0}, "bad argument #2 to '?' (invalid type 'u128')"},
+		{"string", []interface{}{12, 3}, "[hi!]"},
+		{"string", []interface{}{16, 0}, "[]"},
+		{"string", []interface{}{12, 5}, "bad argument #2 to '?' (out of bounds)"},
+		{"string", []interface{}{17, 0}, "bad argument #2 to '?' (out of bounds)"},
+		{"string", []interface{}{0, -1}, "bad argument #3 to '?' (negative length)"},
+		{"array", []interface{}{"u8", 0, 16}, "[userdata]"},
+		{"array", []interface{}{"u8", 1, 16}, "bad argument #3 to '?' (out of bounds)"},
+		{"array", []interface{}{"u32", 0, 4}, "[userdata]"},
+		{"array", []interface{}{"u32", 0, 5}, "bad argument #4 to '?' (out of bounds)"},
+		{"array", []interface{}{"u32", 12, 1, 1 << 40}, "[userdata]"},
+		{"array", []interface{}{"u32", 0, 2, int64(1) << 62}, "bad argument #4 to '?' (out of bounds)"},
+		{"array", []interface{}{"u32", 16, 0}, "[userdata]"},
+		{"array", []interface{}{"u32", 0, -1}, "bad argument #4 to '?' (negative count)"},
+		{"array", []interface{}{"u32", 0, 2, 2}, "bad argument #5 to '?' (stride smaller than the type)"},
+	}
+	for _, tt := range tests {
+		if got := try(file, tt.fn, tt.args...); got != tt.want {
+			t.Errorf("%s%v = %s; want %s", tt.fn, tt.args, got, tt.want)
+		}
+	}
+
+	// Arrays are indexed from 1 to their count.
+	state.Push(file)
+	state.GetField(-1, "array")
+	state.Insert(-2)
+	state.Push("u8")
+	state.Push(4)
+	state.Push(3)
+	state.Push(4)
+	state.Call(5, 1)
+	bytes := state.Pop()
+	var got []string
+	for i := 0; i <= 4; i++ {
+		got = append(got, try(bytes, "", i))
+	}
+	if want := "[nil] [255] [0] [104] [nil]"; strings.Join(got, " ") != want {
+		t.Errorf("array elements = %s; want %s", strings.Join(got, " "), want)
+	}
+
+	if got := try(open("big"), "read", "u16", 0); got != "[258]" {
+		t.Errorf("big-endian read = %s", got)
+	}
+	if got := try(lib, "open", name, "middle"); got != "bad argument #2 to 'data.open' (invalid byte order 'middle')" {
+		t.Errorf("data.open with an invalid order: %s", got)
+	}
+
+	// A closed file can no longer be read, not even through its arrays.
+	if got := try(file, "close"); got != "[boolean]" {
+		t.Errorf("close = %s", got)
+	}
+	for _, got := range []string{try(file, "read", "u8", 0), try(bytes, "", 1)} {
+		if !strings.HasSuffix(got, "attempt to use a closed data file") {
+			t.Errorf("access after close: %s", got)
+		}
+	}
+}
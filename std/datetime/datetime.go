@@ -0,0 +1,407 @@
+package datetime
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/Azure/golua/lua"
+)
+
+const datetimeTypeName = "datetime"
+
+//
+// Lua Extension Library -- datetime
+//
+
+// Open opens the datetime library. Datetimes are immutable values holding an
+// instant together with a time zone, backed by Go's time package:
+//
+//	local datetime = require "datetime"
+//	local t = datetime.parse("2024-03-01T10:00:00+01:00")
+//	local later = t:add{days = 30, hours = 2}
+//	print(later, later:weekday(), later - t) --> 2024-03-31T12:00:00+01:00 7 2599200
+//
+// Durations are expressed in seconds (possibly fractional). Datetimes support
+// the comparison operators, dt + seconds, dt - seconds and dt1 - dt2 (which
+// yields the difference in seconds). Fields year, month, day, hour, min, sec,
+// nsec and tz may be read directly from a datetime.
+//
+// The library is not opened by default; it is available through require "datetime".
+func Open(state *lua.State) int {
+	// Create 'datetime' table.
+	var datetimeFuncs = map[string]lua.Func{
+		"new":   lua.Func(datetimeNew),
+		"now":   lua.Func(datetimeNow),
+		"parse": lua.Func(datetimeParse),
+		"unix":  lua.Func(datetimeUnix),
+	}
+	state.NewTableSize(0, len(datetimeFuncs))
+	state.SetFuncs(datetimeFuncs, 0)
+	createDatetimeMetaTable(state)
+
+	// Return 'datetime' table.
+	return 1
+}
+
+var datetimeMethods = map[string]lua.Func{
+	"add":     lua.Func(dtAdd),
+	"diff":    lua.Func(dtDiff),
+	"format":  lua.Func(dtFormat),
+	"isoweek": lua.Func(dtISOWeek),
+	"totable": lua.Func(dtToTable),
+	"unix":    lua.Func(dtUnix),
+	"weekday": lua.Func(dtWeekday),
+	"yearday": lua.Func(dtYearDay),
+	"zone":    lua.Func(dtZone),
+}
+
+// createDatetimeMetaTable creates the metatable for datetimes.
+func createDatetimeMetaTable(state *lua.State) {
+	var funcs = map[string]lua.Func{
+		"__add":      lua.Func(dtMetaAdd),
+		"__eq":       lua.Func(dtMetaEq),
+		"__index":    lua.Func(dtMetaIndex),
+		"__le":       lua.Func(dtMetaLe),
+		"__lt":       lua.Func(dtMetaLt),
+		"__sub":      lua.Func(dtMetaSub),
+		"__tostring": lua.Func(dtMetaToString),
+	}
+	state.NewMetaTable(datetimeTypeName)
+	state.SetFuncs(funcs, 0)
+	state.Pop()
+}
+
+// datetime.now ([tz])
+//
+// Returns the current time in the time zone tz (default the local time zone).
+func datetimeNow(state *lua.State) int {
+	Push(state, time.Now().In(optLocation(state, 1)))
+	return 1
+}
+
+// datetime.new (fields)
+//
+// Returns the datetime described by the table fields, which may contain year,
+// month, day, hour, min, sec, nsec and tz. Missing date fields default to 1 and
+// missing time fields to 0; tz is a time zone name such as "UTC" or
+// "Europe/Paris" and defaults to the local time zone. Out of range values are
+// normalized, so {month = 13} is January of the next year.
+func datetimeNew(state *lua.State) int {
+	state.CheckType(1, lua.TableType)
+	field := func(name string, def int) int {
+		state.GetField(1, name)
+		defer state.Pop()
+		if state.IsNoneOrNil(-1) {
+			return def
+		}
+		v, ok := state.TryInt(-1)
+		if !ok {
+			state.Errorf("field '%s' is not an integer", name)
+		}
+		return int(v)
+	}
+	loc := time.Local
+	if state.GetField(1, "tz"); !state.IsNoneOrNil(-1) {
+		loc = location(state, state.ToString(-1))
+	}
+	state.Pop()
+	Push(state, time.Date(
+		field("year", 1), time.Month(field("month", 1)), field("day", 1),
+		field("hour", 0), field("min", 0), field("sec", 0), field("nsec", 0),
+		loc,
+	))
+	return 1
+}
+
+// layouts accepted by datetime.parse, tried in order.
+var layouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02",
+}
+
+// datetime.parse (s [, tz])
+//
+// Parses an ISO 8601 date ("2024-03-01"), date-time ("2024-03-01T10:00:00")
+// or date-time with offset ("2024-03-01T10:00:00Z", "...+01:00"). Values
+// without an offset are interpreted in the time zone tz (default the local
+// time zone). On failure returns nil plus an error message.
+func datetimeParse(state *lua.State) int {
+	s := state.CheckString(1)
+	loc := optLocation(state, 2)
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			Push(state, t)
+			return 1
+		}
+	}
+	state.Push(nil)
+	state.Push(fmt.Sprintf("invalid ISO 8601 datetime '%s'", s))
+	return 2
+}
+
+// datetime.unix (seconds [, tz])
+//
+// Returns the datetime corresponding to the given Unix time in the time
+// zone tz (default the local time zone).
+func datetimeUnix(state *lua.State) int {
+	secs := state.CheckNumber(1)
+	Push(state, fromSeconds(secs).In(optLocation(state, 2)))
+	return 1
+}
+
+// dt:add (duration)
+//
+// Returns dt shifted by duration, which is either a number of seconds or a
+// table with any of the fields years, months, days, hours, minutes and seconds.
+// Calendar fields (years, months and days) follow the calendar of dt's time
+// zone, so adding one day across a daylight saving change keeps the wall clock.
+func dtAdd(state *lua.State) int {
+	t := check(state, 1)
+	if state.IsNumber(2) {
+		Push(state, t.Add(seconds(state.ToNumber(2))))
+		return 1
+	}
+	state.CheckType(2, lua.TableType)
+	field := func(name string) float64 {
+		state.GetField(2, name)
+		defer state.Pop()
+		if state.IsNoneOrNil(-1) {
+			return 0
+		}
+		return state.ToNumber(-1)
+	}
+	t = t.AddDate(int(field("years")), int(field("months")), int(field("days")))
+	t = t.Add(seconds(field("hours")*3600 + field("minutes")*60 + field("seconds")))
+	Push(state, t)
+	return 1
+}
+
+// dt:diff (other)
+//
+// Returns the number of seconds from other to dt (dt - other).
+func dtDiff(state *lua.State) int {
+	state.Push(check(state, 1).Sub(check(state, 2)).Seconds())
+	return 1
+}
+
+// dt:format ([layout])
+//
+// Formats dt. Without layout the result is ISO 8601 (RFC 3339, with fractional
+// seconds when non-zero). Otherwise layout is a Go reference-time layout such as
+// "2006-01-02 15:04".
+func dtFormat(state *lua.State) int {
+	state.Push(check(state, 1).Format(state.OptString(2, time.RFC3339Nano)))
+	return 1
+}
+
+// dt:weekday ()
+//
+// Returns the ISO 8601 day of the week, from 1 (Monday) to 7 (Sunday).
+func dtWeekday(state *lua.State) int {
+	wd := int(check(state, 1).Weekday())
+	if wd == 0 {
+		wd = 7
+	}
+	state.Push(wd)
+	return 1
+}
+
+// dt:isoweek ()
+//
+// Returns the ISO 8601 week number of dt and the year it belongs to.
+func dtISOWeek(state *lua.State) int {
+	year, week := check(state, 1).ISOWeek()
+	state.Push(week)
+	state.Push(year)
+	return 2
+}
+
+// dt:yearday ()
+//
+// Returns the day of the year, from 1 to 366.
+func dtYearDay(state *lua.State) int {
+	state.Push(check(state, 1).YearDay())
+	return 1
+}
+
+// dt:unix ()
+//
+// Returns dt as Unix time in seconds, with a fractional part for sub-second
+// precision.
+func dtUnix(state *lua.State) int {
+	t := check(state, 1)
+	if t.Nanosecond() == 0 {
+		state.Push(t.Unix())
+	} else {
+		state.Push(float64(t.UnixNano()) / 1e9)
+	}
+	return 1
+}
+
+// dt:zone ([tz])
+//
+// Without arguments returns the name of dt's time zone and its offset from UTC
+// in seconds. With tz, returns the same instant in the time zone tz.
+func dtZone(state *lua.State) int {
+	t := check(state, 1)
+	if state.IsNoneOrNil(2) {
+		name, offset := t.Zone()
+		state.Push(name)
+		state.Push(offset)
+		return 2
+	}
+	Push(state, t.In(location(state, state.CheckString(2))))
+	return 1
+}
+
+// dt:totable ()
+//
+// Returns a table with the fields year, month, day, hour, min, sec, nsec, tz,
+// wday (1 = Monday) and yday, suitable for datetime.new.
+func dtToTable(state *lua.State) int {
+	t := check(state, 1)
+	state.NewTableSize(0, 10)
+	for _, name := range []string{"year", "month", "day", "hour", "min", "sec", "nsec", "tz"} {
+		pushField(state, t, name)
+		state.SetField(-2, name)
+	}
+	state.Push(int(t.Weekday()+6)%7 + 1)
+	state.SetField(-2, "wday")
+	state.Push(t.YearDay())
+	state.SetField(-2, "yday")
+	return 1
+}
+
+func dtMetaIndex(state *lua.State) int {
+	t := check(state, 1)
+	name := state.CheckString(2)
+	if pushField(state, t, name) {
+		return 1
+	}
+	if fn, ok := datetimeMethods[name]; ok {
+		state.Push(fn)
+		return 1
+	}
+	state.Push(nil)
+	return 1
+}
+
+func dtMetaAdd(state *lua.State) int {
+	if test(state, 1) == nil { // number + datetime
+		state.Rotate(1, 1)
+	}
+	Push(state, check(state, 1).Add(seconds(state.CheckNumber(2))))
+	return 1
+}
+
+func dtMetaSub(state *lua.State) int {
+	t := check(state, 1)
+	if other := test(state, 2); other != nil {
+		state.Push(t.Sub(*other).Seconds())
+		return 1
+	}
+	Push(state, t.Add(-seconds(state.CheckNumber(2))))
+	return 1
+}
+
+func dtMetaEq(state *lua.State) int {
+	t1, t2 := test(state, 1), test(state, 2)
+	state.Push(t1 != nil && t2 != nil && t1.Equal(*t2))
+	return 1
+}
+
+func dtMetaLt(state *lua.State) int {
+	state.Push(check(state, 1).Before(check(state, 2)))
+	return 1
+}
+
+func dtMetaLe(state *lua.State) int {
+	state.Push(!check(state, 1).After(check(state, 2)))
+	return 1
+}
+
+func dtMetaToString(state *lua.State) int {
+	state.Push(check(state, 1).Format(time.RFC3339Nano))
+	return 1
+}
+
+// pushField pushes the named field of t and returns true, or returns false
+// if there is no such field.
+func pushField(state *lua.State, t time.Time, name string) bool {
+	switch name {
+	case "year":
+		state.Push(t.Year())
+	case "month":
+		state.Push(int(t.Month()))
+	case "day":
+		state.Push(t.Day())
+	case "hour":
+		state.Push(t.Hour())
+	case "min":
+		state.Push(t.Minute())
+	case "sec":
+		state.Push(t.Second())
+	case "nsec":
+		state.Push(t.Nanosecond())
+	case "tz":
+		state.Push(t.Location().String())
+	default:
+		return false
+	}
+	return true
+}
+
+// Push pushes t onto the stack as a datetime.
+func Push(state *lua.State, t time.Time) {
+	state.Push(&t)
+	state.SetMetaTable(datetimeTypeName)
+}
+
+func check(state *lua.State, index int) time.Time {
+	return *state.CheckUserData(index, datetimeTypeName).(*time.Time)
+}
+
+func test(state *lua.State, index int) *time.Time {
+	t, _ := state.TestUserData(index, datetimeTypeName).(*time.Time)
+	return t
+}
+
+// Test returns the time of the datetime at index and true, or false if the
+// value at index is not a datetime.
+func Test(state *lua.State, index int) (time.Time, bool) {
+	if t := test(state, index); t != nil {
+		return *t, true
+	}
+	return time.Time{}, false
+}
+
+func optLocation(state *lua.State, index int) *time.Location {
+	if state.IsNoneOrNil(index) {
+		return time.Local
+	}
+	return location(state, state.CheckString(index))
+}
+
+func location(state *lua.State, name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		state.Errorf("unknown time zone '%s'", name)
+	}
+	return loc
+}
+
+// seconds converts a number of seconds to a time.Duration.
+func seconds(secs float64) time.Duration {
+	return time.Duration(math.Round(secs * float64(time.Second)))
+}
+
+// fromSeconds converts a Unix time in seconds to a time.Time.
+func fromSeconds(secs float64) time.Time {
+	whole := math.Floor(secs)
+	return time.Unix(int64(whole), int64(math.Round((secs-whole)*1e9)))
+}
@@ -0,0 +1,106 @@
+package std
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Azure/golua/lua"
+	"github.com/Azure/golua/std/datetime"
+)
+
+func TestDatetime(t *testing.T) {
+	state := lua.NewState()
+	defer state.Close()
+	Open(state)
+	lib := require(t, state, "datetime")
+
+	parse := func(s string) (lua.Value, string) {
+		state.Push(lib)
+		state.GetField(-1, "parse")
+		state.Push(s)
+		state.Push("UTC")
+		state.Call(2, 2)
+		defer state.SetTop(0)
+		msg := state.ToString(-1)
+		state.Pop()
+		return state.Pop(), msg
+	}
+	format := func(dt lua.Value) string {
+		return fmt.Sprint(callMethod(t, state, dt, "format")...)
+	}
+	// fields returns a table with the fields and values given in pairs.
+	fields := func(kvs ...interface{}) lua.Value {
+		state.NewTable()
+		for i := 0; i < len(kvs); i += 2 {
+			state.Push(kvs[i+1])
+			state.SetField(-2, kvs[i].(string))
+		}
+		return state.Pop()
+	}
+
+	var parses = []struct {
+		s, want string
+	}{
+		{"2024-03-01", "2024-03-01T00:00:00Z"},
+		{"2024-03-01T10:30", "2024-03-01T10:30:00Z"},
+		{"2024-03-01T10:30:15.5", "2024-03-01T10:30:15.5Z"},
+		{"2024-03-01T10:00:00+01:00", "2024-03-01T10:00:00+01:00"},
+		{"2024-03-01 10:00:00Z", "2024-03-01T10:00:00Z"},
+	}
+	for _, tt := range parses {
+		if dt, _ := parse(tt.s); format(dt) != tt.want {
+			t.Errorf("datetime.parse(%q) = %s; want %s", tt.s, format(dt), tt.want)
+		}
+	}
+	if dt, msg := parse("01/03/2024"); !lua.IsNone(dt) || msg != "invalid ISO 8601 datetime '01/03/2024'" {
+		t.Errorf("datetime.parse of a bad date = %v, %q", dt, msg)
+	}
+
+	// Calendar arithmetic normalizes, and durations are in seconds.
+	dt, _ := parse("2024-01-31T12:00:00Z")
+	if got := format(method(t, state, dt, "add", fields("months", 1, "minutes", 90))); got != "2024-03-02T13:30:00Z" {
+		t.Errorf("dt:add{months = 1, minutes = 90} = %s", got)
+	}
+	later, _ := parse("2024-02-01T12:00:01Z")
+	if got := fmt.Sprint(callMethod(t, state, later, "diff", dt)); got != "[86401]" {
+		t.Errorf("later:diff(dt) = %s; want [86401]", got)
+	}
+	state.Push(dt)
+	state.Push(later)
+	if !state.Compare(lua.OpLt, -2, -1) || state.Compare(lua.OpEq, -2, -1) {
+		t.Errorf("dt < later is false or dt == later is true")
+	}
+	state.SetTop(0)
+
+	// ISO weeks and weekdays around the new year.
+	var weeks = []struct {
+		s    string
+		want string
+	}{
+		{"2021-01-03", "[7] [53 2020] [3]"},
+		{"2021-01-04", "[1] [1 2021] [4]"},
+		{"2024-12-31", "[2] [1 2025] [366]"},
+	}
+	for _, tt := range weeks {
+		dt, _ := parse(tt.s)
+		got := fmt.Sprint(callMethod(t, state, dt, "weekday"), callMethod(t, state, dt, "isoweek"), callMethod(t, state, dt, "yearday"))
+		if got != tt.want {
+			t.Errorf("%s: weekday, isoweek and yearday = %s; want %s", tt.s, got, tt.want)
+		}
+	}
+
+	// Days follow the calendar of the zone across daylight saving changes.
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skip(err)
+	}
+	datetime.Push(state, time.Date(2024, 3, 30, 12, 0, 0, 0, paris))
+	before := state.Pop()
+	if got := format(method(t, state, before, "add", fields("days", 1))); got != "2024-03-31T12:00:00+02:00" {
+		t.Errorf("adding a day across the DST change = %s; want the same wall clock", got)
+	}
+	if got := format(method(t, state, before, "add", 86400)); got != "2024-03-31T13:00:00+02:00" {
+		t.Errorf("adding 86400 seconds across the DST change = %s", got)
+	}
+}
@@ -2,6 +2,7 @@ package debug
 
 import (
 	"fmt"
+	"reflect"
 	"strings"
 
 	"github.com/Azure/golua/lua"
@@ -84,30 +85,21 @@ func dbgDebug(state *lua.State) int {
 //
 // See https://www.lua.org/manual/5.3/manual.html#pdf-debug.getinfo
 func dbgGetInfo(state *lua.State) int {
-	// (n) => name, kind
-	// (S) => span, what, short, source
-	// (l) => active
-	// (u) => nups, params, vararg
-	// (t) => tailcall
-
-	thread, index := getThread(state)
-	options := state.OptString(index+2, "flnStu")
-	checkstack(state, thread, 3)
+	thread, arg := getThread(state)
+	options := state.OptString(arg+2, "flnStu")
 
 	var dbg lua.Debug
 
-	if state.IsFunc(index + 1) {
+	if state.IsFunc(arg + 1) {
 		options = fmt.Sprintf(">%s", options)
-		state.PushIndex(index + 1)
+		state.PushIndex(arg + 1)
 		state.XMove(thread, 1)
-	} else {
-		if err := state.GetStack(&dbg, int(state.CheckInt(index+1))); err != nil {
-			state.Push(nil)
-			return 1
-		}
+	} else if err := thread.GetStack(&dbg, int(state.CheckInt(arg+1))); err != nil {
+		state.Push(nil) // level out of range
+		return 1
 	}
-	if err := state.GetInfo(&dbg, options); err != nil {
-		panic(fmt.Errorf("bad argument #2 to 'getinfo' %v", err))
+	if err := thread.GetInfo(&dbg, options); err != nil {
+		return state.ArgError(arg+2, "invalid option")
 	}
 	state.NewTable()
 	if contains(options, 'S') {
@@ -126,19 +118,20 @@ func dbgGetInfo(state *lua.State) int {
 		setFieldBool(state, "isvararg", dbg.IsVararg())
 	}
 	if contains(options, 'n') {
-		setFieldStr(state, "name", dbg.Name())
+		if dbg.Name() != "" {
+			setFieldStr(state, "name", dbg.Name())
+		}
 		setFieldStr(state, "namewhat", dbg.NameWhat())
 	}
-	if contains(options, 'T') {
+	if contains(options, 't') {
 		setFieldBool(state, "istailcall", dbg.IsTailCall())
 	}
 	if contains(options, 'L') {
-		fmt.Println("debug.getinfo: option 'L': TODO")
+		treatStackOption(state, thread, "activelines")
 	}
 	if contains(options, 'f') {
-		fmt.Println("debug.getinfo: option 'f': TODO")
+		treatStackOption(state, thread, "func")
 	}
-	fmt.Println()
 	return 1
 }
 
@@ -222,8 +215,22 @@ func dbgSetUpvalue(state *lua.State) int {
 //
 // See https://www.lua.org/manual/5.3/manual.html#pdf-debug.gethook
 func dbgGetHook(state *lua.State) int {
-	unimplemented("debug: gethook")
-	return 0
+	thread, arg := getThread(state)
+	hook, mask, count := thread.GetHook()
+	switch {
+	case hook == nil: // no hook?
+		state.Push(nil)
+	case reflect.ValueOf(hook).Pointer() != reflect.ValueOf(lua.Hook(hookf)).Pointer():
+		state.Push("external hook")
+	default:
+		state.GetField(lua.RegistryIndex, hookKey)
+		pushThread(state, arg)
+		state.RawGet(-2)
+		state.Remove(-2)
+	}
+	state.Push(unmakeMask(mask))
+	state.Push(count)
+	return 3
 }
 
 // debug.sethook([thread,] hook, mask [, count])
@@ -250,7 +257,25 @@ func dbgGetHook(state *lua.State) int {
 //
 // See https://www.lua.org/manual/5.3/manual.html#pdf-debug.sethook
 func dbgSetHook(state *lua.State) int {
-	unimplemented("debug: sethook")
+	thread, arg := getThread(state)
+	var (
+		hook  lua.Hook
+		mask  lua.HookEvent
+		count int
+	)
+	if state.IsNoneOrNil(arg + 1) { // no hook?
+		state.SetTop(arg + 1) // turn off hooks
+	} else {
+		smask := state.CheckString(arg + 2)
+		state.CheckType(arg+1, lua.FuncType)
+		count = int(state.OptInt(arg+3, 0))
+		hook, mask = hookf, makeMask(smask, count)
+	}
+	state.GetSubTable(lua.RegistryIndex, hookKey)
+	pushThread(state, arg)
+	state.PushIndex(arg + 1)
+	state.RawSet(-3) // hooks[thread] = hook
+	thread.SetHook(hook, mask, count)
 	return 0
 }
 
@@ -273,8 +298,30 @@ func dbgSetHook(state *lua.State) int {
 //
 // See https://www.lua.org/manual/5.3/manual.html#pdf-debug.getlocal
 func dbgGetLocal(state *lua.State) int {
-	unimplemented("debug: getlocal")
-	return 0
+	thread, arg := getThread(state)
+	n := int(state.CheckInt(arg + 2))
+	if state.IsFunc(arg + 1) { // function argument?
+		state.PushIndex(arg + 1)
+		if name := state.GetLocal(nil, n); name != "" {
+			state.Push(name)
+		} else {
+			state.Push(nil)
+		}
+		return 1
+	}
+	var dbg lua.Debug
+	if err := thread.GetStack(&dbg, int(state.CheckInt(arg+1))); err != nil {
+		return state.ArgError(arg+1, "level out of range")
+	}
+	name := thread.GetLocal(&dbg, n)
+	if name == "" {
+		state.Push(nil) // no name (nor value)
+		return 1
+	}
+	thread.XMove(state, 1)
+	state.Push(name)
+	state.Insert(-2)
+	return 2
 }
 
 // debug.setlocal ([thread,] level, local, value)
@@ -288,8 +335,21 @@ func dbgGetLocal(state *lua.State) int {
 //
 // See https://www.lua.org/manual/5.3/manual.html#pdf-debug.setlocal
 func dbgSetLocal(state *lua.State) int {
-	unimplemented("debug: setlocal")
-	return 0
+	thread, arg := getThread(state)
+	var dbg lua.Debug
+	if err := thread.GetStack(&dbg, int(state.CheckInt(arg+1))); err != nil {
+		return state.ArgError(arg+1, "level out of range")
+	}
+	n := int(state.CheckInt(arg + 2))
+	state.CheckAny(arg + 3)
+	state.SetTop(arg + 3)
+	state.XMove(thread, 1)
+	if name := thread.SetLocal(&dbg, n); name != "" {
+		state.Push(name)
+	} else {
+		state.Push(nil)
+	}
+	return 1
 }
 
 // debug.getuservalue (u)
@@ -334,8 +394,19 @@ func dbgSetUserValue(state *lua.State) int {
 //
 // See https://www.lua.org/manual/5.3/manual.html#pdf-debug.traceback
 func dbgTraceback(state *lua.State) int {
-	unimplemented("debug: traceback")
-	return 0
+	thread, arg := getThread(state)
+	if !state.IsNoneOrNil(arg+1) && !state.IsString(arg+1) {
+		state.PushIndex(arg + 1) // non-string message is returned untouched
+		return 1
+	}
+	level := 0
+	if thread == state {
+		level = 1 // skip traceback itself
+	}
+	msg := state.OptString(arg+1, "")
+	level = int(state.OptInt(arg+2, int64(level)))
+	state.Push(thread.TracebackLevel(msg, level))
+	return 1
 }
 
 // debug.upvalueid (f, n)
@@ -375,6 +446,59 @@ func dbgUpValueJoin(state *lua.State) int {
 	return 0
 }
 
+// hookKey is the registry key of the table of the Lua hooks of the threads.
+const hookKey = "_HOOKKEY"
+
+// hookf is the hook of the threads with a Lua hook, which it calls with the
+// name of the event and, for line events, the new line.
+func hookf(state *lua.State, debug *lua.Debug) {
+	state.GetField(lua.RegistryIndex, hookKey)
+	state.PushThread()
+	if state.RawGet(-2) == lua.FuncType {
+		state.Push(debug.Event().String())
+		if debug.Event() == lua.HookLine {
+			state.Push(debug.CurrentLine())
+		} else {
+			state.Push(nil)
+		}
+		state.Call(2, 0)
+	}
+}
+
+// makeMask converts the string mask and count of debug.sethook to a mask of
+// hook events.
+func makeMask(smask string, count int) (mask lua.HookEvent) {
+	if contains(smask, 'c') {
+		mask |= lua.HookCall
+	}
+	if contains(smask, 'r') {
+		mask |= lua.HookRets
+	}
+	if contains(smask, 'l') {
+		mask |= lua.HookLine
+	}
+	if count > 0 {
+		mask |= lua.HookCount
+	}
+	return mask
+}
+
+// unmakeMask converts a mask of hook events to the string mask of
+// debug.gethook.
+func unmakeMask(mask lua.HookEvent) string {
+	var smask string
+	if mask&lua.HookCall != 0 {
+		smask += "c"
+	}
+	if mask&lua.HookRets != 0 {
+		smask += "r"
+	}
+	if mask&lua.HookLine != 0 {
+		smask += "l"
+	}
+	return smask
+}
+
 func unimplemented(msg string) { panic(fmt.Errorf(msg)) }
 
 func contains(options string, option byte) bool {
@@ -394,8 +518,24 @@ func getThread(state *lua.State) (*lua.State, int) {
 
 // convenience functions.
 
-func checkstack(l1, l2 *lua.State, n int) {
-	fmt.Println("debug: checkstack: TODO")
+// treatStackOption moves the value pushed by GetInfo on thread into the field
+// of the table at the top of the stack of state.
+func treatStackOption(state, thread *lua.State, field string) {
+	if state == thread {
+		state.Rotate(-2, 1)
+	} else {
+		thread.XMove(state, 1)
+	}
+	state.SetField(-2, field)
+}
+
+// pushThread pushes the thread operated over, given as argument arg if 1.
+func pushThread(state *lua.State, arg int) {
+	if arg == 1 {
+		state.PushIndex(1)
+	} else {
+		state.PushThread()
+	}
 }
 
 func setFieldStr(state *lua.State, key, value string) {
@@ -0,0 +1,152 @@
+package events
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/Azure/golua/lua"
+)
+
+// busKey is the registry key of the state's bus.
+const busKey = "events.bus"
+
+// Handler is a Go subscriber. It receives the topic that was emitted and its
+// payload.
+type Handler func(state *lua.State, topic string, payload lua.Value)
+
+// Subscription identifies a subscriber; it is passed to Bus.Off to unsubscribe.
+type Subscription int64
+
+// subscriber is a Go or Lua handler subscribed to a topic pattern.
+type subscriber struct {
+	id       Subscription
+	pattern  []string
+	priority int
+	once     bool
+	removed  bool
+	handler  Handler   // Go handler
+	fn       lua.Value // Lua handler
+}
+
+// Bus dispatches events to Go and Lua subscribers. Each state has one bus,
+// shared by the events library and Go code; see Of.
+//
+// Topics are dot-separated names such as "player.level_up". Subscription
+// patterns may use "*" to match exactly one segment and "**" to match any
+// number of segments, so "player.*" matches "player.level_up" and "**" matches
+// every topic.
+//
+// Subscribers are called in decreasing priority, then in subscription order.
+type Bus struct {
+	subs []*subscriber
+	next Subscription
+}
+
+// Of returns the bus of the state, creating it if necessary.
+func Of(state *lua.State) *Bus {
+	defer state.Pop()
+	if state.GetField(lua.RegistryIndex, busKey) == lua.UserDataType {
+		if bus, ok := state.ToUserData(-1).Value().(*Bus); ok {
+			return bus
+		}
+	}
+	bus := new(Bus)
+	state.Push(bus)
+	state.SetField(lua.RegistryIndex, busKey)
+	return bus
+}
+
+// On subscribes the Go handler h to the topic pattern.
+func (bus *Bus) On(pattern string, priority int, h Handler) Subscription {
+	return bus.add(&subscriber{pattern: split(pattern), priority: priority, handler: h})
+}
+
+// Once subscribes the Go handler h to the next event matching the topic pattern.
+func (bus *Bus) Once(pattern string, priority int, h Handler) Subscription {
+	return bus.add(&subscriber{pattern: split(pattern), priority: priority, once: true, handler: h})
+}
+
+// Off removes the subscription and reports whether it was subscribed.
+func (bus *Bus) Off(id Subscription) bool {
+	for i, sub := range bus.subs {
+		if sub.id == id {
+			sub.removed = true
+			bus.subs = append(bus.subs[:i:i], bus.subs[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Emit calls the subscribers of topic with payload and returns how many were
+// called. Subscriptions added or removed by a subscriber take effect from the
+// next emit, except that removed subscribers are not called anymore.
+func (bus *Bus) Emit(state *lua.State, topic string, payload lua.Value) int {
+	var (
+		segs  = split(topic)
+		subs  = bus.subs
+		count int
+	)
+	for _, sub := range subs {
+		if sub.removed || !match(sub.pattern, segs) {
+			continue
+		}
+		if sub.once {
+			bus.Off(sub.id)
+		}
+		if sub.handler != nil {
+			sub.handler(state, topic, payload)
+		} else {
+			state.Push(sub.fn)
+			state.Push(payload)
+			state.Push(topic)
+			state.Call(2, 0)
+		}
+		count++
+	}
+	return count
+}
+
+// Len returns the number of subscribers.
+func (bus *Bus) Len() int { return len(bus.subs) }
+
+func (bus *Bus) add(sub *subscriber) Subscription {
+	bus.next++
+	sub.id = bus.next
+	// Off copies the slice, so an emit in progress keeps its own snapshot.
+	subs := append(bus.subs[:len(bus.subs):len(bus.subs)], sub)
+	sort.SliceStable(subs, func(i, j int) bool {
+		return subs[i].priority > subs[j].priority
+	})
+	bus.subs = subs
+	return sub.id
+}
+
+func split(topic string) []string {
+	return strings.Split(topic, ".")
+}
+
+// match reports whether the topic segments match the pattern segments.
+func match(pattern, topic []string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case "**":
+			for i := 0; i <= len(topic); i++ {
+				if match(pattern[1:], topic[i:]) {
+					return true
+				}
+			}
+			return false
+		case "*":
+		default:
+			if len(topic) == 0 || pattern[0] != topic[0] {
+				return false
+			}
+		}
+		if len(topic) == 0 {
+			return false
+		}
+		pattern, topic = pattern[1:], topic[1:]
+	}
+	return len(topic) == 0
+}
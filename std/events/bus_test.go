@@ -0,0 +1,30 @@
+package events
+
+import (
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	var tests = []struct {
+		pattern string
+		topic   string
+		want    bool
+	}{
+		{"player.level_up", "player.level_up", true},
+		{"player.level_up", "player.level", false},
+		{"player.*", "player.level_up", true},
+		{"player.*", "player", false},
+		{"player.*", "player.level_up.extra", false},
+		{"*.died", "npc.died", true},
+		{"player.**", "player", true},
+		{"player.**", "player.a.b", true},
+		{"**.died", "zone.npc.died", true},
+		{"**.died", "zone.npc.spawned", false},
+		{"**", "anything.at.all", true},
+	}
+	for _, test := range tests {
+		if got := match(split(test.pattern), split(test.topic)); got != test.want {
+			t.Errorf("match(%q, %q) = %t; want %t", test.pattern, test.topic, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package events
+
+import (
+	"github.com/Azure/golua/lua"
+)
+
+//
+// Lua Extension Library -- events
+//
+
+// Open opens the events library, which publishes events to Go and Lua
+// subscribers through the state's Bus:
+//
+//	local events = require "events"
+//	events.on("player.*", function(payload, topic) print(topic, payload.level) end)
+//	events.once("player.level_up", function(payload) reward(payload) end)
+//	events.emit("player.level_up", {level = 3})
+//
+// Go code subscribes and emits through Of(state).
+//
+// The library is not opened by default; it is available through require "events".
+func Open(state *lua.State) int {
+	bus := Of(state)
+	// Create 'events' table.
+	var eventsFuncs = map[string]lua.Func{
+		"emit": lua.Func(bus.emit),
+		"off":  lua.Func(bus.off),
+		"on":   lua.Func(func(state *lua.State) int { return bus.on(state, false) }),
+		"once": lua.Func(func(state *lua.State) int { return bus.on(state, true) }),
+	}
+	state.NewTableSize(0, len(eventsFuncs))
+	state.SetFuncs(eventsFuncs, 0)
+
+	// Return 'events' table.
+	return 1
+}
+
+// events.on (pattern, fn [, priority])
+// events.once (pattern, fn [, priority])
+//
+// Subscribes fn to the topics matching pattern and returns the subscription
+// id. The function is called with the payload and the topic. A subscriber
+// added with once is removed before its first call. Subscribers with a higher
+// priority (default 0) are called first.
+func (bus *Bus) on(state *lua.State, once bool) int {
+	pattern := state.CheckString(1)
+	state.CheckType(2, lua.FuncType)
+	fn := state.CheckAny(2)
+	priority := int(state.OptInt(3, 0))
+	state.Push(int64(bus.add(&subscriber{
+		pattern:  split(pattern),
+		priority: priority,
+		once:     once,
+		fn:       fn,
+	})))
+	return 1
+}
+
+// events.off (id)
+//
+// Removes the subscription id and returns whether it was subscribed.
+func (bus *Bus) off(state *lua.State) int {
+	state.Push(bus.Off(Subscription(state.CheckInt(1))))
+	return 1
+}
+
+// events.emit (topic [, payload])
+//
+// Calls the subscribers of topic with payload and returns how many were
+// called.
+func (bus *Bus) emit(state *lua.State) int {
+	topic := state.CheckString(1)
+	var payload lua.Value = lua.None
+	if !state.IsNone(2) {
+		payload = state.CheckAny(2)
+	}
+	state.Push(int64(bus.Emit(state, topic, payload)))
+	return 1
+}
@@ -0,0 +1,75 @@
+package std
+
+import (
+	"github.com/Azure/golua/lua"
+)
+
+// openGoLua sets the global _GOLUA, which describes this implementation so
+// that scripts can detect its features instead of failing on a missing one:
+//
+//	_GOLUA.version           -- version of golua, such as "0.1.0"
+//	_GOLUA.lua               -- Lua version it conforms to, as _VERSION
+//	_GOLUA.extensions.table  -- whether the table extensions are opened
+//	_GOLUA.libraries.csv     -- whether require "csv" loads a library
+//	_GOLUA.compat.lua52      -- whether the Lua 5.2 functions are defined
+//	_GOLUA.limits.maxstack   -- maximum size of the stack
+//
+// The extensions are "string", "table", "numbermethods", "exec" and "lazy"
+// (see the options of Open); the limits are those of lua.Limits, maxstack,
+// maxupvalues, maxcalls, maxstring, maxunpack, mininteger and maxinteger, and
+// intsize and floatsize (in bytes).
+func openGoLua(state *lua.State, cfg *config, libs []string) {
+	state.NewTableSize(0, 6)
+	state.Push(lua.GoLuaVersion)
+	state.SetField(-2, "version")
+	state.Push(lua.Version)
+	state.SetField(-2, "lua")
+
+	flags := func(name string, flags map[string]bool) {
+		state.NewTableSize(0, len(flags))
+		for flag, on := range flags {
+			state.Push(on)
+			state.SetField(-2, flag)
+		}
+		state.SetField(-2, name)
+	}
+	flags("extensions", map[string]bool{
+		"string":        cfg.stringExt,
+		"table":         cfg.tableExt,
+		"numbermethods": cfg.numberMethods,
+		"exec":          cfg.exec != nil,
+		"lazy":          len(cfg.lazy) > 0,
+	})
+	available := make(map[string]bool, len(libs))
+	for _, lib := range libs {
+		available[lib] = true
+	}
+	flags("libraries", available)
+	flags("compat", map[string]bool{
+		"lua51": false,
+		"lua52": false,
+	})
+
+	limits := state.Limits()
+	state.NewTableSize(0, 9)
+	for _, limit := range []struct {
+		name  string
+		value int64
+	}{
+		{"maxstack", int64(limits.MaxStack)},
+		{"maxupvalues", int64(limits.MaxUpvalues)},
+		{"maxcalls", int64(limits.MaxCallDepth)},
+		{"maxstring", int64(limits.MaxStringLength)},
+		{"maxunpack", int64(limits.MaxUnpack)},
+		{"intsize", 8},
+		{"floatsize", 8},
+		{"mininteger", limits.MinInteger},
+		{"maxinteger", limits.MaxInteger},
+	} {
+		state.Push(limit.value)
+		state.SetField(-2, limit.name)
+	}
+	state.SetField(-2, "limits")
+
+	state.SetGlobal("_GOLUA")
+}
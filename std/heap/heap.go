@@ -0,0 +1,253 @@
+package heap
+
+import (
+	"container/heap"
+	"fmt"
+	"math"
+
+	"github.com/Azure/golua/lua"
+)
+
+const heapTypeName = "heap"
+
+//
+// Lua Extension Library -- heap
+//
+
+// Open opens the heap library. The library provides a binary heap (priority queue)
+// implemented natively, suitable for pathfinding open lists, timer queues and
+// similar structures where keeping a Lua table sorted is too slow.
+//
+// Heaps are created with heap.new and manipulated through their methods:
+//
+//	local h = heap.new()
+//	h:push("b", 2)
+//	h:push("a", 1)
+//	print(h:pop()) --> a 1
+//
+// The library is not opened by default; it is available through require "heap".
+func Open(state *lua.State) int {
+	// Create 'heap' table.
+	var heapFuncs = map[string]lua.Func{
+		"new": lua.Func(heapNew),
+	}
+	state.NewTableSize(0, len(heapFuncs))
+	state.SetFuncs(heapFuncs, 0)
+	createHeapMetaTable(state)
+
+	// Return 'heap' table.
+	return 1
+}
+
+// createHeapMetaTable creates the metatable for heaps.
+func createHeapMetaTable(state *lua.State) {
+	var funcs = map[string]lua.Func{
+		"clear":      lua.Func(heapClear),
+		"peek":       lua.Func(heapPeek),
+		"pop":        lua.Func(heapPop),
+		"push":       lua.Func(heapPush),
+		"remove":     lua.Func(heapRemove),
+		"update":     lua.Func(heapUpdate),
+		"__len":      lua.Func(heapLen),
+		"__tostring": lua.Func(heapToString),
+	}
+	state.NewMetaTable(heapTypeName)
+	state.PushIndex(-1)           // push metatable
+	state.SetField(-2, "__index") // metatable.__index = metatable
+	state.SetFuncs(funcs, 0)
+	state.Pop()
+}
+
+// heap.new ([comp])
+//
+// Returns a new empty heap. Elements are ordered by their priority, or by the
+// element itself when pushed without one. If comp is given, then it must be a
+// function that receives two priorities and returns true when the first must
+// be popped before the second; otherwise the standard Lua operator < is used,
+// making the heap a min-heap.
+func heapNew(state *lua.State) int {
+	if !state.IsNoneOrNil(1) {
+		state.CheckType(1, lua.FuncType)
+	}
+	h := &queue{index: make(map[lua.Value]int)}
+	if state.IsFunc(1) {
+		h.comp = state.CheckAny(1)
+	}
+	state.Push(h)
+	state.SetMetaTable(heapTypeName)
+	return 1
+}
+
+// heap:push (value [, priority])
+//
+// Inserts value into the heap with the given priority. If priority is absent,
+// value is its own priority.
+func heapPush(state *lua.State) int {
+	h := toHeap(state)
+	value := state.CheckAny(2)
+	prio := value
+	if !state.IsNoneOrNil(3) {
+		prio = state.CheckAny(3)
+	}
+	heap.Push(h, &entry{value: value, prio: prio})
+	return 0
+}
+
+// heap:pop ()
+//
+// Removes the first element from the heap and returns it together with its
+// priority. Returns nothing if the heap is empty.
+func heapPop(state *lua.State) int {
+	h := toHeap(state)
+	if h.Len() == 0 {
+		return 0
+	}
+	e := heap.Pop(h).(*entry)
+	state.Push(e.value)
+	state.Push(e.prio)
+	return 2
+}
+
+// heap:peek ()
+//
+// Returns the first element of the heap and its priority without removing it.
+// Returns nothing if the heap is empty.
+func heapPeek(state *lua.State) int {
+	h := toHeap(state)
+	if h.Len() == 0 {
+		return 0
+	}
+	state.Push(h.list[0].value)
+	state.Push(h.list[0].prio)
+	return 2
+}
+
+// heap:update (value, priority)
+//
+// Changes the priority of value, which must be in the heap, and restores the
+// heap order. Values are identified as table keys are, so a value pushed more
+// than once should not be updated or removed.
+func heapUpdate(state *lua.State) int {
+	h := toHeap(state)
+	i := h.find(state, 2)
+	h.list[i].prio = state.CheckAny(3)
+	heap.Fix(h, i)
+	return 0
+}
+
+// heap:remove (value)
+//
+// Removes value from the heap and returns its priority, or nil if value is
+// not in the heap.
+func heapRemove(state *lua.State) int {
+	h := toHeap(state)
+	i, ok := h.index[key(state.CheckAny(2))]
+	if !ok {
+		state.Push(nil)
+		return 1
+	}
+	state.Push(heap.Remove(h, i).(*entry).prio)
+	return 1
+}
+
+// heap:clear ()
+//
+// Removes all elements from the heap.
+func heapClear(state *lua.State) int {
+	h := toHeap(state)
+	h.list = nil
+	h.index = make(map[lua.Value]int)
+	return 0
+}
+
+func heapLen(state *lua.State) int {
+	state.Push(toHeap(state).Len())
+	return 1
+}
+
+func heapToString(state *lua.State) int {
+	h := toHeap(state)
+	state.Push(fmt.Sprintf("heap (%d): %p", h.Len(), h))
+	return 1
+}
+
+func toHeap(state *lua.State) *queue {
+	h := state.CheckUserData(1, heapTypeName).(*queue)
+	h.state = state
+	return h
+}
+
+// entry is a single element of a heap.
+type entry struct {
+	value lua.Value
+	prio  lua.Value
+}
+
+// key returns the key of the value v in the index of a heap: floats with an
+// integral value are converted to integers, as table keys are, so that 2 and
+// 2.0 are the same value.
+func key(v lua.Value) lua.Value {
+	if f, ok := v.(lua.Float); ok {
+		if x := float64(f); x >= math.MinInt64 && x < math.MaxInt64 && x == math.Trunc(x) {
+			return lua.Int(int64(x))
+		}
+	}
+	return v
+}
+
+// queue implements heap.Interface over the entries of a Lua heap.
+type queue struct {
+	state *lua.State // state of the current call
+	comp  lua.Value  // optional comparator
+	list  []*entry
+	index map[lua.Value]int
+}
+
+func (h *queue) find(state *lua.State, arg int) int {
+	i, ok := h.index[key(state.CheckAny(arg))]
+	if !ok {
+		state.ArgError(arg, "value not in heap")
+	}
+	return i
+}
+
+func (h *queue) Len() int { return len(h.list) }
+
+func (h *queue) Less(i, j int) bool {
+	state := h.state
+	if h.comp == nil {
+		state.Push(h.list[i].prio)
+		state.Push(h.list[j].prio)
+		less := state.Compare(lua.OpLt, -2, -1)
+		state.PopN(2)
+		return less
+	}
+	state.Push(h.comp)
+	state.Push(h.list[i].prio)
+	state.Push(h.list[j].prio)
+	state.Call(2, 1)
+	return lua.Truth(state.Pop())
+}
+
+func (h *queue) Swap(i, j int) {
+	h.list[i], h.list[j] = h.list[j], h.list[i]
+	h.index[key(h.list[i].value)] = i
+	h.index[key(h.list[j].value)] = j
+}
+
+func (h *queue) Push(x interface{}) {
+	e := x.(*entry)
+	h.index[key(e.value)] = len(h.list)
+	h.list = append(h.list, e)
+}
+
+func (h *queue) Pop() interface{} {
+	n := len(h.list) - 1
+	e := h.list[n]
+	h.list[n] = nil
+	h.list = h.list[:n]
+	if i, ok := h.index[key(e.value)]; ok && i == n {
+		delete(h.index, key(e.value))
+	}
+	return e
+}
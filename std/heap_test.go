@@ -0,0 +1,118 @@
+package std
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Azure/golua/lua"
+)
+
+// callMethod calls the method name of obj and returns its results as call does.
+func callMethod(t *testing.T, state *lua.State, obj lua.Value, name string, args ...interface{}) []interface{} {
+	t.Helper()
+	top := state.Top()
+	defer state.SetTop(top)
+	state.Push(obj)
+	state.GetField(-1, name)
+	state.Insert(-2)
+	for _, arg := range args {
+		state.Push(arg)
+	}
+	if err := state.PCall(1+len(args), lua.MultRets, 0); err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return results(state, top+1)
+}
+
+func TestHeap(t *testing.T) {
+	state := lua.NewState()
+	defer state.Close()
+	Open(state)
+	lib := require(t, state, "heap")
+
+	newHeap := func(args ...interface{}) lua.Value {
+		state.Push(lib)
+		state.GetField(-1, "new")
+		for _, arg := range args {
+			state.Push(arg)
+		}
+		state.Call(len(args), 1)
+		defer state.Pop()
+		return state.Pop()
+	}
+	pops := func(h lua.Value) (got []interface{}) {
+		for {
+			rets := callMethod(t, state, h, "pop")
+			if len(rets) == 0 {
+				return got
+			}
+			got = append(got, rets...)
+		}
+	}
+
+	h := newHeap()
+	for _, v := range []int{5, 1, 4, 1, 3} {
+		callMethod(t, state, h, "push", v)
+	}
+	if got := fmt.Sprint(callMethod(t, state, h, "peek")); got != "[1 1]" {
+		t.Errorf("peek = %s; want [1 1]", got)
+	}
+	if got := fmt.Sprint(pops(h)); got != "[1 1 1 1 3 3 4 4 5 5]" {
+		t.Errorf("pops = %s", got)
+	}
+
+	// Priorities, update and remove.
+	for _, e := range []struct {
+		value string
+		prio  int
+	}{{"a", 3}, {"b", 1}, {"c", 2}, {"d", 4}} {
+		callMethod(t, state, h, "push", e.value, e.prio)
+	}
+	callMethod(t, state, h, "update", "d", 0)
+	if got := fmt.Sprint(callMethod(t, state, h, "remove", "b")); got != "[1]" {
+		t.Errorf("remove('b') = %s; want [1]", got)
+	}
+	if got := fmt.Sprint(callMethod(t, state, h, "remove", "x")); got != "[nil]" {
+		t.Errorf("remove('x') = %s; want [nil]", got)
+	}
+	if got := fmt.Sprint(pops(h)); got != "[d 0 c 2 a 3]" {
+		t.Errorf("pops after update and remove = %s", got)
+	}
+
+	// Numbers are identified as table keys are.
+	callMethod(t, state, h, "push", 2, 5)
+	callMethod(t, state, h, "push", 3.0, 6)
+	callMethod(t, state, h, "update", 3, 1)
+	if got := fmt.Sprint(callMethod(t, state, h, "remove", 2.0)); got != "[5]" {
+		t.Errorf("remove(2.0) = %s; want [5]", got)
+	}
+	if got := fmt.Sprint(pops(h)); got != "[3 1]" {
+		t.Errorf("pops after numeric update and remove = %s", got)
+	}
+
+	// A comparator makes a max-heap.
+	h = newHeap(lua.Func(func(state *lua.State) int {
+		state.Push(state.Compare(lua.OpLt, 2, 1))
+		return 1
+	}))
+	for _, v := range []int{2, 9, 4} {
+		callMethod(t, state, h, "push", v)
+	}
+	if got := fmt.Sprint(callMethod(t, state, h, "__len")); got != "[3]" {
+		t.Errorf("#h = %s; want [3]", got)
+	}
+	if got := fmt.Sprint(pops(h)); got != "[9 9 4 4 2 2]" {
+		t.Errorf("max-heap pops = %s", got)
+	}
+
+	state.Push(h)
+	state.GetField(-1, "update")
+	state.Insert(-2)
+	state.Push("x")
+	state.Push(1)
+	if err := state.PCall(3, 0, 0); err == nil || !strings.HasSuffix(err.Error(), "(value not in heap)") {
+		t.Errorf("update of an absent value: error = %v", err)
+	}
+	state.SetTop(0)
+}
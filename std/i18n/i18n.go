@@ -0,0 +1,258 @@
+package i18n
+
+import (
+	"strings"
+
+	"github.com/Azure/golua/lua"
+)
+
+//
+// Lua Extension Library -- i18n
+//
+
+// Open opens the i18n library, which provides translation lookup with locale
+// fallback and CLDR plural rules:
+//
+//	local i18n = require "i18n"
+//	i18n.load("en", {
+//	    greeting = "Hello, %{name}!",
+//	    apples = {one = "%{count} apple", other = "%{count} apples"},
+//	})
+//	i18n.setlocale("en")
+//	print(i18n.t("apples", {count = 3})) --> 3 apples
+//
+// Each state has its own catalog of translations.
+//
+// The library is not opened by default; it is available through require "i18n".
+func Open(state *lua.State) int {
+	cat := &catalog{
+		locale: "en",
+		tables: make(map[string]map[string]message),
+	}
+	// Create 'i18n' table.
+	var i18nFuncs = map[string]lua.Func{
+		"getlocale":    lua.Func(cat.getLocale),
+		"load":         lua.Func(cat.load),
+		"plural":       lua.Func(i18nPlural),
+		"setfallbacks": lua.Func(cat.setFallbacks),
+		"setlocale":    lua.Func(cat.setLocale),
+		"t":            lua.Func(cat.translate),
+	}
+	state.NewTableSize(0, len(i18nFuncs))
+	state.SetFuncs(i18nFuncs, 0)
+
+	// Return 'i18n' table.
+	return 1
+}
+
+// message is a translation, either a plain text or a set of plural forms.
+type message struct {
+	text   string
+	plural map[string]string
+}
+
+// catalog holds the translations and locale settings of a state.
+type catalog struct {
+	locale    string
+	fallbacks []string
+	tables    map[string]map[string]message
+}
+
+// i18n.load (locale, translations)
+//
+// Adds the translations for locale, replacing existing keys. Nested tables are
+// flattened into dotted keys, so {menu = {open = "Open"}} defines "menu.open".
+// A table whose keys are all plural categories (zero, one, two, few, many and
+// other) defines the plural forms of a single message.
+func (cat *catalog) load(state *lua.State) int {
+	locale := state.CheckString(1)
+	state.CheckType(2, lua.TableType)
+	table, ok := cat.tables[locale]
+	if !ok {
+		table = make(map[string]message)
+		cat.tables[locale] = table
+	}
+	cat.loadTable(state, table, "")
+	return 0
+}
+
+// loadTable adds the entries of the table on top of the stack to table,
+// prefixing keys with prefix.
+func (cat *catalog) loadTable(state *lua.State, table map[string]message, prefix string) {
+	state.Push(nil)
+	for state.Next(-2) {
+		if state.TypeAt(-2) != lua.StringType {
+			state.Errorf("invalid translation key (%s)", state.TypeAt(-2))
+		}
+		key := prefix + state.ToString(-2)
+		switch state.TypeAt(-1) {
+		case lua.StringType, lua.NumberType:
+			table[key] = message{text: state.ToString(-1)}
+		case lua.TableType:
+			if forms, ok := pluralForms(state); ok {
+				table[key] = message{plural: forms}
+			} else {
+				cat.loadTable(state, table, key+".")
+			}
+		default:
+			state.Errorf("invalid translation for '%s' (%s)", key, state.TypeAt(-1))
+		}
+		state.Pop()
+	}
+}
+
+// pluralForms returns the plural forms of the table on top of the stack, or
+// false if it contains keys other than plural categories.
+func pluralForms(state *lua.State) (map[string]string, bool) {
+	forms := make(map[string]string)
+	for _, category := range pluralCategories {
+		if state.GetField(-1, category) == lua.StringType {
+			forms[category] = state.ToString(-1)
+		}
+		state.Pop()
+	}
+	count := 0
+	state.Push(nil)
+	for state.Next(-2) {
+		state.Pop()
+		count++
+	}
+	return forms, len(forms) > 0 && count == len(forms)
+}
+
+// i18n.setlocale (locale)
+//
+// Sets the current locale used by i18n.t.
+func (cat *catalog) setLocale(state *lua.State) int {
+	cat.locale = state.CheckString(1)
+	return 0
+}
+
+// i18n.getlocale ()
+//
+// Returns the current locale.
+func (cat *catalog) getLocale(state *lua.State) int {
+	state.Push(cat.locale)
+	return 1
+}
+
+// i18n.setfallbacks (locales)
+//
+// Sets the sequence of locales searched, in order, when a key is missing from
+// the requested locale and its parent locales (so "pt-BR" falls back to "pt"
+// before the configured fallbacks).
+func (cat *catalog) setFallbacks(state *lua.State) int {
+	state.CheckType(1, lua.TableType)
+	cat.fallbacks = cat.fallbacks[:0]
+	for i, n := 1, state.RawLen(1); i <= n; i++ {
+		state.RawGetIndex(1, i)
+		cat.fallbacks = append(cat.fallbacks, state.CheckString(-1))
+		state.Pop()
+	}
+	return 0
+}
+
+// i18n.t (key [, params [, locale]])
+//
+// Returns the translation of key in locale (default the current locale),
+// searching the fallback chain if necessary. Placeholders of the form %{name}
+// are replaced with the string value of params[name]. If the message has plural
+// forms, the form is chosen by params.count according to the plural rules of the
+// locale that provided the message.
+//
+// If no translation is found, returns key itself.
+func (cat *catalog) translate(state *lua.State) int {
+	key := state.CheckString(1)
+	if !state.IsNoneOrNil(2) {
+		state.CheckType(2, lua.TableType)
+	}
+	locale := state.OptString(3, cat.locale)
+
+	for _, loc := range cat.chain(locale) {
+		msg, ok := cat.tables[loc][key]
+		if !ok {
+			continue
+		}
+		text := msg.text
+		if msg.plural != nil {
+			var count float64
+			if !state.IsNoneOrNil(2) {
+				state.GetField(2, "count")
+				count = state.ToNumber(-1)
+				state.Pop()
+			}
+			if text, ok = msg.plural[pluralCategory(loc, count)]; !ok {
+				text = msg.plural[pluralOther]
+			}
+		}
+		state.Push(interpolate(state, text, 2))
+		return 1
+	}
+	state.Push(key)
+	return 1
+}
+
+// chain returns the locales to search for locale: the locale itself, its
+// parents and the configured fallbacks, without duplicates.
+func (cat *catalog) chain(locale string) (chain []string) {
+	seen := make(map[string]bool)
+	add := func(loc string) {
+		if !seen[loc] {
+			seen[loc] = true
+			chain = append(chain, loc)
+		}
+	}
+	for loc := locale; loc != ""; {
+		add(loc)
+		i := strings.LastIndexAny(loc, "-_")
+		if i < 0 {
+			break
+		}
+		loc = loc[:i]
+	}
+	for _, loc := range cat.fallbacks {
+		add(loc)
+	}
+	return chain
+}
+
+// interpolate replaces %{name} placeholders in text with the fields of the
+// params table at index params.
+func interpolate(state *lua.State, text string, params int) string {
+	if state.IsNoneOrNil(params) || !strings.Contains(text, "%{") {
+		return text
+	}
+	var b strings.Builder
+	for {
+		i := strings.Index(text, "%{")
+		if i < 0 {
+			break
+		}
+		j := strings.IndexByte(text[i:], '}')
+		if j < 0 {
+			break
+		}
+		b.WriteString(text[:i])
+		name := text[i+2 : i+j]
+		if state.GetField(params, name); state.IsNoneOrNil(-1) {
+			b.WriteString(text[i : i+j+1]) // leave unknown placeholders intact
+		} else {
+			b.WriteString(state.ToStringMeta(-1))
+			state.Pop()
+		}
+		state.Pop()
+		text = text[i+j+1:]
+	}
+	b.WriteString(text)
+	return b.String()
+}
+
+// i18n.plural (locale, n)
+//
+// Returns the CLDR plural category ("zero", "one", "two", "few", "many" or
+// "other") of the number n in locale.
+func i18nPlural(state *lua.State) int {
+	locale := state.CheckString(1)
+	state.Push(pluralCategory(locale, state.CheckNumber(2)))
+	return 1
+}
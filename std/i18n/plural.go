@@ -0,0 +1,175 @@
+package i18n
+
+import (
+	"math"
+	"strings"
+)
+
+// Plural categories as defined by the Unicode CLDR.
+const (
+	pluralZero  = "zero"
+	pluralOne   = "one"
+	pluralTwo   = "two"
+	pluralFew   = "few"
+	pluralMany  = "many"
+	pluralOther = "other"
+)
+
+var pluralCategories = []string{pluralZero, pluralOne, pluralTwo, pluralFew, pluralMany, pluralOther}
+
+// operands holds the CLDR plural operands of a number: n is the absolute
+// value, i its integer digits and v the number of visible fraction digits
+// (only whether it is zero matters here).
+type operands struct {
+	n float64
+	i int64
+	v int
+}
+
+func newOperands(x float64) operands {
+	x = math.Abs(x)
+	ops := operands{n: x, i: int64(x)}
+	if x != math.Trunc(x) {
+		ops.v = 1
+	}
+	return ops
+}
+
+// pluralRule maps the operands of a number to its plural category.
+type pluralRule func(operands) string
+
+// pluralRules holds the cardinal plural rules by base language. Languages not
+// listed use the English rule.
+var pluralRules = map[string]pluralRule{
+	"en": pluralRuleOneOther,
+	"de": pluralRuleOneOther,
+	"nl": pluralRuleOneOther,
+	"sv": pluralRuleOneOther,
+	"da": pluralRuleOneOther,
+	"no": pluralRuleOneOther,
+	"nb": pluralRuleOneOther,
+	"fi": pluralRuleOneOther,
+	"it": pluralRuleOneOther,
+	"es": pluralRuleOneOther,
+	"el": pluralRuleOneOther,
+	"hu": pluralRuleOneOther,
+	"tr": pluralRuleOneOther,
+	"fr": pluralRuleFrench,
+	"pt": pluralRuleFrench,
+	"ru": pluralRuleSlavic,
+	"uk": pluralRuleSlavic,
+	"be": pluralRuleSlavic,
+	"pl": pluralRulePolish,
+	"cs": pluralRuleCzech,
+	"sk": pluralRuleCzech,
+	"ar": pluralRuleArabic,
+	"ja": pluralRuleOther,
+	"zh": pluralRuleOther,
+	"ko": pluralRuleOther,
+	"vi": pluralRuleOther,
+	"th": pluralRuleOther,
+	"id": pluralRuleOther,
+	"ms": pluralRuleOther,
+}
+
+// pluralCategory returns the plural category of x in the given locale.
+func pluralCategory(locale string, x float64) string {
+	lang := strings.ToLower(locale)
+	if i := strings.IndexAny(lang, "-_"); i >= 0 {
+		lang = lang[:i]
+	}
+	rule, ok := pluralRules[lang]
+	if !ok {
+		rule = pluralRuleOneOther
+	}
+	return rule(newOperands(x))
+}
+
+// one: i = 1 and v = 0
+func pluralRuleOneOther(ops operands) string {
+	if ops.i == 1 && ops.v == 0 {
+		return pluralOne
+	}
+	return pluralOther
+}
+
+// one: i = 0,1
+func pluralRuleFrench(ops operands) string {
+	if ops.i == 0 || ops.i == 1 {
+		return pluralOne
+	}
+	return pluralOther
+}
+
+// one: v = 0 and i % 10 = 1 and i % 100 != 11
+// few: v = 0 and i % 10 = 2..4 and i % 100 != 12..14
+// many: v = 0 and (i % 10 = 0 or i % 10 = 5..9 or i % 100 = 11..14)
+func pluralRuleSlavic(ops operands) string {
+	if ops.v != 0 {
+		return pluralOther
+	}
+	mod10, mod100 := ops.i%10, ops.i%100
+	switch {
+	case mod10 == 1 && mod100 != 11:
+		return pluralOne
+	case mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14):
+		return pluralFew
+	}
+	return pluralMany
+}
+
+// one: i = 1 and v = 0
+// few: v = 0 and i % 10 = 2..4 and i % 100 != 12..14
+// many: v = 0 and (i != 1 and i % 10 = 0..1 or i % 10 = 5..9 or i % 100 = 12..14)
+func pluralRulePolish(ops operands) string {
+	if ops.v != 0 {
+		return pluralOther
+	}
+	mod10, mod100 := ops.i%10, ops.i%100
+	switch {
+	case ops.i == 1:
+		return pluralOne
+	case mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14):
+		return pluralFew
+	}
+	return pluralMany
+}
+
+// one: i = 1 and v = 0
+// few: i = 2..4 and v = 0
+// many: v != 0
+func pluralRuleCzech(ops operands) string {
+	switch {
+	case ops.v != 0:
+		return pluralMany
+	case ops.i == 1:
+		return pluralOne
+	case ops.i >= 2 && ops.i <= 4:
+		return pluralFew
+	}
+	return pluralOther
+}
+
+// zero: n = 0; one: n = 1; two: n = 2
+// few: n % 100 = 3..10; many: n % 100 = 11..99
+func pluralRuleArabic(ops operands) string {
+	if ops.v != 0 {
+		return pluralOther
+	}
+	switch mod100 := ops.i % 100; {
+	case ops.i == 0:
+		return pluralZero
+	case ops.i == 1:
+		return pluralOne
+	case ops.i == 2:
+		return pluralTwo
+	case mod100 >= 3 && mod100 <= 10:
+		return pluralFew
+	case mod100 >= 11:
+		return pluralMany
+	}
+	return pluralOther
+}
+
+// Languages without plural forms.
+func pluralRuleOther(operands) string { return pluralOther }
@@ -0,0 +1,40 @@
+package i18n
+
+import "testing"
+
+func TestPluralCategory(t *testing.T) {
+	var tests = []struct {
+		locale string
+		n      float64
+		want   string
+	}{
+		{"en", 1, "one"},
+		{"en", 0, "other"},
+		{"en", 1.5, "other"},
+		{"en-US", 2, "other"},
+		{"fr", 0, "one"},
+		{"fr", 1.5, "one"},
+		{"fr", 2, "other"},
+		{"ru", 1, "one"},
+		{"ru", 11, "many"},
+		{"ru", 22, "few"},
+		{"ru", 112, "many"},
+		{"ru", 2.5, "other"},
+		{"pl", 1, "one"},
+		{"pl", 21, "many"},
+		{"pl", 24, "few"},
+		{"cs", 3, "few"},
+		{"cs", 0.5, "many"},
+		{"ar", 0, "zero"},
+		{"ar", 2, "two"},
+		{"ar", 105, "few"},
+		{"ar", 111, "many"},
+		{"ja", 1, "other"},
+		{"xx", 1, "one"},
+	}
+	for _, test := range tests {
+		if got := pluralCategory(test.locale, test.n); got != test.want {
+			t.Errorf("pluralCategory(%q, %v) = %q; want %q", test.locale, test.n, got, test.want)
+		}
+	}
+}
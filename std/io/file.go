@@ -2,6 +2,7 @@ package io
 
 import (
 	"fmt"
+	"io"
 
 	"github.com/Azure/golua/lua"
 )
@@ -28,8 +29,7 @@ func fileClose(state *lua.State) int {
 //
 // See https://www.lua.org/manual/5.3/manual.html#pdf-file:flush
 func fileFlush(state *lua.State) int {
-	unimplemented("file:flush")
-	return 0
+	return flush(state, 1)
 }
 
 // file:lines (···)
@@ -48,8 +48,7 @@ func fileFlush(state *lua.State) int {
 //
 // See https://www.lua.org/manual/5.3/manual.html#pdf-file:lines
 func fileLines(state *lua.State) int {
-	unimplemented("file:lines")
-	return 0
+	return lines(state, 1, 2, false)
 }
 
 // file:read (···)
@@ -86,8 +85,11 @@ func fileLines(state *lua.State) int {
 //
 // See https://www.lua.org/manual/5.3/manual.html#pdf-file:read
 func fileRead(state *lua.State) int {
-	unimplemented("file:read")
-	return 0
+	n, err := read(state, toStreamAt(state, 1), 2)
+	if err != nil {
+		return state.FileResult(err, "")
+	}
+	return n
 }
 
 // file:seek ([whence [, offset]])
@@ -125,10 +127,17 @@ func fileSeek(state *lua.State) int {
 	if float64(offset) != arg2 {
 		panic(fmt.Errorf("bad argument #1 to 'seek' (not an integer in proper range)"))
 	}
-	ret, err := file.Seek(int64(whence), int(offset))
+	stream := toStream(state)
+	if stream.r != nil && whence == io.SeekCurrent {
+		offset -= int64(stream.r.Buffered()) // account for read-ahead
+	}
+	ret, err := file.Seek(offset, whence)
 	if err != nil {
 		return state.FileResult(err, "")
 	}
+	if stream.r != nil {
+		stream.r.Reset(file)
+	}
 	state.Push(ret)
 	return 1
 }
@@ -165,13 +174,19 @@ func fileSetvbuf(state *lua.State) int {
 //
 // See https://www.lua.org/manual/5.3/manual.html#pdf-file:write
 func fileWrite(state *lua.State) int {
-	unimplemented("file:write")
-	return 0
+	return write(state, 1, 2)
 }
 
+// file:__gc ()
+//
+// Closes the file if it is still open; lua.State.Close calls it for the files
+// a script left open.
+//
 // See https://www.lua.org/manual/5.3/manual.html#pdf-file:__gc
 func fileGC(state *lua.State) int {
-	unimplemented("file:__gc")
+	if stream := toStream(state); stream.close != nil && stream.file != nil {
+		closer(state) // ignore closed and incompletely open files
+	}
 	return 0
 }
 
@@ -180,7 +195,7 @@ func fileToString(state *lua.State) int {
 	if stream := toStream(state); stream.close == nil {
 		state.Push("file (closed)")
 	} else {
-		state.Push(fmt.Sprintf("file (%p)", stream.file))
+		state.Push(fmt.Sprintf("file (%p)", stream))
 	}
 	return 1
 }
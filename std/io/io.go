@@ -2,9 +2,11 @@ package io
 
 import (
 	"fmt"
+	"io"
 	"os"
 
 	"github.com/Azure/golua/lua"
+	luaos "github.com/Azure/golua/std/os"
 )
 
 //
@@ -25,6 +27,9 @@ import (
 // meanings from C: io.stdin, io.stdout, and io.stderr. The I/O library never
 // closes these files.
 //
+// Files are opened in the file system of the state, which embedders can replace
+// with a virtual or sandboxed one (see lua.WithFileSystem).
+//
 // Unless otherwise stated, all I/O functions return nil on failure (plus an error
 // message as a second result and a system-dependent error code as a third result)
 // and some value different from nil on success. In non-POSIX systems, the computation
@@ -52,9 +57,9 @@ func Open(state *lua.State) int {
 	createFileMetaTable(state)
 
 	// Create (and set) default standard files.
-	createStdFile(state, os.Stdin, "input", "stdin")
-	createStdFile(state, os.Stdout, "output", "stdout")
-	createStdFile(state, os.Stderr, "", "stderr")
+	createStdFile(state, os.Stdin, nil, "input", "stdin")
+	createStdFile(state, os.Stdout, output{state, false}, "output", "stdout")
+	createStdFile(state, os.Stderr, output{state, true}, "", "stderr")
 
 	// Return 'io' table.
 	return 1
@@ -81,8 +86,8 @@ func createFileMetaTable(state *lua.State) {
 }
 
 // createStdFile creates (and sets) the default standard files.
-func createStdFile(state *lua.State, file *os.File, field, fname string) {
-	newStream(state, file, lua.Func(noClose))
+func createStdFile(state *lua.State, file *os.File, w io.Writer, field, fname string) {
+	newStream(state, file, lua.Func(noClose)).w = w
 	if field != "" {
 		state.PushIndex(-1)
 		state.SetField(lua.RegistryIndex, field)
@@ -108,8 +113,8 @@ func ioClose(state *lua.State) int {
 //
 // See https://www.lua.org/manual/5.3/manual.html#pdf-io.flush
 func ioFlush(state *lua.State) int {
-	unimplemented("io.flush")
-	return 0
+	state.GetField(lua.RegistryIndex, "output")
+	return flush(state, state.Top())
 }
 
 // io.input ([file])
@@ -152,8 +157,17 @@ func ioOutput(state *lua.State) int {
 //
 // See https://www.lua.org/manual/5.3/manual.html#pdf-io.lines
 func ioLines(state *lua.State) int {
-	unimplemented("io.lines")
-	return 0
+	if state.IsNoneOrNil(1) { // no file name?
+		state.SetTop(1)
+		state.GetField(lua.RegistryIndex, "input") // use default input
+		state.Replace(1)
+		toStreamAt(state, 1) // check that it's open
+		return lines(state, 1, 2, false)
+	}
+	filename := state.CheckString(1)
+	newFile(state, mustOpen(state, filename, "r"))
+	state.Replace(1)
+	return lines(state, 1, 2, true)
 }
 
 // io.open (filename [, mode])
@@ -183,12 +197,12 @@ func ioOpen(state *lua.State) int {
 	if err != nil {
 		panic(fmt.Errorf("bad argument #2 to 'open' (invalid mode)"))
 	}
-	stream := newFile(state)
-	stream.file, err = os.OpenFile(filename, flags, 0666)
-	if err == nil {
-		return 1
+	file, err := state.FileSystem().OpenFile(filename, flags, 0666)
+	if err != nil {
+		return state.FileResult(err, filename)
 	}
-	return state.FileResult(err, filename)
+	newFile(state, file)
+	return 1
 }
 
 // io.popen (prog [, mode])
@@ -199,10 +213,48 @@ func ioOpen(state *lua.State) int {
 // can use to read data from this program (if mode is "r", the default) or to write
 // data to this program (if mode is "w").
 //
+// The program runs under the policy installed with os.SetExecPolicy; without
+// one, io.popen raises an error.
+//
 // See https://www.lua.org/manual/5.3/manual.html#pdf-io.popen
 func ioPopen(state *lua.State) int {
-	unimplemented("io.popen")
-	return 0
+	var (
+		prog = state.CheckString(1)
+		mode = state.OptString(2, "r")
+	)
+	state.ArgCheck(mode == "r" || mode == "w", 2, "invalid mode")
+	cmd, cancel, err := luaos.Command(state, prog)
+	if err != nil {
+		panic(fmt.Errorf("io.popen: %v", err))
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		cancel()
+		return state.FileResult(err, prog)
+	}
+	var file, other = r, w
+	if mode == "r" {
+		cmd.Stdout = w
+	} else {
+		file, other = w, r
+		cmd.Stdin = r
+		cmd.Stdout = state.Output()
+	}
+	cmd.Stderr = state.ErrorOutput()
+	if err := cmd.Start(); err != nil {
+		r.Close()
+		w.Close()
+		cancel()
+		return state.FileResult(err, prog)
+	}
+	other.Close() // the child's end of the pipe
+	newStream(state, file, lua.Func(func(state *lua.State) int {
+		file.Close()
+		defer cancel()
+		return state.ExecResult(cmd.Wait())
+	}))
+	state.CloseOnLeak(-1, file)
+	return 1
 }
 
 // io.read (···)
@@ -211,8 +263,14 @@ func ioPopen(state *lua.State) int {
 //
 // See https://www.lua.org/manual/5.3/manual.html#pdf-io.read
 func ioRead(state *lua.State) int {
-	unimplemented("io.read")
-	return 0
+	state.GetField(lua.RegistryIndex, "input")
+	stream := toStreamAt(state, -1)
+	state.Pop()
+	n, err := read(state, stream, 1)
+	if err != nil {
+		return state.FileResult(err, "")
+	}
+	return n
 }
 
 // io.tmpfile ()
@@ -253,8 +311,9 @@ func ioType(state *lua.State) int {
 //
 // See https://www.lua.org/manual/5.3/manual.html#pdf-io.write
 func ioWrite(state *lua.State) int {
-	unimplemented("io.write")
-	return 0
+	state.GetField(lua.RegistryIndex, "output")
+	state.Insert(1)
+	return write(state, 1, 2)
 }
 
 func unimplemented(msg string) { panic(fmt.Errorf(msg)) }
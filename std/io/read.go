@@ -0,0 +1,262 @@
+package io
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/golua/lua"
+)
+
+// maxNumeral is the maximum length of a numeral read by the "n" format.
+const maxNumeral = 200
+
+// maxFormats is the maximum number of formats given to lines.
+const maxFormats = 250
+
+// reader returns the buffered reader of the stream.
+func (stream *stream) reader() *bufio.Reader {
+	if stream.r == nil {
+		stream.r = bufio.NewReader(stream.file)
+	}
+	return stream.r
+}
+
+// read reads the stream according to the formats at first and following,
+// pushing one result per format up to the first failure, which is pushed as
+// nil. It returns the number of results, or an error if reading failed other
+// than by reaching the end of file.
+func read(state *lua.State, stream *stream, first int) (int, error) {
+	var (
+		r    = stream.reader()
+		last = state.Top()
+	)
+	if first > last { // no formats: read a line
+		state.Push("l")
+		last++
+	}
+	n := 0
+	for arg := first; arg <= last; arg++ {
+		ok, err := readFormat(state, r, arg)
+		if n++; err != nil {
+			return 0, err
+		}
+		if !ok {
+			break
+		}
+	}
+	return n, nil
+}
+
+// readFormat reads according to the format at arg and pushes the result, or
+// nil and false on failure.
+func readFormat(state *lua.State, r *bufio.Reader, arg int) (ok bool, err error) {
+	if state.IsNumber(arg) {
+		size := state.CheckInt(arg)
+		state.ArgCheck(size >= 0, arg, "invalid count")
+		if size == 0 { // test end of file
+			if _, err = r.Peek(1); err == nil {
+				state.Push("")
+				return true, nil
+			}
+		} else {
+			// Read through the buffer as the data comes rather than allocating
+			// size bytes up front, so a large count on a short file is cheap.
+			var (
+				b strings.Builder
+				n int64
+			)
+			if n, err = io.CopyN(&b, r, int64(size)); n > 0 {
+				state.Push(b.String())
+				return true, nil
+			}
+		}
+		return readFail(state, err)
+	}
+	format := state.CheckString(arg)
+	if strings.HasPrefix(format, "*") { // skip optional '*' (for compatibility)
+		format = format[1:]
+	}
+	switch {
+	case strings.HasPrefix(format, "n"):
+		var num interface{}
+		if num, err = readNumber(r); num != nil {
+			state.Push(num)
+			return true, nil
+		}
+		return readFail(state, err)
+	case strings.HasPrefix(format, "a"):
+		var b []byte
+		if b, err = io.ReadAll(r); err == nil {
+			state.Push(string(b))
+			return true, nil
+		}
+		return readFail(state, err)
+	case strings.HasPrefix(format, "l"), strings.HasPrefix(format, "L"):
+		var line string
+		if line, err = r.ReadString('\n'); line != "" {
+			if format[0] == 'l' {
+				line = strings.TrimSuffix(line, "\n")
+			}
+			state.Push(line)
+			return true, nil
+		}
+		return readFail(state, err)
+	}
+	state.ArgError(arg, "invalid format")
+	return false, nil
+}
+
+// readFail pushes nil for a failed format, and returns err unless it is the end
+// of file.
+func readFail(state *lua.State, err error) (bool, error) {
+	state.Push(nil)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	return false, err
+}
+
+// readNumber reads the longest prefix of r that may be a numeral, following
+// the lexical conventions of Lua, and returns it as an int64 or float64, or
+// nil if it is not a valid numeral.
+func readNumber(r *bufio.Reader) (interface{}, error) {
+	var (
+		b   strings.Builder
+		err error
+		c   byte
+	)
+	// skip whitespace
+	for {
+		if c, err = r.ReadByte(); err != nil {
+			return nil, err
+		}
+		if !strings.ContainsRune(" \t\n\v\f\r", rune(c)) {
+			break
+		}
+	}
+	r.UnreadByte()
+	accept := func(set string) bool {
+		if b.Len() >= maxNumeral {
+			return false
+		}
+		if c, err = r.ReadByte(); err != nil {
+			return false
+		}
+		if strings.IndexByte(set, c) < 0 {
+			r.UnreadByte()
+			return false
+		}
+		b.WriteByte(c)
+		return true
+	}
+	digits := func(hex bool) (n int) {
+		set := "0123456789"
+		if hex {
+			set += "abcdefABCDEF"
+		}
+		for accept(set) {
+			n++
+		}
+		return n
+	}
+	accept("+-")
+	hex, exp, n := false, "eE", 0
+	if accept("0") {
+		if hex = accept("xX"); hex {
+			exp = "pP"
+		} else {
+			n = 1
+		}
+	}
+	n += digits(hex)
+	if accept(".") {
+		n += digits(hex)
+	}
+	if n > 0 && accept(exp) {
+		accept("+-")
+		digits(false)
+	}
+	if err == io.EOF {
+		err = nil
+	}
+	if num, ok := parseNumber(b.String()); ok {
+		return num, err
+	}
+	return nil, err
+}
+
+// parseNumber converts the numeral s to an int64 or float64.
+func parseNumber(s string) (interface{}, bool) {
+	neg := strings.HasPrefix(s, "-")
+	digits := strings.TrimLeft(s, "+-")
+	hex := strings.HasPrefix(digits, "0x") || strings.HasPrefix(digits, "0X")
+	if hex && !strings.ContainsAny(digits, ".pP") {
+		u, err := strconv.ParseUint(digits[2:], 16, 64)
+		if err != nil && !isRange(err) {
+			return nil, false
+		}
+		if neg {
+			u = -u
+		}
+		return int64(u), true // hexadecimal integers wrap around
+	}
+	if !hex && !strings.ContainsAny(digits, ".eEnN") {
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return i, true
+		}
+	}
+	if hex && !strings.ContainsAny(digits, "pP") {
+		s += "p0" // Go requires an exponent in hexadecimal floats
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil && !isRange(err) {
+		return nil, false
+	}
+	return f, true
+}
+
+func isRange(err error) bool {
+	e, ok := err.(*strconv.NumError)
+	return ok && e.Err == strconv.ErrRange
+}
+
+// lines pushes an iterator reading the file at index with the formats from
+// first on. If toClose is true, the iterator closes the file when it reaches
+// the end of file or fails.
+func lines(state *lua.State, file, first int, toClose bool) int {
+	var (
+		stream  = toStreamAt(state, file)
+		handle  = state.CheckAny(file)
+		formats []lua.Value
+	)
+	for arg := first; arg <= state.Top(); arg++ {
+		formats = append(formats, state.CheckAny(arg))
+	}
+	state.ArgCheck(len(formats) <= maxFormats, maxFormats+first, "too many arguments")
+	state.Push(lua.Func(func(state *lua.State) int {
+		if stream.close == nil {
+			panic(fmt.Errorf("file is already closed"))
+		}
+		state.SetTop(0)
+		state.Push(handle)
+		for _, format := range formats {
+			state.Push(format)
+		}
+		n, err := read(state, stream, 2)
+		if err == nil && !state.IsNil(-n) {
+			return n
+		}
+		if toClose {
+			state.SetTop(1)
+			closer(state)
+		}
+		if err != nil {
+			panic(err)
+		}
+		return 0
+	}))
+	return 1
+}
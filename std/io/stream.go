@@ -1,6 +1,7 @@
 package io
 
 import (
+	"bufio"
 	"fmt"
 	"io"
 	"os"
@@ -16,28 +17,81 @@ var whence = map[string]int{
 }
 
 type stream struct {
-	file  *os.File
+	file  lua.File
+	w     io.Writer // writer of standard streams; file otherwise
+	r     *bufio.Reader
 	close lua.Func
 }
 
-func newStream(state *lua.State, file *os.File, close lua.Func) *stream {
+// writer returns the writer of the stream.
+func (stream *stream) writer() io.Writer {
+	if stream.w != nil {
+		return stream.w
+	}
+	return stream.file
+}
+
+// output is the writer of io.stdout and io.stderr, which follows the writers
+// set with State.SetOutput and State.SetErrorOutput.
+type output struct {
+	state *lua.State
+	err   bool
+}
+
+func (out output) Write(p []byte) (int, error) {
+	if out.err {
+		return out.state.ErrorOutput().Write(p)
+	}
+	return out.state.Output().Write(p)
+}
+
+// write writes the arguments from arg on to the file at index, and returns
+// the file.
+func write(state *lua.State, file, arg int) int {
+	w := toStreamAt(state, file).writer()
+	for top := state.Top(); arg <= top; arg++ {
+		if _, err := io.WriteString(w, state.CheckString(arg)); err != nil {
+			return state.FileResult(err, "")
+		}
+	}
+	state.PushIndex(file)
+	return 1
+}
+
+// flush flushes the file at index if its writer is buffered.
+func flush(state *lua.State, file int) int {
+	var err error
+	if f, ok := toStreamAt(state, file).writer().(interface{ Flush() error }); ok {
+		err = f.Flush()
+	}
+	if err != nil {
+		return state.FileResult(err, "")
+	}
+	state.PushIndex(file)
+	return 1
+}
+
+func newStream(state *lua.State, file lua.File, close lua.Func) *stream {
 	stream := &stream{file: file, close: close}
 	state.Push(stream)
 	state.SetMetaTable(fileTypeName)
 	return stream
 }
 
-func newFile(state *lua.State) *stream {
-	return newStream(state, nil, lua.Func(func(state *lua.State) int {
+// newFile pushes a handle for file, which is closed if the state leaks.
+func newFile(state *lua.State, file lua.File) *stream {
+	stream := newStream(state, file, lua.Func(func(state *lua.State) int {
 		err := toStream(state).file.Close()
 		return state.FileResult(err, "")
 	}))
+	state.CloseOnLeak(-1, file)
+	return stream
 }
 
-func mustOpen(state *lua.State, name, mode string) (file *os.File) {
+func mustOpen(state *lua.State, name, mode string) (file lua.File) {
 	flags, err := mode2flags(mode)
 	if err == nil {
-		file, err = os.OpenFile(name, flags, 0666)
+		file, err = state.FileSystem().OpenFile(name, flags, 0666)
 	}
 	if err != nil {
 		panic(fmt.Errorf("cannot open file '%s' (%s)", name, err.Error()))
@@ -48,7 +102,7 @@ func mustOpen(state *lua.State, name, mode string) (file *os.File) {
 func getOrSetStdFile(state *lua.State, file, mode string) int {
 	if !state.IsNoneOrNil(1) {
 		if name := state.ToString(1); name != "" {
-			newFile(state).file = mustOpen(state, name, mode)
+			newFile(state, mustOpen(state, name, mode))
 		} else {
 			toFile(state) // check that it's a valid file handle
 			state.PushIndex(1)
@@ -60,7 +114,7 @@ func getOrSetStdFile(state *lua.State, file, mode string) int {
 	return 1
 }
 
-func toFile(state *lua.State) *os.File {
+func toFile(state *lua.State) lua.File {
 	stream := toStream(state)
 	if stream.close == nil {
 		panic(fmt.Errorf("attempt to use a closed file"))
@@ -75,6 +129,15 @@ func toStream(state *lua.State) *stream {
 	return state.CheckUserData(1, fileTypeName).(*stream)
 }
 
+// toStreamAt returns the open file at index.
+func toStreamAt(state *lua.State, index int) *stream {
+	stream := state.CheckUserData(index, fileTypeName).(*stream)
+	if stream.close == nil {
+		panic(fmt.Errorf("attempt to use a closed file"))
+	}
+	return stream
+}
+
 func noClose(state *lua.State) int {
 	toStream(state).close = noClose
 	state.Push(nil)
@@ -86,7 +149,11 @@ func closer(state *lua.State) int {
 	stream := toStream(state)
 	closer := stream.close
 	stream.close = nil
-	return closer(state)
+	n := closer(state)
+	if stream.close == nil { // not a standard file
+		state.Finalized(1)
+	}
+	return n
 }
 
 func mode2flags(mode string) (int, error) {
@@ -0,0 +1,305 @@
+package std
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/Azure/golua/lua"
+	"github.com/Azure/golua/lua/binary"
+	"github.com/Azure/golua/lua/vm"
+	luaos "github.com/Azure/golua/std/os"
+)
+
+// memFS is an in-memory lua.FileSystem; files are saved when closed.
+type memFS map[string]string
+
+type memFile struct {
+	*bytes.Reader
+	buf  bytes.Buffer
+	save func(string)
+}
+
+func (f *memFile) Write(p []byte) (int, error) { return f.buf.Write(p) }
+
+func (f *memFile) Close() error {
+	if f.save != nil {
+		f.save(f.buf.String())
+	}
+	return nil
+}
+
+func (fs memFS) OpenFile(name string, flag int, perm os.FileMode) (lua.File, error) {
+	data, ok := fs[name]
+	if flag&os.O_CREATE == 0 && !ok {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	f := &memFile{Reader: bytes.NewReader([]byte(data))}
+	if flag&(os.O_WRONLY|os.O_RDWR) != 0 {
+		f.save = func(data string) { fs[name] = data }
+	}
+	return f, nil
+}
+
+func (fs memFS) Remove(name string) error {
+	if _, ok := fs[name]; !ok {
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrNotExist}
+	}
+	delete(fs, name)
+	return nil
+}
+
+func (fs memFS) Rename(oldname, newname string) error {
+	fs[newname] = fs[oldname]
+	return fs.Remove(oldname)
+}
+
+func TestFileSystem(t *testing.T) {
+	fs := memFS{"in.txt": "first\nsecond\n"}
+	state := lua.NewState(lua.WithFileSystem(fs))
+	defer state.Close()
+	Open(state)
+
+	call(t, state, "io", "output", "out.txt")
+	call(t, state, "io", "write", "hello ", int64(42))
+	call(t, state, "io", "close")
+	if got := fs["out.txt"]; got != "hello 42" {
+		t.Errorf("out.txt = %q; want %q", got, "hello 42")
+	}
+
+	call(t, state, "io", "input", "in.txt")
+	if got := fmt.Sprint(call(t, state, "io", "read", "l", "a")); got != "[first second\n]" {
+		t.Errorf("io.read = %s", got)
+	}
+
+	call(t, state, "os", "rename", "out.txt", "moved.txt")
+	call(t, state, "os", "remove", "in.txt")
+	if got := fmt.Sprintf("%d %s", len(fs), fs["moved.txt"]); got != "1 hello 42" {
+		t.Errorf("files after rename and remove: %v", fs)
+	}
+	if got := fmt.Sprint(call(t, state, "io", "open", "in.txt")); got != "[nil in.txt: open in.txt: file does not exist 0]" {
+		t.Errorf("io.open of a removed file = %s", got)
+	}
+}
+
+func TestFileSystemLibraries(t *testing.T) {
+	// return "m"
+	proto := binary.Prototype{
+		Source: "@m.lua",
+		Vararg: 1,
+		Stack:  2,
+		Consts: []interface{}{"m"},
+		Code: []uint32{
+			uint32(vm.ABx(vm.LOADK, 0, 0)),
+			uint32(vm.ABC(vm.RETURN, 0, 2, 0)),
+		},
+	}
+	fs := memFS{
+		"a.csv":  "x,y\n",
+		"c.json": `{"k": "v"}`,
+		"d.xml":  "<r/>",
+		"e.bin":  "\x2a",
+		"m.lua":  string(binary.Dump(&proto, false)),
+	}
+	state := lua.NewState(lua.WithFileSystem(fs))
+	defer state.Close()
+	Open(state)
+	state.GetGlobal("package")
+	state.Push("?.lua")
+	state.SetField(-2, "path")
+	state.Pop()
+
+	// field calls fn(arg) of the library lib, or the global fn, and returns
+	// the field path of its result, or its error.
+	field := func(lib, fn, arg string, path ...interface{}) string {
+		defer state.SetTop(0)
+		if lib == "" {
+			state.GetGlobal(fn)
+		} else {
+			state.GetGlobal("require")
+			state.Push(lib)
+			state.Call(1, 1)
+			state.GetField(-1, fn)
+		}
+		state.Push(arg)
+		if err := state.PCall(1, 2, 0); err != nil {
+			return err.Error()
+		}
+		if state.IsNil(-2) {
+			return state.ToString(-1)
+		}
+		state.Pop()
+		for _, key := range path {
+			switch key := key.(type) {
+			case string:
+				state.GetField(-1, key)
+			case int:
+				state.GetIndex(-1, int64(key))
+			case lua.Func: // method
+				state.Push(key)
+				state.Insert(-2)
+				state.Call(1, 1)
+			}
+		}
+		return state.ToString(-1)
+	}
+	call := func(state *lua.State) int { state.Call(0, 1); return 1 }
+	read := func(state *lua.State) int {
+		state.GetField(1, "read")
+		state.Insert(1)
+		state.Push("u8")
+		state.Push(0)
+		state.Call(3, 1)
+		return 1
+	}
+	for _, test := range []struct {
+		lib, fn, arg string
+		path         []interface{}
+		want         string
+	}{
+		{"csv", "open", "a.csv", []interface{}{lua.Func(call), 2}, "y"},
+		{"config", "load", "c.json", []interface{}{"k"}, "v"},
+		{"xml", "load", "d.xml", []interface{}{"tag"}, "r"},
+		{"data", "open", "e.bin", []interface{}{lua.Func(read)}, "42"},
+		{"", "dofile", "m.lua", nil, "m"},
+		{"", "loadfile", "m.lua", []interface{}{lua.Func(call)}, "m"},
+		{"", "require", "m", nil, "m"},
+	} {
+		if got := field(test.lib, test.fn, test.arg, test.path...); got != test.want {
+			t.Errorf("%s.%s(%q) gives %q; want %q", test.lib, test.fn, test.arg, got, test.want)
+		}
+	}
+
+	// Files of the host are out of reach.
+	file, err := ioutil.TempFile("", "host*.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+	host := file.Name()
+	for _, lib := range []string{"csv.open", "config.load", "xml.load", "data.open", ".dofile", ".loadfile"} {
+		names := strings.Split(lib, ".")
+		if got := field(names[0], names[1], host); !strings.Contains(got, "does not exist") {
+			t.Errorf("%s of a host file: %q; want does not exist", lib, got)
+		}
+	}
+}
+
+func TestIORead(t *testing.T) {
+	fs := memFS{"in.txt": "12 0x1F -3.5e1 nan\nline\nlast"}
+	state := lua.NewState(lua.WithFileSystem(fs))
+	defer state.Close()
+	Open(state)
+
+	call(t, state, "io", "input", "in.txt")
+	tests := []struct {
+		formats []interface{}
+		want    string
+	}{
+		{[]interface{}{"n", "n", "*n"}, "[12 31 -35]"},
+		{[]interface{}{"n"}, "[nil]"},              // "nan" is not a numeral
+		{[]interface{}{int64(2), "L"}, "[na n\n]"}, // the failed numeral is not consumed
+		{[]interface{}{int64(0), "l"}, "[ line]"},
+		{[]interface{}{int64(1 << 40)}, "[last]"}, // count larger than the file
+		{[]interface{}{int64(0)}, "[nil]"},        // end of file
+		{[]interface{}{"l"}, "[nil]"},
+		{[]interface{}{"a"}, "[]"},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprint(call(t, state, "io", "read", tt.formats...)); got != tt.want {
+			t.Errorf("io.read(%v) = %s; want %s", tt.formats, got, tt.want)
+		}
+	}
+
+	state.GetGlobal("io")
+	state.GetField(-1, "read")
+	state.Push(int64(-1))
+	err := state.PCall(1, lua.MultRets, 0)
+	if want := "bad argument #1 to 'io.read' (invalid count)"; err == nil || err.Error() != want {
+		t.Errorf("io.read(-1) = %v; want %q", err, want)
+	}
+}
+
+func TestIOPopen(t *testing.T) {
+	state := lua.NewState()
+	defer state.Close()
+	Open(state)
+
+	popen := func(args ...interface{}) error {
+		state.GetGlobal("io")
+		state.GetField(-1, "popen")
+		for _, arg := range args {
+			state.Push(arg)
+		}
+		return state.PCall(len(args), 1, 0)
+	}
+	if err, want := popen("printenv"), "io.popen: process execution is disabled"; err == nil || err.Error() != want {
+		t.Errorf("io.popen without a policy: error = %v; want %q", err, want)
+	}
+	luaos.SetExecPolicy(state, &luaos.ExecPolicy{Allow: []string{"printenv"}})
+	var tests = []struct {
+		args []interface{}
+		err  string
+	}{
+		{[]interface{}{"./printenv"}, "io.popen: program './printenv' is not allowed"},
+		{[]interface{}{"printenv 'PATH"}, "io.popen: unterminated quote or escape in command"},
+		{[]interface{}{"printenv", "rw"}, "bad argument #2 to 'io.popen' (invalid mode)"},
+	}
+	for _, tt := range tests {
+		if err := popen(tt.args...); err == nil || err.Error() != tt.err {
+			t.Errorf("io.popen%q: error = %v; want %q", tt.args, err, tt.err)
+		}
+		state.SetTop(0)
+	}
+
+	// The environment of the process starts empty.
+	os.Setenv("GOLUA_TEST_SECRET", "secret")
+	defer os.Unsetenv("GOLUA_TEST_SECRET")
+	if err := popen("printenv"); err != nil {
+		t.Fatal(err)
+	}
+	state.GetField(-1, "read")
+	state.PushIndex(-2)
+	state.Push("a")
+	if err := state.PCall(2, 1, 0); err != nil || state.ToString(-1) != "" {
+		t.Errorf("io.popen('printenv'):read('a') = %q, %v; want an empty environment", state.ToString(-1), err)
+	}
+	state.Pop()
+	file := state.Pop()
+	if got := fmt.Sprint(call(t, state, "io", "close", file)); got != "[boolean exit 0]" {
+		t.Errorf("io.close of the process = %s", got)
+	}
+}
+
+func TestOutput(t *testing.T) {
+	var (
+		states [2]*lua.State
+		outs   [2]bytes.Buffer
+	)
+	for i := range states {
+		states[i] = lua.NewState()
+		defer states[i].Close()
+		states[i].SetOutput(&outs[i])
+		Open(states[i])
+	}
+	for round := 1; round <= 2; round++ {
+		for i, state := range states {
+			call(t, state, "", "print", fmt.Sprintf("state%d", i), round)
+			call(t, state, "io", "write", "w", round, "\n")
+			state.GetGlobal("io")
+			state.GetField(-1, "stdout")
+			callMethod(t, state, state.Pop(), "write", "s\n")
+			state.Pop()
+		}
+	}
+	for i := range states {
+		want := fmt.Sprintf("state%d\t1\nw1\ns\nstate%d\t2\nw2\ns\n", i, i)
+		if got := outs[i].String(); got != want {
+			t.Errorf("output of state %d = %q; want %q", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package std
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Azure/golua/lua"
+)
+
+func TestLargeIndexes(t *testing.T) {
+	state := lua.NewState()
+	defer state.Close()
+	Open(state)
+
+	var tests = []struct {
+		lib, fn string
+		args    []interface{}
+		want    []interface{}
+	}{
+		{"string", "sub", []interface{}{"abc", 2, lua.MaxInt}, []interface{}{"bc"}},
+		{"string", "sub", []interface{}{"abc", lua.MinInt, 1}, []interface{}{"a"}},
+		{"string", "sub", []interface{}{"abc", int64(1) << 32, -1}, []interface{}{""}},
+		{"string", "byte", []interface{}{"abc", lua.MinInt, lua.MaxInt}, []interface{}{int64('a'), int64('b'), int64('c')}},
+		{"string", "find", []interface{}{"abc", "c", int64(1)<<32 + 1}, []interface{}{"nil"}},
+		{"string", "rep", []interface{}{"ab", 0, "x"}, []interface{}{""}},
+	}
+	for _, test := range tests {
+		got := call(t, state, test.lib, test.fn, test.args...)
+		if len(got) != len(test.want) {
+			t.Errorf("%s.%s%v = %q; want %q", test.lib, test.fn, test.args, got, test.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("%s.%s%v = %q; want %q", test.lib, test.fn, test.args, got, test.want)
+				break
+			}
+		}
+	}
+
+	for _, args := range [][]interface{}{
+		{"ab", lua.MaxInt/2 + 1},
+		{"xyz", lua.MaxInt / 2},
+		{"", lua.MaxInt, "xy"},
+	} {
+		state.GetGlobal("string")
+		state.GetField(-1, "rep")
+		for _, arg := range args {
+			state.Push(arg)
+		}
+		err := state.PCall(len(args), 1, 0)
+		if err == nil || !strings.Contains(err.Error(), "resulting string too large") {
+			t.Errorf("string.rep%v: error = %v; want resulting string too large", args, err)
+		}
+		state.SetTop(0)
+	}
+
+	// Integer keys beyond 32 bits do not alias small ones.
+	state.NewTable()
+	for i, key := range []int64{1, 1<<32 + 1, lua.MaxInt, lua.MinInt} {
+		state.Push(i + 1)
+		state.SetIndex(-2, key)
+	}
+	for i, key := range []int64{1, 1<<32 + 1, lua.MaxInt, lua.MinInt} {
+		if state.GetIndex(-1, key); state.ToInt(-1) != int64(i+1) {
+			t.Errorf("t[%d] = %d; want %d", key, state.ToInt(-1), i+1)
+		}
+		state.Pop()
+	}
+	if n := state.RawLen(-1); n != 1 {
+		t.Errorf("#t = %d; want 1", n)
+	}
+	list := state.Pop()
+
+	for _, test := range []struct {
+		i, j int64
+		want int
+	}{
+		{lua.MaxInt - 1, lua.MaxInt, 2},
+		{lua.MinInt, lua.MinInt + 1, 2},
+		{lua.MaxInt, lua.MinInt, 0},
+	} {
+		if got := len(call(t, state, "table", "unpack", list, test.i, test.j)); got != test.want {
+			t.Errorf("table.unpack(t, %d, %d) returned %d values; want %d", test.i, test.j, got, test.want)
+		}
+	}
+}
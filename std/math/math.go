@@ -1,9 +1,9 @@
 package math
 
 import (
-	"fmt"
 	"math"
 	"math/rand"
+	"time"
 
 	"github.com/Azure/golua/lua"
 )
@@ -18,36 +18,46 @@ import (
 // arguments. Rounding functions (math.ceil, math.floor, and math.modf) return an integer when the
 // result fits in the range of an integer, or a float otherwise.
 //
+// Each state has its own pseudo-random generator for math.random, seeded with
+// the time the library is opened, so that math.randomseed in one state does
+// not change the numbers drawn by another.
+//
 // See https://www.lua.org/manual/5.3/manual.html#6.7
 func Open(state *lua.State) int {
 	// Create 'math' table
 	var mathFuncs = map[string]lua.Func{
-		"abs":        lua.Func(mathAbs),
-		"acos":       lua.Func(mathAcos),
-		"asin":       lua.Func(mathAsin),
-		"atan":       lua.Func(mathAtan),
-		"ceil":       lua.Func(mathCeil),
-		"cos":        lua.Func(mathCos),
-		"deg":        lua.Func(mathDeg),
-		"exp":        lua.Func(mathExp),
-		"floor":      lua.Func(mathFloor),
-		"fmod":       lua.Func(mathFmod),
-		"log":        lua.Func(mathLog),
-		"max":        lua.Func(mathMax),
-		"min":        lua.Func(mathMin),
-		"modf":       lua.Func(mathModf),
-		"rad":        lua.Func(mathRad),
+		"abs":       lua.Func(mathAbs),
+		"acos":      lua.Func(mathAcos),
+		"asin":      lua.Func(mathAsin),
+		"atan":      lua.Func(mathAtan),
+		"ceil":      lua.Func(mathCeil),
+		"cos":       lua.Func(mathCos),
+		"deg":       lua.Func(mathDeg),
+		"exp":       lua.Func(mathExp),
+		"floor":     lua.Func(mathFloor),
+		"fmod":      lua.Func(mathFmod),
+		"log":       lua.Func(mathLog),
+		"max":       lua.Func(mathMax),
+		"min":       lua.Func(mathMin),
+		"modf":      lua.Func(mathModf),
+		"rad":       lua.Func(mathRad),
+		"sin":       lua.Func(mathSin),
+		"sqrt":      lua.Func(mathSqrt),
+		"tan":       lua.Func(mathTan),
+		"tointeger": lua.Func(mathToInt),
+		"type":      lua.Func(mathType),
+		"ult":       lua.Func(mathUlt),
+	}
+	state.NewTableSize(0, len(mathFuncs)+6)
+	state.SetFuncs(mathFuncs, 0)
+
+	// Set 'random' and 'randomseed' fields, which share the generator.
+	var randFuncs = map[string]lua.Func{
 		"random":     lua.Func(mathRand),
 		"randomseed": lua.Func(mathRandSeed),
-		"sin":        lua.Func(mathSin),
-		"sqrt":       lua.Func(mathSqrt),
-		"tan":        lua.Func(mathTan),
-		"tointeger":  lua.Func(mathToInt),
-		"type":       lua.Func(mathType),
-		"ult":        lua.Func(mathUlt),
 	}
-	state.NewTableSize(0, len(mathFuncs))
-	state.SetFuncs(mathFuncs, 0)
+	state.Push(rand.New(rand.NewSource(time.Now().UnixNano())))
+	state.SetFuncs(randFuncs, 1)
 
 	// Set 'pi' field.
 	state.Push(math.Pi)
@@ -58,11 +68,11 @@ func Open(state *lua.State) int {
 	state.SetField(-2, "huge") // A value larger than any other numeric value.
 
 	// Set 'maxinteger' field.
-	state.Push(math.MaxInt64)
+	state.Push(lua.Int(math.MaxInt64))
 	state.SetField(-2, "maxinteger") // An integer with the maximum value for an integer.
 
 	// Set 'mininteger' field.
-	state.Push(math.MinInt64)
+	state.Push(lua.Int(math.MinInt64))
 	state.SetField(-2, "mininteger") // An integer with the minimum value for an integer.
 
 	// Return 'math' table
@@ -135,16 +145,7 @@ func mathCeil(state *lua.State) int {
 		state.SetTop(1)
 		return 1
 	}
-	var (
-		num = state.CheckNumber(1)
-		f64 = math.Ceil(num)
-		i64 = int64(f64)
-	)
-	if float64(i64) == f64 {
-		state.Push(i64)
-	} else {
-		state.Push(f64)
-	}
+	pushNumInt(state, math.Ceil(state.CheckNumber(1)))
 	return 1
 }
 
@@ -192,16 +193,7 @@ func mathFloor(state *lua.State) int {
 		state.SetTop(1)
 		return 1
 	}
-	var (
-		num = state.CheckNumber(1)
-		f64 = math.Floor(num)
-		i64 = int64(f64)
-	)
-	if float64(i64) == f64 {
-		state.Push(i64)
-	} else {
-		state.Push(f64)
-	}
+	pushNumInt(state, math.Floor(state.CheckNumber(1)))
 	return 1
 }
 
@@ -215,7 +207,7 @@ func mathFmod(state *lua.State) int {
 	if state.IsInt(1) && state.IsInt(2) {
 		if d := state.CheckInt(2); uint64(d)+1 <= 1 {
 			if d == 0 {
-				panic(fmt.Errorf("bad argument to 'fmod' (zero)"))
+				state.ArgError(2, "zero")
 			}
 			state.Push(0)
 			return 1
@@ -238,9 +230,17 @@ func mathFmod(state *lua.State) int {
 //
 // See https://www.lua.org/manual/5.3/manual.html#pdf-math.log
 func mathLog(state *lua.State) int {
-	num := state.CheckNumber(1)
-	base := state.OptNumber(2, math.E)
-	state.Push(math.Log(num) / math.Log(base))
+	x := state.CheckNumber(1)
+	switch {
+	case state.IsNoneOrNil(2):
+		state.Push(math.Log(x))
+	case state.CheckNumber(2) == 2:
+		state.Push(math.Log2(x))
+	case state.CheckNumber(2) == 10:
+		state.Push(math.Log10(x))
+	default:
+		state.Push(math.Log(x) / math.Log(state.CheckNumber(2)))
+	}
 	return 1
 }
 
@@ -251,11 +251,11 @@ func mathLog(state *lua.State) int {
 // See https://www.lua.org/manual/5.3/manual.html#pdf-math.max
 func mathMax(state *lua.State) int {
 	if state.Top() < 1 {
-		panic(fmt.Errorf("bad argument to 'max' (value expected)"))
+		state.ArgError(1, "value expected")
 	}
 	var max int = 1
 	for i := 1; i <= state.Top(); i++ {
-		if state.Compare(lua.OpLt, max, i) { // i > max
+		if state.CheckNumber(i); state.Compare(lua.OpLt, max, i) { // i > max
 			max = i
 		}
 	}
@@ -270,11 +270,11 @@ func mathMax(state *lua.State) int {
 // See https://www.lua.org/manual/5.3/manual.html#pdf-math.min
 func mathMin(state *lua.State) int {
 	if state.Top() < 1 {
-		panic(fmt.Errorf("bad argument to 'min' (value expected)"))
+		state.ArgError(1, "value expected")
 	}
 	var min int = 1
 	for i := 1; i <= state.Top(); i++ {
-		if state.Compare(lua.OpLt, i, min) { // i < min
+		if state.CheckNumber(i); state.Compare(lua.OpLt, i, min) { // i < min
 			min = i
 		}
 	}
@@ -295,9 +295,7 @@ func mathModf(state *lua.State) int {
 	}
 	num := state.CheckNumber(1)
 	i, f := math.Modf(num)
-	if i64 := int64(i); float64(i64) == i {
-		state.Push(i64)
-	}
+	state.Push(i) // a float, like the fractional part
 	if math.IsInf(num, 0) {
 		state.Push(0.0)
 	} else {
@@ -326,16 +324,17 @@ func mathRad(state *lua.State) int {
 //
 // The call math.random(n) is equivalent to math.random(1,n).
 //
-// This function is an interface to the underling pseudo-random generator function provided by Go.
+// The numbers are drawn from the generator of the state (see Open).
 //
 // See https://www.lua.org/manual/5.3/manual.html#pdf-math.random
 func mathRand(state *lua.State) int {
 	var (
+		rng        = generator(state)
 		lo, hi, rn int64
 	)
 	switch argc := state.Top(); argc {
 	case 0:
-		state.Push(rand.Float64())
+		state.Push(rng.Float64())
 		return 1
 	case 1:
 		lo, hi = 1, state.CheckInt(1)
@@ -343,18 +342,18 @@ func mathRand(state *lua.State) int {
 		lo = state.CheckInt(1)
 		hi = state.CheckInt(2)
 	default:
-		panic(fmt.Errorf("wrong number of arguments"))
-	}
-	if lo < 0 && hi > math.MaxInt64+lo {
-		panic(fmt.Errorf("interval too large"))
+		state.Errorf("wrong number of arguments")
 	}
 	if lo > hi {
-		panic(fmt.Errorf("bad argument to 'random' (interval is empty)"))
+		state.ArgError(1, "interval is empty")
+	}
+	if lo < 0 && hi > math.MaxInt64+lo {
+		state.ArgError(1, "interval too large")
 	}
 	if hi-lo == math.MaxInt64 {
-		rn = rand.Int63() + lo
+		rn = rng.Int63() + lo
 	} else {
-		rn = rand.Int63n(hi-lo+1) + lo
+		rn = rng.Int63n(hi-lo+1) + lo
 	}
 	state.Push(rn)
 	return 1
@@ -366,10 +365,16 @@ func mathRand(state *lua.State) int {
 //
 // See https://www.lua.org/manual/5.3/manual.html#pdf-math.randomseed
 func mathRandSeed(state *lua.State) int {
-	rand.Seed(state.CheckInt(1))
+	generator(state).Seed(int64(state.CheckNumber(1)))
 	return 0
 }
 
+// generator returns the pseudo-random generator of the state, the upvalue of
+// math.random and math.randomseed.
+func generator(state *lua.State) *rand.Rand {
+	return state.ToUserData(lua.UpValueIndex(1)).Value().(*rand.Rand)
+}
+
 // math.sin (x)
 //
 // Returns the sine of x (assumed to be in radians).
@@ -412,6 +417,7 @@ func mathToInt(state *lua.State) int {
 	if i64, ok := state.TryInt(1); ok {
 		state.Push(i64)
 	} else {
+		state.CheckAny(1)
 		state.Push(nil)
 	}
 	return 1
@@ -448,3 +454,13 @@ func mathUlt(state *lua.State) int {
 	state.Push(m < n)
 	return 1
 }
+
+// pushNumInt pushes f as an integer if it fits in the range of integers, or
+// as a float otherwise.
+func pushNumInt(state *lua.State, f float64) {
+	if f >= -(1<<63) && f < 1<<63 {
+		state.Push(int64(f))
+	} else {
+		state.Push(f)
+	}
+}
@@ -0,0 +1,103 @@
+package std
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/Azure/golua/lua"
+)
+
+func TestMath(t *testing.T) {
+	state := lua.NewState()
+	defer state.Close()
+	Open(state)
+
+	// results returns the results of math.fn as "integer 3" or "float 3.5".
+	results := func(fn string, args ...interface{}) (string, error) {
+		defer state.SetTop(0)
+		state.GetGlobal("math")
+		state.GetField(-1, fn)
+		for _, arg := range args {
+			state.Push(arg)
+		}
+		if err := state.PCall(len(args), lua.MultRets, 0); err != nil {
+			return "", err
+		}
+		var s []string
+		for i := 2; i <= state.Top(); i++ {
+			switch {
+			case state.IsInt(i):
+				s = append(s, fmt.Sprintf("integer %d", state.ToInt(i)))
+			case state.TypeAt(i) == lua.NumberType:
+				s = append(s, fmt.Sprintf("float %g", state.ToNumber(i)))
+			default:
+				s = append(s, state.TypeAt(i).String())
+			}
+		}
+		return fmt.Sprint(s), nil
+	}
+	var tests = []struct {
+		fn   string
+		args []interface{}
+		want string // or the error message
+	}{
+		{"floor", []interface{}{3.7}, "[integer 3]"},
+		{"floor", []interface{}{-3.5}, "[integer -4]"},
+		{"floor", []interface{}{int64(5)}, "[integer 5]"},
+		{"floor", []interface{}{1e100}, "[float 1e+100]"},
+		{"ceil", []interface{}{3.2}, "[integer 4]"},
+		{"ceil", []interface{}{math.Inf(-1)}, "[float -Inf]"},
+		{"abs", []interface{}{int64(-3)}, "[integer 3]"},
+		{"abs", []interface{}{-2.5}, "[float 2.5]"},
+		{"fmod", []interface{}{int64(-7), int64(3)}, "[integer -1]"},
+		{"fmod", []interface{}{-7.5, 2.0}, "[float -1.5]"},
+		{"fmod", []interface{}{int64(1), int64(0)}, "bad argument #2 to 'math.fmod' (zero)"},
+		{"modf", []interface{}{3.5}, "[float 3 float 0.5]"},
+		{"modf", []interface{}{-3.5}, "[float -3 float -0.5]"},
+		{"modf", []interface{}{math.Inf(1)}, "[float +Inf float 0]"},
+		{"modf", []interface{}{int64(4)}, "[integer 4 float 0]"},
+		{"max", []interface{}{int64(1), 2.5, int64(2)}, "[float 2.5]"},
+		{"min", []interface{}{int64(1), 2.5, int64(-2)}, "[integer -2]"},
+		{"max", nil, "bad argument #1 to 'math.max' (value expected)"},
+		{"log", []interface{}{8.0, 2.0}, "[float 3]"},
+		{"log", []interface{}{1000.0, 10.0}, "[float 3]"},
+		{"tointeger", []interface{}{3.0}, "[integer 3]"},
+		{"tointeger", []interface{}{3.5}, "[nil]"},
+		{"type", []interface{}{int64(1)}, "[string]"},
+		{"ult", []interface{}{int64(1), int64(-1)}, "[boolean]"},
+		{"random", []interface{}{int64(3), int64(3)}, "[integer 3]"},
+		{"random", []interface{}{int64(2), int64(1)}, "bad argument #1 to 'math.random' (interval is empty)"},
+		{"random", []interface{}{int64(math.MinInt64), int64(0)}, "bad argument #1 to 'math.random' (interval too large)"},
+		{"random", []interface{}{int64(1), int64(2), int64(3)}, "wrong number of arguments"},
+	}
+	for _, test := range tests {
+		got, err := results(test.fn, test.args...)
+		if err != nil {
+			got = err.Error()
+		}
+		if got != test.want {
+			t.Errorf("math.%s%v = %s; want %s", test.fn, test.args, got, test.want)
+		}
+	}
+
+	// Equal seeds produce equal sequences, in each state.
+	other := lua.NewState()
+	defer other.Close()
+	Open(other)
+	draw := func(state *lua.State, seed int64) (s []int64) {
+		call(t, state, "math", "randomseed", seed)
+		for i := 0; i < 5; i++ {
+			s = append(s, call(t, state, "math", "random", 1000)[0].(int64))
+		}
+		return s
+	}
+	first := draw(state, 42)
+	call(t, other, "math", "randomseed", 7)
+	if got := draw(state, 42); fmt.Sprint(got) != fmt.Sprint(first) {
+		t.Errorf("math.random after randomseed(42) = %v; want %v", got, first)
+	}
+	if got := draw(other, 42); fmt.Sprint(got) != fmt.Sprint(first) {
+		t.Errorf("math.random in another state after randomseed(42) = %v; want %v", got, first)
+	}
+}
@@ -0,0 +1,136 @@
+package std
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Azure/golua/lua"
+)
+
+func TestProtectedMetaTables(t *testing.T) {
+	state := lua.NewState()
+	defer state.Close()
+	Open(state)
+
+	newTable := func(fields map[string]interface{}) lua.Value {
+		state.NewTable()
+		for k, v := range fields {
+			state.Push(v)
+			state.SetField(-2, k)
+		}
+		return state.Pop()
+	}
+	setmetatable := func(obj, meta lua.Value) error {
+		state.GetGlobal("setmetatable")
+		state.Push(obj)
+		state.Push(meta)
+		defer state.SetTop(0)
+		return state.PCall(2, 1, 0)
+	}
+
+	locked := newTable(nil)
+	if err := setmetatable(locked, newTable(map[string]interface{}{"__metatable": "locked"})); err != nil {
+		t.Fatal(err)
+	}
+	if got := call(t, state, "", "getmetatable", locked); len(got) != 1 || got[0] != "locked" {
+		t.Errorf("getmetatable = %v; want locked", got)
+	}
+	for _, meta := range []lua.Value{newTable(nil), nil} {
+		if err := setmetatable(locked, meta); err == nil || !strings.Contains(err.Error(), "cannot change a protected metatable") {
+			t.Errorf("setmetatable(locked, %v): error = %v; want cannot change a protected metatable", meta, err)
+		}
+	}
+
+	// Metatables of Go values are protected.
+	type entity struct{ ID int }
+	state.GetGlobal("getmetatable")
+	state.Push(&entity{ID: 1})
+	if err := state.PCall(1, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if state.TypeAt(-1) != lua.BoolType || state.ToBool(-1) {
+		t.Errorf("getmetatable(entity) = %v; want false", state.ToString(-1))
+	}
+}
+
+func TestTypeMethods(t *testing.T) {
+	state := lua.NewState()
+	defer state.Close()
+	Open(state, WithNumberMethods(true))
+
+	method := func(recv interface{}, name string) string {
+		defer state.SetTop(0)
+		state.Push(recv)
+		state.GetField(-1, name)
+		state.PushIndex(-2)
+		if err := state.PCall(1, 1, 0); err != nil {
+			t.Fatalf("%v:%s(): %v", recv, name, err)
+		}
+		return state.ToStringMeta(-1)
+	}
+	if got := method("abc", "upper"); got != "ABC" {
+		t.Errorf(`("abc"):upper() = %q; want "ABC"`, got)
+	}
+	if got := method(2.5, "floor"); got != "2" {
+		t.Errorf("(2.5):floor() = %q; want 2", got)
+	}
+
+	err := state.ExtendType(lua.BoolType, map[string]lua.Func{
+		"toint": func(state *lua.State) int {
+			if state.ToBool(1) {
+				state.Push(1)
+			} else {
+				state.Push(0)
+			}
+			return 1
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := method(true, "toint"); got != "1" {
+		t.Errorf("(true):toint() = %q; want 1", got)
+	}
+	if err := state.ExtendType(lua.StringType, map[string]lua.Func{"upper": nil}); err == nil {
+		t.Error("ExtendType replaced string.upper")
+	}
+	if err := state.ExtendType(lua.TableType, nil); err == nil {
+		t.Error("ExtendType extended tables")
+	}
+}
+
+func TestGoLua(t *testing.T) {
+	state := lua.NewState(lua.WithStackMax(5000))
+	defer state.Close()
+	Open(state, WithTableExt(true))
+
+	field := func(path ...string) string {
+		defer state.SetTop(0)
+		state.GetGlobal("_GOLUA")
+		for _, name := range path {
+			state.GetField(-1, name)
+		}
+		return state.ToString(-1)
+	}
+	var tests = []struct {
+		path []string
+		want string
+	}{
+		{[]string{"version"}, lua.GoLuaVersion},
+		{[]string{"lua"}, "Lua 5.3"},
+		{[]string{"extensions", "table"}, "true"},
+		{[]string{"extensions", "string"}, "false"},
+		{[]string{"libraries", "csv"}, "true"},
+		{[]string{"compat", "lua52"}, "false"},
+		{[]string{"limits", "intsize"}, "8"},
+		{[]string{"limits", "maxinteger"}, "9223372036854775807"},
+		{[]string{"limits", "maxstack"}, "5000"},
+		{[]string{"limits", "maxstring"}, fmt.Sprint(lua.MaxSize)},
+	}
+	for _, test := range tests {
+		if got := field(test.path...); got != test.want {
+			t.Errorf("_GOLUA.%s = %q; want %q", strings.Join(test.path, "."), got, test.want)
+		}
+	}
+}
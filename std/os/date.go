@@ -0,0 +1,186 @@
+package os
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Azure/golua/lua"
+)
+
+// maxDateField bounds the fields of a date table, as INT_MAX/2 does in loslib.c.
+const maxDateField = 1<<31/2 - 1
+
+// strftime appends t formatted according to format, with the conversions of
+// the ISO C function strftime in the C locale, including the E and O modifiers,
+// which do not change the conversion. It returns the first invalid
+// conversion specifier, if any.
+func strftime(b []byte, format string, t time.Time) ([]byte, string) {
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			b = append(b, format[i])
+			continue
+		}
+		spec := format[i+1:]
+		if len(spec) > 1 && (spec[0] == 'E' && strings.IndexByte("cCxXyY", spec[1]) >= 0 ||
+			spec[0] == 'O' && strings.IndexByte("deHImMSuUVwWy", spec[1]) >= 0) {
+			i++
+			spec = spec[1:]
+		}
+		if spec == "" {
+			return b, format[i:]
+		}
+		i++
+		var ok bool
+		if b, ok = conversion(b, spec[0], t); !ok {
+			return b, format[i-1 : i+1]
+		}
+	}
+	return b, ""
+}
+
+// conversion appends t formatted according to the conversion specifier c,
+// and reports whether c is valid.
+func conversion(b []byte, c byte, t time.Time) ([]byte, bool) {
+	num := func(n, width int, pad byte) []byte {
+		s := strconv.Itoa(n)
+		for i := len(s); i < width; i++ {
+			b = append(b, pad)
+		}
+		return append(b, s...)
+	}
+	switch c {
+	case 'a':
+		return append(b, t.Weekday().String()[:3]...), true
+	case 'A':
+		return append(b, t.Weekday().String()...), true
+	case 'b', 'h':
+		return append(b, t.Month().String()[:3]...), true
+	case 'B':
+		return append(b, t.Month().String()...), true
+	case 'c':
+		b, _ = strftime(b, "%a %b %e %H:%M:%S %Y", t)
+	case 'C':
+		return num(t.Year()/100, 2, '0'), true
+	case 'd':
+		return num(t.Day(), 2, '0'), true
+	case 'D', 'x':
+		b, _ = strftime(b, "%m/%d/%y", t)
+	case 'e':
+		return num(t.Day(), 2, ' '), true
+	case 'F':
+		b, _ = strftime(b, "%Y-%m-%d", t)
+	case 'g':
+		year, _ := t.ISOWeek()
+		return num(year%100, 2, '0'), true
+	case 'G':
+		year, _ := t.ISOWeek()
+		return num(year, 4, '0'), true
+	case 'H':
+		return num(t.Hour(), 2, '0'), true
+	case 'I':
+		return num((t.Hour()+11)%12+1, 2, '0'), true
+	case 'j':
+		return num(t.YearDay(), 3, '0'), true
+	case 'm':
+		return num(int(t.Month()), 2, '0'), true
+	case 'M':
+		return num(t.Minute(), 2, '0'), true
+	case 'n':
+		return append(b, '\n'), true
+	case 'p':
+		if t.Hour() < 12 {
+			return append(b, "AM"...), true
+		}
+		return append(b, "PM"...), true
+	case 'r':
+		b, _ = strftime(b, "%I:%M:%S %p", t)
+	case 'R':
+		b, _ = strftime(b, "%H:%M", t)
+	case 'S':
+		return num(t.Second(), 2, '0'), true
+	case 't':
+		return append(b, '\t'), true
+	case 'T', 'X':
+		b, _ = strftime(b, "%H:%M:%S", t)
+	case 'u':
+		return num((int(t.Weekday())+6)%7+1, 1, '0'), true
+	case 'U':
+		return num((t.YearDay()+6-int(t.Weekday()))/7, 2, '0'), true
+	case 'V':
+		_, week := t.ISOWeek()
+		return num(week, 2, '0'), true
+	case 'w':
+		return num(int(t.Weekday()), 1, '0'), true
+	case 'W':
+		return num((t.YearDay()+6-(int(t.Weekday())+6)%7)/7, 2, '0'), true
+	case 'y':
+		return num(t.Year()%100, 2, '0'), true
+	case 'Y':
+		return num(t.Year(), 4, '0'), true
+	case 'z':
+		return append(b, t.Format("-0700")...), true
+	case 'Z':
+		name, _ := t.Zone()
+		return append(b, name...), true
+	case '%':
+		return append(b, '%'), true
+	default:
+		return b, false
+	}
+	return b, true
+}
+
+// isDST reports whether daylight saving time is in effect at t, that is
+// whether its offset is ahead of the standard offset of its year.
+func isDST(t time.Time) bool {
+	_, offset := t.Zone()
+	_, jan := time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, t.Location()).Zone()
+	_, jul := time.Date(t.Year(), time.July, 1, 0, 0, 0, 0, t.Location()).Zone()
+	if jul < jan {
+		jan = jul
+	}
+	return offset > jan
+}
+
+// setAllFields sets the fields of the date table at the top of the stack to t.
+func setAllFields(state *lua.State, t time.Time) {
+	for _, field := range []struct {
+		name  string
+		value int
+	}{
+		{"year", t.Year()},
+		{"month", int(t.Month())},
+		{"day", t.Day()},
+		{"hour", t.Hour()},
+		{"min", t.Minute()},
+		{"sec", t.Second()},
+		{"yday", t.YearDay()},
+		{"wday", int(t.Weekday()) + 1},
+	} {
+		state.Push(field.value)
+		state.SetField(-2, field.name)
+	}
+	state.Push(isDST(t))
+	state.SetField(-2, "isdst")
+}
+
+// getField returns the integer field key of the date table at the top of the
+// stack, or def if it is absent and def is not negative.
+func getField(state *lua.State, key string, def int) int {
+	typ := state.GetField(-1, key)
+	defer state.Pop()
+	n, ok := state.TryInt(-1)
+	switch {
+	case !ok && typ != lua.NilType:
+		panic(fmt.Errorf("field '%s' is not an integer", key))
+	case !ok && def < 0:
+		panic(fmt.Errorf("field '%s' missing in date table", key))
+	case !ok:
+		return def
+	case n < -maxDateField || n > maxDateField:
+		panic(fmt.Errorf("field '%s' is out-of-bound", key))
+	}
+	return int(n)
+}
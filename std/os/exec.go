@@ -0,0 +1,150 @@
+package os
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/Azure/golua/lua"
+)
+
+// policyKey is the registry key of the state's ExecPolicy.
+const policyKey = "os.exec.policy"
+
+// ExecPolicy controls which processes scripts may start with os.execute and
+// io.popen. Process execution is disabled unless the embedder installs a policy
+// with SetExecPolicy.
+//
+// Commands are not passed to a shell: they are split into words, honouring
+// single and double quotes and backslash escapes, and the first word names the
+// program to run.
+type ExecPolicy struct {
+	// Allow lists the programs that may be run, each matched exactly against
+	// the first word of the command: a bare name (e.g. "git") is looked up
+	// in the host's PATH. An empty list allows no programs.
+	Allow []string
+	// Env is the environment of started processes. If nil, processes start
+	// with an empty environment.
+	Env []string
+	// Dir is the working directory of started processes. If empty, the
+	// working directory of the host is used.
+	Dir string
+	// Timeout, if positive, is the time after which a started process is
+	// killed.
+	Timeout time.Duration
+}
+
+// SetExecPolicy installs the process execution policy of the state; a nil
+// policy disables process execution.
+func SetExecPolicy(state *lua.State, policy *ExecPolicy) {
+	if policy == nil {
+		state.Push(nil)
+	} else {
+		state.Push(policy)
+	}
+	state.SetField(lua.RegistryIndex, policyKey)
+}
+
+// GetExecPolicy returns the process execution policy of the state, or nil if
+// process execution is disabled.
+func GetExecPolicy(state *lua.State) *ExecPolicy {
+	defer state.Pop()
+	if state.GetField(lua.RegistryIndex, policyKey) == lua.UserDataType {
+		if policy, ok := state.ToUserData(-1).Value().(*ExecPolicy); ok {
+			return policy
+		}
+	}
+	return nil
+}
+
+// Command returns the command to run the given command line under the state's
+// policy, and a function releasing its resources that must be called once the
+// command has completed. It fails if process execution is disabled or the
+// program is not allowed.
+func Command(state *lua.State, line string) (*exec.Cmd, context.CancelFunc, error) {
+	policy := GetExecPolicy(state)
+	if policy == nil {
+		return nil, nil, fmt.Errorf("process execution is disabled")
+	}
+	args, err := splitCommand(line)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(args) == 0 {
+		return nil, nil, fmt.Errorf("empty command")
+	}
+	if !policy.allows(args[0]) {
+		return nil, nil, fmt.Errorf("program '%s' is not allowed", args[0])
+	}
+	var (
+		ctx    = context.Background()
+		cancel = context.CancelFunc(func() {})
+	)
+	if policy.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, policy.Timeout)
+	}
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Env = policy.Env
+	if cmd.Env == nil {
+		cmd.Env = []string{}
+	}
+	cmd.Dir = policy.Dir
+	return cmd, cancel, nil
+}
+
+// allows reports whether the policy allows running the program.
+func (policy *ExecPolicy) allows(program string) bool {
+	// Names must match exactly, so that "./git" does not pass for "git".
+	for _, allowed := range policy.Allow {
+		if program == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// splitCommand splits the command line into words.
+func splitCommand(line string) (args []string, err error) {
+	var (
+		word  strings.Builder
+		quote rune
+		inArg bool
+		esc   bool
+	)
+	for _, r := range line {
+		switch {
+		case esc:
+			word.WriteRune(r)
+			esc = false
+		case r == '\\' && quote != '\'':
+			esc, inArg = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inArg = r, true
+		case unicode.IsSpace(r):
+			if inArg {
+				args = append(args, word.String())
+				word.Reset()
+				inArg = false
+			}
+		default:
+			word.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 || esc {
+		return nil, fmt.Errorf("unterminated quote or escape in command")
+	}
+	if inArg {
+		args = append(args, word.String())
+	}
+	return args, nil
+}
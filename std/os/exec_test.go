@@ -0,0 +1,102 @@
+package os
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Azure/golua/lua"
+)
+
+func TestSplitCommand(t *testing.T) {
+	var tests = []struct {
+		line string
+		want []string
+		err  bool
+	}{
+		{"", nil, false},
+		{"  git  status ", []string{"git", "status"}, false},
+		{`echo 'a b' "c d"`, []string{"echo", "a b", "c d"}, false},
+		{`echo 'it\'s'`, nil, true}, // no escapes in single quotes
+		{`echo "say \"hi\""`, []string{"echo", `say "hi"`}, false},
+		{`echo a\ b \$HOME`, []string{"echo", "a b", "$HOME"}, false},
+		{`echo '' ""`, []string{"echo", "", ""}, false},
+		{`echo a'b'"c"`, []string{"echo", "abc"}, false},
+		{`echo 'a;b' && rm -rf /`, []string{"echo", "a;b", "&&", "rm", "-rf", "/"}, false},
+		{`echo "unterminated`, nil, true},
+		{`echo 'unterminated`, nil, true},
+		{`echo trailing\`, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := splitCommand(tt.line)
+		if (err != nil) != tt.err || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitCommand(%q) = %q, %v; want %q, error %t", tt.line, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestExecPolicyAllows(t *testing.T) {
+	var tests = []struct {
+		allow   []string
+		program string
+		want    bool
+	}{
+		{nil, "git", false},
+		{[]string{"git"}, "git", true},
+		{[]string{"git"}, "./git", false},
+		{[]string{"git"}, "/usr/bin/git", false},
+		{[]string{"git"}, "Git", false},
+		{[]string{"git"}, "git2", false},
+		{[]string{"/usr/bin/git"}, "/usr/bin/git", true},
+		{[]string{"/usr/bin/git"}, "git", false},
+		{[]string{"ls", "git"}, "git", true},
+	}
+	for _, tt := range tests {
+		policy := &ExecPolicy{Allow: tt.allow}
+		if got := policy.allows(tt.program); got != tt.want {
+			t.Errorf("ExecPolicy{Allow: %q}.allows(%q) = %t; want %t", tt.allow, tt.program, got, tt.want)
+		}
+	}
+}
+
+func TestCommand(t *testing.T) {
+	state := lua.NewState()
+	defer state.Close()
+
+	if _, _, err := Command(state, "true"); err == nil || err.Error() != "process execution is disabled" {
+		t.Errorf("Command without a policy: error = %v", err)
+	}
+	SetExecPolicy(state, &ExecPolicy{Allow: []string{"true"}})
+	var tests = []struct {
+		line string
+		err  string
+	}{
+		{"true --flag", ""},
+		{"  ", "empty command"},
+		{"./true", "program './true' is not allowed"},
+		{"sh -c true", "program 'sh' is not allowed"},
+		{"'true", "unterminated quote or escape in command"},
+	}
+	for _, tt := range tests {
+		cmd, cancel, err := Command(state, tt.line)
+		if err != nil {
+			if err.Error() != tt.err {
+				t.Errorf("Command(%q): error = %v; want %q", tt.line, err, tt.err)
+			}
+			continue
+		}
+		cancel()
+		if tt.err != "" {
+			t.Errorf("Command(%q) succeeded; want %q", tt.line, tt.err)
+		}
+		if cmd.Env == nil || len(cmd.Env) != 0 {
+			t.Errorf("Command(%q).Env = %q; want an empty environment", tt.line, cmd.Env)
+		}
+		if want := []string{"true", "--flag"}; !reflect.DeepEqual(cmd.Args, want) {
+			t.Errorf("Command(%q).Args = %q; want %q", tt.line, cmd.Args, want)
+		}
+	}
+	SetExecPolicy(state, nil)
+	if GetExecPolicy(state) != nil {
+		t.Errorf("GetExecPolicy after SetExecPolicy(nil) is not nil")
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/Azure/golua/lua"
@@ -13,29 +15,59 @@ import (
 // Lua Standard Library -- os
 //
 
+// Options restricts the facilities of the OS library for scripts that must not
+// act on the host; see OpenWith. The zero Options restricts nothing.
+type Options struct {
+	// DisableExit, DisableRemove and DisableRename leave os.exit, os.remove
+	// and os.rename out of the library.
+	DisableExit   bool
+	DisableRemove bool
+	DisableRename bool
+
+	// Getenv, if not nil, looks up the environment variables read by
+	// os.getenv instead of the environment of the process, for example to
+	// only expose some of them.
+	Getenv func(name string) (value string, ok bool)
+}
+
 // Open opens the Lua standard OS library. This library provides operating system facilities.
 //
 // See https://www.lua.org/manual/5.3/manual.html#6.9
-func Open(state *lua.State) int {
-	// Create 'os' table
-	var osFuncs = map[string]lua.Func{
-		"clock":     lua.Func(osClock),
-		"date":      lua.Func(osDate),
-		"difftime":  lua.Func(osDiffTime),
-		"execute":   lua.Func(osExecute),
-		"exit":      lua.Func(osExit),
-		"getenv":    lua.Func(osGetEnv),
-		"remove":    lua.Func(osRemove),
-		"rename":    lua.Func(osRename),
-		"setlocale": lua.Func(osSetLocale),
-		"time":      lua.Func(osTime),
-		"tmpname":   lua.Func(osTmpName),
-	}
-	state.NewTableSize(0, len(osFuncs))
-	state.SetFuncs(osFuncs, 0)
+func Open(state *lua.State) int { return OpenWith(Options{})(state) }
 
-	// Return 'os' table
-	return 1
+// OpenWith returns a function that opens the OS library restricted by opts,
+// for Require or std.WithOS.
+func OpenWith(opts Options) lua.Func {
+	return func(state *lua.State) int {
+		// Create 'os' table
+		var osFuncs = map[string]lua.Func{
+			"clock":     lua.Func(osClock),
+			"date":      lua.Func(osDate),
+			"difftime":  lua.Func(osDiffTime),
+			"execute":   lua.Func(osExecute),
+			"exit":      lua.Func(osExit),
+			"getenv":    osGetEnv(opts.Getenv),
+			"remove":    lua.Func(osRemove),
+			"rename":    lua.Func(osRename),
+			"setlocale": lua.Func(osSetLocale),
+			"time":      lua.Func(osTime),
+			"tmpname":   lua.Func(osTmpName),
+		}
+		if opts.DisableExit {
+			delete(osFuncs, "exit")
+		}
+		if opts.DisableRemove {
+			delete(osFuncs, "remove")
+		}
+		if opts.DisableRename {
+			delete(osFuncs, "rename")
+		}
+		state.NewTableSize(0, len(osFuncs))
+		state.SetFuncs(osFuncs, 0)
+
+		// Return 'os' table
+		return 1
+	}
 }
 
 // os.clock ()
@@ -44,7 +76,13 @@ func Open(state *lua.State) int {
 //
 // See https://www.lua.org/manual/5.3/manual.html#pdf-os.clock
 func osClock(state *lua.State) int {
-	state.Push(time.Since(epoch).Seconds())
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		state.Push(time.Since(epoch).Seconds())
+		return 1
+	}
+	cpu := time.Duration(usage.Utime.Nano() + usage.Stime.Nano())
+	state.Push(cpu.Seconds())
 	return 1
 }
 
@@ -71,8 +109,25 @@ func osClock(state *lua.State) int {
 //
 // See https://www.lua.org/manual/5.3/manual.html#pdf-os.date
 func osDate(state *lua.State) int {
-	unimplemented("os.date")
-	return 0
+	format := state.OptString(1, "%c")
+	t := time.Now()
+	if !state.IsNoneOrNil(2) {
+		t = time.Unix(state.CheckInt(2), 0)
+	}
+	if strings.HasPrefix(format, "!") {
+		format, t = format[1:], t.UTC()
+	}
+	if strings.HasPrefix(format, "*t") {
+		state.NewTableSize(0, 9)
+		setAllFields(state, t)
+		return 1
+	}
+	b, invalid := strftime(nil, format, t)
+	if invalid != "" {
+		state.ArgError(1, fmt.Sprintf("invalid conversion specifier '%s'", invalid))
+	}
+	state.Push(string(b))
+	return 1
 }
 
 // os.difftime (t2, t1)
@@ -82,8 +137,8 @@ func osDate(state *lua.State) int {
 //
 // See https://www.lua.org/manual/5.3/manual.html#pdf-os.difftime
 func osDiffTime(state *lua.State) int {
-	unimplemented("os.difftime")
-	return 0
+	state.Push(float64(state.CheckInt(1) - state.OptInt(2, 0)))
+	return 1
 }
 
 // os.execute ([command])
@@ -97,10 +152,23 @@ func osDiffTime(state *lua.State) int {
 //
 // When called without a command, os.execute returns a boolean that is true if a shell is available.
 //
+// Commands run under the policy installed with SetExecPolicy; without one,
+// os.execute() returns false and running a command raises an error.
+//
 // See https://www.lua.org/manual/5.3/manual.html#pdf-os.execute
 func osExecute(state *lua.State) int {
-	unimplemented("os.execute")
-	return 0
+	if state.IsNoneOrNil(1) {
+		state.Push(GetExecPolicy(state) != nil)
+		return 1
+	}
+	cmd, cancel, err := Command(state, state.CheckString(1))
+	if err != nil {
+		panic(fmt.Errorf("os.execute: %v", err))
+	}
+	defer cancel()
+	cmd.Stdout = state.Output()
+	cmd.Stderr = state.ErrorOutput()
+	return state.ExecResult(cmd.Run())
 }
 
 // os.exit ([code [, close]])
@@ -111,6 +179,8 @@ func osExecute(state *lua.State) int {
 //
 // If the optional second argument close is true, closes the Lua state before exiting.
 //
+// Embedders that must not be terminated by scripts leave it out with Options.DisableExit.
+//
 // See https://www.lua.org/manual/5.3/manual.html#pdf-os.exit
 func osExit(state *lua.State) int {
 	var code int
@@ -132,15 +202,21 @@ func osExit(state *lua.State) int {
 //
 // Returns the value of the process environment variable varname, or nil if the variable is not defined.
 //
+// Variables are looked up with Options.Getenv, if set.
+//
 // See https://www.lua.org/manual/5.3/manual.html#pdf-os.getenv
-func osGetEnv(state *lua.State) int {
-	env := os.Getenv(state.CheckString(1))
-	if env == "" {
-		state.Push(nil)
-	} else {
-		state.Push(env)
+func osGetEnv(getenv func(string) (string, bool)) lua.Func {
+	if getenv == nil {
+		getenv = os.LookupEnv
+	}
+	return func(state *lua.State) int {
+		if env, ok := getenv(state.CheckString(1)); ok {
+			state.Push(env)
+		} else {
+			state.Push(nil)
+		}
+		return 1
 	}
-	return 1
 }
 
 // os.remove (filename)
@@ -148,9 +224,11 @@ func osGetEnv(state *lua.State) int {
 // Deletes the file (or empty directory, on POSIX systems) with the given name. If this function fails, it returns nil,
 // plus a string describing the error and the error code. Otherwise, it returns true.
 //
+// Files are deleted from the file system of the state (see lua.WithFileSystem).
+//
 // See https://www.lua.org/manual/5.3/manual.html#pdf-os.remove
 func osRemove(state *lua.State) int {
-	if err := os.Remove(state.CheckString(1)); err != nil {
+	if err := state.FileSystem().Remove(state.CheckString(1)); err != nil {
 		state.Push(nil)
 		state.Push(err.Error())
 		return 2
@@ -164,13 +242,15 @@ func osRemove(state *lua.State) int {
 // Renames the file or directory named oldname to newname. If this function fails, it returns nil, plus a string describing
 // the error and the error code. Otherwise, it returns true.
 //
+// Files are renamed in the file system of the state (see lua.WithFileSystem).
+//
 // See https://www.lua.org/manual/5.3/manual.html#pdf-os.rename
 func osRename(state *lua.State) int {
 	var (
 		oldname = state.CheckString(1)
 		newname = state.CheckString(2)
 	)
-	if err := os.Rename(oldname, newname); err != nil {
+	if err := state.FileSystem().Rename(oldname, newname); err != nil {
 		state.Push(nil)
 		state.Push(err.Error())
 		return 2
@@ -217,24 +297,19 @@ func osTime(state *lua.State) int {
 		state.Push(time.Now().Unix())
 		return 1
 	}
-	// state.CheckType(1, lua.TableType)
-	// state.SetTop(1)
-	// var (
-	//		sec   =
-	//		min   =
-	//		day   =
-	//		hour  =
-	//		year  =
-	//		month = time.Month(...)
-	//		isdst =
-	// )
-	// date := time.Date(year, month, day, hour, min, sec, 0, time.Local)
-	// state.Push(date.Unix())
-	//
-	// time.Date(year int, month Month, day, hour, min, sec, nsec int, loc *Location) Time
-
-	fmt.Println("os.time: TODO")
-	state.Debug(true)
+	state.CheckType(1, lua.TableType)
+	state.SetTop(1) // make sure table is at the top
+	t := time.Date(
+		getField(state, "year", -1),
+		time.Month(getField(state, "month", -1)),
+		getField(state, "day", -1),
+		getField(state, "hour", 12),
+		getField(state, "min", 0),
+		getField(state, "sec", 0),
+		0, time.Local,
+	)
+	setAllFields(state, t) // update fields with normalized values
+	state.Push(t.Unix())
 	return 1
 }
 
@@ -256,12 +331,9 @@ func osTmpName(state *lua.State) int {
 		panic(fmt.Errorf("unable to generate a unique filename"))
 	}
 	tmp.Close()
-	os.Remove(tmp.Name())
 	state.Push(tmp.Name())
 	return 1
 }
 
 var epoch time.Time // start time.
 func init()         { epoch = time.Now() }
-
-func unimplemented(msg string) { panic(fmt.Errorf(msg)) }
@@ -0,0 +1,95 @@
+package std
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Azure/golua/lua"
+	luaos "github.com/Azure/golua/std/os"
+)
+
+func TestOSDate(t *testing.T) {
+	state := lua.NewState()
+	defer state.Close()
+	Open(state)
+
+	// 2001-08-23 14:55:02 UTC, a Thursday
+	at := time.Date(2001, 8, 23, 14, 55, 2, 0, time.UTC).Unix()
+	for format, want := range map[string]string{
+		"!%c":                   "Thu Aug 23 14:55:02 2001",
+		"!%Y-%m-%d %H:%M:%S":    "2001-08-23 14:55:02",
+		"!%a %A %b %B %h":       "Thu Thursday Aug August Aug",
+		"!%C %y %D %F %e":       "20 01 08/23/01 2001-08-23 23",
+		"!%I %p %r %R %T":       "02 PM 02:55:02 PM 14:55 14:55:02",
+		"!%j %u %w %U %W %V %G": "235 4 4 33 34 34 2001",
+		"!%Ey %OH %z %Z %%":     "01 14 +0000 UTC %",
+	} {
+		if got := call(t, state, "os", "date", format, at); fmt.Sprint(got) != "["+want+"]" {
+			t.Errorf("os.date(%q) = %v; want %s", format, got, want)
+		}
+	}
+	state.GetGlobal("os")
+	state.GetField(-1, "date")
+	state.Push("%Ez")
+	if err := state.PCall(1, 1, 0); err == nil || err.Error() != "bad argument #1 to 'os.date' (invalid conversion specifier '%E')" {
+		t.Errorf("os.date('%%Ez') = %v", err)
+	}
+	state.SetTop(0)
+
+	// os.time normalizes the date table, which os.date("*t") returns.
+	state.GetGlobal("os")
+	state.GetField(-1, "time")
+	state.NewTable()
+	for field, value := range map[string]int64{"year": 2001, "month": 8, "day": 23, "hour": 14, "min": 54, "sec": 62} {
+		state.Push(value)
+		state.SetField(-2, field)
+	}
+	state.PushIndex(-1)
+	state.Insert(1)
+	if err := state.PCall(1, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := state.ToInt(-1), time.Date(2001, 8, 23, 14, 55, 2, 0, time.Local).Unix(); got != want {
+		t.Errorf("os.time() = %d; want %d", got, want)
+	}
+	state.GetField(1, "min")
+	state.GetField(1, "wday")
+	if got := fmt.Sprint(state.ToInt(-2), state.ToInt(-1)); got != "55 5" {
+		t.Errorf("normalized min and wday = %s; want 55 5", got)
+	}
+	state.SetTop(0)
+	if got := fmt.Sprint(call(t, state, "os", "difftime", int64(10), int64(4))); got != "[6]" {
+		t.Errorf("os.difftime(10, 4) = %s", got)
+	}
+}
+
+func TestOSOptions(t *testing.T) {
+	state := lua.NewState()
+	defer state.Close()
+	Open(state, WithOS(luaos.Options{
+		DisableExit:   true,
+		DisableRemove: true,
+		Getenv: func(name string) (string, bool) {
+			if name == "HOME" {
+				return os.LookupEnv(name)
+			}
+			return "", false
+		},
+	}))
+
+	state.GetGlobal("os")
+	for _, name := range []string{"exit", "remove", "rename"} {
+		if typ := state.GetField(-1, name); (typ == lua.FuncType) != (name == "rename") {
+			t.Errorf("os.%s is a %v", name, typ)
+		}
+		state.Pop()
+	}
+	state.Pop()
+	os.Setenv("GOLUA_TEST_SECRET", "secret")
+	defer os.Unsetenv("GOLUA_TEST_SECRET")
+	if got := fmt.Sprint(call(t, state, "os", "getenv", "GOLUA_TEST_SECRET")); got != "[nil]" {
+		t.Errorf("os.getenv of a hidden variable = %s", got)
+	}
+}
@@ -0,0 +1,402 @@
+package path
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/Azure/golua/lua"
+)
+
+const (
+	gridTypeName   = "path.grid"
+	finderTypeName = "path.finder"
+)
+
+// maxCells is the maximum number of cells of a grid.
+const maxCells = 1 << 26
+
+//
+// Lua Extension Library -- path
+//
+
+// Open opens the path library, which finds shortest paths over 2D grids with
+// the A* algorithm:
+//
+//	local path = require "path"
+//	local grid = path.grid(4, 3, "\1\1\1\1" .. "\0\0\0\1" .. "\1\1\1\1")
+//	local finder = path.finder{diagonal = false}
+//	local points, cost = finder:find(grid, 1, 1, 1, 3)
+//	for _, p in ipairs(points) do print(p[1], p[2]) end
+//
+// A grid assigns a cost to entering each cell; 0 (or nil/false from a cost
+// function) marks a blocked cell. Coordinates are 1-based. A finder holds the
+// working memory of a search and reuses it across calls, so a long-lived finder
+// avoids allocating on every search.
+//
+// The library is not opened by default; it is available through require "path".
+func Open(state *lua.State) int {
+	// Create 'path' table.
+	var pathFuncs = map[string]lua.Func{
+		"finder": lua.Func(pathFinder),
+		"grid":   lua.Func(pathGrid),
+	}
+	state.NewTableSize(0, len(pathFuncs))
+	state.SetFuncs(pathFuncs, 0)
+	createMetaTable(state, gridTypeName, map[string]lua.Func{
+		"get":  lua.Func(gridGet),
+		"set":  lua.Func(gridSet),
+		"size": lua.Func(gridSize),
+	})
+	createMetaTable(state, finderTypeName, map[string]lua.Func{
+		"find": lua.Func(finderFind),
+	})
+
+	// Return 'path' table.
+	return 1
+}
+
+func createMetaTable(state *lua.State, name string, funcs map[string]lua.Func) {
+	state.NewMetaTable(name)
+	state.PushIndex(-1)           // push metatable
+	state.SetField(-2, "__index") // metatable.__index = metatable
+	state.SetFuncs(funcs, 0)
+	state.Pop()
+}
+
+// grid is a rectangular map of cell costs, either stored as one byte per
+// cell or computed by a Lua function.
+type grid struct {
+	width, height int
+	cells         []byte
+	cost          lua.Value // cost function, if any
+}
+
+// path.grid (width, height [, cells])
+//
+// Returns a new grid. cells is either a string with one byte per cell (row by
+// row) giving the cost of entering it, a function cost(x, y) returning a number
+// or nil/false for blocked cells, or absent, in which case every cell costs 1.
+// A grid has at most 2^26 cells.
+func pathGrid(state *lua.State) int {
+	g := &grid{width: int(state.CheckInt(1)), height: int(state.CheckInt(2))}
+	state.ArgCheck(g.width > 0, 1, "width must be positive")
+	state.ArgCheck(g.height > 0, 2, "height must be positive")
+	state.ArgCheck(g.width <= maxCells/g.height, 2, "grid too large")
+	switch state.TypeAt(3) {
+	case lua.NoneType, lua.NilType:
+		g.cells = make([]byte, g.width*g.height)
+		for i := range g.cells {
+			g.cells[i] = 1
+		}
+	case lua.StringType:
+		g.cells = []byte(state.ToString(3))
+		state.ArgCheck(len(g.cells) == g.width*g.height, 3, "cell buffer size does not match grid size")
+	case lua.FuncType:
+		g.cost = state.CheckAny(3)
+	default:
+		state.ArgError(3, "string or function expected")
+	}
+	state.Push(g)
+	state.SetMetaTable(gridTypeName)
+	return 1
+}
+
+// grid:get (x, y)
+//
+// Returns the cost of cell (x, y), or 0 if it is blocked or out of bounds.
+func gridGet(state *lua.State) int {
+	g := checkGrid(state, 1)
+	x, y := int(state.CheckInt(2)), int(state.CheckInt(3))
+	state.Push(g.costAt(state, x-1, y-1))
+	return 1
+}
+
+// grid:set (x, y, cost)
+//
+// Sets the cost of cell (x, y) to an integer between 0 (blocked) and 255.
+// Grids built from a cost function cannot be modified.
+func gridSet(state *lua.State) int {
+	g := checkGrid(state, 1)
+	x, y := int(state.CheckInt(2))-1, int(state.CheckInt(3))-1
+	cost := state.CheckInt(4)
+	state.ArgCheck(g.cells != nil, 1, "grid has a cost function")
+	state.ArgCheck(x >= 0 && x < g.width, 2, "out of bounds")
+	state.ArgCheck(y >= 0 && y < g.height, 3, "out of bounds")
+	state.ArgCheck(cost >= 0 && cost <= 255, 4, "cost out of range")
+	g.cells[y*g.width+x] = byte(cost)
+	return 0
+}
+
+// grid:size ()
+//
+// Returns the width and height of the grid.
+func gridSize(state *lua.State) int {
+	g := checkGrid(state, 1)
+	state.Push(g.width)
+	state.Push(g.height)
+	return 2
+}
+
+// costAt returns the cost of entering the 0-based cell (x, y); 0 means blocked.
+func (g *grid) costAt(state *lua.State, x, y int) float64 {
+	if x < 0 || y < 0 || x >= g.width || y >= g.height {
+		return 0
+	}
+	if g.cells != nil {
+		return float64(g.cells[y*g.width+x])
+	}
+	state.Push(g.cost)
+	state.Push(x + 1)
+	state.Push(y + 1)
+	state.Call(2, 1)
+	cost, ok := state.TryFloat(-1)
+	state.Pop()
+	if !ok {
+		return 0
+	}
+	return math.Max(0, cost)
+}
+
+// finder is a reusable A* search context.
+type finder struct {
+	diagonal  bool
+	heuristic func(dx, dy float64) float64
+
+	// working memory, indexed by cell; valid only where mark == gen.
+	gen    uint32
+	mark   []uint32
+	closed []bool
+	g      []float64
+	parent []int32
+	open   openList
+}
+
+// path.finder ([options])
+//
+// Returns a new search context. The optional table options may contain
+// diagonal (default true), which allows diagonal moves (never cutting blocked
+// corners) costing sqrt(2) times the cell cost, and heuristic ("octile",
+// "manhattan", "euclidean" or "none"; the default depends on diagonal).
+func pathFinder(state *lua.State) int {
+	f := &finder{diagonal: true}
+	heuristic := ""
+	if !state.IsNoneOrNil(1) {
+		state.CheckType(1, lua.TableType)
+		if state.GetField(1, "diagonal"); !state.IsNoneOrNil(-1) {
+			f.diagonal = state.ToBool(-1)
+		}
+		state.Pop()
+		if state.GetField(1, "heuristic"); !state.IsNoneOrNil(-1) {
+			heuristic = state.ToString(-1)
+		}
+		state.Pop()
+	}
+	if heuristic == "" {
+		heuristic = "manhattan"
+		if f.diagonal {
+			heuristic = "octile"
+		}
+	}
+	switch heuristic {
+	case "manhattan":
+		f.heuristic = func(dx, dy float64) float64 { return dx + dy }
+	case "octile":
+		f.heuristic = func(dx, dy float64) float64 { return math.Max(dx, dy) + (math.Sqrt2-1)*math.Min(dx, dy) }
+	case "euclidean":
+		f.heuristic = math.Hypot
+	case "none":
+		f.heuristic = func(dx, dy float64) float64 { return 0 }
+	default:
+		state.ArgError(1, fmt.Sprintf("unknown heuristic '%s'", heuristic))
+	}
+	state.Push(f)
+	state.SetMetaTable(finderTypeName)
+	return 1
+}
+
+// finder:find (grid, sx, sy, gx, gy)
+//
+// Searches grid for a cheapest path from (sx, sy) to (gx, gy). Returns the path
+// as a sequence of {x, y} points including both ends, plus its total cost, or
+// nil if the goal cannot be reached. The heuristics assume that no cell costs
+// less than 1; with cheaper cells the path found may not be optimal.
+func finderFind(state *lua.State) int {
+	f := state.CheckUserData(1, finderTypeName).(*finder)
+	g := checkGrid(state, 2)
+	sx, sy := int(state.CheckInt(3))-1, int(state.CheckInt(4))-1
+	gx, gy := int(state.CheckInt(5))-1, int(state.CheckInt(6))-1
+	state.ArgCheck(sx >= 0 && sx < g.width && sy >= 0 && sy < g.height, 3, "start out of bounds")
+	state.ArgCheck(gx >= 0 && gx < g.width && gy >= 0 && gy < g.height, 5, "goal out of bounds")
+
+	cells, cost, ok := f.search(state, g, sy*g.width+sx, gy*g.width+gx)
+	if !ok {
+		state.Push(nil)
+		return 1
+	}
+	state.NewTableSize(len(cells), 0)
+	for i, cell := range cells {
+		state.NewTableSize(2, 0)
+		state.Push(cell%g.width + 1)
+		state.RawSetIndex(-2, 1)
+		state.Push(cell/g.width + 1)
+		state.RawSetIndex(-2, 2)
+		state.RawSetIndex(-2, i+1)
+	}
+	state.Push(cost)
+	return 2
+}
+
+// reset prepares the working memory for a grid of n cells.
+func (f *finder) reset(n int) {
+	if len(f.mark) < n {
+		f.mark = make([]uint32, n)
+		f.closed = make([]bool, n)
+		f.g = make([]float64, n)
+		f.parent = make([]int32, n)
+		f.gen = 0
+	}
+	if f.gen++; f.gen == 0 { // wrapped around: clear marks
+		for i := range f.mark {
+			f.mark[i] = 0
+		}
+		f.gen = 1
+	}
+	f.open = f.open[:0]
+}
+
+// visit initializes the working memory of cell if this is the first time it
+// is seen in the current search.
+func (f *finder) visit(cell int) {
+	if f.mark[cell] != f.gen {
+		f.mark[cell] = f.gen
+		f.closed[cell] = false
+		f.g[cell] = math.Inf(1)
+		f.parent[cell] = -1
+	}
+}
+
+var (
+	straight = [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+	diagonal = [][2]int{{1, 1}, {1, -1}, {-1, 1}, {-1, -1}}
+)
+
+func (f *finder) search(state *lua.State, g *grid, start, goal int) ([]int, float64, bool) {
+	f.reset(g.width * g.height)
+	if g.costAt(state, goal%g.width, goal/g.width) <= 0 {
+		return nil, 0, false
+	}
+	gx, gy := goal%g.width, goal/g.width
+	h := func(cell int) float64 {
+		return f.heuristic(math.Abs(float64(cell%g.width-gx)), math.Abs(float64(cell/g.width-gy)))
+	}
+	f.visit(start)
+	f.g[start] = 0
+	f.open.push(openNode{cell: start, f: h(start)})
+
+	for len(f.open) > 0 {
+		cur := f.open.pop().cell
+		if f.closed[cur] {
+			continue
+		}
+		if cur == goal {
+			return f.walk(goal), f.g[goal], true
+		}
+		f.closed[cur] = true
+		cx, cy := cur%g.width, cur/g.width
+		expand := func(dx, dy int, scale float64) {
+			nx, ny := cx+dx, cy+dy
+			cost := g.costAt(state, nx, ny)
+			if cost <= 0 {
+				return
+			}
+			if dx != 0 && dy != 0 { // do not cut blocked corners
+				if g.costAt(state, cx+dx, cy) <= 0 || g.costAt(state, cx, cy+dy) <= 0 {
+					return
+				}
+			}
+			next := ny*g.width + nx
+			f.visit(next)
+			if f.closed[next] {
+				return
+			}
+			if score := f.g[cur] + cost*scale; score < f.g[next] {
+				f.g[next] = score
+				f.parent[next] = int32(cur)
+				f.open.push(openNode{cell: next, f: score + h(next)})
+			}
+		}
+		for _, d := range straight {
+			expand(d[0], d[1], 1)
+		}
+		if f.diagonal {
+			for _, d := range diagonal {
+				expand(d[0], d[1], math.Sqrt2)
+			}
+		}
+	}
+	return nil, 0, false
+}
+
+// walk returns the cells from the start to goal following parent links.
+func (f *finder) walk(goal int) []int {
+	var cells []int
+	for cell := goal; cell >= 0; cell = int(f.parent[cell]) {
+		cells = append(cells, cell)
+	}
+	for i, j := 0, len(cells)-1; i < j; i, j = i+1, j-1 {
+		cells[i], cells[j] = cells[j], cells[i]
+	}
+	return cells
+}
+
+func checkGrid(state *lua.State, index int) *grid {
+	return state.CheckUserData(index, gridTypeName).(*grid)
+}
+
+// openNode is an entry of the open list.
+type openNode struct {
+	cell int
+	f    float64
+}
+
+// openList is a binary min-heap of open nodes ordered by f score.
+type openList []openNode
+
+// push adds n to the open list.
+func (l *openList) push(n openNode) {
+	*l = append(*l, n)
+	h := *l
+	for i := len(h) - 1; i > 0; {
+		p := (i - 1) / 2
+		if h[p].f <= h[i].f {
+			break
+		}
+		h[p], h[i] = h[i], h[p]
+		i = p
+	}
+}
+
+// pop removes and returns the open node with the lowest f score.
+func (l *openList) pop() openNode {
+	h := *l
+	n := len(h) - 1
+	top := h[0]
+	h[0] = h[n]
+	h = h[:n]
+	for i := 0; ; {
+		c := 2*i + 1
+		if c >= n {
+			break
+		}
+		if c+1 < n && h[c+1].f < h[c].f {
+			c++
+		}
+		if h[i].f <= h[c].f {
+			break
+		}
+		h[i], h[c] = h[c], h[i]
+		i = c
+	}
+	*l = h
+	return top
+}
@@ -0,0 +1,129 @@
+package std
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Azure/golua/lua"
+)
+
+func TestPath(t *testing.T) {
+	state := lua.NewState()
+	defer state.Close()
+	Open(state)
+	lib := require(t, state, "path")
+
+	// fn calls path.name with args and returns its first result.
+	fn := func(name string, args ...interface{}) lua.Value {
+		state.Push(lib)
+		state.GetField(-1, name)
+		for _, arg := range args {
+			state.Push(arg)
+		}
+		state.Call(len(args), 1)
+		defer state.Pop()
+		return state.Pop()
+	}
+	// find returns the points of the path found by finder and its cost.
+	find := func(finder, grid lua.Value, coords ...interface{}) string {
+		state.Push(finder)
+		state.GetField(-1, "find")
+		state.Insert(-2)
+		state.Push(grid)
+		for _, c := range coords {
+			state.Push(c)
+		}
+		if err := state.PCall(2+len(coords), 2, 0); err != nil {
+			return err.Error()
+		}
+		defer state.SetTop(0)
+		if state.IsNil(-2) {
+			return "nil"
+		}
+		var points []string
+		for i := 1; state.RawGetIndex(-2, i) == lua.TableType; i++ {
+			state.RawGetIndex(-1, 1)
+			state.RawGetIndex(-2, 2)
+			points = append(points, fmt.Sprintf("%d,%d", state.ToInt(-2), state.ToInt(-1)))
+			state.PopN(3)
+		}
+		state.Pop()
+		return fmt.Sprintf("%s cost %g", strings.Join(points, " "), state.ToNumber(-1))
+	}
+	options := func(diagonal bool) lua.Value {
+		state.NewTable()
+		state.Push(diagonal)
+		state.SetField(-2, "diagonal")
+		return state.Pop()
+	}
+
+	// A wall with a gap on the right.
+	grid := fn("grid", 4, 3, "\x01\x01\x01\x01"+"\x00\x00\x00\x01"+"\x01\x01\x01\x01")
+	straight, diagonal := fn("finder", options(false)), fn("finder")
+	around := "1,1 2,1 3,1 4,1 4,2 4,3 3,3 2,3 1,3 cost 8"
+	var tests = []struct {
+		finder lua.Value
+		coords []interface{}
+		want   string
+	}{
+		{straight, []interface{}{1, 1, 1, 3}, around},
+		{straight, []interface{}{1, 1, 1, 3}, around}, // the finder is reused
+		{diagonal, []interface{}{1, 1, 1, 3}, around}, // corners of the wall are not cut
+		{diagonal, []interface{}{1, 3, 3, 1}, "1,3 2,3 3,3 4,3 4,2 4,1 3,1 cost 6"},
+		{straight, []interface{}{2, 1, 2, 1}, "2,1 cost 0"},
+		{straight, []interface{}{1, 1, 2, 2}, "nil"}, // blocked goal
+		{straight, []interface{}{0, 1, 2, 2}, "bad argument #3 to '?' (start out of bounds)"},
+		{straight, []interface{}{1, 1, 5, 1}, "bad argument #5 to '?' (goal out of bounds)"},
+	}
+	for i, tt := range tests {
+		if got := find(tt.finder, grid, tt.coords...); got != tt.want {
+			t.Errorf("#%d: find%v = %s; want %s", i, tt.coords, got, tt.want)
+		}
+	}
+	state.Push(lib)
+	state.GetField(-1, "grid")
+	state.Push(1 << 40)
+	state.Push(1 << 40)
+	if err := state.PCall(2, 1, 0); err == nil || err.Error() != "bad argument #2 to 'path.grid' (grid too large)" {
+		t.Errorf("path.grid(2^40, 2^40): error = %v", err)
+	}
+	state.SetTop(0)
+	open := fn("grid", 3, 3)
+	if got := find(diagonal, open, 1, 1, 3, 3); got != "1,1 2,2 3,3 cost 2.8284271247461903" {
+		t.Errorf("diagonal find on an open grid = %s", got)
+	}
+	if got := find(straight, open, 1, 1, 3, 3); !strings.HasSuffix(got, "cost 4") {
+		t.Errorf("straight find on an open grid = %s", got)
+	}
+
+	for _, args := range [][]interface{}{{5, 1, 1}, {1, 1, 256}} {
+		state.Push(grid)
+		state.GetField(-1, "set")
+		state.Insert(-2)
+		for _, arg := range args {
+			state.Push(arg)
+		}
+		if err := state.PCall(4, 0, 0); err == nil || !strings.Contains(err.Error(), "out of") {
+			t.Errorf("grid:set%v: error = %v", args, err)
+		}
+		state.SetTop(0)
+	}
+	callMethod(t, state, grid, "set", 4, 2, 0)
+	if got := find(straight, grid, 1, 1, 1, 3); got != "nil" {
+		t.Errorf("find through a closed gap = %s; want nil", got)
+	}
+
+	// Costs from a function: the cheap row is worth a detour.
+	costs := fn("grid", 3, 2, lua.Func(func(state *lua.State) int {
+		if state.ToInt(2) == 1 {
+			state.Push(5)
+		} else {
+			state.Push(1)
+		}
+		return 1
+	}))
+	if got := find(straight, costs, 1, 1, 3, 1); got != "1,1 1,2 2,2 3,2 3,1 cost 8" {
+		t.Errorf("find over a cost function = %s", got)
+	}
+}
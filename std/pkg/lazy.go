@@ -0,0 +1,475 @@
+package pkg
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Azure/golua/lua"
+	"github.com/Azure/golua/lua/syntax"
+)
+
+// Lazy modules.
+//
+// A data module listed in package.lazy, such as
+//
+//	package.lazy["items"] = true
+//
+// is loaded lazily by the Lua searcher when its file only returns a table
+// constructor:
+//
+//	return {
+//		sword = { damage = 10, ... },
+//		["shield"] = { ... },
+//		{ ... }, -- items[1]
+//	}
+//
+// Instead of running the file, require indexes it: it records the offsets of
+// the value of each top-level field and returns an empty table whose __index
+// metamethod reads the value of a field from the file, evaluates it with
+// "return <value>" and stores it in the table the first time the field is
+// accessed. Servers can then start without evaluating large item tables they
+// may never use. pairs evaluates all the fields that are left, and # is the
+// number of positional fields.
+//
+// Only fields named by an identifier, a string without escapes or an integer
+// are indexed; a file with other keys, or with code before or after the table
+// constructor, is run as usual. The values of the fields must not refer to
+// local variables of the file, and the file must not change while the module
+// is in use.
+
+// lazyIndex is the index of the fields of a lazy module.
+type lazyIndex struct {
+	file string
+	keys map[interface{}]lazyField // fields with a key
+	list []lazyField               // positional fields
+}
+
+// lazyField is the value of a field: file[off:end], starting on line.
+type lazyField struct {
+	off, end int
+	line     int
+	done     bool
+}
+
+// isLazy reports whether package.lazy[modname] is true.
+func isLazy(state *lua.State, modname string) bool {
+	defer state.SetTop(state.Top())
+	if state.GetField(lua.UpValueIndex(1), "lazy") != lua.TableType {
+		return false
+	}
+	state.GetField(-1, modname)
+	return state.ToBool(-1)
+}
+
+// searchLazy pushes the loader of the lazy module in filename, and returns
+// false if the file cannot be indexed.
+func searchLazy(state *lua.State, modname, filename string) bool {
+	src, err := state.ReadFile(filename)
+	if err != nil {
+		return false
+	}
+	idx := indexLazy(src)
+	if idx == nil {
+		return false
+	}
+	idx.file = filename
+	state.Push(lua.Func(func(state *lua.State) int {
+		idx.open(state, modname)
+		return 1
+	}))
+	return true
+}
+
+// open pushes the table of the lazy module modname.
+func (idx *lazyIndex) open(state *lua.State, modname string) {
+	state.NewTableSize(0, 0)
+	state.NewTableSize(0, 3)
+	state.Push(lua.Func(func(state *lua.State) int {
+		key := state.CheckAny(2)
+		if field := idx.lookup(key); field != nil {
+			idx.load(state, modname, key, field)
+			return 1
+		}
+		return 0
+	}))
+	state.SetField(-2, "__index")
+	state.Push(lua.Func(func(state *lua.State) int {
+		idx.loadAll(state, modname)
+		state.GetGlobal("next")
+		state.PushIndex(1)
+		state.Push(nil)
+		return 3
+	}))
+	state.SetField(-2, "__pairs")
+	state.Push(lua.Func(func(state *lua.State) int {
+		state.Push(int64(len(idx.list)))
+		return 1
+	}))
+	state.SetField(-2, "__len")
+	state.SetMetaTableAt(-2)
+}
+
+// lookup returns the field key of the module, or nil.
+func (idx *lazyIndex) lookup(key lua.Value) *lazyField {
+	var field *lazyField
+	switch key := key.(type) {
+	case lua.String:
+		if f, ok := idx.keys[string(key)]; ok {
+			field = &f
+		}
+	case lua.Float:
+		if i := int64(key); lua.Float(i) == key {
+			return idx.lookup(lua.Int(i))
+		}
+	case lua.Int:
+		if i := int64(key); i >= 1 && i <= int64(len(idx.list)) {
+			return &idx.list[i-1]
+		}
+		if f, ok := idx.keys[int64(key)]; ok {
+			field = &f
+		}
+	}
+	if field == nil || field.done {
+		return nil
+	}
+	return field
+}
+
+// load evaluates the field key of the table at index 1, stores it in the
+// table and pushes it.
+func (idx *lazyIndex) load(state *lua.State, modname string, key lua.Value, field *lazyField) {
+	src, err := idx.read(state, field)
+	if err == nil {
+		err = state.LoadChunk(idx.file, src, lua.TextMode)
+	}
+	if err != nil {
+		state.Errorf("error loading field '%v' of module '%s' from file '%s':\n\t%v", key, modname, idx.file, err)
+	}
+	state.Call(0, 1)
+	state.Push(key)
+	state.PushIndex(-2)
+	state.RawSet(1)
+	idx.done(key)
+}
+
+// loadAll evaluates the fields of the table at index 1 that are left, except
+// those the table already has.
+func (idx *lazyIndex) loadAll(state *lua.State, modname string) {
+	load := func(key lua.Value, field *lazyField) {
+		if field.done {
+			return
+		}
+		if state.Push(key); state.RawGet(1) > lua.NilType {
+			state.Pop()
+			idx.done(key)
+			return
+		}
+		state.Pop()
+		idx.load(state, modname, key, field)
+		state.Pop()
+	}
+	for i := range idx.list {
+		load(lua.Int(i+1), &idx.list[i])
+	}
+	for k, f := range idx.keys {
+		switch k := k.(type) {
+		case string:
+			load(lua.String(k), &f)
+		case int64:
+			load(lua.Int(k), &f)
+		}
+	}
+}
+
+// done marks the field key as evaluated.
+func (idx *lazyIndex) done(key lua.Value) {
+	switch key := key.(type) {
+	case lua.String:
+		delete(idx.keys, string(key))
+	case lua.Float:
+		idx.done(lua.Int(int64(key)))
+	case lua.Int:
+		if i := int64(key); i >= 1 && i <= int64(len(idx.list)) {
+			idx.list[i-1].done = true
+		} else {
+			delete(idx.keys, int64(key))
+		}
+	}
+}
+
+// read returns the chunk that evaluates field, padded so that its line
+// numbers are those of the file.
+func (idx *lazyIndex) read(state *lua.State, field *lazyField) (string, error) {
+	file, err := state.FileSystem().OpenFile(idx.file, os.O_RDONLY, 0)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	buf := make([]byte, field.end-field.off)
+	if _, err := file.Seek(int64(field.off), io.SeekStart); err != nil {
+		return "", err
+	}
+	if _, err := io.ReadFull(file, buf); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%sreturn %s", strings.Repeat("\n", field.line-1), buf), nil
+}
+
+// indexLazy indexes the fields of the table returned by src, or returns nil
+// if src does not only return a table constructor with indexable keys.
+func indexLazy(src []byte) *lazyIndex {
+	lx := &lazyLexer{src: src, line: 1}
+	if len(src) > 0 && src[0] == '#' { // skip shebang
+		for lx.pos < len(src) && src[lx.pos] != '\n' {
+			lx.pos++
+		}
+	}
+	if lx.skip(); lx.name() != "return" {
+		return nil
+	}
+	if lx.skip(); !lx.next('{') {
+		return nil
+	}
+	idx := &lazyIndex{keys: make(map[interface{}]lazyField)}
+	for {
+		if lx.skip(); lx.next('}') {
+			break
+		}
+		var key interface{}
+		switch start, line := lx.pos, lx.line; {
+		case lx.peek('[') && !lx.longBracket():
+			lx.pos++
+			lx.skip()
+			if key = lx.key(); key == nil {
+				return nil
+			}
+			if lx.skip(); !lx.next(']') {
+				return nil
+			}
+			if lx.skip(); !lx.next('=') {
+				return nil
+			}
+		default:
+			if name := lx.name(); name != "" {
+				if lx.skip(); lx.peek('=') && !lx.peekAt(1, '=') {
+					lx.pos++
+					key = name
+					break
+				}
+			}
+			lx.pos, lx.line = start, line // positional field
+		}
+		lx.skip()
+		field, ok := lx.value()
+		if !ok {
+			return nil
+		}
+		if key == nil {
+			idx.list = append(idx.list, field)
+		} else {
+			idx.keys[key] = field
+		}
+		if lx.skip(); !lx.next(',') && !lx.next(';') {
+			if lx.next('}') {
+				break
+			}
+			return nil
+		}
+	}
+	lx.skip()
+	lx.next(';')
+	if lx.skip(); lx.pos < len(src) {
+		return nil
+	}
+	return idx
+}
+
+// lazyLexer scans the source of a lazy module.
+type lazyLexer struct {
+	src  []byte
+	pos  int
+	line int
+}
+
+func (lx *lazyLexer) peek(c byte) bool { return lx.peekAt(0, c) }
+
+func (lx *lazyLexer) peekAt(n int, c byte) bool {
+	return lx.pos+n < len(lx.src) && lx.src[lx.pos+n] == c
+}
+
+func (lx *lazyLexer) next(c byte) bool {
+	if lx.peek(c) {
+		lx.pos++
+		return true
+	}
+	return false
+}
+
+// skip skips spaces and comments.
+func (lx *lazyLexer) skip() {
+	for lx.pos < len(lx.src) {
+		switch c := lx.src[lx.pos]; {
+		case c == '\n':
+			lx.line++
+			lx.pos++
+		case c == ' ' || c == '\t' || c == '\r' || c == '\v' || c == '\f':
+			lx.pos++
+		case c == '-' && lx.peekAt(1, '-'):
+			lx.pos += 2
+			if lx.longBracket() {
+				if !lx.longString() {
+					lx.pos = len(lx.src)
+				}
+				continue
+			}
+			for lx.pos < len(lx.src) && lx.src[lx.pos] != '\n' {
+				lx.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// name scans an identifier, or returns "".
+func (lx *lazyLexer) name() string {
+	start := lx.pos
+	for lx.pos < len(lx.src) {
+		c := lx.src[lx.pos]
+		if c != '_' && !('a' <= c|0x20 && c|0x20 <= 'z') && !(lx.pos > start && '0' <= c && c <= '9') {
+			break
+		}
+		lx.pos++
+	}
+	return string(lx.src[start:lx.pos])
+}
+
+// key scans a string without escapes or an integer, or returns nil.
+func (lx *lazyLexer) key() interface{} {
+	if lx.peek('"') || lx.peek('\'') {
+		start := lx.pos
+		if !lx.shortString() {
+			return nil
+		}
+		if s := lx.src[start+1 : lx.pos-1]; !strings.ContainsRune(string(s), '\\') {
+			return string(s)
+		}
+		return nil
+	}
+	start := lx.pos
+	lx.next('-')
+	for lx.pos < len(lx.src) && (lx.src[lx.pos] == 'x' || lx.src[lx.pos] == 'X' || isHex(lx.src[lx.pos])) {
+		lx.pos++
+	}
+	if i, ok := syntax.StrToI64(string(lx.src[start:lx.pos])); ok {
+		return i
+	}
+	return nil
+}
+
+// value scans an expression up to the ',', ';' or '}' that ends the field.
+func (lx *lazyLexer) value() (field lazyField, ok bool) {
+	field = lazyField{off: lx.pos, line: lx.line}
+	end, depth := lx.pos, 0
+	for lx.skip(); lx.pos < len(lx.src); lx.skip() {
+		switch c := lx.src[lx.pos]; {
+		case c == '"' || c == '\'':
+			if !lx.shortString() {
+				return field, false
+			}
+		case c == '[' && lx.longBracket():
+			if !lx.longString() {
+				return field, false
+			}
+		case c == '{' || c == '(' || c == '[':
+			depth++
+			lx.pos++
+		case c == '}' || c == ')' || c == ']':
+			if depth == 0 {
+				field.end = end
+				return field, c == '}' && end > field.off
+			}
+			depth--
+			lx.pos++
+		case (c == ',' || c == ';') && depth == 0:
+			field.end = end
+			return field, end > field.off
+		case strings.IndexByte("=<>~", c) >= 0 && lx.peekAt(1, '='):
+			lx.pos += 2 // comparison
+		case c == '=' && depth == 0: // not an expression
+			return field, false
+		case c == '_' || 'a' <= c|0x20 && c|0x20 <= 'z':
+			switch lx.name() {
+			case "function", "do", "if", "repeat":
+				depth++
+			case "end", "until":
+				depth--
+			}
+		default:
+			lx.pos++
+		}
+		end = lx.pos
+	}
+	return field, false
+}
+
+// shortString scans a quoted string.
+func (lx *lazyLexer) shortString() bool {
+	quote := lx.src[lx.pos]
+	for lx.pos++; lx.pos < len(lx.src); lx.pos++ {
+		switch c := lx.src[lx.pos]; c {
+		case '\\':
+			if lx.pos++; lx.peek('\n') {
+				lx.line++
+			}
+		case '\n':
+			return false
+		case quote:
+			lx.pos++
+			return true
+		}
+	}
+	return false
+}
+
+// longBracket reports whether a long bracket, such as "[==[", starts at pos.
+func (lx *lazyLexer) longBracket() bool {
+	if !lx.peek('[') {
+		return false
+	}
+	n := 1
+	for lx.peekAt(n, '=') {
+		n++
+	}
+	return lx.peekAt(n, '[')
+}
+
+// longString scans a long string or comment.
+func (lx *lazyLexer) longString() bool {
+	lx.pos++
+	level := 0
+	for lx.next('=') {
+		level++
+	}
+	lx.pos++
+	for ; lx.pos < len(lx.src); lx.pos++ {
+		switch lx.src[lx.pos] {
+		case '\n':
+			lx.line++
+		case ']':
+			n := 1
+			for lx.peekAt(n, '=') {
+				n++
+			}
+			if n-1 == level && lx.peekAt(n, ']') {
+				lx.pos += n + 1
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isHex(c byte) bool { return '0' <= c && c <= '9' || 'a' <= c|0x20 && c|0x20 <= 'f' }
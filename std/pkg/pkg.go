@@ -31,7 +31,7 @@ func Open(state *lua.State) int {
 
 	// Set 'cpath' field.
 	state.Push(lua.EnvHome)
-	state.SetField(-2, "gopath")
+	state.SetField(-2, "cpath")
 
 	// Set 'config' field.
 	state.Push(lua.Config)
@@ -45,6 +45,14 @@ func Open(state *lua.State) int {
 	state.GetSubTable(lua.RegistryIndex, lua.PreloadKey)
 	state.SetField(-2, "preload")
 
+	// Set 'versions' field (the module version manifest).
+	state.NewTable()
+	state.SetField(-2, "versions")
+
+	// Set 'lazy' field (the data modules loaded lazily, see lazy.go).
+	state.NewTable()
+	state.SetField(-2, "lazy")
+
 	// Set global 'require' function with 'package' table as an upvalue.
 	var loadFuncs = map[string]lua.Func{
 		"require": lua.Func(require),
@@ -60,7 +68,7 @@ func Open(state *lua.State) int {
 	return 1
 }
 
-// require(modname)
+// require(modname [, constraint])
 //
 // Loads the given module. The function starts by looking into the package.loaded
 // table to determine whether module is already loaded. If it is, then require
@@ -71,8 +79,9 @@ func Open(state *lua.State) int {
 // this array, we can change how require looks for a module.
 //
 // First require queries package.preload[module]. If it has avalue, this value
-// (which should be a function) is the loader. Otherwise require searches for a
-// Lua loader using the path stored in package.path.
+// (which should be a function) is the loader. Otherwise require looks for a Go
+// module registered with lua.RegisterModule, and then searches for a Lua loader
+// using the path stored in package.path.
 //
 // Once a loader is found, require calls the loader with a single argument, module.
 //
@@ -85,55 +94,137 @@ func Open(state *lua.State) int {
 // If there is any error loading or running the module, or if it cannot find any loader for
 // the module, then require signals an error.
 //
+// Extension: versions. The package.versions table is a manifest mapping module
+// names to version strings. A module can also declare its version in the
+// _VERSION field of its table; it is recorded in the manifest when the module
+// is loaded. If both are present and differ, require raises a version conflict
+// error rather than silently keeping either. With a constraint such as ">=1.2"
+// or "^1.2, !=1.4.1" (see parseConstraint), require raises an error unless the
+// module has a version that satisfies it, including when it was already loaded
+// for another script.
+//
 // See https://www.lua.org/manual/5.3/manual.html#pdf-require
 func require(state *lua.State) int {
 	modname := state.CheckString(1) // name of module to require
 
+	var (
+		text = state.OptString(2, "") // version constraint
+		want constraint
+		err  error
+	)
+	if text != "" {
+		if want, err = parseConstraint(text); err != nil {
+			state.ArgError(2, err.Error())
+		}
+	}
+	state.SetTop(1)
+
 	// Push the LOADED table and check if module was already loaded.
 	state.GetField(lua.RegistryIndex, lua.LoadedKey)
-	state.GetField(-1, modname)
-	if state.ToBool(-1) {
-		return 1 // Module is already loaded
-	}
+	state.GetField(2, modname)
+	if !state.ToBool(-1) {
+		// Otherwise we must load the module.
 
-	// Otherwise we must load the module.
+		// First remove the result of GetField.
+		state.Pop()
 
-	// First remove the result of GetField.
-	state.Pop()
+		// Find a loader for the module.
+		searchLoader(state, modname)
 
-	// Find a loader for the module.
-	searchLoader(state, modname)
+		// Pass name as argument to module loader.
+		state.Push(modname)
 
-	// Pass name as argument to module loader.
-	state.Push(modname)
+		// Name is 1st argument (before search data).
+		state.Insert(-2)
 
-	// Name is 1st argument (before search data).
-	state.Insert(-2)
+		// Run the loader to load the module.
+		state.Call(2, 1)
+
+		// If non-nil return, then LOADED[modname] = returned value
+		if !state.IsNil(-1) {
+			state.SetField(2, modname)
+		} else {
+			state.Pop()
+		}
 
-	// Run the loader to load the module.
-	state.Call(2, 1)
+		// If module set no value, use true as result (LOADED[modname] = true).
+		if state.GetField(2, modname); state.IsNoneOrNil(-1) {
+			state.Pop()
+			state.Push(true)
+			state.PushIndex(-1)
+			state.SetField(2, modname)
+		}
+	}
+	checkVersion(state, modname, state.Top(), text, want)
+	return 1
+}
 
-	// If non-nil return, then LOADED[modname] = returned value
-	// if !lua.IsNone(state.Value(-1)) {
-	// 	state.SetField(2, modname)
-	// }
+// checkVersion checks the version of the module at index against the manifest
+// and the constraint c given as text.
+func checkVersion(state *lua.State, modname string, index int, text string, c constraint) {
+	// The declared version: package.versions[modname].
+	state.GetField(lua.UpValueIndex(1), "versions")
+	manifest := state.AbsIndex(-1)
+	var declared string
+	if state.TypeAt(manifest) == lua.TableType {
+		state.GetField(manifest, modname)
+		declared = state.OptString(-1, "")
+		state.Pop()
+	}
 
-	// If module set no value, use true as result (LOADED[modname] = true).
-	// if state.GetField(2, modname) == lua.NilType {
-	// 	state.Push(true) // Value stored
-	// 	state.Push(true) // Value returned
-	// 	state.SetField(2, modname)
-	// }
+	// The version of the module itself: module._VERSION.
+	var actual string
+	if state.TypeAt(index) == lua.TableType {
+		state.GetField(index, "_VERSION")
+		if state.TypeAt(-1) == lua.StringType {
+			actual = state.ToString(-1)
+		}
+		state.Pop()
+	}
 
-	return 1
+	if declared != "" && actual != "" {
+		d, err1 := parseVersion(declared)
+		a, err2 := parseVersion(actual)
+		if err1 != nil || err2 != nil || d.compare(a) != 0 {
+			state.Errorf("module '%s' version conflict: package.versions declares %s but version %s is loaded", modname, declared, actual)
+		}
+	}
+	if declared == "" && actual != "" && state.TypeAt(manifest) == lua.TableType {
+		state.Push(actual)
+		state.SetField(manifest, modname)
+		declared = actual
+	}
+	state.Pop() // versions
+
+	if c == nil {
+		return
+	}
+	if declared == "" {
+		state.Errorf("module '%s' has no version (required %s)", modname, text)
+	}
+	if v, err := parseVersion(declared); err != nil || !c.allows(v) {
+		state.Errorf("module '%s' version %s does not satisfy '%s'", modname, declared, text)
+	}
 }
 
 // package.searchpath(name, path [, sep [, rep]])
 //
 // See https://www.lua.org/manual/5.3/manual.html#pdf-package.searchpath
 func pkgSearchPath(state *lua.State) int {
-	unimplemented("package: searchpath")
-	return 0
+	var (
+		name = state.CheckString(1)
+		path = state.CheckString(2)
+		sep  = state.OptString(3, ".")
+		rep  = state.OptString(4, string(os.PathSeparator))
+	)
+	if file := searchPath(state, name, path, sep, rep); file != "" {
+		state.Push(file)
+		return 1
+	}
+	// Error message is on top of the stack.
+	state.Push(nil)
+	state.Insert(-2)
+	return 2
 }
 
 // package.loadlib(libname, funcname)
@@ -168,6 +259,8 @@ func createSearchersTable(state *lua.State) {
 	var searchers = []lua.Func{
 		// preload searcher
 		lua.Func(searchPreload),
+		// registered go module searcher
+		lua.Func(searchRegistered),
 		// lua searcher
 		lua.Func(searchLua),
 		// go searcher
@@ -220,7 +313,10 @@ func searchLoader(state *lua.State, modname string) {
 	var errs strings.Builder
 
 	// Iterate over available searchers to find a loader.
-	for arr, at := state.Top(), 1; state.RawGetIndex(arr, at) != lua.NilType; at++ {
+	for arr, at := state.Top(), 1; ; at++ {
+		if state.RawGetIndex(arr, at); state.IsNoneOrNil(-1) {
+			break
+		}
 		// Push modname argument and call searcher.
 		state.Push(modname)
 		state.Call(1, 2)
@@ -242,18 +338,33 @@ func searchLoader(state *lua.State, modname string) {
 	// No loader found, pop last result and throw error.
 	state.Pop() // nil from final query.
 
+	// A declared module, such as io, tells how to make it available.
+	if err := state.AbsentModule(modname); err != nil {
+		panic(err)
+	}
 	state.Errorf("module '%s' not found:%s", modname, errs.String())
 }
 
 func searchPreload(state *lua.State) int {
 	modname := state.CheckString(1)
 	state.GetField(lua.RegistryIndex, lua.PreloadKey)
-	if state.GetField(-1, modname) == lua.NilType {
+	if state.GetField(-1, modname); state.IsNoneOrNil(-1) {
 		state.Push(fmt.Sprintf("\n\tno field package.preload['%s']", modname))
 	}
 	return 1
 }
 
+func searchRegistered(state *lua.State) int {
+	modname := state.CheckString(1)
+	open, ok := lua.RegisteredModule(modname)
+	if !ok {
+		state.Push(fmt.Sprintf("\n\tno module '%s' registered in Go", modname))
+		return 1
+	}
+	state.PushClosure(open, 0)
+	return 1
+}
+
 func searchLua(state *lua.State) int {
 	var (
 		modname  = state.CheckString(1)
@@ -263,7 +374,16 @@ func searchLua(state *lua.State) int {
 		// Module not found in this path.
 		return 1
 	}
-	if err := state.LoadChunk(filename, nil, 0); err != nil {
+	if isLazy(state, modname) && searchLazy(state, modname, filename) {
+		// Lazy module indexed, its loader is on top of the stack.
+		state.Push(filename)
+		return 2
+	}
+	src, err := state.ReadFile(filename)
+	if err == nil {
+		err = state.LoadChunk(filename, src, 0)
+	}
+	if err != nil {
 		// Module didn't load successfully.
 		state.Push(fmt.Sprintf("error loading module '%s' from file '%s':\n\t%v",
 			modname,
@@ -286,7 +406,7 @@ func searchGo(state *lua.State) int {
 		open = "Open"
 		file string
 	)
-	if file = findFile(state, name, "gopath"); file == "" {
+	if file = findFile(state, name, "cpath"); file == "" {
 		// Module not found on this path.
 		return 1
 	}
@@ -307,17 +427,28 @@ func findFile(state *lua.State, name, pathkey string) string {
 	if !ok {
 		state.Errorf("'package.%s' must be a string", pathkey)
 	}
-	return searchPath(state, name, path, ".")
+	return searchPath(state, name, path, ".", string(os.PathSeparator))
+}
+
+// readable reports whether the file can be opened for reading in the file
+// system of the state.
+func readable(state *lua.State, file string) bool {
+	f, err := state.FileSystem().OpenFile(file, os.O_RDONLY, 0)
+	if err != nil {
+		return false
+	}
+	f.Close()
+	return true
 }
 
-func searchPath(state *lua.State, name, path, sep string) string {
+func searchPath(state *lua.State, name, path, sep, rep string) string {
 	if sep != "" { // non-empty separator?
-		name = strings.Replace(name, sep, string(os.PathSeparator), -1)
+		name = strings.Replace(name, sep, rep, -1)
 	}
 	var errMsg string
 	for _, file := range strings.Split(path, ";") {
 		file = strings.Replace(file, "?", name, -1)
-		if _, err := os.Stat(file); err == nil {
+		if readable(state, file) {
 			return file
 		}
 		errMsg = fmt.Sprintf("%s\n\tno file '%s'", errMsg, file)
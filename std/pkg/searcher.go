@@ -0,0 +1,93 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+
+	"github.com/Azure/golua/lua"
+)
+
+// Finder finds the chunk of a module for a searcher made with Searcher, for
+// example in an asset bundle or a database instead of the file system. It
+// returns the chunk, text or binary, and where it was found, such as a file
+// name, which is the chunk name of the module and the second argument of its
+// loader.
+//
+// A module that is not found is reported with an error that wraps
+// fs.ErrNotExist, whose text is added to the error of require, so that require
+// goes on to the next searcher; other errors fail require.
+type Finder func(name string) (chunk []byte, where string, err error)
+
+// Searcher returns a searcher for package.searchers that loads the modules
+// found by find.
+func Searcher(find Finder) lua.Func {
+	return func(state *lua.State) int {
+		modname := state.CheckString(1)
+		chunk, where, err := find(modname)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			state.Push(fmt.Sprintf("\n\t%v", err))
+			return 1
+		case err != nil:
+			state.Errorf("error loading module '%s':\n\t%v", modname, err)
+		}
+		if err := state.LoadChunk(where, chunk, lua.BinaryMode|lua.TextMode); err != nil {
+			state.Errorf("error loading module '%s' from '%s':\n\t%v", modname, where, err)
+		}
+		state.Push(where)
+		return 2
+	}
+}
+
+// FSFinder returns a Finder that finds modules in fsys, such as an embed.FS,
+// with the templates of path, as in package.path: the module "a.b" is looked
+// for in "a/b.lua" and then in "a/b/init.lua" with the path "?.lua;?/init.lua".
+func FSFinder(fsys fs.FS, path string) Finder {
+	return func(name string) ([]byte, string, error) {
+		var tried strings.Builder
+		name = strings.Replace(name, ".", "/", -1)
+		for _, file := range strings.Split(path, ";") {
+			file = strings.Replace(file, "?", name, -1)
+			chunk, err := fs.ReadFile(fsys, file)
+			switch {
+			case err == nil:
+				return chunk, file, nil
+			case !errors.Is(err, fs.ErrNotExist):
+				return nil, "", err
+			}
+			if tried.Len() > 0 {
+				tried.WriteString("\n\t")
+			}
+			fmt.Fprintf(&tried, "no file '%s'", file)
+		}
+		return nil, "", notFound(tried.String())
+	}
+}
+
+// notFound is the error of a module that a Finder did not find, described by
+// the places it looked into.
+type notFound string
+
+func (err notFound) Error() string    { return string(err) }
+func (notFound) Is(target error) bool { return target == fs.ErrNotExist }
+
+// AddSearcher appends searcher to package.searchers, after the searchers of
+// package.preload, of the modules registered with lua.RegisterModule, and of
+// package.path and package.cpath. It returns an error if the package library
+// is not opened in state.
+func AddSearcher(state *lua.State, searcher lua.Func) error {
+	defer state.SetTop(state.Top())
+	state.GetField(lua.RegistryIndex, lua.LoadedKey)
+	if state.GetField(-1, "package") != lua.TableType {
+		return errors.New("package library not opened")
+	}
+	if state.GetField(-1, "searchers") != lua.TableType {
+		return errors.New("'package.searchers' must be a table")
+	}
+	state.PushIndex(-2)
+	state.PushClosure(searcher, 1)
+	state.RawSetIndex(-2, state.RawLen(-2)+1)
+	return nil
+}
@@ -0,0 +1,122 @@
+package pkg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// version is a dotted module version such as "1.2.3" or "2.0-beta". Missing
+// components are zero, so "1.2" and "1.2.0" are the same version.
+type version struct {
+	nums [3]int
+	pre  string // pre-release suffix; sorts before the release
+}
+
+// parseVersion parses a version with up to three numeric components, an
+// optional "v" prefix and an optional "-" pre-release suffix.
+func parseVersion(s string) (v version, err error) {
+	str := strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.IndexByte(str, '-'); i >= 0 {
+		str, v.pre = str[:i], str[i+1:]
+	}
+	parts := strings.Split(str, ".")
+	if len(parts) > len(v.nums) {
+		return v, fmt.Errorf("invalid version '%s'", s)
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("invalid version '%s'", s)
+		}
+		v.nums[i] = n
+	}
+	return v, nil
+}
+
+// compare returns -1, 0 or +1 as v is older than, the same as or newer than w.
+func (v version) compare(w version) int {
+	for i := range v.nums {
+		switch {
+		case v.nums[i] < w.nums[i]:
+			return -1
+		case v.nums[i] > w.nums[i]:
+			return +1
+		}
+	}
+	switch {
+	case v.pre == w.pre:
+		return 0
+	case v.pre == "":
+		return +1
+	case w.pre == "":
+		return -1
+	case v.pre < w.pre:
+		return -1
+	}
+	return +1
+}
+
+// constraint is a set of version requirements that must all hold.
+type constraint []func(version) bool
+
+// parseConstraint parses a comma-separated list of requirements. Each is a
+// version preceded by one of the operators = (the default), ==, ~=, !=, <,
+// <=, > and >=, or by ^ (same major version, at least the one given) or ~
+// (same major and minor version, at least the one given).
+//
+//	require("json", ">=1.2, <2")
+//	require("json", "^1.2")
+func parseConstraint(s string) (constraint, error) {
+	var c constraint
+	for _, term := range strings.Split(s, ",") {
+		term = strings.TrimSpace(term)
+		op := ""
+		for _, prefix := range []string{"==", "~=", "!=", "<=", ">=", "=", "<", ">", "^", "~"} {
+			if strings.HasPrefix(term, prefix) {
+				op, term = prefix, term[len(prefix):]
+				break
+			}
+		}
+		want, err := parseVersion(term)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version constraint '%s'", s)
+		}
+		var test func(version) bool
+		switch op {
+		case "", "=", "==":
+			test = func(v version) bool { return v.compare(want) == 0 }
+		case "~=", "!=":
+			test = func(v version) bool { return v.compare(want) != 0 }
+		case "<":
+			test = func(v version) bool { return v.compare(want) < 0 }
+		case "<=":
+			test = func(v version) bool { return v.compare(want) <= 0 }
+		case ">":
+			test = func(v version) bool { return v.compare(want) > 0 }
+		case ">=":
+			test = func(v version) bool { return v.compare(want) >= 0 }
+		case "^":
+			test = func(v version) bool {
+				return v.compare(want) >= 0 && v.nums[0] == want.nums[0] &&
+					(want.nums[0] > 0 || v.nums[1] == want.nums[1])
+			}
+		case "~":
+			test = func(v version) bool {
+				return v.compare(want) >= 0 && v.nums[0] == want.nums[0] && v.nums[1] == want.nums[1]
+			}
+		}
+		c = append(c, test)
+	}
+	return c, nil
+}
+
+// allows reports whether v meets all the requirements of c.
+func (c constraint) allows(v version) bool {
+	for _, test := range c {
+		if !test(v) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,317 @@
+package record
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Azure/golua/lua"
+)
+
+const recordTypeName = "record"
+
+//
+// Lua Extension Library -- record
+//
+
+// Open opens the record library. A record is an immutable value holding a fixed
+// set of named fields. Records are stored as a flat list of values sharing an
+// interned shape (the sorted list of field names) instead of as a hash table,
+// which makes them much cheaper than tables for large numbers of small values
+// such as points or colors.
+//
+//	local record = require "record"
+//	local p = record{x = 1, y = 2}
+//	print(p.x, p.y, p == record{x = 1, y = 2}) --> 1 2 true
+//
+// Assigning to a field of a record raises an error. Two records are equal if they
+// have the same fields with raw-equal values.
+//
+// The library is not opened by default; it is available through require "record".
+func Open(state *lua.State) int {
+	// Create 'record' table.
+	var recordFuncs = map[string]lua.Func{
+		"fields":   lua.Func(recordFields),
+		"isrecord": lua.Func(recordIsRecord),
+		"new":      lua.Func(recordNew),
+		"with":     lua.Func(recordWith),
+	}
+	state.NewTableSize(0, len(recordFuncs))
+	state.SetFuncs(recordFuncs, 0)
+	createRecordMetaTable(state)
+
+	// Allow record{...} as shorthand for record.new{...}.
+	state.NewTableSize(0, 1)
+	state.Push(lua.Func(func(state *lua.State) int {
+		state.Remove(1) // remove module table
+		return recordNew(state)
+	}))
+	state.SetField(-2, "__call")
+	state.SetMetaTableAt(-2)
+
+	// Return 'record' table.
+	return 1
+}
+
+// createRecordMetaTable creates the metatable for records.
+func createRecordMetaTable(state *lua.State) {
+	var funcs = map[string]lua.Func{
+		"__eq":       lua.Func(recordEq),
+		"__index":    lua.Func(recordIndex),
+		"__newindex": lua.Func(recordNewIndex),
+		"__pairs":    lua.Func(recordPairs),
+		"__tostring": lua.Func(recordToString),
+	}
+	state.NewMetaTable(recordTypeName)
+	state.SetFuncs(funcs, 0)
+	state.Pop()
+}
+
+// record.new (fields)
+//
+// Returns a new record with the string-keyed fields of the table fields.
+// Fields with other kinds of keys raise an error.
+func recordNew(state *lua.State) int {
+	state.CheckType(1, lua.TableType)
+	fields := make(map[string]lua.Value)
+	state.Push(nil)
+	for state.Next(1) {
+		value := state.Pop()
+		if state.TypeAt(-1) != lua.StringType {
+			state.ArgError(1, fmt.Sprintf("field name must be a string, got %s", state.TypeAt(-1)))
+		}
+		fields[state.ToString(-1)] = value
+	}
+	Push(state, fromMap(fields))
+	return 1
+}
+
+// record.with (rec, fields)
+//
+// Returns a copy of record rec with the fields of the table fields replaced
+// or added. The original record is not modified.
+func recordWith(state *lua.State) int {
+	rec := Check(state, 1)
+	state.CheckType(2, lua.TableType)
+	fields := make(map[string]lua.Value, len(rec.values))
+	for i, name := range rec.shape.names {
+		fields[name] = rec.values[i]
+	}
+	state.Push(nil)
+	for state.Next(2) {
+		value := state.Pop()
+		if state.TypeAt(-1) != lua.StringType {
+			state.ArgError(2, fmt.Sprintf("field name must be a string, got %s", state.TypeAt(-1)))
+		}
+		if name := state.ToString(-1); lua.IsNone(value) {
+			delete(fields, name)
+		} else {
+			fields[name] = value
+		}
+	}
+	Push(state, fromMap(fields))
+	return 1
+}
+
+// record.fields (rec)
+//
+// Returns a sequence with the field names of record rec in sorted order.
+func recordFields(state *lua.State) int {
+	rec := Check(state, 1)
+	state.NewTableSize(len(rec.shape.names), 0)
+	for i, name := range rec.shape.names {
+		state.Push(name)
+		state.RawSetIndex(-2, i+1)
+	}
+	return 1
+}
+
+// record.isrecord (v)
+//
+// Returns true if v is a record.
+func recordIsRecord(state *lua.State) int {
+	_, ok := state.TestUserData(1, recordTypeName).(*Record)
+	state.Push(ok)
+	return 1
+}
+
+func recordIndex(state *lua.State) int {
+	rec := Check(state, 1)
+	if state.TypeAt(2) == lua.StringType {
+		if i, ok := rec.shape.index[state.ToString(2)]; ok {
+			state.Push(rec.values[i])
+			return 1
+		}
+	}
+	state.Push(nil)
+	return 1
+}
+
+func recordNewIndex(state *lua.State) int {
+	Check(state, 1)
+	return state.Errorf("attempt to modify field '%s' of an immutable record", state.ToStringMeta(2))
+}
+
+func recordEq(state *lua.State) int {
+	r1, _ := state.TestUserData(1, recordTypeName).(*Record)
+	r2, _ := state.TestUserData(2, recordTypeName).(*Record)
+	state.Push(r1 != nil && r2 != nil && r1.Equal(state, r2))
+	return 1
+}
+
+func recordPairs(state *lua.State) int {
+	rec := Check(state, 1)
+	next := 0
+	state.Push(lua.Func(func(state *lua.State) int {
+		if next >= len(rec.values) {
+			state.Push(nil)
+			return 1
+		}
+		state.Push(rec.shape.names[next])
+		state.Push(rec.values[next])
+		next++
+		return 2
+	}))
+	state.PushIndex(1)
+	state.Push(nil)
+	return 3
+}
+
+func recordToString(state *lua.State) int {
+	state.Push(Check(state, 1).String())
+	return 1
+}
+
+// Record is an immutable set of named Lua values.
+type Record struct {
+	shape  *shape
+	values []lua.Value
+}
+
+// New returns a record with the given field names and values. Values are
+// converted with lua.ValueOf; names and values must have the same length.
+func New(state *lua.State, names []string, values ...interface{}) *Record {
+	if len(names) != len(values) {
+		panic(fmt.Errorf("record: %d names for %d values", len(names), len(values)))
+	}
+	fields := make(map[string]lua.Value, len(names))
+	for i, name := range names {
+		fields[name] = lua.ValueOf(state, values[i])
+	}
+	return fromMap(fields)
+}
+
+// Push pushes the record onto the stack of state.
+func Push(state *lua.State, rec *Record) {
+	state.Push(rec)
+	state.SetMetaTable(recordTypeName)
+}
+
+// Check checks whether the function argument at index is a record and returns it.
+func Check(state *lua.State, index int) *Record {
+	return state.CheckUserData(index, recordTypeName).(*Record)
+}
+
+// Get returns the value of the named field, or nil if the record has no such field.
+func (rec *Record) Get(name string) lua.Value {
+	if i, ok := rec.shape.index[name]; ok {
+		return rec.values[i]
+	}
+	return nil
+}
+
+// Fields returns the field names of the record in sorted order.
+func (rec *Record) Fields() []string { return append([]string(nil), rec.shape.names...) }
+
+// Equal reports whether both records have the same fields and raw-equal values,
+// as compared by rawequal in state; for example, 1 and 1.0 are equal.
+func (rec *Record) Equal(state *lua.State, other *Record) bool {
+	if rec.shape != other.shape && !rec.shape.same(other.shape) {
+		return false
+	}
+	for i, v := range rec.values {
+		state.Push(v)
+		state.Push(other.values[i])
+		equal := state.RawEqual(-2, -1)
+		state.PopN(2)
+		if !equal {
+			return false
+		}
+	}
+	return true
+}
+
+func (rec *Record) String() string {
+	var b strings.Builder
+	b.WriteString("record{")
+	for i, name := range rec.shape.names {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprintf(&b, "%s=%v", name, rec.values[i])
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+func fromMap(fields map[string]lua.Value) *Record {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	rec := &Record{shape: shapeOf(names), values: make([]lua.Value, len(names))}
+	for i, name := range names {
+		rec.values[i] = fields[name]
+	}
+	return rec
+}
+
+// shape describes the field layout shared by all records with the same field names.
+type shape struct {
+	names []string
+	index map[string]int
+}
+
+// same reports whether both shapes have the same field names.
+func (s *shape) same(other *shape) bool {
+	if len(s.names) != len(other.names) {
+		return false
+	}
+	for i, name := range s.names {
+		if name != other.names[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// maxShapes is the number of shapes interned before the interned shapes are
+// dropped, so that scripts building records with ever new field names do not
+// grow the table forever. Records keep the shapes they were built with.
+const maxShapes = 4096
+
+var shapes struct {
+	sync.Mutex
+	byKey map[string]*shape
+}
+
+// shapeOf returns the interned shape for the sorted field names.
+func shapeOf(names []string) *shape {
+	key := strings.Join(names, "\x00")
+	shapes.Lock()
+	defer shapes.Unlock()
+	if s, ok := shapes.byKey[key]; ok {
+		return s
+	}
+	s := &shape{names: names, index: make(map[string]int, len(names))}
+	for i, name := range names {
+		s.index[name] = i
+	}
+	if shapes.byKey == nil || len(shapes.byKey) >= maxShapes {
+		shapes.byKey = make(map[string]*shape)
+	}
+	shapes.byKey[key] = s
+	return s
+}
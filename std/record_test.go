@@ -0,0 +1,50 @@
+package std
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Azure/golua/lua"
+	"github.com/Azure/golua/std/record"
+)
+
+func TestRecordEqual(t *testing.T) {
+	state := lua.NewState()
+	defer state.Close()
+	Open(state)
+	call(t, state, "", "require", "record")
+
+	point := func(x, y interface{}) *record.Record {
+		return record.New(state, []string{"x", "y"}, x, y)
+	}
+	tests := []struct {
+		r1, r2 *record.Record
+		want   bool
+	}{
+		{point(1, 2), point(1, 2), true},
+		{point(1, 2), point(1.0, 2.0), true},
+		{point(1, 2), point(1, 2.5), false},
+		{point("1", 2), point(1, 2), false},
+		{point(1, 2), record.New(state, []string{"x", "z"}, 1, 2), false},
+	}
+	for _, tt := range tests {
+		if got := tt.r1.Equal(state, tt.r2); got != tt.want {
+			t.Errorf("%v.Equal(%v) = %t; want %t", tt.r1, tt.r2, got, tt.want)
+		}
+		record.Push(state, tt.r1)
+		record.Push(state, tt.r2)
+		if got := state.Compare(lua.OpEq, -2, -1); got != tt.want {
+			t.Errorf("%v == %v is %t; want %t", tt.r1, tt.r2, got, tt.want)
+		}
+		state.PopN(2)
+	}
+
+	// Records keep comparing equal once the interned shapes are dropped.
+	before := point(1, 2)
+	for i := 0; i < 5000; i++ {
+		record.New(state, []string{fmt.Sprintf("f%d", i)}, i)
+	}
+	if after := point(1, 2); !before.Equal(state, after) {
+		t.Errorf("%v.Equal(%v) = false after interning many shapes", before, after)
+	}
+}
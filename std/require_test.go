@@ -0,0 +1,209 @@
+package std
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/Azure/golua/lua"
+	"github.com/Azure/golua/lua/binary"
+	"github.com/Azure/golua/lua/vm"
+	"github.com/Azure/golua/std/pkg"
+)
+
+func TestRequireVersions(t *testing.T) {
+	state := lua.NewState()
+	defer state.Close()
+	Open(state)
+
+	module := func(version string) lua.Func {
+		return func(state *lua.State) int {
+			state.NewTable()
+			if version != "" {
+				state.Push(version)
+				state.SetField(-2, "_VERSION")
+			}
+			return 1
+		}
+	}
+	state.Preload("json", module("1.3.0"))
+	state.Preload("plain", module(""))
+	state.Preload("mod", module("1.0"))
+	state.Preload("empty", func(*lua.State) int { return 0 })
+	state.GetGlobal("package")
+	state.GetField(-1, "versions")
+	state.Push("2.0")
+	state.SetField(-2, "mod")
+	state.PopN(2)
+
+	var tests = []struct {
+		args []interface{}
+		err  string
+	}{
+		{[]interface{}{"json"}, ""},
+		{[]interface{}{"json", ">=1.2, <2"}, ""},
+		{[]interface{}{"json", "^1.1"}, ""},
+		{[]interface{}{"json", "~1.2"}, "module 'json' version 1.3.0 does not satisfy '~1.2'"},
+		{[]interface{}{"json", "<1"}, "module 'json' version 1.3.0 does not satisfy '<1'"},
+		{[]interface{}{"json", ">=x"}, "invalid version constraint '>=x'"},
+		{[]interface{}{"plain"}, ""},
+		{[]interface{}{"plain", "1"}, "module 'plain' has no version (required 1)"},
+		{[]interface{}{"mod"}, "module 'mod' version conflict: package.versions declares 2.0 but version 1.0 is loaded"},
+	}
+	for _, test := range tests {
+		state.GetGlobal("require")
+		for _, arg := range test.args {
+			state.Push(arg)
+		}
+		err := state.PCall(len(test.args), 1, 0)
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("require%q: %v", test.args, err)
+		case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+			t.Errorf("require%q = %v; want error %q", test.args, err, test.err)
+		}
+		state.SetTop(0)
+	}
+	if got := call(t, state, "", "require", "json"); len(got) != 1 || got[0] != "table" {
+		t.Errorf("require('json') = %v; want the module table", got)
+	}
+	if got := call(t, state, "", "require", "empty"); len(got) != 1 || got[0] != "boolean" {
+		t.Errorf("require('empty') = %v; want true", got)
+	}
+	state.GetGlobal("package")
+	state.GetField(-1, "versions")
+	if state.GetField(-1, "json"); state.ToString(-1) != "1.3.0" {
+		t.Errorf("package.versions.json = %q; want the module's _VERSION", state.ToString(-1))
+	}
+}
+
+func TestRequireLazy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "golua")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	const items = `-- items
+return {
+	sword = { damage = 10, name = "Sword, long" },
+	["shield"] = { armor = [[ {, } ]] },
+	[100] = function(a, b) local c, d = a, b; return c end,
+	{ "first" }, -- items[1]
+	"second";
+	--[==[ end } ]==]
+}
+`
+	if err := ioutil.WriteFile(filepath.Join(dir, "items.lua"), []byte(items), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	state := lua.NewState()
+	defer state.Close()
+	Open(state, WithLazyModules("items"))
+	state.GetGlobal("package")
+	state.Push(filepath.Join(dir, "?.lua"))
+	state.SetField(-2, "path")
+	state.Pop()
+
+	// The module is indexed, not run: none of its fields is evaluated.
+	state.GetGlobal("require")
+	state.Push("items")
+	if err := state.PCall(1, 1, 0); err != nil {
+		t.Fatalf("require('items'): %v", err)
+	}
+	if state.CallMeta(-1, "__len"); state.ToInt(-1) != 2 {
+		t.Errorf("#items = %s; want 2", state.ToString(-1))
+	}
+	state.Pop()
+	if state.GetField(-1, "spear"); !state.IsNil(-1) {
+		t.Errorf("items.spear = %s; want nil", state.ToString(-1))
+	}
+}
+
+func TestRequireAbsent(t *testing.T) {
+	state := lua.NewState()
+	defer state.Close()
+	Open(state)
+	state.DeclareModule("net", "link the net package")
+
+	state.GetGlobal("require")
+	state.Push("net")
+	const want = "module 'net' not available in this build (link the net package)"
+	if err := state.PCall(1, 1, 0); err == nil || err.Error() != want {
+		t.Errorf("require('net') = %v; want error %q", err, want)
+	}
+	if opened := state.Opened(); len(opened) != 10 || opened[0] != "_G" || opened[9] != "debug" {
+		t.Errorf("Opened() = %q; want the standard libraries", opened)
+	}
+}
+
+func TestRequireSearcher(t *testing.T) {
+	// return "net"
+	proto := binary.Prototype{
+		Source: "@game/net.lua",
+		Vararg: 1,
+		Stack:  2,
+		Consts: []interface{}{"net"},
+		Code: []uint32{
+			uint32(vm.ABx(vm.LOADK, 0, 0)),
+			uint32(vm.ABC(vm.RETURN, 0, 2, 0)),
+		},
+	}
+	bundle := fstest.MapFS{
+		"game/net.lua": {Data: binary.Dump(&proto, false)},
+	}
+
+	state := lua.NewState()
+	defer state.Close()
+	Open(state)
+	if err := pkg.AddSearcher(state, pkg.Searcher(pkg.FSFinder(bundle, "?.lua;?/init.lua"))); err != nil {
+		t.Fatal(err)
+	}
+	if got := call(t, state, "", "require", "game.net"); len(got) != 1 || got[0] != "net" {
+		t.Errorf("require('game.net') = %v; want net", got)
+	}
+
+	state.GetGlobal("require")
+	state.Push("game.chat")
+	const want = "no file 'game/chat.lua'\n\tno file 'game/chat/init.lua'"
+	if err := state.PCall(1, 1, 0); err == nil || !strings.HasSuffix(err.Error(), want) {
+		t.Errorf("require('game.chat') = %v; want error ending with %q", err, want)
+	}
+
+	got := call(t, state, "package", "searchpath", "game.chat", "/nonexistent/?.lua;/nonexistent/?.luac")
+	if len(got) != 2 || got[0] != "nil" || got[1] != "\n\tno file '/nonexistent/game/chat.lua'\n\tno file '/nonexistent/game/chat.luac'" {
+		t.Errorf("package.searchpath = %q", got)
+	}
+}
+
+func TestRequireRegistered(t *testing.T) {
+	var opened int
+	lua.RegisterModule("test.registered", func(state *lua.State) int {
+		opened++
+		state.NewTable()
+		state.Push(state.ToString(1))
+		state.SetField(-2, "name")
+		return 1
+	})
+
+	state := lua.NewState()
+	defer state.Close()
+	Open(state)
+	for i := 0; i < 2; i++ {
+		state.GetGlobal("require")
+		state.Push("test.registered")
+		if err := state.PCall(1, 1, 0); err != nil {
+			t.Fatal(err)
+		}
+		if state.GetField(-1, "name"); state.ToString(-1) != "test.registered" {
+			t.Errorf("module name = %q", state.ToString(-1))
+		}
+		state.SetTop(0)
+	}
+	if opened != 1 {
+		t.Errorf("module opened %d times; want once", opened)
+	}
+}
@@ -0,0 +1,187 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cron is a parsed cron expression. Each field is a bit set of the values
+// it matches.
+type cron struct {
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool // day fields given as '*'
+}
+
+// bounds of a cron field.
+type bounds struct {
+	name     string
+	min, max uint
+	names    map[string]uint
+}
+
+var (
+	minutes = bounds{"minute", 0, 59, nil}
+	hours   = bounds{"hour", 0, 23, nil}
+	doms    = bounds{"day of month", 1, 31, nil}
+	months  = bounds{"month", 1, 12, map[string]uint{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	dows = bounds{"day of week", 0, 7, map[string]uint{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// macros are the predefined schedules.
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// parseCron parses a standard five-field cron expression
+// (minute hour day-of-month month day-of-week) or one of the macros.
+func parseCron(expr string) (*cron, error) {
+	if m, ok := macros[strings.ToLower(strings.TrimSpace(expr))]; ok {
+		expr = m
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields in cron expression, got %d", len(fields))
+	}
+	var (
+		c   cron
+		err error
+	)
+	if c.minute, err = parseField(fields[0], minutes); err != nil {
+		return nil, err
+	}
+	if c.hour, err = parseField(fields[1], hours); err != nil {
+		return nil, err
+	}
+	if c.dom, err = parseField(fields[2], doms); err != nil {
+		return nil, err
+	}
+	if c.month, err = parseField(fields[3], months); err != nil {
+		return nil, err
+	}
+	if c.dow, err = parseField(fields[4], dows); err != nil {
+		return nil, err
+	}
+	if c.dow&(1<<7) != 0 { // 7 is Sunday too
+		c.dow |= 1
+	}
+	c.domStar = fields[2] == "*" || fields[2] == "?"
+	c.dowStar = fields[4] == "*" || fields[4] == "?"
+	return &c, nil
+}
+
+// parseField parses a comma-separated list of ranges.
+func parseField(field string, b bounds) (bits uint64, err error) {
+	for _, part := range strings.Split(field, ",") {
+		var (
+			rng  = part
+			step = uint(1)
+		)
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			rng = part[:i]
+			n, err := strconv.ParseUint(part[i+1:], 10, 8)
+			if err != nil || n == 0 {
+				return 0, fmt.Errorf("invalid step '%s' in %s field", part[i+1:], b.name)
+			}
+			step = uint(n)
+		}
+		lo, hi := b.min, b.max
+		switch {
+		case rng == "*" || rng == "?":
+		case strings.Contains(rng, "-"):
+			i := strings.IndexByte(rng, '-')
+			if lo, err = parseValue(rng[:i], b); err != nil {
+				return 0, err
+			}
+			if hi, err = parseValue(rng[i+1:], b); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("invalid range '%s' in %s field", rng, b.name)
+			}
+		default:
+			if lo, err = parseValue(rng, b); err != nil {
+				return 0, err
+			}
+			if step == 1 {
+				hi = lo
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+func parseValue(s string, b bounds) (uint, error) {
+	if v, ok := b.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.ParseUint(s, 10, 8)
+	if err != nil || uint(v) < b.min || uint(v) > b.max {
+		return 0, fmt.Errorf("invalid value '%s' in %s field", s, b.name)
+	}
+	return uint(v), nil
+}
+
+// matches reports whether the minute of t matches the schedule.
+func (c *cron) matches(t time.Time) bool {
+	return c.minute&(1<<uint(t.Minute())) != 0 &&
+		c.hour&(1<<uint(t.Hour())) != 0 &&
+		c.month&(1<<uint(t.Month())) != 0 &&
+		c.dayMatches(t)
+}
+
+// dayMatches implements the traditional cron rule: when both day fields are
+// restricted, a day matches if either of them does.
+func (c *cron) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// next returns the first time strictly after t that matches the schedule,
+// or false if there is none within the next five years.
+func (c *cron) next(t time.Time) (time.Time, bool) {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			// step in local time: in zones with a half-hour offset,
+			// hours do not start on the hour in UTC. An hour skipped by a
+			// daylight saving change may normalize to the hour before.
+			next := time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			for h := 2; !next.After(t); h++ {
+				next = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+h, 0, 0, 0, loc)
+			}
+			t = next
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
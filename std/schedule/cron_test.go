@@ -0,0 +1,68 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCronNext(t *testing.T) {
+	var tests = []struct {
+		expr  string
+		after string
+		want  string
+	}{
+		{"0 12 * * MON", "2024-03-01T10:00:00Z", "2024-03-04T12:00:00Z"},
+		{"*/15 * * * *", "2024-03-01T10:07:30Z", "2024-03-01T10:15:00Z"},
+		{"0 0 29 2 *", "2024-03-01T00:00:00Z", "2028-02-29T00:00:00Z"},
+		{"30 9 1,15 * 5", "2024-03-02T00:00:00Z", "2024-03-08T09:30:00Z"},
+		{"@monthly", "2024-12-31T23:59:00Z", "2025-01-01T00:00:00Z"},
+		{"0 0 * * 7", "2024-03-01T00:00:00Z", "2024-03-03T00:00:00Z"},
+	}
+	for _, test := range tests {
+		c, err := parseCron(test.expr)
+		if err != nil {
+			t.Fatalf("parseCron(%q): %v", test.expr, err)
+		}
+		after, _ := time.Parse(time.RFC3339, test.after)
+		got, ok := c.next(after)
+		if !ok || got.Format(time.RFC3339) != test.want {
+			t.Errorf("%q.next(%s) = %s, %t; want %s", test.expr, test.after, got.Format(time.RFC3339), ok, test.want)
+		}
+	}
+}
+
+func TestCronNextInZone(t *testing.T) {
+	var tests = []struct {
+		expr  string
+		zone  string
+		after string
+		want  string
+	}{
+		{"0 12 * * *", "Asia/Kolkata", "2024-03-01T10:15:00+05:30", "2024-03-01T12:00:00+05:30"},
+		{"45 * * * *", "Asia/Kathmandu", "2024-03-01T10:50:00+05:45", "2024-03-01T11:45:00+05:45"},
+		{"30 2 * * *", "America/New_York", "2024-03-09T12:00:00-05:00", "2024-03-11T02:30:00-04:00"},
+	}
+	for _, test := range tests {
+		loc, err := time.LoadLocation(test.zone)
+		if err != nil {
+			t.Skipf("no time zone data: %v", err)
+		}
+		c, err := parseCron(test.expr)
+		if err != nil {
+			t.Fatalf("parseCron(%q): %v", test.expr, err)
+		}
+		after, _ := time.Parse(time.RFC3339, test.after)
+		got, ok := c.next(after.In(loc))
+		if !ok || got.Format(time.RFC3339) != test.want {
+			t.Errorf("%q.next(%s) in %s = %s, %t; want %s", test.expr, test.after, test.zone, got.Format(time.RFC3339), ok, test.want)
+		}
+	}
+}
+
+func TestCronParseErrors(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "* * * FOO *"} {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("parseCron(%q): expected error", expr)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package schedule
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Azure/golua/lua"
+	"github.com/Azure/golua/std/datetime"
+)
+
+const scheduleTypeName = "schedule"
+
+//
+// Lua Extension Library -- schedule
+//
+
+// Open opens the schedule library, which evaluates cron-style schedules:
+//
+//	local schedule = require "schedule"
+//	local noon = schedule.parse("0 12 * * MON-FRI")
+//	print(noon:next_run(os.time()))
+//
+// Expressions have the five standard fields minute, hour, day of month, month
+// and day of week, each a comma-separated list of values, ranges (a-b), '*' and
+// steps (*/n, a-b/n). Months and days of the week may be given by their English
+// three-letter names; both 0 and 7 denote Sunday. The macros @yearly, @monthly,
+// @weekly, @daily and @hourly are also accepted.
+//
+// Times are given either as Unix times (numbers) or as datetime values, and
+// results are returned in the same form. Schedules are evaluated in the time
+// zone passed to schedule.parse, or else in that of the datetime argument (the
+// local time zone for numbers).
+//
+// The library is not opened by default; it is available through require "schedule".
+func Open(state *lua.State) int {
+	// Create 'schedule' table.
+	var scheduleFuncs = map[string]lua.Func{
+		"parse": lua.Func(scheduleParse),
+	}
+	state.NewTableSize(0, len(scheduleFuncs))
+	state.SetFuncs(scheduleFuncs, 0)
+	createScheduleMetaTable(state)
+
+	// Return 'schedule' table.
+	return 1
+}
+
+// createScheduleMetaTable creates the metatable for schedules.
+func createScheduleMetaTable(state *lua.State) {
+	var funcs = map[string]lua.Func{
+		"matches":    lua.Func(schedMatches),
+		"next_run":   lua.Func(schedNextRun),
+		"__tostring": lua.Func(schedToString),
+	}
+	state.NewMetaTable(scheduleTypeName)
+	state.PushIndex(-1)           // push metatable
+	state.SetField(-2, "__index") // metatable.__index = metatable
+	state.SetFuncs(funcs, 0)
+	state.Pop()
+}
+
+// schedule is a parsed schedule together with its source and time zone.
+type schedule struct {
+	*cron
+	expr string
+	loc  *time.Location
+}
+
+// schedule.parse (expr [, tz])
+//
+// Parses the cron expression expr and returns a schedule. If the time zone tz
+// is given, the schedule is always evaluated in it. On failure returns nil plus
+// an error message.
+func scheduleParse(state *lua.State) int {
+	expr := state.CheckString(1)
+	c, err := parseCron(expr)
+	if err != nil {
+		state.Push(nil)
+		state.Push(err.Error())
+		return 2
+	}
+	sched := &schedule{cron: c, expr: expr}
+	if !state.IsNoneOrNil(2) {
+		loc, err := time.LoadLocation(state.CheckString(2))
+		if err != nil {
+			state.ArgError(2, fmt.Sprintf("unknown time zone '%s'", state.ToString(2)))
+		}
+		sched.loc = loc
+	}
+	state.Push(sched)
+	state.SetMetaTable(scheduleTypeName)
+	return 1
+}
+
+// schedule:next_run ([after])
+//
+// Returns the first time strictly after after (default now) at which the
+// schedule fires, or nil if it never fires within the next five years.
+func schedNextRun(state *lua.State) int {
+	sched := check(state)
+	t, isDatetime := sched.timeArg(state, 2)
+	next, ok := sched.next(t)
+	switch {
+	case !ok:
+		state.Push(nil)
+	case isDatetime:
+		datetime.Push(state, next)
+	default:
+		state.Push(next.Unix())
+	}
+	return 1
+}
+
+// schedule:matches ([t])
+//
+// Returns true if the schedule fires during the minute containing t
+// (default now).
+func schedMatches(state *lua.State) int {
+	sched := check(state)
+	t, _ := sched.timeArg(state, 2)
+	state.Push(sched.matches(t))
+	return 1
+}
+
+func schedToString(state *lua.State) int {
+	state.Push(fmt.Sprintf("schedule: %s", check(state).expr))
+	return 1
+}
+
+// timeArg returns the time argument at index converted to the schedule's
+// time zone, and whether it was given as a datetime.
+func (sched *schedule) timeArg(state *lua.State, index int) (t time.Time, isDatetime bool) {
+	switch t, isDatetime = datetime.Test(state, index); {
+	case isDatetime:
+	case state.IsNoneOrNil(index):
+		t = time.Now()
+	default:
+		t = time.Unix(state.CheckInt(index), 0)
+	}
+	if sched.loc != nil {
+		t = t.In(sched.loc)
+	}
+	return t, isDatetime
+}
+
+func check(state *lua.State) *schedule {
+	return state.CheckUserData(1, scheduleTypeName).(*schedule)
+}
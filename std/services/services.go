@@ -0,0 +1,178 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/Azure/golua/lua"
+)
+
+//
+// Lua Extension Library -- services
+//
+
+// containerKey is the registry key of the state's container.
+const containerKey = "services.container"
+
+// Scope tells how often the factory of a service is called.
+type Scope int
+
+const (
+	Singleton Scope = iota // the first value made is shared by every get
+	PerCall                // every get makes a new value
+)
+
+// Factory makes the value of a service for the state.
+type Factory func(state *lua.State) (interface{}, error)
+
+// service is a registered service.
+type service struct {
+	scope    Scope
+	factory  Factory
+	value    interface{} // value of a singleton, once made
+	made     bool
+	override *interface{} // value set with Override
+}
+
+// Container holds the services that Go registers for the scripts of a state,
+// such as a logger, a database, a random number generator or a clock. Each
+// state has one container, shared by the services library and Go code; see
+// Of.
+type Container struct {
+	services map[string]*service
+}
+
+// Of returns the container of the state, creating it if necessary.
+func Of(state *lua.State) *Container {
+	defer state.Pop()
+	if state.GetField(lua.RegistryIndex, containerKey) == lua.UserDataType {
+		if c, ok := state.ToUserData(-1).Value().(*Container); ok {
+			return c
+		}
+	}
+	c := &Container{services: make(map[string]*service)}
+	state.Push(c)
+	state.SetField(lua.RegistryIndex, containerKey)
+	return c
+}
+
+// Register registers the service name, whose values are made by factory in the
+// given scope. It replaces any service registered with the same name.
+func (c *Container) Register(name string, scope Scope, factory Factory) {
+	c.services[name] = &service{scope: scope, factory: factory}
+}
+
+// Provide registers value as the singleton service name.
+func (c *Container) Provide(name string, value interface{}) {
+	c.services[name] = &service{scope: Singleton, value: value, made: true}
+}
+
+// Override makes every get of the service name return value, such as a fake
+// clock in a test, until restore is called. The service does not need to be
+// registered.
+func (c *Container) Override(name string, value interface{}) (restore func()) {
+	svc, ok := c.services[name]
+	if !ok {
+		svc = new(service)
+		c.services[name] = svc
+	}
+	prev := svc.override
+	svc.override = &value
+	return func() {
+		if svc.override = prev; !ok {
+			delete(c.services, name)
+		}
+	}
+}
+
+// Get returns the value of the service name, making it if needed.
+func (c *Container) Get(state *lua.State, name string) (interface{}, error) {
+	svc, ok := c.services[name]
+	switch {
+	case !ok:
+		return nil, fmt.Errorf("service '%s' not registered", name)
+	case svc.override != nil:
+		return *svc.override, nil
+	case svc.made:
+		return svc.value, nil
+	}
+	value, err := svc.factory(state)
+	if err != nil {
+		return nil, fmt.Errorf("service '%s': %v", name, err)
+	}
+	if svc.scope == Singleton {
+		svc.value, svc.made = value, true
+	}
+	return value, nil
+}
+
+// Names returns the names of the registered services, sorted.
+func (c *Container) Names() []string {
+	names := make([]string, 0, len(c.services))
+	for name := range c.services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Open opens the services library, through which scripts obtain the services
+// that Go registers with Of(state):
+//
+//	local services = require "services"
+//	local clock = services.get("clock")
+//	if services.has("db") then ... end
+//
+// Go values are pushed as with State.Push, so services that implement
+// lua.HasMethods are called with methods, as in clock:now().
+//
+// The library is not opened by default; it is available through require "services".
+func Open(state *lua.State) int {
+	c := Of(state)
+	// Create 'services' table.
+	var servicesFuncs = map[string]lua.Func{
+		"get":  lua.Func(c.get),
+		"has":  lua.Func(c.has),
+		"list": lua.Func(c.list),
+	}
+	state.NewTableSize(0, len(servicesFuncs))
+	state.SetFuncs(servicesFuncs, 0)
+
+	// Return 'services' table.
+	return 1
+}
+
+// services.get (name)
+//
+// Returns the service name; it raises an error if the service is not
+// registered or cannot be made.
+func (c *Container) get(state *lua.State) int {
+	value, err := c.Get(state, state.CheckString(1))
+	if err != nil {
+		panic(err)
+	}
+	state.Push(value)
+	return 1
+}
+
+// services.has (name)
+//
+// Returns whether the service name is registered.
+func (c *Container) has(state *lua.State) int {
+	_, ok := c.services[state.CheckString(1)]
+	state.Push(ok)
+	return 1
+}
+
+// services.list ()
+//
+// Returns the sorted list of the names of the registered services.
+func (c *Container) list(state *lua.State) int {
+	names := c.Names()
+	state.NewTableSize(len(names), 0)
+	for i, name := range names {
+		state.Push(name)
+		state.RawSetIndex(-2, i+1)
+	}
+	return 1
+}
@@ -0,0 +1,74 @@
+package std
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Azure/golua/lua"
+	"github.com/Azure/golua/std/services"
+)
+
+func TestServices(t *testing.T) {
+	state := lua.NewState()
+	defer state.Close()
+	Open(state)
+	state.GetGlobal("require")
+	state.Push("services")
+	if err := state.PCall(1, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	state.SetGlobal("services")
+
+	var made int
+	c := services.Of(state)
+	c.Provide("name", "server-1")
+	c.Register("counter", services.PerCall, func(*lua.State) (interface{}, error) { made++; return made, nil })
+	c.Register("clock", services.Singleton, func(*lua.State) (interface{}, error) { made++; return int64(100), nil })
+	c.Register("db", services.Singleton, func(*lua.State) (interface{}, error) { return nil, errors.New("offline") })
+
+	var tests = []struct {
+		name string
+		want interface{}
+	}{
+		{"name", "server-1"},
+		{"counter", int64(1)},
+		{"counter", int64(2)},
+		{"clock", int64(100)},
+		{"clock", int64(100)},
+	}
+	for _, test := range tests {
+		if got := call(t, state, "services", "get", test.name); len(got) != 1 || got[0] != test.want {
+			t.Errorf("services.get(%q) = %v; want %v", test.name, got, test.want)
+		}
+	}
+	if made != 3 {
+		t.Errorf("factories called %d times; want 3", made)
+	}
+
+	// Tests override a service until they restore it.
+	restore := c.Override("clock", int64(5))
+	if got := call(t, state, "services", "get", "clock"); got[0] != int64(5) {
+		t.Errorf("overridden clock = %v; want 5", got)
+	}
+	restore()
+	if got := call(t, state, "services", "get", "clock"); got[0] != int64(100) {
+		t.Errorf("restored clock = %v; want 100", got)
+	}
+
+	for name, want := range map[string]string{
+		"db":   "service 'db': offline",
+		"mail": "service 'mail' not registered",
+	} {
+		state.GetGlobal("services")
+		state.GetField(-1, "get")
+		state.Push(name)
+		if err := state.PCall(1, 1, 0); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("services.get(%q) = %v; want error %q", name, err, want)
+		}
+		state.SetTop(0)
+	}
+	if got := call(t, state, "services", "has", "mail"); got[0] != "boolean" {
+		t.Errorf("services.has = %v", got)
+	}
+}
@@ -0,0 +1,279 @@
+package std
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Azure/golua/lua"
+)
+
+func TestSort(t *testing.T) {
+	state := lua.NewState()
+	defer state.Close()
+	Open(state)
+
+	list := func(values ...int) {
+		state.NewTable()
+		for i, v := range values {
+			state.Push(v)
+			state.RawSetIndex(-2, i+1)
+		}
+		state.SetGlobal("list")
+	}
+	contents := func() (values []int64) {
+		state.GetGlobal("list")
+		defer state.Pop()
+		for i := 1; state.RawGetIndex(-1, i) > lua.NilType; i++ {
+			values = append(values, state.ToInt(-1))
+			state.Pop()
+		}
+		state.Pop()
+		return values
+	}
+	sort := func(comp lua.Func) error {
+		state.GetGlobal("table")
+		state.GetField(-1, "sort")
+		state.GetGlobal("list")
+		nargs := 1
+		if comp != nil {
+			state.Push(comp)
+			nargs++
+		}
+		defer state.SetTop(0)
+		return state.PCall(nargs, 0, 0)
+	}
+
+	list(5, 3, 9, 1, 7, 2, 8, 6, 4, 0)
+	if err := sort(nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := contents(), []int64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sort = %v; want %v", got, want)
+	}
+	if err := sort(func(state *lua.State) int {
+		state.Push(state.ToInt(1) > state.ToInt(2))
+		return 1
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := contents(), []int64{9, 8, 7, 6, 5, 4, 3, 2, 1, 0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sort descending = %v; want %v", got, want)
+	}
+
+	err := sort(func(state *lua.State) int {
+		state.Push(true)
+		return 1
+	})
+	if err == nil || !strings.Contains(err.Error(), "invalid order function for sorting") {
+		t.Errorf("sort with inconsistent order = %v; want invalid order function error", err)
+	}
+
+	list(5, 3, 9, 1, 7, 2, 8, 6, 4, 0)
+	calls := 0
+	err = sort(func(state *lua.State) int {
+		if calls++; calls == 5 {
+			state.Errorf("comparator failed")
+		}
+		state.Push(state.ToInt(1) < state.ToInt(2))
+		return 1
+	})
+	if err == nil || !strings.Contains(err.Error(), "comparator failed") {
+		t.Errorf("sort with failing comparator = %v; want its error", err)
+	}
+	if got, want := contents(), []int64{5, 3, 9, 1, 7, 2, 8, 6, 4, 0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("list after failed sort = %v; want it unchanged", got)
+	}
+
+	// Integers and floats beyond 2^53 are compared exactly.
+	want := []lua.Value{lua.Float(1 << 53), lua.Int(1<<53 + 1)}
+	state.NewTable()
+	for i := range want {
+		state.Push(want[len(want)-1-i])
+		state.RawSetIndex(-2, i+1)
+	}
+	state.SetGlobal("list")
+	if err := sort(nil); err != nil {
+		t.Fatal(err)
+	}
+	state.GetGlobal("list")
+	for i, v := range want {
+		state.RawGetIndex(-1, i+1)
+		if got := state.Pop(); got != v {
+			t.Errorf("sort of mixed numbers: list[%d] = %v (%T); want %v (%T)", i+1, got, got, v, v)
+		}
+	}
+	state.Pop()
+}
+
+// rows returns a list of tables with the given fields, one per row.
+func rows(state *lua.State, fields []string, rows ...[]interface{}) lua.Value {
+	state.NewTable()
+	for i, row := range rows {
+		state.NewTable()
+		for j, field := range fields {
+			state.Push(row[j])
+			state.SetField(-2, field)
+		}
+		state.RawSetIndex(-2, i+1)
+	}
+	return state.Pop()
+}
+
+// column returns field of the tables of list, in order.
+func column(state *lua.State, list lua.Value, field string) (values []string) {
+	state.Push(list)
+	defer state.Pop()
+	for i := 1; state.RawGetIndex(-1, i) == lua.TableType; i++ {
+		state.GetField(-1, field)
+		values = append(values, state.ToString(-1))
+		state.PopN(2)
+	}
+	state.Pop()
+	return values
+}
+
+func TestSortBy(t *testing.T) {
+	state := lua.NewState()
+	defer state.Close()
+	Open(state)
+
+	fields := []string{"name", "x"}
+	var tests = []struct {
+		list lua.Value
+		desc bool
+		want string
+	}{
+		{rows(state, fields, []interface{}{"a", 3}, []interface{}{"b", 1}, []interface{}{"c", 2}), false, "b c a"},
+		{rows(state, fields, []interface{}{"a", 3}, []interface{}{"b", 1}, []interface{}{"c", 2}), true, "a c b"},
+		{rows(state, fields, []interface{}{"a", 2}, []interface{}{"b", 1.5}, []interface{}{"c", 1}), false, "c b a"},
+		{rows(state, fields, []interface{}{"a", "pear"}, []interface{}{"b", "apple"}), false, "b a"},
+		// Equal keys keep their order, in either direction.
+		{rows(state, fields, []interface{}{"a", 1}, []interface{}{"b", 0}, []interface{}{"c", 1}, []interface{}{"d", 0}, []interface{}{"e", 1}), false, "b d a c e"},
+		{rows(state, fields, []interface{}{"a", 1}, []interface{}{"b", 0}, []interface{}{"c", 1}, []interface{}{"d", 0}, []interface{}{"e", 1}), true, "a c e b d"},
+		{rows(state, fields), false, ""},
+	}
+	for i, tt := range tests {
+		call(t, state, "table", "sort_by", tt.list, "x", tt.desc)
+		if got := strings.Join(column(state, tt.list, "name"), " "); got != tt.want {
+			t.Errorf("#%d: table.sort_by(list, 'x', %t) = %s; want %s", i, tt.desc, got, tt.want)
+		}
+	}
+
+	state.GetGlobal("table")
+	state.GetField(-1, "sort_by")
+	state.Push(rows(state, fields, []interface{}{"a", 1}, []interface{}{"b", "x"}))
+	state.Push("x")
+	if err := state.PCall(2, 0, 0); err == nil || !strings.HasPrefix(err.Error(), "attempt to compare") {
+		t.Errorf("table.sort_by of mixed keys: error = %v", err)
+	}
+	state.SetTop(0)
+}
+
+// seq returns a sequence of the values.
+func seq(state *lua.State, values ...interface{}) lua.Value {
+	state.NewTable()
+	for i, v := range values {
+		state.Push(v)
+		state.RawSetIndex(-2, i+1)
+	}
+	return state.Pop()
+}
+
+func TestSortByKeys(t *testing.T) {
+	state := lua.NewState()
+	defer state.Close()
+	Open(state)
+
+	players := func() lua.Value {
+		return rows(state, []string{"name", "team", "score"},
+			[]interface{}{"ann", "red", 10},
+			[]interface{}{"bob", "blue", 20},
+			[]interface{}{"cid", "red", 30},
+			[]interface{}{"dan", "blue", 20},
+			[]interface{}{"eve", "red", 10},
+		)
+	}
+	var tests = []struct {
+		keys, desc lua.Value
+		want       string
+	}{
+		{seq(state, "team", "score"), nil, "bob dan ann eve cid"},
+		{seq(state, "team", "score"), seq(state, false, true), "bob dan cid ann eve"},
+		{seq(state, "team", "score"), seq(state, true), "ann eve cid bob dan"},
+		{seq(state, "score", "name"), seq(state, true, true), "cid dan bob eve ann"},
+		{seq(state, "score"), nil, "ann eve bob dan cid"}, // stable
+	}
+	for i, tt := range tests {
+		list := players()
+		call(t, state, "table", "sort_by_keys", list, tt.keys, tt.desc)
+		if got := strings.Join(column(state, list, "name"), " "); got != tt.want {
+			t.Errorf("#%d: table.sort_by_keys = %s; want %s", i, got, tt.want)
+		}
+	}
+
+	state.GetGlobal("table")
+	state.GetField(-1, "sort_by_keys")
+	state.Push(players())
+	state.Push(seq(state))
+	if err, want := state.PCall(2, 0, 0), "bad argument #2 to 'table.sort_by_keys' (empty key list)"; err == nil || err.Error() != want {
+		t.Errorf("table.sort_by_keys with no keys: error = %v; want %q", err, want)
+	}
+	state.SetTop(0)
+}
+
+func TestBSearch(t *testing.T) {
+	state := lua.NewState()
+	defer state.Close()
+	Open(state)
+
+	list := seq(state, 10, 20, 20, 30)
+	var tests = []struct {
+		value interface{}
+		want  string
+	}{
+		{10, "[1]"},
+		{20, "[2]"}, // the first of equal elements
+		{30, "[4]"},
+		{5, "[nil 1]"},
+		{25, "[nil 4]"},
+		{35, "[nil 5]"},
+		{20.0, "[2]"},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprint(call(t, state, "table", "bsearch", list, tt.value)); got != tt.want {
+			t.Errorf("table.bsearch(list, %v) = %s; want %s", tt.value, got, tt.want)
+		}
+	}
+	if got := fmt.Sprint(call(t, state, "table", "bsearch", seq(state), 1)); got != "[nil 1]" {
+		t.Errorf("table.bsearch({}, 1) = %s; want [nil 1]", got)
+	}
+
+	events := rows(state, []string{"name", "at"}, []interface{}{"a", 1}, []interface{}{"b", 5})
+	if got := fmt.Sprint(call(t, state, "table", "bsearch", events, 5, "at")); got != "[2]" {
+		t.Errorf("table.bsearch(events, 5, 'at') = %s; want [2]", got)
+	}
+	at := lua.Func(func(state *lua.State) int {
+		state.GetField(1, "at")
+		return 1
+	})
+	if got := fmt.Sprint(call(t, state, "table", "bsearch", events, 3, at)); got != "[nil 2]" {
+		t.Errorf("table.bsearch(events, 3, at) = %s; want [nil 2]", got)
+	}
+
+	// Equal keys are inserted after the elements already there.
+	var positions []interface{}
+	for _, row := range [][]interface{}{{"c", 3}, {"d", 5}, {"e", 0}, {"f", 9}} {
+		state.Push(rows(state, []string{"name", "at"}, row))
+		state.RawGetIndex(-1, 1)
+		positions = append(positions, call(t, state, "table", "insert_sorted", events, state.Pop(), "at")...)
+		state.Pop()
+	}
+	if got := strings.Join(column(state, events, "name"), " "); got != "e a c b d f" {
+		t.Errorf("events after table.insert_sorted = %s; want e a c b d f", got)
+	}
+	if got := fmt.Sprint(positions); got != "[2 4 1 6]" {
+		t.Errorf("table.insert_sorted positions = %s; want [2 4 1 6]", got)
+	}
+}
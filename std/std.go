@@ -2,22 +2,106 @@ package std
 
 import (
 	"github.com/Azure/golua/lua"
+	"github.com/Azure/golua/std/ai"
 	"github.com/Azure/golua/std/base"
+	"github.com/Azure/golua/std/collections"
+	luaconfig "github.com/Azure/golua/std/config"
 	"github.com/Azure/golua/std/coro"
+	"github.com/Azure/golua/std/csv"
+	"github.com/Azure/golua/std/data"
+	"github.com/Azure/golua/std/datetime"
+	"github.com/Azure/golua/std/events"
 	"github.com/Azure/golua/std/debug"
+	"github.com/Azure/golua/std/heap"
+	"github.com/Azure/golua/std/i18n"
 	"github.com/Azure/golua/std/io"
 	"github.com/Azure/golua/std/math"
 	"github.com/Azure/golua/std/os"
+	"github.com/Azure/golua/std/path"
 	"github.com/Azure/golua/std/pkg"
+	"github.com/Azure/golua/std/record"
+	"github.com/Azure/golua/std/schedule"
+	"github.com/Azure/golua/std/services"
 	"github.com/Azure/golua/std/str"
+	"github.com/Azure/golua/std/syncx"
 	"github.com/Azure/golua/std/table"
+	"github.com/Azure/golua/std/template"
 	"github.com/Azure/golua/std/utf8"
+	"github.com/Azure/golua/std/vec"
+	"github.com/Azure/golua/std/xml"
 )
 
-// Open opens all standard Lua libraries into the given state.
+// Option is an optional configuration for Open.
+type Option func(*config)
+
+// config holds the library configuration for Open.
+type config struct {
+	stringExt     bool
+	tableExt      bool
+	numberMethods bool
+	exec          *os.ExecPolicy
+	os            os.Options
+	lazy          []string
+}
+
+// WithStringExt returns an Option that toggles the string extension
+// functions (see str.OpenExt).
+func WithStringExt(enable bool) Option {
+	return func(cfg *config) {
+		cfg.stringExt = enable
+	}
+}
+
+// WithTableExt returns an Option that toggles the table extension
+// functions (see table.OpenExt).
+func WithTableExt(enable bool) Option {
+	return func(cfg *config) {
+		cfg.tableExt = enable
+	}
+}
+
+// WithNumberMethods returns an Option that toggles the number metatable,
+// whose __index is the math library, so that x:floor() is math.floor(x).
+func WithNumberMethods(enable bool) Option {
+	return func(cfg *config) {
+		cfg.numberMethods = enable
+	}
+}
+
+// WithExecPolicy returns an Option that enables os.execute and io.popen
+// under the given policy (see os.SetExecPolicy).
+func WithExecPolicy(policy *os.ExecPolicy) Option {
+	return func(cfg *config) {
+		cfg.exec = policy
+	}
+}
+
+// WithOS returns an Option that restricts the os library with opts, for
+// example to leave os.exit out (see os.OpenWith).
+func WithOS(opts os.Options) Option {
+	return func(cfg *config) {
+		cfg.os = opts
+	}
+}
+
+// WithLazyModules returns an Option that lists the given data modules in
+// package.lazy, so that require evaluates their fields on first access
+// instead of running them (see package pkg).
+func WithLazyModules(names ...string) Option {
+	return func(cfg *config) {
+		cfg.lazy = append(cfg.lazy, names...)
+	}
+}
+
+// Open opens all standard Lua libraries into the given state, and sets the
+// global _GOLUA that describes the implementation to scripts.
 //
 // See https://www.lua.org/manual/5.3/manual.html#luaL_openlibs
-func Open(state *lua.State) {
+func Open(state *lua.State, opts ...Option) {
+	var cfg config
+	for _, opt := range opts {
+		opt(&cfg)
+	}
 	var libs = []struct {
 		Name string
 		Open lua.Func
@@ -27,7 +111,7 @@ func Open(state *lua.State) {
 		{"coroutine", lua.Func(coro.Open)},
 		{"table", lua.Func(table.Open)},
 		{"io", lua.Func(io.Open)},
-		{"os", lua.Func(os.Open)},
+		{"os", os.OpenWith(cfg.os)},
 		{"string", lua.Func(str.Open)},
 		{"math", lua.Func(math.Open)},
 		{"utf8", lua.Func(utf8.Open)},
@@ -38,4 +122,62 @@ func Open(state *lua.State) {
 		state.Require(lib.Name, lib.Open, true)
 		state.Pop()
 	}
+	if cfg.exec != nil {
+		os.SetExecPolicy(state, cfg.exec)
+	}
+	if len(cfg.lazy) > 0 {
+		state.GetGlobal("package")
+		state.GetField(-1, "lazy")
+		for _, name := range cfg.lazy {
+			state.Push(true)
+			state.SetField(-2, name)
+		}
+		state.PopN(2)
+	}
+	if cfg.stringExt {
+		str.OpenExt(state)
+		state.Pop()
+	}
+	if cfg.tableExt {
+		table.OpenExt(state)
+		state.Pop()
+	}
+	if cfg.numberMethods {
+		state.Push(0)                 // dummy number
+		state.NewTableSize(0, 1)      // table to be metatable for numbers
+		state.GetGlobal("math")       // get math library
+		state.SetField(-2, "__index") // metatable.__index = math
+		state.SetMetaTableAt(-2)      // set table as metatable for numbers
+		state.Pop()                   // pop dummy number
+	}
+	// Extension libraries are not loaded eagerly; they are registered
+	// in package.preload so scripts can require them on demand.
+	var exts = []struct {
+		Name string
+		Open lua.Func
+	}{
+		{"ai", lua.Func(ai.Open)},
+		{"collections", lua.Func(collections.Open)},
+		{"config", lua.Func(luaconfig.Open)},
+		{"csv", lua.Func(csv.Open)},
+		{"data", lua.Func(data.Open)},
+		{"datetime", lua.Func(datetime.Open)},
+		{"events", lua.Func(events.Open)},
+		{"heap", lua.Func(heap.Open)},
+		{"i18n", lua.Func(i18n.Open)},
+		{"path", lua.Func(path.Open)},
+		{"record", lua.Func(record.Open)},
+		{"schedule", lua.Func(schedule.Open)},
+		{"services", lua.Func(services.Open)},
+		{"syncx", lua.Func(syncx.Open)},
+		{"template", lua.Func(template.Open)},
+		{"vec", lua.Func(vec.Open)},
+		{"xml", lua.Func(xml.Open)},
+	}
+	names := make([]string, len(exts))
+	for i, ext := range exts {
+		state.Preload(ext.Name, ext.Open)
+		names[i] = ext.Name
+	}
+	openGoLua(state, &cfg, names)
 }
@@ -0,0 +1,213 @@
+package str
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Azure/golua/lua"
+	strutil "github.com/Azure/golua/pkg/strings"
+)
+
+//
+// Lua Extension Library -- string
+//
+
+// OpenExt adds the string extension functions (split, trim, ltrim, rtrim,
+// startswith, endswith, lpad, rpad and collate) to the string library, loading the
+// library first if necessary. Since the string library is the __index of
+// the string metatable, the extensions are also available as methods, for
+// example ("a,b"):split(",").
+//
+// The extensions are not part of standard Lua and must be enabled explicitly,
+// either by calling OpenExt or with std.WithStringExt.
+func OpenExt(state *lua.State) int {
+	var strExtFuncs = map[string]lua.Func{
+		"collate":    lua.Func(strCollate),
+		"endswith":   lua.Func(strEndsWith),
+		"lpad":       lua.Func(strLPad),
+		"ltrim":      lua.Func(strLTrim),
+		"rpad":       lua.Func(strRPad),
+		"rtrim":      lua.Func(strRTrim),
+		"split":      lua.Func(strSplit),
+		"startswith": lua.Func(strStartsWith),
+		"trim":       lua.Func(strTrim),
+	}
+	state.Require("string", Open, true)
+	state.SetFuncs(strExtFuncs, 0)
+
+	// Return 'string' table.
+	return 1
+}
+
+// string.split (s [, sep [, limit [, plain]]])
+//
+// Splits s around each occurrence of the separator sep and returns the pieces
+// as a sequence. By default sep is a pattern (see §6.4.1); if plain is true it
+// is matched literally. If sep is absent or nil, s is split around runs of
+// whitespace and empty pieces are dropped.
+//
+// If limit is given and positive, at most limit pieces are returned; the last
+// piece holds the unsplit remainder of s.
+func strSplit(state *lua.State) int {
+	var (
+		s     = state.CheckString(1)
+		limit = lua.ClampInt(state.OptInt(3, 0))
+		parts []string
+	)
+	switch {
+	case state.IsNoneOrNil(2):
+		parts = splitFields(s, limit)
+	case state.ToBool(4):
+		sep := state.CheckString(2)
+		state.ArgCheck(sep != "", 2, "empty separator")
+		if limit <= 0 {
+			limit = -1
+		}
+		parts = strings.SplitN(s, sep, limit)
+	default:
+		var (
+			sep  = state.CheckString(2)
+			last = 0
+		)
+		state.ArgCheck(sep != "", 2, "empty separator")
+		for _, loc := range strutil.FindAll(s, sep, limit-1) {
+			if loc[1] == loc[0] { // skip empty matches
+				continue
+			}
+			parts = append(parts, s[last:loc[0]])
+			last = loc[1]
+		}
+		parts = append(parts, s[last:])
+	}
+	state.NewTableSize(len(parts), 0)
+	for i, part := range parts {
+		state.Push(part)
+		state.RawSetIndex(-2, i+1)
+	}
+	return 1
+}
+
+// splitFields splits s around runs of whitespace, returning at most limit
+// fields if limit is positive.
+func splitFields(s string, limit int) []string {
+	if limit <= 0 {
+		return strings.Fields(s)
+	}
+	var parts []string
+	for s = strings.TrimLeft(s, spaces); s != "" && len(parts) < limit-1; s = strings.TrimLeft(s, spaces) {
+		i := strings.IndexAny(s, spaces)
+		if i < 0 {
+			break
+		}
+		parts = append(parts, s[:i])
+		s = s[i:]
+	}
+	if s != "" {
+		parts = append(parts, s)
+	}
+	return parts
+}
+
+// spaces is the set of characters matched by the %s pattern class.
+const spaces = " \t\n\v\f\r"
+
+// string.trim (s [, chars])
+//
+// Returns a copy of s with all leading and trailing characters contained in
+// chars removed. The default for chars is whitespace.
+func strTrim(state *lua.State) int {
+	state.Push(strings.Trim(state.CheckString(1), state.OptString(2, spaces)))
+	return 1
+}
+
+// string.ltrim (s [, chars])
+//
+// Like string.trim, but only removes leading characters.
+func strLTrim(state *lua.State) int {
+	state.Push(strings.TrimLeft(state.CheckString(1), state.OptString(2, spaces)))
+	return 1
+}
+
+// string.rtrim (s [, chars])
+//
+// Like string.trim, but only removes trailing characters.
+func strRTrim(state *lua.State) int {
+	state.Push(strings.TrimRight(state.CheckString(1), state.OptString(2, spaces)))
+	return 1
+}
+
+// string.startswith (s, prefix)
+//
+// Returns true if s begins with prefix.
+func strStartsWith(state *lua.State) int {
+	state.Push(strings.HasPrefix(state.CheckString(1), state.CheckString(2)))
+	return 1
+}
+
+// string.endswith (s, suffix)
+//
+// Returns true if s ends with suffix.
+func strEndsWith(state *lua.State) int {
+	state.Push(strings.HasSuffix(state.CheckString(1), state.CheckString(2)))
+	return 1
+}
+
+// string.collate (a, b [, locale])
+//
+// Compares a and b for display sorting in the given locale (for example "sv"
+// or "de_DE.UTF-8") and returns -1, 0 or 1. Letters sort case- and accent-
+// insensitively first, so "apple" sorts before "Banana", while the relational
+// operators compare bytes and put "Banana" first. Without a locale the root
+// order is used; the "C" locale compares bytes.
+//
+//	table.sort(names, function(a, b) return string.collate(a, b, "sv") < 0 end)
+func strCollate(state *lua.State) int {
+	var (
+		a      = state.CheckString(1)
+		b      = state.CheckString(2)
+		locale = state.OptString(3, "")
+	)
+	state.Push(strutil.Collate(a, b, locale))
+	return 1
+}
+
+// string.lpad (s, n [, pad])
+//
+// Returns s padded on the left with the string pad (default a space) so that it
+// is at least n characters long. Lengths are counted in UTF-8 characters.
+func strLPad(state *lua.State) int {
+	s, fill := pad(state)
+	state.Push(fill + s)
+	return 1
+}
+
+// string.rpad (s, n [, pad])
+//
+// Returns s padded on the right with the string pad (default a space) so that it
+// is at least n characters long. Lengths are counted in UTF-8 characters.
+func strRPad(state *lua.State) int {
+	s, fill := pad(state)
+	state.Push(s + fill)
+	return 1
+}
+
+// pad returns the string argument and the padding needed to extend it to
+// the requested width.
+func pad(state *lua.State) (s, fill string) {
+	var (
+		n = lua.ClampInt(state.CheckInt(2))
+		p = state.OptString(3, " ")
+	)
+	s = state.CheckString(1)
+	state.ArgCheck(p != "", 3, "empty padding")
+	need := n - utf8.RuneCountInString(s)
+	if need <= 0 {
+		return s, ""
+	}
+	if need > (lua.MaxSize-len(s))/len(p) {
+		state.Raise(lua.MsgStringTooLarge)
+	}
+	runes := []rune(p)
+	fill = strings.Repeat(p, need/len(runes)) + string(runes[:need%len(runes)])
+	return s, fill
+}
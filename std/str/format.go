@@ -25,7 +25,7 @@ func format(state *lua.State, format string, argc int) string {
 			continue
 		}
 		if arg > argc {
-			state.Errorf("bad argument #%d to 'format' (no value)", arg)
+			state.Raise(lua.MsgFormatNoValue, arg)
 			return ""
 		}
 		o := fmtOpt(state, format[i:])
@@ -43,13 +43,13 @@ func fmtArg(state *lua.State, opt string, arg int, verb byte) string {
 	case 'e', 'E', 'f', 'g', 'G':
 		return fmt.Sprintf(opt, state.CheckNumber(arg))
 	case 'o', 'x', 'X':
-		return fmt.Sprintf(opt, uint(state.CheckInt(arg)))
+		return fmt.Sprintf(opt, uint64(state.CheckInt(arg)))
 	case 'i':
 		opt = opt[:len(opt)-1] + "d"
 		return fmt.Sprintf(opt, state.CheckInt(arg))
 	case 'u':
 		opt = opt[:len(opt)-1] + "d"
-		return fmt.Sprintf(opt, uint(state.CheckInt(arg)))
+		return fmt.Sprintf(opt, uint64(state.CheckInt(arg)))
 	case 'c':
 		return string([]byte{byte(state.ToInt(arg))})
 	case 'd':
@@ -61,7 +61,7 @@ func fmtArg(state *lua.State, opt string, arg int, verb byte) string {
 		)
 		s, ok := state.TryString(arg)
 		if !ok && s == "" {
-			state.ArgError(arg, "value has no literal form")
+			state.ArgError(arg, state.Message(lua.MsgFormatLiteral))
 		}
 		if q {
 			b.WriteByte('"')
@@ -91,11 +91,11 @@ func fmtArg(state *lua.State, opt string, arg int, verb byte) string {
 		if len(opt) > 2 {
 			// If the option has any modifier (flags, width, length),
 			// the string argument should not contain embedded zeros.
-			state.ArgCheck(strings.Count(s, "\x00") == 0, arg, "strings contains zeros")
+			state.ArgCheck(strings.Count(s, "\x00") == 0, arg, "string contains zeros")
 		}
 		return fmt.Sprintf(opt, s)
 	default:
-		state.Errorf("invalid option '%%%c' to 'format'", verb)
+		state.Raise(lua.MsgFormatOption, verb)
 		return ""
 	}
 }
@@ -134,7 +134,7 @@ func fmtOpt(state *lua.State, format string) string {
 		index++
 	}
 	if index >= len(flags) {
-		state.Errorf("invalid format (repeated flags)")
+		state.Raise(lua.MsgFormatFlags)
 		return ""
 	}
 	digit()
@@ -146,7 +146,7 @@ func fmtOpt(state *lua.State, format string) string {
 		digit()
 	}
 	if unicode.IsDigit(rune(format[index])) {
-		state.Errorf("invalid format (width or precision too long)")
+		state.Raise(lua.MsgFormatWidth)
 		return ""
 	}
 	index++
@@ -1,12 +1,11 @@
 package str
 
 import (
-	"fmt"
 	"strings"
 
 	"github.com/Azure/golua/lua"
 	"github.com/Azure/golua/pkg/packer"
-	strutil "github.com/Azure/golua/pkg/strings"
+	"github.com/Azure/golua/pkg/pattern"
 )
 
 //
@@ -68,7 +67,7 @@ func strByte(state *lua.State) int {
 	s := state.CheckString(1)
 	i := state.OptInt(2, 1)
 	j := state.OptInt(3, i)
-	b := byteSlice(s, int(i), int(j))
+	b := byteSlice(s, lua.ClampInt(i), lua.ClampInt(j))
 	for _, c := range b {
 		state.Push(int64(c))
 	}
@@ -187,26 +186,26 @@ func strFormat(state *lua.State) int {
 //
 // https://www.lua.org/manual/5.3/manual.html#pdf-string.gmatch
 func strGmatch(state *lua.State) int {
-	// var (
-	// 	subj = state.CheckString(1)
-	// 	patt = state.CheckString(2)
-	// )
-	// iter, err := strs.MatchIter(subj, patt)
-	// if err != nil {
-	// 	panic(err)
-	// }
-	// state.Push(func(state *lua.State) int {
-	// 	next, more := iter()
-	// 	if !more {
-	// 		return 0
-	// 	}
-	// 	state.Push(next)
-	// 	return 1
-	// })
-	// return 1
-	fmt.Println("string.gmatch")
-	state.Debug(true)
-	return 0
+	var (
+		subj = state.CheckString(1)
+		patt = pattern.Compile(state.CheckString(2))
+		pos  = 0
+		last = -1 // end of last match
+	)
+	state.Push(func(state *lua.State) int {
+		for ; pos <= len(subj); pos++ {
+			m, err := patt.MatchAt(subj, pos)
+			if err != nil {
+				patternError(state, err)
+			}
+			if m != nil && m.End != last {
+				pos, last = m.End, m.End
+				return pushCaptures(state, m, true)
+			}
+		}
+		return 0 // not found
+	})
+	return 1
 }
 
 // string.match (s, pattern [, init])
@@ -218,25 +217,7 @@ func strGmatch(state *lua.State) int {
 //
 // https://www.lua.org/manual/5.3/manual.html#pdf-string.match
 func strMatch(state *lua.State) int {
-	s, p := state.CheckString(1), state.CheckString(2)
-	init := strPos(len(s), int(state.OptInt(3, 1)))
-	switch {
-	case init > len(s)+1:
-		state.Push(nil)
-		return 1
-	case init < 1:
-		init = 1
-	}
-	init--
-	caps := strutil.Match(s[init:], p)
-	for caps == nil {
-		state.Push(nil)
-		return 1
-	}
-	for _, match := range caps {
-		state.Push(match)
-	}
-	return len(caps)
+	return strFindAux(state, false)
 }
 
 // string.find (s, pattern [, init [, plain]])
@@ -259,37 +240,57 @@ func strMatch(state *lua.State) int {
 //
 // https://www.lua.org/manual/5.3/manual.html#pdf-string.find
 func strFind(state *lua.State) int {
+	return strFindAux(state, true)
+}
+
+// strFindAux implements string.find (find is true) and string.match.
+func strFindAux(state *lua.State, find bool) int {
 	s, p := state.CheckString(1), state.CheckString(2)
-	init := strPos(len(s), int(state.OptInt(3, 1)))
+	init := strPos(len(s), lua.ClampInt(state.OptInt(3, 1)))
 	switch {
-	case init > len(s)+1:
-		state.Push(nil)
-		return 1
 	case init < 1:
 		init = 1
+	case init > len(s)+1: // start after string's end?
+		state.Push(nil) // cannot find anything
+		return 1
 	}
 	init--
-	if state.ToBool(4) {
-		pos := strings.Index(s[init:], p)
-		if pos < 0 {
-			state.Push(nil)
-			return 1
+	// explicit request or no special characters?
+	if find && (state.ToBool(4) || pattern.IsPlain(p)) {
+		if pos := strings.Index(s[init:], p); pos >= 0 {
+			state.Push(init + pos + 1)
+			state.Push(init + pos + len(p))
+			return 2
+		}
+	} else {
+		m, err := pattern.Compile(p).Find(s, init)
+		if err != nil {
+			patternError(state, err)
+		}
+		if m != nil {
+			if find {
+				state.Push(m.Start + 1) // start
+				state.Push(m.End)       // end
+				return pushCaptures(state, m, false) + 2
+			}
+			return pushCaptures(state, m, true)
 		}
-		state.Push(init + pos + 1)
-		state.Push(init + pos + len(p))
-		return 2
 	}
-	start, end, caps, ok := find(s, p, init)
-	if !ok {
-		state.Push(nil)
-		return 1
+	state.Push(nil) // not found
+	return 1
+}
+
+// pushCaptures pushes the captures of m, or the whole match if whole is true
+// and the pattern has no captures, and returns their number.
+func pushCaptures(state *lua.State, m *pattern.Match, whole bool) int {
+	caps, err := m.Captures(whole)
+	if err != nil {
+		patternError(state, err)
 	}
-	state.Push(start)
-	state.Push(end)
-	_ = caps
-	// state.Push(init+loc[0]+1)
-	// state.Push(loc[1])
-	return 2
+	for _, cap := range caps {
+		state.Push(cap)
+	}
+	return len(caps)
 }
 
 // string.gsub (s, pattern, repl [, n])
@@ -340,32 +341,128 @@ func strFind(state *lua.State) int {
 //
 // https://www.lua.org/manual/5.3/manual.html#pdf-string.gsub
 func strGsub(state *lua.State) int {
-	subj := state.CheckString(1)
-	patt := state.CheckString(2)
-	upto := int(state.OptInt(4, int64(len(subj))))
 	var (
-		s string
-		n int
+		src  = state.CheckString(1)
+		patt = pattern.Compile(state.CheckString(2))
+		tr   = state.TypeAt(3)
+		max  = state.OptInt(4, int64(len(src)+1)) // max replacements
+		pos  = 0
+		last = -1 // end of last match
+		n    = int64(0)
+		b    strings.Builder
 	)
-	switch state.TypeAt(3) {
-	case lua.StringType:
-		repl := state.CheckString(3)
-		s, n = strutil.GsubStrAll(
-			subj,
-			patt,
-			repl,
-			upto,
-		)
-	// case lua.TableType:
-	// case lua.FuncType:
-	default:
-		state.CheckAny(3)
+	state.ArgCheck(tr == lua.NumberType || tr == lua.StringType ||
+		tr == lua.FuncType || tr == lua.TableType, 3, "string/function/table expected")
+	for n < max {
+		m, err := patt.MatchAt(src, pos)
+		if err != nil {
+			patternError(state, err)
+		}
+		if m != nil && m.End != last { // match?
+			n++
+			addValue(state, &b, m, tr) // add replacement to buffer
+			pos, last = m.End, m.End
+		} else if pos < len(src) { // otherwise, skip one character
+			b.WriteByte(src[pos])
+			pos++
+		} else {
+			break // end of subject
+		}
+		if patt.Anchored() {
+			break
+		}
 	}
-	state.Push(s)
-	state.Push(n)
+	b.WriteString(src[pos:])
+	state.Push(b.String())
+	state.Push(n) // number of substitutions
 	return 2
 }
 
+// addValue adds to b the replacement of the match m by the repl argument of
+// string.gsub, of type tr.
+func addValue(state *lua.State, b *strings.Builder, m *pattern.Match, tr lua.Type) {
+	switch tr {
+	case lua.FuncType: // call the function
+		state.PushIndex(3)
+		n := pushCaptures(state, m, true)
+		state.Call(n, 1)
+	case lua.TableType: // index the table
+		pushCapture(state, m, 0)
+		state.GetTable(3)
+	default: // lua.NumberType or lua.StringType
+		addString(state, b, m)
+		return
+	}
+	switch {
+	case !state.ToBool(-1): // nil or false?
+		b.WriteString(m.String()) // keep original text
+	case !state.IsString(-1):
+		state.Raise(lua.MsgReplaceValue, state.TypeName(-1))
+	default:
+		b.WriteString(state.ToString(-1)) // add result to accumulator
+	}
+	state.Pop()
+}
+
+// addString adds to b the replacement string of string.gsub for the match m,
+// expanding its %0-%9 and %% sequences.
+func addString(state *lua.State, b *strings.Builder, m *pattern.Match) {
+	repl := state.ToString(3)
+	for i := 0; i < len(repl); i++ {
+		if repl[i] != '%' {
+			b.WriteByte(repl[i])
+			continue
+		}
+		switch i++; {
+		case i < len(repl) && repl[i] == '%':
+			b.WriteByte('%')
+		case i < len(repl) && '0' <= repl[i] && repl[i] <= '9':
+			if repl[i] == '0' {
+				b.WriteString(m.String())
+				break
+			}
+			pushCapture(state, m, int(repl[i]-'1'))
+			b.WriteString(state.ToString(-1))
+			state.Pop()
+		default:
+			state.Raise(lua.MsgReplacePercent)
+		}
+	}
+}
+
+// patternMessages are the messages of the errors of the pattern matcher.
+var patternMessages = [...]lua.MsgID{
+	pattern.ErrTooComplex:        lua.MsgPatTooComplex,
+	pattern.ErrFrontier:          lua.MsgPatFrontier,
+	pattern.ErrEndsWithPercent:   lua.MsgPatEndsPercent,
+	pattern.ErrMissingBracket:    lua.MsgPatBracket,
+	pattern.ErrBalance:           lua.MsgPatBalance,
+	pattern.ErrTooManyCaptures:   lua.MsgPatTooManyCaps,
+	pattern.ErrInvalidCapture:    lua.MsgPatCapture,
+	pattern.ErrCaptureIndex:      lua.MsgPatCaptureIdx,
+	pattern.ErrUnfinishedCapture: lua.MsgPatUnfinished,
+}
+
+// patternError raises err, an error of the pattern matcher.
+func patternError(state *lua.State, err error) {
+	if err, ok := err.(*pattern.Error); ok {
+		if err.Code == pattern.ErrCaptureIndex {
+			state.Raise(lua.MsgPatCaptureIdx, err.Index)
+		}
+		state.Raise(patternMessages[err.Code])
+	}
+	state.Errorf("%v", err)
+}
+
+// pushCapture pushes capture i of m.
+func pushCapture(state *lua.State, m *pattern.Match, i int) {
+	cap, err := m.Capture(i)
+	if err != nil {
+		patternError(state, err)
+	}
+	state.Push(cap)
+}
+
 // string.len (s)
 //
 // Receives a string and returns its length. The empty string "" has length 0.
@@ -385,7 +482,7 @@ func strLen(state *lua.State) int {
 //
 // https://www.lua.org/manual/5.3/manual.html#pdf-string.lower
 func strLower(state *lua.State) int {
-	state.Push(strings.ToLower(state.CheckString(1)))
+	state.Push(toLower(state.CheckString(1)))
 	return 1
 }
 
@@ -398,9 +495,9 @@ func strLower(state *lua.State) int {
 func strPackSize(state *lua.State) int {
 	size, err := packer.Size(state.CheckString(1))
 	if err != nil {
-		state.Errorf("%v", err)
+		packError(state, err)
 	}
-	state.Push(size)
+	state.Push(int64(size))
 	return 1
 }
 
@@ -412,9 +509,23 @@ func strPackSize(state *lua.State) int {
 //
 // https://www.lua.org/manual/5.3/manual.html#pdf-string.unpack
 func strUnpack(state *lua.State) int {
-	fmt.Println("string.unpack: TODO")
-	state.Debug(true)
-	return 0
+	var (
+		format = state.CheckString(1)
+		data   = state.CheckString(2)
+		pos    = strPos(len(data), lua.ClampInt(state.OptInt(3, 1))) - 1
+	)
+	values, next, err := packer.Unpack(format, data, pos)
+	if err != nil {
+		packError(state, err)
+	}
+	if !state.CheckStack(len(values) + 1) {
+		state.Raise(lua.MsgTooManyResults)
+	}
+	for _, v := range values {
+		state.Push(v)
+	}
+	state.Push(int64(next + 1))
+	return len(values) + 1
 }
 
 // string.pack (fmt, v1, v2, ···)
@@ -424,15 +535,27 @@ func strUnpack(state *lua.State) int {
 //
 // https://www.lua.org/manual/5.3/manual.html#pdf-string.pack
 func strPack(state *lua.State) int {
-	// b, err := strs.Pack(state.CheckString(1), state.PopN(state.Top()-1)...)
-	// if err != nil {
-	// 	panic(err)
-	// }
-	// state.Push(string(b))
-	// return 1
-	fmt.Println("string.pack: TODO")
-	state.Debug(true)
-	return 0
+	b, err := packer.Pack(state.CheckString(1), packValues{state})
+	if err != nil {
+		packError(state, err)
+	}
+	state.Push(string(b))
+	return 1
+}
+
+// packValues are the arguments of string.pack.
+type packValues struct{ state *lua.State }
+
+func (v packValues) Int(arg int) int64     { return v.state.CheckInt(arg) }
+func (v packValues) Float(arg int) float64 { return v.state.CheckNumber(arg) }
+func (v packValues) String(arg int) string { return v.state.CheckString(arg) }
+
+// packError raises err, an error of the packer.
+func packError(state *lua.State, err error) {
+	if err, ok := err.(*packer.ArgError); ok {
+		state.ArgError(err.Arg, err.Msg)
+	}
+	state.Errorf("%v", err)
 }
 
 // string.rep (s, n [, sep])
@@ -447,9 +570,9 @@ func strPack(state *lua.State) int {
 //
 // https://www.lua.org/manual/5.3/manual.html#pdf-string.rep
 func strRep(state *lua.State) int {
-	s, err := repeat(state.CheckString(1), state.OptString(3, ""), state.CheckInt(2))
-	if err != nil {
-		state.Errorf("%v", err)
+	s, ok := repeat(state.CheckString(1), state.OptString(3, ""), state.CheckInt(2))
+	if !ok {
+		state.Raise(lua.MsgStringTooLarge)
 	}
 	state.Push(s)
 	return 1
@@ -483,8 +606,8 @@ func strReverse(state *lua.State) int {
 // https://www.lua.org/manual/5.3/manual.html#pdf-string.sub
 func strSub(state *lua.State) int {
 	s := state.CheckString(1)
-	i := int(state.OptInt(2, 1))
-	j := int(state.OptInt(3, -1))
+	i := lua.ClampInt(state.OptInt(2, 1))
+	j := lua.ClampInt(state.OptInt(3, -1))
 	state.Push(subStr(s, i, j))
 	return 1
 }
@@ -497,6 +620,6 @@ func strSub(state *lua.State) int {
 //
 // https://www.lua.org/manual/5.3/manual.html#pdf-string.upper
 func strUpper(state *lua.State) int {
-	state.Push(strings.ToUpper(state.CheckString(1)))
+	state.Push(toUpper(state.CheckString(1)))
 	return 1
 }
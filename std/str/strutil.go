@@ -1,23 +1,24 @@
 package str
 
 import (
-	"fmt"
 	"strings"
 
-	strutil "github.com/Azure/golua/pkg/strings"
+	"github.com/Azure/golua/lua"
 )
 
-func repeat(str, sep string, count int64) (string, error) {
-	switch length := int64(len(str + sep)); {
+// repeat returns count copies of str separated by sep, or false if the
+// result would be too large.
+func repeat(str, sep string, count int64) (string, bool) {
+	switch length := int64(len(str) + len(sep)); {
 	case count <= 0:
-		return "", nil
+		return "", true
 	case count == 1:
-		return str, nil
-	case length*count/count != length:
-		return "", fmt.Errorf("resulting string too large")
+		return str, true
+	case length > int64(lua.MaxSize)/count:
+		return "", false
 	}
 	rep := strings.Repeat(str+sep, int(count))
-	return strings.TrimSuffix(rep, sep), nil
+	return strings.TrimSuffix(rep, sep), true
 }
 
 // strPos converts a relative string position: negative means back
@@ -59,15 +60,37 @@ func subStr(str string, beg, end int) (sub string) {
 	return str[beg : end+1]
 }
 
+// reverse reverses the bytes of str.
 func reverse(str string) string {
-	var (
-		runes = []rune(str)
-		count = len(runes)
-	)
-	for i, j := 0, count-1; i < count/2; i, j = i+1, j-1 {
-		runes[i], runes[j] = runes[j], runes[i]
+	b := []byte(str)
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
 	}
-	return string(runes)
+	return string(b)
+}
+
+// toUpper converts the ASCII letters of str to upper case, leaving other
+// bytes unchanged as the C locale does.
+func toUpper(str string) string {
+	b := []byte(str)
+	for i, c := range b {
+		if 'a' <= c && c <= 'z' {
+			b[i] = c - 'a' + 'A'
+		}
+	}
+	return string(b)
+}
+
+// toLower converts the ASCII letters of str to lower case, leaving other
+// bytes unchanged as the C locale does.
+func toLower(str string) string {
+	b := []byte(str)
+	for i, c := range b {
+		if 'A' <= c && c <= 'Z' {
+			b[i] = c - 'A' + 'a'
+		}
+	}
+	return string(b)
 }
 
 func byteSlice(str string, beg, end int) (bytes []byte) {
@@ -77,12 +100,3 @@ func byteSlice(str string, beg, end int) (bytes []byte) {
 	}
 	return []byte(str[beg : end+1])
 }
-
-func find(s, p string, init int) (beg, end int, caps []string, ok bool) {
-	if loc := strutil.Find(s[init:], p); loc != nil {
-		beg = loc[0] + init + 1
-		end = loc[1] + init
-		ok = true
-	}
-	return
-}
@@ -0,0 +1,325 @@
+package std
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Azure/golua/lua"
+)
+
+func TestStringPatterns(t *testing.T) {
+	state := lua.NewState()
+	defer state.Close()
+	Open(state)
+
+	var tests = []struct {
+		fn   string
+		args []interface{}
+		want string
+	}{
+		{"find", []interface{}{"hello world", "o w"}, "[5 7]"},
+		{"find", []interface{}{"hello world", "l+"}, "[3 4]"},
+		{"find", []interface{}{"hello world", "o", 6}, "[8 8]"},
+		{"find", []interface{}{"a.b", ".", 1, true}, "[2 2]"},
+		{"find", []interface{}{"key = value", "(%w+)%s*=%s*(%w+)"}, "[1 11 key value]"},
+		{"find", []interface{}{"abc", "^b"}, "[nil]"},
+		{"find", []interface{}{"abc", "()b()"}, "[2 2 2 3]"},
+		{"match", []interface{}{"hello 123 world", "%d+"}, "[123]"},
+		{"match", []interface{}{"2024-01-15", "(%d+)-(%d+)-(%d+)"}, "[2024 01 15]"},
+		{"match", []interface{}{"  trim  ", "^%s*(.-)%s*$"}, "[trim]"},
+		{"match", []interface{}{"f(a(b)c)d", "%b()"}, "[(a(b)c)]"},
+		{"match", []interface{}{"THE (quick) fox", "%f[%a]%a+", 5}, "[quick]"},
+		{"match", []interface{}{"xyyx", "(.)(.)%2%1"}, "[x y]"},
+		{"match", []interface{}{"[]]", "[]]+"}, "[]]]"},
+		{"match", []interface{}{"a-b_c", "[%w_%-]+"}, "[a-b_c]"},
+		{"match", []interface{}{"abc", "[^a-b]"}, "[c]"},
+		{"match", []interface{}{"abc", "x*"}, "[]"},
+		{"gsub", []interface{}{"hello world", "(%w+)", "<%1>"}, "[<hello> <world> 2]"},
+		{"gsub", []interface{}{"hello world", "%w+", "%0 %0", 1}, "[hello hello world 1]"},
+		{"gsub", []interface{}{"abc", "", "-"}, "[-a-b-c- 4]"},
+		{"gsub", []interface{}{"abc", "^a", "%%"}, "[%bc 1]"},
+		{"gsub", []interface{}{"hello world", "o", 0}, "[hell0 w0rld 2]"},
+	}
+	for _, test := range tests {
+		got := fmt.Sprint(call(t, state, "string", test.fn, test.args...))
+		if got != test.want {
+			t.Errorf("string.%s%v = %s; want %s", test.fn, test.args, got, test.want)
+		}
+	}
+
+	// gsub with a table and a function
+	state.NewTable()
+	state.Push("lua")
+	state.SetField(-2, "name")
+	vars := state.Pop()
+	if got := fmt.Sprint(call(t, state, "string", "gsub", "$name-$version", "%$(%w+)", vars)); got != "[lua-$version 2]" {
+		t.Errorf("gsub with table = %s", got)
+	}
+	upper := func(state *lua.State) int {
+		state.Push(fmt.Sprintf("%s=%d", state.ToString(2), len(state.ToString(1))))
+		return 1
+	}
+	if got := fmt.Sprint(call(t, state, "string", "gsub", "a=bb c=d", "(%w+)=(%w+)", lua.Func(upper))); got != "[bb=1 d=1 2]" {
+		t.Errorf("gsub with function = %s", got)
+	}
+
+	// gmatch
+	state.GetGlobal("string")
+	state.GetField(-1, "gmatch")
+	state.Push("from=world, to=Lua")
+	state.Push("(%w+)=(%w+)")
+	state.Call(2, 1)
+	var pairs []string
+	for {
+		state.PushIndex(-1)
+		state.Call(0, 2)
+		if state.IsNoneOrNil(-2) {
+			break
+		}
+		pairs = append(pairs, state.ToString(-2)+":"+state.ToString(-1))
+		state.Pop()
+		state.Pop()
+	}
+	state.SetTop(0)
+	if got := fmt.Sprint(pairs); got != "[from:world to:Lua]" {
+		t.Errorf("gmatch pairs = %s", got)
+	}
+
+	var errs = []struct {
+		fn   string
+		args []interface{}
+		want string
+	}{
+		{"find", []interface{}{"a", "a%"}, "malformed pattern (ends with '%')"},
+		{"match", []interface{}{"a", "[a"}, "malformed pattern (missing ']')"},
+		{"match", []interface{}{"a", "%b"}, "malformed pattern (missing arguments to '%b')"},
+		{"match", []interface{}{"a", "%fa"}, "missing '[' after '%f' in pattern"},
+		{"match", []interface{}{"a", "(a"}, "unfinished capture"},
+		{"match", []interface{}{"a", "a)"}, "invalid pattern capture"},
+		{"match", []interface{}{"aa", "(a)%2"}, "invalid capture index %2"},
+		{"gsub", []interface{}{"a", "a", "%2"}, "invalid capture index %2"},
+		{"gsub", []interface{}{"a", "a", "%x"}, "invalid use of '%' in replacement string"},
+	}
+	for _, test := range errs {
+		state.GetGlobal("string")
+		state.GetField(-1, test.fn)
+		for _, arg := range test.args {
+			state.Push(arg)
+		}
+		if err := state.PCall(len(test.args), 0, 0); err == nil || err.Error() != test.want {
+			t.Errorf("string.%s%v: error = %v; want %q", test.fn, test.args, err, test.want)
+		}
+		state.SetTop(0)
+	}
+}
+
+func TestStringPack(t *testing.T) {
+	state := lua.NewState()
+	defer state.Close()
+	Open(state)
+
+	var packs = []struct {
+		args []interface{}
+		want string
+	}{
+		{[]interface{}{"<i4", 1}, "\x01\x00\x00\x00"},
+		{[]interface{}{">i4", -2}, "\xff\xff\xff\xfe"},
+		{[]interface{}{">I3 B", 0x010203, 255}, "\x01\x02\x03\xff"},
+		{[]interface{}{"<i16", -1}, strings.Repeat("\xff", 16)},
+		{[]interface{}{">d", 1.5}, "\x3f\xf8\x00\x00\x00\x00\x00\x00"},
+		{[]interface{}{"<f", 1}, "\x00\x00\x80\x3f"},
+		{[]interface{}{"z c3", "ab", "x"}, "ab\x00x\x00\x00"},
+		{[]interface{}{">s2", "hi"}, "\x00\x02hi"},
+		{[]interface{}{"<!4 b i4", 1, 2}, "\x01\x00\x00\x00\x02\x00\x00\x00"},
+		{[]interface{}{"<!8 b Xi8 h", 1, 2}, "\x01\x00\x00\x00\x00\x00\x00\x00\x02\x00"},
+		{[]interface{}{"bxb", 1, 2}, "\x01\x00\x02"},
+	}
+	for _, test := range packs {
+		if got := call(t, state, "string", "pack", test.args...); len(got) != 1 || got[0] != test.want {
+			t.Errorf("string.pack%q = %q; want %q", test.args, got, test.want)
+		}
+	}
+
+	var unpacks = []struct {
+		args []interface{}
+		want string
+	}{
+		{[]interface{}{"<i4", "\x01\x00\x00\x00"}, "[1 5]"},
+		{[]interface{}{">i2 B", "\xff\xfe\x07"}, "[-2 7 4]"},
+		{[]interface{}{"<I2", "xx\x01\x02", 3}, "[513 5]"},
+		{[]interface{}{"<I2", "\x01\x02\x03", -2}, "[770 4]"},
+		{[]interface{}{"z s1 c2", "ab\x00\x02cdef"}, "[ab cd ef 9]"},
+		{[]interface{}{"<!4 b i4", "\x01\x00\x00\x00\x02\x00\x00\x00"}, "[1 2 9]"},
+		{[]interface{}{"<i9", "\xfe" + strings.Repeat("\xff", 8)}, "[-2 10]"},
+	}
+	for _, test := range unpacks {
+		if got := fmt.Sprint(call(t, state, "string", "unpack", test.args...)); got != test.want {
+			t.Errorf("string.unpack%q = %s; want %s", test.args, got, test.want)
+		}
+	}
+	state.GetGlobal("string")
+	state.GetField(-1, "unpack")
+	state.Push("<d")
+	state.Push("\x00\x00\x00\x00\x00\x00\xf8\x3f")
+	if state.Call(2, 1); state.ToNumber(-1) != 1.5 {
+		t.Errorf("string.unpack('<d', ...) = %v; want 1.5", state.ToNumber(-1))
+	}
+	state.SetTop(0)
+
+	if got := call(t, state, "string", "packsize", "!8 b d i2 c5"); len(got) != 1 || got[0] != int64(23) {
+		t.Errorf("string.packsize = %v; want 23", got)
+	}
+
+	var errs = []struct {
+		fn   string
+		args []interface{}
+		want string
+	}{
+		{"pack", []interface{}{"i1", 128}, "bad argument #2 to 'string.pack' (integer overflow)"},
+		{"pack", []interface{}{"b I1", 0, -1}, "bad argument #3 to 'string.pack' (unsigned overflow)"},
+		{"pack", []interface{}{"c2", "abc"}, "bad argument #2 to 'string.pack' (string longer than given size)"},
+		{"pack", []interface{}{"z", "a\x00b"}, "bad argument #2 to 'string.pack' (string contains zeros)"},
+		{"pack", []interface{}{"s1", strings.Repeat("a", 256)}, "bad argument #2 to 'string.pack' (string length does not fit in given size)"},
+		{"pack", []interface{}{"i17", 0}, "integral size (17) out of limits [1,16]"},
+		{"pack", []interface{}{"y", 0}, "invalid format option 'y'"},
+		{"pack", []interface{}{"c", ""}, "missing size for format option 'c'"},
+		{"pack", []interface{}{"!3 i3", 0}, "bad argument #1 to 'string.pack' (format asks for alignment not power of 2)"},
+		{"pack", []interface{}{"Xc1"}, "bad argument #1 to 'string.pack' (invalid next option for option 'X')"},
+		{"packsize", []interface{}{"s"}, "bad argument #1 to 'string.packsize' (variable-length format)"},
+		{"unpack", []interface{}{"i4", "abc"}, "bad argument #2 to 'string.unpack' (data string too short)"},
+		{"unpack", []interface{}{"z", "abc"}, "bad argument #2 to 'string.unpack' (unfinished string for format 'z')"},
+		{"unpack", []interface{}{"b", "a", 3}, "bad argument #3 to 'string.unpack' (initial position out of string)"},
+		{"unpack", []interface{}{"i9", strings.Repeat("\x01", 9)}, "9-byte integer does not fit into Lua Integer"},
+	}
+	for _, test := range errs {
+		state.GetGlobal("string")
+		state.GetField(-1, test.fn)
+		for _, arg := range test.args {
+			state.Push(arg)
+		}
+		if err := state.PCall(len(test.args), 0, 0); err == nil || err.Error() != test.want {
+			t.Errorf("string.%s%q: error = %v; want %q", test.fn, test.args, err, test.want)
+		}
+		state.SetTop(0)
+	}
+}
+
+func TestStringExt(t *testing.T) {
+	state := lua.NewState()
+	defer state.Close()
+	Open(state, WithStringExt(true))
+
+	split := func(args ...interface{}) string {
+		state.GetGlobal("string")
+		state.GetField(-1, "split")
+		for _, arg := range args {
+			state.Push(arg)
+		}
+		if err := state.PCall(len(args), 1, 0); err != nil {
+			return err.Error()
+		}
+		defer state.SetTop(0)
+		var parts []string
+		for i := 1; i <= state.RawLen(-1); i++ {
+			state.RawGetIndex(-1, i)
+			parts = append(parts, fmt.Sprintf("%q", state.ToString(-1)))
+			state.Pop()
+		}
+		return strings.Join(parts, " ")
+	}
+	var splits = []struct {
+		args []interface{}
+		want string
+	}{
+		{[]interface{}{"  a b\t\nc  "}, `"a" "b" "c"`},
+		{[]interface{}{"  a b  c ", nil, 2}, `"a" "b  c "`},
+		{[]interface{}{"a,b,,c", ","}, `"a" "b" "" "c"`},
+		{[]interface{}{"a,b,,c", ",", 2}, `"a" "b,,c"`},
+		{[]interface{}{"a1b22c", "%d+"}, `"a" "b" "c"`},
+		{[]interface{}{"a.b.c", ".", 0, true}, `"a" "b" "c"`},
+		{[]interface{}{"a.b.c", ".", 2, true}, `"a" "b.c"`},
+		{[]interface{}{"abc", "x*"}, `"abc"`}, // empty matches do not split
+		{[]interface{}{"", ","}, `""`},
+		{[]interface{}{"a", ""}, "bad argument #2 to 'string.split' (empty separator)"},
+	}
+	for _, tt := range splits {
+		if got := split(tt.args...); got != tt.want {
+			t.Errorf("string.split%q = %s; want %s", tt.args, got, tt.want)
+		}
+	}
+
+	var tests = []struct {
+		fn   string
+		args []interface{}
+		want string
+	}{
+		{"trim", []interface{}{" \t a b \n"}, "[a b]"},
+		{"trim", []interface{}{"xxaxbxx", "x"}, "[axb]"},
+		{"ltrim", []interface{}{"  a  "}, "[a  ]"},
+		{"rtrim", []interface{}{"--a--", "-"}, "[--a]"},
+		{"lpad", []interface{}{"7", 3, "0"}, "[007]"},
+		{"lpad", []interface{}{"long", 2}, "[long]"},
+		{"rpad", []interface{}{"é", 3}, "[é  ]"},
+		{"rpad", []interface{}{"a", 6, "xyz"}, "[axyzxy]"},
+	}
+	for _, tt := range tests {
+		if got := fmt.Sprint(call(t, state, "string", tt.fn, tt.args...)); got != tt.want {
+			t.Errorf("string.%s%q = %s; want %s", tt.fn, tt.args, got, tt.want)
+		}
+	}
+	for _, tt := range []struct {
+		fn, s, affix string
+		want         bool
+	}{
+		{"startswith", "golua", "go", true},
+		{"startswith", "golua", "lua", false},
+		{"endswith", "golua", "lua", true},
+		{"endswith", "golua", "", true},
+		{"endswith", "a", "ba", false},
+	} {
+		state.GetGlobal("string")
+		state.GetField(-1, tt.fn)
+		state.Push(tt.s)
+		state.Push(tt.affix)
+		state.Call(2, 1)
+		if got := state.ToBool(-1); got != tt.want {
+			t.Errorf("string.%s(%q, %q) = %t; want %t", tt.fn, tt.s, tt.affix, got, tt.want)
+		}
+		state.SetTop(0)
+	}
+}
+
+func TestStringMessages(t *testing.T) {
+	state := lua.NewState(lua.WithMessages(lua.Messages{
+		lua.MsgPatBracket:   "motif mal formé (']' manquant)",
+		lua.MsgReplaceValue: "valeur de remplacement invalide (%s)",
+	}))
+	defer state.Close()
+	Open(state)
+
+	state.NewTable()
+	state.Push(true)
+	state.SetField(-2, "a")
+	repl := state.Pop()
+	var tests = []struct {
+		fn   string
+		args []interface{}
+		want string
+	}{
+		{"find", []interface{}{"abc", "[a"}, "motif mal formé (']' manquant)"},
+		{"gsub", []interface{}{"abc", "%w", repl}, "valeur de remplacement invalide (boolean)"},
+		{"match", []interface{}{"abc", "%1"}, "invalid capture index %1"},
+	}
+	for _, test := range tests {
+		state.GetGlobal("string")
+		state.GetField(-1, test.fn)
+		for _, arg := range test.args {
+			state.Push(arg)
+		}
+		if err := state.PCall(len(test.args), 0, 0); err == nil || err.Error() != test.want {
+			t.Errorf("string.%s%v: error = %v; want %q", test.fn, test.args, err, test.want)
+		}
+		state.SetTop(0)
+	}
+}
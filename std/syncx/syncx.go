@@ -0,0 +1,495 @@
+package syncx
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Azure/golua/lua"
+)
+
+//
+// Lua Extension Library -- syncx
+//
+
+// Open opens the syncx library. The library provides mutexes, read-write
+// mutexes, wait groups and semaphores backed by Go synchronization, for
+// coordinating scripts that run on several states and share external
+// resources:
+//
+//	local m = syncx.mutex()
+//	if m:lock(0.5) then -- wait at most half a second
+//		-- use the resource
+//		m:unlock()
+//	end
+//
+// The primitives are Go values with methods, so that an embedder can create
+// one in Go, such as with new(syncx.Mutex), and push it onto several states
+// with State.Push; the scripts of all the states then share it.
+//
+// Methods that wait take an optional timeout in seconds, and return false if
+// it elapses first; without a timeout they wait as long as needed. Scripts
+// running in a coroutine do not block while waiting: the method yields the
+// primitive to the resumer instead, and tries again when resumed, so that a
+// scheduler written in Lua can run other coroutines meanwhile. On the main
+// thread, they block.
+//
+// The library is not opened by default; it is available through require "syncx".
+func Open(state *lua.State) int {
+	// Create 'syncx' table.
+	var syncxFuncs = map[string]lua.Func{
+		"mutex":     lua.Func(syncxMutex),
+		"rwmutex":   lua.Func(syncxRWMutex),
+		"semaphore": lua.Func(syncxSemaphore),
+		"waitgroup": lua.Func(syncxWaitGroup),
+	}
+	state.NewTableSize(0, len(syncxFuncs))
+	state.SetFuncs(syncxFuncs, 0)
+
+	// Return 'syncx' table.
+	return 1
+}
+
+// gate is the state of a primitive and its waiters. The conditions of the
+// primitive are tested and changed with mu held; changed is closed whenever
+// they change, to wake up the waiters.
+type gate struct {
+	mu      sync.Mutex
+	changed chan struct{}
+}
+
+// acquire calls try until it succeeds, waiting for the gate to change between
+// attempts until the deadline, unless it is zero. It reports whether try
+// succeeded.
+func (g *gate) acquire(try func() bool, deadline time.Time) bool {
+	var timeout <-chan time.Time
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	for {
+		g.mu.Lock()
+		if try() {
+			g.mu.Unlock()
+			return true
+		}
+		if g.changed == nil {
+			g.changed = make(chan struct{})
+		}
+		changed := g.changed
+		g.mu.Unlock()
+		select {
+		case <-changed:
+		case <-timeout:
+			return false
+		}
+	}
+}
+
+// attempt calls try once, reporting whether it succeeded.
+func (g *gate) attempt(try func() bool) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return try()
+}
+
+// update calls fn to change the gate and wakes up the waiters, unless fn
+// fails.
+func (g *gate) update(fn func() error) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if err := fn(); err != nil {
+		return err
+	}
+	if g.changed != nil {
+		close(g.changed)
+		g.changed = nil
+	}
+	return nil
+}
+
+// deadline returns the deadline in timeout from now.
+func deadline(timeout time.Duration) time.Time { return time.Now().Add(timeout) }
+
+// Mutex is a mutual exclusion lock. The zero value is an unlocked mutex.
+type Mutex struct {
+	gate
+	locked bool
+}
+
+// Lock locks m, waiting until it is available.
+func (m *Mutex) Lock() { m.acquire(m.tryLock, time.Time{}) }
+
+// TryLock locks m, waiting at most timeout, and reports whether it did.
+func (m *Mutex) TryLock(timeout time.Duration) bool { return m.acquire(m.tryLock, deadline(timeout)) }
+
+// Unlock unlocks m. It is an error to unlock a mutex that is not locked.
+func (m *Mutex) Unlock() error {
+	return m.update(func() error {
+		if !m.locked {
+			return errors.New("unlock of unlocked mutex")
+		}
+		m.locked = false
+		return nil
+	})
+}
+
+func (m *Mutex) tryLock() bool {
+	if m.locked {
+		return false
+	}
+	m.locked = true
+	return true
+}
+
+// Methods implements lua.HasMethods.
+func (*Mutex) Methods() map[string]lua.Func {
+	return map[string]lua.Func{
+		"lock": func(state *lua.State) int {
+			m := checkMutex(state)
+			return wait(state, &m.gate, m.tryLock, 2)
+		},
+		"trylock": func(state *lua.State) int {
+			m := checkMutex(state)
+			state.Push(m.attempt(m.tryLock))
+			return 1
+		},
+		"unlock": func(state *lua.State) int {
+			release(state, checkMutex(state).Unlock())
+			return 0
+		},
+	}
+}
+
+func checkMutex(state *lua.State) *Mutex {
+	return state.CheckGoValue(1, (*Mutex)(nil)).(*Mutex)
+}
+
+// RWMutex is a reader/writer mutual exclusion lock, which can be held by any
+// number of readers or by a single writer. Writers waiting for the lock keep
+// new readers from acquiring it. The zero value is an unlocked mutex.
+type RWMutex struct {
+	gate
+	readers int  // readers holding the lock
+	writer  bool // a writer holds the lock
+	writers int  // writers waiting for the lock
+}
+
+// Lock locks rw for writing, waiting until it is available.
+func (rw *RWMutex) Lock() { rw.lock(time.Time{}) }
+
+// TryLock locks rw for writing, waiting at most timeout, and reports whether
+// it did.
+func (rw *RWMutex) TryLock(timeout time.Duration) bool { return rw.lock(deadline(timeout)) }
+
+func (rw *RWMutex) lock(deadline time.Time) bool {
+	defer rw.waiting()()
+	return rw.acquire(rw.tryLock, deadline)
+}
+
+// waiting counts a writer waiting for rw until the returned function is
+// called.
+func (rw *RWMutex) waiting() (done func()) {
+	rw.update(func() error { rw.writers++; return nil })
+	return func() { rw.update(func() error { rw.writers--; return nil }) }
+}
+
+// Unlock unlocks rw for writing. It is an error if rw is not locked for
+// writing.
+func (rw *RWMutex) Unlock() error {
+	return rw.update(func() error {
+		if !rw.writer {
+			return errors.New("unlock of unlocked rwmutex")
+		}
+		rw.writer = false
+		return nil
+	})
+}
+
+// RLock locks rw for reading, waiting until it is available.
+func (rw *RWMutex) RLock() { rw.acquire(rw.tryRLock, time.Time{}) }
+
+// TryRLock locks rw for reading, waiting at most timeout, and reports whether
+// it did.
+func (rw *RWMutex) TryRLock(timeout time.Duration) bool {
+	return rw.acquire(rw.tryRLock, deadline(timeout))
+}
+
+// RUnlock undoes a single RLock. It is an error if rw is not locked for
+// reading.
+func (rw *RWMutex) RUnlock() error {
+	return rw.update(func() error {
+		if rw.readers == 0 {
+			return errors.New("runlock of unlocked rwmutex")
+		}
+		rw.readers--
+		return nil
+	})
+}
+
+func (rw *RWMutex) tryLock() bool {
+	if rw.writer || rw.readers > 0 {
+		return false
+	}
+	rw.writer = true
+	return true
+}
+
+func (rw *RWMutex) tryRLock() bool {
+	if rw.writer || rw.writers > 0 {
+		return false
+	}
+	rw.readers++
+	return true
+}
+
+// Methods implements lua.HasMethods.
+func (*RWMutex) Methods() map[string]lua.Func {
+	return map[string]lua.Func{
+		"lock": func(state *lua.State) int {
+			rw := checkRWMutex(state)
+			defer rw.waiting()()
+			return wait(state, &rw.gate, rw.tryLock, 2)
+		},
+		"trylock": func(state *lua.State) int {
+			rw := checkRWMutex(state)
+			state.Push(rw.attempt(rw.tryLock))
+			return 1
+		},
+		"unlock": func(state *lua.State) int {
+			release(state, checkRWMutex(state).Unlock())
+			return 0
+		},
+		"rlock": func(state *lua.State) int {
+			rw := checkRWMutex(state)
+			return wait(state, &rw.gate, rw.tryRLock, 2)
+		},
+		"tryrlock": func(state *lua.State) int {
+			rw := checkRWMutex(state)
+			state.Push(rw.attempt(rw.tryRLock))
+			return 1
+		},
+		"runlock": func(state *lua.State) int {
+			release(state, checkRWMutex(state).RUnlock())
+			return 0
+		},
+	}
+}
+
+func checkRWMutex(state *lua.State) *RWMutex {
+	return state.CheckGoValue(1, (*RWMutex)(nil)).(*RWMutex)
+}
+
+// WaitGroup waits for a collection of tasks to finish. The zero value is a
+// wait group with no task.
+type WaitGroup struct {
+	gate
+	count int64
+}
+
+// Add adds delta, which may be negative, to the count of tasks of wg. It is an
+// error for the count to become negative.
+func (wg *WaitGroup) Add(delta int64) error {
+	return wg.update(func() error {
+		if wg.count+delta < 0 {
+			return errors.New("negative waitgroup counter")
+		}
+		wg.count += delta
+		return nil
+	})
+}
+
+// Done decrements the count of tasks of wg.
+func (wg *WaitGroup) Done() error { return wg.Add(-1) }
+
+// Wait waits until the count of tasks of wg is zero.
+func (wg *WaitGroup) Wait() { wg.acquire(wg.done, time.Time{}) }
+
+// TryWait waits at most timeout for the count of tasks of wg to be zero, and
+// reports whether it is.
+func (wg *WaitGroup) TryWait(timeout time.Duration) bool {
+	return wg.acquire(wg.done, deadline(timeout))
+}
+
+func (wg *WaitGroup) done() bool { return wg.count == 0 }
+
+// Methods implements lua.HasMethods.
+func (*WaitGroup) Methods() map[string]lua.Func {
+	return map[string]lua.Func{
+		"add": func(state *lua.State) int {
+			release(state, checkWaitGroup(state).Add(state.OptInt(2, 1)))
+			return 0
+		},
+		"done": func(state *lua.State) int {
+			release(state, checkWaitGroup(state).Done())
+			return 0
+		},
+		"wait": func(state *lua.State) int {
+			wg := checkWaitGroup(state)
+			return wait(state, &wg.gate, wg.done, 2)
+		},
+	}
+}
+
+func checkWaitGroup(state *lua.State) *WaitGroup {
+	return state.CheckGoValue(1, (*WaitGroup)(nil)).(*WaitGroup)
+}
+
+// Semaphore is a weighted semaphore, which holders acquire with a weight up to
+// its size in total.
+type Semaphore struct {
+	gate
+	size, held int64
+}
+
+// NewSemaphore returns a semaphore of the given size.
+func NewSemaphore(size int64) *Semaphore { return &Semaphore{size: size} }
+
+// Acquire acquires s with weight n, waiting until it is available. It is an
+// error for n to exceed the size of s.
+func (s *Semaphore) Acquire(n int64) error {
+	if err := s.check(n); err != nil {
+		return err
+	}
+	s.acquire(s.tryAcquire(n), time.Time{})
+	return nil
+}
+
+// TryAcquire acquires s with weight n, waiting at most timeout, and reports
+// whether it did. It does not if n exceeds the size of s.
+func (s *Semaphore) TryAcquire(n int64, timeout time.Duration) bool {
+	return s.check(n) == nil && s.acquire(s.tryAcquire(n), deadline(timeout))
+}
+
+// Release releases the weight n of s. It is an error to release more than is
+// held.
+func (s *Semaphore) Release(n int64) error {
+	return s.update(func() error {
+		if n < 0 || n > s.held {
+			return errors.New("semaphore released more than held")
+		}
+		s.held -= n
+		return nil
+	})
+}
+
+// check checks that the weight n can be acquired.
+func (s *Semaphore) check(n int64) error {
+	if n < 0 || n > s.size {
+		return errors.New("semaphore weight out of range")
+	}
+	return nil
+}
+
+// tryAcquire returns the function that tries to acquire s with weight n.
+func (s *Semaphore) tryAcquire(n int64) func() bool {
+	return func() bool {
+		if s.held+n > s.size {
+			return false
+		}
+		s.held += n
+		return true
+	}
+}
+
+// Methods implements lua.HasMethods.
+func (*Semaphore) Methods() map[string]lua.Func {
+	return map[string]lua.Func{
+		"acquire": func(state *lua.State) int {
+			s := checkSemaphore(state)
+			n := state.OptInt(2, 1)
+			state.ArgCheck(s.check(n) == nil, 2, "weight out of range")
+			return wait(state, &s.gate, s.tryAcquire(n), 3)
+		},
+		"tryacquire": func(state *lua.State) int {
+			s := checkSemaphore(state)
+			n := state.OptInt(2, 1)
+			state.ArgCheck(s.check(n) == nil, 2, "weight out of range")
+			state.Push(s.attempt(s.tryAcquire(n)))
+			return 1
+		},
+		"release": func(state *lua.State) int {
+			s := checkSemaphore(state)
+			release(state, s.Release(state.OptInt(2, 1)))
+			return 0
+		},
+	}
+}
+
+func checkSemaphore(state *lua.State) *Semaphore {
+	return state.CheckGoValue(1, (*Semaphore)(nil)).(*Semaphore)
+}
+
+// wait acquires the primitive receiving the method call with try, waiting at
+// most the timeout in seconds at argument arg, if any, and pushes whether it
+// did. Coroutines yield the primitive while waiting instead of blocking.
+func wait(state *lua.State, g *gate, try func() bool, arg int) int {
+	var until time.Time
+	if !state.IsNoneOrNil(arg) {
+		until = deadline(time.Duration(state.CheckNumber(arg) * float64(time.Second)))
+	}
+	if !state.IsYieldable() {
+		state.Push(g.acquire(try, until))
+		return 1
+	}
+	top := state.Top()
+	for !g.attempt(try) {
+		if !until.IsZero() && !time.Now().Before(until) {
+			state.Push(false)
+			return 1
+		}
+		state.PushIndex(1)
+		state.Yield(1)
+		state.SetTop(top)
+	}
+	state.Push(true)
+	return 1
+}
+
+// release raises err, if any, as the error of the method call.
+func release(state *lua.State, err error) {
+	if err != nil {
+		state.Errorf("%v", err)
+	}
+}
+
+// syncx.mutex ()
+//
+// Returns a new unlocked mutex, with the methods lock([timeout]), trylock()
+// and unlock().
+func syncxMutex(state *lua.State) int {
+	state.Push(new(Mutex))
+	return 1
+}
+
+// syncx.rwmutex ()
+//
+// Returns a new unlocked read-write mutex, with the methods lock([timeout]),
+// trylock() and unlock() for writers, and rlock([timeout]), tryrlock() and
+// runlock() for readers.
+func syncxRWMutex(state *lua.State) int {
+	state.Push(new(RWMutex))
+	return 1
+}
+
+// syncx.waitgroup ()
+//
+// Returns a new wait group, with the methods add([n]), done() and
+// wait([timeout]).
+func syncxWaitGroup(state *lua.State) int {
+	state.Push(new(WaitGroup))
+	return 1
+}
+
+// syncx.semaphore (size)
+//
+// Returns a new semaphore of the given size, with the methods
+// acquire([n [, timeout]]), tryacquire([n]) and release([n]), whose weight n
+// defaults to 1.
+func syncxSemaphore(state *lua.State) int {
+	size := state.CheckInt(1)
+	state.ArgCheck(size > 0, 1, "size must be positive")
+	state.Push(NewSemaphore(size))
+	return 1
+}
@@ -0,0 +1,77 @@
+package std
+
+import (
+	"testing"
+
+	"github.com/Azure/golua/lua"
+	"github.com/Azure/golua/std/syncx"
+)
+
+// method calls the method name of the Go value obj pushed onto state and
+// returns its first result.
+func method(t *testing.T, state *lua.State, obj interface{}, name string, args ...interface{}) lua.Value {
+	t.Helper()
+	state.Push(obj)
+	state.GetField(-1, name)
+	state.Insert(-2)
+	for _, arg := range args {
+		state.Push(arg)
+	}
+	if err := state.PCall(1+len(args), 1, 0); err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	return state.Pop()
+}
+
+func TestSyncx(t *testing.T) {
+	state := lua.NewState()
+	defer state.Close()
+	Open(state)
+
+	// A mutex held in Go times out in Lua, and is shared once released.
+	m := new(syncx.Mutex)
+	m.Lock()
+	if got := method(t, state, m, "lock", 0.01); got != lua.False {
+		t.Errorf("lock(0.01) of a held mutex = %v; want false", got)
+	}
+	if got := method(t, state, m, "trylock"); got != lua.False {
+		t.Errorf("trylock() of a held mutex = %v; want false", got)
+	}
+	m.Unlock()
+	if got := method(t, state, m, "lock"); got != lua.True {
+		t.Errorf("lock() = %v; want true", got)
+	}
+	if m.TryLock(0) {
+		t.Error("mutex locked in Lua is not held in Go")
+	}
+
+	// Coroutines yield the mutex instead of blocking.
+	co := state.NewThread()
+	co.Push(lua.Func(func(co *lua.State) int {
+		co.Push(method(t, co, m, "lock"))
+		return 1
+	}))
+	if rets, err := state.Resume(co, 0); err != nil || rets != 1 || state.CheckGoValue(-1, m) != m {
+		t.Fatalf("resume = %d, %v; want the mutex yielded", rets, err)
+	}
+	state.Pop()
+	if err := m.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+	if rets, err := state.Resume(co, 0); err != nil || rets != 1 || !state.ToBool(-1) {
+		t.Fatalf("resume = %d, %v; want true", rets, err)
+	}
+	state.PopN(2)
+
+	// Semaphores are weighted and wait groups count down to zero.
+	s := syncx.NewSemaphore(3)
+	if err := s.Acquire(2); err != nil || s.TryAcquire(2, 0) || !s.TryAcquire(1, 0) {
+		t.Error("semaphore of size 3 does not hold weights 2 and 1")
+	}
+	var wg syncx.WaitGroup
+	wg.Add(1)
+	go wg.Done()
+	if got := method(t, state, &wg, "wait", 1); got != lua.True {
+		t.Errorf("wait(1) = %v; want true", got)
+	}
+}
@@ -0,0 +1,63 @@
+package table
+
+import (
+	"github.com/Azure/golua/lua"
+)
+
+// table.deepcopy (t [, seen [, meta]])
+//
+// Returns a deep copy of t: the tables reachable from t, as keys or values,
+// are copied recursively, and other values are shared. A table reachable in
+// several ways, including through cycles, is copied once, so the copy has the
+// same shape as t.
+//
+// The optional table seen maps tables to the copies to use in their place; it
+// is filled with the copies made, so it can be passed to several calls that
+// must share copies, or pre-filled to share some tables instead of copying
+// them (seen[x] = x). If meta is true the copies are given the metatable of
+// the table they copy; the metatables themselves are shared, not copied.
+//
+// Fields are copied raw, without calling metamethods.
+func tableDeepCopy(state *lua.State) int {
+	state.CheckType(1, lua.TableType)
+	meta := state.ToBool(3)
+	state.SetTop(2)
+	if state.IsNoneOrNil(2) {
+		state.NewTable()
+		state.Replace(2)
+	} else {
+		state.CheckType(2, lua.TableType)
+	}
+	deepCopy(state, 1, 2, meta)
+	return 1
+}
+
+// deepCopy pushes the copy of the value at index, using and filling the
+// table of copies at seen.
+func deepCopy(state *lua.State, index, seen int, meta bool) {
+	index = state.AbsIndex(index)
+	if state.TypeAt(index) != lua.TableType {
+		state.PushIndex(index)
+		return
+	}
+	state.PushIndex(index)
+	if state.RawGet(seen) > lua.NilType {
+		return // already copied
+	}
+	state.Pop()
+
+	state.NewTable()
+	dst := state.Top()
+	state.PushIndex(index)
+	state.PushIndex(dst)
+	state.RawSet(seen) // seen[t] = copy
+
+	for state.Push(nil); state.Next(index); state.Pop() {
+		deepCopy(state, -2, seen, meta) // key
+		deepCopy(state, -2, seen, meta) // value
+		state.RawSet(dst)
+	}
+	if meta && state.GetMetaTableAt(index) {
+		state.SetMetaTableAt(dst)
+	}
+}
@@ -0,0 +1,106 @@
+package table
+
+import (
+	"github.com/Azure/golua/lua"
+)
+
+//
+// Lua Extension Library -- table
+//
+
+// OpenExt adds the table extension functions (keys, values, count, isarray
+// and isempty) to the table library, loading the library first if necessary.
+//
+// The extensions are not part of standard Lua and must be enabled explicitly,
+// either by calling OpenExt or with std.WithTableExt.
+func OpenExt(state *lua.State) int {
+	var tableExtFuncs = map[string]lua.Func{
+		"count":   lua.Func(tableCount),
+		"isarray": lua.Func(tableIsArray),
+		"isempty": lua.Func(tableIsEmpty),
+		"keys":    lua.Func(tableKeys),
+		"values":  lua.Func(tableValues),
+	}
+	state.Require("table", Open, true)
+	state.SetFuncs(tableExtFuncs, 0)
+
+	// Return 'table' table.
+	return 1
+}
+
+// table.keys (t)
+//
+// Returns a new sequence holding the keys of t, in the order of next.
+// Metamethods are not called.
+func tableKeys(state *lua.State) int {
+	return collect(state, -2)
+}
+
+// table.values (t)
+//
+// Returns a new sequence holding the values of t, in the order of next.
+// Metamethods are not called.
+func tableValues(state *lua.State) int {
+	return collect(state, -1)
+}
+
+// collect returns a sequence of the keys (at -2) or values (at -1) of the
+// table argument.
+func collect(state *lua.State, at int) int {
+	state.CheckType(1, lua.TableType)
+	state.NewTable()
+	n := 0
+	for state.Push(nil); state.Next(1); state.Pop() {
+		n++
+		state.PushIndex(at)
+		state.RawSetIndex(2, n)
+	}
+	return 1
+}
+
+// table.count (t)
+//
+// Returns the number of entries of t, including those not counted by the
+// length operator because their key is not a positive integer or because the
+// sequence has holes. Metamethods are not called.
+func tableCount(state *lua.State) int {
+	state.CheckType(1, lua.TableType)
+	n := int64(0)
+	for state.Push(nil); state.Next(1); state.Pop() {
+		n++
+	}
+	state.Push(n)
+	return 1
+}
+
+// table.isarray (t)
+//
+// Returns true if t is a sequence without holes: if its keys are exactly the
+// integers 1 to n, for some n. An empty table is an array. Metamethods are
+// not called.
+func tableIsArray(state *lua.State) int {
+	state.CheckType(1, lua.TableType)
+	var n, max int64
+	for state.Push(nil); state.Next(1); state.Pop() {
+		k, ok := state.TryInt(-2)
+		if !ok || state.TypeAt(-2) != lua.NumberType || k < 1 {
+			state.Push(false)
+			return 1
+		}
+		if n++; k > max {
+			max = k
+		}
+	}
+	state.Push(max == n)
+	return 1
+}
+
+// table.isempty (t)
+//
+// Returns true if t has no entries. Metamethods are not called.
+func tableIsEmpty(state *lua.State) int {
+	state.CheckType(1, lua.TableType)
+	state.Push(nil)
+	state.Push(!state.Next(1))
+	return 1
+}
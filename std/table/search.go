@@ -0,0 +1,82 @@
+package table
+
+import (
+	"sort"
+
+	"github.com/Azure/golua/lua"
+)
+
+// table.bsearch (list, value [, key])
+//
+// Searches the sorted list for value using binary search. The list must be in
+// ascending order with respect to the standard Lua operator < applied to the
+// keys of its elements. If key is absent the elements themselves are compared;
+// if key is a function the element key is key(elem); otherwise it is elem[key].
+//
+// Returns the position of the first element whose key equals value. If there
+// is no such element, returns nil followed by the position at which value would
+// have to be inserted to keep the list sorted.
+func tableBSearch(state *lua.State) int {
+	n := int(length(state, 1, opRead))
+	value := state.CheckAny(2)
+
+	pos := sort.Search(n, func(i int) bool {
+		return !lessThan(state, keyAt(state, int64(i+1), 3), value)
+	})
+	if pos < n && !lessThan(state, value, keyAt(state, int64(pos+1), 3)) {
+		state.Push(pos + 1)
+		return 1
+	}
+	state.Push(nil)
+	state.Push(pos + 1)
+	return 2
+}
+
+// table.insert_sorted (list, value [, key])
+//
+// Inserts value into the sorted list, keeping it sorted, and returns the position
+// at which it was inserted. Keys are extracted as in table.bsearch; value is placed
+// after any elements with an equal key, so repeated insertions are stable.
+func tableInsertSorted(state *lua.State) int {
+	var (
+		n     = length(state, 1, opReadWrite)
+		value = state.CheckAny(2)
+		key   = keyOf(state, value, 3)
+	)
+	pos := int64(sort.Search(int(n), func(i int) bool {
+		return lessThan(state, key, keyAt(state, int64(i+1), 3))
+	})) + 1
+	for i := n + 1; i > pos; i-- { // move up elements
+		state.GetIndex(1, i-1)
+		state.SetIndex(1, i) // t[i] = t[i-1]
+	}
+	state.Push(value)
+	state.SetIndex(1, pos) // t[pos] = v
+	state.Push(pos)
+	return 1
+}
+
+// keyAt returns the key of the list element at position pos.
+func keyAt(state *lua.State, pos int64, key int) lua.Value {
+	state.GetIndex(1, pos)
+	return keyOf(state, state.Pop(), key)
+}
+
+// keyOf returns the key of elem as selected by the optional extractor at
+// index key: elem itself if absent, key(elem) if a function, or elem[key].
+func keyOf(state *lua.State, elem lua.Value, key int) lua.Value {
+	switch state.TypeAt(key) {
+	case lua.NoneType, lua.NilType:
+		return elem
+	case lua.FuncType:
+		state.PushIndex(key)
+		state.Push(elem)
+		state.Call(1, 1)
+		return state.Pop()
+	default:
+		state.Push(elem)
+		elem = fieldOf(state, -1, key)
+		state.Pop()
+		return elem
+	}
+}
@@ -0,0 +1,156 @@
+package table
+
+import (
+	"math"
+	"time"
+
+	"github.com/Azure/golua/lua"
+)
+
+// table.sort (list [, comp])
+//
+// Sorts list elements in a given order, in-place, from list[1] to list[#list].
+// If comp is given, then it must be a function that receives two list elements
+// and returns true when the first element must come before the second in the
+// final order (so that, after the sort, i < j implies not comp(list[j],list[i])).
+// If comp is not given, then the standard Lua operator < is used instead.
+//
+// Note that the comp function must define a strict partial order over the elements
+// in the list; that is, it must be asymmetric and transitive. Otherwise, no valid
+// sort may be possible.
+//
+// The sort algorithm is not stable: elements considered equal by the given order
+// may have their relative positions changed by the sort.
+//
+// As in the reference implementation, an inconsistent comp may raise the error
+// "invalid order function for sorting". The elements are sorted in a copy of the
+// list that is stored back once sorted, so if comp raises an error the list is
+// left unchanged.
+//
+// See https://www.lua.org/manual/5.3/manual.html#pdf-table.sort
+func tableSort(state *lua.State) int {
+	n := length(state, 1, opReadWrite)
+	if n <= 1 {
+		return 0
+	}
+	state.ArgCheck(n < math.MaxInt32, 1, "array too big")
+	s := sorter{state: state, list: make([]lua.Value, n)}
+	if !state.IsNoneOrNil(2) { // is there a 2nd argument?
+		state.CheckType(2, lua.FuncType)
+		s.comp = state.CheckAny(2)
+	}
+	for i := range s.list {
+		state.GetIndex(1, int64(i+1))
+		s.list[i] = state.Pop()
+	}
+	s.sort(0, len(s.list)-1, 0)
+	for i, v := range s.list {
+		state.Push(v)
+		state.SetIndex(1, int64(i+1))
+	}
+	return 0
+}
+
+// sortRandLimit is the size of the intervals above which the pivot is chosen
+// at random once a partition is found to be too imbalanced.
+const sortRandLimit = 100
+
+// sorter sorts list with the quicksort of the reference implementation, so
+// that inconsistent order functions are detected the same way.
+type sorter struct {
+	state *lua.State
+	list  []lua.Value
+	comp  lua.Value // order function, or nil for <
+}
+
+// less reports whether a must come before b.
+func (s *sorter) less(a, b lua.Value) bool {
+	if s.comp == nil {
+		return lessThan(s.state, a, b)
+	}
+	s.state.Push(s.comp)
+	s.state.Push(a)
+	s.state.Push(b)
+	s.state.Call(2, 1)
+	res := s.state.ToBool(-1)
+	s.state.Pop()
+	return res
+}
+
+// sort sorts list[lo..up], choosing pivots at random if rnd is not 0.
+func (s *sorter) sort(lo, up int, rnd uint) {
+	a := s.list
+	for lo < up { // loop for tail recursion
+		// sort elements lo, p and up
+		if s.less(a[up], a[lo]) {
+			a[lo], a[up] = a[up], a[lo]
+		}
+		if up-lo == 1 { // only 2 elements?
+			break
+		}
+		p := (lo + up) / 2 // middle element is a good pivot
+		if up-lo >= sortRandLimit && rnd != 0 {
+			r4 := (up - lo) / 4
+			p = int(rnd%uint(r4*2)) + lo + r4
+		}
+		if s.less(a[p], a[lo]) {
+			a[p], a[lo] = a[lo], a[p]
+		} else if s.less(a[up], a[p]) {
+			a[p], a[up] = a[up], a[p]
+		}
+		if up-lo == 2 { // only 3 elements?
+			break
+		}
+		a[p], a[up-1] = a[up-1], a[p]
+		p = s.partition(lo, up)
+		// a[lo .. p - 1] <= a[p] == P <= a[p + 1 .. up]
+		var n int // size of the smaller interval
+		if p-lo < up-p {
+			s.sort(lo, p-1, rnd)
+			n = p - lo
+			lo = p + 1
+		} else {
+			s.sort(p+1, up, rnd)
+			n = up - p
+			up = p - 1
+		}
+		if (up-lo)/128 > n { // partition too imbalanced?
+			rnd = uint(time.Now().UnixNano()) // try a new randomization
+		}
+	}
+}
+
+// partition partitions list[lo..up] around the pivot P in list[up-1] and
+// returns the final position of the pivot.
+func (s *sorter) partition(lo, up int) int {
+	var (
+		a = s.list
+		P = a[up-1]
+		i = lo     // will be incremented before first use
+		j = up - 1 // will be decremented before first use
+	)
+	// loop invariant: a[lo .. i] <= P <= a[j .. up], a[up - 1] == P
+	for {
+		// repeat ++i while a[i] < P
+		for i++; s.less(a[i], P); i++ {
+			if i == up-1 { // a[i] < P but a[up - 1] == P ??
+				s.state.Errorf("invalid order function for sorting")
+			}
+		}
+		// after the loop, a[i] >= P and a[lo .. i - 1] < P;
+		// repeat --j while P < a[j]
+		for j--; s.less(P, a[j]); j-- {
+			if j < i { // j < i but a[j] > P ??
+				s.state.Errorf("invalid order function for sorting")
+			}
+		}
+		// after the loop, a[j] <= P and a[j + 1 .. up] >= P
+		if j < i { // no elements to be exchanged?
+			// swap pivot (a[up - 1]) with a[i] to satisfy the invariant
+			a[up-1], a[i] = a[i], a[up-1]
+			return i
+		}
+		// otherwise, swap a[i] - a[j] to restore the invariant and repeat
+		a[i], a[j] = a[j], a[i]
+	}
+}
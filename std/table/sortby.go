@@ -0,0 +1,176 @@
+package table
+
+import (
+	"math"
+	"sort"
+
+	"github.com/Azure/golua/lua"
+)
+
+// table.sort_by (list, key [, desc])
+//
+// Sorts list elements in-place by the value each element holds at key, from
+// list[1] to list[#list]. The call table.sort_by(t, "x") orders t exactly as
+//
+//	table.sort(t, function(a, b) return a.x < b.x end)
+//
+// would, but the comparisons are done natively instead of calling back into
+// Lua for every pair. Numbers and strings are compared directly; any other key
+// values fall back to the standard Lua operator < (including metamethods). If
+// desc is true the list is sorted in descending order.
+//
+// Unlike table.sort, the sort is stable: elements with equal keys keep their
+// relative positions.
+func tableSortBy(state *lua.State) int {
+	n := length(state, 1, opReadWrite)
+	state.ArgCheck(!state.IsNoneOrNil(2), 2, "key expected")
+	desc := state.ToBool(3)
+
+	elems := make([]sortElem, n)
+	for i := range elems {
+		state.GetIndex(1, int64(i+1))
+		elems[i].keys = []lua.Value{fieldOf(state, -1, 2)}
+		elems[i].val = state.Pop()
+	}
+	sortElems(state, elems, []bool{desc})
+	return 0
+}
+
+// table.sort_by_keys (list, keys [, desc])
+//
+// Sorts list elements in-place by several keys, from list[1] to list[#list].
+// The sequence keys lists the keys to order by, most significant first; elements
+// that compare equal on one key are ordered by the next. The optional sequence
+// desc holds one boolean per key; a true entry sorts that key in descending order.
+//
+// For example, table.sort_by_keys(t, {"team", "score"}, {false, true}) groups t by
+// team and orders each team by decreasing score.
+//
+// Keys are extracted once per element before sorting (decorate-sort-undecorate),
+// so metamethods such as __index are invoked at most once per element and key.
+// Keys compare as in table.sort_by, and the sort is stable.
+func tableSortByKeys(state *lua.State) int {
+	n := length(state, 1, opReadWrite)
+	state.CheckType(2, lua.TableType)
+	nkeys := state.RawLen(2)
+	state.ArgCheck(nkeys > 0, 2, "empty key list")
+
+	desc := make([]bool, nkeys)
+	if !state.IsNoneOrNil(3) {
+		state.CheckType(3, lua.TableType)
+		for k := range desc {
+			state.RawGetIndex(3, k+1)
+			desc[k] = state.ToBool(-1)
+			state.Pop()
+		}
+	}
+
+	elems := make([]sortElem, n)
+	for i := range elems {
+		state.GetIndex(1, int64(i+1))
+		elems[i].keys = make([]lua.Value, nkeys)
+		for k := range elems[i].keys {
+			state.RawGetIndex(2, k+1)
+			elems[i].keys[k] = fieldOf(state, -2, -1)
+			state.Pop()
+		}
+		elems[i].val = state.Pop()
+	}
+	sortElems(state, elems, desc)
+	return 0
+}
+
+// sortElem pairs a list element with the keys it is ordered by.
+type sortElem struct {
+	val  lua.Value
+	keys []lua.Value
+}
+
+// sortElems stably sorts elems by their keys, most significant key first, and
+// stores the result back into the list at index 1.
+func sortElems(state *lua.State, elems []sortElem, desc []bool) {
+	sort.SliceStable(elems, func(i, j int) bool {
+		for k, desc := range desc {
+			a, b := elems[i].keys[k], elems[j].keys[k]
+			if desc {
+				a, b = b, a
+			}
+			if lessThan(state, a, b) {
+				return true
+			}
+			if lessThan(state, b, a) {
+				return false
+			}
+		}
+		return false
+	})
+	for i, elem := range elems {
+		state.Push(elem.val)
+		state.SetIndex(1, int64(i+1))
+	}
+}
+
+// fieldOf returns the value of v[k] where v is the value at index and k is the
+// value at key, honoring any __index metamethod.
+func fieldOf(state *lua.State, index, key int) lua.Value {
+	index = state.AbsIndex(index)
+	state.PushIndex(key)
+	state.GetTable(index)
+	return state.Pop()
+}
+
+// lessThan reports whether a < b. Numbers and strings are compared natively;
+// everything else goes through the Lua '<' operator.
+func lessThan(state *lua.State, a, b lua.Value) bool {
+	switch a := a.(type) {
+	case lua.Int:
+		switch b := b.(type) {
+		case lua.Int:
+			return a < b
+		case lua.Float:
+			return ltIntFloat(a, b)
+		}
+	case lua.Float:
+		switch b := b.(type) {
+		case lua.Float:
+			return a < b
+		case lua.Int:
+			return ltFloatInt(a, b)
+		}
+	case lua.String:
+		if b, ok := b.(lua.String); ok {
+			return a < b
+		}
+	}
+	state.Push(a)
+	state.Push(b)
+	less := state.Compare(lua.OpLt, -2, -1)
+	state.PopN(2)
+	return less
+}
+
+// ltIntFloat reports whether i < f, exactly even where i has no float
+// representation (LTintfloat in lvm.c).
+func ltIntFloat(i lua.Int, f lua.Float) bool {
+	if -1<<53 <= i && i <= 1<<53 {
+		return lua.Float(i) < f
+	}
+	// i < f <=> i < ceil(f)
+	if c := math.Ceil(float64(f)); -1<<63 <= c && c < 1<<63 {
+		return i < lua.Int(c)
+	}
+	return f > 0 // f is out of range of the integers, or NaN
+}
+
+// ltFloatInt reports whether f < i, exactly even where i has no float
+// representation (LTfloatint in lvm.c).
+func ltFloatInt(f lua.Float, i lua.Int) bool {
+	if -1<<53 <= i && i <= 1<<53 {
+		return f < lua.Float(i)
+	}
+	// f < i <=> floor(f) < i
+	if c := math.Floor(float64(f)); -1<<63 <= c && c < 1<<63 {
+		return lua.Int(c) < i
+	}
+	return f < 0 // f is out of range of the integers, or NaN
+}
@@ -1,8 +1,6 @@
 package table
 
 import (
-	"fmt"
-	"sort"
 	"strings"
 
 	"github.com/Azure/golua/lua"
@@ -32,8 +30,14 @@ func Open(state *lua.State) int {
 		"remove": lua.Func(tableRemove),
 		"move":   lua.Func(tableMove),
 		"sort":   lua.Func(tableSort),
+
+		"bsearch":       lua.Func(tableBSearch),
+		"deepcopy":      lua.Func(tableDeepCopy),
+		"insert_sorted": lua.Func(tableInsertSorted),
+		"sort_by":       lua.Func(tableSortBy),
+		"sort_by_keys":  lua.Func(tableSortByKeys),
 	}
-	state.NewTableSize(0, 7)
+	state.NewTableSize(0, len(tableFuncs))
 	state.SetFuncs(tableFuncs, 0)
 
 	// Return 'table' table.
@@ -49,28 +53,35 @@ func Open(state *lua.State) int {
 //
 // See https://www.lua.org/manual/5.3/manual.html#pdf-table.concat
 func tableConcat(state *lua.State) int {
-	len := length(state, 1, opRead)
-	sep := state.OptString(2, "")
-	i := state.OptInt(3, 1)
-	j := state.OptInt(4, len)
-
-	if i > j {
-		state.Push("")
-		return 1
+	var (
+		last = length(state, 1, opRead)
+		sep  = state.OptString(2, "")
+		i    = state.OptInt(3, 1)
+		b    strings.Builder
+	)
+	last = state.OptInt(4, last)
+	for ; i < last; i++ {
+		addField(state, &b, i)
+		b.WriteString(sep)
 	}
-	buf := make([]string, j-i+1)
-	for k := i; k > 0 && k <= j; k++ {
-		state.GetIndex(1, k)
-		if !state.IsString(-1) {
-			state.Errorf("invalid value (%s) at index %d in table for 'concat'", state.TypeAt(-1).String(), i)
-		}
-		buf[k-i] = state.ToString(-1)
-		state.Pop()
+	if i == last { // add last value (if interval was not empty)
+		addField(state, &b, i)
 	}
-	state.Push(strings.Join(buf, sep))
+	state.Push(b.String())
 	return 1
 }
 
+// addField appends list[i] to b, honoring any __index metamethod of the list.
+// It raises an error unless the value is a string or a number.
+func addField(state *lua.State, b *strings.Builder, i int64) {
+	state.GetIndex(1, i)
+	if !state.IsString(-1) {
+		state.Raise(lua.MsgConcatValue, state.TypeAt(-1).String(), i)
+	}
+	b.WriteString(state.ToString(-1))
+	state.Pop()
+}
+
 // table.insert (list, [pos,] value)
 //
 // Inserts element value at position pos in list, shifting up the elements
@@ -86,7 +97,7 @@ func tableInsert(state *lua.State) int {
 	switch state.Top() {
 	case 3:
 		if pos = state.CheckInt(2); pos < 1 || pos > len {
-			panic(fmt.Errorf("bad argument #2 to 'insert' (position out of bounds)"))
+			state.Raise(lua.MsgInsertPosition)
 		}
 		for i := len; i > pos; i-- { // move up elements
 			state.GetIndex(1, i-1)
@@ -95,7 +106,7 @@ func tableInsert(state *lua.State) int {
 	case 2: // called with 2 arguments
 		pos = len // insert new element at the end
 	default:
-		panic(fmt.Errorf("wrong number of arguments to 'insert'"))
+		state.Raise(lua.MsgInsertArgs)
 	}
 	state.SetIndex(1, pos) // t[pos] = v
 	return 0
@@ -126,7 +137,7 @@ func tablePack(state *lua.State) int {
 //
 // This function is equivalent to
 //
-//     return list[i], list[i+1], ···, list[j]
+//	return list[i], list[i+1], ···, list[j]
 //
 // By default, i is 1 and j is #list.
 //
@@ -136,18 +147,21 @@ func tableUnpack(state *lua.State) int {
 	var (
 		i = state.OptInt(2, 1)
 		j = state.OptInt(3, int64(state.RawLen(1)))
-		n = int(j - i + 1)
 	)
-	const max = 1000000
-	if n <= 0 || n >= max || !state.CheckStack(n) {
-		panic(fmt.Errorf("too many results to unpack"))
+	if i > j { // empty range
+		return 0
+	}
+	// number of elements minus 1, computed without overflow
+	n := uint64(j) - uint64(i)
+	if n >= uint64(state.UnpackLimit()) || !state.CheckStack(int(n+1)) {
+		state.Raise(lua.MsgUnpackResults)
 	}
 	for i < j {
 		state.GetIndex(1, i)
 		i++
 	}
 	state.GetIndex(1, j)
-	return n
+	return int(n + 1)
 }
 
 // table.remove (list [, pos])
@@ -167,11 +181,9 @@ func tableRemove(state *lua.State) int {
 		len = length(state, 1, opReadWrite)
 		pos = state.OptInt(2, len)
 	)
-	if (pos != len) && (pos < 1 || pos >= len+1) { // validate pos if given
-		// panic(fmt.Errorf("bad argument #2 to 'remove' (position out of bounds)"))
-		return 0
+	if pos != len && (pos < 1 || pos > len+1) { // validate pos if given
+		state.Raise(lua.MsgRemovePosition)
 	}
-	fmt.Println("top", pos, state.Top())
 	state.GetIndex(1, pos) // result = t[pos]
 	for ; pos < len; pos++ {
 		state.GetIndex(1, pos+1)
@@ -179,8 +191,6 @@ func tableRemove(state *lua.State) int {
 	}
 	state.Push(nil)
 	state.SetIndex(1, pos) // t[pos] = nil
-	fmt.Println("top", state.Top())
-
 	return 1
 }
 
@@ -199,7 +209,10 @@ func tableMove(state *lua.State) int {
 	e := state.CheckInt(3)
 	t := state.CheckInt(4)
 	// 目标table
-	tt := int(state.OptInt(5, 1))
+	tt := 1
+	if !state.IsNoneOrNil(5) {
+		tt = 5
+	}
 	checkTable(state, 1, opRead)
 	checkTable(state, tt, opWrite)
 	if e >= f { // othervise, nothing to move
@@ -227,81 +240,12 @@ func tableMove(state *lua.State) int {
 	return 1
 }
 
-type tableSorter struct {
-	state *lua.State
-	len   int
-}
-
-func (ts *tableSorter) Len() int {
-	return ts.len
-}
-
-func (ts *tableSorter) Less(i, j int) bool {
-	i++
-	j++
-
-	// fmt.Println("less >>>", i, j, ts.state.TypeAt(1), ts.state.TypeAt(2))
-
-	if ts.state.IsNone(2) { /* no function? */
-		ts.state.GetIndex(1, int64(i))
-		// fmt.Println(">>> after get index", ts.state.CheckAny(-1))
-		ts.state.GetIndex(1, int64(j))
-		ret := ts.state.Compare(lua.OpLt, -2, -1)
-		ts.state.PopN(2)
-		return ret
-	}
-
-	ts.state.PushIndex(2) // push the comp function
-	ts.state.GetIndex(1, int64(i))
-	ts.state.GetIndex(1, int64(j))
-	err := ts.state.PCall(2, 1, 0) /* call function */
-	if err != nil {
-		ts.state.Errorf("pcall error:%s", err.Error())
-	}
-	res := ts.state.ToBool(-1) /* get result */
-	ts.state.Pop()             /* pop result */
-	return res
-}
-
-func (ts *tableSorter) Swap(i, j int) {
-	i++
-	j++
-
-	ts.state.GetIndex(1, int64(j))
-	ts.state.GetIndex(1, int64(i))
-
-	ts.state.SetIndex(1, int64(j))
-	ts.state.SetIndex(1, int64(i))
-}
-
-// table.sort (list [, comp])
-//
-// Sorts list elements in a given order, in-place, from list[1] to list[#list].
-// If comp is given, then it must be a function that receives two list elements
-// and returns true when the first element must come before the second in the
-// final order (so that, after the sort, i < j implies not comp(list[j],list[i])).
-// If comp is not given, then the standard Lua operator < is used instead.
-//
-// Note that the comp function must define a strict partial order over the elements
-// in the list; that is, it must be asymmetric and transitive. Otherwise, no valid
-// sort may be possible.
-//
-// The sort algorithm is not stable: elements considered equal by the given order
-// may have their relative positions changed by the sort.
-//
-// See https://www.lua.org/manual/5.3/manual.html#pdf-table.sort
-func tableSort(state *lua.State) int {
-	ts := tableSorter{state: state, len: int(length(state, 1, opReadWrite))}
-	sort.Sort(&ts)
-
-	return 0
-}
-
 // operations that an object must define to mimic a table (some functions
 // only need some of them.)
 const (
 	opRead      = 1
 	opWrite     = 2
+	opLen       = 4
 	opReadWrite = opRead | opWrite
 )
 
@@ -309,17 +253,11 @@ const (
 // it has a metatable with the required metamethods.)
 func checkTable(state *lua.State, index, ops int) {
 	if state.TypeAt(index) != lua.TableType { // not a table?
-		n := 1                           // number of elements to pop
-		if state.GetMetaTableAt(index) { // must have metatable
-			if !((ops&opRead != 0) || checkField(state, "__index", n)) {
-				n++
-			}
-			if !((ops&opWrite != 0) || checkField(state, "__newindex", n)) {
-				n++
-			}
-			if !((ops&opRead != 0) || checkField(state, "__len", n)) {
-				n++
-			}
+		n := 1 // number of elements to pop
+		if state.GetMetaTableAt(index) && // must have metatable
+			(ops&opRead == 0 || checkField(state, "__index", &n)) &&
+			(ops&opWrite == 0 || checkField(state, "__newindex", &n)) &&
+			(ops&opLen == 0 || checkField(state, "__len", &n)) {
 			state.PopN(n) // pop metatable and tested metamethods
 		} else {
 			state.CheckType(index, lua.TableType) // force an error.
@@ -327,12 +265,16 @@ func checkTable(state *lua.State, index, ops int) {
 	}
 }
 
-func checkField(state *lua.State, key string, index int) bool {
+// checkField pushes the raw field key of the metatable below the n values at
+// the top of the stack, counting it in n, and reports whether it is not nil.
+func checkField(state *lua.State, key string, n *int) bool {
+	*n++
 	state.Push(key)
-	return state.RawGet(-index) != lua.NilType
+	state.RawGet(-*n)
+	return !state.IsNoneOrNil(-1)
 }
 
 func length(state *lua.State, index, ops int) int64 {
-	checkTable(state, index, ops)
+	checkTable(state, index, ops|opLen)
 	return int64(state.RawLen(index))
 }
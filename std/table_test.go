@@ -0,0 +1,339 @@
+package std
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/Azure/golua/lua"
+)
+
+func TestTableExt(t *testing.T) {
+	state := lua.NewState()
+	defer state.Close()
+	Open(state, WithTableExt(true))
+
+	state.NewTable()
+	for i, v := range []string{"a", "b"} {
+		state.Push(v)
+		state.RawSetIndex(-2, i+1)
+	}
+	state.Push("c")
+	state.SetField(-2, "x")
+	state.Push("d")
+	state.RawSetIndex(-2, 10)
+	state.SetGlobal("t")
+
+	state.GetGlobal("t")
+	arg := state.Pop()
+	if got := call(t, state, "table", "count", arg); len(got) != 1 || got[0] != int64(4) {
+		t.Errorf("table.count = %v; want 4", got)
+	}
+	elems := func(fn string) (s []string) {
+		state.GetGlobal("table")
+		state.GetField(-1, fn)
+		state.Push(arg)
+		if err := state.PCall(1, 1, 0); err != nil {
+			t.Fatalf("table.%s: %v", fn, err)
+		}
+		for i := 1; i <= state.RawLen(-1); i++ {
+			state.RawGetIndex(-1, i)
+			s = append(s, state.ToString(-1))
+			state.Pop()
+		}
+		state.SetTop(0)
+		sort.Strings(s)
+		return s
+	}
+	if got := elems("keys"); len(got) != 4 || got[0] != "1" || got[1] != "10" || got[2] != "2" || got[3] != "x" {
+		t.Errorf("table.keys = %q; want 1, 2, 10 and x", got)
+	}
+	if got := elems("values"); len(got) != 4 || got[0] != "a" || got[3] != "d" {
+		t.Errorf("table.values = %q; want a, b, c and d", got)
+	}
+
+	is := func(fn string, tbl lua.Value) bool {
+		state.GetGlobal("table")
+		state.GetField(-1, fn)
+		state.Push(tbl)
+		state.Call(1, 1)
+		defer state.SetTop(0)
+		return state.ToBool(-1)
+	}
+	list := func(keys ...int) lua.Value {
+		state.NewTable()
+		for _, k := range keys {
+			state.Push(true)
+			state.RawSetIndex(-2, k)
+		}
+		return state.Pop()
+	}
+	var tests = []struct {
+		tbl              lua.Value
+		isarray, isempty bool
+	}{
+		{arg, false, false},
+		{list(), true, true},
+		{list(1, 2, 3), true, false},
+		{list(1, 3), false, false},
+		{list(2), false, false},
+	}
+	for i, test := range tests {
+		if got := is("isarray", test.tbl); got != test.isarray {
+			t.Errorf("#%d: table.isarray = %t; want %t", i, got, test.isarray)
+		}
+		if got := is("isempty", test.tbl); got != test.isempty {
+			t.Errorf("#%d: table.isempty = %t; want %t", i, got, test.isempty)
+		}
+	}
+}
+
+func TestTableRemove(t *testing.T) {
+	state := lua.NewState()
+	defer state.Close()
+	Open(state)
+
+	var tests = []struct {
+		list []string
+		pos  interface{} // nil for no argument
+		want string      // removed value, "nil" or "error"
+		rest []string
+	}{
+		{[]string{"a", "b", "c"}, nil, "c", []string{"a", "b"}},
+		{[]string{"a", "b", "c"}, 1, "a", []string{"b", "c"}},
+		{[]string{"a", "b", "c"}, 2, "b", []string{"a", "c"}},
+		{[]string{"a", "b", "c"}, 3, "c", []string{"a", "b"}},
+		{[]string{"a", "b", "c"}, 4, "nil", []string{"a", "b", "c"}},
+		{[]string{"a", "b", "c"}, 5, "error", nil},
+		{[]string{"a", "b", "c"}, 0, "error", nil},
+		{[]string{"a", "b", "c"}, -1, "error", nil},
+		{nil, nil, "nil", nil},
+		{nil, 0, "nil", nil},
+		{nil, 1, "nil", nil},
+		{nil, 2, "error", nil},
+		{nil, -1, "error", nil},
+	}
+	for _, test := range tests {
+		state.GetGlobal("table")
+		state.GetField(-1, "remove")
+		state.NewTable()
+		for i, v := range test.list {
+			state.Push(v)
+			state.RawSetIndex(-2, i+1)
+		}
+		list := state.CheckAny(-1)
+		nargs := 1
+		if test.pos != nil {
+			state.Push(test.pos)
+			nargs++
+		}
+		err := state.PCall(nargs, 1, 0)
+		switch {
+		case test.want == "error":
+			if err == nil || err.Error() != "bad argument #2 to 'remove' (position out of bounds)" {
+				t.Errorf("remove(%q, %v) = %v; want position out of bounds", test.list, test.pos, err)
+			}
+			state.SetTop(0)
+			continue
+		case err != nil:
+			t.Errorf("remove(%q, %v): %v", test.list, test.pos, err)
+			state.SetTop(0)
+			continue
+		}
+		if got := state.ToString(-1); state.IsNil(-1) && test.want != "nil" || !state.IsNil(-1) && got != test.want {
+			t.Errorf("remove(%q, %v) = %v; want %s", test.list, test.pos, state.CheckAny(-1), test.want)
+		}
+		state.Push(list)
+		var rest []string
+		for i := 1; state.RawGetIndex(-1, i) > lua.NilType; i++ {
+			rest = append(rest, state.ToString(-1))
+			state.Pop()
+		}
+		if state.RawLen(-2) != len(test.rest) || strings.Join(rest, ",") != strings.Join(test.rest, ",") {
+			t.Errorf("remove(%q, %v) left %q; want %q", test.list, test.pos, rest, test.rest)
+		}
+		state.SetTop(0)
+	}
+}
+
+func TestTableConcat(t *testing.T) {
+	state := lua.NewState()
+	defer state.Close()
+	Open(state)
+
+	// list = {"a", 2, 3.0}, whose other integer keys k map to "<k>" through __index
+	state.NewTable()
+	for i, v := range []interface{}{"a", 2, 3.0} {
+		state.Push(v)
+		state.RawSetIndex(-2, i+1)
+	}
+	state.NewTable()
+	state.Push(func(state *lua.State) int {
+		state.Push("<" + state.ToString(2) + ">")
+		return 1
+	})
+	state.SetField(-2, "__index")
+	state.SetMetaTableAt(-2)
+	list := state.Pop()
+
+	var tests = []struct {
+		args []interface{}
+		want string
+	}{
+		{[]interface{}{}, "a23.0"},
+		{[]interface{}{", "}, "a, 2, 3.0"},
+		{[]interface{}{"-", 2}, "2-3.0"},
+		{[]interface{}{"-", 3, 3}, "3.0"},
+		{[]interface{}{"-", 4, 3}, ""},
+		{[]interface{}{"", -1, 1}, "<-1><0>a"},
+		{[]interface{}{",", 3, 5}, "3.0,<4>,<5>"},
+	}
+	for _, test := range tests {
+		got := call(t, state, "table", "concat", append([]interface{}{list}, test.args...)...)
+		if len(got) != 1 || got[0] != test.want {
+			t.Errorf("table.concat(list, %v) = %q; want %q", test.args, got, test.want)
+		}
+	}
+
+	state.GetGlobal("table")
+	state.GetField(-1, "concat")
+	state.NewTable()
+	state.Push("a")
+	state.RawSetIndex(-2, 1)
+	state.NewTable()
+	state.RawSetIndex(-2, 2)
+	err := state.PCall(1, 1, 0)
+	if want := "invalid value (table) at index 2 in table for 'concat'"; err == nil || err.Error() != want {
+		t.Errorf("table.concat({'a', {}}) = %v; want %q", err, want)
+	}
+}
+
+func TestTableDeepCopy(t *testing.T) {
+	state := lua.NewState()
+	defer state.Close()
+	Open(state)
+
+	// t = setmetatable({sub = {}}, {}); t.sub.back = t; t[t.sub] = 1
+	state.NewTable()
+	state.NewTable()
+	state.PushIndex(-2)
+	state.SetField(-2, "back")
+	state.PushIndex(-1)
+	state.SetField(-3, "sub")
+	state.Push(int64(1))
+	state.RawSet(-3)
+	state.NewTable()
+	state.SetMetaTableAt(-2)
+	orig := state.Pop()
+
+	deepcopy := func(args ...interface{}) lua.Value {
+		state.GetGlobal("table")
+		state.GetField(-1, "deepcopy")
+		for _, arg := range args {
+			state.Push(arg)
+		}
+		if err := state.PCall(len(args), 1, 0); err != nil {
+			t.Fatalf("table.deepcopy: %v", err)
+		}
+		state.Remove(-2)
+		return state.Pop()
+	}
+
+	cp := deepcopy(orig)
+	state.Push(cp)
+	if state.GetField(-1, "sub") != lua.TableType {
+		t.Fatalf("copy.sub is not a table")
+	}
+	state.Push(orig)
+	state.GetField(-1, "sub")
+	if state.RawEqual(-1, -3) {
+		t.Errorf("copy.sub is orig.sub")
+	}
+	state.Pop()
+	state.Pop()
+	if state.GetField(-1, "back"); !state.RawEqual(-1, -3) {
+		t.Errorf("copy.sub.back is not the copy")
+	}
+	state.Pop()
+	if state.RawGet(-2); state.ToInt(-1) != 1 {
+		t.Errorf("copy[copy.sub] = %v; want 1", state.ToString(-1))
+	}
+	if state.SetTop(1); state.GetMetaTableAt(1) {
+		t.Errorf("copy has a metatable without meta")
+	}
+	state.SetTop(0)
+
+	cp = deepcopy(orig, nil, true)
+	state.Push(cp)
+	state.Push(orig)
+	if !state.GetMetaTableAt(1) || !state.GetMetaTableAt(2) || !state.RawEqual(-1, -2) {
+		t.Errorf("copy does not share the metatable with meta")
+	}
+	state.SetTop(0)
+
+	// Copies made with the same seen table share their subtables.
+	state.NewTable()
+	seen := state.Pop()
+	cp = deepcopy(orig, seen)
+	state.Push(cp)
+	state.GetField(-1, "sub")
+	state.Push(orig)
+	state.GetField(-1, "sub")
+	cpsub := deepcopy(state.Pop(), seen)
+	state.Push(cpsub)
+	if !state.RawEqual(-1, 2) {
+		t.Errorf("copy of orig.sub with seen is not copy.sub")
+	}
+}
+
+func TestTableUnpackLimit(t *testing.T) {
+	state := lua.NewState(lua.WithUnpackLimit(3))
+	defer state.Close()
+	Open(state)
+
+	state.NewTable()
+	for i := 1; i <= 4; i++ {
+		state.Push(int64(i))
+		state.RawSetIndex(-2, i)
+	}
+	list := state.Pop()
+
+	if got := call(t, state, "table", "unpack", list, 2); len(got) != 3 {
+		t.Errorf("table.unpack(list, 2) = %v; want 3 values", got)
+	}
+	state.GetGlobal("table")
+	state.GetField(-1, "unpack")
+	state.Push(list)
+	err := state.PCall(1, lua.MultRets, 0)
+	if want := "too many results to unpack"; err == nil || err.Error() != want {
+		t.Errorf("table.unpack(list) = %v; want %q", err, want)
+	}
+}
+
+func TestTableUnpackStackMax(t *testing.T) {
+	state := lua.NewState(lua.WithStackMax(100))
+	defer state.Close()
+	Open(state)
+
+	if limit := state.UnpackLimit(); limit != 100 {
+		t.Errorf("UnpackLimit() = %d; want the stack size 100", limit)
+	}
+	state.NewTable()
+	for i := 1; i <= 100; i++ {
+		state.Push(int64(i))
+		state.RawSetIndex(-2, i)
+	}
+	list := state.Pop()
+
+	if got := call(t, state, "table", "unpack", list, 1, 50); len(got) != 50 {
+		t.Errorf("table.unpack(list, 1, 50) = %d values; want 50", len(got))
+	}
+	// The frames below table.unpack use part of the stack.
+	state.GetGlobal("table")
+	state.GetField(-1, "unpack")
+	state.Push(list)
+	err := state.PCall(1, lua.MultRets, 0)
+	if want := "too many results to unpack"; err == nil || err.Error() != want {
+		t.Errorf("table.unpack(list) = %v; want %q", err, want)
+	}
+}
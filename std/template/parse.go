@@ -0,0 +1,125 @@
+package template
+
+import (
+	"fmt"
+	"strings"
+)
+
+type nodeKind int
+
+const (
+	textNode     nodeKind = iota // literal text
+	varNode                      // {{name}}
+	rawNode                      // {{{name}}} or {{&name}}
+	sectionNode                  // {{#name}} ... {{/name}}
+	invertedNode                 // {{^name}} ... {{/name}}
+)
+
+// node is a parsed template element.
+type node struct {
+	kind  nodeKind
+	text  string   // literal text for textNode
+	path  []string // dotted name; nil for "."
+	nodes []node   // body of sections
+}
+
+const (
+	openDelim  = "{{"
+	closeDelim = "}}"
+)
+
+// parse parses a mustache-style template into a list of nodes.
+func parse(src string) ([]node, error) {
+	p := &parser{src: src}
+	nodes, closer, err := p.parseNodes()
+	if err != nil {
+		return nil, err
+	}
+	if closer != "" {
+		return nil, p.errorf("unexpected closing tag '%s'", closer)
+	}
+	return nodes, nil
+}
+
+type parser struct {
+	src string
+	pos int
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	line := 1 + strings.Count(p.src[:p.pos], "\n")
+	return fmt.Errorf("template:%d: %s", line, fmt.Sprintf(format, args...))
+}
+
+// parseNodes parses nodes until the end of input or a closing tag, whose
+// name is returned.
+func (p *parser) parseNodes() (nodes []node, closer string, err error) {
+	for p.pos < len(p.src) {
+		i := strings.Index(p.src[p.pos:], openDelim)
+		if i < 0 {
+			nodes = append(nodes, node{kind: textNode, text: p.src[p.pos:]})
+			p.pos = len(p.src)
+			break
+		}
+		if i > 0 {
+			nodes = append(nodes, node{kind: textNode, text: p.src[p.pos : p.pos+i]})
+		}
+		p.pos += i
+		start := p.pos
+		p.pos += len(openDelim)
+
+		end := closeDelim
+		if strings.HasPrefix(p.src[p.pos:], "{") {
+			end = "}" + closeDelim
+		}
+		j := strings.Index(p.src[p.pos:], end)
+		if j < 0 {
+			p.pos = start
+			return nil, "", p.errorf("unclosed tag")
+		}
+		tag := p.src[p.pos : p.pos+j]
+		p.pos += j + len(end)
+
+		if end != closeDelim { // {{{name}}}
+			nodes = append(nodes, node{kind: rawNode, path: splitName(tag[1:])})
+			continue
+		}
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			p.pos = start
+			return nil, "", p.errorf("empty tag")
+		}
+		switch sigil, name := tag[0], strings.TrimSpace(tag[1:]); sigil {
+		case '!': // comment
+		case '&':
+			nodes = append(nodes, node{kind: rawNode, path: splitName(name)})
+		case '#', '^':
+			body, closer, err := p.parseNodes()
+			if err != nil {
+				return nil, "", err
+			}
+			if closer != name {
+				p.pos = start
+				return nil, "", p.errorf("unclosed section '%s'", name)
+			}
+			kind := sectionNode
+			if sigil == '^' {
+				kind = invertedNode
+			}
+			nodes = append(nodes, node{kind: kind, path: splitName(name), nodes: body})
+		case '/':
+			return nodes, name, nil
+		default:
+			nodes = append(nodes, node{kind: varNode, path: splitName(tag)})
+		}
+	}
+	return nodes, "", nil
+}
+
+// splitName splits a dotted tag name into its components.
+func splitName(name string) []string {
+	if name = strings.TrimSpace(name); name == "." {
+		return nil
+	}
+	return strings.Split(name, ".")
+}
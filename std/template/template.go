@@ -0,0 +1,279 @@
+package template
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/Azure/golua/lua"
+)
+
+const templateTypeName = "template"
+
+//
+// Lua Extension Library -- template
+//
+
+// Open opens the template library. The library renders mustache-style templates
+// with a Lua value as context. Templates are compiled once and can be rendered
+// any number of times:
+//
+//	local template = require "template"
+//	local mail = template.compile("Hello {{name}}!{{#items}}\n- {{.}}{{/items}}")
+//	print(mail:render{name = "Ann", items = {"a", "b"}})
+//
+// The supported tags are:
+//
+//	{{name}}               the value of name, escaped
+//	{{{name}}}, {{&name}}  the value of name, not escaped
+//	{{#name}}...{{/name}}  a section, rendered once for each element if name is
+//	                       a sequence, once with name as the context if it is
+//	                       another non-empty table, and once if it is any other
+//	                       true value
+//	{{^name}}...{{/name}}  an inverted section, rendered if name is false, nil
+//	                       or an empty table
+//	{{! comment }}         ignored
+//
+// Names may be dotted (a.b.c) and are looked up from the innermost context
+// outwards; "." denotes the current context. Function values are called with
+// the current context and their result is used instead.
+//
+// The library is not opened by default; it is available through require "template".
+func Open(state *lua.State) int {
+	// Create 'template' table.
+	var templateFuncs = map[string]lua.Func{
+		"compile": lua.Func(templateCompile),
+		"render":  lua.Func(templateRender),
+	}
+	state.NewTableSize(0, len(templateFuncs))
+	state.SetFuncs(templateFuncs, 0)
+	createTemplateMetaTable(state)
+
+	// Return 'template' table.
+	return 1
+}
+
+// createTemplateMetaTable creates the metatable for compiled templates.
+func createTemplateMetaTable(state *lua.State) {
+	var funcs = map[string]lua.Func{
+		"render": lua.Func(tmplRender),
+	}
+	state.NewMetaTable(templateTypeName)
+	state.PushIndex(-1)           // push metatable
+	state.SetField(-2, "__index") // metatable.__index = metatable
+	state.SetFuncs(funcs, 0)
+	state.Pop()
+}
+
+// template.compile (source [, options])
+//
+// Compiles the template source and returns a template object. The optional
+// table options may contain the field escape, which selects how values are
+// escaped: "html" (the default) escapes HTML special characters, "none" (or
+// false) disables escaping, and a function is called with each value and must
+// return the escaped string.
+func templateCompile(state *lua.State) int {
+	tmpl := compile(state, 1, 2)
+	state.Push(tmpl)
+	state.SetMetaTable(templateTypeName)
+	return 1
+}
+
+// template.render (source, context [, options])
+//
+// Compiles and renders the template source in one step; equivalent to
+// template.compile(source, options):render(context).
+func templateRender(state *lua.State) int {
+	ctx := state.CheckAny(2)
+	state.Push(render(state, compile(state, 1, 3), ctx))
+	return 1
+}
+
+// template:render (context)
+//
+// Renders the template with the given context and returns the resulting string.
+func tmplRender(state *lua.State) int {
+	tmpl := state.CheckUserData(1, templateTypeName).(*template)
+	state.Push(render(state, tmpl, state.CheckAny(2)))
+	return 1
+}
+
+// compile compiles the template source at index src with the options at index opts.
+func compile(state *lua.State, src, opts int) *template {
+	var (
+		text = state.CheckString(src)
+		tmpl = &template{escape: html.EscapeString}
+	)
+	if !state.IsNoneOrNil(opts) {
+		state.CheckType(opts, lua.TableType)
+		state.GetField(opts, "escape")
+		switch esc := state.Pop(); {
+		case lua.IsNone(esc):
+		case esc.Type() == lua.FuncType:
+			tmpl.escapeFn = esc
+		case esc == lua.Bool(false) || esc == lua.String("none"):
+			tmpl.escape = nil
+		case esc == lua.String("html"):
+		default:
+			state.ArgError(opts, fmt.Sprintf("invalid escape option '%v'", esc))
+		}
+	}
+	nodes, err := parse(text)
+	if err != nil {
+		state.Errorf("%v", err)
+	}
+	tmpl.nodes = nodes
+	return tmpl
+}
+
+// render renders tmpl with the context ctx.
+func render(state *lua.State, tmpl *template, ctx lua.Value) string {
+	r := &renderer{state: state, tmpl: tmpl, stack: []lua.Value{ctx}}
+	r.render(tmpl.nodes)
+	return r.out.String()
+}
+
+// template is a compiled template.
+type template struct {
+	nodes    []node
+	escape   func(string) string
+	escapeFn lua.Value // Lua escape function, if any
+}
+
+// renderer holds the state of a single rendering.
+type renderer struct {
+	state *lua.State
+	tmpl  *template
+	stack []lua.Value // context stack; innermost last
+	out   strings.Builder
+}
+
+func (r *renderer) render(nodes []node) {
+	for _, n := range nodes {
+		switch n.kind {
+		case textNode:
+			r.out.WriteString(n.text)
+		case varNode:
+			r.out.WriteString(r.escaped(r.toString(r.lookup(n.path))))
+		case rawNode:
+			r.out.WriteString(r.toString(r.lookup(n.path)))
+		case sectionNode:
+			r.section(n)
+		case invertedNode:
+			if v := r.lookup(n.path); !lua.Truth(v) || (v.Type() == lua.TableType && r.isEmpty(v)) {
+				r.render(n.nodes)
+			}
+		}
+	}
+}
+
+func (r *renderer) section(n node) {
+	v := r.lookup(n.path)
+	switch {
+	case !lua.Truth(v):
+	case v.Type() == lua.TableType:
+		state := r.state
+		state.Push(v)
+		size := state.RawLen(-1)
+		state.Pop()
+		if size == 0 {
+			if !r.isEmpty(v) {
+				r.with(v, n.nodes)
+			}
+			return
+		}
+		for i := 1; i <= size; i++ {
+			state.Push(v)
+			state.GetIndex(-1, int64(i))
+			elem := state.Pop()
+			state.Pop()
+			r.with(elem, n.nodes)
+		}
+	default:
+		r.with(v, n.nodes)
+	}
+}
+
+func (r *renderer) with(ctx lua.Value, nodes []node) {
+	r.stack = append(r.stack, ctx)
+	r.render(nodes)
+	r.stack = r.stack[:len(r.stack)-1]
+}
+
+// lookup resolves a dotted name against the context stack.
+func (r *renderer) lookup(path []string) lua.Value {
+	if len(path) == 0 {
+		return r.call(r.stack[len(r.stack)-1])
+	}
+	for i := len(r.stack) - 1; i >= 0; i-- {
+		if v := r.field(r.stack[i], path[0]); !lua.IsNone(v) {
+			for _, name := range path[1:] {
+				v = r.field(r.call(v), name)
+			}
+			return r.call(v)
+		}
+	}
+	return nil
+}
+
+// field returns ctx[name] for indexable contexts, or nil.
+func (r *renderer) field(ctx lua.Value, name string) lua.Value {
+	switch ctx.Type() {
+	case lua.TableType, lua.UserDataType:
+		r.state.Push(ctx)
+		r.state.GetField(-1, name)
+		v := r.state.Pop()
+		r.state.Pop()
+		return v
+	}
+	return nil
+}
+
+// call calls function values with the current context and returns their result.
+func (r *renderer) call(v lua.Value) lua.Value {
+	if v == nil || v.Type() != lua.FuncType {
+		return v
+	}
+	r.state.Push(v)
+	r.state.Push(r.stack[len(r.stack)-1])
+	r.state.Call(1, 1)
+	return r.state.Pop()
+}
+
+// isEmpty reports whether the table v has no entries.
+func (r *renderer) isEmpty(v lua.Value) bool {
+	r.state.Push(v)
+	r.state.Push(nil)
+	more := r.state.Next(-2)
+	if more {
+		r.state.PopN(2)
+	}
+	r.state.Pop()
+	return !more
+}
+
+func (r *renderer) toString(v lua.Value) string {
+	if lua.IsNone(v) {
+		return ""
+	}
+	r.state.Push(v)
+	s := r.state.ToStringMeta(-1)
+	r.state.PopN(2)
+	return s
+}
+
+func (r *renderer) escaped(s string) string {
+	if r.tmpl.escapeFn != nil {
+		r.state.Push(r.tmpl.escapeFn)
+		r.state.Push(s)
+		r.state.Call(1, 1)
+		if !r.state.IsString(-1) {
+			r.state.Errorf("escape function must return a string")
+		}
+		return r.state.Pop().String()
+	}
+	if r.tmpl.escape != nil {
+		return r.tmpl.escape(s)
+	}
+	return s
+}
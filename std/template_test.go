@@ -0,0 +1,90 @@
+package std
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/Azure/golua/lua"
+)
+
+func TestTemplate(t *testing.T) {
+	state := lua.NewState()
+	defer state.Close()
+	Open(state)
+	lib := require(t, state, "template")
+
+	render := func(src string, ctx interface{}, opts lua.Value) (string, error) {
+		state.Push(lib)
+		state.GetField(-1, "render")
+		state.Push(src)
+		if err := state.PushValue(ctx); err != nil {
+			t.Fatal(err)
+		}
+		state.Push(opts)
+		defer state.SetTop(0)
+		if err := state.PCall(3, 1, 0); err != nil {
+			return "", err
+		}
+		return state.ToString(-1), nil
+	}
+	ctx := map[string]interface{}{
+		"name":  "<Ann>",
+		"items": []interface{}{"a", "b"},
+		"user":  map[string]interface{}{"name": "bob", "admin": false},
+		"empty": []interface{}{},
+		"n":     3,
+	}
+	var tests = []struct {
+		src, want string
+	}{
+		{"Hello {{name}}!", "Hello &lt;Ann&gt;!"},
+		{"{{{name}}} {{&name}}", "<Ann> <Ann>"},
+		{"{{#items}}[{{.}}]{{/items}}", "[a][b]"},
+		{"{{#user}}{{name}} of {{n}}{{/user}}", "bob of 3"}, // outer contexts
+		{"{{user.name}}{{user.missing}}", "bob"},
+		{"{{^user.admin}}guest{{/user.admin}}", "guest"},
+		{"{{^empty}}none{{/empty}}{{#empty}}some{{/empty}}", "none"},
+		{"a{{! comment }}b", "ab"},
+	}
+	for _, tt := range tests {
+		if got, err := render(tt.src, ctx, nil); err != nil || got != tt.want {
+			t.Errorf("render(%q) = %q, %v; want %q", tt.src, got, err, tt.want)
+		}
+	}
+
+	state.NewTable()
+	state.Push("none")
+	state.SetField(-2, "escape")
+	if got, err := render("{{name}}", ctx, state.Pop()); err != nil || got != "<Ann>" {
+		t.Errorf("render with escape 'none' = %q, %v", got, err)
+	}
+	state.NewTable()
+	state.Push(lua.Func(func(state *lua.State) int {
+		state.Push(strings.ToUpper(state.CheckString(1)))
+		return 1
+	}))
+	state.SetField(-2, "escape")
+	if got, err := render("{{user.name}}", ctx, state.Pop()); err != nil || got != "BOB" {
+		t.Errorf("render with an escape function = %q, %v", got, err)
+	}
+
+	// A compiled template renders any number of contexts.
+	state.Push(lib)
+	state.GetField(-1, "compile")
+	state.Push("{{.}}!")
+	state.Call(1, 1)
+	tmpl := state.Pop()
+	state.Pop()
+	for _, v := range []interface{}{"a", int64(2)} {
+		if got := callMethod(t, state, tmpl, "render", v); len(got) != 1 || got[0] != fmt.Sprint(v, "!") {
+			t.Errorf("tmpl:render(%v) = %v; want %v!", v, got, v)
+		}
+	}
+
+	for _, src := range []string{"{{name", "{{}}", "{{#a}}x", "x{{/a}}"} {
+		if _, err := render(src, ctx, nil); err == nil {
+			t.Errorf("render(%q) succeeded; want a syntax error", src)
+		}
+	}
+}
@@ -2,7 +2,6 @@ package utf8
 
 import (
 	"fmt"
-	"unicode/utf8"
 
 	"github.com/Azure/golua/lua"
 )
@@ -36,7 +35,7 @@ func Open(state *lua.State) int {
 	state.SetFuncs(utf8Funcs, 0)
 
 	// pattern to match a single UTF-8 character.
-	const pattern = `[\0-\x7F\xC2-\xF4][\x80-\xBF]*`
+	const pattern = "[\x00-\x7F\xC2-\xF4][\x80-\xBF]*"
 
 	// The pattern (a string, not a function) "[\0-\x7F\xC2-\xF4][\x80-\xBF]*" (see §6.4.1),
 	// which matches exactly one UTF-8 byte sequence, assuming that the subject is a valid
@@ -57,24 +56,13 @@ func Open(state *lua.State) int {
 //
 // See https://www.lua.org/manual/5.3/manual.html#pdf-utf8.char
 func utf8Char(state *lua.State) int {
-	runes := make([]rune, state.Top())
+	var b []byte
 	for i := 1; i <= state.Top(); i++ {
 		c := state.CheckInt(i)
-		if c < 0 || c > unicodeMax {
-
-			panic(fmt.Errorf("bad argument #1 to 'char' (value out of range)"))
-		}
-		runes[i-1] = rune(c)
-	}
-	var (
-		buf = make([]byte, 6)
-		str string
-	)
-	for _, r := range runes {
-		w := utf8.EncodeRune(buf, r)
-		str += string(buf[:w])
+		state.ArgCheck(0 <= c && c <= unicodeMax, i, "value out of range")
+		b = encode(b, rune(c))
 	}
-	state.Push(str)
+	state.Push(string(b))
 	return 1
 }
 
@@ -88,35 +76,39 @@ func utf8Char(state *lua.State) int {
 //
 // See https://www.lua.org/manual/5.3/manual.html#pdf-utf8.codes
 func utf8Codes(state *lua.State) int {
-	iter := lua.Func(func(state *lua.State) int {
-		var (
-			s = state.CheckString(1)
-			n = state.ToInt(2)
-		)
-		if n > 0 && n < int64(len(s)) {
-			n++
-			for n < int64(len(s)) && isContByte(s[n]) {
-				n++
-			}
-		}
-		if n >= int64(len(s)) {
-			return 0
-		}
-		r, _ := utf8.DecodeRuneInString(s[n:])
-		if r == utf8.RuneError {
-			panic(fmt.Errorf("invalid UTF-8 code"))
-		}
-		state.Push(n + 1)
-		state.Push(int64(r))
-		return 2
-	})
 	state.CheckString(1)
-	state.Push(iter)
+	state.Push(lua.Func(codesIter))
 	state.PushIndex(1)
 	state.Push(0)
 	return 3
 }
 
+// codesIter is the iterator function of utf8.codes.
+func codesIter(state *lua.State) int {
+	var (
+		s = state.CheckString(1)
+		n = int(state.ToInt(2)) - 1
+	)
+	if n < 0 { // first iteration?
+		n = 0
+	} else if n < len(s) {
+		n++ // skip current byte
+		for isCont(s, n) {
+			n++ // and its continuations
+		}
+	}
+	if n >= len(s) {
+		return 0 // no more codepoints
+	}
+	r, w := decode(s[n:])
+	if w == 0 || isCont(s, n+w) {
+		panic(fmt.Errorf("invalid UTF-8 code"))
+	}
+	state.Push(n + 1)
+	state.Push(int64(r))
+	return 2
+}
+
 // utf8.codepoint (s [, i [, j]])
 //
 // Returns the codepoints (as integers) from all characters in s that start between byte position
@@ -129,25 +121,19 @@ func utf8Codes(state *lua.State) int {
 func utf8CodePoint(state *lua.State) int {
 	var (
 		s = state.CheckString(1)
-		i = state.OptInt(2, 1)
-		j = state.OptInt(3, i)
+		i = strPos(len(s), lua.ClampInt(state.OptInt(2, 1)))
+		j = strPos(len(s), lua.ClampInt(state.OptInt(3, int64(i))))
 		n = 0
 	)
-	if i = int64(strPos(len(s), int(i))); i < 1 {
-		panic(fmt.Errorf("bad argument #2 to 'codepoint' (out of range)"))
-	}
-	if j = int64(strPos(len(s), int(j))); j > int64(len(s)) {
-		panic(fmt.Errorf("bad argument #3 to 'codepoint' (out of range)"))
-	}
-	for s = s[i-1:]; i <= j; {
-		r, w := utf8.DecodeRuneInString(s)
-		if r == utf8.RuneError {
+	state.ArgCheck(i >= 1, 2, "out of range")
+	state.ArgCheck(j <= len(s), 3, "out of range")
+	for i--; i < j; n++ {
+		r, w := decode(s[i:])
+		if w == 0 {
 			panic(fmt.Errorf("invalid UTF-8 code"))
 		}
 		state.Push(int64(r))
-		i += int64(w)
-		s = s[w:]
-		n++
+		i += w
 	}
 	return n
 }
@@ -166,31 +152,20 @@ func utf8CodePoint(state *lua.State) int {
 func utf8Len(state *lua.State) int {
 	var (
 		s = state.CheckString(1)
-		i = int64(strPos(len(s), int(state.OptInt(2, 1))))
-		j = int64(strPos(len(s), int(state.OptInt(3, -1))))
-		n = int64(0)
+		i = strPos(len(s), lua.ClampInt(state.OptInt(2, 1)))
+		j = strPos(len(s), lua.ClampInt(state.OptInt(3, -1)))
+		n = 0
 	)
-	state.ArgCheck(1 <= i && i <= int64(len(s))+1, 2, "initial position out of string")
-	state.ArgCheck(j <= int64(len(s)), 3, "final position out of string")
-
-	// if i = int64(strPos(len(s), int(i))); i > 1 {
-	// 	panic(fmt.Errorf("initial position out of string"))
-	// }
-	// if j = int64(strPos(len(s), int(j))); j > int64(len(s)) {
-	// 	panic(fmt.Errorf("final position out of string"))
-	// }
-	if i <= j {
-		for s = s[i-1 : j]; len(s) > 0; {
-			r, w := utf8.DecodeRuneInString(s)
-			if r == utf8.RuneError {
-				state.Push(false)
-				state.Push(i)
-				return 2
-			}
-			i += int64(w)
-			s = s[w:]
-			n++
+	state.ArgCheck(1 <= i && i <= len(s)+1, 2, "initial position out of string")
+	state.ArgCheck(j <= len(s), 3, "final position out of string")
+	for i--; i < j; n++ {
+		_, w := decode(s[i:])
+		if w == 0 { // conversion error?
+			state.Push(nil)
+			state.Push(i + 1)
+			return 2
 		}
+		i += w
 	}
 	state.Push(n)
 	return 1
@@ -222,53 +197,84 @@ func utf8Offset(state *lua.State) int {
 	if n < 0 {
 		i = int64(len(s) + 1)
 	}
-	i = int64(strPos(len(s), int(state.OptInt(3, i))))
-	if i < 1 || i > int64(len(s))+1 {
-		panic(fmt.Errorf("position out of range"))
-	}
-	i--
+	pos := strPos(len(s), lua.ClampInt(state.OptInt(3, i)))
+	state.ArgCheck(1 <= pos && pos <= len(s)+1, 3, "position out of range")
+	pos--
 	if n == 0 {
-		for i > 0 && isContByte(s[i]) {
-			i--
+		// find beginning of current byte sequence
+		for pos > 0 && isCont(s, pos) {
+			pos--
 		}
 	} else {
-		if i < int64(len(s)) && isContByte(s[i]) {
+		if isCont(s, pos) {
 			panic(fmt.Errorf("initial position is a continuation byte"))
 		}
 		if n < 0 {
-			for n < 0 && i > 0 {
-				for {
-					i--
-					if !(i > 0 && isContByte(s[i])) {
-						break
-					}
+			for ; n < 0 && pos > 0; n++ { // move back
+				for pos--; pos > 0 && isCont(s, pos); pos-- {
 				}
-				n++
 			}
 		} else {
-			n--
-			for n > 0 && i < int64(len(s)) {
-				for {
-					i++
-					if i >= int64(len(s)) || !isContByte(s[i]) {
-						break
-					}
+			for n--; n > 0 && pos < len(s); n-- { // move forward
+				for pos++; isCont(s, pos); pos++ {
 				}
-				n--
 			}
 		}
 	}
-	if n == 0 {
-		state.Push(i + 1)
-	} else {
+	if n == 0 { // did it find given character?
+		state.Push(pos + 1)
+	} else { // no such character
 		state.Push(nil)
 	}
 	return 1
 }
 
+// decode decodes the UTF-8 sequence at the start of s, returning its code
+// point and width, or a width of 0 if it is invalid. As in Lua 5.3, overlong
+// sequences and code points above 10FFFF are invalid, but surrogates are not.
+func decode(s string) (r rune, w int) {
+	limits := [...]rune{0xFF, 0x7F, 0x7FF, 0xFFFF}
+	if len(s) == 0 {
+		return 0, 0
+	}
+	c := s[0]
+	if c < 0x80 { // ascii?
+		return rune(c), 1
+	}
+	for ; c&0x40 != 0; c <<= 1 { // while it needs continuation bytes...
+		w++
+		if w >= len(s) || !isContByte(s[w]) {
+			return 0, 0 // invalid byte sequence
+		}
+		r = r<<6 | rune(s[w]&0x3F)
+	}
+	r |= rune(c&0x7F) << (w * 5) // add bits from first byte
+	if w == 0 || w > 3 || r > unicodeMax || r <= limits[w] {
+		return 0, 0
+	}
+	return r, w + 1
+}
+
+// encode appends the UTF-8 encoding of r, which may be a surrogate.
+func encode(b []byte, r rune) []byte {
+	switch {
+	case r < 0x80:
+		return append(b, byte(r))
+	case r < 0x800:
+		return append(b, 0xC0|byte(r>>6), 0x80|byte(r)&0x3F)
+	case r < 0x10000:
+		return append(b, 0xE0|byte(r>>12), 0x80|byte(r>>6)&0x3F, 0x80|byte(r)&0x3F)
+	default:
+		return append(b, 0xF0|byte(r>>18), 0x80|byte(r>>12)&0x3F, 0x80|byte(r>>6)&0x3F, 0x80|byte(r)&0x3F)
+	}
+}
+
 // isContByte reports whether b is a continuation byte.
 func isContByte(b byte) bool { return b&0xC0 == 0x80 }
 
+// isCont reports whether s[i] is a continuation byte; the end of s is not.
+func isCont(s string, i int) bool { return i < len(s) && isContByte(s[i]) }
+
 // strPos converts a relative string position: negative means back
 // from end. The absolute position is returned.
 func strPos(len, pos int) int {
@@ -0,0 +1,77 @@
+package std
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Azure/golua/lua"
+)
+
+func TestUTF8(t *testing.T) {
+	state := lua.NewState()
+	defer state.Close()
+	Open(state)
+
+	const s = "hé世\U0001F600" // 1, 2, 3 and 4 bytes
+	var tests = []struct {
+		fn   string
+		args []interface{}
+		want string
+	}{
+		{"char", []interface{}{104, 0xE9, 0x4E16, 0x1F600}, "[" + s + "]"},
+		{"char", []interface{}{0xD800}, "[\xed\xa0\x80]"},
+		{"len", []interface{}{s}, "[4]"},
+		{"len", []interface{}{s, 2, 2}, "[1]"},
+		{"len", []interface{}{s, -4}, "[1]"},
+		{"len", []interface{}{"a\xffb"}, "[nil 2]"},
+		{"len", []interface{}{"\xef\xbf\xbd"}, "[1]"},
+		{"len", []interface{}{"\xc0\x80"}, "[nil 1]"},
+		{"codepoint", []interface{}{s, 1, -1}, "[104 233 19990 128512]"},
+		{"codepoint", []interface{}{s, 4}, "[19990]"},
+		{"codepoint", []interface{}{s, 5, 4}, "[]"},
+		{"offset", []interface{}{s, 3}, "[4]"},
+		{"offset", []interface{}{s, -1}, "[7]"},
+		{"offset", []interface{}{s, 0, 9}, "[7]"},
+		{"offset", []interface{}{s, 0, 11}, "[11]"},
+		{"offset", []interface{}{s, 6}, "[nil]"},
+		{"offset", []interface{}{s, 5}, "[11]"},
+	}
+	for _, test := range tests {
+		got := fmt.Sprint(call(t, state, "utf8", test.fn, test.args...))
+		if got != test.want {
+			t.Errorf("utf8.%s%v = %s; want %s", test.fn, test.args, got, test.want)
+		}
+	}
+
+	// codes iterates over every character.
+	var codes []interface{}
+	iter := call(t, state, "utf8", "codes", s)
+	if fmt.Sprint(iter) != "[function "+s+" 0]" {
+		t.Fatalf("utf8.codes = %v", iter)
+	}
+	state.GetGlobal("utf8")
+	state.GetField(-1, "codes")
+	state.Push(s)
+	state.Call(1, 3)
+	for {
+		state.PushIndex(-3)
+		state.PushIndex(-3)
+		state.PushIndex(-3)
+		state.Call(2, 2)
+		if state.IsNil(-2) {
+			break
+		}
+		codes = append(codes, state.ToInt(-2), state.ToInt(-1))
+		state.Remove(-3) // the previous position
+		state.Pop()
+	}
+	if got := fmt.Sprint(codes); got != "[1 104 2 233 4 19990 7 128512]" {
+		t.Errorf("utf8.codes positions and codes = %s", got)
+	}
+
+	state.GetGlobal("utf8")
+	state.GetField(-1, "charpattern")
+	if got := state.ToString(-1); got != "[\x00-\x7F\xC2-\xF4][\x80-\xBF]*" {
+		t.Errorf("utf8.charpattern = %q", got)
+	}
+}
@@ -0,0 +1,604 @@
+package vec
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/Azure/golua/lua"
+)
+
+const vecTypeName = "vec"
+
+//
+// Lua Extension Library -- vec
+//
+
+// Open opens the vec library, which provides 2D and 3D vectors and quaternions
+// implemented natively:
+//
+//	local vec = require "vec"
+//	local a, b = vec.vec3(1, 0, 0), vec.vec3(0, 1, 0)
+//	print(a:cross(b), (a + b):normalize(), a:dot(b)) --> vec3(0, 0, 1) vec3(0.70710678118655, 0.70710678118655, 0) 0
+//
+// Vectors support + and - between vectors of the same kind, multiplication and
+// division by numbers, componentwise multiplication of vectors, unary minus and
+// ==. Quaternions multiply with quaternions (composition) and with vec3 values
+// (rotation). Components are accessible as fields x, y, z and w, and may be
+// assigned; all other operations return new values.
+//
+// The library is not opened by default; it is available through require "vec".
+func Open(state *lua.State) int {
+	// Create 'vec' table.
+	var vecFuncs = map[string]lua.Func{
+		"vec2":      lua.Func(vecVec2),
+		"vec3":      lua.Func(vecVec3),
+		"quat":      lua.Func(vecQuat),
+		"axisangle": lua.Func(vecAxisAngle),
+		"isvec":     lua.Func(vecIsVec),
+		"sum":       lua.Func(vecSum),
+		"translate": lua.Func(vecTranslate),
+		"scale":     lua.Func(vecScale),
+		"rotate":    lua.Func(vecRotate),
+		"lerp":      lua.Func(vecLerpAll),
+	}
+	state.NewTableSize(0, len(vecFuncs))
+	state.SetFuncs(vecFuncs, 0)
+	createVecMetaTable(state)
+
+	// Return 'vec' table.
+	return 1
+}
+
+// kinds of vectors.
+const (
+	kindVec2 = 2
+	kindVec3 = 3
+	kindQuat = 4
+)
+
+var kindNames = map[int]string{kindVec2: "vec2", kindVec3: "vec3", kindQuat: "quat"}
+
+// vector is a vec2, vec3 or quaternion (x, y, z, w).
+type vector struct {
+	kind int
+	v    [4]float64
+}
+
+var vecMethods = map[string]lua.Func{
+	"conjugate": lua.Func(vecConjugate),
+	"cross":     lua.Func(vecCross),
+	"dot":       lua.Func(vecDot),
+	"kind":      lua.Func(vecKind),
+	"len":       lua.Func(vecLen),
+	"len2":      lua.Func(vecLen2),
+	"lerp":      lua.Func(vecLerp),
+	"normalize": lua.Func(vecNormalize),
+	"rotate":    lua.Func(vecRotateBy),
+	"slerp":     lua.Func(vecSlerp),
+	"unpack":    lua.Func(vecUnpack),
+}
+
+// createVecMetaTable creates the metatable for vectors.
+func createVecMetaTable(state *lua.State) {
+	var funcs = map[string]lua.Func{
+		"__add":      lua.Func(vecMetaAdd),
+		"__div":      lua.Func(vecMetaDiv),
+		"__eq":       lua.Func(vecMetaEq),
+		"__index":    lua.Func(vecMetaIndex),
+		"__mul":      lua.Func(vecMetaMul),
+		"__newindex": lua.Func(vecMetaNewIndex),
+		"__sub":      lua.Func(vecMetaSub),
+		"__tostring": lua.Func(vecMetaToString),
+		"__unm":      lua.Func(vecMetaUnm),
+	}
+	state.NewMetaTable(vecTypeName)
+	state.SetFuncs(funcs, 0)
+	state.Pop()
+}
+
+// vec.vec2 ([x [, y]])
+//
+// Returns a new 2D vector. Missing components default to 0.
+func vecVec2(state *lua.State) int {
+	push(state, &vector{kind: kindVec2, v: [4]float64{state.OptNumber(1, 0), state.OptNumber(2, 0)}})
+	return 1
+}
+
+// vec.vec3 ([x [, y [, z]]])
+//
+// Returns a new 3D vector. Missing components default to 0.
+func vecVec3(state *lua.State) int {
+	push(state, &vector{kind: kindVec3, v: [4]float64{state.OptNumber(1, 0), state.OptNumber(2, 0), state.OptNumber(3, 0)}})
+	return 1
+}
+
+// vec.quat ([x, y, z, w])
+//
+// Returns a new quaternion x*i + y*j + z*k + w. Without arguments, returns the
+// identity rotation.
+func vecQuat(state *lua.State) int {
+	if state.IsNone(1) {
+		push(state, &vector{kind: kindQuat, v: [4]float64{0, 0, 0, 1}})
+		return 1
+	}
+	push(state, &vector{kind: kindQuat, v: [4]float64{
+		state.CheckNumber(1), state.CheckNumber(2), state.CheckNumber(3), state.CheckNumber(4),
+	}})
+	return 1
+}
+
+// vec.axisangle (axis, angle)
+//
+// Returns the quaternion rotating by angle radians around the vec3 axis.
+func vecAxisAngle(state *lua.State) int {
+	axis := normalize(checkKind(state, 1, kindVec3).v)
+	angle := state.CheckNumber(2)
+	s := math.Sin(angle / 2)
+	push(state, &vector{kind: kindQuat, v: [4]float64{axis[0] * s, axis[1] * s, axis[2] * s, math.Cos(angle / 2)}})
+	return 1
+}
+
+// vec.isvec (v)
+//
+// Returns the kind of v ("vec2", "vec3" or "quat") if it is a vector, or false.
+func vecIsVec(state *lua.State) int {
+	if v := test(state, 1); v != nil {
+		state.Push(kindNames[v.kind])
+	} else {
+		state.Push(false)
+	}
+	return 1
+}
+
+// vec.sum (list)
+//
+// Returns the sum of the vectors in the sequence list, which must all be of
+// the same kind. Returns nil for an empty list.
+func vecSum(state *lua.State) int {
+	var sum *vector
+	forEach(state, 1, func(v *vector) {
+		if sum == nil {
+			sum = &vector{kind: v.kind}
+		}
+		sameKind(state, sum, v)
+		for i := range sum.v {
+			sum.v[i] += v.v[i]
+		}
+	})
+	if sum == nil {
+		state.Push(nil)
+	} else {
+		push(state, sum)
+	}
+	return 1
+}
+
+// vec.translate (list, offset)
+//
+// Adds offset to each vector in the sequence list, in place.
+func vecTranslate(state *lua.State) int {
+	offset := check(state, 2)
+	forEach(state, 1, func(v *vector) {
+		sameKind(state, v, offset)
+		for i := range v.v {
+			v.v[i] += offset.v[i]
+		}
+	})
+	return 0
+}
+
+// vec.scale (list, s)
+//
+// Multiplies each vector in the sequence list by the number s, in place.
+func vecScale(state *lua.State) int {
+	s := state.CheckNumber(2)
+	forEach(state, 1, func(v *vector) {
+		for i := range v.v {
+			v.v[i] *= s
+		}
+	})
+	return 0
+}
+
+// vec.rotate (list, q)
+//
+// Rotates each vec3 in the sequence list by the quaternion q, in place.
+func vecRotate(state *lua.State) int {
+	q := checkKind(state, 2, kindQuat)
+	forEach(state, 1, func(v *vector) {
+		if v.kind != kindVec3 {
+			state.Errorf("vec3 expected in list, got %s", kindNames[v.kind])
+		}
+		v.v = rotate(q.v, v.v)
+	})
+	return 0
+}
+
+// vec.lerp (from, to, t [, out])
+//
+// Linearly interpolates between the vectors of the sequences from and to, which
+// must have the same length, storing the results in the sequence out (a new
+// table by default). Returns out.
+func vecLerpAll(state *lua.State) int {
+	state.CheckType(1, lua.TableType)
+	state.CheckType(2, lua.TableType)
+	t := state.CheckNumber(3)
+	n := state.RawLen(1)
+	state.ArgCheck(state.RawLen(2) == n, 2, "lists of different lengths")
+	if state.IsNoneOrNil(4) {
+		state.SetTop(3)
+		state.NewTableSize(n, 0)
+	} else {
+		state.CheckType(4, lua.TableType)
+		state.SetTop(4)
+	}
+	for i := 1; i <= n; i++ {
+		state.RawGetIndex(1, i)
+		state.RawGetIndex(2, i)
+		a, b := check(state, -2), check(state, -1)
+		sameKind(state, a, b)
+		state.PopN(2)
+		push(state, &vector{kind: a.kind, v: lerp(a.v, b.v, t)})
+		state.RawSetIndex(4, i)
+	}
+	return 1
+}
+
+// v:dot (w)
+//
+// Returns the dot product of v and w.
+func vecDot(state *lua.State) int {
+	v, w := check(state, 1), check(state, 2)
+	sameKind(state, v, w)
+	state.Push(dot(v.v, w.v))
+	return 1
+}
+
+// v:cross (w)
+//
+// Returns the cross product of the vec3 values v and w. For vec2 values,
+// returns the z component of the cross product as a number.
+func vecCross(state *lua.State) int {
+	v, w := check(state, 1), check(state, 2)
+	sameKind(state, v, w)
+	a, b := v.v, w.v
+	switch v.kind {
+	case kindVec2:
+		state.Push(a[0]*b[1] - a[1]*b[0])
+	case kindVec3:
+		push(state, &vector{kind: kindVec3, v: [4]float64{
+			a[1]*b[2] - a[2]*b[1],
+			a[2]*b[0] - a[0]*b[2],
+			a[0]*b[1] - a[1]*b[0],
+		}})
+	default:
+		state.ArgError(1, "vec2 or vec3 expected")
+	}
+	return 1
+}
+
+// v:len ()
+//
+// Returns the length of v.
+func vecLen(state *lua.State) int {
+	v := check(state, 1)
+	state.Push(math.Sqrt(dot(v.v, v.v)))
+	return 1
+}
+
+// v:len2 ()
+//
+// Returns the squared length of v.
+func vecLen2(state *lua.State) int {
+	v := check(state, 1)
+	state.Push(dot(v.v, v.v))
+	return 1
+}
+
+// v:normalize ()
+//
+// Returns v scaled to unit length. The zero vector is returned unchanged.
+func vecNormalize(state *lua.State) int {
+	v := check(state, 1)
+	push(state, &vector{kind: v.kind, v: normalize(v.v)})
+	return 1
+}
+
+// v:lerp (w, t)
+//
+// Returns the linear interpolation v + (w - v) * t.
+func vecLerp(state *lua.State) int {
+	v, w := check(state, 1), check(state, 2)
+	sameKind(state, v, w)
+	push(state, &vector{kind: v.kind, v: lerp(v.v, w.v, state.CheckNumber(3))})
+	return 1
+}
+
+// q:slerp (r, t)
+//
+// Returns the spherical linear interpolation between the quaternions q and r,
+// taking the shortest path. Vectors are interpolated along the arc between
+// their directions, with the length interpolated linearly.
+func vecSlerp(state *lua.State) int {
+	v, w := check(state, 1), check(state, 2)
+	sameKind(state, v, w)
+	t := state.CheckNumber(3)
+	if v.kind == kindQuat {
+		push(state, &vector{kind: kindQuat, v: slerp(v.v, w.v, t, true)})
+		return 1
+	}
+	lv, lw := math.Sqrt(dot(v.v, v.v)), math.Sqrt(dot(w.v, w.v))
+	r := slerp(normalize(v.v), normalize(w.v), t, false)
+	l := lv + (lw-lv)*t
+	for i := range r {
+		r[i] *= l
+	}
+	push(state, &vector{kind: v.kind, v: r})
+	return 1
+}
+
+// q:conjugate ()
+//
+// Returns the conjugate of the quaternion q, which is its inverse rotation
+// when q has unit length.
+func vecConjugate(state *lua.State) int {
+	q := checkKind(state, 1, kindQuat)
+	push(state, &vector{kind: kindQuat, v: [4]float64{-q.v[0], -q.v[1], -q.v[2], q.v[3]}})
+	return 1
+}
+
+// q:rotate (v)
+//
+// Returns the vec3 v rotated by the quaternion q; equivalent to q * v.
+func vecRotateBy(state *lua.State) int {
+	q := checkKind(state, 1, kindQuat)
+	v := checkKind(state, 2, kindVec3)
+	push(state, &vector{kind: kindVec3, v: rotate(q.v, v.v)})
+	return 1
+}
+
+// v:kind ()
+//
+// Returns "vec2", "vec3" or "quat".
+func vecKind(state *lua.State) int {
+	state.Push(kindNames[check(state, 1).kind])
+	return 1
+}
+
+// v:unpack ()
+//
+// Returns the components of v.
+func vecUnpack(state *lua.State) int {
+	v := check(state, 1)
+	for _, c := range v.v[:v.kind] {
+		state.Push(c)
+	}
+	return v.kind
+}
+
+func vecMetaIndex(state *lua.State) int {
+	v := check(state, 1)
+	name := state.CheckString(2)
+	if i := component(v, name); i >= 0 {
+		state.Push(v.v[i])
+		return 1
+	}
+	if fn, ok := vecMethods[name]; ok {
+		state.Push(fn)
+		return 1
+	}
+	state.Push(nil)
+	return 1
+}
+
+func vecMetaNewIndex(state *lua.State) int {
+	v := check(state, 1)
+	name := state.CheckString(2)
+	i := component(v, name)
+	if i < 0 {
+		state.Errorf("%s has no component '%s'", kindNames[v.kind], name)
+	}
+	v.v[i] = state.CheckNumber(3)
+	return 0
+}
+
+func vecMetaAdd(state *lua.State) int {
+	v, w := check(state, 1), check(state, 2)
+	sameKind(state, v, w)
+	r := &vector{kind: v.kind}
+	for i := range r.v {
+		r.v[i] = v.v[i] + w.v[i]
+	}
+	push(state, r)
+	return 1
+}
+
+func vecMetaSub(state *lua.State) int {
+	v, w := check(state, 1), check(state, 2)
+	sameKind(state, v, w)
+	r := &vector{kind: v.kind}
+	for i := range r.v {
+		r.v[i] = v.v[i] - w.v[i]
+	}
+	push(state, r)
+	return 1
+}
+
+func vecMetaMul(state *lua.State) int {
+	v, w := test(state, 1), test(state, 2)
+	switch {
+	case v == nil: // number * vector
+		push(state, scaled(w, state.CheckNumber(1)))
+	case w == nil: // vector * number
+		push(state, scaled(v, state.CheckNumber(2)))
+	case v.kind == kindQuat && w.kind == kindQuat:
+		push(state, &vector{kind: kindQuat, v: qmul(v.v, w.v)})
+	case v.kind == kindQuat && w.kind == kindVec3:
+		push(state, &vector{kind: kindVec3, v: rotate(v.v, w.v)})
+	default:
+		sameKind(state, v, w)
+		r := &vector{kind: v.kind}
+		for i := range r.v {
+			r.v[i] = v.v[i] * w.v[i]
+		}
+		push(state, r)
+	}
+	return 1
+}
+
+func vecMetaDiv(state *lua.State) int {
+	v := check(state, 1)
+	push(state, scaled(v, 1/state.CheckNumber(2)))
+	return 1
+}
+
+func vecMetaUnm(state *lua.State) int {
+	push(state, scaled(check(state, 1), -1))
+	return 1
+}
+
+func vecMetaEq(state *lua.State) int {
+	v, w := test(state, 1), test(state, 2)
+	state.Push(v != nil && w != nil && *v == *w)
+	return 1
+}
+
+func vecMetaToString(state *lua.State) int {
+	v := check(state, 1)
+	parts := make([]string, v.kind)
+	for i := range parts {
+		parts[i] = fmt.Sprintf("%.14g", v.v[i])
+	}
+	state.Push(fmt.Sprintf("%s(%s)", kindNames[v.kind], strings.Join(parts, ", ")))
+	return 1
+}
+
+func push(state *lua.State, v *vector) {
+	state.Push(v)
+	state.SetMetaTable(vecTypeName)
+}
+
+func check(state *lua.State, index int) *vector {
+	return state.CheckUserData(index, vecTypeName).(*vector)
+}
+
+func test(state *lua.State, index int) *vector {
+	v, _ := state.TestUserData(index, vecTypeName).(*vector)
+	return v
+}
+
+func checkKind(state *lua.State, index, kind int) *vector {
+	v := check(state, index)
+	if v.kind != kind {
+		state.ArgError(index, fmt.Sprintf("%s expected, got %s", kindNames[kind], kindNames[v.kind]))
+	}
+	return v
+}
+
+func sameKind(state *lua.State, v, w *vector) {
+	if v.kind != w.kind {
+		state.Errorf("attempt to combine %s with %s", kindNames[v.kind], kindNames[w.kind])
+	}
+}
+
+// forEach calls fn with each vector of the sequence at index.
+func forEach(state *lua.State, index int, fn func(*vector)) {
+	state.CheckType(index, lua.TableType)
+	for i, n := 1, state.RawLen(index); i <= n; i++ {
+		state.RawGetIndex(index, i)
+		v := test(state, -1)
+		if v == nil {
+			state.Errorf("vector expected at index %d in list, got %s", i, state.TypeAt(-1))
+		}
+		state.Pop()
+		fn(v)
+	}
+}
+
+// component returns the index of the named component of v, or -1.
+func component(v *vector, name string) int {
+	i := -1
+	switch name {
+	case "x":
+		i = 0
+	case "y":
+		i = 1
+	case "z":
+		i = 2
+	case "w":
+		i = 3
+	}
+	if i >= v.kind {
+		return -1
+	}
+	return i
+}
+
+func scaled(v *vector, s float64) *vector {
+	r := &vector{kind: v.kind}
+	for i := range r.v {
+		r.v[i] = v.v[i] * s
+	}
+	return r
+}
+
+func dot(a, b [4]float64) float64 {
+	return a[0]*b[0] + a[1]*b[1] + a[2]*b[2] + a[3]*b[3]
+}
+
+func normalize(a [4]float64) [4]float64 {
+	l := math.Sqrt(dot(a, a))
+	if l == 0 {
+		return a
+	}
+	return [4]float64{a[0] / l, a[1] / l, a[2] / l, a[3] / l}
+}
+
+func lerp(a, b [4]float64, t float64) (r [4]float64) {
+	for i := range r {
+		r[i] = a[i] + (b[i]-a[i])*t
+	}
+	return r
+}
+
+// slerp interpolates between the unit vectors a and b. If shortest is set
+// (quaternions), b is negated when needed to take the shorter arc.
+func slerp(a, b [4]float64, t float64, shortest bool) [4]float64 {
+	d := dot(a, b)
+	if shortest && d < 0 {
+		d = -d
+		for i := range b {
+			b[i] = -b[i]
+		}
+	}
+	if d > 0.9995 { // nearly parallel: fall back to normalized lerp
+		return normalize(lerp(a, b, t))
+	}
+	theta := math.Acos(math.Max(-1, math.Min(1, d)))
+	sa := math.Sin((1-t)*theta) / math.Sin(theta)
+	sb := math.Sin(t*theta) / math.Sin(theta)
+	var r [4]float64
+	for i := range r {
+		r[i] = a[i]*sa + b[i]*sb
+	}
+	return r
+}
+
+// qmul returns the Hamilton product a * b of two quaternions.
+func qmul(a, b [4]float64) [4]float64 {
+	return [4]float64{
+		a[3]*b[0] + a[0]*b[3] + a[1]*b[2] - a[2]*b[1],
+		a[3]*b[1] - a[0]*b[2] + a[1]*b[3] + a[2]*b[0],
+		a[3]*b[2] + a[0]*b[1] - a[1]*b[0] + a[2]*b[3],
+		a[3]*b[3] - a[0]*b[0] - a[1]*b[1] - a[2]*b[2],
+	}
+}
+
+// rotate returns the vec3 v rotated by the quaternion q (q * v * q⁻¹).
+func rotate(q, v [4]float64) [4]float64 {
+	conj := [4]float64{-q[0], -q[1], -q[2], q[3]}
+	r := qmul(qmul(q, [4]float64{v[0], v[1], v[2], 0}), conj)
+	r[3] = 0
+	return r
+}
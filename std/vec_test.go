@@ -0,0 +1,102 @@
+package std
+
+import (
+	"math"
+	"testing"
+
+	"github.com/Azure/golua/lua"
+)
+
+func TestVec(t *testing.T) {
+	state := lua.NewState()
+	defer state.Close()
+	Open(state)
+	lib := require(t, state, "vec")
+
+	// fn calls vec.name with args and returns its first result.
+	fn := func(name string, args ...interface{}) lua.Value {
+		state.Push(lib)
+		state.GetField(-1, name)
+		for _, arg := range args {
+			state.Push(arg)
+		}
+		if err := state.PCall(len(args), 1, 0); err != nil {
+			t.Fatalf("vec.%s: %v", name, err)
+		}
+		defer state.Pop()
+		return state.Pop()
+	}
+	str := func(v lua.Value) string {
+		state.Push(v)
+		defer state.Pop()
+		return state.ToStringMeta(-1)
+	}
+	arith := func(op lua.Op, x, y interface{}) lua.Value {
+		state.Push(x)
+		state.Push(y)
+		state.Arith(op)
+		return state.Pop()
+	}
+
+	a, b := fn("vec3", 1, 0, 0), fn("vec3", 0, 1, 0)
+	var tests = []struct {
+		got  lua.Value
+		want string
+	}{
+		{method(t, state, a, "cross", b), "vec3(0, 0, 1)"},
+		{method(t, state, a, "dot", b), "0.0"},
+		{method(t, state, fn("vec2", 3, 4), "len"), "5.0"},
+		{method(t, state, fn("vec2", 3, 4), "normalize"), "vec2(0.6, 0.8)"},
+		{method(t, state, fn("vec2"), "lerp", fn("vec2", 2, 4), 0.25), "vec2(0.5, 1)"},
+		{arith(lua.OpAdd, a, b), "vec3(1, 1, 0)"},
+		{arith(lua.OpSub, a, b), "vec3(1, -1, 0)"},
+		{arith(lua.OpMul, a, 2), "vec3(2, 0, 0)"},
+		{arith(lua.OpMul, 2, a), "vec3(2, 0, 0)"},
+		{arith(lua.OpDiv, fn("vec2", 2, 4), 2), "vec2(1, 2)"},
+		{arith(lua.OpMul, fn("vec2", 2, 3), fn("vec2", 4, 5)), "vec2(8, 15)"},
+		{fn("isvec", fn("quat")), "quat"},
+		{fn("isvec", "vec3"), "false"},
+		{fn("sum", seq(state, a, b, b)), "vec3(1, 2, 0)"},
+	}
+	for i, tt := range tests {
+		if got := str(tt.got); got != tt.want {
+			t.Errorf("#%d: %s; want %s", i, got, tt.want)
+		}
+	}
+
+	// A quarter turn around z maps x to y.
+	q := fn("axisangle", fn("vec3", 0, 0, 1), math.Pi/2)
+	state.Push(arith(lua.OpMul, q, a))
+	state.Push(b)
+	for i, c := range []string{"x", "y", "z"} {
+		state.GetField(-2, c)
+		state.GetField(-2, c)
+		if x, y := state.ToNumber(-2), state.ToNumber(-1); math.Abs(x-y) > 1e-12 {
+			t.Errorf("component %d of q * a = %g; want %g", i, x, y)
+		}
+		state.PopN(2)
+	}
+	state.SetTop(0)
+
+	// Bulk operations work in place.
+	list := seq(state, fn("vec2", 1, 1), fn("vec2", 2, 0))
+	fn("translate", list, fn("vec2", 1, -1))
+	fn("scale", list, 10)
+	state.Push(list)
+	state.RawGetIndex(-1, 2)
+	if got := state.ToStringMeta(-1); got != "vec2(30, -10)" {
+		t.Errorf("list[2] after translate and scale = %s; want vec2(30, -10)", got)
+	}
+	state.SetTop(0)
+
+	state.Push(a)
+	state.Push(fn("vec2"))
+	state.Push(lua.Func(func(state *lua.State) int {
+		state.Arith(lua.OpAdd)
+		return 1
+	}))
+	state.Insert(1)
+	if err := state.PCall(2, 1, 0); err == nil {
+		t.Errorf("vec3 + vec2 succeeded")
+	}
+}
@@ -0,0 +1,517 @@
+package xml
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Azure/golua/lua"
+)
+
+const elementTypeName = "xml.element"
+
+//
+// Lua Extension Library -- xml
+//
+
+// Open opens the xml library, which parses XML documents into light element
+// trees. An element is a table whose field tag is its name, attr a table of its
+// attributes, ns its namespace (if any), and whose sequence holds its children:
+// elements and strings of character data.
+//
+//	local feed = xml.parse([[<feed><item id="1">Sword</item></feed>]])
+//	for _, item in ipairs(feed:findall("item")) do
+//	    print(item.attr.id, item:text())
+//	end
+//
+// Elements have methods to query their descendants with a subset of XPath, as
+// in Python's ElementTree: a path is a sequence of steps separated by "/",
+// each step a tag name, "*" for any element, or "." for the element itself,
+// and "//" selects all descendants instead of the children. A step may be
+// followed by predicates: [@name] and [@name='value'] test attributes, [tag]
+// and [tag='text'] test children, and [n] selects the nth match.
+//
+//	feed:find("item[@id='1']")
+//	feed:findall(".//price")
+//
+// The library is not opened by default; it is available through require "xml".
+func Open(state *lua.State) int {
+	// Create 'xml' table.
+	var xmlFuncs = map[string]lua.Func{
+		"load":  lua.Func(xmlLoad),
+		"parse": lua.Func(xmlParse),
+	}
+	state.NewTableSize(0, len(xmlFuncs))
+	state.SetFuncs(xmlFuncs, 0)
+	createElementMetaTable(state)
+
+	// Return 'xml' table.
+	return 1
+}
+
+// createElementMetaTable creates the metatable for elements.
+func createElementMetaTable(state *lua.State) {
+	var funcs = map[string]lua.Func{
+		"find":    lua.Func(elementFind),
+		"findall": lua.Func(elementFindAll),
+		"get":     lua.Func(elementGet),
+		"text":    lua.Func(elementText),
+	}
+	state.NewMetaTable(elementTypeName)
+	state.PushIndex(-1)           // push metatable
+	state.SetField(-2, "__index") // metatable.__index = metatable
+	state.SetFuncs(funcs, 0)
+	state.Pop()
+}
+
+// xml.parse (text [, options])
+//
+// Parses the XML document text and returns its root element. If
+// options.whitespace is true, character data consisting only of whitespace is
+// kept; it is dropped by default. If options.strict is false, the parser
+// accepts common HTML mistakes such as unquoted attributes and unclosed
+// elements. In case of errors this function returns nil, plus a string
+// describing the error.
+func xmlParse(state *lua.State) int {
+	text := state.CheckString(1)
+	return parse(state, strings.NewReader(text), 2)
+}
+
+// xml.load (filename [, options])
+//
+// Like xml.parse, but parses the contents of the file named filename.
+func xmlLoad(state *lua.State) int {
+	name := state.CheckString(1)
+	text, err := state.ReadFile(name)
+	if err != nil {
+		return state.FileResult(err, name)
+	}
+	return parse(state, strings.NewReader(string(text)), 2)
+}
+
+// parse parses the document read from r with the options at arg, and pushes
+// its root element, or nil and an error message.
+func parse(state *lua.State, r io.Reader, arg int) int {
+	var keepSpace, strict = false, true
+	if !state.IsNoneOrNil(arg) {
+		state.CheckType(arg, lua.TableType)
+		state.GetField(arg, "whitespace")
+		keepSpace = state.ToBool(-1)
+		state.GetField(arg, "strict")
+		strict = state.IsNoneOrNil(-1) || state.ToBool(-1)
+		state.PopN(2)
+	}
+	top := state.Top()
+	if err := build(state, r, keepSpace, strict); err != nil {
+		state.SetTop(top)
+		state.Push(nil)
+		state.Push(err.Error())
+		return 2
+	}
+	return 1
+}
+
+// build decodes the document from r, building the element tree on the stack.
+func build(state *lua.State, r io.Reader, keepSpace, strict bool) error {
+	var (
+		dec   = xml.NewDecoder(r)
+		sizes []int // number of children of the open elements
+		text  strings.Builder
+		root  bool
+	)
+	dec.Strict = strict
+	if !strict {
+		dec.AutoClose = xml.HTMLAutoClose
+		dec.Entity = xml.HTMLEntity
+	}
+	dec.CharsetReader = charsetReader
+	// flush appends the pending character data to the open element.
+	flush := func() {
+		s := text.String()
+		text.Reset()
+		if len(sizes) == 0 || (!keepSpace && strings.TrimSpace(s) == "") {
+			return
+		}
+		sizes[len(sizes)-1]++
+		state.Push(s)
+		state.RawSetIndex(-2, sizes[len(sizes)-1])
+	}
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			flush()
+			if len(sizes) == 0 && root {
+				return errors.New("xml: multiple root elements")
+			}
+			if !state.CheckStack(2) {
+				return errors.New("xml: elements nested too deeply")
+			}
+			root = true
+			pushElement(state, tok)
+			sizes = append(sizes, 0)
+		case xml.EndElement:
+			flush()
+			sizes = sizes[:len(sizes)-1]
+			if len(sizes) > 0 {
+				sizes[len(sizes)-1]++
+				state.RawSetIndex(-2, sizes[len(sizes)-1])
+			}
+		case xml.CharData:
+			text.Write(tok)
+		}
+	}
+	if !root {
+		return errors.New("xml: no root element")
+	}
+	if len(sizes) > 0 {
+		return errors.New("xml: unexpected end of document")
+	}
+	return nil
+}
+
+// pushElement pushes a new element for the start tag tok.
+func pushElement(state *lua.State, tok xml.StartElement) {
+	state.NewTable()
+	state.Push(tok.Name.Local)
+	state.SetField(-2, "tag")
+	if tok.Name.Space != "" {
+		state.Push(tok.Name.Space)
+		state.SetField(-2, "ns")
+	}
+	state.NewTableSize(0, len(tok.Attr))
+	for _, attr := range tok.Attr {
+		if attr.Name.Space == "xmlns" || (attr.Name.Space == "" && attr.Name.Local == "xmlns") {
+			continue // namespace declarations are resolved by the decoder
+		}
+		state.Push(attr.Value)
+		state.SetField(-2, attr.Name.Local)
+	}
+	state.SetField(-2, "attr")
+	state.SetMetaTable(elementTypeName)
+}
+
+// charsetReader decodes the legacy single-byte encodings ISO-8859-1 and
+// US-ASCII, besides UTF-8 which is supported by the decoder.
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "iso-8859-1", "iso8859-1", "latin1", "latin-1", "us-ascii", "ascii":
+		b, err := ioutil.ReadAll(input)
+		if err != nil {
+			return nil, err
+		}
+		var s strings.Builder
+		s.Grow(len(b))
+		for _, c := range b {
+			s.WriteRune(rune(c))
+		}
+		return strings.NewReader(s.String()), nil
+	}
+	return nil, fmt.Errorf("unsupported charset '%s'", charset)
+}
+
+// element:find (path)
+//
+// Returns the first element matching path, or nil if there is none.
+func elementFind(state *lua.State) int {
+	matches := query(state, 1, state.CheckString(2), true)
+	if len(matches) == 0 {
+		state.Push(nil)
+	} else {
+		state.Push(matches[0])
+	}
+	return 1
+}
+
+// element:findall (path)
+//
+// Returns a sequence of the elements matching path, in document order.
+func elementFindAll(state *lua.State) int {
+	matches := query(state, 1, state.CheckString(2), false)
+	state.NewTableSize(len(matches), 0)
+	for i, elem := range matches {
+		state.Push(elem)
+		state.RawSetIndex(-2, i+1)
+	}
+	return 1
+}
+
+// element:get (name [, default])
+//
+// Returns the value of the attribute name, or default if the element has no
+// such attribute.
+func elementGet(state *lua.State) int {
+	elem := checkElement(state, 1)
+	name := state.CheckString(2)
+	if attr, ok := elem.Index(lua.String("attr")).(lua.Table); ok {
+		if v, ok := attr.Index(lua.String(name)).(lua.String); ok {
+			state.Push(v)
+			return 1
+		}
+	}
+	state.PushIndex(3)
+	return 1
+}
+
+// element:text ()
+//
+// Returns the character data of the element and its descendants, in document
+// order.
+func elementText(state *lua.State) int {
+	var b strings.Builder
+	var walk func(elem lua.Table)
+	walk = func(elem lua.Table) {
+		for i, n := 1, elem.Length(); i <= n; i++ {
+			switch child := elem.Index(lua.Int(i)).(type) {
+			case lua.String:
+				b.WriteString(string(child))
+			case lua.Table:
+				walk(child)
+			}
+		}
+	}
+	walk(checkElement(state, 1))
+	state.Push(b.String())
+	return 1
+}
+
+func checkElement(state *lua.State, index int) lua.Table {
+	state.CheckType(index, lua.TableType)
+	return state.ToTable(index)
+}
+
+// step is a step of a path.
+type step struct {
+	descendants bool   // select descendants instead of children
+	tag         string // tag name, "*" or "."
+	preds       []pred
+}
+
+// pred is a predicate of a step.
+type pred struct {
+	attr     bool   // test an attribute instead of a child
+	name     string // attribute or child name
+	value    string
+	hasValue bool
+	pos      int // position, if positive
+}
+
+// query returns the elements matching path from the element at index.
+func query(state *lua.State, index int, path string, first bool) []lua.Value {
+	elem := checkElement(state, index)
+	steps, err := parsePath(path)
+	if err != nil {
+		state.ArgError(index+1, err.Error())
+	}
+	set := []lua.Table{elem}
+	for _, s := range steps {
+		var next []lua.Table
+		seen := make(map[lua.Table]bool)
+		for _, e := range set {
+			var cands []lua.Table
+			switch {
+			case s.tag == "." && !s.descendants:
+				cands = []lua.Table{e}
+			case s.descendants:
+				cands = descendants(e, s.tag == ".", nil)
+			default:
+				cands = children(e)
+			}
+			matched := 0
+			for _, c := range cands {
+				if s.tag != "." && s.tag != "*" && tagOf(c) != s.tag {
+					continue
+				}
+				if !s.test(c, &matched) || seen[c] {
+					continue
+				}
+				seen[c] = true
+				next = append(next, c)
+			}
+		}
+		set = next
+	}
+	if first && len(set) > 1 {
+		set = set[:1]
+	}
+	values := make([]lua.Value, len(set))
+	for i, e := range set {
+		values[i] = e
+	}
+	return values
+}
+
+// test reports whether the element e satisfies the predicates of the step;
+// matched counts the elements that satisfied the predicates before a
+// position.
+func (s step) test(e lua.Table, matched *int) bool {
+	for _, p := range s.preds {
+		switch {
+		case p.pos > 0:
+			// positions count the matches of the preceding predicates
+			*matched++
+			return *matched == p.pos
+		case p.attr:
+			attr, ok := e.Index(lua.String("attr")).(lua.Table)
+			if !ok {
+				return false
+			}
+			v, ok := attr.Index(lua.String(p.name)).(lua.String)
+			if !ok || (p.hasValue && string(v) != p.value) {
+				return false
+			}
+		default:
+			found := false
+			for _, c := range children(e) {
+				if tagOf(c) == p.name && (!p.hasValue || textOf(c) == p.value) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// children returns the child elements of e.
+func children(e lua.Table) []lua.Table {
+	var list []lua.Table
+	for i, n := 1, e.Length(); i <= n; i++ {
+		if c, ok := e.Index(lua.Int(i)).(lua.Table); ok {
+			list = append(list, c)
+		}
+	}
+	return list
+}
+
+// descendants appends the descendant elements of e, and e itself if self is
+// true, to list in document order.
+func descendants(e lua.Table, self bool, list []lua.Table) []lua.Table {
+	if self {
+		list = append(list, e)
+	}
+	for _, c := range children(e) {
+		list = descendants(c, true, list)
+	}
+	return list
+}
+
+func tagOf(e lua.Table) string {
+	tag, _ := e.Index(lua.String("tag")).(lua.String)
+	return string(tag)
+}
+
+// textOf returns the direct character data of e.
+func textOf(e lua.Table) string {
+	var b strings.Builder
+	for i, n := 1, e.Length(); i <= n; i++ {
+		if s, ok := e.Index(lua.Int(i)).(lua.String); ok {
+			b.WriteString(string(s))
+		}
+	}
+	return b.String()
+}
+
+// parsePath parses a path into steps.
+func parsePath(path string) ([]step, error) {
+	var (
+		steps       []step
+		descendants bool
+	)
+	for i := 0; ; {
+		j := i
+		for j < len(path) && path[j] != '/' && path[j] != '[' {
+			j++
+		}
+		s := step{descendants: descendants, tag: path[i:j]}
+		if s.tag == "" || s.tag == ".." {
+			return nil, fmt.Errorf("invalid path '%s'", path)
+		}
+		for j < len(path) && path[j] == '[' {
+			end := closing(path, j)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated predicate in path '%s'", path)
+			}
+			p, err := parsePred(path[j+1 : end])
+			if err != nil {
+				return nil, err
+			}
+			if n := len(s.preds); n > 0 && s.preds[n-1].pos > 0 {
+				return nil, fmt.Errorf("position must be the last predicate in path '%s'", path)
+			}
+			s.preds = append(s.preds, p)
+			j = end + 1
+		}
+		steps = append(steps, s)
+		if j == len(path) {
+			return steps, nil
+		}
+		if path[j] != '/' {
+			return nil, fmt.Errorf("invalid path '%s'", path)
+		}
+		j++
+		if descendants = j < len(path) && path[j] == '/'; descendants {
+			j++
+		}
+		i = j
+	}
+}
+
+// closing returns the index of the bracket closing the predicate opened at i,
+// or -1.
+func closing(path string, i int) int {
+	var quote byte
+	for ; i < len(path); i++ {
+		switch c := path[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == ']':
+			return i
+		}
+	}
+	return -1
+}
+
+// parsePred parses the predicate s, without brackets.
+func parsePred(s string) (p pred, err error) {
+	s = strings.TrimSpace(s)
+	if s != "" && s[0] >= '0' && s[0] <= '9' {
+		if _, err := fmt.Sscanf(s, "%d", &p.pos); err != nil || p.pos < 1 || fmt.Sprint(p.pos) != s {
+			return p, fmt.Errorf("invalid position '%s'", s)
+		}
+		return p, nil
+	}
+	if strings.HasPrefix(s, "@") {
+		p.attr = true
+		s = s[1:]
+	}
+	if i := strings.IndexByte(s, '='); i >= 0 {
+		v := strings.TrimSpace(s[i+1:])
+		if len(v) < 2 || (v[0] != '\'' && v[0] != '"') || v[len(v)-1] != v[0] {
+			return p, fmt.Errorf("invalid predicate value '%s'", v)
+		}
+		p.value, p.hasValue = v[1:len(v)-1], true
+		s = strings.TrimSpace(s[:i])
+	}
+	if p.name = s; s == "" || !utf8.ValidString(s) || strings.ContainsAny(s, "[]/ '\"") {
+		return p, fmt.Errorf("invalid predicate name '%s'", s)
+	}
+	return p, nil
+}
@@ -0,0 +1,142 @@
+package std
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Azure/golua/lua"
+)
+
+const shop = `<shop xmlns:g="urn:game">
+  <item id="1"><name>Sword</name><price>12</price></item>
+  <item id="2" rare="yes"><name>Shield</name><price>8</price></item>
+  <g:box id="3"><item id="4"><name>Potion</name></item></g:box>
+</shop>`
+
+func TestXML(t *testing.T) {
+	state := lua.NewState()
+	defer state.Close()
+	Open(state)
+	lib := require(t, state, "xml")
+
+	// parse returns the root element of text, or the error.
+	parse := func(text string, opts map[string]interface{}) (lua.Value, string) {
+		defer state.SetTop(0)
+		state.Push(lib)
+		state.GetField(-1, "parse")
+		state.Push(text)
+		state.PushValue(opts)
+		state.Call(2, 2)
+		if state.IsNil(-2) {
+			return nil, state.ToString(-1)
+		}
+		return state.CheckAny(-2), ""
+	}
+	// label returns the id attribute of elem, or its text if it has none.
+	label := func(elem lua.Value) string {
+		if id := method(t, state, elem, "get", "id"); id != lua.Nil(1) && !lua.IsNone(id) {
+			return id.String()
+		}
+		return method(t, state, elem, "text").String()
+	}
+	// findall returns the labels of the elements matching path, or the error.
+	findall := func(elem lua.Value, path string) string {
+		defer state.SetTop(0)
+		state.Push(elem)
+		state.GetField(-1, "findall")
+		state.Insert(-2)
+		state.Push(path)
+		if err := state.PCall(2, 1, 0); err != nil {
+			return err.Error()
+		}
+		var labels []string
+		for i := 1; state.RawGetIndex(1, i) == lua.TableType; i++ {
+			labels = append(labels, label(state.Pop()))
+		}
+		return strings.Join(labels, " ")
+	}
+
+	root, err := parse(shop, nil)
+	if err != "" {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		path string
+		want string
+	}{
+		{"item", "1 2"},
+		{"*", "1 2 3"},
+		{".", "Sword12Shield8Potion"},
+		{".//item", "1 2 4"},
+		{".//item[2]", "2"},
+		{"item[@rare]", "2"},
+		{"item[@id='1']", "1"},
+		{`item[@id="4"]`, ""},
+		{"item[name='Shield']", "2"},
+		{"item[price][2]", "2"},
+		{"item[3]", ""},
+		{"box/item", "4"},
+		{"./item/name", "Sword Shield"},
+		{"*//name", "Sword Shield Potion"},
+		{"missing", ""},
+		{"", "bad argument #2 to '?' (invalid path '')"},
+		{"item/", "bad argument #2 to '?' (invalid path 'item/')"},
+		{"../item", "bad argument #2 to '?' (invalid path '../item')"},
+		{"item[@id", "bad argument #2 to '?' (unterminated predicate in path 'item[@id')"},
+		{"item[1][@id]", "bad argument #2 to '?' (position must be the last predicate in path 'item[1][@id]')"},
+		{"item[0]", "bad argument #2 to '?' (invalid position '0')"},
+		{"item[@id=1]", "bad argument #2 to '?' (invalid predicate value '1')"},
+		{"item[@]", "bad argument #2 to '?' (invalid predicate name '')"},
+	}
+	for _, tt := range tests {
+		if got := findall(root, tt.path); got != tt.want {
+			t.Errorf("findall(%q) = %s; want %s", tt.path, got, tt.want)
+		}
+	}
+
+	shield := method(t, state, root, "find", "item[@rare='yes']")
+	if got := label(shield); got != "2" {
+		t.Errorf("find = %s; want 2", got)
+	}
+	if got := method(t, state, root, "find", "missing"); got != lua.Nil(1) && !lua.IsNone(got) {
+		t.Errorf("find of a missing element = %v", got)
+	}
+	if got := method(t, state, shield, "get", "color", "grey"); got != lua.String("grey") {
+		t.Errorf("get with a default = %v", got)
+	}
+	if got := method(t, state, shield, "text"); got != lua.String("Shield8") {
+		t.Errorf("text = %v", got)
+	}
+	box := method(t, state, root, "find", "box").(lua.Table)
+	if ns := box.Index(lua.String("ns")); ns != lua.String("urn:game") {
+		t.Errorf("box.ns = %v", ns)
+	}
+	if n := root.(lua.Table).Length(); n != 3 {
+		t.Errorf("root has %d children; want 3 without whitespace", n)
+	}
+	if spaced, _ := parse(shop, map[string]interface{}{"whitespace": true}); spaced.(lua.Table).Length() != 7 {
+		t.Errorf("root has %d children; want 7 with whitespace", spaced.(lua.Table).Length())
+	}
+
+	for _, tt := range []struct {
+		text string
+		opts map[string]interface{}
+		want string // the text of the document or the end of the error
+	}{
+		{"<?xml version='1.0' encoding='ISO-8859-1'?><a>caf\xe9</a>", nil, "café"},
+		{"<p>a<br>b &nbsp;</p>", map[string]interface{}{"strict": false}, "ab  "},
+		{"<p>a<br>b</p>", nil, "XML syntax error on line 1: element <br> closed by </p>"},
+		{"<?xml version='1.0' encoding='koi8-r'?><a/>", nil, "unsupported charset 'koi8-r'"},
+		{"<a/><b/>", nil, "xml: multiple root elements"},
+		{"  ", nil, "xml: no root element"},
+		{"<a><b></b>", nil, "XML syntax error on line 1: unexpected EOF"},
+	} {
+		doc, err := parse(tt.text, tt.opts)
+		if doc != nil {
+			err = method(t, state, doc, "text").String()
+		}
+		if doc != nil && err != tt.want || doc == nil && !strings.HasSuffix(err, tt.want) {
+			t.Errorf("parse(%q) = %q; want %q", tt.text, err, tt.want)
+		}
+	}
+}